@@ -32,46 +32,30 @@ func BQL() *Grammar {
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemQuery),
+					NewSymbol("SELECT_DISTINCT"),
 					NewSymbol("VARS"),
 					NewTokenType(lexer.ItemFrom),
-					NewSymbol("GRAPHS"),
-					NewSymbol("WHERE"),
+					NewSymbol("QUERY_SOURCE"),
 					NewSymbol("GROUP_BY"),
 					NewSymbol("ORDER_BY"),
 					NewSymbol("HAVING"),
 					NewSymbol("GLOBAL_TIME_BOUND"),
 					NewSymbol("LIMIT"),
+					NewSymbol("OFFSET"),
+					NewSymbol("TEXT_MATCHING"),
 					NewTokenType(lexer.ItemSemicolon),
 				},
 			},
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemInsert),
-					NewTokenType(lexer.ItemData),
-					NewTokenType(lexer.ItemInto),
-					NewSymbol("GRAPHS"),
-					NewTokenType(lexer.ItemLBracket),
-					NewTokenType(lexer.ItemNode),
-					NewTokenType(lexer.ItemPredicate),
-					NewSymbol("INSERT_OBJECT"),
-					NewSymbol("INSERT_DATA"),
-					NewTokenType(lexer.ItemRBracket),
-					NewTokenType(lexer.ItemSemicolon),
+					NewSymbol("INSERT_SOURCE"),
 				},
 			},
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemDelete),
-					NewTokenType(lexer.ItemData),
-					NewTokenType(lexer.ItemFrom),
-					NewSymbol("GRAPHS"),
-					NewTokenType(lexer.ItemLBracket),
-					NewTokenType(lexer.ItemNode),
-					NewTokenType(lexer.ItemPredicate),
-					NewSymbol("DELETE_OBJECT"),
-					NewSymbol("DELETE_DATA"),
-					NewTokenType(lexer.ItemRBracket),
-					NewTokenType(lexer.ItemSemicolon),
+					NewSymbol("DELETE_SOURCE"),
 				},
 			},
 			{
@@ -88,12 +72,39 @@ func BQL() *Grammar {
 					NewTokenType(lexer.ItemSemicolon),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemTruncate),
+					NewSymbol("TRUNCATE_GRAPHS"),
+					NewTokenType(lexer.ItemSemicolon),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemImport),
+					NewSymbol("IMPORT_SOURCE"),
+					NewTokenType(lexer.ItemSemicolon),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemShow),
+					NewSymbol("SHOW_TARGET"),
+					NewTokenType(lexer.ItemSemicolon),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemDescribe),
+					NewSymbol("DESCRIBE_GRAPHS"),
+					NewTokenType(lexer.ItemSemicolon),
+				},
+			},
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemConstruct),
 					NewSymbol("CONSTRUCT_FACTS"),
-					NewTokenType(lexer.ItemInto),
-					NewSymbol("GRAPHS"),
+					NewSymbol("CONSTRUCT_INTO"),
 					NewTokenType(lexer.ItemFrom),
 					NewSymbol("GRAPHS"),
 					NewSymbol("WHERE"),
@@ -101,14 +112,88 @@ func BQL() *Grammar {
 					NewTokenType(lexer.ItemSemicolon),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemFrequencies),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemRPar),
+					NewTokenType(lexer.ItemFrom),
+					NewSymbol("GRAPHS"),
+					NewSymbol("FREQUENCIES_SOURCE"),
+					NewSymbol("LIMIT"),
+					NewTokenType(lexer.ItemSemicolon),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemLatest),
+					NewTokenType(lexer.ItemLiteral),
+					NewTokenType(lexer.ItemPer),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemOf),
+					NewTokenType(lexer.ItemPredicate),
+					NewTokenType(lexer.ItemFrom),
+					NewSymbol("GRAPHS"),
+					NewSymbol("GLOBAL_TIME_BOUND"),
+					NewTokenType(lexer.ItemSemicolon),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemWeightedSample),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemComma),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemComma),
+					NewTokenType(lexer.ItemLiteral),
+					NewSymbol("SAMPLE_SEED"),
+					NewTokenType(lexer.ItemRPar),
+					NewTokenType(lexer.ItemFrom),
+					NewSymbol("GRAPHS"),
+					NewSymbol("WHERE"),
+					NewTokenType(lexer.ItemSemicolon),
+				},
+			},
+		},
+		"SAMPLE_SEED": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemComma),
+					NewTokenType(lexer.ItemLiteral),
+				},
+			},
+			{},
+		},
+		"FREQUENCIES_SOURCE": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemWhere),
+					NewTokenType(lexer.ItemLBracket),
+					NewSymbol("CLAUSES"),
+					NewTokenType(lexer.ItemRBracket),
+				},
+			},
+			{},
 		},
 		"CREATE_GRAPHS": []*Clause{
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemGraph),
 					NewSymbol("GRAPHS"),
+					NewSymbol("CREATE_SOURCE"),
+				},
+			},
+		},
+		"CREATE_SOURCE": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemBinding),
 				},
 			},
+			{},
 		},
 		"DROP_GRAPHS": []*Clause{
 			{
@@ -118,6 +203,46 @@ func BQL() *Grammar {
 				},
 			},
 		},
+		"TRUNCATE_GRAPHS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemGraph),
+					NewSymbol("GRAPHS"),
+				},
+			},
+		},
+		"DESCRIBE_GRAPHS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemGraph),
+					NewSymbol("GRAPHS"),
+				},
+			},
+		},
+		"IMPORT_SOURCE": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemString),
+					NewTokenType(lexer.ItemInto),
+					NewSymbol("GRAPHS"),
+				},
+			},
+		},
+		"SHOW_TARGET": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemGraphs),
+				},
+			},
+		},
+		"SELECT_DISTINCT": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemDistinct),
+				},
+			},
+			{},
+		},
 		"VARS": []*Clause{
 			{
 				Elements: []Element{
@@ -126,6 +251,11 @@ func BQL() *Grammar {
 					NewSymbol("MORE_VARS"),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemStar),
+				},
+			},
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemCount),
@@ -133,560 +263,1207 @@ func BQL() *Grammar {
 					NewSymbol("COUNT_DISTINCT"),
 					NewTokenType(lexer.ItemBinding),
 					NewTokenType(lexer.ItemRPar),
+					NewSymbol("WINDOW"),
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("MORE_VARS"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemSum),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemRPar),
+					NewSymbol("WINDOW"),
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("MORE_VARS"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemMin),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemRPar),
+					NewSymbol("WINDOW"),
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("MORE_VARS"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemMax),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemRPar),
+					NewSymbol("WINDOW"),
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("MORE_VARS"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemAvg),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemRPar),
+					NewSymbol("WINDOW"),
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("MORE_VARS"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemType),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemRPar),
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("MORE_VARS"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemID),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemRPar),
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("MORE_VARS"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemReify),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemRPar),
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("MORE_VARS"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemCoalesce),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemComma),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("COALESCE_BINDINGS"),
+					NewTokenType(lexer.ItemRPar),
 					NewTokenType(lexer.ItemAs),
 					NewTokenType(lexer.ItemBinding),
-					NewSymbol("MORE_VARS"),
+					NewSymbol("MORE_VARS"),
+				},
+			},
+		},
+		"COALESCE_BINDINGS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemComma),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("COALESCE_BINDINGS"),
+				},
+			},
+			{},
+		},
+		"COUNT_DISTINCT": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemDistinct),
+				},
+			},
+			{},
+		},
+		"WINDOW": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemOver),
+					NewTokenType(lexer.ItemLPar),
+					NewSymbol("WINDOW_PARTITION"),
+					NewTokenType(lexer.ItemOrder),
+					NewTokenType(lexer.ItemBy),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("WINDOW_ORDER_DIRECTION"),
+					NewSymbol("WINDOW_ORDER_BINDINGS"),
+					NewTokenType(lexer.ItemRPar),
+				},
+			},
+			{},
+		},
+		"WINDOW_PARTITION": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPartition),
+					NewTokenType(lexer.ItemBy),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("WINDOW_PARTITION_BINDINGS"),
+				},
+			},
+			{},
+		},
+		"WINDOW_PARTITION_BINDINGS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemComma),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("WINDOW_PARTITION_BINDINGS"),
+				},
+			},
+			{},
+		},
+		"WINDOW_ORDER_DIRECTION": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemAsc),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemDesc),
+				},
+			},
+			{},
+		},
+		"WINDOW_ORDER_BINDINGS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemComma),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("WINDOW_ORDER_DIRECTION"),
+					NewSymbol("WINDOW_ORDER_BINDINGS"),
+				},
+			},
+			{},
+		},
+		"VARS_AS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemBinding),
+				},
+			},
+			{},
+		},
+		"MORE_VARS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemComma),
+					NewSymbol("VARS"),
+				},
+			},
+			{},
+		},
+		"QUERY_SOURCE": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("MORE_GRAPHS"),
+					NewSymbol("AS_OF"),
+					NewSymbol("WHERE"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemSchema),
+					NewTokenType(lexer.ItemLPar),
+					NewSymbol("SCHEMA_SOURCE"),
+					NewTokenType(lexer.ItemRPar),
+				},
+			},
+		},
+		"SCHEMA_SOURCE": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("SCHEMA_ARGS"),
+				},
+			},
+		},
+		"SCHEMA_ARGS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemComma),
+					NewSymbol("SCHEMA_ARG"),
+				},
+			},
+			{},
+		},
+		"SCHEMA_ARG": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("SCHEMA_ARGS"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("SCHEMA_ARGS"),
+				},
+			},
+		},
+		"GRAPHS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("MORE_GRAPHS"),
+				},
+			},
+		},
+		"MORE_GRAPHS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemComma),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("MORE_GRAPHS"),
+				},
+			},
+			{},
+		},
+		"AS_OF": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemOf),
+					NewTokenType(lexer.ItemPredicate),
+				},
+			},
+			{},
+		},
+		"WHERE": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemWhere),
+					NewTokenType(lexer.ItemLBracket),
+					NewSymbol("CLAUSES"),
+					NewTokenType(lexer.ItemRBracket),
+				},
+			},
+		},
+		"CLAUSES": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemNode),
+					NewSymbol("SUBJECT_EXTRACT"),
+					NewSymbol("PREDICATE"),
+					NewSymbol("OBJECT"),
+					NewSymbol("MORE_CLAUSES"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("SUBJECT_NODE_TYPE"),
+					NewSymbol("SUBJECT_EXTRACT"),
+					NewSymbol("PREDICATE"),
+					NewSymbol("OBJECT"),
+					NewSymbol("MORE_CLAUSES"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBang),
+					NewTokenType(lexer.ItemLBracket),
+					NewSymbol("NEGATED_CLAUSE"),
+					NewTokenType(lexer.ItemRBracket),
+					NewSymbol("MORE_CLAUSES"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemNot),
+					NewTokenType(lexer.ItemExists),
+					NewTokenType(lexer.ItemLBracket),
+					NewSymbol("NOT_EXISTS_CLAUSES"),
+					NewTokenType(lexer.ItemRBracket),
+					NewSymbol("MORE_CLAUSES"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemFilter),
+					NewTokenType(lexer.ItemLPar),
+					NewSymbol("FILTER_CLAUSE"),
+					NewTokenType(lexer.ItemRPar),
+					NewSymbol("MORE_CLAUSES"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemLBracket),
+					NewSymbol("CLAUSES"),
+					NewTokenType(lexer.ItemRBracket),
+					NewTokenType(lexer.ItemUnion),
+					NewTokenType(lexer.ItemLBracket),
+					NewSymbol("CLAUSES"),
+					NewTokenType(lexer.ItemRBracket),
+					NewSymbol("UNION_BRANCHES"),
+				},
+			},
+		},
+		"UNION_BRANCHES": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemUnion),
+					NewTokenType(lexer.ItemLBracket),
+					NewSymbol("CLAUSES"),
+					NewTokenType(lexer.ItemRBracket),
+					NewSymbol("UNION_BRANCHES"),
+				},
+			},
+			{},
+		},
+		"NEGATED_CLAUSE": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemNode),
+					NewSymbol("SUBJECT_EXTRACT"),
+					NewSymbol("PREDICATE"),
+					NewSymbol("OBJECT"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("SUBJECT_NODE_TYPE"),
+					NewSymbol("SUBJECT_EXTRACT"),
+					NewSymbol("PREDICATE"),
+					NewSymbol("OBJECT"),
+				},
+			},
+		},
+		"NOT_EXISTS_CLAUSES": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemNode),
+					NewSymbol("SUBJECT_EXTRACT"),
+					NewSymbol("PREDICATE"),
+					NewSymbol("OBJECT"),
+					NewSymbol("MORE_NOT_EXISTS_CLAUSES"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("SUBJECT_NODE_TYPE"),
+					NewSymbol("SUBJECT_EXTRACT"),
+					NewSymbol("PREDICATE"),
+					NewSymbol("OBJECT"),
+					NewSymbol("MORE_NOT_EXISTS_CLAUSES"),
+				},
+			},
+		},
+		"MORE_NOT_EXISTS_CLAUSES": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemDot),
+					NewSymbol("NOT_EXISTS_CLAUSES"),
+				},
+			},
+			{},
+		},
+		"SUBJECT_NODE_TYPE": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemNodeType),
+				},
+			},
+			{},
+		},
+		"SUBJECT_EXTRACT": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("SUBJECT_TYPE"),
+					NewSymbol("SUBJECT_ID"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemType),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("SUBJECT_ID"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemID),
+					NewTokenType(lexer.ItemBinding),
+				},
+			},
+			{},
+		},
+		"SUBJECT_TYPE": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemType),
+					NewTokenType(lexer.ItemBinding),
+				},
+			},
+			{},
+		},
+		"SUBJECT_ID": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemID),
+					NewTokenType(lexer.ItemBinding),
+				},
+			},
+			{},
+		},
+		"PREDICATE": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("PREDICATE_PATH"),
+					NewSymbol("PREDICATE_AS"),
+					NewSymbol("PREDICATE_ID"),
+					NewSymbol("PREDICATE_AT"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPredicateBound),
+					NewSymbol("PREDICATE_AS"),
+					NewSymbol("PREDICATE_ID"),
+					NewSymbol("PREDICATE_BOUND_AT"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("PREDICATE_AS"),
+					NewSymbol("PREDICATE_ID"),
+					NewSymbol("PREDICATE_AT"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemPredicate),
+					NewTokenType(lexer.ItemPipe),
+					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("PREDICATE_ALTERNATIVES"),
+					NewTokenType(lexer.ItemRPar),
+					NewSymbol("PREDICATE_AS"),
+					NewSymbol("PREDICATE_ID"),
+					NewSymbol("PREDICATE_AT"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPrefix),
+					NewTokenType(lexer.ItemLPar),
+					NewSymbol("PREDICATE_PREFIX"),
+				},
+			},
+		},
+		"PREDICATE_PREFIX": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPredicate),
+					NewTokenType(lexer.ItemRPar),
+					NewSymbol("PREDICATE_AS"),
+					NewSymbol("PREDICATE_ID"),
+					NewSymbol("PREDICATE_AT"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPredicateBound),
+					NewTokenType(lexer.ItemRPar),
+					NewSymbol("PREDICATE_AS"),
+					NewSymbol("PREDICATE_ID"),
+					NewSymbol("PREDICATE_BOUND_AT"),
+				},
+			},
+		},
+		"PREDICATE_ALTERNATIVES": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPipe),
+					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("PREDICATE_ALTERNATIVES"),
+				},
+			},
+			{},
+		},
+		"PREDICATE_PATH": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPlus),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemStar),
+				},
+			},
+			{},
+		},
+		"PREDICATE_AS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemBinding),
+				},
+			},
+			{},
+		},
+		"PREDICATE_ID": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemID),
+					NewTokenType(lexer.ItemBinding),
+				},
+			},
+			{},
+		},
+		"PREDICATE_AT": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemAt),
+					NewTokenType(lexer.ItemBinding),
+				},
+			},
+			{},
+		},
+		"PREDICATE_BOUND_AT": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemAt),
+					NewSymbol("PREDICATE_BOUND_AT_BINDINGS"),
+				},
+			},
+			{},
+		},
+		"PREDICATE_BOUND_AT_BINDINGS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("PREDICATE_BOUND_AT_BINDINGS_END"),
+				},
+			},
+			{},
+		},
+		"PREDICATE_BOUND_AT_BINDINGS_END": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemComma),
+					NewTokenType(lexer.ItemBinding),
+				},
+			},
+			{},
+		},
+		"OBJECT": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemLiteral),
+					NewSymbol("OBJECT_LITERAL_AS"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemNode),
+					NewSymbol("OBJECT_SUBJECT_EXTRACT"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("OBJECT_PREDICATE_AS"),
+					NewSymbol("OBJECT_PREDICATE_ID"),
+					NewSymbol("OBJECT_PREDICATE_AT"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemPredicateBound),
+					NewSymbol("OBJECT_PREDICATE_AS"),
+					NewSymbol("OBJECT_PREDICATE_ID"),
+					NewSymbol("OBJECT_PREDICATE_BOUND_AT"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("OBJECT_NODE_TYPE"),
+					NewSymbol("OBJECT_LITERAL_BINDING_AS"),
+					NewSymbol("OBJECT_LITERAL_BINDING_TYPE"),
+					NewSymbol("OBJECT_LITERAL_BINDING_ID"),
+					NewSymbol("OBJECT_LITERAL_BINDING_AT"),
+				},
+			},
+		},
+		"OBJECT_NODE_TYPE": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemNodeType),
+				},
+			},
+			{},
+		},
+		"OBJECT_SUBJECT_EXTRACT": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("OBJECT_SUBJECT_TYPE"),
+					NewSymbol("OBJECT_SUBJECT_ID"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemType),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("OBJECT_SUBJECT_ID"),
 				},
 			},
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemSum),
-					NewTokenType(lexer.ItemLPar),
-					NewTokenType(lexer.ItemBinding),
-					NewTokenType(lexer.ItemRPar),
-					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemID),
 					NewTokenType(lexer.ItemBinding),
-					NewSymbol("MORE_VARS"),
 				},
 			},
+			{},
 		},
-		"COUNT_DISTINCT": []*Clause{
+		"OBJECT_SUBJECT_TYPE": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemDistinct),
+					NewTokenType(lexer.ItemType),
+					NewTokenType(lexer.ItemBinding),
 				},
 			},
 			{},
 		},
-		"VARS_AS": []*Clause{
+		"OBJECT_SUBJECT_ID": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemID),
 					NewTokenType(lexer.ItemBinding),
 				},
 			},
 			{},
 		},
-		"MORE_VARS": []*Clause{
+		"OBJECT_PREDICATE_AS": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemComma),
-					NewSymbol("VARS"),
+					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemBinding),
 				},
 			},
 			{},
 		},
-		"GRAPHS": []*Clause{
+		"OBJECT_PREDICATE_ID": []*Clause{
 			{
 				Elements: []Element{
+					NewTokenType(lexer.ItemID),
 					NewTokenType(lexer.ItemBinding),
-					NewSymbol("MORE_GRAPHS"),
 				},
 			},
+			{},
 		},
-		"MORE_GRAPHS": []*Clause{
+		"OBJECT_PREDICATE_AT": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemComma),
+					NewTokenType(lexer.ItemAt),
 					NewTokenType(lexer.ItemBinding),
-					NewSymbol("MORE_GRAPHS"),
 				},
 			},
 			{},
 		},
-		"WHERE": []*Clause{
+		"OBJECT_PREDICATE_BOUND_AT": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemWhere),
-					NewTokenType(lexer.ItemLBracket),
-					NewSymbol("CLAUSES"),
-					NewTokenType(lexer.ItemRBracket),
+					NewTokenType(lexer.ItemAt),
+					NewSymbol("OBJECT_PREDICATE_BOUND_AT_BINDINGS"),
 				},
 			},
+			{},
 		},
-		"CLAUSES": []*Clause{
+		"OBJECT_PREDICATE_BOUND_AT_BINDINGS": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemNode),
-					NewSymbol("SUBJECT_EXTRACT"),
-					NewSymbol("PREDICATE"),
-					NewSymbol("OBJECT"),
-					NewSymbol("MORE_CLAUSES"),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("OBJECT_PREDICATE_BOUND_AT_BINDINGS_END"),
 				},
 			},
+			{},
+		},
+		"OBJECT_PREDICATE_BOUND_AT_BINDINGS_END": []*Clause{
 			{
 				Elements: []Element{
+					NewTokenType(lexer.ItemComma),
 					NewTokenType(lexer.ItemBinding),
-					NewSymbol("SUBJECT_EXTRACT"),
-					NewSymbol("PREDICATE"),
-					NewSymbol("OBJECT"),
-					NewSymbol("MORE_CLAUSES"),
 				},
 			},
+			{},
 		},
-		"SUBJECT_EXTRACT": []*Clause{
+		"OBJECT_LITERAL_AS": []*Clause{
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemAs),
 					NewTokenType(lexer.ItemBinding),
-					NewSymbol("SUBJECT_TYPE"),
-					NewSymbol("SUBJECT_ID"),
 				},
 			},
+			{},
+		},
+		"OBJECT_LITERAL_BINDING_AS": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemType),
+					NewTokenType(lexer.ItemAs),
 					NewTokenType(lexer.ItemBinding),
-					NewSymbol("SUBJECT_ID"),
 				},
 			},
+			{},
+		},
+		"OBJECT_LITERAL_BINDING_TYPE": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemID),
+					NewTokenType(lexer.ItemType),
 					NewTokenType(lexer.ItemBinding),
 				},
 			},
 			{},
 		},
-		"SUBJECT_TYPE": []*Clause{
+		"OBJECT_LITERAL_BINDING_ID": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemType),
+					NewTokenType(lexer.ItemID),
 					NewTokenType(lexer.ItemBinding),
 				},
 			},
 			{},
 		},
-		"SUBJECT_ID": []*Clause{
+		"OBJECT_LITERAL_BINDING_AT": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemID),
+					NewTokenType(lexer.ItemAt),
 					NewTokenType(lexer.ItemBinding),
 				},
 			},
 			{},
 		},
-		"PREDICATE": []*Clause{
+		"MORE_CLAUSES": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemPredicate),
-					NewSymbol("PREDICATE_AS"),
-					NewSymbol("PREDICATE_ID"),
-					NewSymbol("PREDICATE_AT"),
+					NewTokenType(lexer.ItemDot),
+					NewSymbol("CLAUSES"),
 				},
 			},
+			{},
+		},
+		"GROUP_BY": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemPredicateBound),
-					NewSymbol("PREDICATE_AS"),
-					NewSymbol("PREDICATE_ID"),
-					NewSymbol("PREDICATE_BOUND_AT"),
+					NewTokenType(lexer.ItemGroup),
+					NewTokenType(lexer.ItemBy),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("GROUP_BY_BINDINGS"),
 				},
 			},
+			{},
+		},
+		"GROUP_BY_BINDINGS": []*Clause{
 			{
 				Elements: []Element{
+					NewTokenType(lexer.ItemComma),
 					NewTokenType(lexer.ItemBinding),
-					NewSymbol("PREDICATE_AS"),
-					NewSymbol("PREDICATE_ID"),
-					NewSymbol("PREDICATE_AT"),
+					NewSymbol("GROUP_BY_BINDINGS"),
 				},
 			},
+			{},
 		},
-		"PREDICATE_AS": []*Clause{
+		"ORDER_BY": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemOrder),
+					NewTokenType(lexer.ItemBy),
 					NewTokenType(lexer.ItemBinding),
+					NewSymbol("ORDER_BY_DIRECTION"),
+					NewSymbol("ORDER_BY_BINDINGS"),
 				},
 			},
 			{},
 		},
-		"PREDICATE_ID": []*Clause{
+		"ORDER_BY_DIRECTION": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemID),
-					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemAsc),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemDesc),
 				},
 			},
 			{},
 		},
-		"PREDICATE_AT": []*Clause{
+		"ORDER_BY_BINDINGS": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemAt),
+					NewTokenType(lexer.ItemComma),
 					NewTokenType(lexer.ItemBinding),
+					NewSymbol("ORDER_BY_DIRECTION"),
+					NewSymbol("ORDER_BY_BINDINGS"),
 				},
 			},
 			{},
 		},
-		"PREDICATE_BOUND_AT": []*Clause{
+		"HAVING": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemAt),
-					NewSymbol("PREDICATE_BOUND_AT_BINDINGS"),
+					NewTokenType(lexer.ItemHaving),
+					NewSymbol("HAVING_CLAUSE"),
 				},
 			},
 			{},
 		},
-		"PREDICATE_BOUND_AT_BINDINGS": []*Clause{
+		"HAVING_CLAUSE": []*Clause{
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemBinding),
-					NewSymbol("PREDICATE_BOUND_AT_BINDINGS_END"),
+					NewSymbol("HAVING_CLAUSE_BINARY_COMPOSITE"),
 				},
 			},
-			{},
-		},
-		"PREDICATE_BOUND_AT_BINDINGS_END": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemComma),
-					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemNot),
+					NewSymbol("HAVING_CLAUSE"),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemLPar),
+					NewSymbol("HAVING_CLAUSE"),
+					NewTokenType(lexer.ItemRPar),
+					NewSymbol("HAVING_CLAUSE_BINARY_COMPOSITE"),
 				},
 			},
-			{},
 		},
-		"OBJECT": []*Clause{
+		"HAVING_CLAUSE_BINARY_COMPOSITE": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemLiteral),
-					NewSymbol("OBJECT_LITERAL_AS"),
+					NewTokenType(lexer.ItemAnd),
+					NewSymbol("HAVING_CLAUSE"),
 				},
 			},
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemNode),
-					NewSymbol("OBJECT_SUBJECT_EXTRACT"),
+					NewTokenType(lexer.ItemOr),
+					NewSymbol("HAVING_CLAUSE"),
 				},
 			},
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemPredicate),
-					NewSymbol("OBJECT_PREDICATE_AS"),
-					NewSymbol("OBJECT_PREDICATE_ID"),
-					NewSymbol("OBJECT_PREDICATE_AT"),
+					NewTokenType(lexer.ItemEQ),
+					NewSymbol("HAVING_CLAUSE"),
 				},
 			},
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemPredicateBound),
-					NewSymbol("OBJECT_PREDICATE_AS"),
-					NewSymbol("OBJECT_PREDICATE_ID"),
-					NewSymbol("OBJECT_PREDICATE_BOUND_AT"),
+					NewTokenType(lexer.ItemLT),
+					NewSymbol("HAVING_CLAUSE"),
 				},
 			},
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemBinding),
-					NewSymbol("OBJECT_LITERAL_BINDING_AS"),
-					NewSymbol("OBJECT_LITERAL_BINDING_TYPE"),
-					NewSymbol("OBJECT_LITERAL_BINDING_ID"),
-					NewSymbol("OBJECT_LITERAL_BINDING_AT"),
+					NewTokenType(lexer.ItemGT),
+					NewSymbol("HAVING_CLAUSE"),
 				},
 			},
+			{},
 		},
-		"OBJECT_SUBJECT_EXTRACT": []*Clause{
+		"FILTER_CLAUSE": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemAs),
-					NewTokenType(lexer.ItemBinding),
-					NewSymbol("OBJECT_SUBJECT_TYPE"),
-					NewSymbol("OBJECT_SUBJECT_ID"),
+					NewTokenType(lexer.ItemNode),
+					NewSymbol("FILTER_CLAUSE_BINARY_COMPOSITE"),
 				},
 			},
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemType),
 					NewTokenType(lexer.ItemBinding),
-					NewSymbol("OBJECT_SUBJECT_ID"),
+					NewSymbol("FILTER_CLAUSE_BINARY_COMPOSITE"),
 				},
 			},
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemID),
-					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemLiteral),
+					NewSymbol("FILTER_CLAUSE_BINARY_COMPOSITE"),
 				},
 			},
-			{},
-		},
-		"OBJECT_SUBJECT_TYPE": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemType),
-					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("FILTER_CLAUSE_BINARY_COMPOSITE"),
 				},
 			},
-			{},
-		},
-		"OBJECT_SUBJECT_ID": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemID),
-					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemParameter),
+					NewSymbol("FILTER_CLAUSE_BINARY_COMPOSITE"),
 				},
 			},
-			{},
-		},
-		"OBJECT_PREDICATE_AS": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemAs),
+					NewTokenType(lexer.ItemFuzzy),
+					NewTokenType(lexer.ItemLPar),
 					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemComma),
+					NewTokenType(lexer.ItemLiteral),
+					NewTokenType(lexer.ItemComma),
+					NewTokenType(lexer.ItemLiteral),
+					NewTokenType(lexer.ItemRPar),
+					NewSymbol("FILTER_CLAUSE_BINARY_COMPOSITE"),
 				},
 			},
-			{},
-		},
-		"OBJECT_PREDICATE_ID": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemID),
-					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemStartsWith),
+					NewTokenType(lexer.ItemLPar),
+					NewSymbol("FILTER_TEXT_OPERAND"),
+					NewTokenType(lexer.ItemComma),
+					NewTokenType(lexer.ItemLiteral),
+					NewTokenType(lexer.ItemRPar),
+					NewSymbol("FILTER_CLAUSE_BINARY_COMPOSITE"),
 				},
 			},
-			{},
-		},
-		"OBJECT_PREDICATE_AT": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemAt),
-					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemNot),
+					NewSymbol("FILTER_CLAUSE"),
 				},
 			},
-			{},
-		},
-		"OBJECT_PREDICATE_BOUND_AT": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemAt),
-					NewSymbol("OBJECT_PREDICATE_BOUND_AT_BINDINGS"),
+					NewTokenType(lexer.ItemLPar),
+					NewSymbol("FILTER_CLAUSE"),
+					NewTokenType(lexer.ItemRPar),
+					NewSymbol("FILTER_CLAUSE_BINARY_COMPOSITE"),
 				},
 			},
-			{},
 		},
-		"OBJECT_PREDICATE_BOUND_AT_BINDINGS": []*Clause{
+		"FILTER_TEXT_OPERAND": []*Clause{
 			{
 				Elements: []Element{
+					NewTokenType(lexer.ItemID),
+					NewTokenType(lexer.ItemLPar),
 					NewTokenType(lexer.ItemBinding),
-					NewSymbol("OBJECT_PREDICATE_BOUND_AT_BINDINGS_END"),
+					NewTokenType(lexer.ItemRPar),
 				},
 			},
-			{},
-		},
-		"OBJECT_PREDICATE_BOUND_AT_BINDINGS_END": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemComma),
 					NewTokenType(lexer.ItemBinding),
 				},
 			},
-			{},
 		},
-		"OBJECT_LITERAL_AS": []*Clause{
+		"FILTER_CLAUSE_BINARY_COMPOSITE": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemAs),
-					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemAnd),
+					NewSymbol("FILTER_CLAUSE"),
 				},
 			},
-			{},
-		},
-		"OBJECT_LITERAL_BINDING_AS": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemAs),
-					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemOr),
+					NewSymbol("FILTER_CLAUSE"),
 				},
 			},
-			{},
-		},
-		"OBJECT_LITERAL_BINDING_TYPE": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemType),
-					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemEQ),
+					NewSymbol("FILTER_CLAUSE"),
 				},
 			},
-			{},
-		},
-		"OBJECT_LITERAL_BINDING_ID": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemID),
-					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemNEQ),
+					NewSymbol("FILTER_CLAUSE"),
 				},
 			},
-			{},
-		},
-		"OBJECT_LITERAL_BINDING_AT": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemAt),
-					NewTokenType(lexer.ItemBinding),
+					NewTokenType(lexer.ItemLT),
+					NewSymbol("FILTER_CLAUSE"),
 				},
 			},
-			{},
-		},
-		"MORE_CLAUSES": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemDot),
-					NewSymbol("CLAUSES"),
+					NewTokenType(lexer.ItemLEQ),
+					NewSymbol("FILTER_CLAUSE"),
 				},
 			},
-			{},
-		},
-		"GROUP_BY": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemGroup),
-					NewTokenType(lexer.ItemBy),
-					NewTokenType(lexer.ItemBinding),
-					NewSymbol("GROUP_BY_BINDINGS"),
+					NewTokenType(lexer.ItemGT),
+					NewSymbol("FILTER_CLAUSE"),
 				},
 			},
-			{},
-		},
-		"GROUP_BY_BINDINGS": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemComma),
-					NewTokenType(lexer.ItemBinding),
-					NewSymbol("GROUP_BY_BINDINGS"),
+					NewTokenType(lexer.ItemGEQ),
+					NewSymbol("FILTER_CLAUSE"),
 				},
 			},
-			{},
-		},
-		"ORDER_BY": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemOrder),
-					NewTokenType(lexer.ItemBy),
-					NewTokenType(lexer.ItemBinding),
-					NewSymbol("ORDER_BY_DIRECTION"),
-					NewSymbol("ORDER_BY_BINDINGS"),
+					NewTokenType(lexer.ItemMatch),
+					NewSymbol("FILTER_CLAUSE"),
 				},
 			},
 			{},
 		},
-		"ORDER_BY_DIRECTION": []*Clause{
+		"GLOBAL_TIME_BOUND": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemAsc),
+					NewTokenType(lexer.ItemBefore),
+					NewTokenType(lexer.ItemPredicate),
 				},
 			},
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemDesc),
+					NewTokenType(lexer.ItemAfter),
+					NewTokenType(lexer.ItemPredicate),
 				},
 			},
-			{},
-		},
-		"ORDER_BY_BINDINGS": []*Clause{
 			{
 				Elements: []Element{
+					NewTokenType(lexer.ItemBetween),
+					NewTokenType(lexer.ItemPredicate),
 					NewTokenType(lexer.ItemComma),
-					NewTokenType(lexer.ItemBinding),
-					NewSymbol("ORDER_BY_DIRECTION"),
-					NewSymbol("ORDER_BY_BINDINGS"),
+					NewTokenType(lexer.ItemPredicate),
 				},
 			},
 			{},
 		},
-		"HAVING": []*Clause{
+		"LIMIT": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemHaving),
-					NewSymbol("HAVING_CLAUSE"),
+					NewTokenType(lexer.ItemLimit),
+					NewSymbol("LIMIT_VALUE"),
 				},
 			},
 			{},
 		},
-		"HAVING_CLAUSE": []*Clause{
+		"LIMIT_VALUE": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemBinding),
-					NewSymbol("HAVING_CLAUSE_BINARY_COMPOSITE"),
+					NewTokenType(lexer.ItemLiteral),
 				},
 			},
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemNot),
-					NewSymbol("HAVING_CLAUSE"),
+					NewTokenType(lexer.ItemNumber),
 				},
 			},
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemLPar),
-					NewSymbol("HAVING_CLAUSE"),
-					NewTokenType(lexer.ItemRPar),
-					NewSymbol("HAVING_CLAUSE_BINARY_COMPOSITE"),
+					NewTokenType(lexer.ItemParameter),
 				},
 			},
 		},
-		"HAVING_CLAUSE_BINARY_COMPOSITE": []*Clause{
+		"OFFSET": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemAnd),
-					NewSymbol("HAVING_CLAUSE"),
+					NewTokenType(lexer.ItemOffset),
+					NewSymbol("OFFSET_VALUE"),
 				},
 			},
+			{},
+		},
+		"OFFSET_VALUE": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemOr),
-					NewSymbol("HAVING_CLAUSE"),
+					NewTokenType(lexer.ItemLiteral),
 				},
 			},
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemEQ),
-					NewSymbol("HAVING_CLAUSE"),
+					NewTokenType(lexer.ItemNumber),
 				},
 			},
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemLT),
-					NewSymbol("HAVING_CLAUSE"),
+					NewTokenType(lexer.ItemParameter),
 				},
 			},
+		},
+		"TEXT_MATCHING": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemGT),
-					NewSymbol("HAVING_CLAUSE"),
+					NewTokenType(lexer.ItemIgnoreCase),
 				},
 			},
 			{},
 		},
-		"GLOBAL_TIME_BOUND": []*Clause{
+		"INSERT_SOURCE": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemBefore),
+					NewTokenType(lexer.ItemData),
+					NewTokenType(lexer.ItemInto),
+					NewSymbol("GRAPHS"),
+					NewTokenType(lexer.ItemLBracket),
+					NewTokenType(lexer.ItemNode),
 					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("INSERT_OBJECT"),
+					NewSymbol("INSERT_DATA"),
+					NewTokenType(lexer.ItemRBracket),
+					NewTokenType(lexer.ItemSemicolon),
 				},
 			},
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemAfter),
-					NewTokenType(lexer.ItemPredicate),
+					NewTokenType(lexer.ItemInto),
+					NewSymbol("OUTPUT_GRAPHS"),
+					NewTokenType(lexer.ItemConstruct),
+					NewSymbol("CONSTRUCT_FACTS"),
+					NewTokenType(lexer.ItemFrom),
+					NewSymbol("GRAPHS"),
+					NewSymbol("WHERE"),
+					NewSymbol("HAVING"),
+					NewTokenType(lexer.ItemSemicolon),
 				},
 			},
+		},
+		"DELETE_SOURCE": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemBetween),
-					NewTokenType(lexer.ItemPredicate),
-					NewTokenType(lexer.ItemComma),
+					NewTokenType(lexer.ItemData),
+					NewTokenType(lexer.ItemFrom),
+					NewSymbol("GRAPHS"),
+					NewTokenType(lexer.ItemLBracket),
+					NewTokenType(lexer.ItemNode),
 					NewTokenType(lexer.ItemPredicate),
+					NewSymbol("DELETE_OBJECT"),
+					NewSymbol("DELETE_DATA"),
+					NewTokenType(lexer.ItemRBracket),
+					NewTokenType(lexer.ItemSemicolon),
 				},
 			},
-			{},
-		},
-		"LIMIT": []*Clause{
 			{
 				Elements: []Element{
-					NewTokenType(lexer.ItemLimit),
-					NewTokenType(lexer.ItemLiteral),
+					NewTokenType(lexer.ItemWhere),
+					NewTokenType(lexer.ItemLBracket),
+					NewSymbol("CLAUSES"),
+					NewTokenType(lexer.ItemRBracket),
+					NewTokenType(lexer.ItemFrom),
+					NewSymbol("GRAPHS"),
+					NewTokenType(lexer.ItemSemicolon),
 				},
 			},
-			{},
 		},
 		"INSERT_OBJECT": []*Clause{
 			{
@@ -755,6 +1532,33 @@ func BQL() *Grammar {
 				},
 			},
 		},
+		"CONSTRUCT_INTO": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemInto),
+					NewSymbol("OUTPUT_GRAPHS"),
+				},
+			},
+			{},
+		},
+		"OUTPUT_GRAPHS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("MORE_OUTPUT_GRAPHS"),
+				},
+			},
+		},
+		"MORE_OUTPUT_GRAPHS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemComma),
+					NewTokenType(lexer.ItemBinding),
+					NewSymbol("MORE_OUTPUT_GRAPHS"),
+				},
+			},
+			{},
+		},
 		"CONSTRUCT_TRIPLES": []*Clause{
 			{
 				Elements: []Element{
@@ -783,6 +1587,17 @@ func BQL() *Grammar {
 					NewSymbol("MORE_CONSTRUCT_TRIPLES"),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemNewBlank),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemRPar),
+					NewSymbol("CONSTRUCT_PREDICATE"),
+					NewSymbol("CONSTRUCT_OBJECT"),
+					NewSymbol("REIFICATION_CLAUSE"),
+					NewSymbol("MORE_CONSTRUCT_TRIPLES"),
+				},
+			},
 		},
 		"CONSTRUCT_PREDICATE": []*Clause{
 			{
@@ -802,6 +1617,15 @@ func BQL() *Grammar {
 					NewTokenType(lexer.ItemNode),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemConcat),
+					NewTokenType(lexer.ItemLPar),
+					NewSymbol("CONCAT_ARGUMENT"),
+					NewSymbol("MORE_CONCAT_ARGUMENTS"),
+					NewTokenType(lexer.ItemRPar),
+				},
+			},
 			{
 				Elements: []Element{
 					NewTokenType(lexer.ItemBlankNode),
@@ -822,6 +1646,40 @@ func BQL() *Grammar {
 					NewTokenType(lexer.ItemBinding),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemNewBlank),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemRPar),
+				},
+			},
+		},
+		"CONCAT_ARGUMENT": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemBinding),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemString),
+				},
+			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemLiteral),
+				},
+			},
+		},
+		"MORE_CONCAT_ARGUMENTS": []*Clause{
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemComma),
+					NewSymbol("CONCAT_ARGUMENT"),
+					NewSymbol("MORE_CONCAT_ARGUMENTS"),
+				},
+			},
+			{},
 		},
 		"REIFICATION_CLAUSE": []*Clause{
 			{
@@ -872,6 +1730,13 @@ func BQL() *Grammar {
 					NewTokenType(lexer.ItemBinding),
 				},
 			},
+			{
+				Elements: []Element{
+					NewTokenType(lexer.ItemNewBlank),
+					NewTokenType(lexer.ItemLPar),
+					NewTokenType(lexer.ItemRPar),
+				},
+			},
 		},
 		"MORE_CONSTRUCT_TRIPLES": []*Clause{
 			{
@@ -911,13 +1776,51 @@ func SemanticBQL() *Grammar {
 	semanticBQL := BQL()
 	dataAcc := semantic.DataAccumulatorHook()
 
-	// Create and Drop semantic hooks for type.
+	// Create, Drop, and Truncate semantic hooks for type.
 	setClauseHook(semanticBQL, []semantic.Symbol{"CREATE_GRAPHS"}, nil, semantic.TypeBindingClauseHook(semantic.Create))
 	setClauseHook(semanticBQL, []semantic.Symbol{"DROP_GRAPHS"}, nil, semantic.TypeBindingClauseHook(semantic.Drop))
+	setClauseHook(semanticBQL, []semantic.Symbol{"TRUNCATE_GRAPHS"}, nil, semantic.TypeBindingClauseHook(semantic.Truncate))
+
+	setElementHook(semanticBQL, []semantic.Symbol{"CREATE_SOURCE"}, semantic.CloneSourceHook(), nil)
+
+	// Import semantic hooks.
+	setElementHook(semanticBQL, []semantic.Symbol{"IMPORT_SOURCE"}, semantic.ImportDataHook(), nil)
+	setClauseHook(semanticBQL, []semantic.Symbol{"IMPORT_SOURCE"}, nil, semantic.TypeBindingClauseHook(semantic.Import))
+
+	// Show semantic hooks.
+	setClauseHook(semanticBQL, []semantic.Symbol{"SHOW_TARGET"}, nil, semantic.TypeBindingClauseHook(semantic.Show))
+	setClauseHook(semanticBQL, []semantic.Symbol{"DESCRIBE_GRAPHS"}, nil, semantic.TypeBindingClauseHook(semantic.Describe))
 
 	// Add graph binding collection to GRAPHS and MORE_GRAPHS clauses.
 	graphSymbols := []semantic.Symbol{"GRAPHS", "MORE_GRAPHS"}
 	setElementHook(semanticBQL, graphSymbols, semantic.GraphAccumulatorHook(), nil)
+	setElementHook(semanticBQL, []semantic.Symbol{"QUERY_SOURCE"}, semantic.GraphAccumulatorHook(),
+		func(cls *Clause) bool {
+			return cls.Elements[0].Token() == lexer.ItemBinding
+		})
+
+	// Schema semantic hooks.
+	schemaSymbols := []semantic.Symbol{"SCHEMA_SOURCE", "SCHEMA_ARGS", "SCHEMA_ARG"}
+	setElementHook(semanticBQL, schemaSymbols, semantic.SchemaAccumulatorHook(), nil)
+	setClauseHook(semanticBQL, []semantic.Symbol{"SCHEMA_SOURCE"}, semantic.SchemaQueryClauseHook(), semantic.VarBindingsGraphChecker())
+
+	// Frequencies semantic hooks.
+	setElementHook(semanticBQL, []semantic.Symbol{"START"}, semantic.FrequenciesQueryHook(),
+		func(cls *Clause) bool {
+			return cls.Elements[0].Token() == lexer.ItemFrequencies
+		})
+
+	// Latest semantic hooks.
+	setElementHook(semanticBQL, []semantic.Symbol{"START"}, semantic.LatestQueryHook(),
+		func(cls *Clause) bool {
+			return cls.Elements[0].Token() == lexer.ItemLatest
+		})
+
+	// Weighted sample semantic hooks.
+	sampleCond := func(cls *Clause) bool {
+		return len(cls.Elements) > 0 && (cls.Elements[0].Token() == lexer.ItemWeightedSample || cls.Elements[0].Token() == lexer.ItemComma)
+	}
+	setElementHook(semanticBQL, []semantic.Symbol{"START", "SAMPLE_SEED"}, semantic.WeightedSampleQueryHook(), sampleCond)
 
 	// Insert and Delete semantic hooks addition.
 	insertSymbols := []semantic.Symbol{
@@ -925,29 +1828,73 @@ func SemanticBQL() *Grammar {
 	}
 	setElementHook(semanticBQL, insertSymbols, dataAcc, nil)
 	setClauseHook(semanticBQL, []semantic.Symbol{"INSERT_OBJECT"}, nil, semantic.TypeBindingClauseHook(semantic.Insert))
+	setElementHook(semanticBQL, []semantic.Symbol{"INSERT_SOURCE"}, dataAcc,
+		func(cls *Clause) bool {
+			return cls.Elements[0].Token() == lexer.ItemData
+		})
+	// Inserting constructed triples binds the type once the construct clauses
+	// are done.
+	setClauseHook(semanticBQL, []semantic.Symbol{"INSERT_SOURCE"}, nil, semantic.TypeBindingClauseHook(semantic.Insert))
 	setClauseHook(semanticBQL, []semantic.Symbol{"DELETE_OBJECT"}, nil, semantic.TypeBindingClauseHook(semantic.Delete))
+	setElementHook(semanticBQL, []semantic.Symbol{"DELETE_SOURCE"}, dataAcc,
+		func(cls *Clause) bool {
+			return cls.Elements[0].Token() == lexer.ItemData
+		})
+	// Deleting the triples matching a graph pattern initializes the working
+	// graph clause as WHERE does, and binds the type once the pattern is done.
+	setClauseHook(semanticBQL, []semantic.Symbol{"DELETE_SOURCE"}, semantic.WhereInitWorkingClauseHook(), semantic.TypeBindingClauseHook(semantic.Delete))
 
-	// Query semantic hooks.
-	setClauseHook(semanticBQL, []semantic.Symbol{"WHERE"}, semantic.WhereInitWorkingClauseHook(), semantic.VarBindingsGraphChecker())
+	// Query semantic hooks. The graph pattern of frequencies queries is
+	// optional.
+	setClauseHook(semanticBQL, []semantic.Symbol{"WHERE", "FREQUENCIES_SOURCE"}, semantic.WhereInitWorkingClauseHook(), semantic.VarBindingsGraphChecker())
 
 	clauseSymbols := []semantic.Symbol{
-		"CLAUSES", "MORE_CLAUSES",
+		"CLAUSES", "MORE_CLAUSES", "NOT_EXISTS_CLAUSES", "MORE_NOT_EXISTS_CLAUSES",
 	}
 	setClauseHook(semanticBQL, clauseSymbols, semantic.WhereNextWorkingClauseHook(), semantic.WhereNextWorkingClauseHook())
 
 	subSymbols := []semantic.Symbol{
-		"CLAUSES", "SUBJECT_EXTRACT", "SUBJECT_TYPE", "SUBJECT_ID",
+		"CLAUSES", "NEGATED_CLAUSE", "NOT_EXISTS_CLAUSES", "SUBJECT_NODE_TYPE", "SUBJECT_EXTRACT", "SUBJECT_TYPE", "SUBJECT_ID",
 	}
 	setElementHook(semanticBQL, subSymbols, semantic.WhereSubjectClauseHook(), nil)
 
+	// Negated clauses only delimit the clause that gets negated.
+	setElementHook(semanticBQL, []semantic.Symbol{"CLAUSES"}, nil,
+		func(cls *Clause) bool {
+			return cls.Elements[0].Token() == lexer.ItemBang
+		})
+
+	// NOT EXISTS groups track the group each nested graph clause belongs to.
+	setElementHook(semanticBQL, []semantic.Symbol{"CLAUSES"}, semantic.WhereNotExistsClauseHook(),
+		func(cls *Clause) bool {
+			return cls.Elements[0].Token() == lexer.ItemNot
+		})
+
+	// Filter clauses collect the tokens that form the filter expression and
+	// build the function that will evaluate the rows.
+	setElementHook(semanticBQL, []semantic.Symbol{"CLAUSES"}, semantic.WhereFilterClauseHook(),
+		func(cls *Clause) bool {
+			return cls.Elements[0].Token() == lexer.ItemFilter
+		})
+	filterSymbols := []semantic.Symbol{"FILTER_CLAUSE", "FILTER_TEXT_OPERAND", "FILTER_CLAUSE_BINARY_COMPOSITE"}
+	setElementHook(semanticBQL, filterSymbols, semantic.WhereFilterExpressionHook(), nil)
+	setClauseHook(semanticBQL, []semantic.Symbol{"NEGATED_CLAUSE"}, semantic.WhereNegatedWorkingClauseHook(), nil)
+
+	// Union clauses track the branch each nested graph clause belongs to.
+	setElementHook(semanticBQL, []semantic.Symbol{"CLAUSES"}, semantic.WhereUnionClauseHook(),
+		func(cls *Clause) bool {
+			return cls.Elements[0].Token() == lexer.ItemLBracket
+		})
+	setElementHook(semanticBQL, []semantic.Symbol{"UNION_BRANCHES"}, semantic.WhereUnionClauseHook(), nil)
+
 	predSymbols := []semantic.Symbol{
-		"PREDICATE", "PREDICATE_AS", "PREDICATE_ID", "PREDICATE_AT", "PREDICATE_BOUND_AT",
+		"PREDICATE", "PREDICATE_ALTERNATIVES", "PREDICATE_PREFIX", "PREDICATE_PATH", "PREDICATE_AS", "PREDICATE_ID", "PREDICATE_AT", "PREDICATE_BOUND_AT",
 		"PREDICATE_BOUND_AT_BINDINGS", "PREDICATE_BOUND_AT_BINDINGS_END",
 	}
 	setElementHook(semanticBQL, predSymbols, semantic.WherePredicateClauseHook(), nil)
 
 	objSymbols := []semantic.Symbol{
-		"OBJECT", "OBJECT_SUBJECT_EXTRACT", "OBJECT_SUBJECT_TYPE", "OBJECT_SUBJECT_ID",
+		"OBJECT", "OBJECT_NODE_TYPE", "OBJECT_SUBJECT_EXTRACT", "OBJECT_SUBJECT_TYPE", "OBJECT_SUBJECT_ID",
 		"OBJECT_PREDICATE_AS", "OBJECT_PREDICATE_ID", "OBJECT_PREDICATE_AT",
 		"OBJECT_PREDICATE_BOUND_AT", "OBJECT_PREDICATE_BOUND_AT_BINDINGS",
 		"OBJECT_PREDICATE_BOUND_AT_BINDINGS_END", "OBJECT_LITERAL_AS",
@@ -956,12 +1903,22 @@ func SemanticBQL() *Grammar {
 	}
 	setElementHook(semanticBQL, objSymbols, semantic.WhereObjectClauseHook(), nil)
 
+	// Mark queries that only return distinct rows.
+	setElementHook(semanticBQL, []semantic.Symbol{"SELECT_DISTINCT"}, semantic.SelectDistinctHook(), nil)
+
 	// Collect binding variables variables.
 	varSymbols := []semantic.Symbol{
-		"VARS", "VARS_AS", "MORE_VARS", "COUNT_DISTINCT",
+		"VARS", "VARS_AS", "MORE_VARS", "COUNT_DISTINCT", "COALESCE_BINDINGS",
 	}
 	setElementHook(semanticBQL, varSymbols, semantic.VarAccumulatorHook(), nil)
 
+	// Collect the partition and order bindings of window aggregations.
+	windowSymbols := []semantic.Symbol{
+		"WINDOW", "WINDOW_PARTITION", "WINDOW_PARTITION_BINDINGS",
+		"WINDOW_ORDER_DIRECTION", "WINDOW_ORDER_BINDINGS",
+	}
+	setElementHook(semanticBQL, windowSymbols, semantic.WindowAccumulatorHook(), nil)
+
 	// Collect and validate group by bindings.
 	grpSymbols := []semantic.Symbol{"GROUP_BY", "GROUP_BY_BINDINGS"}
 	setElementHook(semanticBQL, grpSymbols, semantic.GroupByBindings(), nil)
@@ -982,10 +1939,20 @@ func SemanticBQL() *Grammar {
 	globalSymbols := []semantic.Symbol{"GLOBAL_TIME_BOUND"}
 	setElementHook(semanticBQL, globalSymbols, semantic.CollectGlobalBounds(), nil)
 
+	// AS OF clause semantic hook addition.
+	setElementHook(semanticBQL, []semantic.Symbol{"AS_OF"}, semantic.AsOfHook(), nil)
+
 	// LIMIT clause semantic hook addition.
-	limitSymbols := []semantic.Symbol{"LIMIT"}
+	limitSymbols := []semantic.Symbol{"LIMIT", "LIMIT_VALUE"}
 	setElementHook(semanticBQL, limitSymbols, semantic.LimitCollection(), nil)
 
+	// OFFSET clause semantic hook addition.
+	offsetSymbols := []semantic.Symbol{"OFFSET", "OFFSET_VALUE"}
+	setElementHook(semanticBQL, offsetSymbols, semantic.OffsetCollection(), nil)
+
+	// Mark queries that match text literals regardless of their case.
+	setElementHook(semanticBQL, []semantic.Symbol{"TEXT_MATCHING"}, semantic.IgnoreCaseHook(), nil)
+
 	// Global data accumulator hook.
 	setElementHook(semanticBQL, []semantic.Symbol{"START"}, dataAcc,
 		func(cls *Clause) bool {
@@ -997,6 +1964,7 @@ func SemanticBQL() *Grammar {
 	setClauseHook(semanticBQL, []semantic.Symbol{"START"}, nil, semantic.GroupByBindingsChecker())
 
 	// CONSTRUCT clause semantic hooks.
+	setElementHook(semanticBQL, []semantic.Symbol{"OUTPUT_GRAPHS", "MORE_OUTPUT_GRAPHS"}, semantic.OutputGraphAccumulatorHook(), nil)
 	setClauseHook(semanticBQL, []semantic.Symbol{"CONSTRUCT_FACTS"}, semantic.InitWorkingConstructClauseHook(), semantic.TypeBindingClauseHook(semantic.Construct))
 
 	constructTriplesSymbols := []semantic.Symbol{"CONSTRUCT_TRIPLES", "MORE_CONSTRUCT_TRIPLES"}
@@ -1004,7 +1972,7 @@ func SemanticBQL() *Grammar {
 
 	setElementHook(semanticBQL, []semantic.Symbol{"CONSTRUCT_TRIPLES"}, semantic.ConstructSubjectClauseHook(), nil)
 	setElementHook(semanticBQL, []semantic.Symbol{"CONSTRUCT_PREDICATE"}, semantic.ConstructPredicateClauseHook(), nil)
-	setElementHook(semanticBQL, []semantic.Symbol{"CONSTRUCT_OBJECT"}, semantic.ConstructObjectClauseHook(), nil)
+	setElementHook(semanticBQL, []semantic.Symbol{"CONSTRUCT_OBJECT", "CONCAT_ARGUMENT", "MORE_CONCAT_ARGUMENTS"}, semantic.ConstructObjectClauseHook(), nil)
 
 	setClauseHook(semanticBQL, []semantic.Symbol{"REIFICATION_CLAUSE"}, semantic.NextWorkingReificationClauseHook(), semantic.NextWorkingReificationClauseHook())
 	setElementHook(semanticBQL, []semantic.Symbol{"REIFICATION_PREDICATE"}, semantic.ReificationPredicateClauseHook(), nil)
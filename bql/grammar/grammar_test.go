@@ -15,6 +15,7 @@
 package grammar
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/google/badwolf/bql/semantic"
@@ -30,7 +31,16 @@ func TestAcceptByParse(t *testing.T) {
 		`select ?a as ?b from ?c where{?s ?p ?o};`,
 		`select ?a as ?b, ?c as ?d from ?e where{?s ?p ?o};`,
 		`select count(?a) as ?b, sum(?c) as ?d, ?e as ?f from ?g where{?s ?p ?o};`,
+		`select min(?a) as ?b, max(?c) as ?d, ?e as ?f from ?g where{?s ?p ?o};`,
 		`select count(distinct ?a) as ?b from ?c where{?s ?p ?o};`,
+		`select ?s, type(?s) as ?t, id(?s) as ?i from ?c where{?s ?p ?o} order by ?t, ?i;`,
+		// Test window aggregations.
+		`select sum(?a) over (order by ?t) as ?b from ?c where{?s ?p ?o};`,
+		`select ?s, sum(?a) over (partition by ?s order by ?t desc) as ?b from ?c where{?s ?p ?o};`,
+		`select count(distinct ?a) over (partition by ?s, ?p order by ?t asc, ?o) as ?b from ?c where{?s ?p ?o};`,
+		// Test union graph patterns.
+		`select ?s from ?b where{ {?s ?p ?o} union {?s ?p ?o . ?o ?q ?r} };`,
+		`select ?s from ?b where{ {?s ?p ?o} union {?s ?p ?o} union {?s ?p ?o . filter(?o = ?s)} };`,
 		// Test multiple graphs are accepted.
 		`select ?a from ?b where{?s ?p ?o};`,
 		`select ?a from ?b, ?c where{?s ?p ?o};`,
@@ -43,6 +53,7 @@ func TestAcceptByParse(t *testing.T) {
 		`select ?a from ?b where{?s ?p as ?x ?o};`,
 		`select ?a from ?b where{?s ?p as ?x id ?y ?o};`,
 		`select ?a from ?b where{?s ?p as ?x id ?y at ?z ?o};`,
+		`select ?a from ?b where{?s ?p ?o at ?t};`,
 		`select ?a from ?b where{?s ?p ?o as ?x};`,
 		`select ?a from ?b where{?s ?p ?o as ?x type ?y};`,
 		`select ?a from ?b where{?s ?p ?o as ?x type ?y id ?z};`,
@@ -52,6 +63,8 @@ func TestAcceptByParse(t *testing.T) {
 		`select ?a from ?b where{?s "foo"@[,] as ?x id ?y at ?z ?o};`,
 		`select ?a from ?b where{?s "foo"@[,] as ?x id ?y at ?z, ?zz ?o};`,
 		`select ?a from ?b where{?s ?p "foo"@[,] as ?x id ?z at ?t, ?tt};`,
+		`select ?a from ?b where{?s "foo"@[=2016-02-01T00:00:00-08:00] ?o};`,
+		`select ?a from ?b where{?s ?p "foo"@[=?t] as ?x};`,
 		// Test multiple clauses.
 		`select ?a from ?b where{?s ?p ?o};`,
 		`select ?a from ?b where{?s ?p ?o . ?s ?p ?o};`,
@@ -76,16 +89,83 @@ func TestAcceptByParse(t *testing.T) {
 		`select ?a from ?b where {?a ?p ?o} having ?b = ?b;`,
 		`select ?a from ?b where {?a ?p ?o} having (?b and ?b) or not (?b = ?b);`,
 		`select ?a from ?b where {?a ?p ?o} having ((?b and ?b) or not (?b = ?b));`,
+		// Test filter clauses.
+		`select ?a from ?b where {?a ?p ?o . filter(?o > "10"^^type:int64)};`,
+		`select ?a from ?b where {?a ?p ?o . filter(?o != ?a) . ?a ?p ?x};`,
+		`select ?a from ?b where {?a ?p ?o . filter(not (?o <= ?a))};`,
+		`select ?a from ?b where {?a ?p ?o . filter((?o >= ?a) and (?o < ""@["123"]))};`,
+		`select ?a from ?b where {?a ?p ?o . filter(?o = ?a) . filter(?o = ?a)};`,
+		// Test distinct projections.
+		`select distinct ?a from ?b where {?a ?p ?o};`,
+		`select distinct ?a, ?o from ?b where {?a ?p ?o} order by ?a limit "10"^^type:int64;`,
+		// Test ignore case acceptance.
+		`select ?a from ?b where {?a "name"@[] "Model S"^^type:text} ignore_case;`,
+		`select ?a from ?b where {?a ?p ?o} limit "10"^^type:int64 offset "2"^^type:int64 ignore_case;`,
+		// Test deleting the triples matching a graph pattern.
+		`delete where {?s "obsolete"@[] ?o} from ?g;`,
+		`delete where {?s "bought"@[,2016-02-15T00:00:00Z] ?o . ?o "is_a"@[] /t<car>} from ?a, ?b;`,
+		// Test as of time-sliced views.
+		`select ?s, ?o from ?g as of ""@[2016-02-15T00:00:00Z] where {?s "bought"@[?t] ?o};`,
+		`select ?s from ?a, ?b as of ""@[2016-02-15T00:00:00Z] where {?s ?p ?o} before ""@[2016-03-01T00:00:00Z];`,
+		// Test frequencies queries.
+		`frequencies(?p) from ?test;`,
+		`frequencies(?o) from ?a, ?b where {?s ?p ?o} limit "10"^^type:int64;`,
+		// Test latest queries.
+		`latest "3"^^type:int64 per ?s of "bought"@[] from ?test;`,
+		`latest "3"^^type:int64 per ?s of "bought"@[] from ?a, ?b before ""@[2016-01-01T00:00:00-08:00];`,
+		// Test weighted sample queries.
+		`weighted_sample(?s, ?w, "10"^^type:int64) from ?test where {?s "score"@[] ?w};`,
+		`weighted_sample(?s, ?w, "10"^^type:int64, "42"^^type:int64) from ?a, ?b where {?s "score"@[] ?w};`,
+		`select ?a from ?b where {?a ?p ?o . filter(fuzzy(?o, "Marry"^^type:text, "1"^^type:int64))};`,
+		`select ?a from ?b where {?a ?p ?o . filter(not (fuzzy(?o, "Marry"^^type:text, "1"^^type:int64)))};`,
+		`select ?a from ?b where {?a ?p ?o . filter(starts_with(id(?p), "bought"^^type:text))};`,
+		`select ?a from ?b where {?a ?p ?o . filter(not (starts_with(?o, "Ma"^^type:text)))};`,
+		`select ?a from ?b where {?a PREFIX("_"@[]) ?o};`,
+		`select ?bn, reify(?bn) as ?t from ?b where {?bn "_subject"@[,] ?s};`,
+		`select ?bn, coalesce(?s, ?bn) as ?v from ?b where {?bn "_subject"@[,] ?s};`,
+		`select reify(?bn) as ?t, coalesce(?t, ?s, ?bn) as ?v from ?b where {?bn "_subject"@[,] ?s};`,
+		`select ?a, ?t from ?b where {?a PREFIX("_"@[?t]) AS ?p ID ?id ?o};`,
+		`select ?a from ?b where {?a PREFIX("_"@[2016-01-01T00:00:00-08:00,]) ?o};`,
+		`select ?a from ?b where {?a ?p ?o . filter(?o =~ "^Model .*"^^type:text)};`,
 		// Test global time bounds.
 		`select ?a from ?b where {?s ?p ?o} before ""@["123"];`,
 		`select ?a from ?b where {?s ?p ?o} after ""@["123"];`,
 		`select ?a from ?b where {?s ?p ?o} between ""@["123"], ""@["123"];`,
 		// Test limit clause.
 		`select ?a from ?b where {?s ?p ?o} limit "10"^^type:int64;`,
+		// Test offset clause.
+		`select ?a from ?b where {?s ?p ?o} order by ?a limit "10"^^type:int64 offset "20"^^type:int64;`,
+		`select ?a from ?b where {?s ?p ?o} order by ?a offset "20"^^type:int64;`,
+		`select ?a from ?b where {?s ?p ?o} limit @n;`,
+		`select ?a from ?b where {?s ?p ?o} order by ?a limit @n offset @o;`,
+		`select ?o from ?b where {/u<joe> "parent_of"@[] ?o . filter(?o = @name)};`,
+		`select ?o from ?b where {?s ?p ?o . filter((@p = ?p) and (?o != @o))};`,
+		// Test plain integer limits and offsets.
+		`select ?a from ?b where {?s ?p ?o} limit 10;`,
+		`select ?a from ?b where {?s ?p ?o} order by ?a limit 10 offset 20;`,
+		`select ?a from ?b where {?s ?p ?o} order by ?a limit "10"^^type:int64 offset 20;`,
+		// Test schema queries.
+		`select ?p, ?domain, ?range from schema(?a);`,
+		`select ?p, ?domain, ?range from schema(?a, ?b, "is_a"@[], "bought"@[]) order by ?p;`,
+		// Test inline negated clauses.
+		`select ?a from ?b where {?s ?p ?o . !{?s ?p ?x}};`,
+		`select ?a from ?b where {!{?s ?p ?o} . ?s ?p ?o};`,
+		`select ?a from ?b where {?s ?p ?o . !{/_<foo> "bar"@[] ?o}};`,
+		// Test NOT EXISTS graph patterns.
+		`select ?a from ?b where {?s ?p ?o . not exists {?s ?p ?x}};`,
+		`select ?a from ?b where {?s ?p ?o . not exists {?s ?p ?x . ?x ?p ?o} . ?o ?p ?s};`,
+		`select ?a from ?b where {NOT EXISTS {/_<foo> "bar"@[] ?o} . ?s ?p ?o};`,
+		// Test path predicates.
+		`select ?a from ?b where {?a "parent_of"@[]+ /person<Amy Schumer>};`,
+		`select ?a from ?b where {/person<Amy Schumer> "parent_of"@[]* ?a . ?a ?p ?o};`,
+		// Test predicate alternatives.
+		`select ?s, ?o from ?g where {?s ("parent_of"@[] | "guardian_of"@[]) ?o};`,
+		`select ?p from ?g where {/u<joe> ("parent_of"@[] | "guardian_of"@[] | "tutor_of"@[2016-02-15T00:00:00Z]) as ?p ?o . !{?o ("a"@[] | "b"@[]) ?s}};`,
 		// Insert data.
 		`insert data into ?a {/_<foo> "bar"@["1234"] /_<foo>};`,
 		`insert data into ?a {/_<foo> "bar"@["1234"] "bar"@["1234"]};`,
 		`insert data into ?a {/_<foo> "bar"@["1234"] "yeah"^^type:text};`,
+		`insert data into ?a {/_<foo> "bar"@[NOW()] "bar"@[NOW()]};`,
 		// Insert into multiple graphs.
 		`insert data into ?a,?b,?c {/_<foo> "bar"@["1234"] /_<foo>};`,
 		// Insert multiple data.
@@ -105,9 +185,24 @@ func TestAcceptByParse(t *testing.T) {
 		// Create graphs.
 		`create graph ?a;`,
 		`create graph ?a, ?b, ?c;`,
+		`create graph ?a as ?b;`,
+		`create graph ?a, ?b as ?c;`,
 		// Drop graphs.
 		`drop graph ?a;`,
 		`drop graph ?a, ?b, ?c;`,
+		// Truncate graphs.
+		`truncate graph ?a;`,
+		`truncate graph ?a, ?b, ?c;`,
+		// Import serialized triples.
+		`import "/_<foo> \"bar\"@[] /_<baz>" into ?a;`,
+		`import "/_<foo> \"bar\"@[] /_<baz>\n/_<foo> \"bar\"@[] \"yeah\"^^type:text" into ?a, ?b;`,
+		`import "/_<foo> \"bar\"@[] /_<baz>
+		        /_<foo> \"bar\"@[] /_<qux>" into ?a;`,
+		// Show graphs.
+		`show graphs;`,
+		// Describe graphs.
+		`describe graph ?a;`,
+		`describe graph ?a, ?b;`,
 		// Issue 39 (https://github.com/google/badwolf/issues/39)
 		`insert data into ?world {/room<000> "named"@[] "Hallway"^^type:text.
 		                          /room<000> "connects_to"@[] /room<001>};`,
@@ -134,6 +229,21 @@ func TestAcceptByParse(t *testing.T) {
 		            ?s "predicate_3"@[] ?o3} into ?a from ?b where {?s "old_predicate_1"@[,] ?o1.
 									    ?s "old_predicate_2"@[,] ?o2.
 									    ?s "old_predicate_3"@[,] ?o3};`,
+		// Construct clauses minting fresh blank nodes.
+		`construct {new_blank() "parent"@[] ?s; "generated_by"@[] NEW_BLANK() . ?s "related_to"@[] new_blank()} from ?b where {?s "parent_of"@[] ?o};`,
+		// Construct text objects by concatenation.
+		`construct {?s "full_name"@[] concat(?a, " ", ?b)} into ?a from ?b where {?s "first"@[] ?a . ?s "last"@[] ?b};`,
+		`construct {?s "label"@[] CONCAT("name: "^^type:text, ?n)} from ?b where {?s "name"@[] ?n};`,
+		// Construct clauses without destination return the constructed triples.
+		`construct {?s "new_predicate"@[] ?o} from ?b where {?s "old_predicate"@[,] ?o};`,
+		// Construct clauses may store the constructed triples in several graphs.
+		`construct {?s "new_predicate"@[] ?o} into ?a, ?c from ?b where {?s "old_predicate"@[,] ?o};`,
+		// Insert constructed triples.
+		`insert into ?a construct {?s "new_predicate"@[] ?o} from ?b where {?s "old_predicate"@[,] ?o};`,
+		`insert into ?a, ?c construct {?s "new_predicate"@[] ?o} from ?b where {?s "old_predicate"@[,] ?o} having ?s = ?o;`,
+		// Wildcard projections.
+		`select * from ?a where {?s ?p ?o};`,
+		`select distinct * from ?a where {?s ?p ?o} order by ?s;`,
 	}
 	p, err := NewParser(BQL())
 	if err != nil {
@@ -172,6 +282,18 @@ func TestRejectByParse(t *testing.T) {
 		`select ?a from ?b where {?s ?p ?o at ?t id ?i};`,
 		// Reject incomplete group by.
 		`select ?a from ?b where{?s ?p ?o} group by;`,
+		// Reject node type and id extractions without alias.
+		`select type(?s) from ?b where{?s ?p ?o};`,
+		`select id(?s), ?p from ?b where{?s ?p ?o};`,
+		// Reject incomplete window aggregations.
+		`select sum(?a) over () as ?b from ?c where{?s ?p ?o};`,
+		`select sum(?a) over (partition by ?s) as ?b from ?c where{?s ?p ?o};`,
+		`select sum(?a) over (order by ?t) from ?c where{?s ?p ?o};`,
+		`select sum(?a) over order by ?t as ?b from ?c where{?s ?p ?o};`,
+		// Reject incomplete union graph patterns.
+		`select ?s from ?b where{ {?s ?p ?o} };`,
+		`select ?s from ?b where{ {?s ?p ?o} union };`,
+		`select ?s from ?b where{ {?s ?p ?o} union {?s ?p ?o} . ?s ?p ?o };`,
 		`select ?a from ?b where{?s ?p ?o} group ?a;`,
 		`select ?a from ?b where{?s ?p ?o} by ?a;`,
 		// Reject incomplete order by.
@@ -191,6 +313,47 @@ func TestRejectByParse(t *testing.T) {
 		`select ?a from ?b where {?a ?p ?o} having ?b = ;`,
 		`select ?a from ?b where {?a ?p ?o} having () or not (?b = ?b);`,
 		`select ?a from ?b where {?a ?p ?o} having ((?b and ?b) (?b = ?b));`,
+		// Reject invalid filter clauses.
+		`select ?a from ?b where {?a ?p ?o . filter()};`,
+		`select ?a from ?b where {?a ?p ?o . filter ?o = ?a};`,
+		`select ?a from ?b where {?a ?p ?o . filter(?o = )};`,
+		`select ?a from ?b where {?a ?p ?o . filter(?o =< ?a)};`,
+		`select ?a from ?b where {?a ?p ?o filter(?o = ?a)};`,
+		`select ?a distinct from ?b where {?a ?p ?o};`,
+		`select distinct distinct ?a from ?b where {?a ?p ?o};`,
+		`select ?a from ?b where {?a ?p ?o} ignore_case ignore_case;`,
+		`select ?a from ?b where {?a ?p ?o} ignore_case limit "10"^^type:int64;`,
+		`select ?a from ?b as of where {?a ?p ?o};`,
+		`select ?a from ?b where {?s ("parent_of"@[]) ?o};`,
+		`select ?a from ?b where {?s ("parent_of"@[] | ) ?o};`,
+		`select ?a from ?b where {?s ("parent_of"@[] | ?p) ?o};`,
+		`select ?a from ?b where {?s ("parent_of"@[] | "guardian_of"@[])+ ?o};`,
+		`delete where {?s "obsolete"@[] ?o};`,
+		`delete where {?s "obsolete"@[] ?o} from ?g`,
+		`delete from ?g where {?s "obsolete"@[] ?o};`,
+		`select ?a from ?b as ""@[2016-02-15T00:00:00Z] where {?a ?p ?o};`,
+		`select ?a from as of ""@[2016-02-15T00:00:00Z] ?b where {?a ?p ?o};`,
+		`select ?a from ?b where {/u<a>[/room] ?p ?o};`,
+		`select ?a from ?b where {?a ?p "1"^^type:int64[/room]};`,
+		`select ?a from ?b where {?a[/room][/u] ?p ?o};`,
+		`frequencies() from ?test;`,
+		`frequencies(?p, ?o) from ?test;`,
+		`latest per ?s of "bought"@[] from ?test;`,
+		`latest "3"^^type:int64 per "bought"@[] from ?test;`,
+		`latest "3"^^type:int64 per ?s of "bought"@[];`,
+		`weighted_sample(?s, "10"^^type:int64) from ?test where {?s "score"@[] ?w};`,
+		`weighted_sample(?s, ?w, "10"^^type:int64,) from ?test where {?s "score"@[] ?w};`,
+		`weighted_sample(?s, ?w, "10"^^type:int64) from ?test;`,
+		`select ?a from ?b where {?a ?p ?o . filter(fuzzy(?o, "Marry"^^type:text))};`,
+		`select ?a from ?b where {?a ?p ?o . filter(fuzzy(/u<joe>, "Marry"^^type:text, "1"^^type:int64))};`,
+		`select ?a from ?b where {?a ?p ?o . filter(starts_with(id(/u<joe>), "j"^^type:text))};`,
+		`select ?a from ?b where {?a ?p ?o . filter(starts_with(?o))};`,
+		`select ?a from ?b where {?a PREFIX("_")@[] ?o};`,
+		`select ?a from ?b where {?a PREFIX(?p) ?o};`,
+		`select reify(?bn) from ?b where {?bn "_subject"@[,] ?s};`,
+		`select coalesce(?bn) as ?v from ?b where {?bn "_subject"@[,] ?s};`,
+		`select coalesce(?bn, ?s) from ?b where {?bn "_subject"@[,] ?s};`,
+		`select ?a from ?b where {?a PREFIX("_"@[])+ ?o};`,
 		// Reject invalid global time bounds.
 		`select ?a from ?b where {?s ?p ?o} before ;`,
 		`select ?a from ?b where {?s ?p ?o} after ;`,
@@ -202,6 +365,31 @@ func TestRejectByParse(t *testing.T) {
 		// Test limit clause.
 		`select ?a from ?b where {?s ?p ?o} limit ?b;`,
 		`select ?a from ?b where {?s ?p ?o} limit ;`,
+		// Test offset clause.
+		`select ?a from ?b where {?s ?p ?o} offset ;`,
+		`select ?a from ?b where {?s ?p ?o} offset "20"^^type:int64 limit "10"^^type:int64;`,
+		`select ?a from ?b where {?s ?p ?o} limit @;`,
+		`select ?a from ?b where {?s ?p ?o} limit @n @o;`,
+		`select ?a from ?b where {?s ?p ?o} limit 10abc;`,
+		`select ?a from ?b where {?s ?p ?o} limit 10 20;`,
+		// Test malformed schema queries.
+		`select ?p from schema();`,
+		`select ?p from schema(?a, );`,
+		`select ?p from schema("is_a"@[]);`,
+		`select ?p from schema(?a) where {?s ?p ?o};`,
+		// Test malformed negated clauses.
+		`select ?a from ?b where {?s ?p ?o . !?s ?p ?x};`,
+		`select ?a from ?b where {?s ?p ?o . !{?s ?p ?x . ?x ?p ?o}};`,
+		// Test malformed NOT EXISTS graph patterns.
+		`select ?a from ?b where {?s ?p ?o . not {?s ?p ?x}};`,
+		`select ?a from ?b where {?s ?p ?o . exists {?s ?p ?x}};`,
+		`select ?a from ?b where {?s ?p ?o . not exists {}};`,
+		`select ?a from ?b where {?s ?p ?o . not exists {!{?s ?p ?x}}};`,
+		`select ?a from ?b where {?s ?p ?o . not exists {?s ?p ?x . filter(?x = ?o)}};`,
+		// Test malformed path predicates.
+		`select ?a from ?b where {?s ?p+ ?o};`,
+		`select ?a from ?b where {?s "p"@[,]+ ?o};`,
+		`select ?a from ?b where {?s "p"@[]+* ?o};`,
 		// Insert incomplete data.
 		`insert data into ?a {"bar"@["1234"] /_<foo>};`,
 		`insert data into ?a {/_<foo> "bar"@["1234"]};`,
@@ -225,24 +413,50 @@ func TestRejectByParse(t *testing.T) {
 		// Create graphs.
 		`create graph ;`,
 		`create graph ?a, ?b ?c;`,
+		`create graph ?a as ;`,
+		`create graph ?a as ?b, ?c;`,
 		// Drop graphs.
 		`drop graph ;`,
 		`drop graph ?a ?b, ?c;`,
+		// Truncate graphs.
+		`truncate ?a;`,
+		`truncate graph ;`,
+		// Import without payload or destination graphs.
+		`import into ?a;`,
+		`import "/_<foo> \"bar\"@[] /_<baz>";`,
+		`import "/_<foo> \"bar\"@[] /_<baz>" into ;`,
+		// Show without target.
+		`show;`,
+		`show graph;`,
+		// Describe without graphs.
+		`describe graph;`,
+		`describe ?a;`,
+		// Insert constructed triples without destination or source.
+		`insert construct {?s "new_predicate"@[] ?o} from ?b where {?s "old_predicate"@[,] ?o};`,
+		`insert into ?a construct {?s "new_predicate"@[] ?o} where {?s "old_predicate"@[,] ?o};`,
 		// Construct clause without source.
 		`construct {?s "foo"@[,] ?o} into ?a where{?s "foo"@[,] ?o} having ?s = ?o;`,
-		// Construct clause without destination.
-		`construct {?s "foo"@[,] ?o} from ?b where{?s "foo"@[,] ?o} having ?s = ?o;`,
 		// Construct clause with badly formed blank node.
 		`construct {?s ?p ?o.
 			    _v "some_pred"@[] ?k } into ?a from ?b where {?s "foo"@[,] ?o};`,
 		// Construct clause with badly formed triple.
 		`construct {?s ?p ?o.
 		            _:v "some_pred"@[]} into ?a from ?b where {?s "foo"@[,] ?o};`,
+		// Construct clause with badly formed new blank nodes.
+		`construct {new_blank "parent"@[] ?s} into ?a from ?b where {?s "foo"@[,] ?o};`,
+		`construct {?s new_blank() ?o} into ?a from ?b where {?s "foo"@[,] ?o};`,
+		`construct {?s "parent"@[] new_blank(?o)} into ?a from ?b where {?s "foo"@[,] ?o};`,
+		`construct {?s "full_name"@[] concat()} into ?a from ?b where {?s "foo"@[,] ?o};`,
+		`construct {?s "full_name"@[] concat(?a,)} into ?a from ?b where {?s "foo"@[,] ?o};`,
+		`construct {?s "full_name"@[] concat(?a ?b)} into ?a from ?b where {?s "foo"@[,] ?o};`,
+		`construct {concat(?a) "full_name"@[] ?o} into ?a from ?b where {?s "foo"@[,] ?o};`,
 		// Construct clause with badly formed reification clause.
 		`construct {?s "predicate_1"@[] ?o1;
 		            ?s "predicate_2"@[] ?o2} into ?a from ?b where {?s "old_predicate_1"@[,] ?o1.
 									    ?s "old_predicate_2"@[,] ?o2};`,
-
+		// Wildcard projections cannot be followed by other projections.
+		`select *, ?s from ?a where {?s ?p ?o};`,
+		`select * as ?x from ?a where {?s ?p ?o};`,
 	}
 	p, err := NewParser(BQL())
 	if err != nil {
@@ -285,6 +499,10 @@ func TestAcceptOpsByParseAndSemantic(t *testing.T) {
 		{`create graph ?foo;`, 1, 0},
 		// Drop graphs.
 		{`drop graph ?foo, ?bar;`, 2, 0},
+		// Truncate graphs.
+		{`truncate graph ?foo, ?bar;`, 2, 0},
+		// Import serialized triples.
+		{`import "/_<foo> \"bar\"@[] /_<baz>" into ?foo, ?bar;`, 2, 0},
 	}
 	p, err := NewParser(SemanticBQL())
 	if err != nil {
@@ -332,10 +550,54 @@ func TestAcceptQueryBySemanticParse(t *testing.T) {
 		// Test group by acceptance.
 		`select ?s from ?g where{/_<foo> as ?s  ?p "id"@[?foo, ?bar] as ?o} group by ?s;`,
 		`select count(?s) as ?a, sum(?o) as ?b, ?o as ?c from ?g where{?s ?p ?o} group by ?c;`,
+		`select min(?s) as ?a, max(?o) as ?b, ?o as ?c from ?g where{?s ?p ?o} group by ?c;`,
+		`select avg(?s) as ?a, sum(?o) as ?b, ?o as ?c from ?g where{?s ?p ?o} group by ?c;`,
+		// Test window aggregation acceptance.
+		`select ?s, sum(?o) over (partition by ?s order by ?p) as ?r from ?g where{?s ?p ?o};`,
+		`select ?s, avg(?o) over (order by ?p desc) as ?r from ?g where{?s ?p ?o};`,
+		// Test union graph pattern acceptance.
+		`select ?s, ?o from ?g where{ {?s "parent_of"@[] ?o} union {?s "bought"@[,] ?o} };`,
+		`select ?s from ?g where{ {?s "parent_of"@[] ?o} union {?s "bought"@[,] ?c . !{?c "is_a"@[] /t<car>}} };`,
 		// Test order by acceptance.
 		`select ?s from ?g where{/_<foo> as ?s  ?p "id"@[?foo, ?bar] as ?o} order by ?s;`,
 		`select ?s as ?a, ?o as ?b, ?o as ?c from ?g where{?s ?p ?o} order by ?a ASC, ?b DESC;`,
 		`select ?s as ?a, ?o as ?b, ?o as ?c from ?g where{?s ?p ?o} order by ?a ASC, ?b DESC, ?a ASC, ?b DESC, ?c;`,
+		// Test schema queries acceptance.
+		`select ?p, ?domain as ?d, ?range from schema(?g, "is_a"@[]) group by ?p, ?d, ?range;`,
+		// Test multiple group by bindings acceptance.
+		`select ?s, ?p, count(?o) as ?n from ?g where{?s ?p ?o} group by ?s, ?p;`,
+		// Test inline negated clauses acceptance.
+		`select ?s from ?g where{?s ?p ?o . !{?o ?p ?x}};`,
+		// Test NOT EXISTS graph patterns acceptance.
+		`select ?s from ?g where{?s ?p ?o . not exists {?o ?p ?x . ?x ?p ?y}};`,
+		`select ?s from ?g where{?s ?p ?o . not exists {?x ?p ?y}};`,
+		// Test path predicates acceptance.
+		`select ?s from ?g where{?s "parent_of"@[]+ ?o};`,
+		`select ?s from ?g where{?s "parent_of"@[]* ?s};`,
+		// Test predicate alternatives acceptance.
+		`select ?s, ?p from ?g where{?s ("parent_of"@[] | "guardian_of"@[]) as ?p ?o};`,
+		// Test binding node type constraints acceptance.
+		`select ?s from ?g where{?s[/room] ?p ?o[/room]};`,
+		`select ?s from ?g where{?s[/item/book] as ?b ?p ?o . !{?o[/u] ?p ?x}};`,
+		// Test graph binding acceptance.
+		`select ?s, ?_graph from ?g where{?s ?p ?o};`,
+		`select ?s from ?g where{?s ?p ?o . filter(?_graph = "?g"^^type:text)};`,
+		// Test filter clauses acceptance.
+		`select ?s from ?g where{?s ?p ?o . filter(?o > "10"^^type:int64)};`,
+		`select ?s from ?g where{filter(?o != ?s) . ?s ?p ?o};`,
+		// Test distinct projections acceptance.
+		`select distinct ?s, count(distinct ?o) as ?n from ?g where{?s ?p ?o} group by ?s;`,
+		// Test parameterized limit and offset acceptance.
+		`select ?s from ?g where{?s ?p ?o} order by ?s limit @n offset @o;`,
+		`select ?s from ?g where{?s ?p ?o . filter(?o = @o)} limit @n;`,
+		// Test frequencies queries acceptance.
+		`frequencies(?p) from ?g;`,
+		`frequencies(?o) from ?g where{?s ?p ?o};`,
+		// Test latest queries acceptance.
+		`latest "3"^^type:int64 per ?s of "bought"@[] from ?g;`,
+		// Test weighted sample queries acceptance.
+		`weighted_sample(?s, ?w, "10"^^type:int64) from ?g where {?s "score"@[] ?w};`,
+		`weighted_sample(?s, ?w, "10"^^type:int64, "-1"^^type:int64) from ?g where {?s "score"@[] ?w};`,
 	}
 	p, err := NewParser(SemanticBQL())
 	if err != nil {
@@ -357,16 +619,82 @@ func TestRejectByParseAndSemantic(t *testing.T) {
 		`select ?s from ?b where{/_<foo> as ?s  ?p "id"@[2019-07-19T13:12:04.669618843-07:00, 2015-07-19T13:12:04.669618843-07:00] as ?o};`,
 		// Check the bindings on the projection exist on the graph clauses.
 		`select ?foo from ?g where {?s ?p ?o};`,
+		`select coalesce(?s, ?foo) as ?v from ?g where {?s ?p ?o};`,
 		// Reject invalid group by.
 		`select ?s from ?g where{/_<foo> as ?s  ?p "id"@[?foo, ?bar] as ?o} group by ?unknown;`,
 		`select count(?s) as ?a, sum(?o) as ?b, ?o as ?c from ?g where{?s ?p ?o};`,
 		`select count(?s) as ?a, sum(?o) as ?b, ?o as ?c from ?g where{?s ?p ?o} group by ?b;`,
 		`select count(?s) as ?a, sum(?o) as ?b, ?o as ?c from ?g where{?s ?p ?o} group by ?a;`,
+		`select ?s, ?p, count(?o) as ?n from ?g where{?s ?p ?o} group by ?s;`,
+		`select ?s, type(?o) as ?t from ?g where{?s ?p ?o} group by ?s;`,
+		`select type(?unknown) as ?t from ?g where{?s ?p ?o};`,
+		// Reject invalid window aggregations.
+		`select ?s, sum(?o) over (order by ?unknown) as ?r from ?g where{?s ?p ?o};`,
+		`select ?s, sum(?o) over (partition by ?unknown order by ?p) as ?r from ?g where{?s ?p ?o};`,
+		`select ?s, sum(?o) over (order by ?p) as ?r from ?g where{?s ?p ?o} group by ?s;`,
+		// Reject invalid union graph patterns.
+		`select ?s from ?g where{?s ?p ?o . {?s ?p ?o} union {?s ?p ?o} };`,
+		`select ?s from ?g where{ {{?s ?p ?o} union {?s ?p ?o}} union {?s ?p ?o} };`,
+		`select ?s, ?x from ?g where{ {?s ?p ?o} union {?s ?p ?o} };`,
 		// Reject order by acceptance.
 		`select ?s from ?g where{/_<foo> as ?s  ?p "id"@[?foo, ?bar] as ?o} order by ?unknown_s;`,
 		`select ?s as ?a, ?o as ?b, ?o as ?c from ?g where{?s ?p ?o} order by ?a ASC, ?a DESC;`,
 		// Wrong limit literal.
 		`select ?s as ?a, ?o as ?b, ?o as ?c from ?g where{?s ?p ?o} LIMIT "true"^^type:bool;`,
+		// Wrong or nondeterministic offsets.
+		`select ?s from ?g where{?s ?p ?o} ORDER BY ?s OFFSET "true"^^type:bool;`,
+		`select ?s from ?g where{?s ?p ?o} ORDER BY ?s OFFSET "-1"^^type:int64;`,
+		`select ?s from ?g where{?s ?p ?o} LIMIT "10"^^type:int64 OFFSET "20"^^type:int64;`,
+		`select ?s from ?g where{?s ?p ?o} LIMIT @n OFFSET @o;`,
+		`select ?s from ?g where{?s ?p ?o} LIMIT 10 OFFSET 20;`,
+		`select ?s from ?g where{?s ?p ?o} LIMIT 99999999999999999999;`,
+		// Schema queries only provide the schema bindings.
+		`select ?s from schema(?g);`,
+		// Bindings in negated clauses do not escape them.
+		`select ?x from ?g where{?s ?p ?o . !{?o ?p ?x}};`,
+		`select ?x from ?g where{?s ?p ?o . not exists {?o ?p ?x}};`,
+		`select ?s from ?g where{?s ?p ?o . not exists {?o ?p ?x} . filter(?x = ?o)};`,
+		// Path predicates match several triples.
+		`select ?s from ?g where{?s "parent_of"@[?t]+ ?o};`,
+		`select ?s from ?g where{?s "parent_of"@[]+ as ?x ?o};`,
+		// Predicate alternatives must be fully specified and different.
+		`select ?s from ?g where{?s ("parent_of"@[?t] | "guardian_of"@[]) ?o};`,
+		`select ?s from ?g where{?s ("parent_of"@[] | "parent_of"@[]) ?o};`,
+		// The graph binding is reserved for the name of the graphs.
+		`select ?_graph from ?g where{?s ?p ?_graph};`,
+		`select ?s from ?g where{?_graph ?p ?o . filter(?_graph = ?s)};`,
+		// Filters can only use bindings available in the graph pattern.
+		`select ?s from ?g where{?s ?p ?o . filter(?x > "10"^^type:int64)};`,
+		`select ?s from ?g where{?s ?p ?o . !{?o ?p ?x} . filter(?x = ?o)};`,
+		// Filters require well formed constants.
+		`select ?s from ?g where{?s ?p ?o . filter(?o > "true"^^type:int64)};`,
+		`select ?s from ?g where{?s ?p ?o . filter(?o =~ "(("^^type:text)};`,
+		// Filters expressions must be fully consumed.
+		`select ?s from ?g where{?s ?p ?o . filter(?o = ?s and ?o = ?s)};`,
+		// Frequencies queries can only count bindings of the graph pattern.
+		`frequencies(?x) from ?g where{?s ?p ?o};`,
+		`frequencies(?count) from ?g;`,
+		// Latest queries require a positive int64, a free binding, and a
+		// predicate without time anchor.
+		`latest "0"^^type:int64 per ?s of "bought"@[] from ?g;`,
+		`latest "3"^^type:float64 per ?s of "bought"@[] from ?g;`,
+		`latest "3"^^type:int64 per ?p of "bought"@[] from ?g;`,
+		`latest "3"^^type:int64 per ?s of "bought"@[2016-01-01T00:00:00-08:00] from ?g;`,
+		// Weighted sample queries require two different bindings of the graph
+		// pattern, a positive int64 size, and an int64 seed.
+		`weighted_sample(?s, ?w, "0"^^type:int64) from ?g where {?s "score"@[] ?w};`,
+		`weighted_sample(?s, ?w, "1.5"^^type:float64) from ?g where {?s "score"@[] ?w};`,
+		`weighted_sample(?s, ?w, "10"^^type:int64, "x"^^type:text) from ?g where {?s "score"@[] ?w};`,
+		`weighted_sample(?s, ?s, "10"^^type:int64) from ?g where {?s "score"@[] ?w};`,
+		`weighted_sample(?s, ?x, "10"^^type:int64) from ?g where {?s "score"@[] ?w};`,
+		// Wildcard projections cannot be combined with other projections and
+		// require bindings in the graph pattern.
+		`select ?s, * from ?g where{?s ?p ?o};`,
+		`select * from ?g where{/_<foo> "bar"@[] /_<baz>};`,
+		`select * from ?g where{/_<foo> "bar"@[] /_<baz> . !{/_<foo> "bar"@[] ?o}};`,
+		// Reject as of times that are not plain time anchors.
+		`select ?s from ?g as of "bought"@[2016-02-15T00:00:00Z] where {?s ?p ?o};`,
+		`select ?s from ?g as of ""@[] where {?s ?p ?o};`,
 	}
 	p, err := NewParser(SemanticBQL())
 	if err != nil {
@@ -397,6 +725,10 @@ func TestSemanticStatementGraphClausesLengthCorrectness(t *testing.T) {
 			query: `SELECT ?o,?l FROM ?bbacl WHERE { ?o "some_id"@[,] ?x . ?x "some_id"@[,] ?y . ?y "some_id"@[,] ?l } LIMIT "20"^^type:int64;`,
 			want:  3,
 		},
+		{
+			query: `SELECT ?o FROM ?bbacl WHERE { ?o "some_id"@[,] ?x . !{?x "some_id"@[,] ?l} };`,
+			want:  2,
+		},
 	}
 	p, err := NewParser(SemanticBQL())
 	if err != nil {
@@ -449,6 +781,45 @@ func TestSemanticStatementConstructClausesLengthCorrectness(t *testing.T) {
 	}
 }
 
+func TestSemanticStatementConstructGraphs(t *testing.T) {
+	table := []struct {
+		query string
+		in    []string
+		out   []string
+	}{
+		{
+			query: `construct {?s "new_predicate"@[] ?o} from ?b where {?s "old_predicate"@[,] ?o};`,
+			in:    []string{"?b"},
+		},
+		{
+			query: `construct {?s "new_predicate"@[] ?o} into ?a, ?c from ?b, ?d where {?s "old_predicate"@[,] ?o};`,
+			in:    []string{"?b", "?d"},
+			out:   []string{"?a", "?c"},
+		},
+		{
+			query: `insert into ?a construct {?s "new_predicate"@[] ?o} from ?b where {?s "old_predicate"@[,] ?o};`,
+			in:    []string{"?b"},
+			out:   []string{"?a"},
+		},
+	}
+	p, err := NewParser(SemanticBQL())
+	if err != nil {
+		t.Errorf("grammar.NewParser: Should have produced a valid BQL parser, %v", err)
+	}
+	for _, entry := range table {
+		st := &semantic.Statement{}
+		if err := p.Parse(NewLLk(entry.query, 1), st); err != nil {
+			t.Errorf("Parser.consume: Failed to accept valid semantic entry %q", entry.query)
+		}
+		if got, want := st.GraphNames(), entry.in; !reflect.DeepEqual(got, want) {
+			t.Errorf("Invalid source graphs for query %q; got %v, want %v", entry.query, got, want)
+		}
+		if got, want := st.OutputGraphNames(), entry.out; !reflect.DeepEqual(got, want) {
+			t.Errorf("Invalid output graphs for query %q; got %v, want %v", entry.query, got, want)
+		}
+	}
+}
+
 func TestSemanticStatementReificationClausesLengthCorrectness(t *testing.T) {
 	table := []struct {
 		query     string
@@ -528,3 +899,200 @@ func TestSemanticStatementReificationClausesLengthCorrectness(t *testing.T) {
 		}
 	}
 }
+
+func TestSemanticStatementDescribe(t *testing.T) {
+	table := []struct {
+		query string
+		want  semantic.StatementInfo
+	}{
+		{
+			query: `select ?s as ?x, count(?o) as ?n from ?a, ?b where {?s "knows"@[] ?o} group by ?x order by ?n desc;`,
+			want: semantic.StatementInfo{
+				Type:            semantic.Query,
+				GraphNames:      []string{"?a", "?b"},
+				InputBindings:   []string{"?s", "?o"},
+				OutputBindings:  []string{"?x", "?n"},
+				HasAggregations: true,
+				HasOrderBy:      true,
+			},
+		},
+		{
+			query: `select * from ?a where {?s "knows"@[] ?o . ?o ?p ?s AT ?t . ?o "name"@[] ?n . !{?o "hates"@[] ?x}};`,
+			want: semantic.StatementInfo{
+				Type:           semantic.Query,
+				GraphNames:     []string{"?a"},
+				InputBindings:  []string{"?s", "?o", "?p", "?t", "?n"},
+				OutputBindings: []string{"?s", "?o", "?p", "?t", "?n"},
+			},
+		},
+		{
+			query: `construct {?s "related_to"@[] ?o} from ?b where {?s "knows"@[] ?o};`,
+			want: semantic.StatementInfo{
+				Type:          semantic.Construct,
+				GraphNames:    []string{"?b"},
+				InputBindings: []string{"?s", "?o"},
+			},
+		},
+		{
+			query: `construct {?s "related_to"@[] ?o} into ?a from ?b where {?s "knows"@[] ?o};`,
+			want: semantic.StatementInfo{
+				Type:             semantic.Construct,
+				Mutation:         true,
+				GraphNames:       []string{"?b"},
+				OutputGraphNames: []string{"?a"},
+				InputBindings:    []string{"?s", "?o"},
+			},
+		},
+		{
+			query: `insert data into ?a {/_<foo> "bar"@[] /_<foo>};`,
+			want: semantic.StatementInfo{
+				Type:       semantic.Insert,
+				Mutation:   true,
+				GraphNames: []string{"?a"},
+			},
+		},
+		{
+			query: `drop graph ?a, ?b;`,
+			want: semantic.StatementInfo{
+				Type:       semantic.Drop,
+				Mutation:   true,
+				GraphNames: []string{"?a", "?b"},
+			},
+		},
+		{
+			query: `truncate graph ?a, ?b;`,
+			want: semantic.StatementInfo{
+				Type:       semantic.Truncate,
+				Mutation:   true,
+				GraphNames: []string{"?a", "?b"},
+			},
+		},
+		{
+			query: `import "/_<foo> \"bar\"@[] /_<baz>" into ?a;`,
+			want: semantic.StatementInfo{
+				Type:       semantic.Import,
+				Mutation:   true,
+				GraphNames: []string{"?a"},
+			},
+		},
+		{
+			query: `show graphs;`,
+			want: semantic.StatementInfo{
+				Type: semantic.Show,
+			},
+		},
+		{
+			query: `describe graph ?a, ?b;`,
+			want: semantic.StatementInfo{
+				Type:       semantic.Describe,
+				GraphNames: []string{"?a", "?b"},
+			},
+		},
+	}
+	p, err := NewParser(SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: Should have produced a valid BQL parser, %v", err)
+	}
+	for _, entry := range table {
+		st := &semantic.Statement{}
+		if err := p.Parse(NewLLk(entry.query, 1), st); err != nil {
+			t.Errorf("Parser.consume: Failed to accept valid semantic entry %q", entry.query)
+			continue
+		}
+		got := st.Describe()
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("Statement.Describe for query %q returned %+v; want %+v", entry.query, got, entry.want)
+		}
+		// The returned values do not change the statement.
+		if len(got.GraphNames) > 0 {
+			got.GraphNames[0] = "?changed"
+			if st.GraphNames()[0] == "?changed" {
+				t.Errorf("Statement.Describe for query %q shares the graph names with the statement", entry.query)
+			}
+		}
+	}
+}
+
+func TestSemanticStatementDump(t *testing.T) {
+	table := []struct {
+		query string
+		want  string
+	}{
+		{
+			query: `select ?o as ?friend, count(distinct ?s) as ?n from ?a, ?b where {?s "knows"@[] ?o . /u<joe> "knows"@[] ?o . !{?o "hates"@[] ?s}} group by ?friend order by ?n desc, ?friend limit "10"^^type:int64 offset @skip;`,
+			want: `(statement QUERY
+  (graphs ?a ?b)
+  (clause 2 (bindings ?o) { /u<joe> "knows"@[] ?o })
+  (clause 1 (bindings ?s ?o) { ?s "knows"@[] ?o })
+  (negated 1 (bindings ?o ?s) !{ ?o "hates"@[] ?s })
+  (projection ?o as ?friend)
+  (projection ?s as ?n (COUNT DISTINCT))
+  (group-by ?friend)
+  (order-by ?n desc, ?friend asc)
+  (limit 10)
+  (offset @skip))`,
+		},
+		{
+			query: `construct {?o "known_by"@[] ?s} into ?a from ?b where {?s "knows"@[] ?o};`,
+			want: `(statement CONSTRUCT
+  (graphs ?b)
+  (output-graphs ?a)
+  (clause 1 (bindings ?s ?o) { ?s "knows"@[] ?o })
+  (construct ?o "known_by"@[] ?s))`,
+		},
+		{
+			query: `select ?s from ?g as of ""@[2016-02-15T00:00:00Z] where {?s "bought"@[?t] ?o};`,
+			want: `(statement QUERY
+  (graphs ?g)
+  (as-of 2016-02-15T00:00:00Z)
+  (clause 0 (bindings ?s ?t ?o) { ?s "bought"@[?t] ?o })
+  (projection ?s))`,
+		},
+		{
+			query: `select ?s from ?g where {?s ("parent_of"@[] | "guardian_of"@[]) ?o};`,
+			want: `(statement QUERY
+  (graphs ?g)
+  (clause 1 (bindings ?s ?o) { ?s ("parent_of"@[] | "guardian_of"@[]) ?o })
+  (projection ?s))`,
+		},
+		{
+			query: `delete where {?s "obsolete"@[] ?o} from ?g;`,
+			want: `(statement DELETE
+  (graphs ?g)
+  (clause 1 (bindings ?s ?o) { ?s "obsolete"@[] ?o }))`,
+		},
+		{
+			query: `insert data into ?a {/_<foo> "bar"@[] /_<foo>};`,
+			want: "(statement INSERT\n" +
+				"  (graphs ?a)\n" +
+				"  (data /_<foo>\t\"bar\"@[]\t/_<foo>))",
+		},
+		{
+			query: `insert data into ?a {/sensor<a> "reading"@[NOW()] "42"^^type:int64};`,
+			want: "(statement INSERT\n" +
+				"  (graphs ?a)\n" +
+				"  (data /sensor<a>\t\"reading\"@[NOW()]\t\"42\"^^type:int64))",
+		},
+		{
+			query: `import "/_<foo> \"bar\"@[] /_<baz>
+			        /_<baz> \"bar\"@[] /_<foo>" into ?a;`,
+			want: `(statement IMPORT
+  (graphs ?a)
+  (import "/_<foo> \"bar\"@[] /_<baz>\n\t\t\t        /_<baz> \"bar\"@[] /_<foo>"))`,
+		},
+	}
+	p, err := NewParser(SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: Should have produced a valid BQL parser, %v", err)
+	}
+	for _, entry := range table {
+		st := &semantic.Statement{}
+		if err := p.Parse(NewLLk(entry.query, 1), st); err != nil {
+			t.Errorf("Parser.consume: Failed to accept valid semantic entry %q", entry.query)
+			continue
+		}
+		if got := st.Dump(); got != entry.want {
+			t.Errorf("Statement.Dump for query %q returned\n%s\nwant\n%s", entry.query, got, entry.want)
+		}
+	}
+}
@@ -16,6 +16,8 @@ package grammar
 
 import (
 	"fmt"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/google/badwolf/bql/lexer"
 )
@@ -23,9 +25,10 @@ import (
 // LLk provide the basic lookahead mechanisms required to implement a recursive
 // descent LLk parser.
 type LLk struct {
-	k    int
-	c    <-chan lexer.Token
-	tkns []lexer.Token
+	k     int
+	c     <-chan lexer.Token
+	tkns  []lexer.Token
+	input string
 }
 
 // NewLLk creates a LLk structure for the given string to parse and the
@@ -33,8 +36,9 @@ type LLk struct {
 func NewLLk(input string, k int) *LLk {
 	c := lexer.New(input, 2*k) // +2 to keep a bit of buffer available.
 	l := &LLk{
-		k: k,
-		c: c,
+		k:     k,
+		c:     c,
+		input: input,
 	}
 	for i := 0; i < k+1; i++ {
 		appendNextToken(l)
@@ -49,7 +53,7 @@ func appendNextToken(l *LLk) {
 		l.tkns = append(l.tkns, t)
 		return
 	}
-	l.tkns = append(l.tkns, lexer.Token{Type: lexer.ItemEOF})
+	l.tkns = append(l.tkns, lexer.Token{Type: lexer.ItemEOF, Offset: len(l.input)})
 }
 
 // Current returns the current token being processed.
@@ -57,6 +61,18 @@ func (l *LLk) Current() *lexer.Token {
 	return &l.tkns[0]
 }
 
+// Position returns the line and column, both starting at 1, where the current
+// token starts in the input. Columns are counted in runes.
+func (l *LLk) Position() (int, int) {
+	in := l.input[:l.tkns[0].Offset]
+	line, col := 1+strings.Count(in, "\n"), 1
+	if idx := strings.LastIndex(in, "\n"); idx >= 0 {
+		in = in[idx+1:]
+	}
+	col += utf8.RuneCountInString(in)
+	return line, col
+}
+
 // Peek returns the token for the k look ahead. It will return nil and failed
 // fail with an error if the provided k is bigger than the declared look ahead
 // on creation.
@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/semantic"
 )
 
 func TestEmptyInputLLk(t *testing.T) {
@@ -175,3 +176,52 @@ func TestStatementInputLLK(t *testing.T) {
 		l.Consume(l.Current().Type)
 	}
 }
+
+func TestPositionLLK(t *testing.T) {
+	statement := "select ?s\nfrom ?test\n  where {?s ?p \"ñu\"^^type:text . ?s ?p ?o};"
+	want := []struct {
+		text      string
+		line, col int
+	}{
+		{"select", 1, 1},
+		{"?s", 1, 8},
+		{"from", 2, 1},
+		{"?test", 2, 6},
+		{"where", 3, 3},
+		{"{", 3, 9},
+		{"?s", 3, 10},
+		{"?p", 3, 13},
+		{`"ñu"^^type:text`, 3, 16},
+		{".", 3, 32},
+		{"?s", 3, 34},
+		{"?p", 3, 37},
+		{"?o", 3, 40},
+		{"}", 3, 42},
+		{";", 3, 43},
+		{"", 3, 44},
+	}
+	l := NewLLk(statement, 1)
+	for _, w := range want {
+		if tkn := l.Current(); tkn.Text != w.text {
+			t.Fatalf("LLk.Current returned the wrong token; got %v, want text %q", tkn, w.text)
+		}
+		if line, col := l.Position(); line != w.line || col != w.col {
+			t.Errorf("LLk.Position returned the wrong position for %q; got %d:%d, want %d:%d", w.text, line, col, w.line, w.col)
+		}
+		l.Consume(l.Current().Type)
+	}
+}
+
+func TestPositionOfParseErrorsLLK(t *testing.T) {
+	p, err := NewParser(BQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser should have produced a valid BQL parser; %v", err)
+	}
+	l := NewLLk("select ?s\nfrom ?test\nwhere {?s ?p ?o} group ?s;", 1)
+	if err := p.Parse(l, &semantic.Statement{}); err == nil {
+		t.Fatal("Parser.Parse should have failed to parse a group clause missing by")
+	}
+	if line, col := l.Position(); line != 3 || col != 24 {
+		t.Errorf("LLk.Position returned the wrong position of the parse error; got %d:%d, want 3:24", line, col)
+	}
+}
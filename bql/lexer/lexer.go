@@ -45,8 +45,22 @@ const (
 	ItemConstruct
 	// ItemDrop represent the destruction of a graph in BQL.
 	ItemDrop
+	// ItemImport represents the import keyword used to load serialized triples
+	// into graphs in BQL.
+	ItemImport
+	// ItemShow represents the show keyword used to list the graphs of the
+	// store in BQL.
+	ItemShow
+	// ItemDescribe represents the describe keyword used to summarize the
+	// contents of graphs in BQL.
+	ItemDescribe
+	// ItemTruncate represents the truncate keyword used to remove all the
+	// triples of graphs while keeping them in BQL.
+	ItemTruncate
 	// ItemGraph represent the graph to be created of destroyed in BQL.
 	ItemGraph
+	// ItemGraphs represents the graphs keyword of show statements in BQL.
+	ItemGraphs
 	// ItemData represents the data keyword in BQL.
 	ItemData
 	// ItemInto represents the into keyword in BQL.
@@ -61,6 +75,15 @@ const (
 	ItemType
 	// ItemID represents id keyword in BQL.
 	ItemID
+	// ItemReify represents the reify keyword projecting the triple reified by
+	// a node in BQL.
+	ItemReify
+	// ItemCoalesce represents the coalesce keyword projecting the first bound
+	// binding of a list in BQL.
+	ItemCoalesce
+	// ItemConcat represents the concat keyword building a text literal out of
+	// several values in BQL.
+	ItemConcat
 	// ItemAt represents at keyword in BQL.
 	ItemAt
 	// ItemBefore represents the before keyword in BQL.
@@ -75,6 +98,12 @@ const (
 	ItemDistinct
 	// ItemSum represents the sum function in BQL.
 	ItemSum
+	// ItemMin represents the min function in BQL.
+	ItemMin
+	// ItemMax represents the max function in BQL.
+	ItemMax
+	// ItemAvg represents the avg function in BQL.
+	ItemAvg
 	// ItemGroup represents the group keyword in group by clause in BQL.
 	ItemGroup
 	// ItemBy represents the by keyword in group by clause in BQL.
@@ -83,20 +112,78 @@ const (
 	ItemOrder
 	// ItemHaving represents the having clause keyword clause in BQL.
 	ItemHaving
+	// ItemFilter represents the filter clause keyword in BQL.
+	ItemFilter
+	// ItemUnion represents the union keyword between graph patterns in BQL.
+	ItemUnion
+	// ItemOver represents the over keyword of window aggregations in BQL.
+	ItemOver
+	// ItemPartition represents the partition keyword of window aggregations in
+	// BQL.
+	ItemPartition
+	// ItemFuzzy represents the fuzzy approximate string matching function of
+	// filter clauses in BQL.
+	ItemFuzzy
+	// ItemStartsWith represents the starts_with text prefix matching function
+	// of filter clauses in BQL.
+	ItemStartsWith
+	// ItemPrefix represents the prefix keyword matching the predicates of graph
+	// clauses by the beginning of their IDs in BQL.
+	ItemPrefix
 	// ItemAsc represents asc keyword on order by clause in BQL.
 	ItemAsc
 	// ItemDesc represents desc keyword on order by clause in BQL
 	ItemDesc
 	// ItemLimit represents the limit clause in BQL.
 	ItemLimit
+	// ItemOffset represents the offset clause in BQL.
+	ItemOffset
+	// ItemSchema represents the schema keyword used to query the observed
+	// schema of a graph in BQL.
+	ItemSchema
+	// ItemFrequencies represents the frequencies keyword used to query the
+	// distinct values of a binding along with their number of occurrences in
+	// BQL.
+	ItemFrequencies
+	// ItemLatest represents the latest keyword used to query the newest
+	// triples of each subject in BQL.
+	ItemLatest
+	// ItemPer represents the per keyword of latest queries in BQL.
+	ItemPer
+	// ItemOf represents the of keyword of latest queries in BQL.
+	ItemOf
+	// ItemWeightedSample represents the weighted_sample keyword used to sample
+	// rows with probability proportional to a weight in BQL.
+	ItemWeightedSample
+	// ItemNewBlank represents the new_blank function minting fresh blank nodes
+	// in the templates of construct statements in BQL.
+	ItemNewBlank
+	// ItemExists represents the exists keyword of NOT EXISTS graph patterns in
+	// BQL.
+	ItemExists
+	// ItemIgnoreCase represents the ignore_case keyword used to match text
+	// literals regardless of their case in BQL.
+	ItemIgnoreCase
 	// ItemBinding represents a variable binding in BQL.
 	ItemBinding
+	// ItemParameter represents a query parameter in BQL whose value is provided
+	// when the query is executed.
+	ItemParameter
 	// ItemNode represents a BadWolf node in BQL.
 	ItemNode
 	// ItemBlankNode represents a blank BadWolf node in BQL.
 	ItemBlankNode
+	// ItemNodeType represents the node type constraint of a binding in BQL,
+	// for instance [/room].
+	ItemNodeType
 	// ItemLiteral represents a BadWolf literal in BQL.
 	ItemLiteral
+	// ItemString represents a quoted string in BQL that is neither a predicate
+	// nor a literal, for instance the payload of an import statement.
+	ItemString
+	// ItemNumber represents a non negative integer in BQL, for instance the 10
+	// of LIMIT 10.
+	ItemNumber
 	// ItemPredicate represents a BadWolf predicates in BQL.
 	ItemPredicate
 	// ItemPredicateBound represents a BadWolf predicate bound in BQL.
@@ -121,12 +208,28 @@ const (
 	ItemGT
 	// ItemEQ represents = in BQL.
 	ItemEQ
+	// ItemNEQ represents != in BQL.
+	ItemNEQ
+	// ItemLEQ represents <= in BQL.
+	ItemLEQ
+	// ItemGEQ represents >= in BQL.
+	ItemGEQ
+	// ItemMatch represents the =~ regular expression matching operator in BQL.
+	ItemMatch
 	// ItemNot represents keyword not in BQL.
 	ItemNot
 	// ItemAnd represents keyword and in BQL.
 	ItemAnd
 	// ItemOr represents keyword or in BQL.
 	ItemOr
+	// ItemBang represents the ! negation symbol in BQL.
+	ItemBang
+	// ItemPlus represents the + one or more path quantifier in BQL.
+	ItemPlus
+	// ItemStar represents the * zero or more path quantifier in BQL.
+	ItemStar
+	// ItemPipe represents the | predicate alternative separator in BQL.
+	ItemPipe
 )
 
 func (tt TokenType) String() string {
@@ -147,8 +250,18 @@ func (tt TokenType) String() string {
 		return "CONSTRUCT"
 	case ItemDrop:
 		return "DROP"
+	case ItemImport:
+		return "IMPORT"
+	case ItemShow:
+		return "SHOW"
+	case ItemDescribe:
+		return "DESCRIBE"
+	case ItemTruncate:
+		return "TRUNCATE"
 	case ItemGraph:
 		return "Graph"
+	case ItemGraphs:
+		return "GRAPHS"
 	case ItemData:
 		return "DATA"
 	case ItemInto:
@@ -161,12 +274,32 @@ func (tt TokenType) String() string {
 		return "COUNT"
 	case ItemSum:
 		return "SUM"
+	case ItemMin:
+		return "MIN"
+	case ItemMax:
+		return "MAX"
+	case ItemAvg:
+		return "AVG"
 	case ItemGroup:
 		return "GROUP"
 	case ItemBy:
 		return "BY"
 	case ItemHaving:
 		return "HAVING"
+	case ItemFilter:
+		return "FILTER"
+	case ItemUnion:
+		return "UNION"
+	case ItemOver:
+		return "OVER"
+	case ItemPartition:
+		return "PARTITION"
+	case ItemFuzzy:
+		return "FUZZY"
+	case ItemStartsWith:
+		return "STARTS_WITH"
+	case ItemPrefix:
+		return "PREFIX"
 	case ItemOrder:
 		return "ORDER"
 	case ItemAsc:
@@ -175,6 +308,26 @@ func (tt TokenType) String() string {
 		return "DESC"
 	case ItemLimit:
 		return "LIMIT"
+	case ItemOffset:
+		return "OFFSET"
+	case ItemSchema:
+		return "SCHEMA"
+	case ItemFrequencies:
+		return "FREQUENCIES"
+	case ItemLatest:
+		return "LATEST"
+	case ItemPer:
+		return "PER"
+	case ItemOf:
+		return "OF"
+	case ItemWeightedSample:
+		return "WEIGHTED_SAMPLE"
+	case ItemNewBlank:
+		return "NEW_BLANK"
+	case ItemExists:
+		return "EXISTS"
+	case ItemIgnoreCase:
+		return "IGNORE_CASE"
 	case ItemAs:
 		return "AS"
 	case ItemBefore:
@@ -185,12 +338,20 @@ func (tt TokenType) String() string {
 		return "BETWEEN"
 	case ItemBinding:
 		return "BINDING"
+	case ItemParameter:
+		return "PARAMETER"
 	case ItemNode:
 		return "NODE"
 	case ItemBlankNode:
 		return "BLANK_NODE"
+	case ItemNodeType:
+		return "NODE_TYPE"
 	case ItemLiteral:
 		return "LITERAL"
+	case ItemString:
+		return "STRING"
+	case ItemNumber:
+		return "NUMBER"
 	case ItemPredicate:
 		return "PREDICATE"
 	case ItemPredicateBound:
@@ -215,14 +376,36 @@ func (tt TokenType) String() string {
 		return "GT"
 	case ItemEQ:
 		return "EQ"
+	case ItemNEQ:
+		return "NEQ"
+	case ItemLEQ:
+		return "LEQ"
+	case ItemGEQ:
+		return "GEQ"
+	case ItemMatch:
+		return "MATCH"
 	case ItemNot:
 		return "NOT"
 	case ItemAnd:
 		return "AND"
 	case ItemOr:
 		return "OR"
+	case ItemBang:
+		return "BANG"
+	case ItemPlus:
+		return "PLUS"
+	case ItemStar:
+		return "STAR"
+	case ItemPipe:
+		return "PIPE"
 	case ItemID:
 		return "ID"
+	case ItemReify:
+		return "REIFY"
+	case ItemCoalesce:
+		return "COALESCE"
+	case ItemConcat:
+		return "CONCAT"
 	case ItemType:
 		return "TYPE"
 	case ItemAt:
@@ -242,6 +425,7 @@ const (
 	rightBracket   = rune('}')
 	leftPar        = rune('(')
 	rightPar       = rune(')')
+	leftSquarePar  = rune('[')
 	rightSquarePar = rune(']')
 	dot            = rune('.')
 	colon          = rune(':')
@@ -253,6 +437,11 @@ const (
 	lt             = rune('<')
 	gt             = rune('>')
 	eq             = rune('=')
+	bang           = rune('!')
+	tilde          = rune('~')
+	plus           = rune('+')
+	star           = rune('*')
+	pipe           = rune('|')
 	quote          = rune('"')
 	hat            = rune('^')
 	at             = rune('@')
@@ -263,7 +452,12 @@ const (
 	create         = "create"
 	construct      = "construct"
 	drop           = "drop"
+	importKeyword  = "import"
+	show           = "show"
+	describe       = "describe"
+	truncate       = "truncate"
 	graph          = "graph"
+	graphs         = "graphs"
 	data           = "data"
 	into           = "into"
 	from           = "from"
@@ -275,17 +469,40 @@ const (
 	count          = "count"
 	distinct       = "distinct"
 	sum            = "sum"
+	min            = "min"
+	max            = "max"
+	avg            = "avg"
 	group          = "group"
 	having         = "having"
+	filter         = "filter"
+	union          = "union"
+	over           = "over"
+	partition      = "partition"
+	fuzzy          = "fuzzy"
+	startsWith     = "starts_with"
+	prefix         = "prefix"
 	by             = "by"
 	order          = "order"
 	asc            = "asc"
 	desc           = "desc"
 	limit          = "limit"
+	offset         = "offset"
+	schema         = "schema"
+	frequencies    = "frequencies"
+	latest         = "latest"
+	per            = "per"
+	of             = "of"
+	weightedSample = "weighted_sample"
+	newBlank       = "new_blank"
+	exists         = "exists"
+	ignoreCase     = "ignore_case"
 	not            = "not"
 	and            = "and"
 	or             = "or"
 	id             = "id"
+	reify          = "reify"
+	coalesce       = "coalesce"
+	concat         = "concat"
 	typeKeyword    = "type"
 	atKeyword      = "at"
 	anchor         = "\"@["
@@ -295,6 +512,7 @@ const (
 	literalFloat   = "float64"
 	literalText    = "text"
 	literalBlob    = "blob"
+	literalDecimal = "decimal"
 )
 
 // Token contains the type and text collected around the captured token.
@@ -302,6 +520,8 @@ type Token struct {
 	Type         TokenType
 	Text         string
 	ErrorMessage string
+	// Offset is the byte offset in the input where the token starts.
+	Offset int
 }
 
 // String returns a readable form of the token.
@@ -360,12 +580,21 @@ func lexToken(l *lexer) stateFn {
 			case underscore:
 				l.next()
 				return lexBlankNode
+			case at:
+				l.next()
+				return lexParameter
+			case leftSquarePar:
+				l.next()
+				return lexNodeType
 			case quote:
 				return lexPredicateOrLiteral
 			}
 			if unicode.IsLetter(r) {
 				return lexKeyword
 			}
+			if isDecimalDigit(r) {
+				return lexNumber
+			}
 		}
 		if state := isSingleSymbolToken(l, ItemLBracket, leftBracket); state != nil {
 			return state
@@ -388,6 +617,18 @@ func lexToken(l *lexer) stateFn {
 		if state := isSingleSymbolToken(l, ItemComma, comma); state != nil {
 			return state
 		}
+		if state := isDoubleSymbolToken(l, ItemNEQ, bang, eq); state != nil {
+			return state
+		}
+		if state := isDoubleSymbolToken(l, ItemLEQ, lt, eq); state != nil {
+			return state
+		}
+		if state := isDoubleSymbolToken(l, ItemGEQ, gt, eq); state != nil {
+			return state
+		}
+		if state := isDoubleSymbolToken(l, ItemMatch, eq, tilde); state != nil {
+			return state
+		}
 		if state := isSingleSymbolToken(l, ItemLT, lt); state != nil {
 			return state
 		}
@@ -397,6 +638,18 @@ func lexToken(l *lexer) stateFn {
 		if state := isSingleSymbolToken(l, ItemEQ, eq); state != nil {
 			return state
 		}
+		if state := isSingleSymbolToken(l, ItemBang, bang); state != nil {
+			return state
+		}
+		if state := isSingleSymbolToken(l, ItemPlus, plus); state != nil {
+			return state
+		}
+		if state := isSingleSymbolToken(l, ItemStar, star); state != nil {
+			return state
+		}
+		if state := isSingleSymbolToken(l, ItemPipe, pipe); state != nil {
+			return state
+		}
 		{
 			r := l.next()
 			if unicode.IsSpace(r) {
@@ -422,6 +675,20 @@ func isSingleSymbolToken(l *lexer, tt TokenType, symbol rune) stateFn {
 	return nil
 }
 
+// isDoubleSymbolToken checks if a two char symbol should be lexed.
+func isDoubleSymbolToken(l *lexer, tt TokenType, first, second rune) stateFn {
+	if r := l.peek(); r != first {
+		return nil
+	}
+	if r, _ := utf8.DecodeRuneInString(l.input[l.pos+1:]); r != second {
+		return nil
+	}
+	l.next()
+	l.next()
+	l.emit(tt)
+	return lexSpace // Next state.
+}
+
 // lexBinding lexes a binding variable.
 func lexBinding(l *lexer) stateFn {
 	for {
@@ -434,6 +701,41 @@ func lexBinding(l *lexer) stateFn {
 	return lexSpace
 }
 
+// lexParameter lexes a query parameter out of the input.
+func lexParameter(l *lexer) stateFn {
+	if r := l.next(); !unicode.IsLetter(r) {
+		l.emitError("parameter name should begin with a letter")
+		return nil
+	}
+	for {
+		if r := l.next(); !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != rune('_') || r == eof {
+			l.backup()
+			l.emit(ItemParameter)
+			break
+		}
+	}
+	return lexSpace
+}
+
+// isDecimalDigit returns true if the rune is one of the ASCII decimal digits.
+func isDecimalDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// lexNumber lexes a non negative integer.
+func lexNumber(l *lexer) stateFn {
+	for isDecimalDigit(l.peek()) {
+		l.next()
+	}
+	if r := l.peek(); unicode.IsLetter(r) || r == '_' {
+		l.next()
+		l.emitError("numbers should only contain decimal digits")
+		return nil
+	}
+	l.emit(ItemNumber)
+	return lexSpace
+}
+
 // lexSpace consumes spaces without emitting any token.
 func lexSpace(l *lexer) stateFn {
 	for {
@@ -450,7 +752,7 @@ func lexSpace(l *lexer) stateFn {
 func lexKeyword(l *lexer) stateFn {
 	input := l.input[l.pos:]
 	f := func(r rune) bool {
-		return !unicode.IsLetter(r)
+		return !unicode.IsLetter(r) && r != '_'
 	}
 	if idx := strings.IndexFunc(input, f); idx >= 0 {
 		input = input[:idx]
@@ -479,10 +781,30 @@ func lexKeyword(l *lexer) stateFn {
 		consumeKeyword(l, ItemDrop)
 		return lexSpace
 	}
+	if strings.EqualFold(input, importKeyword) {
+		consumeKeyword(l, ItemImport)
+		return lexSpace
+	}
+	if strings.EqualFold(input, show) {
+		consumeKeyword(l, ItemShow)
+		return lexSpace
+	}
+	if strings.EqualFold(input, describe) {
+		consumeKeyword(l, ItemDescribe)
+		return lexSpace
+	}
+	if strings.EqualFold(input, truncate) {
+		consumeKeyword(l, ItemTruncate)
+		return lexSpace
+	}
 	if strings.EqualFold(input, graph) {
 		consumeKeyword(l, ItemGraph)
 		return lexSpace
 	}
+	if strings.EqualFold(input, graphs) {
+		consumeKeyword(l, ItemGraphs)
+		return lexSpace
+	}
 	if strings.EqualFold(input, data) {
 		consumeKeyword(l, ItemData)
 		return lexSpace
@@ -527,6 +849,18 @@ func lexKeyword(l *lexer) stateFn {
 		consumeKeyword(l, ItemSum)
 		return lexSpace
 	}
+	if strings.EqualFold(input, min) {
+		consumeKeyword(l, ItemMin)
+		return lexSpace
+	}
+	if strings.EqualFold(input, max) {
+		consumeKeyword(l, ItemMax)
+		return lexSpace
+	}
+	if strings.EqualFold(input, avg) {
+		consumeKeyword(l, ItemAvg)
+		return lexSpace
+	}
 	if strings.EqualFold(input, group) {
 		consumeKeyword(l, ItemGroup)
 		return lexSpace
@@ -551,10 +885,78 @@ func lexKeyword(l *lexer) stateFn {
 		consumeKeyword(l, ItemHaving)
 		return lexSpace
 	}
+	if strings.EqualFold(input, filter) {
+		consumeKeyword(l, ItemFilter)
+		return lexSpace
+	}
+	if strings.EqualFold(input, union) {
+		consumeKeyword(l, ItemUnion)
+		return lexSpace
+	}
+	if strings.EqualFold(input, over) {
+		consumeKeyword(l, ItemOver)
+		return lexSpace
+	}
+	if strings.EqualFold(input, partition) {
+		consumeKeyword(l, ItemPartition)
+		return lexSpace
+	}
+	if strings.EqualFold(input, fuzzy) {
+		consumeKeyword(l, ItemFuzzy)
+		return lexSpace
+	}
+	if strings.EqualFold(input, startsWith) {
+		consumeKeyword(l, ItemStartsWith)
+		return lexSpace
+	}
+	if strings.EqualFold(input, prefix) {
+		consumeKeyword(l, ItemPrefix)
+		return lexSpace
+	}
 	if strings.EqualFold(input, limit) {
 		consumeKeyword(l, ItemLimit)
 		return lexSpace
 	}
+	if strings.EqualFold(input, offset) {
+		consumeKeyword(l, ItemOffset)
+		return lexSpace
+	}
+	if strings.EqualFold(input, schema) {
+		consumeKeyword(l, ItemSchema)
+		return lexSpace
+	}
+	if strings.EqualFold(input, frequencies) {
+		consumeKeyword(l, ItemFrequencies)
+		return lexSpace
+	}
+	if strings.EqualFold(input, latest) {
+		consumeKeyword(l, ItemLatest)
+		return lexSpace
+	}
+	if strings.EqualFold(input, per) {
+		consumeKeyword(l, ItemPer)
+		return lexSpace
+	}
+	if strings.EqualFold(input, of) {
+		consumeKeyword(l, ItemOf)
+		return lexSpace
+	}
+	if strings.EqualFold(input, weightedSample) {
+		consumeKeyword(l, ItemWeightedSample)
+		return lexSpace
+	}
+	if strings.EqualFold(input, newBlank) {
+		consumeKeyword(l, ItemNewBlank)
+		return lexSpace
+	}
+	if strings.EqualFold(input, exists) {
+		consumeKeyword(l, ItemExists)
+		return lexSpace
+	}
+	if strings.EqualFold(input, ignoreCase) {
+		consumeKeyword(l, ItemIgnoreCase)
+		return lexSpace
+	}
 	if strings.EqualFold(input, not) {
 		consumeKeyword(l, ItemNot)
 		return lexSpace
@@ -571,6 +973,18 @@ func lexKeyword(l *lexer) stateFn {
 		consumeKeyword(l, ItemID)
 		return lexSpace
 	}
+	if strings.EqualFold(input, reify) {
+		consumeKeyword(l, ItemReify)
+		return lexSpace
+	}
+	if strings.EqualFold(input, coalesce) {
+		consumeKeyword(l, ItemCoalesce)
+		return lexSpace
+	}
+	if strings.EqualFold(input, concat) {
+		consumeKeyword(l, ItemConcat)
+		return lexSpace
+	}
 	if strings.EqualFold(input, typeKeyword) {
 		consumeKeyword(l, ItemType)
 		return lexSpace
@@ -636,9 +1050,35 @@ func lexBlankNode(l *lexer) stateFn {
 	return lexSpace
 }
 
+// lexNodeType tries to lex the node type constraint of a binding out of the
+// input.
+func lexNodeType(l *lexer) stateFn {
+	if r := l.next(); r != slash {
+		l.emitError("node type constraint should start with a / delimiter")
+		return nil
+	}
+	for {
+		r := l.next()
+		if r == rightSquarePar {
+			l.emit(ItemNodeType)
+			break
+		}
+		if unicode.IsSpace(r) || r == eof {
+			l.emitError("node type constraint is not properly terminated; missing final ] delimiter")
+			return nil
+		}
+	}
+	return lexSpace
+}
+
 // lexPredicateOrLiteral tries to lex a predicate or a literal out of the input.
+// Quoted strings not followed by a time anchor or a type are lexed as plain
+// strings.
 func lexPredicateOrLiteral(l *lexer) stateFn {
 	text := l.input[l.pos:]
+	if end := closingQuote(text); end > 0 && (end+1 == len(text) || (text[end+1] != '@' && text[end+1] != '^')) {
+		return lexString
+	}
 	// Fix issue 39 (https://github.com/google/badwolf/issues/39)
 	pIdx, lIdx := strings.Index(text, "\"@["), strings.Index(text, "\"^^type:")
 	if pIdx < 0 && lIdx < 0 {
@@ -651,6 +1091,30 @@ func lexPredicateOrLiteral(l *lexer) stateFn {
 	return lexLiteral
 }
 
+// closingQuote returns the index of the quote closing the quoted string the
+// text starts with, skipping escaped characters. It returns -1 if the string is
+// not terminated.
+func closingQuote(text string) int {
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// lexString lexes a plain quoted string out of the input.
+func lexString(l *lexer) stateFn {
+	for end := l.pos + closingQuote(l.input[l.pos:]); l.pos <= end; {
+		l.next()
+	}
+	l.emit(ItemString)
+	return lexSpace
+}
+
 // lexPredicate lexes a predicate out of the input.
 func lexPredicate(l *lexer) stateFn {
 	l.next()
@@ -670,6 +1134,8 @@ func lexPredicate(l *lexer) stateFn {
 			var (
 				nr     rune
 				commas = 0
+				// Anchors starting with = match an exact time.
+				exact = l.peek() == eq
 			)
 			for {
 				nr = l.next()
@@ -688,7 +1154,11 @@ func lexPredicate(l *lexer) stateFn {
 				l.emitError("predicate bounds should only have one , to separate bounds")
 				return nil
 			}
-			if commas == 0 {
+			if exact && commas > 0 {
+				l.emitError("exact predicate time anchors cannot have bounds separated by ,")
+				return nil
+			}
+			if commas == 0 && !exact {
 				l.emit(ItemPredicate)
 			} else {
 				l.emit(ItemPredicateBound)
@@ -728,7 +1198,7 @@ func lexLiteral(l *lexer) stateFn {
 			}
 			literalT = strings.ToLower(literalT)
 			switch literalT {
-			case literalBool, literalInt, literalFloat, literalText, literalBlob:
+			case literalBool, literalInt, literalFloat, literalText, literalBlob, literalDecimal:
 				l.backup()
 				l.emit(ItemLiteral)
 				done = true
@@ -747,7 +1217,7 @@ func lexLiteral(l *lexer) stateFn {
 // consumeKeyword consume and emits a valid token
 func consumeKeyword(l *lexer, t TokenType) {
 	for {
-		if r := l.next(); !unicode.IsLetter(r) || r == eof {
+		if r := l.next(); !(unicode.IsLetter(r) || r == '_') || r == eof {
 			l.backup()
 			l.emit(t)
 			break
@@ -766,8 +1236,9 @@ func (l *lexer) run() {
 // emit passes an item back to the client.
 func (l *lexer) emit(t TokenType) {
 	l.tokens <- Token{
-		Type: t,
-		Text: l.input[l.start:l.pos],
+		Type:   t,
+		Text:   l.input[l.start:l.pos],
+		Offset: l.start,
 	}
 	l.start = l.pos
 }
@@ -778,6 +1249,7 @@ func (l *lexer) emitError(msg string) {
 		Type:         ItemError,
 		Text:         l.input[l.start:l.pos],
 		ErrorMessage: fmt.Sprintf("[lexer:%d:%d] %s", l.line, l.col, msg),
+		Offset:       l.start,
 	}
 	l.start = l.pos
 }
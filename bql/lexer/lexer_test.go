@@ -14,7 +14,10 @@
 
 package lexer
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestIndividualTokens(t *testing.T) {
 	table := []struct {
@@ -24,7 +27,7 @@ func TestIndividualTokens(t *testing.T) {
 		{"",
 			[]Token{
 				{Type: ItemEOF}}},
-		{"{}().;,<>=",
+		{"{}().;,<> =!+*|",
 			[]Token{
 				{Type: ItemLBracket, Text: "{"},
 				{Type: ItemRBracket, Text: "}"},
@@ -36,6 +39,20 @@ func TestIndividualTokens(t *testing.T) {
 				{Type: ItemLT, Text: "<"},
 				{Type: ItemGT, Text: ">"},
 				{Type: ItemEQ, Text: "="},
+				{Type: ItemBang, Text: "!"},
+				{Type: ItemPlus, Text: "+"},
+				{Type: ItemStar, Text: "*"},
+				{Type: ItemPipe, Text: "|"},
+				{Type: ItemEOF}}},
+		{"!=<=>==~ < = !",
+			[]Token{
+				{Type: ItemNEQ, Text: "!="},
+				{Type: ItemLEQ, Text: "<="},
+				{Type: ItemGEQ, Text: ">="},
+				{Type: ItemMatch, Text: "=~"},
+				{Type: ItemLT, Text: "<"},
+				{Type: ItemEQ, Text: "="},
+				{Type: ItemBang, Text: "!"},
 				{Type: ItemEOF}}},
 		{"?foo ?bar ?1234 ?foo_bar ?bar_foo",
 			[]Token{
@@ -45,9 +62,9 @@ func TestIndividualTokens(t *testing.T) {
 				{Type: ItemBinding, Text: "?foo_bar"},
 				{Type: ItemBinding, Text: "?bar_foo"},
 				{Type: ItemEOF}}},
-		{`SeLeCt FrOm WhErE As BeFoRe AfTeR BeTwEeN CoUnT SuM GrOuP bY HaViNg LiMiT
+		{`SeLeCt FrOm WhErE As BeFoRe AfTeR BeTwEeN CoUnT SuM MiN MaX AvG GrOuP bY HaViNg FiLtEr UnIoN OvEr PaRtItIoN FuZzY StArTs_WiTh PrEfIx ReIfY CoAlEsCe CoNcAt LiMiT OfFsEt SchEmA FrEqUeNcIeS LaTeSt PeR oF WeIgHtEd_SaMpLe NeW_BlAnK ExIsTs IgNoRe_CaSe
 		  OrDeR AsC DeSc NoT AnD Or Id TyPe At DiStInCt InSeRt DeLeTe DaTa InTo
-		  cONsTruCT CrEaTe DrOp GrApH`,
+		  cONsTruCT CrEaTe DrOp GrApH ImPoRt ShOw GrApHs DeScRiBe TrUnCaTe`,
 			[]Token{
 				{Type: ItemQuery, Text: "SeLeCt"},
 				{Type: ItemFrom, Text: "FrOm"},
@@ -58,10 +75,33 @@ func TestIndividualTokens(t *testing.T) {
 				{Type: ItemBetween, Text: "BeTwEeN"},
 				{Type: ItemCount, Text: "CoUnT"},
 				{Type: ItemSum, Text: "SuM"},
+				{Type: ItemMin, Text: "MiN"},
+				{Type: ItemMax, Text: "MaX"},
+				{Type: ItemAvg, Text: "AvG"},
 				{Type: ItemGroup, Text: "GrOuP"},
 				{Type: ItemBy, Text: "bY"},
 				{Type: ItemHaving, Text: "HaViNg"},
+				{Type: ItemFilter, Text: "FiLtEr"},
+				{Type: ItemUnion, Text: "UnIoN"},
+				{Type: ItemOver, Text: "OvEr"},
+				{Type: ItemPartition, Text: "PaRtItIoN"},
+				{Type: ItemFuzzy, Text: "FuZzY"},
+				{Type: ItemStartsWith, Text: "StArTs_WiTh"},
+				{Type: ItemPrefix, Text: "PrEfIx"},
+				{Type: ItemReify, Text: "ReIfY"},
+				{Type: ItemCoalesce, Text: "CoAlEsCe"},
+				{Type: ItemConcat, Text: "CoNcAt"},
 				{Type: ItemLimit, Text: "LiMiT"},
+				{Type: ItemOffset, Text: "OfFsEt"},
+				{Type: ItemSchema, Text: "SchEmA"},
+				{Type: ItemFrequencies, Text: "FrEqUeNcIeS"},
+				{Type: ItemLatest, Text: "LaTeSt"},
+				{Type: ItemPer, Text: "PeR"},
+				{Type: ItemOf, Text: "oF"},
+				{Type: ItemWeightedSample, Text: "WeIgHtEd_SaMpLe"},
+				{Type: ItemNewBlank, Text: "NeW_BlAnK"},
+				{Type: ItemExists, Text: "ExIsTs"},
+				{Type: ItemIgnoreCase, Text: "IgNoRe_CaSe"},
 				{Type: ItemOrder, Text: "OrDeR"},
 				{Type: ItemAsc, Text: "AsC"},
 				{Type: ItemDesc, Text: "DeSc"},
@@ -80,6 +120,11 @@ func TestIndividualTokens(t *testing.T) {
 				{Type: ItemCreate, Text: "CrEaTe"},
 				{Type: ItemDrop, Text: "DrOp"},
 				{Type: ItemGraph, Text: "GrApH"},
+				{Type: ItemImport, Text: "ImPoRt"},
+				{Type: ItemShow, Text: "ShOw"},
+				{Type: ItemGraphs, Text: "GrApHs"},
+				{Type: ItemDescribe, Text: "DeScRiBe"},
+				{Type: ItemTruncate, Text: "TrUnCaTe"},
 				{Type: ItemEOF}}},
 		{"/_<foo>/_<bar>",
 			[]Token{
@@ -123,6 +168,32 @@ func TestIndividualTokens(t *testing.T) {
 				{Type: ItemError, Text: "_:_",
 					ErrorMessage: "[lexer:0:3] blank node label should begin with a letter"},
 				{Type: ItemEOF}}},
+		{"?s[/room] [/item/book]",
+			[]Token{
+				{Type: ItemBinding, Text: "?s"},
+				{Type: ItemNodeType, Text: "[/room]"},
+				{Type: ItemNodeType, Text: "[/item/book]"},
+				{Type: ItemEOF}}},
+		{"[room]",
+			[]Token{
+				{Type: ItemError, Text: "[r",
+					ErrorMessage: "[lexer:0:2] node type constraint should start with a / delimiter"},
+				{Type: ItemEOF}}},
+		{"[/room",
+			[]Token{
+				{Type: ItemError, Text: "[/room",
+					ErrorMessage: "[lexer:0:6] node type constraint is not properly terminated; missing final ] delimiter"},
+				{Type: ItemEOF}}},
+		{"@n @page_size2",
+			[]Token{
+				{Type: ItemParameter, Text: "@n"},
+				{Type: ItemParameter, Text: "@page_size2"},
+				{Type: ItemEOF}}},
+		{"@1",
+			[]Token{
+				{Type: ItemError, Text: "@1",
+					ErrorMessage: "[lexer:0:2] parameter name should begin with a letter"},
+				{Type: ItemEOF}}},
 		{`"true"^^type:bool "1"^^type:int64"2"^^type:float64"t"^^type:text`,
 			[]Token{
 				{Type: ItemLiteral, Text: `"true"^^type:bool`},
@@ -134,6 +205,19 @@ func TestIndividualTokens(t *testing.T) {
 			[]Token{
 				{Type: ItemLiteral, Text: `"[1 2 3 4]"^^type:blob`},
 				{Type: ItemEOF}}},
+		{`"aGVsbG8="^^type:blob`,
+			[]Token{
+				{Type: ItemLiteral, Text: `"aGVsbG8="^^type:blob`},
+				{Type: ItemEOF}}},
+		{`"19.99"^^type:decimal`,
+			[]Token{
+				{Type: ItemLiteral, Text: `"19.99"^^type:decimal`},
+				{Type: ItemEOF}}},
+		{`"/_<foo> \"bar\"@[] /_<baz>\n" "x"`,
+			[]Token{
+				{Type: ItemString, Text: `"/_<foo> \"bar\"@[] /_<baz>\n"`},
+				{Type: ItemString, Text: `"x"`},
+				{Type: ItemEOF}}},
 		{"\"1\"^type:int64",
 			[]Token{
 				{Type: ItemError,
@@ -155,6 +239,17 @@ func TestIndividualTokens(t *testing.T) {
 				{Type: ItemPredicateBound, Text: `"p4"@[,"b"]`},
 				{Type: ItemPredicateBound, Text: `"p4"@[,]`},
 				{Type: ItemEOF}}},
+		{`"p5"@[=2016-02-01T00:00:00-08:00]"p5"@[=?t]`,
+			[]Token{
+				{Type: ItemPredicateBound, Text: `"p5"@[=2016-02-01T00:00:00-08:00]`},
+				{Type: ItemPredicateBound, Text: `"p5"@[=?t]`},
+				{Type: ItemEOF}}},
+		{`"p1"@[=a,b]`,
+			[]Token{
+				{Type: ItemError,
+					Text:         `"p1"@[=a,b]`,
+					ErrorMessage: "[lexer:0:11] exact predicate time anchors cannot have bounds separated by ,"},
+				{Type: ItemEOF}}},
 		{`"p1"@]`,
 			[]Token{
 				{Type: ItemError,
@@ -177,6 +272,40 @@ func TestIndividualTokens(t *testing.T) {
 				{Type: ItemPredicate, Text: `"connects_to"@[]`},
 				{Type: ItemNode, Text: `/room<001>`},
 				{Type: ItemEOF}}},
+		{"limit 10 offset 0042",
+			[]Token{
+				{Type: ItemLimit, Text: "limit"},
+				{Type: ItemNumber, Text: "10"},
+				{Type: ItemOffset, Text: "offset"},
+				{Type: ItemNumber, Text: "0042"},
+				{Type: ItemEOF}}},
+		{"limit 7abc",
+			[]Token{
+				{Type: ItemLimit, Text: "limit"},
+				{Type: ItemError, Text: "7a",
+					ErrorMessage: "[lexer:0:8] numbers should only contain decimal digits"}}},
+		{`?a "parent_of"@[]+ /u<b> . ?a "parent_of"@[]* ?c`,
+			[]Token{
+				{Type: ItemBinding, Text: `?a`},
+				{Type: ItemPredicate, Text: `"parent_of"@[]`},
+				{Type: ItemPlus, Text: `+`},
+				{Type: ItemNode, Text: `/u<b>`},
+				{Type: ItemDot, Text: `.`},
+				{Type: ItemBinding, Text: `?a`},
+				{Type: ItemPredicate, Text: `"parent_of"@[]`},
+				{Type: ItemStar, Text: `*`},
+				{Type: ItemBinding, Text: `?c`},
+				{Type: ItemEOF}}},
+		{`?s ("parent_of"@[] | "guardian_of"@[]) ?o`,
+			[]Token{
+				{Type: ItemBinding, Text: `?s`},
+				{Type: ItemLPar, Text: `(`},
+				{Type: ItemPredicate, Text: `"parent_of"@[]`},
+				{Type: ItemPipe, Text: `|`},
+				{Type: ItemPredicate, Text: `"guardian_of"@[]`},
+				{Type: ItemRPar, Text: `)`},
+				{Type: ItemBinding, Text: `?o`},
+				{Type: ItemEOF}}},
 	}
 
 	for _, test := range table {
@@ -186,6 +315,8 @@ func TestIndividualTokens(t *testing.T) {
 			if idx >= len(test.tokens) {
 				t.Fatalf("lex(%q) has not finished producing tokens when it should have.", test.input)
 			}
+			// Offsets are checked by TestTokenOffsets.
+			got.Offset = 0
 			if want := test.tokens[idx]; got != want {
 				t.Errorf("lex(%q) failed to provide %+v, got %+v instead", test.input, want, got)
 			}
@@ -194,6 +325,19 @@ func TestIndividualTokens(t *testing.T) {
 	}
 }
 
+func TestTokenOffsets(t *testing.T) {
+	input := "select ?s\nfrom ?g where {?s \"ñu\"@[] ?s};"
+	want := []int{0, 7, 10, 15, 18, 24, 25, 28, 37, 39, 40, 41}
+	_, c := lex(input, 0)
+	var got []int
+	for tkn := range c {
+		got = append(got, tkn.Offset)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lex(%q) returned the wrong token offsets; got %v, want %v", input, got, want)
+	}
+}
+
 func TestValidTokenQuery(t *testing.T) {
 	table := []struct {
 		input  string
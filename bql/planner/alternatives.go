@@ -0,0 +1,94 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// expandAlternatives returns one clause per predicate alternative of the
+// provided clause, in the order they were written. Each returned clause only
+// matches its own predicate.
+func expandAlternatives(cls *semantic.GraphClause) []*semantic.GraphClause {
+	var res []*semantic.GraphClause
+	for _, p := range cls.PAlternatives {
+		nc := &semantic.GraphClause{}
+		*nc = *cls
+		nc.P, nc.PTemporal, nc.PAlternatives = p, p.Type() == predicate.Temporal, nil
+		res = append(res, nc)
+	}
+	return res
+}
+
+// processAlternatives resolves a clause with predicate alternatives as the
+// union of the clause resolved once per alternative against the current
+// table. Rows are kept in the order the alternatives were written, and a
+// subject and object connected by several of the predicates produce a row
+// for each of them.
+func (p *queryPlan) processAlternatives(ctx context.Context, cls *semantic.GraphClause, lo *storage.LookupOptions) (bool, error) {
+	base := p.tbl
+	defer func() {
+		p.tbl = base
+	}()
+	var tbls []*table.Table
+	for _, alt := range expandAlternatives(cls) {
+		p.tbl = base.Copy()
+		unresolvable, err := p.processClause(ctx, alt, lo)
+		if err != nil {
+			return false, err
+		}
+		if !unresolvable {
+			tbls = append(tbls, p.tbl)
+		}
+	}
+	if len(tbls) == 0 {
+		return true, nil
+	}
+	n := int64(0)
+	for _, t := range tbls {
+		n += int64(t.NumRows())
+	}
+	if err := p.checkRows(n); err != nil {
+		return false, err
+	}
+	base.Truncate()
+	for _, t := range tbls {
+		base.AddBindings(t.Bindings())
+		for _, r := range t.Rows() {
+			base.AddRow(r)
+		}
+	}
+	if p.rowCap > 0 {
+		base.Limit(p.rowCap)
+	}
+	return false, nil
+}
+
+// alternativeMatches returns true if any of the predicate alternatives of the
+// negated clause matches once bound to the provided row.
+func (p *queryPlan) alternativeMatches(ctx context.Context, cls *semantic.GraphClause, r table.Row, lo *storage.LookupOptions) (bool, error) {
+	for _, alt := range expandAlternatives(cls) {
+		b, err := p.negatedClauseMatches(ctx, alt, r, lo)
+		if err != nil || b {
+			return b, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,247 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
+)
+
+// QueryCache memoizes the result tables of query plans keyed by the query
+// text, its parameters, and the generation of the store. Entries are evicted
+// in least recently used order once the number of entries or the estimated
+// size of the cached tables goes over budget.
+//
+// A cached table is only returned if the store generation did not change
+// since it was computed, hence results are exactly consistent with a fresh
+// execution within a generation. Stores that do not implement
+// storage.GenerationCounter are never cached.
+//
+// The cache keeps its own copy of each table and hands out copies on every
+// hit, hence callers are free to modify the returned tables.
+type QueryCache struct {
+	store      storage.Store
+	maxEntries int
+	maxBytes   int
+
+	mu      sync.Mutex
+	gen     uint64
+	size    int
+	lru     *list.List
+	entries map[cacheKey]*list.Element
+}
+
+// cacheKey identifies a cached result table.
+type cacheKey struct {
+	query  string
+	params string
+	gen    uint64
+}
+
+// cacheEntry contains a cached result table and the hash computed when it was
+// added to the cache.
+type cacheEntry struct {
+	key  cacheKey
+	tbl  *table.Table
+	hash []byte
+	size int
+}
+
+// NewQueryCache returns a new cache for the queries run against the provided
+// store. The cache holds at most maxEntries tables whose estimated size adds up
+// to at most maxBytes. Non positive budgets are unbounded.
+func NewQueryCache(s storage.Store, maxEntries, maxBytes int) *QueryCache {
+	return &QueryCache{
+		store:      s,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		lru:        list.New(),
+		entries:    make(map[cacheKey]*list.Element),
+	}
+}
+
+// Len returns the number of cached tables.
+func (c *QueryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Size returns the estimated size in bytes of the cached tables.
+func (c *QueryCache) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Executor decorates the provided executor with the cache. Only query plans
+// are cached; any other executor is returned untouched.
+func (c *QueryCache) Executor(query string, params []string, e Executor) Executor {
+	if _, ok := e.(*queryPlan); !ok {
+		return e
+	}
+	return &cachedPlan{
+		cache: c,
+		query: query,
+		// The NUL separator cannot be part of a BQL query parameter.
+		params: strings.Join(params, "\x00"),
+		plan:   e,
+	}
+}
+
+// get returns a copy of the cached table for the provided key if available.
+func (c *QueryCache) get(k cacheKey) (*table.Table, bool) {
+	c.mu.Lock()
+	e, ok := c.entries[k]
+	if !ok {
+		c.mu.Unlock()
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	tbl := e.Value.(*cacheEntry).tbl
+	c.mu.Unlock()
+	// Cached tables are never modified, hence they can be copied without
+	// holding the lock.
+	return tbl.Copy(), true
+}
+
+// put adds a copy of the table to the cache and evicts the least recently used
+// entries until the cache is back within budget. Entries from older
+// generations can no longer be hit, hence they are purged when a newer
+// generation is seen. Tables from generations older than the newest one seen
+// are not cached.
+func (c *QueryCache) put(k cacheKey, tbl *table.Table) {
+	b, err := tbl.ToText("\t")
+	if err != nil {
+		return
+	}
+	h, err := tbl.Hash()
+	if err != nil {
+		return
+	}
+	ce := &cacheEntry{
+		key:  k,
+		hash: h,
+		size: b.Len(),
+	}
+	if c.maxBytes > 0 && ce.size > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case k.gen < c.gen:
+		return
+	case k.gen > c.gen:
+		for e := c.lru.Front(); e != nil; {
+			next := e.Next()
+			c.remove(e)
+			e = next
+		}
+		c.gen = k.gen
+	}
+	if e, ok := c.entries[k]; ok {
+		// Concurrent executions of the same query may race to cache the same
+		// table; keep the cached one if both match.
+		if bytes.Equal(e.Value.(*cacheEntry).hash, ce.hash) {
+			c.lru.MoveToFront(e)
+			return
+		}
+		c.remove(e)
+	}
+	ce.tbl = tbl.Copy()
+	c.entries[k] = c.lru.PushFront(ce)
+	c.size += ce.size
+	for (c.maxEntries > 0 && c.lru.Len() > c.maxEntries) || (c.maxBytes > 0 && c.size > c.maxBytes) {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops the provided element from the cache. It assumes the lock is
+// already held.
+func (c *QueryCache) remove(e *list.Element) {
+	ce := c.lru.Remove(e).(*cacheEntry)
+	delete(c.entries, ce.key)
+	c.size -= ce.size
+}
+
+// cachedPlan decorates a query plan with a query cache.
+type cachedPlan struct {
+	cache  *QueryCache
+	query  string
+	params string
+	plan   Executor
+}
+
+// Execute returns the cached table if the store did not change since it was
+// computed. Otherwise, it runs the decorated plan and caches the result if the
+// store generation did not change during the execution.
+func (p *cachedPlan) Execute(ctx context.Context) (*table.Table, error) {
+	return p.execute(ctx, p.params, p.plan.Execute)
+}
+
+// ExecuteWithParameters works like Execute, but it binds the provided values
+// to the statement parameters. The values are part of the cache key, hence
+// each set of values is cached independently.
+func (p *cachedPlan) ExecuteWithParameters(ctx context.Context, ps map[string]interface{}) (*table.Table, error) {
+	var kvs []string
+	for n, v := range ps {
+		kvs = append(kvs, fmt.Sprintf("%s=%T %v", n, v, v))
+	}
+	sort.Strings(kvs)
+	pe := p.plan.(ParameterizedExecutor)
+	return p.execute(ctx, p.params+"\x00\x00"+strings.Join(kvs, "\x00"), func(ctx context.Context) (*table.Table, error) {
+		return pe.ExecuteWithParameters(ctx, ps)
+	})
+}
+
+// execute returns the cached table for the provided parameters or runs the
+// provided function to compute it.
+func (p *cachedPlan) execute(ctx context.Context, params string, run func(context.Context) (*table.Table, error)) (*table.Table, error) {
+	gc, ok := p.cache.store.(storage.GenerationCounter)
+	if !ok {
+		return run(ctx)
+	}
+	gen, err := gc.Generation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	k := cacheKey{query: p.query, params: params, gen: gen}
+	if tbl, ok := p.cache.get(k); ok {
+		return tbl, nil
+	}
+	tbl, err := run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if after, err := gc.Generation(ctx); err == nil && after == gen {
+		p.cache.put(k, tbl)
+	}
+	return tbl, nil
+}
+
+// String returns a readable description of the execution plan.
+func (p *cachedPlan) String() string {
+	return "cached " + p.plan.String()
+}
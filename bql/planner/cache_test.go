@@ -0,0 +1,176 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+)
+
+// runCached plans and executes the provided query through the cache.
+func runCached(t *testing.T, c *QueryCache, s storage.Store, query string, params ...string) *table.Table {
+	tbl, err := c.Executor(query, params, planQuery(t, s, query)).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("planner.Execute failed for query %q with error %v", query, err)
+	}
+	return tbl
+}
+
+// isCached returns true if the cache holds a table for the provided query.
+func isCached(c *QueryCache, query string, params ...string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[cacheKey{query: query, params: strings.Join(params, "\x00"), gen: c.gen}]
+	return ok
+}
+
+func TestQueryCacheHit(t *testing.T) {
+	s := populateTestStore(t)
+	c := NewQueryCache(s, 10, 0)
+	q := `select ?o from ?test where {/u<joe> "parent_of"@[] ?o};`
+	t1 := runCached(t, c, s, q)
+	if !isCached(c, q) {
+		t.Fatalf("QueryCache should have cached the table for %q", q)
+	}
+	t2 := runCached(t, c, s, q)
+	if t1 == t2 {
+		t.Errorf("QueryCache should return a copy of the cached table for %q", q)
+	}
+	if got, want := t2.String(), t1.String(); got != want {
+		t.Errorf("QueryCache returned %q; want %q", got, want)
+	}
+	if got, want := c.Len(), 1; got != want {
+		t.Errorf("QueryCache.Len() returned %d; want %d", got, want)
+	}
+	runCached(t, c, s, q, "other")
+	if got, want := c.Len(), 2; got != want {
+		t.Errorf("QueryCache should not share cached tables across different parameters; got %d entries, want %d", got, want)
+	}
+}
+
+func TestQueryCacheInvalidatesOnMutation(t *testing.T) {
+	s, ctx := populateTestStore(t), context.Background()
+	c := NewQueryCache(s, 10, 0)
+	q := `select ?o from ?test where {/u<joe> "parent_of"@[] ?o};`
+	t1 := runCached(t, c, s, q)
+	g, err := s.Graph(ctx, "?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trpl, err := triple.Parse(`/u<joe>	"parent_of"@[]	/u<alice>`, literal.DefaultBuilder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.AddTriples(ctx, []*triple.Triple{trpl}); err != nil {
+		t.Fatal(err)
+	}
+	t2 := runCached(t, c, s, q)
+	if got, want := t2.NumRows(), t1.NumRows()+1; got != want {
+		t.Errorf("QueryCache returned %d rows after the mutation; want %d", got, want)
+	}
+}
+
+func TestQueryCachePurgesOldGenerations(t *testing.T) {
+	s := populateTestStore(t)
+	c := NewQueryCache(s, 10, 0)
+	q1 := `select ?o from ?test where {/u<joe> "parent_of"@[] ?o};`
+	q2 := `select ?o from ?test where {/u<peter> "parent_of"@[] ?o};`
+	tbl := runCached(t, c, s, q1)
+	gen := c.gen
+	c.put(cacheKey{query: q2, gen: gen + 1}, tbl)
+	if got, want := c.Len(), 1; got != want {
+		t.Errorf("QueryCache.Len() returned %d after a newer generation; want %d", got, want)
+	}
+	if isCached(c, q1) {
+		t.Errorf("QueryCache should have purged the tables of older generations")
+	}
+	c.put(cacheKey{query: q1, gen: gen}, tbl)
+	if isCached(c, q1) || c.Len() != 1 {
+		t.Errorf("QueryCache should not cache tables of older generations")
+	}
+}
+
+func TestQueryCacheEviction(t *testing.T) {
+	s := populateTestStore(t)
+	q1 := `select ?o from ?test where {/u<joe> "parent_of"@[] ?o};`
+	q2 := `select ?o from ?test where {/u<peter> "parent_of"@[] ?o};`
+
+	// Evict by number of entries.
+	c := NewQueryCache(s, 1, 0)
+	t1 := runCached(t, c, s, q1)
+	runCached(t, c, s, q2)
+	if got, want := c.Len(), 1; got != want {
+		t.Errorf("QueryCache.Len() returned %d; want %d", got, want)
+	}
+	if isCached(c, q1) || !isCached(c, q2) {
+		t.Errorf("QueryCache should have evicted the least recently used table for %q", q1)
+	}
+
+	// Evict by size budget.
+	b, err := t1.ToText("\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = NewQueryCache(s, 0, b.Len())
+	runCached(t, c, s, q1)
+	runCached(t, c, s, q2)
+	if got, want := c.Len(), 1; got != want {
+		t.Errorf("QueryCache.Len() returned %d; want %d", got, want)
+	}
+	if c.Size() > b.Len() {
+		t.Errorf("QueryCache.Size() returned %d; should not be over budget %d", c.Size(), b.Len())
+	}
+
+	// Tables over budget are never cached.
+	c = NewQueryCache(s, 0, 1)
+	runCached(t, c, s, q1)
+	if got, want := c.Len(), 0; got != want {
+		t.Errorf("QueryCache.Len() returned %d; want %d", got, want)
+	}
+}
+
+func TestQueryCacheIntegrity(t *testing.T) {
+	s := populateTestStore(t)
+	c := NewQueryCache(s, 10, 0)
+	q := `select ?o from ?test where {/u<joe> "parent_of"@[] ?o};`
+	t1 := runCached(t, c, s, q)
+	want := t1.NumRows()
+	t1.Truncate()
+	t2 := runCached(t, c, s, q)
+	if got := t2.NumRows(); got != want {
+		t.Errorf("QueryCache returned %d rows after modifying the executed table; want %d", got, want)
+	}
+	t2.Truncate()
+	if got := runCached(t, c, s, q).NumRows(); got != want {
+		t.Errorf("QueryCache returned %d rows after modifying a cached table; want %d", got, want)
+	}
+}
+
+func TestQueryCacheOnlyDecoratesQueries(t *testing.T) {
+	s := populateTestStore(t)
+	c := NewQueryCache(s, 10, 0)
+	q := `insert data into ?test {/u<joe> "parent_of"@[] /u<alice>};`
+	plnr := planQuery(t, s, q)
+	if got := c.Executor(q, nil, plnr); got != plnr {
+		t.Errorf("QueryCache.Executor should not decorate non query plans; got %v", got)
+	}
+}
@@ -0,0 +1,383 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"bytes"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// constructBindings contains the bindings of the table returned by construct
+// plans; one row per constructed triple.
+var constructBindings = []string{"?s", "?p", "?o"}
+
+// constructPlan encapsulates the sequence of instructions that need to be
+// executed in order to satisfy the execution of a valid construct BQL
+// statement. The graph pattern is resolved as a query would, and each row of
+// the resulting table instantiates the construct templates.
+type constructPlan struct {
+	qp *queryPlan
+}
+
+// blankNodes provides the fresh blank nodes replacing the template blank nodes
+// for a row. The same template blank node is always replaced by the same blank
+// node within a row.
+type blankNodes map[string]*node.Node
+
+// node returns the node to use for the provided template node.
+func (bn blankNodes) node(n *node.Node) *node.Node {
+	if n.Type().String() != "/_" {
+		return n
+	}
+	k := n.String()
+	if _, ok := bn[k]; !ok {
+		bn[k] = node.NewBlankNode()
+	}
+	return bn[k]
+}
+
+// templatePredicate returns the predicate for the provided template values
+// given the row. It returns false if the bindings are not available on the row
+// or their values cannot be used as a predicate.
+func templatePredicate(r table.Row, p *predicate.Predicate, pBinding, pID, pAnchorBinding string) (*predicate.Predicate, bool) {
+	switch {
+	case p != nil:
+		return p, true
+	case pBinding != "":
+		c, ok := r[pBinding]
+		if !ok || c.P == nil {
+			return nil, false
+		}
+		return c.P, true
+	case pID != "" && pAnchorBinding != "":
+		c, ok := r[pAnchorBinding]
+		if !ok {
+			return nil, false
+		}
+		ta := c.T
+		if ta == nil && c.P != nil {
+			ta, _ = c.P.TimeAnchor()
+		}
+		if ta == nil {
+			return nil, false
+		}
+		np, err := predicate.NewTemporal(pID, *ta)
+		if err != nil {
+			return nil, false
+		}
+		return np, true
+	}
+	return nil, false
+}
+
+// templateObject returns the object for the provided template values given
+// the row. A fresh blank node is minted on every call if oNewBlank is set. It
+// returns false if the bindings are not available on the row or their values
+// cannot be used as an object.
+func templateObject(r table.Row, bn blankNodes, o *triple.Object, oBinding, oID, oAnchorBinding string, oNewBlank bool) (*triple.Object, bool) {
+	switch {
+	case oNewBlank:
+		return triple.NewNodeObject(node.NewBlankNode()), true
+	case o != nil:
+		if n, err := o.Node(); err == nil {
+			return triple.NewNodeObject(bn.node(n)), true
+		}
+		return o, true
+	case oBinding != "":
+		c, ok := r[oBinding]
+		if !ok {
+			return nil, false
+		}
+		obj, err := cellToObject(c)
+		if err != nil {
+			return nil, false
+		}
+		return obj, true
+	case oID != "":
+		p, ok := templatePredicate(r, nil, "", oID, oAnchorBinding)
+		if !ok {
+			return nil, false
+		}
+		return triple.NewPredicateObject(p), true
+	}
+	return nil, false
+}
+
+// concatObject returns the text literal object resulting of concatenating the
+// provided arguments given the row. It returns false if any of the bindings is
+// not available on the row, and an error if any of them is not bound to a
+// text.
+func concatObject(r table.Row, args []*semantic.ConcatArgument) (*triple.Object, bool, error) {
+	var buf bytes.Buffer
+	for _, a := range args {
+		if a.Binding == "" {
+			buf.WriteString(a.Text)
+			continue
+		}
+		c, ok := r[a.Binding]
+		if !ok || c == nil {
+			return nil, false, nil
+		}
+		if c.S != nil {
+			buf.WriteString(*c.S)
+			continue
+		}
+		if c.L == nil {
+			return nil, false, fmt.Errorf("concat requires binding %q to be bound to a text; found %s instead", a.Binding, c)
+		}
+		t, err := c.L.Text()
+		if err != nil {
+			return nil, false, fmt.Errorf("concat requires binding %q to be bound to a text; found %s instead", a.Binding, c)
+		}
+		buf.WriteString(t)
+	}
+	l, err := literal.DefaultBuilder().Build(literal.Text, buf.String())
+	if err != nil {
+		return nil, false, err
+	}
+	return triple.NewLiteralObject(l), true, nil
+}
+
+// constructTriples instantiates the construct clause for the provided row.
+// Every NEW_BLANK() in the clause mints a fresh blank node, and CONCAT objects
+// are built out of the bound texts. It returns no triples if any of the
+// bindings of the clause is not available on the row. Reified triples get a
+// fresh blank node that becomes the subject of the reification clauses;
+// reification clauses whose bindings are not available on the row are
+// dropped.
+func constructTriples(cc *semantic.ConstructClause, r table.Row, bn blankNodes) ([]*triple.Triple, error) {
+	var s *node.Node
+	switch {
+	case cc.S != nil:
+		s = bn.node(cc.S)
+	case cc.SBinding != "":
+		c, ok := r[cc.SBinding]
+		if !ok || c.N == nil {
+			return nil, nil
+		}
+		s = c.N
+	case cc.SNewBlank:
+		s = node.NewBlankNode()
+	default:
+		return nil, nil
+	}
+	p, ok := templatePredicate(r, cc.P, cc.PBinding, cc.PID, cc.PAnchorBinding)
+	if !ok {
+		return nil, nil
+	}
+	var o *triple.Object
+	if len(cc.OConcat) > 0 {
+		co, ok, err := concatObject(r, cc.OConcat)
+		if err != nil || !ok {
+			return nil, err
+		}
+		o = co
+	} else {
+		o, ok = templateObject(r, bn, cc.O, cc.OBinding, cc.OID, cc.OAnchorBinding, cc.ONewBlank)
+		if !ok {
+			return nil, nil
+		}
+	}
+	t, err := triple.New(s, p, o)
+	if err != nil {
+		return nil, err
+	}
+	rcs := cc.ReificationClauses()
+	if len(rcs) == 0 {
+		return []*triple.Triple{t}, nil
+	}
+	ts, b, err := t.Reify()
+	if err != nil {
+		return nil, err
+	}
+	for _, rc := range rcs {
+		rp, ok := templatePredicate(r, rc.P, rc.PBinding, rc.PID, rc.PAnchorBinding)
+		if !ok {
+			continue
+		}
+		ro, ok := templateObject(r, bn, rc.O, rc.OBinding, rc.OID, rc.OAnchorBinding, rc.ONewBlank)
+		if !ok {
+			continue
+		}
+		rt, err := triple.New(b, rp, ro)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, rt)
+	}
+	return ts, nil
+}
+
+// resolvePattern resolves the graph pattern of the statement and filters the
+// resulting rows with its having clause, leaving them on the plan table.
+func (p *queryPlan) resolvePattern(ctx context.Context) error {
+	t, err := table.New([]string{})
+	if err != nil {
+		return err
+	}
+	p.tbl = t
+	trace(p.tracer, func() []string {
+		return []string{fmt.Sprintf("Caching graph instances for graphs %v", p.stm.GraphNames())}
+	})
+	if err := p.stm.Init(ctx, p.store); err != nil {
+		return err
+	}
+	p.grfs = p.stm.Graphs()
+	lo := p.stm.GlobalLookupOptions()
+	if p.stm.UnionBranches() > 0 {
+		if err := p.processUnion(ctx, lo); err != nil {
+			return err
+		}
+	} else {
+		if err := p.processGraphPattern(ctx, lo); err != nil {
+			return err
+		}
+		if err := p.filterNegatedClauses(ctx, lo); err != nil {
+			return err
+		}
+	}
+	return p.having()
+}
+
+// constructBatchSize contains the maximum number of constructed triples
+// handed at once to the consumer of a construct plan.
+const constructBatchSize = 1000
+
+// construct resolves the graph pattern and instantiates the construct
+// templates for each resulting row. Constructed triples are handed to emit in
+// batches of at most constructBatchSize triples as they are built, hence they
+// are never all kept in memory.
+func (p *constructPlan) construct(ctx context.Context, emit func([]*triple.Triple) error) error {
+	qp := p.qp
+	if err := qp.resolvePattern(ctx); err != nil {
+		return err
+	}
+	trace(qp.tracer, func() []string {
+		return []string{fmt.Sprintf("Constructing triples for %d rows", qp.tbl.NumRows())}
+	})
+	var ts []*triple.Triple
+	for _, r := range qp.tbl.Rows() {
+		bn := make(blankNodes)
+		for _, cc := range qp.stm.ConstructClauses() {
+			cts, err := constructTriples(cc, r, bn)
+			if err != nil {
+				return err
+			}
+			ts = append(ts, cts...)
+		}
+		if len(ts) >= constructBatchSize {
+			if err := emit(ts); err != nil {
+				return err
+			}
+			ts = nil
+		}
+	}
+	if len(ts) > 0 {
+		return emit(ts)
+	}
+	return nil
+}
+
+// outputGraphs returns the graphs where the constructed triples get stored.
+func (p *constructPlan) outputGraphs(ctx context.Context) ([]storage.Graph, error) {
+	var gs []storage.Graph
+	for _, gn := range p.qp.stm.OutputGraphNames() {
+		g, err := p.qp.store.Graph(ctx, gn)
+		if err != nil {
+			return nil, fmt.Errorf("cannot store the constructed triples into graph %q; %v", gn, err)
+		}
+		gs = append(gs, g)
+	}
+	return gs, nil
+}
+
+// addTriples adds the triples to the provided graphs.
+func (p *constructPlan) addTriples(ctx context.Context, gs []storage.Graph, ts []*triple.Triple) error {
+	for _, g := range gs {
+		trace(p.qp.tracer, func() []string {
+			return []string{fmt.Sprintf("Inserting %d triples to graph %q", len(ts), g.ID(ctx))}
+		})
+		if err := g.AddTriples(ctx, ts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Execute resolves the graph pattern and instantiates the construct templates
+// for each resulting row. The constructed triples are added to the output
+// graphs if any were provided, and returned as a table with one row per
+// triple.
+func (p *constructPlan) Execute(ctx context.Context) (*table.Table, error) {
+	gs, err := p.outputGraphs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := table.New(constructBindings)
+	if err != nil {
+		return nil, err
+	}
+	err = p.construct(ctx, func(ts []*triple.Triple) error {
+		if err := p.addTriples(ctx, gs, ts); err != nil {
+			return err
+		}
+		for _, t := range ts {
+			oc, err := objectToCell(t.Object())
+			if err != nil {
+				return err
+			}
+			res.AddRow(table.Row{
+				"?s": &table.Cell{N: t.Subject()},
+				"?p": &table.Cell{P: t.Predicate()},
+				"?o": oc,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// String returns a readable description of the execution plan.
+func (p *constructPlan) String() string {
+	qp := p.qp
+	b := bytes.NewBufferString("CONSTRUCT plan:\n\n")
+	b.WriteString(fmt.Sprintf("using store(%q) graphs %v\n", qp.store.Name(nil), qp.grfsNames))
+	for _, cls := range qp.cls {
+		b.WriteString("\t")
+		b.WriteString(cls.String())
+		b.WriteString("\n")
+	}
+	if qp.stm.HasHavingClause() {
+		b.WriteString("having filtering\n")
+	}
+	b.WriteString(fmt.Sprintf("instantiate %d construct clauses for each row\n", len(qp.stm.ConstructClauses())))
+	for _, g := range qp.stm.OutputGraphNames() {
+		b.WriteString(fmt.Sprintf("store(%q).Graph(%q).AddTriples(_, constructed)\n", qp.store.Name(nil), g))
+	}
+	return b.String()
+}
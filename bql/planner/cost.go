@@ -0,0 +1,132 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/storage"
+)
+
+// clauseCardinality returns the estimated number of triples matching the fixed
+// parts of the provided clause across all the provided graphs. Graphs able to
+// count triples count the ones within the time bounds of the clause if it has
+// any. It returns false if any of the graphs is not able to estimate its
+// cardinality.
+func clauseCardinality(ctx context.Context, gs []storage.Graph, cls *semantic.GraphClause) (int, bool, error) {
+	if len(cls.PAlternatives) > 0 {
+		// Clauses with predicate alternatives match the triples of all of them.
+		total := 0
+		for _, alt := range expandAlternatives(cls) {
+			n, ok, err := clauseCardinality(ctx, gs, alt)
+			if err != nil || !ok {
+				return 0, ok, err
+			}
+			total += n
+		}
+		return total, true, nil
+	}
+	lookup := &storage.CardinalityLookup{
+		S: cls.S,
+		P: cls.P,
+		O: cls.O,
+	}
+	if cls.P == nil && !cls.PIDPrefix {
+		lookup.PID = cls.PID
+	}
+	lo := updateTimeBounds(storage.DefaultLookup, cls)
+	bounded := lo.LowerAnchor != nil || lo.UpperAnchor != nil
+	total := 0
+	for _, g := range gs {
+		if tc, ok := g.(storage.TripleCounter); ok && bounded {
+			n, err := tc.Count(ctx, lookup, lo)
+			if err != nil {
+				return 0, false, err
+			}
+			total += n
+			continue
+		}
+		ce, ok := g.(storage.CardinalityEstimator)
+		if !ok {
+			return 0, false, nil
+		}
+		n, err := ce.Cardinality(ctx, lookup)
+		if err != nil {
+			return 0, false, err
+		}
+		total += n
+	}
+	return total, true, nil
+}
+
+// orderByCardinality returns the provided clauses ordered to keep the
+// intermediate tables small. Each UNION branch is ordered independently. The
+// next clause of a branch is the one with the smallest estimated cardinality
+// among the ones sharing bindings with the clauses already picked, or among
+// all the remaining clauses if none shares bindings. Ties keep the provided
+// order. It returns false if the cardinality of any clause cannot be
+// estimated, in which case the provided order should be used.
+func orderByCardinality(ctx context.Context, gs []storage.Graph, cls []*semantic.GraphClause) ([]*semantic.GraphClause, bool, error) {
+	if len(gs) == 0 {
+		return cls, false, nil
+	}
+	cards := make(map[*semantic.GraphClause]int, len(cls))
+	var branches []int
+	byBranch := make(map[int][]*semantic.GraphClause)
+	for _, c := range cls {
+		n, ok, err := clauseCardinality(ctx, gs, c)
+		if err != nil || !ok {
+			return cls, false, err
+		}
+		cards[c] = n
+		if _, ok := byBranch[c.Branch]; !ok {
+			branches = append(branches, c.Branch)
+		}
+		byBranch[c.Branch] = append(byBranch[c.Branch], c)
+	}
+	res := make([]*semantic.GraphClause, 0, len(cls))
+	for _, b := range branches {
+		pending := byBranch[b]
+		bound := make(map[string]bool)
+		for len(pending) > 0 {
+			best, bestConnected := -1, false
+			for i, c := range pending {
+				connected := false
+				for _, bn := range c.Bindings() {
+					if bound[bn] {
+						connected = true
+						break
+					}
+				}
+				switch {
+				case best < 0:
+				case connected && !bestConnected:
+				case connected == bestConnected && cards[c] < cards[pending[best]]:
+				default:
+					continue
+				}
+				best, bestConnected = i, connected
+			}
+			c := pending[best]
+			res = append(res, c)
+			for _, bn := range c.Bindings() {
+				bound[bn] = true
+			}
+			pending = append(pending[:best], pending[best+1:]...)
+		}
+	}
+	return res, true, nil
+}
@@ -0,0 +1,316 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/bql/grammar"
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// scanningStore wraps the graphs of a store to count the values they return.
+// The wrapped graphs only expose the cardinality estimates of the store graphs
+// if estimate is true.
+type scanningStore struct {
+	storage.Store
+	rows     *int64
+	estimate bool
+}
+
+// Graph returns the wrapped graph.
+func (s *scanningStore) Graph(ctx context.Context, id string) (storage.Graph, error) {
+	g, err := s.Store.Graph(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	sg := &scanningGraph{Graph: g, rows: s.rows}
+	if s.estimate {
+		return &estimatingGraph{sg}, nil
+	}
+	return sg, nil
+}
+
+// scanningGraph counts the values returned by the lookups of a graph.
+type scanningGraph struct {
+	storage.Graph
+	rows *int64
+}
+
+// estimatingGraph exposes the cardinality estimates of the wrapped graph.
+type estimatingGraph struct {
+	*scanningGraph
+}
+
+// Cardinality forwards the lookup to the wrapped graph.
+func (g *estimatingGraph) Cardinality(ctx context.Context, lookup *storage.CardinalityLookup) (int, error) {
+	return g.Graph.(storage.CardinalityEstimator).Cardinality(ctx, lookup)
+}
+
+// forwardTriples counts and forwards the triples returned by the provided
+// lookup.
+func (g *scanningGraph) forwardTriples(lookup func(chan<- *triple.Triple) error, trpls chan<- *triple.Triple) error {
+	c, errc := make(chan *triple.Triple), make(chan error, 1)
+	go func() {
+		errc <- lookup(c)
+	}()
+	for t := range c {
+		atomic.AddInt64(g.rows, 1)
+		trpls <- t
+	}
+	close(trpls)
+	return <-errc
+}
+
+// Objects counts and forwards the objects returned by the wrapped graph.
+func (g *scanningGraph) Objects(ctx context.Context, s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions, objs chan<- *triple.Object) error {
+	c, errc := make(chan *triple.Object), make(chan error, 1)
+	go func() {
+		errc <- g.Graph.Objects(ctx, s, p, lo, c)
+	}()
+	for o := range c {
+		atomic.AddInt64(g.rows, 1)
+		objs <- o
+	}
+	close(objs)
+	return <-errc
+}
+
+// Subjects counts and forwards the subjects returned by the wrapped graph.
+func (g *scanningGraph) Subjects(ctx context.Context, p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions, subjs chan<- *node.Node) error {
+	c, errc := make(chan *node.Node), make(chan error, 1)
+	go func() {
+		errc <- g.Graph.Subjects(ctx, p, o, lo, c)
+	}()
+	for s := range c {
+		atomic.AddInt64(g.rows, 1)
+		subjs <- s
+	}
+	close(subjs)
+	return <-errc
+}
+
+// TriplesForSubject counts and forwards the triples returned by the wrapped
+// graph.
+func (g *scanningGraph) TriplesForSubject(ctx context.Context, s *node.Node, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	return g.forwardTriples(func(c chan<- *triple.Triple) error {
+		return g.Graph.TriplesForSubject(ctx, s, lo, c)
+	}, trpls)
+}
+
+// TriplesForPredicate counts and forwards the triples returned by the wrapped
+// graph.
+func (g *scanningGraph) TriplesForPredicate(ctx context.Context, p *predicate.Predicate, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	return g.forwardTriples(func(c chan<- *triple.Triple) error {
+		return g.Graph.TriplesForPredicate(ctx, p, lo, c)
+	}, trpls)
+}
+
+// TriplesForObject counts and forwards the triples returned by the wrapped
+// graph.
+func (g *scanningGraph) TriplesForObject(ctx context.Context, o *triple.Object, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	return g.forwardTriples(func(c chan<- *triple.Triple) error {
+		return g.Graph.TriplesForObject(ctx, o, lo, c)
+	}, trpls)
+}
+
+// TriplesForSubjectAndPredicate counts and forwards the triples returned by
+// the wrapped graph.
+func (g *scanningGraph) TriplesForSubjectAndPredicate(ctx context.Context, s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	return g.forwardTriples(func(c chan<- *triple.Triple) error {
+		return g.Graph.TriplesForSubjectAndPredicate(ctx, s, p, lo, c)
+	}, trpls)
+}
+
+// TriplesForPredicateAndObject counts and forwards the triples returned by
+// the wrapped graph.
+func (g *scanningGraph) TriplesForPredicateAndObject(ctx context.Context, p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	return g.forwardTriples(func(c chan<- *triple.Triple) error {
+		return g.Graph.TriplesForPredicateAndObject(ctx, p, o, lo, c)
+	}, trpls)
+}
+
+// Triples counts and forwards the triples returned by the wrapped graph.
+func (g *scanningGraph) Triples(ctx context.Context, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	return g.forwardTriples(func(c chan<- *triple.Triple) error {
+		return g.Graph.Triples(ctx, lo, c)
+	}, trpls)
+}
+
+// chainQuery follows the users of the hub and then the documents they wrote.
+// Only a few users wrote documents, hence resolving the second clause first
+// keeps the intermediate tables small.
+const chainQuery = `SELECT ?u, ?d FROM ?test WHERE {/u<hub> "follows"@[] ?u . ?u "wrote"@[] ?d} ORDER BY ?u;`
+
+// newHubStore returns a store where the hub follows the provided number of
+// users and only the first writers of them wrote a document.
+func newHubStore(tb testing.TB, users, writers int) storage.Store {
+	var trpls bytes.Buffer
+	for i := 0; i < users; i++ {
+		trpls.WriteString(fmt.Sprintf("/u<hub>\t\"follows\"@[]\t/u<user%d>\n", i))
+		if i < writers {
+			trpls.WriteString(fmt.Sprintf("/u<user%d>\t\"wrote\"@[]\t/doc<doc%d>\n", i, i))
+		}
+	}
+	return populateTestStoreWith(tb, trpls.String())
+}
+
+// runChainQuery runs the chaining query against the provided store and returns
+// the resulting plan and rows.
+func runChainQuery(tb testing.TB, s storage.Store) (*queryPlan, []string) {
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	st, ctx := &semantic.Statement{}, context.Background()
+	if err := p.Parse(grammar.NewLLk(chainQuery, 1), st); err != nil {
+		tb.Fatalf("Parser.consume: failed to parse query %q with error %v", chainQuery, err)
+	}
+	plnr, err := New(ctx, s, st, 0, nil)
+	if err != nil {
+		tb.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+	}
+	tbl, err := plnr.Execute(ctx)
+	if err != nil {
+		tb.Fatalf("planner.Execute failed for query %q with error %v", chainQuery, err)
+	}
+	var rows []string
+	for _, r := range tbl.Rows() {
+		rows = append(rows, r["?u"].String()+" "+r["?d"].String())
+	}
+	return plnr.(*queryPlan), rows
+}
+
+func TestOrderByCardinality(t *testing.T) {
+	s := newHubStore(t, 100, 3)
+	want := []string{"/u<user0> /doc<doc0>", "/u<user1> /doc<doc1>", "/u<user2> /doc<doc2>"}
+	for _, estimate := range []bool{true, false} {
+		var rows int64
+		p, got := runChainQuery(t, &scanningStore{Store: s, rows: &rows, estimate: estimate})
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("planner.Execute(estimate=%v) returned rows %v; want %v", estimate, got, want)
+		}
+		first, wantFirst := string(p.cls[0].P.ID()), "wrote"
+		if !estimate {
+			// Without estimates the clauses keep the specificity order.
+			wantFirst = "follows"
+		}
+		if first != wantFirst {
+			t.Errorf("planner.Execute(estimate=%v) resolved first the %q clause; want %q", estimate, first, wantFirst)
+		}
+	}
+}
+
+func TestOrderByCardinalityPrefersConnectedClauses(t *testing.T) {
+	s, ctx := newHubStore(t, 10, 5), context.Background()
+	g, err := s.Graph(ctx, "?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	follows, err := predicate.NewImmutable("follows")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrote, err := predicate.NewImmutable("wrote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub, err := node.Parse("/u<hub>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &semantic.GraphClause{P: wrote, SBinding: "?u", OBinding: "?d"}
+	b := &semantic.GraphClause{S: hub, P: follows, OBinding: "?f"}
+	c := &semantic.GraphClause{SBinding: "?d", P: follows, OBinding: "?x"}
+	got, ok, err := orderByCardinality(ctx, []storage.Graph{g}, []*semantic.GraphClause{b, c, a})
+	if err != nil || !ok {
+		t.Fatalf("orderByCardinality failed to order the clauses; got %v, %v", ok, err)
+	}
+	// The clause on the followers of the documents shares ?d with the first
+	// one, hence it is picked before the hub one despite having the same
+	// cardinality.
+	if want := []*semantic.GraphClause{a, c, b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("orderByCardinality returned %v; want %v", got, want)
+	}
+}
+
+func TestClauseCardinalityHonorsTimeBounds(t *testing.T) {
+	s, ctx := populateTestStore(t), context.Background()
+	g, err := s.Graph(ctx, "?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc := time.FixedZone("", -8*60*60)
+	lower, upper := time.Date(2016, 1, 1, 0, 0, 0, 0, loc), time.Date(2016, 2, 1, 0, 0, 0, 0, loc)
+	cls := &semantic.GraphClause{SBinding: "?s", PID: "bought", PTemporal: true, OBinding: "?o"}
+	for _, entry := range []struct {
+		lower, upper *time.Time
+		want         int
+	}{
+		{want: 4},
+		{lower: &lower, upper: &upper, want: 2},
+		{lower: &upper, want: 3},
+	} {
+		cls.PLowerBound, cls.PUpperBound = entry.lower, entry.upper
+		got, ok, err := clauseCardinality(ctx, []storage.Graph{g}, cls)
+		if err != nil || !ok {
+			t.Fatalf("clauseCardinality failed to estimate clause %v; got %v, %v", cls, ok, err)
+		}
+		if got != entry.want {
+			t.Errorf("clauseCardinality(%v) returned %d; want %d", cls, got, entry.want)
+		}
+	}
+	q := `SELECT ?s, ?o FROM ?test WHERE {?s "bought"@[2016-01-01T00:00:00-08:00,2016-02-01T00:00:00-08:00] ?o};`
+	rows := mustRunQuery(t, s, q).NumRows()
+	cnt, err := storage.CountTriples(ctx, g, &storage.CardinalityLookup{PID: "bought"}, &storage.LookupOptions{LowerAnchor: &lower, UpperAnchor: &upper})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cnt != rows {
+		t.Errorf("storage.CountTriples returned %d triples; want the %d rows returned by query %q", cnt, rows, q)
+	}
+}
+
+func BenchmarkChainQueryByCardinality(b *testing.B) {
+	benchmarkChainQuery(b, true)
+}
+
+func BenchmarkChainQueryBySpecificity(b *testing.B) {
+	benchmarkChainQuery(b, false)
+}
+
+// benchmarkChainQuery runs the chaining query and reports the number of values
+// returned by the graph lookups.
+func benchmarkChainQuery(b *testing.B, estimate bool) {
+	s := newHubStore(b, 1000, 5)
+	var rows int64
+	ss := &scanningStore{Store: s, rows: &rows, estimate: estimate}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		runChainQuery(b, ss)
+	}
+	b.ReportMetric(float64(atomic.LoadInt64(&rows))/float64(b.N), "rows/op")
+}
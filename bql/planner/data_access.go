@@ -17,7 +17,9 @@ package planner
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/net/context"
 
@@ -25,6 +27,7 @@ import (
 	"github.com/google/badwolf/bql/table"
 	"github.com/google/badwolf/storage"
 	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
 	"github.com/google/badwolf/triple/node"
 	"github.com/google/badwolf/triple/predicate"
 )
@@ -54,21 +57,19 @@ func updateTimeBounds(lo *storage.LookupOptions, cls *semantic.GraphClause) *sto
 // the provided graph clause.
 func updateTimeBoundsForRow(lo *storage.LookupOptions, cls *semantic.GraphClause, r table.Row) (*storage.LookupOptions, error) {
 	lo = updateTimeBounds(lo, cls)
-	if cls.PLowerBoundAlias != "" {
-		v, ok := r[cls.PLowerBoundAlias]
-		if ok && v.T == nil {
+	if v, ok := r[cls.PLowerBoundAlias]; ok && cls.PLowerBoundAlias != "" {
+		if v.T == nil {
 			return nil, fmt.Errorf("invalid time anchor value %v for bound %s", v, cls.PLowerBoundAlias)
 		}
-		if lo.LowerAnchor == nil || (lo.LowerAnchor != nil && v.T.After(*lo.LowerAnchor)) {
+		if lo.LowerAnchor == nil || v.T.After(*lo.LowerAnchor) {
 			lo.LowerAnchor = v.T
 		}
 	}
-	if cls.PUpperBoundAlias != "" {
-		v, ok := r[cls.PUpperBoundAlias]
-		if ok && v.T == nil {
+	if v, ok := r[cls.PUpperBoundAlias]; ok && cls.PUpperBoundAlias != "" {
+		if v.T == nil {
 			return nil, fmt.Errorf("invalid time anchor value %v for bound %s", v, cls.PUpperBoundAlias)
 		}
-		if lo.UpperAnchor == nil || (lo.UpperAnchor != nil && v.T.After(*lo.UpperAnchor)) {
+		if lo.UpperAnchor == nil || v.T.Before(*lo.UpperAnchor) {
 			lo.UpperAnchor = v.T
 		}
 	}
@@ -76,9 +77,20 @@ func updateTimeBoundsForRow(lo *storage.LookupOptions, cls *semantic.GraphClause
 	return nlo, nil
 }
 
+// uniqueTriples returns the set used to only add once the triples present on
+// several of the provided graphs. It returns nil if there is a single graph or
+// multiset semantics were requested, hence no triple is ever dropped.
+func uniqueTriples(gs []storage.Graph, multiset bool) map[string]bool {
+	if len(gs) < 2 || multiset {
+		return nil
+	}
+	return make(map[string]bool)
+}
+
 // simpleExist returns true if the triple exist. Return the unfeasible state,
-// the table and the error if present.
-func simpleExist(ctx context.Context, gs []storage.Graph, cls *semantic.GraphClause, t *triple.Triple) (bool, *table.Table, error) {
+// the table and the error if present. If seen is not nil, the triple is only
+// added to the table if it is not already in it.
+func simpleExist(ctx context.Context, gs []storage.Graph, cls *semantic.GraphClause, t *triple.Triple, seen map[string]bool) (bool, *table.Table, error) {
 	unfeasible := true
 	tbl, err := table.New(cls.Bindings())
 	if err != nil {
@@ -94,7 +106,7 @@ func simpleExist(ctx context.Context, gs []storage.Graph, cls *semantic.GraphCla
 			ts := make(chan *triple.Triple, 1)
 			ts <- t
 			close(ts)
-			if err := addTriples(ts, cls, tbl); err != nil {
+			if err := addTriples(ts, cls, g.ID(ctx), tbl, seen); err != nil {
 				return true, nil, err
 			}
 		}
@@ -104,14 +116,43 @@ func simpleExist(ctx context.Context, gs []storage.Graph, cls *semantic.GraphCla
 
 // simpleFetch returns a table containing the data specified by the graph
 // clause by querying the provided stora. Will return an error if it had poblems
-// retrieveing the data.
-func simpleFetch(ctx context.Context, gs []storage.Graph, cls *semantic.GraphClause, lo *storage.LookupOptions, stmLimit int64, chanSize int) (*table.Table, error) {
+// retrieveing the data. The triples of all graphs are unioned; if seen is not
+// nil, triples already in it are not added again.
+func simpleFetch(ctx context.Context, gs []storage.Graph, cls *semantic.GraphClause, lo *storage.LookupOptions, stmLimit int64, chanSize int, seen map[string]bool) (*table.Table, error) {
+	if cls.PPath != semantic.SingleStep {
+		if cls.GBinding != "" {
+			return graphPathFetch(ctx, gs, cls, lo, chanSize)
+		}
+		return pathFetch(ctx, gs, cls, lo, chanSize)
+	}
 	s, p, o := cls.S, cls.P, cls.O
+	if _, ok := foldedObject(cls); ok {
+		// Text literals matching regardless of case cannot be looked up, hence
+		// the triples are retrieved without the object and filtered instead.
+		o, stmLimit = nil, 0
+	}
+	if cls.PID != "" {
+		// Triples are filtered by the ID of their predicate once retrieved,
+		// hence the limit cannot be pushed down.
+		stmLimit = 0
+	}
 	lo = updateTimeBounds(lo, cls)
 	tbl, err := table.New(cls.Bindings())
 	if err != nil {
 		return nil, err
 	}
+	if hasNodeTypes(cls) {
+		// Bound nodes not satisfying the node type constraints cannot match.
+		if s != nil && cls.SNodeType != nil && !s.Type().Covariant(cls.SNodeType) {
+			return tbl, nil
+		}
+		if o != nil && cls.ONodeType != nil {
+			if on, err := o.Node(); err != nil || !on.Type().Covariant(cls.ONodeType) {
+				return tbl, nil
+			}
+		}
+		stmLimit = 0
+	}
 	if s != nil && p != nil && o != nil {
 		// Fully qualified triple.
 		t, err := triple.New(s, p, o)
@@ -127,7 +168,7 @@ func simpleFetch(ctx context.Context, gs []storage.Graph, cls *semantic.GraphCla
 				ts := make(chan *triple.Triple, 1)
 				ts <- t
 				close(ts)
-				if err := addTriples(ts, cls, tbl); err != nil {
+				if err := addTriples(ts, cls, g.ID(ctx), tbl, seen); err != nil {
 					return nil, err
 				}
 			}
@@ -152,7 +193,7 @@ func simpleFetch(ctx context.Context, gs []storage.Graph, cls *semantic.GraphCla
 			ts := make(chan *triple.Triple, chanSize)
 			go func() {
 				defer wg.Done()
-				aErr = addTriples(ts, cls, tbl)
+				aErr = addTriples(ts, cls, g.ID(ctx), tbl, seen)
 			}()
 			for o := range os {
 				if lErr != nil {
@@ -198,7 +239,7 @@ func simpleFetch(ctx context.Context, gs []storage.Graph, cls *semantic.GraphCla
 			ts := make(chan *triple.Triple, chanSize)
 			go func() {
 				defer wg.Done()
-				aErr = addTriples(ts, cls, tbl)
+				aErr = addTriples(ts, cls, g.ID(ctx), tbl, seen)
 			}()
 			for p := range ps {
 				if lErr != nil {
@@ -244,7 +285,7 @@ func simpleFetch(ctx context.Context, gs []storage.Graph, cls *semantic.GraphCla
 			ts := make(chan *triple.Triple, chanSize)
 			go func() {
 				defer wg.Done()
-				aErr = addTriples(ts, cls, tbl)
+				aErr = addTriples(ts, cls, g.ID(ctx), tbl, seen)
 			}()
 			for s := range ss {
 				if lErr != nil {
@@ -286,7 +327,7 @@ func simpleFetch(ctx context.Context, gs []storage.Graph, cls *semantic.GraphCla
 				defer wg.Done()
 				tErr = g.TriplesForSubject(ctx, s, lo, ts)
 			}()
-			aErr = addTriples(ts, cls, tbl)
+			aErr = addTriples(ts, cls, g.ID(ctx), tbl, seen)
 			wg.Wait()
 			if tErr != nil {
 				return nil, tErr
@@ -311,7 +352,7 @@ func simpleFetch(ctx context.Context, gs []storage.Graph, cls *semantic.GraphCla
 				defer wg.Done()
 				tErr = g.TriplesForPredicate(ctx, p, lo, ts)
 			}()
-			aErr = addTriples(ts, cls, tbl)
+			aErr = addTriples(ts, cls, g.ID(ctx), tbl, seen)
 			wg.Wait()
 			if tErr != nil {
 				return nil, tErr
@@ -335,7 +376,7 @@ func simpleFetch(ctx context.Context, gs []storage.Graph, cls *semantic.GraphCla
 				defer wg.Done()
 				tErr = g.TriplesForObject(ctx, o, lo, ts)
 			}()
-			aErr := addTriples(ts, cls, tbl)
+			aErr := addTriples(ts, cls, g.ID(ctx), tbl, seen)
 			wg.Wait()
 			if tErr != nil {
 				return nil, tErr
@@ -365,7 +406,7 @@ func simpleFetch(ctx context.Context, gs []storage.Graph, cls *semantic.GraphCla
 				}
 				tErr = g.Triples(ctx, &nlo, ts)
 			}()
-			aErr = addTriples(ts, cls, tbl)
+			aErr = addTriples(ts, cls, g.ID(ctx), tbl, seen)
 			wg.Wait()
 			if tErr != nil {
 				return nil, tErr
@@ -380,14 +421,58 @@ func simpleFetch(ctx context.Context, gs []storage.Graph, cls *semantic.GraphCla
 	return nil, fmt.Errorf("planner.simpleFetch could not recognize request in clause %v", cls)
 }
 
+// matchesPredicateID returns true if the ID of the predicate matches the
+// predicate ID of the graph clause. Clauses matching predicate ID prefixes
+// without a time anchor only match immutable predicates.
+func matchesPredicateID(cls *semantic.GraphClause, p *predicate.Predicate) bool {
+	if !cls.PIDPrefix {
+		return string(p.ID()) == cls.PID
+	}
+	if !cls.PTemporal && p.Type() != predicate.Immutable {
+		return false
+	}
+	return strings.HasPrefix(string(p.ID()), cls.PID)
+}
+
 // addTriples add all the retrieved triples from the graphs into the results
 // table. The semantic graph clause is also passed to be able to identify what
-// bindings to set.
-func addTriples(ts <-chan *triple.Triple, cls *semantic.GraphClause, tbl *table.Table) error {
+// bindings to set, and the ID of the graph the triples were read from is bound
+// to its graph binding, if any. If seen is not nil, triples already in it are
+// skipped and the added ones are recorded. The channel is always drained, even
+// on error, to avoid blocking the producer.
+func addTriples(ts <-chan *triple.Triple, cls *semantic.GraphClause, gID string, tbl *table.Table, seen map[string]bool) error {
+	defer func() {
+		for range ts {
+		}
+	}()
+	var gc *table.Cell
+	if cls.GBinding != "" {
+		c, err := graphNameCell(gID)
+		if err != nil {
+			return err
+		}
+		gc = c
+	}
+	fl, fold := foldedObject(cls)
 	for t := range ts {
+		if !matchesNodeTypes(cls, t) {
+			continue
+		}
+		if fold {
+			if l, err := t.Object().Literal(); err != nil || !fl.EqualFold(l) {
+				continue
+			}
+		}
+		if seen != nil {
+			k := string(t.UUID())
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
 		if cls.PID != "" {
 			// The triples need to be filtered.
-			if string(t.Predicate().ID()) != cls.PID {
+			if !matchesPredicateID(cls, t.Predicate()) {
 				continue
 			}
 			if cls.PTemporal {
@@ -408,26 +493,29 @@ func addTriples(ts <-chan *triple.Triple, cls *semantic.GraphClause, tbl *table.
 			}
 		}
 		if cls.OID != "" {
-			if p, err := t.Object().Predicate(); err == nil {
-				// The triples need to be filtered.
-				if string(p.ID()) != cls.OID {
+			// Objects other than predicates cannot match a predicate bound.
+			p, err := t.Object().Predicate()
+			if err != nil {
+				continue
+			}
+			// The triples need to be filtered.
+			if string(p.ID()) != cls.OID {
+				continue
+			}
+			if cls.OTemporal {
+				if p.Type() != predicate.Temporal {
 					continue
 				}
-				if cls.OTemporal {
-					if p.Type() != predicate.Temporal {
-						continue
-					}
-					ta, err := p.TimeAnchor()
-					if err != nil {
-						return fmt.Errorf("failed to retrieve time anchor from time predicate in triple %s with error %v", t, err)
-					}
-					// Need to check the bounds of the triple.
-					if cls.OLowerBound != nil && cls.OLowerBound.After(*ta) {
-						continue
-					}
-					if cls.OUpperBound != nil && cls.OUpperBound.Before(*ta) {
-						continue
-					}
+				ta, err := p.TimeAnchor()
+				if err != nil {
+					return fmt.Errorf("failed to retrieve time anchor from time predicate in triple %s with error %v", t, err)
+				}
+				// Need to check the bounds of the triple.
+				if cls.OLowerBound != nil && cls.OLowerBound.After(*ta) {
+					continue
+				}
+				if cls.OUpperBound != nil && cls.OUpperBound.Before(*ta) {
+					continue
 				}
 			}
 		}
@@ -436,12 +524,83 @@ func addTriples(ts <-chan *triple.Triple, cls *semantic.GraphClause, tbl *table.
 			return err
 		}
 		if r != nil {
+			if gc != nil {
+				r[cls.GBinding] = gc
+			}
 			tbl.AddRow(r)
 		}
 	}
 	return nil
 }
 
+// graphNameCell returns the cell bound to the graph binding of the clauses
+// matching triples read from the graph with the provided ID.
+func graphNameCell(id string) (*table.Cell, error) {
+	l, err := literal.DefaultBuilder().Build(literal.Text, id)
+	if err != nil {
+		return nil, err
+	}
+	return &table.Cell{L: l}, nil
+}
+
+// graphsForRow returns the graphs the clause needs to be resolved against for
+// the provided row. If the clause binds the graph its triples are read from
+// and the row already provides it, only that graph is returned.
+func graphsForRow(ctx context.Context, gs []storage.Graph, cls *semantic.GraphClause, r table.Row) []storage.Graph {
+	if cls.GBinding == "" {
+		return gs
+	}
+	c, ok := r[cls.GBinding]
+	if !ok || c.L == nil {
+		return gs
+	}
+	id, err := c.L.Text()
+	if err != nil {
+		return gs
+	}
+	var res []storage.Graph
+	for _, g := range gs {
+		if g.ID(ctx) == id {
+			res = append(res, g)
+		}
+	}
+	return res
+}
+
+// hasNodeTypes returns true if the clause constrains the type of the nodes
+// bound to its subject or object.
+func hasNodeTypes(cls *semantic.GraphClause) bool {
+	return cls.SNodeType != nil || cls.ONodeType != nil
+}
+
+// matchesNodeTypes returns true if the subject and the object of the triple
+// satisfy the node type constraints of the clause.
+func matchesNodeTypes(cls *semantic.GraphClause, t *triple.Triple) bool {
+	if cls.SNodeType != nil && !t.Subject().Type().Covariant(cls.SNodeType) {
+		return false
+	}
+	if cls.ONodeType != nil {
+		n, err := t.Object().Node()
+		if err != nil || !n.Type().Covariant(cls.ONodeType) {
+			return false
+		}
+	}
+	return true
+}
+
+// foldedObject returns the text literal object of the clause if it matches
+// the text literals that only differ from it in case.
+func foldedObject(cls *semantic.GraphClause) (*literal.Literal, bool) {
+	if !cls.OIgnoreCase || cls.O == nil {
+		return nil, false
+	}
+	l, err := cls.O.Literal()
+	if err != nil || l.Type() != literal.Text {
+		return nil, false
+	}
+	return l, true
+}
+
 // objectToCell returns a cell containing the data boxed in the object.
 func objectToCell(o *triple.Object) (*table.Cell, error) {
 	c := &table.Cell{}
@@ -460,6 +619,21 @@ func objectToCell(o *triple.Object) (*table.Cell, error) {
 	return nil, fmt.Errorf("unknown object type in object %q", o)
 }
 
+// objectTimeAnchor returns the time anchor of the object if it is a temporal
+// predicate. Objects without a time anchor cannot be bound to it, hence they do
+// not match clauses binding the object anchor.
+func objectTimeAnchor(o *triple.Object) (*time.Time, bool) {
+	p, err := o.Predicate()
+	if err != nil || p.Type() != predicate.Temporal {
+		return nil, false
+	}
+	ta, err := p.TimeAnchor()
+	if err != nil {
+		return nil, false
+	}
+	return ta, true
+}
+
 // tripleToRow converts a triple into a row using the binndings specidfied
 // on the graph clause.
 func tripleToRow(t *triple.Triple, cls *semantic.GraphClause) (table.Row, error) {
@@ -612,13 +786,9 @@ func tripleToRow(t *triple.Triple, cls *semantic.GraphClause) (table.Row, error)
 		}
 	}
 	if cls.OAnchorBinding != "" {
-		p, err := o.Predicate()
-		if err != nil {
-			return nil, err
-		}
-		ts, err := p.TimeAnchor()
-		if err != nil {
-			return nil, err
+		ts, ok := objectTimeAnchor(o)
+		if !ok {
+			return nil, nil
 		}
 		c := &table.Cell{T: ts}
 		r[cls.OAnchorBinding] = c
@@ -627,13 +797,9 @@ func tripleToRow(t *triple.Triple, cls *semantic.GraphClause) (table.Row, error)
 		}
 	}
 	if cls.OAnchorAlias != "" {
-		p, err := o.Predicate()
-		if err != nil {
-			return nil, err
-		}
-		ts, err := p.TimeAnchor()
-		if err != nil {
-			return nil, err
+		ts, ok := objectTimeAnchor(o)
+		if !ok {
+			return nil, nil
 		}
 		c := &table.Cell{T: ts}
 		r[cls.OAnchorAlias] = c
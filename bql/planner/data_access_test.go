@@ -92,7 +92,7 @@ func TestDataAccessSimpleFetch(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	tbl, err := simpleFetch(ctx, []storage.Graph{g}, cls, &storage.LookupOptions{}, 0, 0)
+	tbl, err := simpleFetch(ctx, []storage.Graph{g}, cls, &storage.LookupOptions{}, 0, 0, nil)
 	if err != nil {
 		t.Errorf("simpleFetch failed with errorf %v", err)
 	}
@@ -127,7 +127,7 @@ func TestDataAccessSimpleFetchIssue40(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	tbl, err := simpleFetch(ctx, []storage.Graph{g}, cls, &storage.LookupOptions{}, 0, 0)
+	tbl, err := simpleFetch(ctx, []storage.Graph{g}, cls, &storage.LookupOptions{}, 0, 0, nil)
 	if err != nil {
 		t.Errorf("simpleFetch failed with errorf %v", err)
 	}
@@ -156,7 +156,7 @@ func TestDataAccessFeasibleSimpleExist(t *testing.T) {
 		P: p,
 		O: o,
 	}
-	unfeasible, tbl, err := simpleExist(ctx, []storage.Graph{g}, clsOK, tt[0])
+	unfeasible, tbl, err := simpleExist(ctx, []storage.Graph{g}, clsOK, tt[0], nil)
 	if err != nil {
 		t.Errorf("simpleExist should have not failed with error %v", err)
 	}
@@ -189,7 +189,7 @@ func TestDataAccessUnfeasibleSimpleExist(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	unfeasible, tbl, err := simpleExist(ctx, []storage.Graph{g}, clsNotOK, tplNotOK)
+	unfeasible, tbl, err := simpleExist(ctx, []storage.Graph{g}, clsNotOK, tplNotOK, nil)
 	if err != nil {
 		t.Errorf("simpleExist should have not failed with error %v", err)
 	}
@@ -228,7 +228,7 @@ func TestDataAccessAddTriples(t *testing.T) {
 	}()
 	go func() {
 		defer wg.Done()
-		if err := addTriples(ts, cls, tbl); err != nil {
+		if err := addTriples(ts, cls, "?test", tbl, nil); err != nil {
 			t.Errorf("addTriple failed with errorf %v", err)
 		}
 	}()
@@ -0,0 +1,168 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+)
+
+// checkDeletePattern returns an error if the triples matching the graph
+// pattern of the delete statement cannot be told apart from its rows.
+func checkDeletePattern(stm *semantic.Statement) error {
+	if stm.UnionBranches() > 0 {
+		return errors.New("planner.New: DELETE WHERE does not support UNION graph patterns")
+	}
+	for _, cls := range stm.SortedGraphPatternClauses() {
+		if cls.PPath != semantic.SingleStep {
+			return fmt.Errorf("planner.New: DELETE WHERE does not support predicate paths; found %s", cls)
+		}
+		if cls.PIDPrefix {
+			return fmt.Errorf("planner.New: DELETE WHERE does not support predicate ID prefixes; found %s", cls)
+		}
+		if len(cls.PAlternatives) > 0 {
+			return fmt.Errorf("planner.New: DELETE WHERE does not support predicate alternatives; found %s", cls)
+		}
+		if cls.OID != "" && cls.OAnchorBinding == "" {
+			return fmt.Errorf("planner.New: DELETE WHERE requires binding the anchor of temporal object ranges; found %s", cls)
+		}
+	}
+	return nil
+}
+
+// deleteLookup returns the lookup and the lookup options matching the triples
+// of the graph clause for the provided row. The time bounds of the clause
+// constrain the lookup options, hence bounded clauses never match triples out
+// of their range.
+func deleteLookup(cls *semantic.GraphClause, r table.Row, lo *storage.LookupOptions) (*storage.CardinalityLookup, *storage.LookupOptions, error) {
+	l := &storage.CardinalityLookup{S: cls.S, P: cls.P, O: cls.O}
+	if l.S == nil {
+		c, ok := r[cls.SBinding]
+		if !ok || c.N == nil {
+			return nil, nil, fmt.Errorf("cannot delete the triples matching %s; binding %q is not bound to a node", cls, cls.SBinding)
+		}
+		l.S = c.N
+	}
+	if l.P == nil {
+		p, ok := templatePredicate(r, nil, cls.PBinding, cls.PID, cls.PAnchorBinding)
+		switch {
+		case ok:
+			l.P = p
+		case cls.PBinding != "" || cls.PAnchorBinding != "":
+			return nil, nil, fmt.Errorf("cannot delete the triples matching %s; the predicate is not bound", cls)
+		default:
+			l.PID = cls.PID
+		}
+	}
+	if l.O == nil {
+		switch {
+		case cls.OBinding != "":
+			o, err := cellToObject(r[cls.OBinding])
+			if err != nil {
+				return nil, nil, fmt.Errorf("cannot delete the triples matching %s; %v", cls, err)
+			}
+			l.O = o
+		default:
+			p, ok := templatePredicate(r, nil, "", cls.OID, cls.OAnchorBinding)
+			if !ok {
+				return nil, nil, fmt.Errorf("cannot delete the triples matching %s; the object is not bound", cls)
+			}
+			l.O = triple.NewPredicateObject(p)
+		}
+	}
+	nlo, err := updateTimeBoundsForRow(lo, cls, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, nlo, nil
+}
+
+// removeMatching resolves the graph pattern and removes, for each resulting
+// row, the triples matching each of its clauses from the graphs they were read
+// from. The whole pattern is resolved before any triple is removed.
+func (p *deletePlan) removeMatching(ctx context.Context) error {
+	qp := p.pattern
+	if err := qp.resolvePattern(ctx); err != nil {
+		return err
+	}
+	lo := p.stm.GlobalLookupOptions()
+	cls := p.stm.SortedGraphPatternClauses()
+	rows := qp.tbl.Rows()
+	if len(qp.tbl.Bindings()) == 0 {
+		// Patterns without bindings produce no rows; their triples are removed
+		// if all of them are stored.
+		ok, err := p.allStored(ctx, cls, lo)
+		if err != nil {
+			return err
+		}
+		rows = nil
+		if ok {
+			rows = []table.Row{{}}
+		}
+	}
+	cnt := 0
+	for _, r := range rows {
+		for _, c := range cls {
+			l, clo, err := deleteLookup(c, r, lo)
+			if err != nil {
+				return err
+			}
+			for _, g := range graphsForRow(ctx, qp.grfs, c, r) {
+				n, err := storage.RemoveMatching(ctx, g, l, clo)
+				if err != nil {
+					return err
+				}
+				cnt += n
+			}
+		}
+	}
+	trace(p.tracer, func() []string {
+		return []string{fmt.Sprintf("Removed %d triples matching the graph pattern", cnt)}
+	})
+	return nil
+}
+
+// allStored returns true if every clause matches at least one triple of the
+// graphs of the statement.
+func (p *deletePlan) allStored(ctx context.Context, cls []*semantic.GraphClause, lo *storage.LookupOptions) (bool, error) {
+	for _, c := range cls {
+		l, clo, err := deleteLookup(c, table.Row{}, lo)
+		if err != nil {
+			return false, err
+		}
+		found := false
+		for _, g := range p.pattern.grfs {
+			n, err := storage.CountTriples(ctx, g, l, clo)
+			if err != nil {
+				return false, err
+			}
+			if n > 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
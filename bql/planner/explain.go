@@ -0,0 +1,546 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/storage"
+)
+
+// Explainer is implemented by the executors able to describe the steps they
+// would perform without executing them.
+type Explainer interface {
+	Executor
+
+	// Explain returns a readable description of the steps the plan would
+	// perform without executing it. Clauses are listed in the order they are
+	// resolved along with their specificity and estimated number of rows.
+	Explain(ctx context.Context) (string, error)
+}
+
+// Explain returns a readable description of the steps the executor would
+// perform without executing it. Executors that do not implement Explainer
+// perform no lookups, hence they are described by their String method.
+func Explain(ctx context.Context, e Executor) (string, error) {
+	if ex, ok := e.(Explainer); ok {
+		return ex.Explain(ctx)
+	}
+	return e.String(), nil
+}
+
+// JSONExplainer is implemented by the executors able to describe their plan
+// in a machine readable format.
+type JSONExplainer interface {
+	Executor
+
+	// ExplainJSON returns the plan tree encoded as JSON. The JSON document
+	// follows the structure of PlanExplanation.
+	ExplainJSON() ([]byte, error)
+}
+
+// Clause ordering strategies.
+const (
+	// OrderByCardinality is used when all the graphs estimate the cardinality
+	// of the clauses.
+	OrderByCardinality = "cardinality"
+	// OrderBySpecificity is used otherwise.
+	OrderBySpecificity = "specificity"
+)
+
+// Join types describe how a clause is combined with the rows obtained by the
+// clauses resolved before it.
+const (
+	// JoinExist checks if a fully specified triple exists.
+	JoinExist = "exist"
+	// JoinScan retrieves the data of the first clause of a branch.
+	JoinScan = "scan"
+	// JoinCartesian combines every retrieved row with every existing row since
+	// the clause shares no bindings with the rows.
+	JoinCartesian = "cartesian"
+	// JoinSpecialize specializes the clause for each row with the bound values
+	// and extends the row with the retrieved bindings.
+	JoinSpecialize = "specialize"
+	// JoinExistence drops the rows whose specialized clause does not exist.
+	JoinExistence = "existence"
+	// JoinNegated drops the rows whose specialized negated clause exists.
+	JoinNegated = "negated"
+	// JoinNotExists drops the rows whose specialized NOT EXISTS group
+	// matches.
+	JoinNotExists = "not exists"
+)
+
+// PlanExplanation is the machine readable description of a query plan.
+type PlanExplanation struct {
+	Plan              string                 `json:"plan"`
+	Cached            bool                   `json:"cached,omitempty"`
+	Store             string                 `json:"store"`
+	Graphs            []string               `json:"graphs"`
+	Operation         string                 `json:"operation"`
+	ClauseOrdering    string                 `json:"clause_ordering"`
+	Branches          []*BranchExplanation   `json:"branches"`
+	IgnoreCase        bool                   `json:"ignore_case,omitempty"`
+	Filters           []string               `json:"filters,omitempty"`
+	NegatedClauses    []*ClauseExplanation   `json:"negated_clauses,omitempty"`
+	NotExistsGroups   [][]*ClauseExplanation `json:"not_exists_groups,omitempty"`
+	Projection        []string               `json:"projection,omitempty"`
+	GroupBy           []string               `json:"group_by,omitempty"`
+	OrderBy           string                 `json:"order_by,omitempty"`
+	MergeSortedGraphs bool                   `json:"merge_sorted_graphs,omitempty"`
+	Having            string                 `json:"having,omitempty"`
+	Distinct          bool                   `json:"distinct,omitempty"`
+	Offset            string                 `json:"offset,omitempty"`
+	Limit             string                 `json:"limit,omitempty"`
+	OutputGraphs      []string               `json:"output_graphs,omitempty"`
+	Constructs        int                    `json:"constructs,omitempty"`
+}
+
+// BranchExplanation describes the clauses of a UNION branch in the order they
+// are resolved. Graph patterns that are not a UNION have a single branch 0.
+type BranchExplanation struct {
+	Branch  int                  `json:"branch"`
+	Clauses []*ClauseExplanation `json:"clauses"`
+}
+
+// ClauseExplanation describes how a graph clause is resolved. Lookup is the
+// storage.Graph method used to retrieve its data. The estimated cardinality is
+// only provided if all the graphs estimate it. Clauses with predicate
+// alternatives are resolved once per alternative, hence Alternatives describes
+// each of them and the estimate adds up theirs.
+type ClauseExplanation struct {
+	Clause               string               `json:"clause"`
+	Specificity          int                  `json:"specificity"`
+	Join                 string               `json:"join,omitempty"`
+	Lookup               string               `json:"lookup"`
+	EstimatedCardinality *int                 `json:"estimated_cardinality,omitempty"`
+	Alternatives         []*ClauseExplanation `json:"alternatives,omitempty"`
+}
+
+// lookupFor returns the storage.Graph method used to retrieve the triples of
+// a clause given which of its components are known.
+func lookupFor(s, p, o bool) string {
+	switch {
+	case s && p && o:
+		return "Exist"
+	case s && p:
+		return "Objects"
+	case s && o:
+		return "PredicatesForSubjectAndObject"
+	case p && o:
+		return "Subjects"
+	case s:
+		return "TriplesForSubject"
+	case p:
+		return "TriplesForPredicate"
+	case o:
+		return "TriplesForObject"
+	default:
+		return "Triples"
+	}
+}
+
+// explainClause describes how the provided clause is resolved given the
+// bindings already bound by the clauses resolved before it.
+func explainClause(ctx context.Context, gs []storage.Graph, cls *semantic.GraphClause, bound map[string]bool) (*ClauseExplanation, error) {
+	if len(cls.PAlternatives) > 0 {
+		return explainAlternatives(ctx, gs, cls, bound)
+	}
+	ce := &ClauseExplanation{Clause: cls.String(), Specificity: cls.Specificity()}
+	exist, total := 0, 0
+	for _, b := range cls.Bindings() {
+		total++
+		if bound[b] {
+			exist++
+		}
+	}
+	// Text literals matching regardless of case are not used for lookups.
+	_, folded := foldedObject(cls)
+	switch {
+	case cls.Negated:
+		ce.Join = JoinNegated
+	case cls.NotExists > 0:
+		ce.Join = JoinNotExists
+	case cls.Specificity() == 3 && !folded:
+		ce.Join = JoinExist
+	case exist == 0 && len(bound) == 0:
+		ce.Join = JoinScan
+	case exist == 0:
+		ce.Join = JoinCartesian
+	case exist < total || (cls.PTemporal && cls.PID != "") || cls.PIDPrefix || folded || hasNodeTypes(cls):
+		ce.Join = JoinSpecialize
+	default:
+		ce.Join = JoinExistence
+	}
+	if exist == 0 {
+		ce.Lookup = lookupFor(cls.S != nil, cls.P != nil, cls.O != nil && !folded)
+	} else {
+		ce.Lookup = lookupFor(
+			cls.S != nil || bound[cls.SBinding] || bound[cls.SAlias],
+			cls.P != nil || bound[cls.PBinding] || bound[cls.PAlias],
+			(cls.O != nil && !folded) || bound[cls.OBinding] || bound[cls.OAlias])
+	}
+	if cls.PPath != semantic.SingleStep {
+		// Paths are expanded one step at a time.
+		switch ce.Lookup {
+		case "Exist":
+			ce.Lookup = "Objects"
+		case "Objects", "Subjects":
+		default:
+			ce.Lookup = "TriplesForPredicate"
+		}
+	}
+	n, ok, err := clauseCardinality(ctx, gs, cls)
+	if err != nil {
+		return nil, err
+	}
+	if ok && len(gs) > 0 {
+		ce.EstimatedCardinality = &n
+	}
+	return ce, nil
+}
+
+// explainAlternatives describes a clause with predicate alternatives as the
+// clauses resolved for each alternative. All of them share the lookup and the
+// specificity since they only differ in their predicate; the join is the one
+// of the alternatives, or JoinSpecialize if temporal and immutable predicates
+// are joined differently.
+func explainAlternatives(ctx context.Context, gs []storage.Graph, cls *semantic.GraphClause, bound map[string]bool) (*ClauseExplanation, error) {
+	ce := &ClauseExplanation{Clause: cls.String()}
+	for _, alt := range expandAlternatives(cls) {
+		ae, err := explainClause(ctx, gs, alt, bound)
+		if err != nil {
+			return nil, err
+		}
+		if len(ce.Alternatives) == 0 {
+			ce.Specificity, ce.Join, ce.Lookup = ae.Specificity, ae.Join, ae.Lookup
+		} else if ce.Join != ae.Join {
+			ce.Join = JoinSpecialize
+		}
+		ce.Alternatives = append(ce.Alternatives, ae)
+	}
+	n, ok, err := clauseCardinality(ctx, gs, cls)
+	if err != nil {
+		return nil, err
+	}
+	if ok && len(gs) > 0 {
+		ce.EstimatedCardinality = &n
+	}
+	return ce, nil
+}
+
+// explain returns the description of the plan. Graphs are opened to estimate
+// the cardinality of the clauses, and clauses are ordered as Execute would.
+func (p *queryPlan) explain(ctx context.Context) (*PlanExplanation, error) {
+	gs := p.grfs
+	if gs == nil {
+		for _, n := range p.grfsNames {
+			g, err := p.store.Graph(ctx, n)
+			if err != nil {
+				return nil, err
+			}
+			gs = append(gs, g)
+		}
+	}
+	cls, ok, err := orderByCardinality(ctx, gs, p.stm.SortedGraphPatternClauses())
+	if err != nil {
+		return nil, err
+	}
+	e := &PlanExplanation{
+		Plan:           "QUERY",
+		Store:          p.store.Name(ctx),
+		Graphs:         p.grfsNames,
+		Operation:      "resolve",
+		ClauseOrdering: OrderBySpecificity,
+		Branches:       []*BranchExplanation{},
+	}
+	if ok {
+		e.ClauseOrdering = OrderByCardinality
+	}
+	switch {
+	case p.stm.IsSchemaQuery():
+		e.Operation = "scan schema"
+	case p.countsPredicates():
+		e.Operation = "count predicates"
+	case p.stm.IsLatestQuery():
+		e.Operation = "latest"
+	}
+	branchBound := make(map[int]map[string]bool)
+	for i := 0; i <= p.stm.UnionBranches(); i++ {
+		be := &BranchExplanation{Branch: i, Clauses: []*ClauseExplanation{}}
+		bound := make(map[string]bool)
+		branchBound[i] = bound
+		for _, c := range cls {
+			if c.Branch != i {
+				continue
+			}
+			ce, err := explainClause(ctx, gs, c, bound)
+			if err != nil {
+				return nil, err
+			}
+			be.Clauses = append(be.Clauses, ce)
+			for _, b := range c.Bindings() {
+				bound[b] = true
+			}
+		}
+		if i > 0 || len(be.Clauses) > 0 {
+			e.Branches = append(e.Branches, be)
+		}
+	}
+	e.IgnoreCase = p.stm.IgnoresCase()
+	for _, f := range p.stm.Filters() {
+		e.Filters = append(e.Filters, f.String())
+	}
+	for _, c := range p.stm.NegatedGraphPatternClauses() {
+		ce, err := explainClause(ctx, gs, c, branchBound[c.Branch])
+		if err != nil {
+			return nil, err
+		}
+		e.NegatedClauses = append(e.NegatedClauses, ce)
+	}
+	for _, grp := range p.stm.NotExistsGroups() {
+		var ges []*ClauseExplanation
+		bound := make(map[string]bool)
+		for _, c := range grp {
+			for b := range branchBound[c.Branch] {
+				bound[b] = true
+			}
+			ce, err := explainClause(ctx, gs, c, bound)
+			if err != nil {
+				return nil, err
+			}
+			ges = append(ges, ce)
+			for _, b := range c.Bindings() {
+				bound[b] = true
+			}
+		}
+		e.NotExistsGroups = append(e.NotExistsGroups, ges)
+	}
+	for _, pr := range p.stm.Projection() {
+		e.Projection = append(e.Projection, pr.String())
+	}
+	e.GroupBy = p.stm.GroupBy()
+	if ob := p.stm.OrderBy(); ob != nil {
+		e.OrderBy = ob.String()
+		e.MergeSortedGraphs = !p.stm.IsSchemaQuery() && p.canMergeSortedGraphs()
+	}
+	var hv []string
+	for _, h := range p.stm.HavingExpression() {
+		hv = append(hv, h.Token().Text)
+	}
+	e.Having = strings.Join(hv, " ")
+	e.Distinct = p.stm.IsDistinct()
+	if n := p.stm.OffsetParameter(); n != "" {
+		e.Offset = n
+	} else if p.stm.Offset() > 0 {
+		e.Offset = fmt.Sprintf("%d", p.stm.Offset())
+	}
+	if n := p.stm.LimitParameter(); n != "" {
+		e.Limit = n
+	} else if p.stm.HasLimit() {
+		e.Limit = fmt.Sprintf("%d", p.stm.Limit())
+	}
+	return e, nil
+}
+
+// String returns a readable description of the explained plan listing the
+// steps in the order they are executed.
+func (e *PlanExplanation) String() string {
+	b := bytes.NewBufferString("")
+	if e.Cached {
+		b.WriteString("cached ")
+	}
+	b.WriteString(e.Plan)
+	b.WriteString(" plan:\n\n")
+	b.WriteString(fmt.Sprintf("using store(%q) graphs %v\n", e.Store, e.Graphs))
+	b.WriteString(e.Operation)
+	b.WriteString("\n")
+	b.WriteString(fmt.Sprintf("clauses ordered by %s\n", e.ClauseOrdering))
+	for _, br := range e.Branches {
+		if br.Branch > 0 {
+			b.WriteString(fmt.Sprintf("union branch %d\n", br.Branch))
+		}
+		writeClauses(b, br.Clauses)
+	}
+	if e.IgnoreCase {
+		b.WriteString("match text literals ignoring case\n")
+	}
+	if len(e.Filters) > 0 {
+		b.WriteString("filter rows using\n")
+		for _, f := range e.Filters {
+			b.WriteString("\t")
+			b.WriteString(f)
+			b.WriteString("\n")
+		}
+	}
+	if len(e.NegatedClauses) > 0 {
+		b.WriteString("filter out rows matching\n")
+		writeClauses(b, e.NegatedClauses)
+	}
+	for _, grp := range e.NotExistsGroups {
+		b.WriteString("filter out rows matching all of\n")
+		writeClauses(b, grp)
+	}
+	if len(e.Projection) > 0 {
+		b.WriteString("project results using\n")
+		for _, p := range e.Projection {
+			b.WriteString("\t")
+			b.WriteString(p)
+			b.WriteString("\n")
+		}
+	}
+	if len(e.GroupBy) > 0 {
+		b.WriteString("group results using\n")
+		for _, g := range e.GroupBy {
+			b.WriteString("\t")
+			b.WriteString(g)
+			b.WriteString("\n")
+		}
+	}
+	if e.OrderBy != "" {
+		b.WriteString("order results by ")
+		b.WriteString(e.OrderBy)
+		b.WriteString("\n")
+		if e.MergeSortedGraphs {
+			b.WriteString("merge the sorted results of each graph\n")
+		}
+	}
+	if e.Having != "" {
+		b.WriteString("having projected values\n\t")
+		b.WriteString(e.Having)
+		b.WriteString("\n")
+	}
+	if e.Distinct {
+		b.WriteString("remove duplicated rows\n")
+	}
+	if e.Offset != "" {
+		b.WriteString(fmt.Sprintf("skip the first %s rows\n", e.Offset))
+	}
+	if e.Limit != "" {
+		b.WriteString(fmt.Sprintf("limit results to %s rows\n", e.Limit))
+	}
+	if e.Plan == "CONSTRUCT" {
+		b.WriteString(fmt.Sprintf("instantiate %d construct clauses for each row\n", e.Constructs))
+		for _, g := range e.OutputGraphs {
+			b.WriteString(fmt.Sprintf("store(%q).Graph(%q).AddTriples(_, constructed)\n", e.Store, g))
+		}
+	}
+	return b.String()
+}
+
+// writeClauses writes one numbered line per clause with how it is joined, its
+// specificity, and its estimated number of rows. The alternatives of a clause
+// are listed right after it.
+func writeClauses(b *bytes.Buffer, cls []*ClauseExplanation) {
+	for i, c := range cls {
+		writeClause(b, "\t", fmt.Sprintf("%d", i+1), c)
+		for j, a := range c.Alternatives {
+			writeClause(b, "\t\t", fmt.Sprintf("%d.%d", i+1, j+1), a)
+		}
+	}
+}
+
+// writeClause writes the line describing the provided clause.
+func writeClause(b *bytes.Buffer, indent, num string, c *ClauseExplanation) {
+	rows := "unknown"
+	if c.EstimatedCardinality != nil {
+		rows = fmt.Sprintf("%d", *c.EstimatedCardinality)
+	}
+	b.WriteString(fmt.Sprintf("%s%s. %s %s using %s, specificity %d, estimated rows %s\n", indent, num, c.Join, c.Clause, c.Lookup, c.Specificity, rows))
+}
+
+// Explain returns the description of the plan without executing it. Graphs
+// are only asked for the estimated cardinality of the clauses.
+func (p *queryPlan) Explain(ctx context.Context) (string, error) {
+	e, err := p.explain(ctx)
+	if err != nil {
+		return "", err
+	}
+	return e.String(), nil
+}
+
+// ExplainJSON returns the plan tree encoded as JSON.
+func (p *queryPlan) ExplainJSON() ([]byte, error) {
+	return marshalExplanation(p.explain(context.Background()))
+}
+
+// explain returns the description of the query building the triples along
+// with the graphs they are added to.
+func (p *constructPlan) explain(ctx context.Context) (*PlanExplanation, error) {
+	e, err := p.qp.explain(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e.Plan = "CONSTRUCT"
+	e.OutputGraphs = p.qp.stm.OutputGraphNames()
+	e.Constructs = len(p.qp.stm.ConstructClauses())
+	return e, nil
+}
+
+// Explain returns the description of the plan without executing it.
+func (p *constructPlan) Explain(ctx context.Context) (string, error) {
+	e, err := p.explain(ctx)
+	if err != nil {
+		return "", err
+	}
+	return e.String(), nil
+}
+
+// ExplainJSON returns the plan tree encoded as JSON.
+func (p *constructPlan) ExplainJSON() ([]byte, error) {
+	return marshalExplanation(p.explain(context.Background()))
+}
+
+// Explain returns the description of the decorated plan without executing
+// it.
+func (p *cachedPlan) Explain(ctx context.Context) (string, error) {
+	s, err := Explain(ctx, p.plan)
+	if err != nil {
+		return "", err
+	}
+	return "cached " + s, nil
+}
+
+// ExplainJSON returns the plan tree of the decorated plan encoded as JSON.
+func (p *cachedPlan) ExplainJSON() ([]byte, error) {
+	e, err := p.plan.(*queryPlan).explain(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	e.Cached = true
+	return marshalExplanation(e, nil)
+}
+
+// marshalExplanation encodes the provided explanation as indented JSON.
+// HTML characters are not escaped since node types and IDs are usually
+// wrapped in angle brackets.
+func marshalExplanation(e *PlanExplanation, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(e); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,299 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/bql/grammar"
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/storage"
+)
+
+// explainQuery returns the JSON explanation of the plan for the provided query.
+func explainQuery(t *testing.T, s storage.Store, q string) []byte {
+	b, err := planQuery(t, s, q).(JSONExplainer).ExplainJSON()
+	if err != nil {
+		t.Fatalf("planner.ExplainJSON failed for query %q with error %v", q, err)
+	}
+	return b
+}
+
+func TestExplainJSON(t *testing.T) {
+	want := `{
+  "plan": "QUERY",
+  "store": "VOLATILE",
+  "graphs": [
+    "?test"
+  ],
+  "operation": "resolve",
+  "clause_ordering": "cardinality",
+  "branches": [
+    {
+      "branch": 0,
+      "clauses": [
+        {
+          "clause": "{ ?u \"wrote\"@[] ?d }",
+          "specificity": 1,
+          "join": "scan",
+          "lookup": "TriplesForPredicate",
+          "estimated_cardinality": 2
+        },
+        {
+          "clause": "{ /u<hub> \"follows\"@[] ?u }",
+          "specificity": 2,
+          "join": "existence",
+          "lookup": "Exist",
+          "estimated_cardinality": 10
+        }
+      ]
+    }
+  ],
+  "projection": [
+    "?u as ?u",
+    "?d as ?d"
+  ],
+  "order_by": "[ ?u->ASC ]"
+}
+`
+	if got := string(explainQuery(t, newHubStore(t, 10, 2), chainQuery)); got != want {
+		t.Errorf("planner.ExplainJSON returned\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExplainJSONJoins(t *testing.T) {
+	q := `SELECT ?u FROM ?test WHERE {
+		/u<hub> "follows"@[] ?u .
+		/u<hub> "follows"@[] /u<user1> .
+		?u "wrote"@[] ?d .
+		?x "wrote"@[] ?y .
+		!{?u "blocks"@[] ?z}
+	} LIMIT "3"^^type:int64;`
+	var got PlanExplanation
+	if err := json.Unmarshal(explainQuery(t, newHubStore(t, 10, 2), q), &got); err != nil {
+		t.Fatal(err)
+	}
+	var joins []string
+	for _, c := range got.Branches[0].Clauses {
+		joins = append(joins, c.Join+" "+c.Lookup)
+	}
+	if want := []string{"exist Exist", "scan TriplesForPredicate", "existence Exist", "cartesian TriplesForPredicate"}; !reflect.DeepEqual(joins, want) {
+		t.Errorf("planner.ExplainJSON returned joins %v for query %q; want %v", joins, q, want)
+	}
+	if len(got.NegatedClauses) != 1 {
+		t.Fatalf("planner.ExplainJSON returned %d negated clauses for query %q; want 1", len(got.NegatedClauses), q)
+	}
+	if nc := got.NegatedClauses[0]; nc.Join != JoinNegated || nc.Lookup != "Objects" {
+		t.Errorf("planner.ExplainJSON returned negated clause %+v for query %q; want a negated lookup of the objects", nc, q)
+	}
+	if got.Limit != "3" {
+		t.Errorf("planner.ExplainJSON returned limit %q for query %q; want 3", got.Limit, q)
+	}
+}
+
+func TestExplainJSONAlternatives(t *testing.T) {
+	q := `SELECT ?u FROM ?test WHERE {/u<hub> ("follows"@[] | "wrote"@[]) ?u};`
+	var got PlanExplanation
+	if err := json.Unmarshal(explainQuery(t, newHubStore(t, 10, 2), q), &got); err != nil {
+		t.Fatal(err)
+	}
+	var c *ClauseExplanation
+	for _, ce := range got.Branches[0].Clauses {
+		if len(ce.Alternatives) > 0 {
+			c = ce
+		}
+	}
+	if c == nil {
+		t.Fatalf("planner.ExplainJSON did not describe the alternatives of query %q; got %+v", q, got.Branches[0].Clauses)
+	}
+	if c.Specificity != 2 || c.Join != JoinScan || c.Lookup != "Objects" || len(c.Alternatives) != 2 {
+		t.Errorf("planner.ExplainJSON returned clause %+v for query %q; want a scan of the objects of the 2 predicates", c, q)
+	}
+	total := 0
+	for _, a := range c.Alternatives {
+		if a.Join != c.Join || a.Lookup != c.Lookup || a.EstimatedCardinality == nil {
+			t.Errorf("planner.ExplainJSON returned alternative %+v of clause %+v for query %q", a, c, q)
+			continue
+		}
+		total += *a.EstimatedCardinality
+	}
+	if c.EstimatedCardinality == nil || *c.EstimatedCardinality != total {
+		t.Errorf("planner.ExplainJSON returned clause %+v for query %q; want the estimates of the alternatives adding up to %d", c, q, total)
+	}
+	txt, err := Explain(context.Background(), planQuery(t, newHubStore(t, 10, 2), q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\t\t1.2. scan { /u<hub> \"wrote\"@[] ?u } using Objects"; !strings.Contains(txt, want) {
+		t.Errorf("planner.Explain returned\n%s\nwant it to list the alternative %q", txt, want)
+	}
+}
+
+func TestExplainJSONWithoutEstimates(t *testing.T) {
+	var rows int64
+	s := &scanningStore{Store: newHubStore(t, 10, 2), rows: &rows}
+	var got PlanExplanation
+	if err := json.Unmarshal(explainQuery(t, s, chainQuery), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ClauseOrdering != OrderBySpecificity {
+		t.Errorf("planner.ExplainJSON returned clause ordering %q; want %q", got.ClauseOrdering, OrderBySpecificity)
+	}
+	for _, c := range got.Branches[0].Clauses {
+		if c.EstimatedCardinality != nil {
+			t.Errorf("planner.ExplainJSON should not estimate the cardinality of clause %q", c.Clause)
+		}
+	}
+	if first := got.Branches[0].Clauses[0]; first.Join != JoinScan || first.Lookup != "Objects" {
+		t.Errorf("planner.ExplainJSON should start scanning the objects of the hub; got %+v", first)
+	}
+}
+
+func TestExplainJSONCachedPlan(t *testing.T) {
+	s := newHubStore(t, 10, 2)
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, ctx := &semantic.Statement{}, context.Background()
+	if err := p.Parse(grammar.NewLLk(chainQuery, 1), st); err != nil {
+		t.Fatalf("Parser.consume: failed to parse query %q with error %v", chainQuery, err)
+	}
+	plnr, err := New(ctx, s, st, 0, nil)
+	if err != nil {
+		t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+	}
+	b, err := NewQueryCache(s, 10, 0).Executor(chainQuery, nil, plnr).(JSONExplainer).ExplainJSON()
+	if err != nil {
+		t.Fatalf("planner.ExplainJSON failed for the cached query with error %v", err)
+	}
+	var got PlanExplanation
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Cached || got.Plan != "QUERY" || len(got.Branches) != 1 {
+		t.Errorf("planner.ExplainJSON returned %+v for a cached query plan; want a cached QUERY plan with one branch", got)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	want := `QUERY plan:
+
+using store("VOLATILE") graphs [?test]
+resolve
+clauses ordered by cardinality
+	1. scan { ?u "wrote"@[] ?d } using TriplesForPredicate, specificity 1, estimated rows 2
+	2. existence { /u<hub> "follows"@[] ?u } using Exist, specificity 2, estimated rows 10
+project results using
+	?u as ?u
+	?d as ?d
+order results by [ ?u->ASC ]
+`
+	var rows int64
+	s := &scanningStore{Store: newHubStore(t, 10, 2), rows: &rows, estimate: true}
+	got, err := Explain(context.Background(), planQuery(t, s, chainQuery))
+	if err != nil {
+		t.Fatalf("planner.Explain failed with error %v", err)
+	}
+	if got != want {
+		t.Errorf("planner.Explain returned\n%s\nwant\n%s", got, want)
+	}
+	if rows != 0 {
+		t.Errorf("planner.Explain retrieved %d values from the store; want none", rows)
+	}
+}
+
+func TestExplainSteps(t *testing.T) {
+	q := `SELECT ?u, COUNT(?d) AS ?n FROM ?test WHERE {
+		/u<hub> "follows"@[] ?u .
+		?x "wrote"@[] ?d .
+		!{?u "blocks"@[] ?z}
+	} GROUP BY ?u ORDER BY ?n DESC HAVING ?n = ?n LIMIT "3"^^type:int64;`
+	want := `QUERY plan:
+
+using store("VOLATILE") graphs [?test]
+resolve
+clauses ordered by cardinality
+	1. scan { ?x "wrote"@[] ?d } using TriplesForPredicate, specificity 1, estimated rows 2
+	2. cartesian { /u<hub> "follows"@[] ?u } using Objects, specificity 2, estimated rows 10
+filter out rows matching
+	1. negated !{ ?u "blocks"@[] ?z } using Objects, specificity 1, estimated rows 0
+project results using
+	?u as ?u
+	?d as ?n via COUNT
+group results using
+	?u
+order results by [ ?n->DESC ]
+having projected values
+	?n = ?n
+limit results to 3 rows
+`
+	got, err := Explain(context.Background(), planQuery(t, newHubStore(t, 10, 2), q))
+	if err != nil {
+		t.Fatalf("planner.Explain failed for query %q with error %v", q, err)
+	}
+	if got != want {
+		t.Errorf("planner.Explain returned\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExplainIgnoreCase(t *testing.T) {
+	q := `SELECT ?u FROM ?test WHERE {?u "name"@[] "Hub"^^type:text} ignore_case;`
+	want := `QUERY plan:
+
+using store("VOLATILE") graphs [?test]
+resolve
+clauses ordered by cardinality
+	1. scan { ?u "name"@[] "Hub"^^type:text } using TriplesForPredicate, specificity 2, estimated rows 0
+match text literals ignoring case
+project results using
+	?u as ?u
+`
+	got, err := Explain(context.Background(), planQuery(t, newHubStore(t, 10, 2), q))
+	if err != nil {
+		t.Fatalf("planner.Explain failed for query %q with error %v", q, err)
+	}
+	if got != want {
+		t.Errorf("planner.Explain returned\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExplainDoesNotModifyGraphs(t *testing.T) {
+	s, ctx := newHubStore(t, 10, 2), context.Background()
+	for _, q := range []string{
+		`INSERT DATA INTO ?test {/u<hub> "follows"@[] /u<new>};`,
+		`DELETE DATA FROM ?test {/u<hub> "follows"@[] /u<user0>};`,
+		`DROP GRAPH ?test;`,
+	} {
+		pln := planQuery(t, s, q)
+		got, err := Explain(ctx, pln)
+		if err != nil {
+			t.Errorf("planner.Explain failed for statement %q with error %v", q, err)
+		}
+		// Plans that perform no lookups are described by String.
+		if _, ok := pln.(Explainer); !ok && got != pln.String() {
+			t.Errorf("planner.Explain for statement %q returned %q; want %q", q, got, pln.String())
+		}
+	}
+	tbl := mustRunQuery(t, s, `SELECT ?u FROM ?test WHERE {/u<hub> "follows"@[] ?u};`)
+	if got := tbl.NumRows(); got != 10 {
+		t.Errorf("planner.Explain modified the graph; got %d followed users, want 10", got)
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// checkPathClauses checks that the path clauses of the statement only bind
+// their subject and object. Aliases, types, IDs, and anchors of the traversed
+// triples are not available once the path is expanded.
+func checkPathClauses(stm *semantic.Statement) error {
+	for _, cls := range stm.GraphPatternClauses() {
+		if cls.PPath == semantic.SingleStep {
+			continue
+		}
+		if cls.SAlias != "" || cls.STypeAlias != "" || cls.SIDAlias != "" ||
+			cls.OAlias != "" || cls.OTypeAlias != "" || cls.OIDAlias != "" ||
+			cls.OAnchorBinding != "" || cls.OAnchorAlias != "" || cls.OLowerBoundAlias != "" || cls.OUpperBoundAlias != "" ||
+			cls.OID != "" || cls.OTemporal {
+			return fmt.Errorf("path clause %s only supports binding its subject and object", cls)
+		}
+		if cls.O != nil {
+			if _, err := cls.O.Node(); err != nil {
+				return fmt.Errorf("path clause %s requires a node object; %v", cls, err)
+			}
+		}
+	}
+	return nil
+}
+
+// pathNeighbors returns the nodes one step away from the provided node
+// following the predicate forwards, from subject to object, or backwards
+// across all the provided graphs. Objects that are not nodes end the path.
+func pathNeighbors(ctx context.Context, gs []storage.Graph, p *predicate.Predicate, lo *storage.LookupOptions, n *node.Node, forward bool, chanSize int) ([]*node.Node, error) {
+	var ns []*node.Node
+	for _, g := range gs {
+		if forward {
+			os, errc := make(chan *triple.Object, chanSize), make(chan error, 1)
+			go func() {
+				errc <- g.Objects(ctx, n, p, lo, os)
+			}()
+			for o := range os {
+				if on, err := o.Node(); err == nil {
+					ns = append(ns, on)
+				}
+			}
+			if err := <-errc; err != nil {
+				return nil, err
+			}
+			continue
+		}
+		o := triple.NewNodeObject(n)
+		ss, errc := make(chan *node.Node, chanSize), make(chan error, 1)
+		go func() {
+			errc <- g.Subjects(ctx, p, o, lo, ss)
+		}()
+		for s := range ss {
+			ns = append(ns, s)
+		}
+		if err := <-errc; err != nil {
+			return nil, err
+		}
+	}
+	return ns, nil
+}
+
+// reachableNodes returns the nodes reachable from the provided node after one
+// or more steps, or zero or more if zero is set. Each node is only expanded
+// once, hence cycles do not prevent the traversal from finishing.
+func reachableNodes(start *node.Node, zero bool, neighbors func(*node.Node) ([]*node.Node, error)) ([]*node.Node, error) {
+	var res []*node.Node
+	visited := make(map[string]bool)
+	if zero {
+		visited[start.String()] = true
+		res = append(res, start)
+	}
+	frontier := []*node.Node{start}
+	for len(frontier) > 0 {
+		var next []*node.Node
+		for _, n := range frontier {
+			ns, err := neighbors(n)
+			if err != nil {
+				return nil, err
+			}
+			for _, nn := range ns {
+				if visited[nn.String()] {
+					continue
+				}
+				visited[nn.String()] = true
+				res = append(res, nn)
+				next = append(next, nn)
+			}
+		}
+		frontier = next
+	}
+	return res, nil
+}
+
+// addPathRow adds the row for the path from s to o to the table. Clauses
+// using the same binding for subject and object only match cycles, and paths
+// whose ends do not satisfy the node type constraints are skipped.
+func addPathRow(tbl *table.Table, cls *semantic.GraphClause, s, o *node.Node) {
+	if cls.SBinding != "" && cls.SBinding == cls.OBinding && s.String() != o.String() {
+		return
+	}
+	if cls.SNodeType != nil && !s.Type().Covariant(cls.SNodeType) || cls.ONodeType != nil && !o.Type().Covariant(cls.ONodeType) {
+		return
+	}
+	r := make(table.Row)
+	if cls.SBinding != "" {
+		r[cls.SBinding] = &table.Cell{N: s}
+	}
+	if cls.OBinding != "" {
+		r[cls.OBinding] = &table.Cell{N: o}
+	}
+	tbl.AddRow(r)
+}
+
+// graphPathFetch returns a table with the subjects and objects of the paths
+// matching the provided path clause in each of the provided graphs, binding
+// the graph they were found in to the graph binding of the clause. Unlike
+// pathFetch, paths do not traverse triples of different graphs.
+func graphPathFetch(ctx context.Context, gs []storage.Graph, cls *semantic.GraphClause, lo *storage.LookupOptions, chanSize int) (*table.Table, error) {
+	tbl, err := table.New(cls.Bindings())
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range gs {
+		gtbl, err := pathFetch(ctx, []storage.Graph{g}, cls, lo, chanSize)
+		if err != nil {
+			return nil, err
+		}
+		gc, err := graphNameCell(g.ID(ctx))
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range gtbl.Rows() {
+			r[cls.GBinding] = gc
+			tbl.AddRow(r)
+		}
+	}
+	return tbl, nil
+}
+
+// pathFetch returns a table with the subjects and objects of the paths
+// matching the provided path clause across all the provided graphs. Paths are
+// expanded iteratively until no new nodes are reached. Paths from a known
+// subject are expanded forwards, paths to a known object backwards, and
+// otherwise the triples of the predicate are loaded once and the paths from
+// each node are expanded in memory.
+func pathFetch(ctx context.Context, gs []storage.Graph, cls *semantic.GraphClause, lo *storage.LookupOptions, chanSize int) (*table.Table, error) {
+	tbl, err := table.New(cls.Bindings())
+	if err != nil {
+		return nil, err
+	}
+	// Limits apply to the paths, not to the triples traversed.
+	nlo := *updateTimeBounds(lo, cls)
+	nlo.MaxElements = 0
+	zero := cls.PPath == semantic.ZeroOrMore
+	var o *node.Node
+	if cls.O != nil {
+		if o, err = cls.O.Node(); err != nil {
+			return nil, fmt.Errorf("path clause %s requires a node object; %v", cls, err)
+		}
+	}
+	lookup := func(forward bool) func(*node.Node) ([]*node.Node, error) {
+		return func(n *node.Node) ([]*node.Node, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return pathNeighbors(ctx, gs, cls.P, &nlo, n, forward, chanSize)
+		}
+	}
+	switch {
+	case cls.S != nil:
+		ns, err := reachableNodes(cls.S, zero, lookup(true))
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range ns {
+			if o == nil || n.String() == o.String() {
+				addPathRow(tbl, cls, cls.S, n)
+			}
+		}
+	case o != nil:
+		ns, err := reachableNodes(o, zero, lookup(false))
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range ns {
+			addPathRow(tbl, cls, n, o)
+		}
+	default:
+		adj, nodes, err := pathAdjacency(ctx, gs, cls.P, &nlo, zero, chanSize)
+		if err != nil {
+			return nil, err
+		}
+		inMemory := func(n *node.Node) ([]*node.Node, error) {
+			return adj[n.String()], nil
+		}
+		for _, s := range nodes {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			ns, err := reachableNodes(s, zero, inMemory)
+			if err != nil {
+				return nil, err
+			}
+			for _, n := range ns {
+				addPathRow(tbl, cls, s, n)
+			}
+		}
+	}
+	return tbl, nil
+}
+
+// pathExist returns true if the object of the provided fully specified path
+// clause is reachable from its subject across all the provided graphs.
+func pathExist(ctx context.Context, gs []storage.Graph, cls *semantic.GraphClause, lo *storage.LookupOptions, chanSize int) (bool, error) {
+	o, err := cls.O.Node()
+	if err != nil {
+		return false, fmt.Errorf("path clause %s requires a node object; %v", cls, err)
+	}
+	if cls.PPath == semantic.ZeroOrMore && cls.S.String() == o.String() {
+		return true, nil
+	}
+	nlo := *updateTimeBounds(lo, cls)
+	nlo.MaxElements = 0
+	ns, err := reachableNodes(cls.S, false, func(n *node.Node) ([]*node.Node, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return pathNeighbors(ctx, gs, cls.P, &nlo, n, true, chanSize)
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, n := range ns {
+		if n.String() == o.String() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// pathAdjacency returns the objects of each subject of the provided predicate
+// across all the provided graphs along with the nodes paths start from. Paths
+// start from every subject, and also from every node object if zero steps are
+// allowed.
+func pathAdjacency(ctx context.Context, gs []storage.Graph, p *predicate.Predicate, lo *storage.LookupOptions, zero bool, chanSize int) (map[string][]*node.Node, []*node.Node, error) {
+	adj := make(map[string][]*node.Node)
+	seen := make(map[string]bool)
+	var nodes []*node.Node
+	addNode := func(n *node.Node) {
+		if !seen[n.String()] {
+			seen[n.String()] = true
+			nodes = append(nodes, n)
+		}
+	}
+	for _, g := range gs {
+		ts, errc := make(chan *triple.Triple, chanSize), make(chan error, 1)
+		go func() {
+			errc <- g.TriplesForPredicate(ctx, p, lo, ts)
+		}()
+		for t := range ts {
+			o, err := t.Object().Node()
+			if err != nil {
+				continue
+			}
+			addNode(t.Subject())
+			if zero {
+				addNode(o)
+			}
+			adj[t.Subject().String()] = append(adj[t.Subject().String()], o)
+		}
+		if err := <-errc; err != nil {
+			return nil, nil, err
+		}
+	}
+	return adj, nodes, nil
+}
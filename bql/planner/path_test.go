@@ -0,0 +1,155 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/bql/grammar"
+	"github.com/google/badwolf/bql/semantic"
+)
+
+var testFamilyTriples = []string{
+	"/person<Gavin Belson>\t\"parent_of\"@[]\t/person<Peter Belson>",
+	"/person<Gavin Belson>\t\"parent_of\"@[]\t/person<Mary Belson>",
+	"/person<Mary Belson>\t\"parent_of\"@[]\t/person<Amy Schumer>",
+	"/person<Mary Belson>\t\"parent_of\"@[]\t/person<Joe Schumer>",
+	"/person<Amy Schumer>\t\"born_in\"@[]\t/city<Springfield>",
+}
+
+func TestPlannerPathQueries(t *testing.T) {
+	family, rooms := getTestStore(t, testFamilyTriples), populateTestStore(t)
+	testTable := []struct {
+		q     string
+		bs    []string
+		rooms bool
+		want  []string
+	}{
+		{
+			q:    `SELECT ?ancestor FROM ?test WHERE {?ancestor "parent_of"@[]+ /person<Amy Schumer>} ORDER BY ?ancestor;`,
+			bs:   []string{"?ancestor"},
+			want: []string{"/person<Gavin Belson>", "/person<Mary Belson>"},
+		},
+		{
+			q:    `SELECT ?ancestor FROM ?test WHERE {?ancestor "parent_of"@[]* /person<Amy Schumer>} ORDER BY ?ancestor;`,
+			bs:   []string{"?ancestor"},
+			want: []string{"/person<Amy Schumer>", "/person<Gavin Belson>", "/person<Mary Belson>"},
+		},
+		{
+			q:    `SELECT ?d FROM ?test WHERE {/person<Gavin Belson> "parent_of"@[]+ ?d} ORDER BY ?d;`,
+			bs:   []string{"?d"},
+			want: []string{"/person<Amy Schumer>", "/person<Joe Schumer>", "/person<Mary Belson>", "/person<Peter Belson>"},
+		},
+		{
+			q:  `SELECT ?a, ?d FROM ?test WHERE {?a "parent_of"@[]+ ?d} ORDER BY ?a, ?d;`,
+			bs: []string{"?a", "?d"},
+			want: []string{
+				"/person<Gavin Belson>\t/person<Amy Schumer>",
+				"/person<Gavin Belson>\t/person<Joe Schumer>",
+				"/person<Gavin Belson>\t/person<Mary Belson>",
+				"/person<Gavin Belson>\t/person<Peter Belson>",
+				"/person<Mary Belson>\t/person<Amy Schumer>",
+				"/person<Mary Belson>\t/person<Joe Schumer>",
+			},
+		},
+		{
+			// Paths are joined with the rows of the previous clauses.
+			q:    `SELECT ?a FROM ?test WHERE {?a "parent_of"@[]+ ?c . ?c "born_in"@[] /city<Springfield>} ORDER BY ?a;`,
+			bs:   []string{"?a"},
+			want: []string{"/person<Gavin Belson>", "/person<Mary Belson>"},
+		},
+		{
+			q:    `SELECT ?c FROM ?test WHERE {/person<Gavin Belson> "parent_of"@[]+ /person<Amy Schumer> . ?c "born_in"@[] ?city};`,
+			bs:   []string{"?c"},
+			want: []string{"/person<Amy Schumer>"},
+		},
+		{
+			q:  `SELECT ?c FROM ?test WHERE {/person<Amy Schumer> "parent_of"@[]+ /person<Gavin Belson> . ?c "born_in"@[] ?city};`,
+			bs: []string{"?c"},
+		},
+		{
+			// The rooms form cycles, hence the Hallway can be reached from itself.
+			q:     `SELECT ?r FROM ?test WHERE {/room<Hallway> "connects_to"@[]+ ?r} ORDER BY ?r;`,
+			bs:    []string{"?r"},
+			rooms: true,
+			want:  []string{"/room<Bathroom>", "/room<Bedroom>", "/room<Fire Escape>", "/room<Hallway>", "/room<Kitchen>"},
+		},
+		{
+			q:     `SELECT ?r FROM ?test WHERE {?r "connects_to"@[]* /room<Fire Escape>} ORDER BY ?r;`,
+			bs:    []string{"?r"},
+			rooms: true,
+			want:  []string{"/room<Bathroom>", "/room<Bedroom>", "/room<Fire Escape>", "/room<Hallway>", "/room<Kitchen>"},
+		},
+		{
+			// Using the same binding only matches the cycles.
+			q:     `SELECT ?r FROM ?test WHERE {?r "connects_to"@[]+ ?r} ORDER BY ?r;`,
+			bs:    []string{"?r"},
+			rooms: true,
+			want:  []string{"/room<Bathroom>", "/room<Bedroom>", "/room<Fire Escape>", "/room<Hallway>", "/room<Kitchen>"},
+		},
+		{
+			q:     `SELECT ?r FROM ?test WHERE {?r "connects_to"@[]+ ?r . ?r "connects_to"@[] /room<Fire Escape>};`,
+			bs:    []string{"?r"},
+			rooms: true,
+			want:  []string{"/room<Bedroom>"},
+		},
+	}
+	for _, entry := range testTable {
+		s := family
+		if entry.rooms {
+			s = rooms
+		}
+		tbl := mustRunQuery(t, s, entry.q)
+		if got := rowStrings(tbl, entry.bs); !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %q, want %q", entry.q, got, entry.want)
+		}
+	}
+}
+
+func TestPlannerPathQueriesNegated(t *testing.T) {
+	q := `SELECT ?p FROM ?test WHERE {?p "parent_of"@[] ?c . !{?p "parent_of"@[]+ /person<Amy Schumer>}} ORDER BY ?p;`
+	tbl := mustRunQuery(t, getTestStore(t, testFamilyTriples), q)
+	if got, want := rowStrings(tbl, []string{"?p"}), []string(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("planner.Execute returned the wrong rows for query %q; got %q, want %q", q, got, want)
+	}
+	q = `SELECT ?c FROM ?test WHERE {/person<Gavin Belson> "parent_of"@[] ?c . !{?c "parent_of"@[]+ /person<Amy Schumer>}};`
+	tbl = mustRunQuery(t, getTestStore(t, testFamilyTriples), q)
+	if got, want := rowStrings(tbl, []string{"?c"}), []string{"/person<Peter Belson>"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("planner.Execute returned the wrong rows for query %q; got %q, want %q", q, got, want)
+	}
+}
+
+func TestPlannerRejectsInvalidPathClauses(t *testing.T) {
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := getTestStore(t, testFamilyTriples)
+	for _, q := range []string{
+		`SELECT ?a, ?t FROM ?test WHERE {?a "parent_of"@[]+ ?d TYPE ?t};`,
+		`SELECT ?a FROM ?test WHERE {?a "parent_of"@[]+ "1"^^type:int64};`,
+	} {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+		}
+		if _, err := New(context.Background(), s, st, 0, nil); err == nil {
+			t.Errorf("planner.New should have rejected query %q", q)
+		}
+	}
+}
@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -32,9 +34,12 @@ import (
 	"github.com/google/badwolf/bql/lexer"
 	"github.com/google/badwolf/bql/semantic"
 	"github.com/google/badwolf/bql/table"
+	bio "github.com/google/badwolf/io"
 	"github.com/google/badwolf/storage"
 	"github.com/google/badwolf/triple"
 	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
 )
 
 // Executor interface unifies the execution of statements.
@@ -46,6 +51,40 @@ type Executor interface {
 	String() string
 }
 
+// ParameterizedExecutor is implemented by the executors of statements that
+// accept parameters. Parameter values are provided on each execution, hence
+// the same plan can be executed multiple times with different values.
+type ParameterizedExecutor interface {
+	Executor
+
+	// ExecuteWithParameters runs the plan binding the provided values to the
+	// statement parameters. Parameter names include the @ prefix. Values must
+	// be a *node.Node, a *predicate.Predicate, or a *literal.Literal.
+	ExecuteWithParameters(ctx context.Context, ps map[string]interface{}) (*table.Table, error)
+}
+
+// parameterCell returns the cell boxing the value of the named parameter.
+func parameterCell(n string, v interface{}) (*table.Cell, error) {
+	switch tv := v.(type) {
+	case *node.Node:
+		if tv != nil {
+			return &table.Cell{N: tv}, nil
+		}
+	case *predicate.Predicate:
+		if tv != nil {
+			return &table.Cell{P: tv}, nil
+		}
+	case *literal.Literal:
+		if tv != nil {
+			return &table.Cell{L: tv}, nil
+		}
+	case nil:
+	default:
+		return nil, fmt.Errorf("planner.Execute: parameter %s requires a node, a predicate, or a literal value; found %T instead", n, v)
+	}
+	return nil, fmt.Errorf("planner.Execute: missing value for parameter %s", n)
+}
+
 // trace attempts to write a trace if a valid writer is provided. The
 // tracer is lazy on the string generation to avoid adding too much
 // overhead when tracing ins not on.
@@ -70,14 +109,25 @@ type createPlan struct {
 	tracer io.Writer
 }
 
-// Execute creates the indicated graphs.
+// Execute creates the indicated graphs, or clones the source graph into each
+// of them if the statement has one.
 func (p *createPlan) Execute(ctx context.Context) (*table.Table, error) {
 	t, err := table.New([]string{})
 	if err != nil {
 		return nil, err
 	}
 	errs := []string{}
+	src := p.stm.CloneSource()
 	for _, g := range p.stm.GraphNames() {
+		if src != "" {
+			trace(p.tracer, func() []string {
+				return []string{"Cloning graph \"" + src + "\" into new graph \"" + g + "\""}
+			})
+			if err := storage.CloneGraph(ctx, p.store, src, g); err != nil {
+				errs = append(errs, err.Error())
+			}
+			continue
+		}
 		trace(p.tracer, func() []string {
 			return []string{"Creating new graph \"" + g + "\""}
 		})
@@ -93,6 +143,9 @@ func (p *createPlan) Execute(ctx context.Context) (*table.Table, error) {
 
 // String returns a readable description of the execution plan.
 func (p *createPlan) String() string {
+	if src := p.stm.CloneSource(); src != "" {
+		return fmt.Sprintf("CREATE plan:\n\nstorage.CloneGraph(_, store(%q), %q, %v)", p.store.Name(nil), src, p.stm.GraphNames())
+	}
 	return fmt.Sprintf("CREATE plan:\n\nstore(%q).NewGraph(_, %v)", p.store.Name(nil), p.stm.Graphs())
 }
 
@@ -130,17 +183,224 @@ func (p *dropPlan) String() string {
 	return fmt.Sprintf("DROP plan:\n\nstore(%q).DeleteGraph(_, %v)", p.store.Name(nil), p.stm.Graphs())
 }
 
+// truncatePlan encapsulates the sequence of instructions that need to be
+// executed in order to satisfy the execution of a valid truncate BQL
+// statement. Unlike dropPlan, it keeps the graphs and their metadata, and only
+// removes their triples.
+type truncatePlan struct {
+	stm    *semantic.Statement
+	store  storage.Store
+	tracer io.Writer
+}
+
+// Execute removes all the triples of the indicated graphs.
+func (p *truncatePlan) Execute(ctx context.Context) (*table.Table, error) {
+	t, err := table.New([]string{})
+	if err != nil {
+		return nil, err
+	}
+	errs := []string{}
+	for _, id := range p.stm.GraphNames() {
+		trace(p.tracer, func() []string {
+			return []string{"Clearing graph \"" + id + "\""}
+		})
+		g, err := p.store.Graph(ctx, id)
+		if err == nil {
+			err = storage.ClearGraph(ctx, g)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+	return t, nil
+}
+
+// String returns a readable description of the execution plan.
+func (p *truncatePlan) String() string {
+	return fmt.Sprintf("TRUNCATE plan:\n\nstorage.ClearGraph(_, store(%q).Graph(_, %v))", p.store.Name(nil), p.stm.Graphs())
+}
+
+// importPlan encapsulates the sequence of instructions that need to be
+// executed in order to satisfy the execution of a valid import BQL statement.
+type importPlan struct {
+	stm    *semantic.Statement
+	store  storage.Store
+	tracer io.Writer
+}
+
+// Execute loads the serialized triples of the statement into the indicated
+// graphs using the same format as io.ReadIntoGraph. Loading stops on the
+// first triple that fails to parse; the triples read till then are kept. No
+// data is loaded if any of the graphs is read-only.
+func (p *importPlan) Execute(ctx context.Context) (*table.Table, error) {
+	t, err := table.New([]string{})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWritable(ctx, p.store, p.stm.GraphNames()); err != nil {
+		return nil, err
+	}
+	for _, name := range p.stm.GraphNames() {
+		g, err := p.store.Graph(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		n, err := bio.ReadIntoGraph(ctx, g, strings.NewReader(p.stm.ImportData()), literal.DefaultBuilder())
+		trace(p.tracer, func() []string {
+			return []string{fmt.Sprintf("Imported %d triples into graph %q", n, name)}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to import triples into graph %q with error %v", name, err)
+		}
+	}
+	return t, nil
+}
+
+// String returns a readable description of the execution plan.
+func (p *importPlan) String() string {
+	b := bytes.NewBufferString("IMPORT plan:\n\n")
+	for _, g := range p.stm.GraphNames() {
+		b.WriteString(fmt.Sprintf("io.ReadIntoGraph(_, store(%q).Graph(%q), data, _)\n", p.store.Name(nil), g))
+	}
+	b.WriteString("where data:\n")
+	for _, l := range strings.Split(p.stm.ImportData(), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			b.WriteString("\t")
+			b.WriteString(l)
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// showPlan encapsulates the sequence of instructions that need to be executed
+// in order to satisfy the execution of a valid show BQL statement.
+type showPlan struct {
+	store  storage.Store
+	tracer io.Writer
+}
+
+// Execute lists the graphs of the store sorted by name along with their
+// metadata. It returns a row per metadata key of each graph sorted by key;
+// graphs without metadata return a single row with the key and value unbound.
+func (p *showPlan) Execute(ctx context.Context) (*table.Table, error) {
+	t, err := table.New([]string{"?graph", "?key", "?value"})
+	if err != nil {
+		return nil, err
+	}
+	gs, err := storage.GraphNames(ctx, p.store)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range gs {
+		trace(p.tracer, func() []string {
+			return []string{fmt.Sprintf("Retrieving the metadata of graph %q", g)}
+		})
+		md, err := storage.GraphMetadata(ctx, p.store, g)
+		if err != nil {
+			return nil, err
+		}
+		gc := &table.Cell{S: table.CellString(g)}
+		if len(md) == 0 {
+			t.AddRow(table.Row{"?graph": gc})
+			continue
+		}
+		var ks []string
+		for k := range md {
+			ks = append(ks, k)
+		}
+		sort.Strings(ks)
+		for _, k := range ks {
+			t.AddRow(table.Row{
+				"?graph": gc,
+				"?key":   &table.Cell{S: table.CellString(k)},
+				"?value": &table.Cell{S: table.CellString(md[k])},
+			})
+		}
+	}
+	return t, nil
+}
+
+// String returns a readable description of the execution plan.
+func (p *showPlan) String() string {
+	return fmt.Sprintf("SHOW plan:\n\nstore(%q).GraphNames(_, _)\nstorage.GraphMetadata(_, _, graph) for each graph", p.store.Name(nil))
+}
+
+// describePlan encapsulates the sequence of instructions that need to be
+// executed in order to satisfy the execution of a valid describe BQL
+// statement.
+type describePlan struct {
+	stm    *semantic.Statement
+	store  storage.Store
+	tracer io.Writer
+}
+
+// Execute returns a row per graph of the statement, in the order they were
+// listed, with the number of triples, distinct predicate IDs, and distinct
+// subjects of the graph.
+func (p *describePlan) Execute(ctx context.Context) (*table.Table, error) {
+	t, err := table.New([]string{"?graph", "?triples", "?predicates", "?subjects"})
+	if err != nil {
+		return nil, err
+	}
+	count := func(n int) (*table.Cell, error) {
+		l, err := literal.DefaultBuilder().Build(literal.Int64, int64(n))
+		if err != nil {
+			return nil, err
+		}
+		return &table.Cell{L: l}, nil
+	}
+	for _, name := range p.stm.GraphNames() {
+		trace(p.tracer, func() []string {
+			return []string{fmt.Sprintf("Describing graph %q", name)}
+		})
+		g, err := p.store.Graph(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		d, err := storage.DescribeGraph(ctx, g)
+		if err != nil {
+			return nil, err
+		}
+		r := table.Row{"?graph": &table.Cell{S: table.CellString(name)}}
+		for b, n := range map[string]int{"?triples": d.Triples, "?predicates": d.Predicates, "?subjects": d.Subjects} {
+			c, err := count(n)
+			if err != nil {
+				return nil, err
+			}
+			r[b] = c
+		}
+		t.AddRow(r)
+	}
+	return t, nil
+}
+
+// String returns a readable description of the execution plan.
+func (p *describePlan) String() string {
+	b := bytes.NewBufferString("DESCRIBE plan:\n\n")
+	for _, g := range p.stm.GraphNames() {
+		b.WriteString(fmt.Sprintf("storage.DescribeGraph(_, store(%q).Graph(%q))\n", p.store.Name(nil), g))
+	}
+	return b.String()
+}
+
 // insertPlan encapsulates the sequence of instructions that need to be
 // executed in order to satisfy the execution of a valid insert BQL statement.
 type insertPlan struct {
 	stm    *semantic.Statement
 	store  storage.Store
 	tracer io.Writer
+	// construct contains the plan building the triples to insert if they are
+	// constructed out of a query instead of provided as data.
+	construct *constructPlan
 }
 
 type updater func(storage.Graph, []*triple.Triple) error
 
-func update(ctx context.Context, stm *semantic.Statement, store storage.Store, f updater) error {
+func update(ctx context.Context, stm *semantic.Statement, store storage.Store, d []*triple.Triple, f updater) error {
 	var (
 		mu   sync.Mutex
 		wg   sync.WaitGroup
@@ -161,7 +421,7 @@ func update(ctx context.Context, stm *semantic.Statement, store storage.Store, f
 				appendError(err)
 				return
 			}
-			err = f(g, stm.Data())
+			err = f(g, d)
 			if err != nil {
 				appendError(err)
 			}
@@ -174,13 +434,60 @@ func update(ctx context.Context, stm *semantic.Statement, store storage.Store, f
 	return nil
 }
 
-// Execute inserts the provided data into the indicated graphs.
+// mutatedGraphs returns the names of the graphs the insert or delete statement
+// adds triples to or removes them from.
+func mutatedGraphs(stm *semantic.Statement) []string {
+	if len(stm.ConstructClauses()) > 0 {
+		return stm.OutputGraphNames()
+	}
+	return stm.GraphNames()
+}
+
+// checkWritable returns an error if the store marks any of the provided graphs
+// as read-only.
+func checkWritable(ctx context.Context, store storage.Store, graphs []string) error {
+	rom, ok := store.(storage.ReadOnlyMarker)
+	if !ok {
+		return nil
+	}
+	for _, g := range graphs {
+		ro, err := rom.ReadOnly(ctx, g)
+		if err != nil {
+			return err
+		}
+		if ro {
+			return fmt.Errorf("cannot mutate graph %q; the graph is read-only", g)
+		}
+	}
+	return nil
+}
+
+// Execute inserts the provided data into the indicated graphs. Constructed
+// triples are inserted as they are built. All the NOW() anchors of the data
+// are set to the time the execution starts. No data is inserted if any of the
+// graphs is read-only.
 func (p *insertPlan) Execute(ctx context.Context) (*table.Table, error) {
 	t, err := table.New([]string{})
 	if err != nil {
 		return nil, err
 	}
-	return t, update(ctx, p.stm, p.store, func(g storage.Graph, d []*triple.Triple) error {
+	if err := checkWritable(ctx, p.store, mutatedGraphs(p.stm)); err != nil {
+		return nil, err
+	}
+	if p.construct != nil {
+		gs, err := p.construct.outputGraphs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return t, p.construct.construct(ctx, func(ts []*triple.Triple) error {
+			return p.construct.addTriples(ctx, gs, ts)
+		})
+	}
+	data, err := p.stm.DataAt(time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return t, update(ctx, p.stm, p.store, data, func(g storage.Graph, d []*triple.Triple) error {
 		trace(p.tracer, func() []string {
 			return []string{"Inserting triples to graph \"" + g.ID(ctx) + "\""}
 		})
@@ -191,6 +498,14 @@ func (p *insertPlan) Execute(ctx context.Context) (*table.Table, error) {
 // String returns a readable description of the execution plan.
 func (p *insertPlan) String() string {
 	b := bytes.NewBufferString("INSERT plan:\n\n")
+	if p.construct != nil {
+		for _, g := range p.stm.OutputGraphNames() {
+			b.WriteString(fmt.Sprintf("store(%q).Graph(%q).AddTriples(_, constructed)\n", p.store.Name(nil), g))
+		}
+		b.WriteString("where constructed:\n")
+		b.WriteString(p.construct.String())
+		return b.String()
+	}
 	for _, g := range p.stm.Graphs() {
 		b.WriteString(fmt.Sprintf("store(%q).Graph(%q).AddTriples(_, data)\n", p.store.Name(nil), g))
 	}
@@ -200,24 +515,59 @@ func (p *insertPlan) String() string {
 		b.WriteString(t.String())
 		b.WriteString("\n")
 	}
+	for _, t := range p.stm.NowData() {
+		b.WriteString("\t")
+		b.WriteString(t.String())
+		b.WriteString("\n")
+	}
 	return b.String()
 }
 
+// Explain returns the description of the plan. Inserting data requires no
+// lookups unless the triples are constructed from the results of a query.
+func (p *insertPlan) Explain(ctx context.Context) (string, error) {
+	if p.construct == nil {
+		return p.String(), nil
+	}
+	c, err := p.construct.Explain(ctx)
+	if err != nil {
+		return "", err
+	}
+	b := bytes.NewBufferString("INSERT plan:\n\n")
+	for _, g := range p.stm.OutputGraphNames() {
+		b.WriteString(fmt.Sprintf("store(%q).Graph(%q).AddTriples(_, constructed)\n", p.store.Name(nil), g))
+	}
+	b.WriteString("where constructed:\n")
+	b.WriteString(c)
+	return b.String(), nil
+}
+
 // deletePlan encapsulates the sequence of instructions that need to be
 // executed in order to satisfy the execution of a valid delete BQL statement.
 type deletePlan struct {
 	stm    *semantic.Statement
 	store  storage.Store
 	tracer io.Writer
+	// pattern resolves the graph pattern of the statement, if any, whose
+	// matching triples get deleted.
+	pattern *queryPlan
 }
 
-// Execute deletes the provided data into the indicated graphs.
+// Execute deletes the provided data into the indicated graphs, or the triples
+// matching the graph pattern of the statement. No data is deleted if any of
+// the graphs is read-only.
 func (p *deletePlan) Execute(ctx context.Context) (*table.Table, error) {
 	t, err := table.New([]string{})
 	if err != nil {
 		return nil, err
 	}
-	return t, update(ctx, p.stm, p.store, func(g storage.Graph, d []*triple.Triple) error {
+	if err := checkWritable(ctx, p.store, mutatedGraphs(p.stm)); err != nil {
+		return nil, err
+	}
+	if p.pattern != nil {
+		return t, p.removeMatching(ctx)
+	}
+	return t, update(ctx, p.stm, p.store, p.stm.Data(), func(g storage.Graph, d []*triple.Triple) error {
 		trace(p.tracer, func() []string {
 			return []string{"Removing triples from graph \"" + g.ID(ctx) + "\""}
 		})
@@ -228,6 +578,14 @@ func (p *deletePlan) Execute(ctx context.Context) (*table.Table, error) {
 // String returns a readable description of the execution plan.
 func (p *deletePlan) String() string {
 	b := bytes.NewBufferString("DELETE plan:\n\n")
+	if p.pattern != nil {
+		for _, g := range p.stm.GraphNames() {
+			b.WriteString(fmt.Sprintf("store(%q).Graph(%q).RemoveMatching(_, matched)\n", p.store.Name(nil), g))
+		}
+		b.WriteString("where matched:\n")
+		b.WriteString(p.pattern.String())
+		return b.String()
+	}
 	for _, g := range p.stm.Graphs() {
 		b.WriteString(fmt.Sprintf("store(%q).Graph(%q).RemoveTriples(_, data)\n", p.store.Name(nil), g))
 	}
@@ -240,6 +598,25 @@ func (p *deletePlan) String() string {
 	return b.String()
 }
 
+// Explain returns the description of the plan. Deleting data requires no
+// lookups unless the triples are matched by a graph pattern.
+func (p *deletePlan) Explain(ctx context.Context) (string, error) {
+	if p.pattern == nil {
+		return p.String(), nil
+	}
+	e, err := p.pattern.Explain(ctx)
+	if err != nil {
+		return "", err
+	}
+	b := bytes.NewBufferString("DELETE plan:\n\n")
+	for _, g := range p.stm.GraphNames() {
+		b.WriteString(fmt.Sprintf("store(%q).Graph(%q).RemoveMatching(_, matched)\n", p.store.Name(nil), g))
+	}
+	b.WriteString("where matched:\n")
+	b.WriteString(e)
+	return b.String(), nil
+}
+
 // queryPlan encapsulates the sequence of instructions that need to be
 // executed in order to satisfy the execution of a valid query BQL statement.
 type queryPlan struct {
@@ -254,10 +631,59 @@ type queryPlan struct {
 	tbl       *table.Table
 	chanSize  int
 	tracer    io.Writer
+	// branch is the UNION branch resolved by the plan; 0 if the graph pattern
+	// is not a UNION.
+	branch int
+	// workers bounds the number of workers the parallel operators of the plan
+	// run concurrently. It is shared by all the sub plans.
+	workers semaphore
+	// rowLimit and rowOffset contain the limit and offset of the current
+	// execution once the statement parameters are bound.
+	rowLimit  int64
+	rowOffset int64
+	// filterEvals contains the evaluators of the filters with parameters once
+	// the values of the current execution are bound.
+	filterEvals map[*semantic.FilterClause]semantic.Evaluator
+	// rowCap is the number of rows the clause being resolved needs to provide
+	// to satisfy the statement limit; 0 if all its rows are needed.
+	rowCap int64
+	// maxRows is the maximum number of rows the intermediate tables of the plan
+	// may hold; 0 if they are not bounded.
+	maxRows int64
+	// merged contains the triples matched by the per graph plans of a sorted
+	// merge resolved so far, hence a triple present on several graphs is only
+	// matched once; nil if the plan is not merged or uses multiset graphs.
+	merged map[string]bool
+}
+
+// semaphore bounds the number of goroutines running concurrently.
+type semaphore chan struct{}
+
+// acquire blocks until a slot is available.
+func (s semaphore) acquire() {
+	s <- struct{}{}
+}
+
+// release frees a slot previously acquired.
+func (s semaphore) release() {
+	<-s
+}
+
+// tryAcquire acquires up to n slots without blocking and returns the number of
+// slots acquired.
+func (s semaphore) tryAcquire(n int) int {
+	for i := 0; i < n; i++ {
+		select {
+		case s <- struct{}{}:
+		default:
+			return i
+		}
+	}
+	return n
 }
 
 // newQueryPlan returns a new query plan ready to be executed.
-func newQueryPlan(ctx context.Context, store storage.Store, stm *semantic.Statement, chanSize int, w io.Writer) (*queryPlan, error) {
+func newQueryPlan(ctx context.Context, store storage.Store, stm *semantic.Statement, chanSize, budget int, maxRows int64, w io.Writer) (*queryPlan, error) {
 	bs := []string{}
 	for _, b := range stm.Bindings() {
 		bs = append(bs, b)
@@ -266,6 +692,12 @@ func newQueryPlan(ctx context.Context, store storage.Store, stm *semantic.Statem
 	if err != nil {
 		return nil, err
 	}
+	if err := checkUnionBindings(stm); err != nil {
+		return nil, err
+	}
+	if err := checkPathClauses(stm); err != nil {
+		return nil, err
+	}
 	return &queryPlan{
 		stm:       stm,
 		store:     store,
@@ -275,20 +707,76 @@ func newQueryPlan(ctx context.Context, store storage.Store, stm *semantic.Statem
 		tbl:       t,
 		chanSize:  chanSize,
 		tracer:    w,
+		workers:   make(semaphore, budget),
+		maxRows:   maxRows,
 	}, nil
 }
 
+// checkRows returns an error if an intermediate table holding the provided
+// number of rows exceeds the row limit of the plan.
+func (p *queryPlan) checkRows(n int64) error {
+	if p.maxRows > 0 && n > p.maxRows {
+		return fmt.Errorf("planner.Execute: result too large; the query needs %d intermediate rows, which exceeds the limit of %d rows", n, p.maxRows)
+	}
+	return nil
+}
+
+// branchBindings returns the bindings of the graph clauses of the provided
+// UNION branch.
+func branchBindings(stm *semantic.Statement, branch int) map[string]bool {
+	bm := make(map[string]bool)
+	for _, cls := range stm.SortedGraphPatternClauses() {
+		if cls.Branch != branch {
+			continue
+		}
+		for _, b := range cls.Bindings() {
+			bm[b] = true
+		}
+	}
+	return bm
+}
+
+// checkUnionBindings checks that all the branches of a UNION graph pattern
+// provide the bindings used by the rest of the statement. Other bindings may
+// differ across branches since they are dropped by the projection. Statements
+// constructing triples skip the templates whose bindings are not available on
+// a row, hence their branches are free to provide different bindings.
+func checkUnionBindings(stm *semantic.Statement) error {
+	if len(stm.ConstructClauses()) > 0 {
+		return nil
+	}
+	for i := 1; i <= stm.UnionBranches(); i++ {
+		bm := branchBindings(stm, i)
+		for _, b := range stm.InputBindings() {
+			if !bm[b] {
+				return fmt.Errorf("UNION branch %d does not provide the binding %s used by the query", i, b)
+			}
+		}
+	}
+	return nil
+}
+
 // processClause retrieves the triples for the provided triple given the
 // information available.
 func (p *queryPlan) processClause(ctx context.Context, cls *semantic.GraphClause, lo *storage.LookupOptions) (bool, error) {
 	// This method decides how to process the clause based on the current
 	// list of bindings solved and data available.
-	if cls.Specificity() == 3 {
+	if len(cls.PAlternatives) > 0 {
+		return p.processAlternatives(ctx, cls, lo)
+	}
+	if cls.Specificity() == 3 && cls.PPath != semantic.SingleStep && cls.GBinding == "" {
+		// Fully specified paths only check the object is reachable.
+		b, err := pathExist(ctx, p.grfs, cls, lo, p.chanSize)
+		return !b, err
+	}
+	_, folded := foldedObject(cls)
+	filtered := folded || hasNodeTypes(cls)
+	if cls.Specificity() == 3 && !filtered && cls.GBinding == "" {
 		t, err := triple.New(cls.S, cls.P, cls.O)
 		if err != nil {
 			return false, err
 		}
-		b, tbl, err := simpleExist(ctx, p.grfs, cls, t)
+		b, tbl, err := simpleExist(ctx, p.grfs, cls, t, p.uniqueTriples(p.grfs, cls))
 		if err != nil {
 			return false, err
 		}
@@ -307,15 +795,35 @@ func (p *queryPlan) processClause(ctx context.Context, cls *semantic.GraphClause
 	if exist == 0 {
 		// Data is new.
 		stmLimit := int64(0)
-		if len(p.stm.GraphPatternClauses()) == 1 && len(p.stm.GroupBy()) == 0 && len(p.stm.HavingExpression()) == 0 {
-			stmLimit = p.stm.Limit()
+		if p.canPushLimitDown() {
+			stmLimit = p.rowLimit + p.rowOffset
 		}
-		tbl, err := simpleFetch(ctx, p.grfs, cls, lo, stmLimit, p.chanSize)
+		tbl, err := simpleFetch(ctx, p.grfs, cls, lo, stmLimit, p.chanSize, p.uniqueTriples(p.grfs, cls))
 		if err != nil {
 			return false, err
 		}
 		if len(p.tbl.Bindings()) > 0 {
-			return false, p.tbl.DotProduct(tbl)
+			if n := int64(tbl.NumRows()); p.rowCap > 0 && n > 0 {
+				// Only the rows merged with the first rows of the table are needed.
+				p.tbl.Limit((p.rowCap + n - 1) / n)
+			}
+		}
+		if err := p.checkRows(int64(tbl.NumRows()) + int64(p.tbl.NumRows())); err != nil {
+			return false, err
+		}
+		if len(p.tbl.Bindings()) > 0 {
+			// The product is checked before building it, hence oversized products
+			// fail without allocating their rows.
+			if err := p.checkRows(int64(p.tbl.NumRows()) * int64(tbl.NumRows())); err != nil {
+				return false, err
+			}
+			if err := p.dotProduct(ctx, tbl); err != nil {
+				return false, err
+			}
+			if p.rowCap > 0 {
+				p.tbl.Limit(p.rowCap)
+			}
+			return false, nil
 		}
 		return false, p.tbl.AppendTable(tbl)
 	}
@@ -328,7 +836,7 @@ func (p *queryPlan) processClause(ctx context.Context, cls *semantic.GraphClause
 		// Since all bindings in the clause are already solved, the clause becomes a
 		// fully specified triple. If the triple does not exist the row will be
 		// deleted.
-		if cls.PTemporal && cls.PID != "" {
+		if (cls.PTemporal && cls.PID != "") || cls.PIDPrefix || cls.PPath != semantic.SingleStep || filtered {
 			return false, p.specifyClauseWithTable(ctx, cls, lo)
 		}
 		return false, p.filterOnExistence(ctx, cls, lo)
@@ -337,6 +845,21 @@ func (p *queryPlan) processClause(ctx context.Context, cls *semantic.GraphClause
 	return false, fmt.Errorf("queryPlan.processClause(%v) should have never failed to resolve the clause", cls)
 }
 
+// dotProduct joins the rows of the plan table with the ones of the provided
+// table, whose bindings are disjoint. The rows of the plan table are
+// partitioned among as many workers as slots are available on the plan budget
+// at the time; if none is available the product is computed serially. The
+// resulting rows are in the same order as the serial product.
+func (p *queryPlan) dotProduct(ctx context.Context, tbl *table.Table) error {
+	n := p.workers.tryAcquire(cap(p.workers))
+	defer func() {
+		for i := 0; i < n; i++ {
+			p.workers.release()
+		}
+	}()
+	return p.tbl.DotProductConcurrently(ctx, tbl, n)
+}
+
 // getBoundValueForComponent return the unique bound value if available on
 // the provided row.
 func getBoundValueForComponent(r table.Row, bs []string) *table.Cell {
@@ -352,9 +875,9 @@ func getBoundValueForComponent(r table.Row, bs []string) *table.Cell {
 	return nil
 }
 
-// addSpecifiedData specializes the clause given the row provided and attempt to
+// specifiedData specializes the clause given the row provided and attempt to
 // retrieve the corresponding clause data.
-func (p *queryPlan) addSpecifiedData(ctx context.Context, r table.Row, cls *semantic.GraphClause, lo *storage.LookupOptions) error {
+func (p *queryPlan) specifiedData(ctx context.Context, r table.Row, cls *semantic.GraphClause, lo *storage.LookupOptions) (*table.Table, error) {
 	if cls.S == nil {
 		v := getBoundValueForComponent(r, []string{cls.SBinding, cls.SAlias})
 		if v != nil {
@@ -372,7 +895,7 @@ func (p *queryPlan) addSpecifiedData(ctx context.Context, r table.Row, cls *sema
 		}
 		nlo, err := updateTimeBoundsForRow(lo, cls, r)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		lo = nlo
 	}
@@ -386,36 +909,77 @@ func (p *queryPlan) addSpecifiedData(ctx context.Context, r table.Row, cls *sema
 		}
 		nlo, err := updateTimeBoundsForRow(lo, cls, r)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		lo = nlo
 	}
 	stmLimit := int64(0)
-	if len(p.stm.GraphPatternClauses()) == 1 && len(p.stm.GroupBy()) == 0 && len(p.stm.HavingExpression()) == 0 {
-		stmLimit = p.stm.Limit()
+	if p.canPushLimitDown() {
+		stmLimit = p.rowLimit + p.rowOffset
 	}
-	tbl, err := simpleFetch(ctx, p.grfs, cls, lo, stmLimit, p.chanSize)
-	if err != nil {
-		return err
-	}
-	p.tbl.AddBindings(tbl.Bindings())
-	for _, nr := range tbl.Rows() {
-		p.tbl.AddRow(table.MergeRows([]table.Row{r, nr}))
-	}
-	return nil
+	gs := graphsForRow(ctx, p.grfs, cls, r)
+	return simpleFetch(ctx, gs, cls, lo, stmLimit, p.chanSize, p.uniqueTriples(gs, cls))
 }
 
 // specifyClauseWithTable runs the clause, but it specifies it further based on
-// the current row being processed.
+// the current row being processed. Rows are specified in parallel by workers
+// bounded by the plan budget, and the resulting rows are added in the order of
+// the rows they extend. If the clause only needs to provide a few rows, rows
+// are specified in batches as large as the budget until enough rows are
+// available.
 func (p *queryPlan) specifyClauseWithTable(ctx context.Context, cls *semantic.GraphClause, lo *storage.LookupOptions) error {
 	rws := p.tbl.Rows()
 	p.tbl.Truncate()
-	for _, r := range rws {
-		tmpCls := &semantic.GraphClause{}
-		*tmpCls = *cls
-		if err := p.addSpecifiedData(ctx, r, tmpCls, lo); err != nil {
+	var (
+		wg   sync.WaitGroup
+		tbls = make([]*table.Table, len(rws))
+		errs = make([]error, len(rws))
+	)
+	batch := len(rws)
+	if p.rowCap > 0 && cap(p.workers) > 0 {
+		batch = cap(p.workers)
+	}
+	done, cnt := 0, int64(0)
+	for done < len(rws) && (p.rowCap <= 0 || cnt < p.rowCap) {
+		end := done + batch
+		if end > len(rws) {
+			end = len(rws)
+		}
+		for i := done; i < end; i++ {
+			if ctx.Err() != nil {
+				// Stop specifying rows; the context error is returned below.
+				break
+			}
+			tmpCls := &semantic.GraphClause{}
+			*tmpCls = *cls
+			p.workers.acquire()
+			wg.Add(1)
+			go func(i int, r table.Row, cls *semantic.GraphClause) {
+				defer wg.Done()
+				defer p.workers.release()
+				tbls[i], errs[i] = p.specifiedData(ctx, r, cls, lo)
+			}(i, rws[i], tmpCls)
+		}
+		wg.Wait()
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for i := done; i < end; i++ {
+			if errs[i] != nil {
+				return errs[i]
+			}
+			cnt += int64(tbls[i].NumRows())
+		}
+		if err := p.checkRows(cnt); err != nil {
 			return err
 		}
+		done = end
+	}
+	for i, r := range rws[:done] {
+		p.tbl.AddBindings(tbls[i].Bindings())
+		for _, nr := range tbls[i].Rows() {
+			p.tbl.AddRow(table.MergeRows([]table.Row{r, nr}))
+		}
 	}
 	return nil
 }
@@ -450,6 +1014,9 @@ func (p *queryPlan) filterOnExistence(ctx context.Context, cls *semantic.GraphCl
 	data := p.tbl.Rows()
 	p.tbl.Truncate()
 	for _, r := range data {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		sbj, prd, obj := cls.S, cls.P, cls.O
 		// Attempt to rebind the subject.
 		if sbj == nil && p.tbl.HasBinding(cls.SBinding) {
@@ -524,7 +1091,7 @@ func (p *queryPlan) filterOnExistence(ctx context.Context, cls *semantic.GraphCl
 			return fmt.Errorf("failed to fully specify clause %v for row %+v", cls, r)
 		}
 		exist := false
-		for _, g := range p.stm.Graphs() {
+		for _, g := range graphsForRow(ctx, p.stm.Graphs(), cls, r) {
 			t, err := triple.New(sbj, prd, obj)
 			if err != nil {
 				return err
@@ -540,43 +1107,898 @@ func (p *queryPlan) filterOnExistence(ctx context.Context, cls *semantic.GraphCl
 		}
 		if exist {
 			p.tbl.AddRow(r)
+			if p.rowCap > 0 && int64(p.tbl.NumRows()) >= p.rowCap {
+				break
+			}
 		}
 	}
 	return nil
 }
 
+// traceStart returns the time a traced step starts at. Plans without a tracer
+// return the zero time, hence they do not pay for reading the clock.
+func (p *queryPlan) traceStart() time.Time {
+	if p.tracer == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// traceStep traces the number of rows of the table before and after the step
+// along with the time it took since it started.
+func (p *queryPlan) traceStep(step string, in int, start time.Time) {
+	trace(p.tracer, func() []string {
+		return []string{fmt.Sprintf("%s: rows_in=%d rows_out=%d elapsed=%v", step, in, p.tbl.NumRows(), time.Since(start))}
+	})
+}
+
 // processGraphPattern process the query graph pattern to retrieve the
-// data from the specified graphs.
+// data from the specified graphs. Filters are applied as soon as all their
+// bindings are available to keep the intermediate tables small.
 func (p *queryPlan) processGraphPattern(ctx context.Context, lo *storage.LookupOptions) error {
-	for _, cls := range p.cls {
-		trace(p.tracer, func() []string {
-			return []string{"Processing graph clause " + cls.String()}
+	var fs []*semantic.FilterClause
+	for _, f := range p.stm.Filters() {
+		if f.Branch() == p.branch {
+			fs = append(fs, f)
+		}
+	}
+	for i, cls := range p.cls {
+		// Abort as soon as the context is done instead of resolving the
+		// remaining clauses.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		trace(p.tracer, func() []string {
+			return []string{"Processing graph clause " + cls.String()}
 		})
-		// The current planner is based on naively executing clauses by
-		// specificity.
+		if n, ok := p.rowsNeeded(); ok && i == len(p.cls)-1 && i > 0 {
+			// Rows provided by the last clause are already part of the result.
+			trace(p.tracer, func() []string {
+				return []string{"Stop resolving the clause once " + strconv.Itoa(int(n)) + " rows are available"}
+			})
+			p.rowCap = n
+			defer func() {
+				p.rowCap = 0
+			}()
+		}
+		// Clauses are executed in the order picked when the plan started,
+		// either by estimated cardinality or by specificity.
+		in, start := p.tbl.NumRows(), p.traceStart()
 		unresolvable, err := p.processClause(ctx, cls, lo)
 		if err != nil {
 			return err
 		}
+		// The clause is only serialized if the plan is traced.
+		resolved := func() {
+			if p.tracer != nil {
+				p.traceStep("Resolved graph clause "+cls.String(), in, start)
+			}
+		}
 		if unresolvable {
 			p.tbl.Truncate()
+			resolved()
+			return nil
+		}
+		if fs, err = p.filterRows(fs, false); err != nil {
+			return err
+		}
+		resolved()
+	}
+	_, err := p.filterRows(fs, true)
+	return err
+}
+
+// processUnion resolves each branch of the UNION graph pattern independently
+// and concatenates the resulting tables.
+func (p *queryPlan) processUnion(ctx context.Context, lo *storage.LookupOptions) error {
+	for i := 1; i <= p.stm.UnionBranches(); i++ {
+		trace(p.tracer, func() []string {
+			return []string{fmt.Sprintf("Processing UNION branch %d", i)}
+		})
+		t, err := table.New([]string{})
+		if err != nil {
+			return err
+		}
+		bp := &queryPlan{
+			stm:       p.stm,
+			store:     p.store,
+			bndgs:     p.bndgs,
+			grfsNames: p.grfsNames,
+			grfs:      p.grfs,
+			tbl:       t,
+			chanSize:  p.chanSize,
+			tracer:    p.tracer,
+			branch:    i,
+			workers:   p.workers,
+			rowLimit:  p.rowLimit,
+			rowOffset: p.rowOffset,
+			maxRows:   p.maxRows,
+
+			filterEvals: p.filterEvals,
+		}
+		for _, cls := range p.cls {
+			if cls.Branch == i {
+				bp.cls = append(bp.cls, cls)
+			}
+		}
+		if err := bp.processGraphPattern(ctx, lo); err != nil {
+			return err
+		}
+		if err := bp.filterNegatedClauses(ctx, lo); err != nil {
+			return err
+		}
+		if err := p.checkRows(int64(p.tbl.NumRows()) + int64(bp.tbl.NumRows())); err != nil {
+			return err
+		}
+		p.tbl.AddBindings(bp.tbl.Bindings())
+		for _, r := range bp.tbl.Rows() {
+			p.tbl.AddRow(r)
+		}
+	}
+	return nil
+}
+
+// filterRows drops the rows that do not satisfy the provided filters. Filters
+// are only applied if all their bindings are available on the table, unless
+// all is true. It returns the filters that were not applied.
+func (p *queryPlan) filterRows(fs []*semantic.FilterClause, all bool) ([]*semantic.FilterClause, error) {
+	var pending []*semantic.FilterClause
+	for _, f := range fs {
+		ready := true
+		for _, b := range f.Bindings() {
+			if !p.tbl.HasBinding(b) {
+				ready = false
+				break
+			}
+		}
+		if !ready && !all {
+			pending = append(pending, f)
+			continue
+		}
+		trace(p.tracer, func() []string {
+			return []string{"Filtering rows using " + f.String()}
+		})
+		eval := f.Evaluator()
+		if e, ok := p.filterEvals[f]; ok {
+			eval = e
+		}
+		var eErr error
+		p.tbl.Filter(func(r table.Row) bool {
+			if eErr != nil {
+				return true
+			}
+			b, err := eval.Evaluate(r)
+			if err != nil {
+				eErr = err
+			}
+			return !b
+		})
+		if eErr != nil {
+			return nil, eErr
+		}
+	}
+	return pending, nil
+}
+
+// uniqueTriples returns the set used to only match once the triples of the
+// provided clause present on several of the provided graphs, or nil if every
+// triple needs to be matched. Triples present on several graphs provide a row
+// per graph if the clause binds the graph they were read from.
+func (p *queryPlan) uniqueTriples(gs []storage.Graph, cls *semantic.GraphClause) map[string]bool {
+	if cls.GBinding != "" {
+		return nil
+	}
+	if p.merged != nil {
+		return p.merged
+	}
+	return uniqueTriples(gs, p.stm.IsMultisetGraphs())
+}
+
+// canMergeSortedGraphs returns true if the query results can be computed
+// independently for each graph, sorted, and then k-way merged into the
+// globally sorted result. That only holds for single clause patterns without
+// group by, having clauses, or aggregations, since aggregated values, like
+// running aggregations, depend on the rows of all the graphs. Storage drivers
+// do not stream triples in the requested order, hence each per graph result
+// is sorted before merging.
+func (p *queryPlan) canMergeSortedGraphs() bool {
+	return len(p.grfsNames) > 1 && len(p.stm.GraphPatternClauses()) == 1 && p.stm.GraphPatternClauses()[0].PPath == semantic.SingleStep && len(p.stm.GroupBy()) == 0 &&
+		len(p.stm.HavingExpression()) == 0 && len(p.stm.OrderByConfig()) > 0 && !p.stm.Describe().HasAggregations
+}
+
+// countsPredicates returns true if the query counts the predicates of the
+// graphs, which only requires the number of triples of each predicate instead
+// of resolving a graph pattern.
+func (p *queryPlan) countsPredicates() bool {
+	return p.stm.FrequenciesBinding() != "" && len(p.stm.GraphPatternClauses()) == 0
+}
+
+// processAndMergeGraphs resolves and projects the graph pattern independently
+// for each graph, sorts each per graph result, and k-way merges the sorted
+// streams into the final table. Graphs are resolved in order, and unless the
+// statement uses multiset graphs the triples already matched on a previous
+// graph are dropped, hence each triple is only matched once as it would
+// without merging. Dropping them requires resolving the graphs in sequence,
+// hence every per graph table is fully built before the merge starts.
+func (p *queryPlan) processAndMergeGraphs(ctx context.Context, lo *storage.LookupOptions) error {
+	order := p.stm.OrderByConfig()
+	trace(p.tracer, func() []string {
+		return []string{fmt.Sprintf("Merging the results of %d graphs ordered by %s", len(p.grfs), order)}
+	})
+	done := make(chan struct{})
+	defer close(done)
+	var merged map[string]bool
+	if !p.stm.IsMultisetGraphs() {
+		merged = make(map[string]bool)
+	}
+	var ins []<-chan table.Row
+	for _, g := range p.grfs {
+		t, err := table.New([]string{})
+		if err != nil {
+			return err
+		}
+		gp := *p
+		gp.grfs, gp.tbl, gp.merged = []storage.Graph{g}, t, merged
+		if err := gp.processGraphPattern(ctx, lo); err != nil {
+			return err
+		}
+		// Negated clauses must not match on any of the graphs.
+		gp.grfs, gp.merged = p.grfs, nil
+		if err := gp.filterNegatedClauses(ctx, lo); err != nil {
+			return err
+		}
+		if err := gp.projectAndGroupBy(ctx); err != nil {
+			return err
+		}
+		gp.tbl.Sort(order)
+		rows := make(chan table.Row, p.chanSize)
+		go func(rs []table.Row) {
+			defer close(rows)
+			for _, r := range rs {
+				select {
+				case rows <- r:
+				case <-done:
+					return
+				}
+			}
+		}(gp.tbl.Rows())
+		ins = append(ins, rows)
+	}
+	tbl, err := table.New(p.stm.OutputBindings())
+	if err != nil {
+		return err
+	}
+	out := make(chan table.Row, p.chanSize)
+	go table.MergeSorted(done, order, ins, out)
+	for r := range out {
+		tbl.AddRow(r)
+	}
+	p.tbl = tbl
+	return nil
+}
+
+// objectType returns the type of the provided object. Nodes return their
+// node type, literals their literal type, and predicates "predicate".
+func objectType(o *triple.Object) string {
+	if n, err := o.Node(); err == nil {
+		return n.Type().String()
+	}
+	if l, err := o.Literal(); err == nil {
+		return l.Type().String()
+	}
+	return "predicate"
+}
+
+// processSchema scans the graphs and populates the table with the observed
+// domain and range for each of the predicates.
+func (p *queryPlan) processSchema(ctx context.Context, lo *storage.LookupOptions) error {
+	trace(p.tracer, func() []string {
+		return []string{fmt.Sprintf("Scanning graphs %v for predicates %v", p.grfsNames, p.stm.SchemaPredicates())}
+	})
+	pids := make(map[predicate.ID]bool)
+	for _, id := range p.stm.SchemaPredicates() {
+		pids[id] = true
+	}
+	seen := make(map[string]table.Row)
+	for _, g := range p.grfs {
+		var (
+			tErr error
+			wg   sync.WaitGroup
+		)
+		ts := make(chan *triple.Triple, p.chanSize)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tErr = g.Triples(ctx, lo, ts)
+		}()
+		for t := range ts {
+			id := t.Predicate().ID()
+			if len(pids) > 0 && !pids[id] {
+				continue
+			}
+			pid, dom, rng := strconv.Quote(string(id)), t.Subject().Type().String(), objectType(t.Object())
+			k := pid + "\t" + dom + "\t" + rng
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			seen[k] = table.Row{
+				semantic.SchemaBindings[0]: &table.Cell{S: table.CellString(pid)},
+				semantic.SchemaBindings[1]: &table.Cell{S: table.CellString(dom)},
+				semantic.SchemaBindings[2]: &table.Cell{S: table.CellString(rng)},
+			}
+		}
+		wg.Wait()
+		if tErr != nil {
+			return tErr
+		}
+	}
+	t, err := table.New(semantic.SchemaBindings)
+	if err != nil {
+		return err
+	}
+	for _, r := range seen {
+		t.AddRow(r)
+	}
+	cfg := table.SortConfig{}
+	for _, b := range semantic.SchemaBindings {
+		cfg = append(cfg, table.SortConfig{{Binding: b}}...)
+	}
+	t.Sort(cfg)
+	p.tbl = t
+	return nil
+}
+
+// processPredicateFrequencies populates the table with the number of triples
+// of each predicate in the graphs. A triple present on several graphs is only
+// counted once, unless the statement keeps one copy per graph. Graphs
+// implementing storage.PredicateCounter provide the counts out of their
+// indexes if no triple needs to be deduplicated; the triples of any other
+// graph are scanned.
+func (p *queryPlan) processPredicateFrequencies(ctx context.Context, lo *storage.LookupOptions) error {
+	trace(p.tracer, func() []string {
+		return []string{fmt.Sprintf("Counting the predicates of graphs %v", p.grfsNames)}
+	})
+	preds, cnts := make(map[string]*predicate.Predicate), make(map[string]int64)
+	seen := uniqueTriples(p.grfs, p.stm.IsMultisetGraphs())
+	for _, g := range p.grfs {
+		if pc, ok := g.(storage.PredicateCounter); ok && seen == nil {
+			pcs, err := pc.PredicateCounts(ctx)
+			if err != nil {
+				return err
+			}
+			for _, c := range pcs {
+				k := c.P.String()
+				preds[k] = c.P
+				cnts[k] += int64(c.Count)
+			}
+			continue
+		}
+		var (
+			tErr error
+			wg   sync.WaitGroup
+		)
+		ts := make(chan *triple.Triple, p.chanSize)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tErr = g.Triples(ctx, lo, ts)
+		}()
+		for t := range ts {
+			if seen != nil {
+				id := string(t.UUID())
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+			}
+			k := t.Predicate().String()
+			preds[k] = t.Predicate()
+			cnts[k]++
+		}
+		wg.Wait()
+		if tErr != nil {
+			return tErr
+		}
+	}
+	b := p.stm.FrequenciesBinding()
+	t, err := table.New([]string{b, semantic.FrequencyCountBinding})
+	if err != nil {
+		return err
+	}
+	for k, pred := range preds {
+		l, err := literal.DefaultBuilder().Build(literal.Int64, cnts[k])
+		if err != nil {
+			return err
+		}
+		t.AddRow(table.Row{
+			b:                              &table.Cell{P: pred},
+			semantic.FrequencyCountBinding: &table.Cell{L: l},
+		})
+	}
+	p.tbl = t
+	return nil
+}
+
+// processLatest populates the table with the newest triples of each subject
+// for the predicate of a latest query. Graphs implementing storage.LatestLister
+// answer out of their time index. The latest triples of each graph are merged
+// keeping the newest ones, hence rows are returned in the order
+// storage.LatestLister describes.
+func (p *queryPlan) processLatest(ctx context.Context, lo *storage.LookupOptions) error {
+	b, id, n := p.stm.LatestQuery()
+	var ts []*triple.Triple
+	for _, g := range p.grfs {
+		gts, err := storage.LatestTriples(ctx, g, string(id), int(n), lo)
+		if err != nil {
+			return err
+		}
+		ts = append(ts, gts...)
+	}
+	if len(p.grfs) > 1 {
+		ts = storage.KeepLatest(ts, int(n))
+	}
+	t, err := table.New([]string{b, semantic.LatestPredicateBinding, semantic.LatestObjectBinding})
+	if err != nil {
+		return err
+	}
+	for _, trpl := range ts {
+		oc, err := objectToCell(trpl.Object())
+		if err != nil {
+			return err
+		}
+		t.AddRow(table.Row{
+			b:                               &table.Cell{N: trpl.Subject()},
+			semantic.LatestPredicateBinding: &table.Cell{P: trpl.Predicate()},
+			semantic.LatestObjectBinding:    oc,
+		})
+	}
+	p.tbl = t
+	return nil
+}
+
+// bindClauseToRow returns a copy of the provided clause where the subject,
+// predicate, and object have been bound to the values available in the row.
+// It also returns the bindings that were replaced by a value of the row. If a
+// value in the row cannot be used in the position the binding occupies, the
+// clause cannot match the row and false is returned.
+func bindClauseToRow(cls *semantic.GraphClause, r table.Row) (*semantic.GraphClause, map[string]bool, bool) {
+	nc, bound := &semantic.GraphClause{}, make(map[string]bool)
+	*nc = *cls
+	markBound := func(bs ...string) {
+		for _, b := range bs {
+			if b != "" {
+				bound[b] = true
+			}
+		}
+	}
+	if nc.S == nil {
+		if v := getBoundValueForComponent(r, []string{nc.SBinding, nc.SAlias}); v != nil {
+			if v.N == nil {
+				return nil, nil, false
+			}
+			nc.S = v.N
+			markBound(nc.SBinding, nc.SAlias)
+		}
+	}
+	if nc.P == nil {
+		if v := getBoundValueForComponent(r, []string{nc.PBinding, nc.PAlias}); v != nil {
+			if v.P == nil {
+				return nil, nil, false
+			}
+			nc.P = v.P
+			markBound(nc.PBinding, nc.PAlias)
+		}
+	}
+	if nc.O == nil {
+		if v := getBoundValueForComponent(r, []string{nc.OBinding, nc.OAlias}); v != nil {
+			o, err := cellToObject(v)
+			if err != nil {
+				return nil, nil, false
+			}
+			nc.O = o
+			markBound(nc.OBinding, nc.OAlias)
+		}
+	}
+	return nc, bound, true
+}
+
+// equalCells returns true if both cells hold the same value. Time anchors are
+// compared as instants regardless of their location.
+func equalCells(c1, c2 *table.Cell) bool {
+	if c1.T != nil && c2.T != nil {
+		return c1.T.Equal(*c2.T)
+	}
+	return reflect.DeepEqual(c1, c2)
+}
+
+// compatibleRows returns true if the provided rows agree on the values of all
+// the bindings they share.
+func compatibleRows(r1, r2 table.Row) bool {
+	for k, v := range r1 {
+		if ov, ok := r2[k]; ok && !equalCells(v, ov) {
+			return false
+		}
+	}
+	return true
+}
+
+// negatedClauseMatches returns true if at least one triple on the graphs
+// matches the negated clause once bound to the provided row.
+func (p *queryPlan) negatedClauseMatches(ctx context.Context, cls *semantic.GraphClause, r table.Row, lo *storage.LookupOptions) (bool, error) {
+	if len(cls.PAlternatives) > 0 {
+		return p.alternativeMatches(ctx, cls, r, lo)
+	}
+	nc, bound, ok := bindClauseToRow(cls, r)
+	if !ok {
+		return false, nil
+	}
+	// Bindings available on the row that could not be replaced by a value,
+	// like anchors or TYPE and ID aliases, text literals matching regardless
+	// of case, and node type constraints require filtering the matches.
+	_, filter := foldedObject(nc)
+	filter = filter || hasNodeTypes(nc)
+	for _, b := range nc.Bindings() {
+		if _, ok := r[b]; ok && !bound[b] {
+			filter = true
+			break
+		}
+	}
+	if nc.S != nil && nc.P != nil && nc.O != nil && nc.PPath == semantic.SingleStep && !filter {
+		t, err := triple.New(nc.S, nc.P, nc.O)
+		if err != nil {
+			return false, err
+		}
+		for _, g := range p.grfs {
+			b, err := g.Exist(ctx, t)
+			if err != nil {
+				return false, err
+			}
+			if b {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	// A single triple is enough to prove the negated clause matches. That only
+	// holds if the retrieved triples do not need extra filtering.
+	nlo := *lo
+	if nc.PID == "" && nc.OID == "" && !filter {
+		nlo.MaxElements = 1
+	}
+	tbl, err := simpleFetch(ctx, p.grfs, nc, &nlo, 0, p.chanSize, p.uniqueTriples(p.grfs, nc))
+	if err != nil {
+		return false, err
+	}
+	for _, fr := range tbl.Rows() {
+		if compatibleRows(fr, r) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// filterNegatedClauses removes the rows for which any of the inline negated
+// clauses or NOT EXISTS groups matches. Bindings in the negated clauses are
+// not added to the table.
+func (p *queryPlan) filterNegatedClauses(ctx context.Context, lo *storage.LookupOptions) error {
+	for _, cls := range p.stm.NegatedGraphPatternClauses() {
+		if cls.Branch != p.branch {
+			continue
+		}
+		trace(p.tracer, func() []string {
+			return []string{"Filtering rows using negated graph clause " + cls.String()}
+		})
+		rws := p.tbl.Rows()
+		p.tbl.Truncate()
+		for _, r := range rws {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			b, err := p.negatedClauseMatches(ctx, cls, r, lo)
+			if err != nil {
+				return err
+			}
+			if !b {
+				p.tbl.AddRow(r)
+			}
+		}
+	}
+	return p.filterNotExistsGroups(ctx, lo)
+}
+
+// filterNotExistsGroups removes the rows for which any of the NOT EXISTS
+// groups matches once the bindings shared with the row are replaced by its
+// values. Groups sharing no bindings with the table match or not regardless
+// of the row, hence they are only resolved once.
+func (p *queryPlan) filterNotExistsGroups(ctx context.Context, lo *storage.LookupOptions) error {
+	for _, grp := range p.stm.NotExistsGroups() {
+		if len(grp) == 0 || grp[0].Branch != p.branch || p.tbl.NumRows() == 0 {
+			continue
+		}
+		trace(p.tracer, func() []string {
+			return []string{fmt.Sprintf("Filtering rows using NOT EXISTS group %d", grp[0].NotExists)}
+		})
+		correlated := false
+		for _, cls := range grp {
+			for _, b := range cls.Bindings() {
+				correlated = correlated || p.tbl.HasBinding(b)
+			}
+		}
+		if !correlated {
+			b, err := p.notExistsGroupMatches(ctx, grp, table.Row{}, lo)
+			if err != nil {
+				return err
+			}
+			if b {
+				p.tbl.Truncate()
+			}
+			continue
+		}
+		rws := p.tbl.Rows()
+		p.tbl.Truncate()
+		for _, r := range rws {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			b, err := p.notExistsGroupMatches(ctx, grp, r, lo)
+			if err != nil {
+				return err
+			}
+			if !b {
+				p.tbl.AddRow(r)
+			}
+		}
+	}
+	return nil
+}
+
+// notExistsGroupMatches returns true if the clauses of the NOT EXISTS group
+// match the graphs once bound to the provided row. The bound clauses are
+// resolved from the most to the least specific one as a graph pattern of its
+// own.
+func (p *queryPlan) notExistsGroupMatches(ctx context.Context, grp []*semantic.GraphClause, r table.Row, lo *storage.LookupOptions) (bool, error) {
+	var bcls []*semantic.GraphClause
+	for _, cls := range grp {
+		nc, _, ok := bindClauseToRow(cls, r)
+		if !ok {
+			return false, nil
+		}
+		bcls = append(bcls, nc)
+	}
+	t, err := table.New([]string{})
+	if err != nil {
+		return false, err
+	}
+	gp := &queryPlan{
+		stm:      p.stm,
+		store:    p.store,
+		grfs:     p.grfs,
+		tbl:      t,
+		chanSize: p.chanSize,
+		workers:  p.workers,
+		maxRows:  p.maxRows,
+
+		filterEvals: p.filterEvals,
+	}
+	for sp := 3; sp >= 0; sp-- {
+		for _, cls := range bcls {
+			if cls.Specificity() != sp {
+				continue
+			}
+			unresolvable, err := gp.processClause(ctx, cls, lo)
+			if err != nil {
+				return false, err
+			}
+			if unresolvable {
+				return false, nil
+			}
+		}
+	}
+	if len(gp.tbl.Bindings()) == 0 {
+		// All the clauses were fully specified and exist.
+		return true, nil
+	}
+	// Bindings available on the row that could not be replaced by a value,
+	// like anchors or TYPE and ID aliases, must agree with the matches.
+	for _, fr := range gp.tbl.Rows() {
+		if compatibleRows(fr, r) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkNumericLiterals checks that all the values bound to the projected
+// binding are int64, float64, or decimal literals.
+func checkNumericLiterals(tbl *table.Table, prj *semantic.Projection) error {
+	for _, r := range tbl.Rows() {
+		cell := r[prj.Binding]
+		if cell == nil {
+			return fmt.Errorf("can only %s int64, float64, and decimal literals; found an unbound value instead for binding %q", prj.OP, prj.Binding)
+		}
+		if cell.L == nil {
+			return fmt.Errorf("can only %s int64, float64, and decimal literals; found %s instead for binding %q", prj.OP, cell, prj.Binding)
+		}
+		if t := cell.L.Type(); t != literal.Int64 && t != literal.Float64 && t != literal.Decimal {
+			return fmt.Errorf("can only %s int64, float64, and decimal literals; found literal type %s instead for binding %q", prj.OP, t, prj.Binding)
+		}
+	}
+	return nil
+}
+
+// extractNodeParts binds the alias of each TYPE and ID projection to a text
+// literal containing the type or the id of the node bound to its binding, and
+// the alias of each REIFY projection to the triple reified by the node.
+// Unbound values are left unbound.
+func (p *queryPlan) extractNodeParts(ctx context.Context) error {
+	for _, prj := range p.stm.Projections() {
+		if prj.Extract == lexer.ItemError {
+			continue
+		}
+		trace(p.tracer, func() []string {
+			return []string{"Extracting " + prj.String()}
+		})
+		p.tbl.AddBindings([]string{prj.Alias})
+		reified := make(map[string]*table.Cell)
+		for _, r := range p.tbl.Rows() {
+			if err := p.extractNodePart(ctx, prj, r, reified); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// extractNodePart binds the alias of the TYPE, ID, or REIFY projection on the
+// provided row. The cells of the reified triples are cached by node on the
+// provided map.
+func (p *queryPlan) extractNodePart(ctx context.Context, prj *semantic.Projection, r table.Row, reified map[string]*table.Cell) error {
+	c := r[prj.Binding]
+	if c == nil {
+		delete(r, prj.Alias)
+		return nil
+	}
+	if c.N == nil {
+		return fmt.Errorf("%s can only be applied to nodes; found %s instead for binding %q", prj.Extract, c, prj.Binding)
+	}
+	if prj.Extract == lexer.ItemReify {
+		k := c.N.String()
+		rc, ok := reified[k]
+		if !ok {
+			t, err := reifiedTriple(ctx, p.grfs, c.N, p.chanSize)
+			if err != nil {
+				return err
+			}
+			if rc, err = reifiedTripleCell(t); err != nil {
+				return err
+			}
+			reified[k] = rc
+		}
+		if rc == nil {
+			// Nodes reifying no triple leave the alias unbound.
+			delete(r, prj.Alias)
 			return nil
 		}
+		r[prj.Alias] = rc
+		return nil
+	}
+	v := c.N.ID().String()
+	if prj.Extract == lexer.ItemType {
+		v = c.N.Type().String()
+	}
+	l, err := literal.DefaultBuilder().Build(literal.Text, v)
+	if err != nil {
+		return err
+	}
+	r[prj.Alias] = &table.Cell{L: l}
+	return nil
+}
+
+// coalesceBindings binds the alias of each COALESCE projection to the value of
+// the first of its bindings bound on each row. Values keep the type of the
+// binding they come from, hence an alias may hold both nodes and literals. The
+// alias is left unbound if none of the bindings are bound.
+func (p *queryPlan) coalesceBindings() {
+	for _, prj := range p.stm.Projections() {
+		if len(prj.Coalesce) == 0 {
+			continue
+		}
+		trace(p.tracer, func() []string {
+			return []string{"Coalescing " + prj.String()}
+		})
+		p.tbl.AddBindings([]string{prj.Alias})
+		for _, r := range p.tbl.Rows() {
+			coalesceRow(prj, r)
+		}
+	}
+}
+
+// coalesceRow binds the alias of the COALESCE projection on the provided row.
+func coalesceRow(prj *semantic.Projection, r table.Row) {
+	for _, b := range prj.Coalesce {
+		if c := r[b]; c != nil {
+			r[prj.Alias] = c
+			return
+		}
+	}
+	delete(r, prj.Alias)
+}
+
+// newAccumulator returns the accumulator for the aggregation function of the
+// provided projection. Projections without an aggregation function return a
+// nil accumulator.
+func newAccumulator(tbl *table.Table, prj *semantic.Projection) (table.Accumulator, error) {
+	switch prj.OP {
+	case lexer.ItemCount:
+		if prj.Modifier == lexer.ItemDistinct {
+			return table.NewCountDistinctAccumulator(), nil
+		}
+		return table.NewCountAccumulator(), nil
+	case lexer.ItemSum:
+		if err := checkNumericLiterals(tbl, prj); err != nil {
+			return nil, err
+		}
+		return table.NewSumNumericLiteralAccumulator(), nil
+	case lexer.ItemAvg:
+		if err := checkNumericLiterals(tbl, prj); err != nil {
+			return nil, err
+		}
+		return table.NewAvgFloat64LiteralAccumulator(), nil
+	case lexer.ItemMin:
+		return table.NewMinAccumulator(), nil
+	case lexer.ItemMax:
+		return table.NewMaxAccumulator(), nil
+	}
+	return nil, nil
+}
+
+// runningAggregations adds the running aggregations of the window projections
+// to the table.
+func (p *queryPlan) runningAggregations() error {
+	for _, prj := range p.stm.Projections() {
+		if prj.Window == nil {
+			continue
+		}
+		trace(p.tracer, func() []string {
+			return []string{"Running window aggregation " + prj.String()}
+		})
+		acc, err := newAccumulator(p.tbl, prj)
+		if err != nil {
+			return err
+		}
+		aap := table.AliasAccPair{
+			InAlias:  prj.Binding,
+			OutAlias: prj.Alias,
+			Acc:      acc,
+		}
+		if err := p.tbl.RunningAccumulate(prj.Window.PartitionBy, prj.Window.OrderBy, aap); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
 // projectAndGroupBy takes the resulting table and projects its contents and
 // groups it by if needed.
-func (p *queryPlan) projectAndGroupBy() error {
+func (p *queryPlan) projectAndGroupBy(ctx context.Context) error {
+	if err := p.extractNodeParts(ctx); err != nil {
+		return err
+	}
+	p.coalesceBindings()
 	grp := p.stm.GroupByBindings()
 	if len(grp) == 0 { // The table only needs to be projected.
 		trace(p.tracer, func() []string {
 			return []string{fmt.Sprintf("Running projection for %v", grp)}
 		})
+		if err := p.runningAggregations(); err != nil {
+			return err
+		}
 		p.tbl.AddBindings(p.stm.OutputBindings())
 		// For each row, copy each input binding value to its appropriate alias.
 		for _, prj := range p.stm.Projections() {
+			if prj.Window != nil || prj.Extract != lexer.ItemError || len(prj.Coalesce) > 0 {
+				continue
+			}
 			for _, row := range p.tbl.Rows() {
 				row[prj.Alias] = row[prj.Binding]
 			}
@@ -600,21 +2022,26 @@ func (p *queryPlan) projectAndGroupBy() error {
 		trace(p.tracer, func() []string {
 			return []string{"Analysing projection " + prj.String()}
 		})
-		// Only include used incoming bindings.
-		tmpBindings = append(tmpBindings, prj.Binding)
+		// Only include used incoming bindings. The extracted node parts and the
+		// coalesced bindings are already bound to the alias.
+		in := prj.Binding
+		if prj.Extract != lexer.ItemError || len(prj.Coalesce) > 0 {
+			in = prj.Alias
+		}
+		tmpBindings = append(tmpBindings, in)
 		// Update sorting configuration.
 		found := false
 		for _, g := range p.stm.GroupByBindings() {
-			if prj.Binding == g {
+			if in == g {
 				found = true
 			}
 		}
-		if found && !mapBindings[prj.Binding] {
-			cfg = append(cfg, table.SortConfig{{Binding: prj.Binding}}...)
-			mapBindings[prj.Binding] = true
+		if found && !mapBindings[in] {
+			cfg = append(cfg, table.SortConfig{{Binding: in}}...)
+			mapBindings[in] = true
 		}
 		aap := table.AliasAccPair{
-			InAlias: prj.Binding,
+			InAlias: in,
 		}
 		if prj.Alias == "" {
 			aap.OutAlias = prj.Binding
@@ -622,27 +2049,11 @@ func (p *queryPlan) projectAndGroupBy() error {
 			aap.OutAlias = prj.Alias
 		}
 		// Update accumulators.
-		switch prj.OP {
-		case lexer.ItemCount:
-			if prj.Modifier == lexer.ItemDistinct {
-				aap.Acc = table.NewCountDistinctAccumulator()
-			} else {
-				aap.Acc = table.NewCountAccumulator()
-			}
-		case lexer.ItemSum:
-			cell := p.tbl.Rows()[0][prj.Binding]
-			if cell.L == nil {
-				return fmt.Errorf("cannot only sum int64 and float64 literals; found %s instead for binding %q", cell, prj.Binding)
-			}
-			switch cell.L.Type() {
-			case literal.Int64:
-				aap.Acc = table.NewSumInt64LiteralAccumulator(0)
-			case literal.Float64:
-				aap.Acc = table.NewSumFloat64LiteralAccumulator(0)
-			default:
-				return fmt.Errorf("cannot only sum int64 and float64 literals; found literal type %s instead for binding %q", cell.L.Type(), prj.Binding)
-			}
+		acc, err := newAccumulator(p.tbl, prj)
+		if err != nil {
+			return err
 		}
+		aap.Acc = acc
 		aaps = append(aaps, aap)
 	}
 	trace(p.tracer, func() []string {
@@ -654,8 +2065,7 @@ func (p *queryPlan) projectAndGroupBy() error {
 	trace(p.tracer, func() []string {
 		return []string{"Reducing the table using configuration " + cfg.String()}
 	})
-	p.tbl.Reduce(cfg, aaps)
-	return nil
+	return p.tbl.Reduce(cfg, aaps)
 }
 
 // orderBy takes the resulting table and sorts its contents according to the
@@ -694,18 +2104,165 @@ func (p *queryPlan) having() error {
 	return nil
 }
 
-// limit truncates the table if the limit clause if available.
+// limitApplies returns true if the rows resolved by the graph pattern are only
+// truncated by the statement limit, since no operator sorts, groups, filters,
+// or removes them afterwards. If so, resolving the pattern can stop once
+// enough rows are available.
+func (p *queryPlan) limitApplies() bool {
+	for _, prj := range p.stm.Projections() {
+		if prj.Window != nil || prj.OP != lexer.ItemError {
+			return false
+		}
+	}
+	return p.stm.IsLimitSet() && p.branch == 0 && p.stm.UnionBranches() == 0 && len(p.stm.Filters()) == 0 &&
+		len(p.stm.GroupBy()) == 0 && len(p.stm.HavingExpression()) == 0 && len(p.stm.OrderByConfig()) == 0 &&
+		!p.stm.IsDistinct() && !p.stm.IsWeightedSample() && len(p.stm.NegatedGraphPatternClauses()) == 0 &&
+		len(p.stm.NotExistsGroups()) == 0
+}
+
+// rowsNeeded returns the number of rows the graph pattern needs to provide to
+// satisfy the limit and the offset of the statement. The boolean is false if
+// all the rows are needed.
+func (p *queryPlan) rowsNeeded() (int64, bool) {
+	n := p.rowLimit + p.rowOffset
+	if n <= 0 || !p.limitApplies() {
+		return 0, false
+	}
+	return n, true
+}
+
+// canPushLimitDown returns true if the statement limit can be used while
+// fetching the data of the only clause of the graph pattern.
+func (p *queryPlan) canPushLimitDown() bool {
+	_, ok := p.rowsNeeded()
+	return ok && len(p.stm.GraphPatternClauses()) == 1
+}
+
+// distinct removes the duplicated projected rows if requested. Rows are
+// already ordered, and the first occurrence of each row is kept; hence the
+// order of the results is preserved.
+func (p *queryPlan) distinct() {
+	if !p.stm.IsDistinct() {
+		return
+	}
+	trace(p.tracer, func() []string {
+		return []string{"Removing duplicated rows"}
+	})
+	p.tbl.Distinct()
+}
+
+// limit skips the rows requested by the offset clause and truncates the table
+// if the limit clause if available.
 func (p *queryPlan) limit() {
+	if o := p.rowOffset; o > 0 {
+		trace(p.tracer, func() []string {
+			return []string{"Skip the first " + strconv.Itoa(int(o)) + " results"}
+		})
+		p.tbl.Offset(o)
+	}
 	if p.stm.IsLimitSet() {
 		trace(p.tracer, func() []string {
-			return []string{"Limit results to " + strconv.Itoa(int(p.stm.Limit()))}
+			return []string{"Limit results to " + strconv.Itoa(int(p.rowLimit))}
 		})
-		p.tbl.Limit(p.stm.Limit())
+		p.tbl.Limit(p.rowLimit)
 	}
 }
 
-// Execute queries the indicated graphs.
+// bindParameters sets the limit, the offset, and the filter evaluators of the
+// current execution using the provided parameter values. All the parameters of
+// the statement must be provided; the limit and offset ones must be non
+// negative int64 literals.
+func (p *queryPlan) bindParameters(ps map[string]interface{}) error {
+	known := make(map[string]bool)
+	for _, n := range p.stm.Parameters() {
+		known[n] = true
+	}
+	for n := range ps {
+		if !known[n] {
+			return fmt.Errorf("planner.Execute: unknown parameter %s", n)
+		}
+	}
+	cs := make(map[string]*table.Cell)
+	for _, n := range p.stm.Parameters() {
+		c, err := parameterCell(n, ps[n])
+		if err != nil {
+			return err
+		}
+		cs[n] = c
+	}
+	value := func(n string) (int64, error) {
+		l := cs[n].L
+		if l == nil || l.Type() != literal.Int64 {
+			return 0, fmt.Errorf("planner.Execute: parameter %s requires an int64 value; found %s instead", n, cs[n])
+		}
+		v, err := l.Int64()
+		if err != nil {
+			return 0, err
+		}
+		if v < 0 {
+			return 0, fmt.Errorf("planner.Execute: parameter %s requires a non negative value; found %d instead", n, v)
+		}
+		return v, nil
+	}
+	p.rowLimit, p.rowOffset = p.stm.Limit(), p.stm.Offset()
+	if n := p.stm.LimitParameter(); n != "" {
+		v, err := value(n)
+		if err != nil {
+			return err
+		}
+		p.rowLimit = v
+	}
+	if n := p.stm.OffsetParameter(); n != "" {
+		v, err := value(n)
+		if err != nil {
+			return err
+		}
+		p.rowOffset = v
+	}
+	p.filterEvals = nil
+	for _, f := range p.stm.Filters() {
+		if len(f.Parameters()) == 0 {
+			continue
+		}
+		e, err := f.BindParameters(cs)
+		if err != nil {
+			return fmt.Errorf("planner.Execute: %v", err)
+		}
+		if p.filterEvals == nil {
+			p.filterEvals = make(map[*semantic.FilterClause]semantic.Evaluator)
+		}
+		p.filterEvals[f] = e
+	}
+	return nil
+}
+
+// Execute queries the indicated graphs. It fails if the statement has
+// parameters; use ExecuteWithParameters instead.
 func (p *queryPlan) Execute(ctx context.Context) (*table.Table, error) {
+	return p.ExecuteWithParameters(ctx, nil)
+}
+
+// ExecuteWithParameters queries the indicated graphs binding the provided
+// values to the statement parameters. Plans can be executed multiple times,
+// but not concurrently.
+func (p *queryPlan) ExecuteWithParameters(ctx context.Context, ps map[string]interface{}) (*table.Table, error) {
+	return p.execute(ctx, ps, nil, nil)
+}
+
+// execute runs the plan binding the provided parameter values. If rows is not
+// nil, start is called with the bindings of the resulting rows and then the
+// rows are sent to rows; streamable queries send them as they are projected
+// and return a nil table.
+func (p *queryPlan) execute(ctx context.Context, ps map[string]interface{}, start func([]string) error, rows chan<- table.Row) (*table.Table, error) {
+	if err := p.bindParameters(ps); err != nil {
+		return nil, err
+	}
+	t, err := table.New([]string{})
+	if err != nil {
+		return nil, err
+	}
+	p.tbl = t
+	begin := p.traceStart()
 	// Fetch and catch graph instances.
 	trace(p.tracer, func() []string {
 		return []string{fmt.Sprintf("Caching graph instances for graphs %v", p.stm.GraphNames())}
@@ -714,22 +2271,101 @@ func (p *queryPlan) Execute(ctx context.Context) (*table.Table, error) {
 		return nil, err
 	}
 	p.grfs = p.stm.Graphs()
+	// Order the clauses by their estimated cardinality if the graphs are able
+	// to provide it, otherwise keep the specificity order.
+	cls, ok, err := orderByCardinality(ctx, p.grfs, p.stm.SortedGraphPatternClauses())
+	if err != nil {
+		return nil, err
+	}
+	p.cls = cls
+	if ok {
+		trace(p.tracer, func() []string {
+			var msgs []string
+			for _, c := range p.cls {
+				msgs = append(msgs, "Ordered graph clause by cardinality "+c.String())
+			}
+			return msgs
+		})
+	}
 	// Retrieve the data.
 	lo := p.stm.GlobalLookupOptions()
 	trace(p.tracer, func() []string {
 		return []string{"Setting global lookup options to " + lo.String()}
 	})
-	if err := p.processGraphPattern(ctx, lo); err != nil {
-		return nil, err
+	merge := !p.stm.IsSchemaQuery() && p.canMergeSortedGraphs()
+	counted := p.countsPredicates()
+	latest := p.stm.IsLatestQuery()
+	switch {
+	case p.stm.IsSchemaQuery():
+		if err := p.processSchema(ctx, lo); err != nil {
+			return nil, err
+		}
+	case latest:
+		if err := p.processLatest(ctx, lo); err != nil {
+			return nil, err
+		}
+	case counted:
+		if err := p.processPredicateFrequencies(ctx, lo); err != nil {
+			return nil, err
+		}
+	case p.stm.UnionBranches() > 0:
+		if err := p.processUnion(ctx, lo); err != nil {
+			return nil, err
+		}
+	case merge:
+		if err := p.processAndMergeGraphs(ctx, lo); err != nil {
+			return nil, err
+		}
+	default:
+		if err := p.processGraphPattern(ctx, lo); err != nil {
+			return nil, err
+		}
+		in, nStart := p.tbl.NumRows(), p.traceStart()
+		if err := p.filterNegatedClauses(ctx, lo); err != nil {
+			return nil, err
+		}
+		if len(p.stm.NegatedGraphPatternClauses()) > 0 || len(p.stm.NotExistsGroups()) > 0 {
+			p.traceStep("Filtered negated graph clauses", in, nStart)
+		}
 	}
-	if err := p.projectAndGroupBy(); err != nil {
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-	p.orderBy()
-	err := p.having()
+	if rows != nil && !merge && !latest && !counted && p.streamable() {
+		if err := start(p.stm.OutputBindings()); err != nil {
+			return nil, err
+		}
+		in, sStart := p.tbl.NumRows(), p.traceStart()
+		n, err := p.streamRows(ctx, rows)
+		if err != nil {
+			return nil, err
+		}
+		p.traceStep("Streamed rows", in, sStart)
+		trace(p.tracer, func() []string {
+			return []string{fmt.Sprintf("Executed query: bindings=%v rows=%d elapsed=%v", p.stm.OutputBindings(), n, time.Since(begin))}
+		})
+		return nil, nil
+	}
+	if !merge && !latest {
+		if !counted {
+			in, pStart := p.tbl.NumRows(), p.traceStart()
+			if err := p.projectAndGroupBy(ctx); err != nil {
+				return nil, err
+			}
+			p.traceStep("Projected rows", in, pStart)
+		}
+		if p.stm.IsWeightedSample() {
+			if err := p.weightedSample(); err != nil {
+				return nil, err
+			}
+		}
+		p.orderBy()
+	}
+	err = p.having()
 	if err != nil {
 		return nil, err
 	}
+	p.distinct()
 	p.limit()
 	if p.tbl.NumRows() == 0 {
 		// Correct the bindings.
@@ -739,6 +2375,17 @@ func (p *queryPlan) Execute(ctx context.Context) (*table.Table, error) {
 		}
 		p.tbl = t
 	}
+	trace(p.tracer, func() []string {
+		return []string{fmt.Sprintf("Executed query: bindings=%v rows=%d elapsed=%v", p.tbl.Bindings(), p.tbl.NumRows(), time.Since(begin))}
+	})
+	if rows != nil {
+		if err := start(p.tbl.Bindings()); err != nil {
+			return nil, err
+		}
+		if err := sendRows(ctx, p.tbl, rows); err != nil {
+			return nil, err
+		}
+	}
 	return p.tbl, nil
 }
 
@@ -747,11 +2394,56 @@ func (p *queryPlan) String() string {
 	b := bytes.NewBufferString("QUERY plan:\n\n")
 	b.WriteString("using store(\"")
 	b.WriteString(p.store.Name(nil))
-	b.WriteString(fmt.Sprintf("\") graphs %v\nresolve\n", p.grfsNames))
-	for _, c := range p.cls {
-		b.WriteString("\t")
-		b.WriteString(c.String())
-		b.WriteString("\n")
+	b.WriteString(fmt.Sprintf("\") graphs %v\n", p.grfsNames))
+	if p.stm.IsSchemaQuery() {
+		b.WriteString(fmt.Sprintf("scan schema for predicates %v\n", p.stm.SchemaPredicates()))
+	} else if p.countsPredicates() {
+		b.WriteString("count predicates\n")
+	} else if p.stm.IsLatestQuery() {
+		_, id, n := p.stm.LatestQuery()
+		b.WriteString(fmt.Sprintf("retrieve the latest %d triples per subject of predicate %q\n", n, id))
+	} else {
+		b.WriteString("resolve\n")
+	}
+	for i := 0; i <= p.stm.UnionBranches(); i++ {
+		if i > 0 {
+			b.WriteString(fmt.Sprintf("union branch %d\n", i))
+		}
+		for _, c := range p.cls {
+			if c.Branch != i {
+				continue
+			}
+			b.WriteString("\t")
+			b.WriteString(c.String())
+			b.WriteString("\n")
+		}
+	}
+	if p.stm.IgnoresCase() {
+		b.WriteString("match text literals ignoring case\n")
+	}
+	if fs := p.stm.Filters(); len(fs) > 0 {
+		b.WriteString("filter rows using\n")
+		for _, f := range fs {
+			b.WriteString("\t")
+			b.WriteString(f.String())
+			b.WriteString("\n")
+		}
+	}
+	if ncs := p.stm.NegatedGraphPatternClauses(); len(ncs) > 0 {
+		b.WriteString("filter out rows matching\n")
+		for _, c := range ncs {
+			b.WriteString("\t")
+			b.WriteString(c.String())
+			b.WriteString("\n")
+		}
+	}
+	for _, grp := range p.stm.NotExistsGroups() {
+		b.WriteString("filter out rows matching all of\n")
+		for _, c := range grp {
+			b.WriteString("\t")
+			b.WriteString(c.String())
+			b.WriteString("\n")
+		}
 	}
 	b.WriteString("project results using\n")
 	for _, p := range p.stm.Projection() {
@@ -767,10 +2459,17 @@ func (p *queryPlan) String() string {
 			b.WriteString("\n")
 		}
 	}
+	if p.stm.IsWeightedSample() {
+		_, w, n := p.stm.WeightedSample()
+		b.WriteString(fmt.Sprintf("sample %d rows weighted by %s\n", n, w))
+	}
 	if ob := p.stm.OrderBy(); ob != nil {
 		b.WriteString("order results by ")
 		b.WriteString(ob.String())
 		b.WriteString("\n")
+		if !p.stm.IsSchemaQuery() && p.canMergeSortedGraphs() {
+			b.WriteString("merge the sorted results of each graph\n")
+		}
 	}
 	if hv := p.stm.HavingExpression(); hv != nil {
 		b.WriteString("having projected values\n")
@@ -780,7 +2479,23 @@ func (p *queryPlan) String() string {
 			b.WriteString("\n")
 		}
 	}
-	if p.stm.HasLimit() {
+	if p.stm.IsDistinct() {
+		b.WriteString("remove duplicated rows\n")
+	}
+	if n := p.stm.OffsetParameter(); n != "" {
+		b.WriteString("skip the first ")
+		b.WriteString(n)
+		b.WriteString(" rows\n")
+	} else if p.stm.Offset() > 0 {
+		b.WriteString("skip the first ")
+		b.WriteString(fmt.Sprintf("%d", p.stm.Offset()))
+		b.WriteString(" rows\n")
+	}
+	if n := p.stm.LimitParameter(); n != "" {
+		b.WriteString("limit results to ")
+		b.WriteString(n)
+		b.WriteString(" rows\n")
+	} else if p.stm.HasLimit() {
 		b.WriteString("limit results to ")
 		b.WriteString(fmt.Sprintf("%d", p.stm.Limit()))
 		b.WriteString(" rows\n")
@@ -788,23 +2503,91 @@ func (p *queryPlan) String() string {
 	return b.String()
 }
 
-// New create a new executable plan given a semantic BQL statement.
+// DefaultBudget returns the default concurrency budget of query plans, which
+// matches the number of goroutines that can run simultaneously as reported by
+// GOMAXPROCS.
+func DefaultBudget() int {
+	return runtime.GOMAXPROCS(0)
+}
+
+// New create a new executable plan given a semantic BQL statement. The
+// channel size sets the buffer of the channels the store streams the triples,
+// nodes, and predicates of each lookup through; hence the store may run up to
+// that many values ahead of the planner. 0 uses unbuffered channels, which
+// hand the values over one at a time. If the writer is not nil, the plan
+// writes a timestamped line to it for each step it executes. Query plans use
+// the default concurrency budget, and their intermediate tables are not
+// bounded.
 func New(ctx context.Context, store storage.Store, stm *semantic.Statement, chanSize int, w io.Writer) (Executor, error) {
+	return NewWithBudget(ctx, store, stm, chanSize, DefaultBudget(), w)
+}
+
+// NewWithBudget works like New, but the parallel operators of query plans will
+// never run more than budget workers concurrently. Each worker may still use a
+// few goroutines to stream the data from the store. Non positive budgets use
+// the default one.
+func NewWithBudget(ctx context.Context, store storage.Store, stm *semantic.Statement, chanSize, budget int, w io.Writer) (Executor, error) {
+	return NewWithRowLimit(ctx, store, stm, chanSize, budget, 0, w)
+}
+
+// NewWithRowLimit works like NewWithBudget, but executing the plan fails with
+// a result too large error once any of its intermediate tables would hold more
+// than maxRows rows. The intermediate tables include the rows retrieved for
+// each clause and the joins of the graph pattern, hence Cartesian products are
+// rejected before they are built. Non positive limits do not bound the tables.
+func NewWithRowLimit(ctx context.Context, store storage.Store, stm *semantic.Statement, chanSize, budget, maxRows int, w io.Writer) (Executor, error) {
+	if maxRows < 0 {
+		maxRows = 0
+	}
+	if budget <= 0 {
+		budget = DefaultBudget()
+	}
+	if stm.Type() != semantic.Query && len(stm.Parameters()) > 0 {
+		return nil, errors.New("planner.New: parameters can only be used in queries")
+	}
 	switch stm.Type() {
 	case semantic.Query:
-		return newQueryPlan(ctx, store, stm, chanSize, w)
+		return newQueryPlan(ctx, store, stm, chanSize, budget, int64(maxRows), w)
+	case semantic.Construct:
+		qp, err := newQueryPlan(ctx, store, stm, chanSize, budget, int64(maxRows), w)
+		if err != nil {
+			return nil, err
+		}
+		return &constructPlan{qp: qp}, nil
 	case semantic.Insert:
-		return &insertPlan{
+		ip := &insertPlan{
 			stm:    stm,
 			store:  store,
 			tracer: w,
-		}, nil
+		}
+		if len(stm.ConstructClauses()) > 0 {
+			qp, err := newQueryPlan(ctx, store, stm, chanSize, budget, int64(maxRows), w)
+			if err != nil {
+				return nil, err
+			}
+			ip.construct = &constructPlan{qp: qp}
+		}
+		return ip, nil
 	case semantic.Delete:
-		return &deletePlan{
+		if len(stm.NowData()) > 0 {
+			return nil, errors.New("planner.New: NOW() time anchors can only be used when inserting data")
+		}
+		dp := &deletePlan{
 			stm:    stm,
 			store:  store,
 			tracer: w,
-		}, nil
+		}
+		if len(stm.GraphPatternClauses()) > 0 {
+			if err := checkDeletePattern(stm); err != nil {
+				return nil, err
+			}
+			qp, err := newQueryPlan(ctx, store, stm, chanSize, budget, int64(maxRows), w)
+			if err != nil {
+				return nil, err
+			}
+			dp.pattern = qp
+		}
+		return dp, nil
 	case semantic.Create:
 		return &createPlan{
 			stm:    stm,
@@ -817,6 +2600,29 @@ func New(ctx context.Context, store storage.Store, stm *semantic.Statement, chan
 			store:  store,
 			tracer: w,
 		}, nil
+	case semantic.Truncate:
+		return &truncatePlan{
+			stm:    stm,
+			store:  store,
+			tracer: w,
+		}, nil
+	case semantic.Import:
+		return &importPlan{
+			stm:    stm,
+			store:  store,
+			tracer: w,
+		}, nil
+	case semantic.Show:
+		return &showPlan{
+			store:  store,
+			tracer: w,
+		}, nil
+	case semantic.Describe:
+		return &describePlan{
+			stm:    stm,
+			store:  store,
+			tracer: w,
+		}, nil
 	default:
 		return nil, fmt.Errorf("planner.New: unknown statement type in statement %v", stm)
 	}
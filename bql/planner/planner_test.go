@@ -16,25 +16,49 @@ package planner
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"golang.org/x/net/context"
 
 	"github.com/google/badwolf/bql/grammar"
 	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
 	"github.com/google/badwolf/io"
 	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/bolt"
+	"github.com/google/badwolf/storage/leveldb"
 	"github.com/google/badwolf/storage/memory"
 	"github.com/google/badwolf/triple"
 	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
 )
 
-func insertTest(t *testing.T) {
-	ctx := context.Background()
-	bql := `insert data into ?a {/_<foo> "bar"@[] /_<foo> .
+// insertTestBQL and deleteTestBQL insert and delete the same three triples
+// into and from graph ?a.
+const (
+	insertTestBQL = `insert data into ?a {/_<foo> "bar"@[] /_<foo> .
+                               /_<foo> "bar"@[] "bar"@[1975-01-01T00:01:01.999999999Z] .
+                               /_<foo> "bar"@[] "yeah"^^type:text};`
+	deleteTestBQL = `delete data from ?a {/_<foo> "bar"@[] /_<foo> .
                                /_<foo> "bar"@[] "bar"@[1975-01-01T00:01:01.999999999Z] .
                                /_<foo> "bar"@[] "yeah"^^type:text};`
+)
+
+func insertTest(t *testing.T) {
+	ctx := context.Background()
+	bql := insertTestBQL
 	p, err := grammar.NewParser(grammar.SemanticBQL())
 	if err != nil {
 		t.Errorf("grammar.NewParser: should have produced a valid BQL parser, %v", err)
@@ -71,9 +95,7 @@ func insertTest(t *testing.T) {
 
 func deleteTest(t *testing.T) {
 	ctx := context.Background()
-	bql := `delete data from ?a {/_<foo> "bar"@[] /_<foo> .
-                               /_<foo> "bar"@[] "bar"@[1975-01-01T00:01:01.999999999Z] .
-                               /_<foo> "bar"@[] "yeah"^^type:text};`
+	bql := deleteTestBQL
 	p, err := grammar.NewParser(grammar.SemanticBQL())
 	if err != nil {
 		t.Errorf("grammar.NewParser: should have produced a valid BQL parser, %v", err)
@@ -139,6 +161,308 @@ func TestPlannerInsertDeleteDoesNotFail(t *testing.T) {
 	}
 }
 
+func TestPlannerInsertNowAnchors(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewStore()
+	g, err := s.NewGraph(ctx, "?a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser, %v", err)
+	}
+	bql := `insert data into ?a {/sensor<a> "reading"@[NOW()] "42"^^type:int64 .
+	                             /sensor<b> "reading"@[now()] "7"^^type:int64 .
+	                             /sensor<a> "calibrated"@[] "checked"@[NOW()]};`
+	stm := &semantic.Statement{}
+	if err := p.Parse(grammar.NewLLk(bql, 1), stm); err != nil {
+		t.Fatalf("Parser.consume: failed to accept BQL %q with error %v", bql, err)
+	}
+	pln, err := New(ctx, s, stm, 0, nil)
+	if err != nil {
+		t.Fatalf("planner.New failed to create a plan for %q with error %v", bql, err)
+	}
+	before := time.Now()
+	if _, err := pln.Execute(ctx); err != nil {
+		t.Fatalf("planner.Execute failed for %q with error %v", bql, err)
+	}
+	after := time.Now()
+	ts := make(chan *triple.Triple, 10)
+	if err := g.Triples(ctx, storage.DefaultLookup, ts); err != nil {
+		t.Fatal(err)
+	}
+	var anchors []time.Time
+	for trpl := range ts {
+		p := trpl.Predicate()
+		if o, err := trpl.Object().Predicate(); err == nil {
+			p = o
+		}
+		ta, err := p.TimeAnchor()
+		if err != nil {
+			t.Errorf("triple %v should have been anchored at the execution time; %v", trpl, err)
+			continue
+		}
+		anchors = append(anchors, *ta)
+	}
+	if got, want := len(anchors), 3; got != want {
+		t.Fatalf("planner.Execute(%q) inserted %d anchored triples; want %d", bql, got, want)
+	}
+	for _, ta := range anchors {
+		if ta.Before(before) || ta.After(after) || !ta.Equal(anchors[0]) {
+			t.Errorf("planner.Execute(%q) anchored triples at %v; want the same time between %v and %v", bql, anchors, before, after)
+			break
+		}
+	}
+
+	del := `delete data from ?a {/sensor<a> "reading"@[NOW()] "42"^^type:int64};`
+	stm = &semantic.Statement{}
+	if err := p.Parse(grammar.NewLLk(del, 1), stm); err != nil {
+		t.Fatalf("Parser.consume: failed to accept BQL %q with error %v", del, err)
+	}
+	if _, err := New(ctx, s, stm, 0, nil); err == nil {
+		t.Errorf("planner.New(%q) should have failed since deleted data cannot be anchored at NOW()", del)
+	}
+}
+
+func TestPlannerImport(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewStore()
+	for _, g := range []string{"?a", "?b"} {
+		if _, err := s.NewGraph(ctx, g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser, %v", err)
+	}
+	testTable := []struct {
+		bql     string
+		wantErr bool
+		want    int
+	}{
+		{
+			bql: `import "/u<joe> \"parent_of\"@[] /u<mary>
+			         /u<joe> \"name\"@[] \"Joe\"^^type:text
+
+			         /u<mary> \"parent_of\"@[2016-01-01T00:00:00Z] /u<peter>" into ?a, ?b;`,
+			want: 3,
+		},
+		{
+			bql:  "import \"/u<peter> \\\"parent_of\\\"@[] /u<kim>\\n/u<kim> \\\"name\\\"@[] \\\"Kim\\\"^^type:text\" into ?a, ?b;",
+			want: 5,
+		},
+		{
+			bql:     `import "/u<kim> \"parent_of\"@[] /u<eve>\nnot a triple" into ?a;`,
+			wantErr: true,
+			want:    6,
+		},
+	}
+	for _, entry := range testTable {
+		stm := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(entry.bql, 1), stm); err != nil {
+			t.Fatalf("Parser.consume: failed to accept BQL %q with error %v", entry.bql, err)
+		}
+		pln, err := New(ctx, s, stm, 0, nil)
+		if err != nil {
+			t.Fatalf("planner.New failed to create a plan for %q with error %v", entry.bql, err)
+		}
+		if _, err := pln.Execute(ctx); (err != nil) != entry.wantErr {
+			t.Errorf("planner.Execute(%q) returned error %v; want error %v", entry.bql, err, entry.wantErr)
+		}
+		g, err := s.Graph(ctx, "?a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := storage.CountTriples(ctx, g, &storage.CardinalityLookup{}, storage.DefaultLookup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != entry.want {
+			t.Errorf("planner.Execute(%q) left graph ?a with %d triples; want %d", entry.bql, n, entry.want)
+		}
+	}
+}
+
+func TestPlannerDeleteWhere(t *testing.T) {
+	s, ctx := populateTestStoreWith(t, originalTriples), context.Background()
+	testTable := []struct {
+		q    string
+		want []string
+	}{
+		{
+			q: `DELETE WHERE {?s "parent_of"@[] ?o} FROM ?test;`,
+			want: []string{
+				"/c<mini>\t\"is_a\"@[]",
+				"/c<model s>\t\"is_a\"@[]",
+				"/c<model x>\t\"is_a\"@[]",
+				"/c<model y>\t\"is_a\"@[]",
+				"/l<barcelona>\t\"predicate\"@[]",
+				"/l<barcelona>\t\"predicate\"@[]",
+				"/l<barcelona>\t\"predicate\"@[]",
+				"/l<barcelona>\t\"predicate\"@[]",
+				"/u<peter>\t\"bought\"@[2016-01-01T00:00:00-08:00]",
+				"/u<peter>\t\"bought\"@[2016-02-01T00:00:00-08:00]",
+				"/u<peter>\t\"bought\"@[2016-03-01T00:00:00-08:00]",
+				"/u<peter>\t\"bought\"@[2016-04-01T00:00:00-08:00]",
+			},
+		},
+		{
+			q: `DELETE WHERE {/u<peter> "bought"@[,2016-02-15T00:00:00-08:00] ?o} FROM ?test;`,
+			want: []string{
+				"/c<mini>\t\"is_a\"@[]",
+				"/c<model s>\t\"is_a\"@[]",
+				"/c<model x>\t\"is_a\"@[]",
+				"/c<model y>\t\"is_a\"@[]",
+				"/l<barcelona>\t\"predicate\"@[]",
+				"/l<barcelona>\t\"predicate\"@[]",
+				"/l<barcelona>\t\"predicate\"@[]",
+				"/l<barcelona>\t\"predicate\"@[]",
+				"/u<peter>\t\"bought\"@[2016-03-01T00:00:00-08:00]",
+				"/u<peter>\t\"bought\"@[2016-04-01T00:00:00-08:00]",
+			},
+		},
+		{
+			q: `DELETE WHERE {?c "is_a"@[] /t<car> . /u<peter> "bought"@[?t] ?c} FROM ?test;`,
+			want: []string{
+				"/c<mini>\t\"is_a\"@[]",
+				"/c<model s>\t\"is_a\"@[]",
+				"/l<barcelona>\t\"predicate\"@[]",
+				"/l<barcelona>\t\"predicate\"@[]",
+				"/l<barcelona>\t\"predicate\"@[]",
+				"/l<barcelona>\t\"predicate\"@[]",
+			},
+		},
+		{
+			q: `DELETE WHERE {/c<mini> "is_a"@[] /t<car>} FROM ?test;`,
+			want: []string{
+				"/c<model s>\t\"is_a\"@[]",
+				"/l<barcelona>\t\"predicate\"@[]",
+				"/l<barcelona>\t\"predicate\"@[]",
+				"/l<barcelona>\t\"predicate\"@[]",
+				"/l<barcelona>\t\"predicate\"@[]",
+			},
+		},
+		{
+			q: `DELETE WHERE {?s "predicate"@[] "turned"@[?t] . !{?s "is_a"@[] /t<car>}} FROM ?test;`,
+			want: []string{
+				"/c<model s>\t\"is_a\"@[]",
+			},
+		},
+	}
+	for _, entry := range testTable {
+		mustRunQuery(t, s, entry.q)
+		tbl := mustRunQuery(t, s, `SELECT ?s, ?p FROM ?test WHERE {?s ?p ?o} ORDER BY ?s, ?p;`)
+		if got := rowStrings(tbl, []string{"?s", "?p"}); !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute(%q) left the wrong triples; got %q, want %q", entry.q, got, entry.want)
+		}
+	}
+	for _, q := range []string{
+		`DELETE WHERE {?s "predicate"@[] "turned"@[,]} FROM ?test;`,
+		`DELETE WHERE { {?s "parent_of"@[] ?o} UNION {?s "is_a"@[] ?o} } FROM ?test;`,
+	} {
+		if _, err := New(ctx, s, parseQuery(t, q), 0, nil); err == nil {
+			t.Errorf("planner.New(%q) should have failed", q)
+		}
+	}
+}
+
+// nonTransactionalStore hides the optional interfaces of the wrapped store.
+type nonTransactionalStore struct {
+	storage.Store
+}
+
+func TestPlannerExecuteTx(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewStore()
+	g, err := s.NewGraph(ctx, "?a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := func() int {
+		n, err := storage.CountTriples(ctx, g, &storage.CardinalityLookup{PID: "bar"}, storage.DefaultLookup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	failingDelete := strings.Replace(deleteTestBQL, "?a", "?missing", 1)
+	testTable := []struct {
+		store   storage.Store
+		stms    []string
+		wantErr bool
+		want    int
+	}{
+		{store: s, stms: []string{insertTestBQL, deleteTestBQL}, want: 0},
+		{store: s, stms: []string{insertTestBQL}, want: 3},
+		{store: s, stms: []string{deleteTestBQL, failingDelete}, wantErr: true, want: 3},
+		{store: s, stms: []string{deleteTestBQL}, want: 0},
+		{store: s, stms: []string{insertTestBQL, failingDelete, deleteTestBQL}, wantErr: true, want: 0},
+		{store: s, stms: []string{insertTestBQL, `select ?s from ?a where {?s ?p ?o};`}, wantErr: true, want: 0},
+		// Stores without transactions keep the mutations before the failure.
+		{store: nonTransactionalStore{s}, stms: []string{insertTestBQL, failingDelete, deleteTestBQL}, wantErr: true, want: 3},
+	}
+	for _, entry := range testTable {
+		var stms []*semantic.Statement
+		for _, q := range entry.stms {
+			stms = append(stms, parseQuery(t, q))
+		}
+		err := ExecuteTx(ctx, entry.store, stms)
+		if entry.wantErr != (err != nil) {
+			t.Errorf("planner.ExecuteTx(%q) returned error %v; want error %v", entry.stms, err, entry.wantErr)
+		}
+		if got := count(); got != entry.want {
+			t.Errorf("planner.ExecuteTx(%q) left %d triples in graph ?a; want %d", entry.stms, got, entry.want)
+		}
+	}
+}
+
+func TestPlannerReadOnlyGraphs(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewStore()
+	for _, g := range []string{"?a", "?ro"} {
+		if _, err := s.NewGraph(ctx, g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustRunQuery(t, s, strings.Replace(insertTestBQL, "?a", "?ro", 1))
+	if err := s.(storage.ReadOnlyMarker).SetReadOnly(ctx, "?ro", true); err != nil {
+		t.Fatal(err)
+	}
+	count := func(g string) int {
+		return len(mustRunQuery(t, s, `select ?s from `+g+` where {?s ?p ?o};`).Rows())
+	}
+	for _, q := range []string{
+		strings.Replace(insertTestBQL, "?a", "?a, ?ro", 1),
+		strings.Replace(deleteTestBQL, "?a", "?ro", 1),
+		`INSERT INTO ?ro CONSTRUCT {?s "copy"@[] ?o} FROM ?a WHERE {?s "bar"@[] ?o};`,
+	} {
+		if _, err := runQuery(t, s, q); err == nil {
+			t.Errorf("planner.Execute(%q) should have failed since graph ?ro is read-only", q)
+		}
+		if got, want := count("?a"), 0; got != want {
+			t.Errorf("planner.Execute(%q) should not have changed graph ?a; got %d triples, want %d", q, got, want)
+		}
+		if got, want := count("?ro"), 3; got != want {
+			t.Errorf("planner.Execute(%q) should not have changed graph ?ro; got %d triples, want %d", q, got, want)
+		}
+	}
+	// Read-only graphs can still be queried.
+	mustRunQuery(t, s, `INSERT INTO ?a CONSTRUCT {?s "copy"@[] ?o} FROM ?ro WHERE {?s "bar"@[] ?o};`)
+	if got, want := count("?a"), 3; got != want {
+		t.Errorf("constructing out of a read-only graph returned %d triples; want %d", got, want)
+	}
+	// Transactions touching read-only graphs revert the earlier statements.
+	stms := []*semantic.Statement{parseQuery(t, insertTestBQL), parseQuery(t, strings.Replace(insertTestBQL, "?a", "?ro", 1))}
+	if err := ExecuteTx(ctx, s, stms); err == nil {
+		t.Error("planner.ExecuteTx should have failed since graph ?ro is read-only")
+	}
+	if got, want := count("?a"), 3; got != want {
+		t.Errorf("planner.ExecuteTx should have reverted graph ?a; got %d triples, want %d", got, want)
+	}
+}
+
 func TestPlannerCreateGraph(t *testing.T) {
 	ctx := context.Background()
 	memory.DefaultStore.DeleteGraph(ctx, "?foo")
@@ -168,6 +492,175 @@ func TestPlannerCreateGraph(t *testing.T) {
 	}
 }
 
+func TestPlannerCloneGraph(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewStore()
+	g, err := s.NewGraph(ctx, "?original")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := io.ReadIntoGraph(ctx, g, strings.NewReader(`/u<joe> "parent_of"@[] /u<mary>
+		/u<joe> "bought"@[2016-01-01T00:00:00-08:00] /c<mini>`), literal.DefaultBuilder())
+	if err != nil || n != 2 {
+		t.Fatalf("io.ReadIntoGraph returned %d, %v; want 2, nil", n, err)
+	}
+	mustRunQuery(t, s, `create graph ?copy as ?original;`)
+	if _, err := runQuery(t, s, `delete data from ?original {/u<joe> "parent_of"@[] /u<mary>};`); err != nil {
+		t.Fatal(err)
+	}
+	got, err := mustRunQuery(t, s, `select ?s, ?p, ?o from ?copy where {?s ?p ?o} order by ?p;`).ToText(", ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `?s, ?p, ?o
+/u<joe>, "bought"@[2016-01-01T00:00:00-08:00], /c<mini>
+/u<joe>, "parent_of"@[], /u<mary>
+`
+	if got.String() != want {
+		t.Errorf("planner.Execute returned the wrong triples for the cloned graph;\nGot:\n%s\nWant:\n%s", got, want)
+	}
+	for _, q := range []string{
+		`create graph ?copy as ?original;`,
+		`create graph ?other as ?missing;`,
+	} {
+		if _, err := runQuery(t, s, q); err == nil {
+			t.Errorf("planner.Execute(%q) should have failed", q)
+		}
+	}
+	if _, err := s.Graph(ctx, "?other"); err == nil {
+		t.Errorf("planner.Execute should have not created graph ?other out of a missing graph")
+	}
+}
+
+func TestPlannerShowGraphs(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewStore()
+	for _, g := range []string{"?b", "?a"} {
+		if _, err := s.NewGraph(ctx, g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gms := s.(storage.GraphMetadataStore)
+	for _, kv := range [][]string{{"owner", "joe"}, {"description", "family tree"}} {
+		if err := gms.SetGraphMetadata(ctx, "?b", kv[0], kv[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := mustRunQuery(t, s, `show graphs;`).ToText(", ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "?graph, ?key, ?value\n?a, <NULL>, <NULL>\n?b, description, family tree\n?b, owner, joe\n"
+	if got.String() != want {
+		t.Errorf("planner.Execute returned the wrong graphs for show graphs;\nGot:\n%s\nWant:\n%s", got, want)
+	}
+}
+
+func TestPlannerDescribeGraph(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewStore()
+	g, err := s.NewGraph(ctx, "?family")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.NewGraph(ctx, "?empty"); err != nil {
+		t.Fatal(err)
+	}
+	n, err := io.ReadIntoGraph(ctx, g, strings.NewReader(`/u<joe> "parent_of"@[] /u<mary>
+		/u<joe> "parent_of"@[] /u<peter>
+		/u<peter> "parent_of"@[] /u<john>
+		/u<joe> "bought"@[2016-01-01T00:00:00-08:00] /c<mini>
+		/u<joe> "bought"@[2016-02-01T00:00:00-08:00] /c<model s>`), literal.DefaultBuilder())
+	if err != nil || n != 5 {
+		t.Fatalf("io.ReadIntoGraph returned %d, %v; want 5, nil", n, err)
+	}
+	got, err := mustRunQuery(t, s, `describe graph ?family, ?empty;`).ToText(", ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `?graph, ?triples, ?predicates, ?subjects
+?family, "5"^^type:int64, "2"^^type:int64, "2"^^type:int64
+?empty, "0"^^type:int64, "0"^^type:int64, "0"^^type:int64
+`
+	if got.String() != want {
+		t.Errorf("planner.Execute returned the wrong description;\nGot:\n%s\nWant:\n%s", got, want)
+	}
+	if _, err := runQuery(t, s, `describe graph ?missing;`); err == nil {
+		t.Errorf("planner.Execute should have failed to describe a non existing graph")
+	}
+}
+
+func TestPlannerPredicatePrefix(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewStore()
+	g, err := s.NewGraph(ctx, "?reified")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := io.ReadIntoGraph(ctx, g, strings.NewReader(`/u<joe> "parent_of"@[] /u<mary>
+		/_<b1> "_subject"@[] /u<joe>
+		/_<b1> "_predicate"@[] "parent_of"@[]
+		/_<b1> "_object"@[] /u<mary>
+		/_<b1> "_owner"@[2016-01-01T00:00:00-08:00] /u<peter>
+		/_<b1> "_owner"@[2016-03-01T00:00:00-08:00] /u<eve>`), literal.DefaultBuilder())
+	if err != nil || n != 6 {
+		t.Fatalf("io.ReadIntoGraph returned %d, %v; want 6, nil", n, err)
+	}
+	table := []struct {
+		q    string
+		want []string
+	}{
+		{
+			q:    `select ?p, ?o from ?reified where {?s PREFIX("_"@[]) AS ?p ?o} order by ?p;`,
+			want: []string{`"_object"@[]	/u<mary>`, `"_predicate"@[]	"parent_of"@[]`, `"_subject"@[]	/u<joe>`},
+		},
+		{
+			q:    `select ?p, ?o from ?reified where {?s PREFIX("_sub"@[]) AS ?p ?o};`,
+			want: []string{`"_subject"@[]	/u<joe>`},
+		},
+		{
+			q:    `select ?p, ?o from ?reified where {?s PREFIX("_"@[?t]) AS ?p ?o} order by ?o;`,
+			want: []string{`"_owner"@[2016-03-01T00:00:00-08:00]	/u<eve>`, `"_owner"@[2016-01-01T00:00:00-08:00]	/u<peter>`},
+		},
+		{
+			q:    `select ?p, ?o from ?reified where {?s PREFIX("_"@[2016-03-01T00:00:00-08:00]) AS ?p ?o};`,
+			want: []string{`"_owner"@[2016-03-01T00:00:00-08:00]	/u<eve>`},
+		},
+		{
+			q:    `select ?p, ?o from ?reified where {?s PREFIX("_o"@[2016-02-01T00:00:00-08:00,]) AS ?p ?o};`,
+			want: []string{`"_owner"@[2016-03-01T00:00:00-08:00]	/u<eve>`},
+		},
+		{
+			q:    `select ?p, ?o from ?reified where {?s PREFIX("_"@[?t]) AS ?p ?o} before ""@[2016-02-01T00:00:00-08:00];`,
+			want: []string{`"_owner"@[2016-01-01T00:00:00-08:00]	/u<peter>`},
+		},
+		{
+			q:    `select ?p, ?o from ?reified where {/_<b1> PREFIX("_o"@[]) AS ?p ?o};`,
+			want: []string{`"_object"@[]	/u<mary>`},
+		},
+		{
+			q:    `select ?p, ?o from ?reified where {?s PREFIX("parent"@[?t]) AS ?p ?o};`,
+			want: nil,
+		},
+	}
+	for _, entry := range table {
+		tbl := mustRunQuery(t, s, entry.q)
+		if got := rowStrings(tbl, []string{"?p", "?o"}); !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute(%q) returned rows %q; want %q", entry.q, got, entry.want)
+		}
+	}
+	// The limit is not pushed down to the scan, since the triples are filtered
+	// by the prefix once retrieved.
+	tbl := mustRunQuery(t, s, `select ?o from ?reified where {?s PREFIX("_own"@[?t]) ?o} limit "1"^^type:int64;`)
+	if got, want := tbl.NumRows(), 1; got != want {
+		t.Errorf("planner.Execute returned %d rows for a limited prefix scan; want %d", got, want)
+	}
+	stm := parseQuery(t, `DELETE WHERE {?s PREFIX("_"@[]) ?o} FROM ?reified;`)
+	if _, err := New(ctx, s, stm, 0, nil); err == nil {
+		t.Errorf("planner.New should have rejected deleting the triples matching a predicate ID prefix")
+	}
+}
+
 func TestPlannerDropGraph(t *testing.T) {
 	ctx := context.Background()
 	memory.DefaultStore.DeleteGraph(ctx, "?foo")
@@ -199,6 +692,36 @@ func TestPlannerDropGraph(t *testing.T) {
 	}
 }
 
+func TestPlannerTruncateGraph(t *testing.T) {
+	ctx := context.Background()
+	s := memory.NewStore()
+	g, err := s.NewGraph(ctx, "?foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadIntoGraph(ctx, g, bytes.NewBufferString(originalTriples), literal.DefaultBuilder()); err != nil {
+		t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+	}
+	if err := s.(storage.GraphMetadataStore).SetGraphMetadata(ctx, "?foo", "owner", "joe"); err != nil {
+		t.Fatal(err)
+	}
+	mustRunQuery(t, s, `truncate graph ?foo;`)
+	// Unlike drop, the graph and its metadata are kept.
+	g, err = s.Graph(ctx, "?foo")
+	if err != nil {
+		t.Fatalf("planner.Execute: truncate should have kept graph %q; %v", "?foo", err)
+	}
+	if d, err := storage.DescribeGraph(ctx, g); err != nil || d.Triples != 0 {
+		t.Errorf("storage.DescribeGraph returned %v, %v after truncating the graph; want no triples", d, err)
+	}
+	if md, err := storage.GraphMetadata(ctx, s, "?foo"); err != nil || md["owner"] != "joe" {
+		t.Errorf("storage.GraphMetadata returned %v, %v after truncating the graph; want the metadata kept", md, err)
+	}
+	if _, err := runQuery(t, s, `truncate graph ?missing;`); err == nil {
+		t.Errorf("planner.Execute: truncate should have failed for a non existing graph")
+	}
+}
+
 const (
 	originalTriples = `/u<joe> "parent_of"@[] /u<mary>
 		/u<joe> "parent_of"@[] /u<peter>
@@ -235,14 +758,10 @@ const (
 )
 
 func populateTestStore(t *testing.T) storage.Store {
-	s, ctx := memory.NewStore(), context.Background()
-	g, err := s.NewGraph(ctx, "?test")
+	s, ctx := populateTestStoreWith(t, testTriples), context.Background()
+	g, err := s.Graph(ctx, "?test")
 	if err != nil {
-		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", err)
-	}
-	b := bytes.NewBufferString(testTriples)
-	if _, err := io.ReadIntoGraph(ctx, g, b, literal.DefaultBuilder()); err != nil {
-		t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+		t.Fatal(err)
 	}
 	trpls := make(chan *triple.Triple)
 	go func() {
@@ -260,11 +779,25 @@ func populateTestStore(t *testing.T) storage.Store {
 	return s
 }
 
-func populateBenchmarkStore(b *testing.B) storage.Store {
+// populateTestStoreWith returns a memory store with the provided triples, one
+// per line, in the "?test" graph.
+func populateTestStoreWith(tb testing.TB, triples string) storage.Store {
 	s, ctx := memory.NewStore(), context.Background()
 	g, err := s.NewGraph(ctx, "?test")
 	if err != nil {
-		b.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", err)
+		tb.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", err)
+	}
+	if _, err := io.ReadIntoGraph(ctx, g, strings.NewReader(triples), literal.DefaultBuilder()); err != nil {
+		tb.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+	}
+	return s
+}
+
+func populateBenchmarkStore(b *testing.B, s storage.Store) storage.Store {
+	ctx := context.Background()
+	g, err := s.NewGraph(ctx, "?test")
+	if err != nil {
+		b.Fatalf("%s.NewGraph failed to create \"?test\" with error %v", s.Name(ctx), err)
 	}
 	buf := bytes.NewBufferString(testTriples)
 	if _, err := io.ReadIntoGraph(ctx, g, buf, literal.DefaultBuilder()); err != nil {
@@ -399,82 +932,187 @@ func TestPlannerQuery(t *testing.T) {
 			nrws: 4,
 		},
 		{
-			q:    `select ?o from ?test where {/u<peter> "bought"@[,2015-01-01T00:00:00-08:00] ?o};`,
+			q:    `select ?o from ?test where {/u<peter> "bought"@[=2016-02-01T00:00:00-08:00] ?o};`,
 			nbs:  1,
-			nrws: 0,
+			nrws: 1,
 		},
 		{
-			q:    `select ?o from ?test where {/u<peter> "bought"@[2017-01-01T00:00:00-08:00,] ?o};`,
+			q:    `select ?o from ?test where {/u<peter> "bought"@[=2016-02-01T08:00:00Z] ?o};`,
 			nbs:  1,
-			nrws: 0,
+			nrws: 1,
 		},
 		{
-			q:    `select ?o from ?test where {/u<peter> "bought"@[2015-01-01T00:00:00-08:00,2017-01-01T00:00:00-08:00] ?o};`,
+			q:    `select ?o from ?test where {/u<peter> "bought"@[=2016-02-01T00:00:01-08:00] ?o};`,
 			nbs:  1,
-			nrws: 4,
+			nrws: 0,
 		},
 		{
-			q:    `select ?o from ?test where {/l<barcelona> "predicate"@[] "turned"@[,] as ?o};`,
+			q:    `select ?o from ?test where {/u<peter> "bought"@[,] AT ?t /c<model s> . /u<peter> "bought"@[=?t] ?o};`,
 			nbs:  1,
-			nrws: 4,
+			nrws: 1,
 		},
 		{
-			q:    `select ?o from ?test where {/l<barcelona> "predicate"@[] "turned"@[,2015-01-01T00:00:00-08:00] as ?o};`,
+			q:    `select ?o from ?test where {/u<peter> "bought"@[,2015-01-01T00:00:00-08:00] ?o};`,
 			nbs:  1,
 			nrws: 0,
 		},
 		{
-			q:    `select ?o from ?test where {/l<barcelona> "predicate"@[] "turned"@[2017-01-01T00:00:00-08:00,] as ?o};`,
+			q:    `select ?o from ?test where {/u<peter> "bought"@[2017-01-01T00:00:00-08:00,] ?o};`,
 			nbs:  1,
 			nrws: 0,
 		},
 		{
-			q:    `select ?o from ?test where {/l<barcelona> "predicate"@[] "turned"@[2015-01-01T00:00:00-08:00,2017-01-01T00:00:00-08:00] as ?o};`,
+			q:    `select ?o from ?test where {/u<peter> "bought"@[2015-01-01T00:00:00-08:00,2017-01-01T00:00:00-08:00] ?o};`,
 			nbs:  1,
 			nrws: 4,
 		},
 		{
-			q:    `select ?grandparent, count(?name) as ?grandchildren from ?test where {/u<joe> as ?grandparent "parent_of"@[] ?offspring . ?offspring "parent_of"@[] ?name} group by ?grandparent;`,
+			q:    `select ?s, ?t from ?test where {?s "parent_of"@[] /u<john> . ?s "bought"@[,] AT ?t ?o};`,
 			nbs:  2,
-			nrws: 1,
+			nrws: 4,
 		},
 		{
-			q:    `select ?grandparent, count(distinct ?name) as ?grandchildren from ?test where {/u<joe> as ?grandparent "parent_of"@[] ?offspring . ?offspring "parent_of"@[] ?name} group by ?grandparent;`,
+			q:    `select ?s, ?o from ?test where {?s "parent_of"@[] ?o . !{?o "parent_of"@[] ?x}};`,
 			nbs:  2,
-			nrws: 1,
+			nrws: 3,
 		},
 		{
-			q:    `select ?s, ?p, ?o, ?k, ?l, ?m from ?test where {?s ?p ?o. ?k ?l ?m} order by ?s, ?p, ?o, ?k, ?l, ?m;`,
-			nbs:  6,
-			nrws: (len(strings.Split(testTriples, "\n")) - 1) * (len(strings.Split(testTriples, "\n")) - 1),
-		},
-		{
-			q:    `select ?s, ?p, ?o, ?k, ?l, ?m from ?test where {?s ?p ?o. ?k ?l ?m} order by ?s, ?p, ?o, ?k, ?l, ?m  having not(?s = ?s);`,
-			nbs:  6,
-			nrws: 0,
-		},
-		{
-			q:    `select ?o from ?test where {/l<barcelona> "predicate"@[] "turned"@[2015-01-01T00:00:00-08:00,2017-01-01T00:00:00-08:00] as ?o} LIMIT "2"^^type:int64;`,
+			q:    `select ?s from ?test where {?s "is_a"@[] /t<car> . !{/u<peter> "bought"@[2016-01-01T00:00:00-08:00] ?s}};`,
 			nbs:  1,
-			nrws: 2,
+			nrws: 3,
 		},
 		{
-			q:    `select ?o from ?test where {/u<peter> "bought"@[2015-01-01T00:00:00-08:00,2017-01-01T00:00:00-08:00] ?o} before ""@[2014-01-01T00:00:00-08:00];`,
+			q:    `select ?o from ?test where {/u<peter> "bought"@[,] ?o . !{?o "is_a"@[] /t<car>}};`,
 			nbs:  1,
 			nrws: 0,
 		},
 		{
-			q:    `select ?o from ?test where {/u<peter> "bought"@[2015-01-01T00:00:00-08:00,2017-01-01T00:00:00-08:00] ?o} after ""@[2017-01-01T00:00:00-08:00];`,
+			q:    `select ?s from ?test where {?s "is_a"@[] /t<car> . !{/u<peter> "bought"@[,] ?s}};`,
 			nbs:  1,
 			nrws: 0,
 		},
 		{
-			q:    `select ?o from ?test where {/u<peter> "bought"@[2015-01-01T00:00:00-08:00,2017-01-01T00:00:00-08:00] ?o} between ""@[2014-01-01T00:00:00-08:00], ""@[2017-01-01T00:00:00-08:00];`,
+			q:    `select ?s from ?test where {?s "is_a"@[] /t<car> . !{?s "recalled"@[] ?x}};`,
 			nbs:  1,
 			nrws: 4,
 		},
 		{
-			q:    `SELECT ?grandparent, COUNT(?grandparent) AS ?number_of_grandchildren FROM ?test WHERE{ ?gp ID ?grandparent "parent_of"@[] ?c . ?c "parent_of"@[] ?gc ID ?gc } GROUP BY ?grandparent;`,
+			q:    `select ?o from ?test where {/l<barcelona> "predicate"@[] ?o . !{?o "parent_of"@[] ?x}};`,
+			nbs:  1,
+			nrws: 4,
+		},
+		{
+			q:    `select ?o, ?t from ?test where {/u<peter> "bought"@[,] AT ?t ?o . !{/u<peter> "bought"@[?t] /c<mini>}};`,
+			nbs:  2,
+			nrws: 3,
+		},
+		{
+			q:    `select ?s, ?o from ?test where {?s "parent_of"@[] ?o . not exists {?o "parent_of"@[] ?x}};`,
+			nbs:  2,
+			nrws: 3,
+		},
+		{
+			q:    `select ?s, ?o from ?test where {?s "parent_of"@[] ?o . not exists {?o "parent_of"@[] ?c . ?c "bought"@[,] ?x}};`,
+			nbs:  2,
+			nrws: 4,
+		},
+		{
+			q:    `select ?s, ?o from ?test where {?s "parent_of"@[] ?o . not exists {?s "parent_of"@[] ?c . ?c "bought"@[,] ?x}};`,
+			nbs:  2,
+			nrws: 2,
+		},
+		{
+			q:    `select ?s from ?test where {?s "is_a"@[] /t<car> . not exists {/u<peter> "bought"@[,] ?s}};`,
+			nbs:  1,
+			nrws: 0,
+		},
+		{
+			q:    `select ?s from ?test where {?s "is_a"@[] /t<car> . not exists {?x "recalled"@[] ?y}};`,
+			nbs:  1,
+			nrws: 4,
+		},
+		{
+			q:    `select ?s from ?test where {?s "is_a"@[] /t<car> . not exists {?x "parent_of"@[] ?y . ?y "bought"@[,] ?z}};`,
+			nbs:  1,
+			nrws: 0,
+		},
+		{
+			q:    `select ?s from ?test where {?s "is_a"@[] /t<car> . not exists {/u<joe> "parent_of"@[] /u<mary>}};`,
+			nbs:  1,
+			nrws: 0,
+		},
+		{
+			q:    `select ?o from ?test where {/l<barcelona> "predicate"@[] "turned"@[,] as ?o};`,
+			nbs:  1,
+			nrws: 4,
+		},
+		{
+			q:    `select ?o from ?test where {/l<barcelona> "predicate"@[] "turned"@[,2015-01-01T00:00:00-08:00] as ?o};`,
+			nbs:  1,
+			nrws: 0,
+		},
+		{
+			q:    `select ?o from ?test where {/l<barcelona> "predicate"@[] "turned"@[2017-01-01T00:00:00-08:00,] as ?o};`,
+			nbs:  1,
+			nrws: 0,
+		},
+		{
+			q:    `select ?o from ?test where {/l<barcelona> "predicate"@[] "turned"@[2015-01-01T00:00:00-08:00,2017-01-01T00:00:00-08:00] as ?o};`,
+			nbs:  1,
+			nrws: 4,
+		},
+		{
+			q:    `select ?grandparent, count(?name) as ?grandchildren from ?test where {/u<joe> as ?grandparent "parent_of"@[] ?offspring . ?offspring "parent_of"@[] ?name} group by ?grandparent;`,
+			nbs:  2,
+			nrws: 1,
+		},
+		{
+			q:    `select ?grandparent, count(distinct ?name) as ?grandchildren from ?test where {/u<joe> as ?grandparent "parent_of"@[] ?offspring . ?offspring "parent_of"@[] ?name} group by ?grandparent;`,
+			nbs:  2,
+			nrws: 1,
+		},
+		{
+			q:    `select ?s, ?p, ?o, ?k, ?l, ?m from ?test where {?s ?p ?o. ?k ?l ?m} order by ?s, ?p, ?o, ?k, ?l, ?m;`,
+			nbs:  6,
+			nrws: (len(strings.Split(testTriples, "\n")) - 1) * (len(strings.Split(testTriples, "\n")) - 1),
+		},
+		{
+			q:    `select ?s, ?p, ?o, ?k, ?l, ?m from ?test where {?s ?p ?o. ?k ?l ?m} order by ?s, ?p, ?o, ?k, ?l, ?m  having not(?s = ?s);`,
+			nbs:  6,
+			nrws: 0,
+		},
+		{
+			q:    `select ?o from ?test where {/l<barcelona> "predicate"@[] "turned"@[2015-01-01T00:00:00-08:00,2017-01-01T00:00:00-08:00] as ?o} LIMIT "2"^^type:int64;`,
+			nbs:  1,
+			nrws: 2,
+		},
+		{
+			q:    `select ?s, ?k from ?test where {?s ?p ?o . ?k ?l ?m} LIMIT "7"^^type:int64;`,
+			nbs:  2,
+			nrws: 7,
+		},
+		{
+			q:    `select ?s, ?o from ?test where {?s "parent_of"@[] ?o . !{?o "parent_of"@[] ?x}} LIMIT "3"^^type:int64;`,
+			nbs:  2,
+			nrws: 3,
+		},
+		{
+			q:    `select ?o from ?test where {/u<peter> "bought"@[2015-01-01T00:00:00-08:00,2017-01-01T00:00:00-08:00] ?o} before ""@[2014-01-01T00:00:00-08:00];`,
+			nbs:  1,
+			nrws: 0,
+		},
+		{
+			q:    `select ?o from ?test where {/u<peter> "bought"@[2015-01-01T00:00:00-08:00,2017-01-01T00:00:00-08:00] ?o} after ""@[2017-01-01T00:00:00-08:00];`,
+			nbs:  1,
+			nrws: 0,
+		},
+		{
+			q:    `select ?o from ?test where {/u<peter> "bought"@[2015-01-01T00:00:00-08:00,2017-01-01T00:00:00-08:00] ?o} between ""@[2014-01-01T00:00:00-08:00], ""@[2017-01-01T00:00:00-08:00];`,
+			nbs:  1,
+			nrws: 4,
+		},
+		{
+			q:    `SELECT ?grandparent, COUNT(?grandparent) AS ?number_of_grandchildren FROM ?test WHERE{ ?gp ID ?grandparent "parent_of"@[] ?c . ?c "parent_of"@[] ?gc ID ?gc } GROUP BY ?grandparent;`,
 			nbs:  2,
 			nrws: 1,
 		},
@@ -483,6 +1121,61 @@ func TestPlannerQuery(t *testing.T) {
 			nbs:  2,
 			nrws: 1,
 		},
+		{
+			q:    `SELECT ?s FROM ?test WHERE {?s[/room] "connects_to"@[] ?o};`,
+			nbs:  1,
+			nrws: 8,
+		},
+		{
+			q:    `SELECT ?s FROM ?test WHERE {?s[/room] "in"@[,] ?o};`,
+			nbs:  1,
+			nrws: 0,
+		},
+		{
+			q:    `SELECT ?s, ?o FROM ?test WHERE {?s[/room] ?p ?o};`,
+			nbs:  2,
+			nrws: 8,
+		},
+		{
+			q:    `SELECT ?s, ?o FROM ?test WHERE {?s[/item] ?p ?o[/room]};`,
+			nbs:  2,
+			nrws: 3,
+		},
+		{
+			q:    `SELECT ?o FROM ?test WHERE {/item/book<000> "in"@[,] ?o[/room] . ?o[/room] "connects_to"@[] /room<Kitchen>};`,
+			nbs:  1,
+			nrws: 2,
+		},
+		{
+			q:    `SELECT ?s FROM ?test WHERE {?s "parent_of"@[] ?o . ?s[/room] "parent_of"@[] ?o};`,
+			nbs:  1,
+			nrws: 0,
+		},
+		{
+			q:    `SELECT ?s FROM ?test WHERE {?s "parent_of"@[] ?o . ?s[/u] "parent_of"@[] ?o[/u]};`,
+			nbs:  1,
+			nrws: 4,
+		},
+		{
+			q:    `SELECT ?s FROM ?test WHERE {?s "parent_of"@[] ?o . !{?s "parent_of"@[] ?x[/c]}};`,
+			nbs:  1,
+			nrws: 4,
+		},
+		{
+			q:    `SELECT ?s FROM ?test WHERE {?s "parent_of"@[] ?o . !{?s "parent_of"@[] ?x[/u]}};`,
+			nbs:  1,
+			nrws: 0,
+		},
+		{
+			q:    `SELECT ?r FROM ?test WHERE {/room<Hallway> "connects_to"@[]+ ?r[/room]};`,
+			nbs:  1,
+			nrws: 5,
+		},
+		{
+			q:    `SELECT ?r FROM ?test WHERE {/room<Hallway> "connects_to"@[]+ ?r[/item]};`,
+			nbs:  1,
+			nrws: 0,
+		},
 	}
 
 	s := populateTestStore(t)
@@ -513,6 +1206,2117 @@ func TestPlannerQuery(t *testing.T) {
 	}
 }
 
+// parseQuery parses the provided query and returns its statement.
+func parseQuery(t *testing.T, q string) *semantic.Statement {
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	st := &semantic.Statement{}
+	if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+		t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+	}
+	return st
+}
+
+// planQuery parses the provided query and returns its plan against the
+// provided store.
+func planQuery(t *testing.T, s storage.Store, q string) Executor {
+	return planStatement(t, s, parseQuery(t, q))
+}
+
+// planStatement returns the plan of the provided statement against the
+// provided store.
+func planStatement(t *testing.T, s storage.Store, st *semantic.Statement) Executor {
+	plnr, err := New(context.Background(), s, st, 0, nil)
+	if err != nil {
+		t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+	}
+	return plnr
+}
+
+// runQuery parses, plans, and executes the provided query against the
+// provided store.
+func runQuery(t *testing.T, s storage.Store, q string) (*table.Table, error) {
+	return planQuery(t, s, q).Execute(context.Background())
+}
+
+// mustRunQuery runs the provided query and fails the test if the execution
+// returns an error.
+func mustRunQuery(t *testing.T, s storage.Store, q string) *table.Table {
+	tbl, err := runQuery(t, s, q)
+	if err != nil {
+		t.Fatalf("planner.Excecute failed for query %q with error %v", q, err)
+	}
+	return tbl
+}
+
+// rowStrings returns the rows of the table as tab separated values of the
+// provided bindings.
+func rowStrings(tbl *table.Table, bs []string) []string {
+	var res []string
+	for _, r := range tbl.Rows() {
+		var vs []string
+		for _, b := range bs {
+			vs = append(vs, r[b].String())
+		}
+		res = append(res, strings.Join(vs, "\t"))
+	}
+	return res
+}
+
+func TestPlannerOrderByDirections(t *testing.T) {
+	ts := `/u<joe> "parent_of"@[] /u<mary>
+		/u<joe> "bought"@[] /c<mini>
+		/u<mary> "parent_of"@[] /u<peter>
+		/u<mary> "bought"@[] /c<model s>
+		/u<mary> "bought"@[] /c<mini>`
+	s := populateTestStoreWith(t, ts)
+	testTable := []struct {
+		q    string
+		want []string
+	}{
+		{
+			q: `SELECT ?s, ?p, ?o FROM ?test WHERE {?s ?p ?o} ORDER BY ?s DESC, ?p ASC, ?o DESC;`,
+			want: []string{
+				`/u<mary>	"bought"@[]	/c<model s>`,
+				`/u<mary>	"bought"@[]	/c<mini>`,
+				`/u<mary>	"parent_of"@[]	/u<peter>`,
+				`/u<joe>	"bought"@[]	/c<mini>`,
+				`/u<joe>	"parent_of"@[]	/u<mary>`,
+			},
+		},
+		{
+			// Keys without a direction are sorted in ascending order.
+			q: `SELECT ?s, ?p, ?o FROM ?test WHERE {?s ?p ?o} ORDER BY ?s, ?p DESC, ?o;`,
+			want: []string{
+				`/u<joe>	"parent_of"@[]	/u<mary>`,
+				`/u<joe>	"bought"@[]	/c<mini>`,
+				`/u<mary>	"parent_of"@[]	/u<peter>`,
+				`/u<mary>	"bought"@[]	/c<mini>`,
+				`/u<mary>	"bought"@[]	/c<model s>`,
+			},
+		},
+	}
+	for _, entry := range testTable {
+		if got := rowStrings(mustRunQuery(t, s, entry.q), []string{"?s", "?p", "?o"}); !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %q, want %q", entry.q, got, entry.want)
+		}
+	}
+}
+
+func TestPlannerOffset(t *testing.T) {
+	testTable := []struct {
+		q    string
+		want []string
+	}{
+		{
+			q:    `select ?o from ?test where {/u<peter> "bought"@[,] ?o} order by ?o limit "2"^^type:int64 offset "1"^^type:int64;`,
+			want: []string{"/c<model s>", "/c<model x>"},
+		},
+		{
+			q:    `select ?o from ?test where {/u<peter> "bought"@[,] ?o} order by ?o desc offset "3"^^type:int64;`,
+			want: []string{"/c<mini>"},
+		},
+		{
+			q:    `select ?o from ?test where {/u<peter> "bought"@[,] ?o} order by ?o offset "4"^^type:int64;`,
+			want: nil,
+		},
+		{
+			q:    `select ?o from ?test where {/u<peter> "bought"@[,] ?o} order by ?o limit 2 offset 1;`,
+			want: []string{"/c<model s>", "/c<model x>"},
+		},
+	}
+	s := populateTestStore(t)
+	for _, entry := range testTable {
+		tbl := mustRunQuery(t, s, entry.q)
+		if got := rowStrings(tbl, []string{"?o"}); !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %v, want %v", entry.q, got, entry.want)
+		}
+	}
+}
+
+func TestPlannerPlainIntegerLimitAndOffset(t *testing.T) {
+	testTable := []struct {
+		typed, plain string
+	}{
+		{
+			typed: `select ?o from ?test where {/u<peter> "bought"@[,] ?o} limit "2"^^type:int64;`,
+			plain: `select ?o from ?test where {/u<peter> "bought"@[,] ?o} limit 2;`,
+		},
+		{
+			typed: `select ?o from ?test where {/u<peter> "bought"@[,] ?o} order by ?o limit "3"^^type:int64 offset "1"^^type:int64;`,
+			plain: `select ?o from ?test where {/u<peter> "bought"@[,] ?o} order by ?o limit 3 offset 1;`,
+		},
+	}
+	s := populateTestStore(t)
+	for _, entry := range testTable {
+		typed, plain := parseQuery(t, entry.typed), parseQuery(t, entry.plain)
+		if typed.Limit() != plain.Limit() || typed.Offset() != plain.Offset() {
+			t.Errorf("query %q returned limit %d and offset %d; want limit %d and offset %d as %q", entry.plain, plain.Limit(), plain.Offset(), typed.Limit(), typed.Offset(), entry.typed)
+		}
+		if got, want := planStatement(t, s, plain).String(), planStatement(t, s, typed).String(); got != want {
+			t.Errorf("query %q returned the wrong plan; got\n%s\nwant\n%s", entry.plain, got, want)
+		}
+	}
+}
+
+// anchorsStore wraps the graphs of a store to record the anchors of the lookup
+// options provided to their lookups.
+type anchorsStore struct {
+	storage.Store
+	mu      sync.Mutex
+	anchors []string
+}
+
+// record keeps the anchors of the provided lookup options.
+func (s *anchorsStore) record(lo *storage.LookupOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.anchors = append(s.anchors, anchorString(lo.LowerAnchor)+","+anchorString(lo.UpperAnchor))
+}
+
+// anchorString returns the RFC3339 form of the anchor, or an empty string if
+// the anchor is nil.
+func anchorString(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// Graph returns the wrapped graph.
+func (s *anchorsStore) Graph(ctx context.Context, id string) (storage.Graph, error) {
+	g, err := s.Store.Graph(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &anchorsGraph{Graph: g, s: s}, nil
+}
+
+// anchorsGraph records the anchors of the lookups of a graph.
+type anchorsGraph struct {
+	storage.Graph
+	s *anchorsStore
+}
+
+// Objects records the anchors and forwards the lookup to the wrapped graph.
+func (g *anchorsGraph) Objects(ctx context.Context, s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions, objs chan<- *triple.Object) error {
+	g.s.record(lo)
+	return g.Graph.Objects(ctx, s, p, lo, objs)
+}
+
+// TriplesForSubject records the anchors and forwards the lookup to the wrapped
+// graph.
+func (g *anchorsGraph) TriplesForSubject(ctx context.Context, s *node.Node, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	g.s.record(lo)
+	return g.Graph.TriplesForSubject(ctx, s, lo, trpls)
+}
+
+// TriplesForObject records the anchors and forwards the lookup to the wrapped
+// graph.
+func (g *anchorsGraph) TriplesForObject(ctx context.Context, o *triple.Object, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	g.s.record(lo)
+	return g.Graph.TriplesForObject(ctx, o, lo, trpls)
+}
+
+// Triples records the anchors and forwards the lookup to the wrapped graph.
+func (g *anchorsGraph) Triples(ctx context.Context, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	g.s.record(lo)
+	return g.Graph.Triples(ctx, lo, trpls)
+}
+
+func TestPlannerAnchorBoundsOnClauses(t *testing.T) {
+	const (
+		feb = "2016-02-01T00:00:00-08:00"
+		mar = "2016-03-01T00:00:00-08:00"
+	)
+	testTable := []struct {
+		q        string
+		bindings []string
+		want     []string
+		// anchors contains the lower and upper anchors expected on every
+		// lookup, separated by a comma.
+		anchors string
+	}{
+		// Predicate anchor bounds are pushed down to the lookups.
+		{
+			q:        `select ?o from ?test where {/u<peter> "bought"@[` + feb + `,] ?o};`,
+			bindings: []string{"?o"},
+			want:     []string{"/c<model s>", "/c<model x>", "/c<model y>"},
+			anchors:  feb + ",",
+		},
+		{
+			q:        `select ?o from ?test where {/u<peter> "bought"@[,` + feb + `] ?o};`,
+			bindings: []string{"?o"},
+			want:     []string{"/c<mini>", "/c<model s>"},
+			anchors:  "," + feb,
+		},
+		{
+			q:        `select ?o from ?test where {/u<peter> "bought"@[` + feb + `,` + mar + `] ?o};`,
+			bindings: []string{"?o"},
+			want:     []string{"/c<model s>", "/c<model x>"},
+			anchors:  feb + "," + mar,
+		},
+		{
+			q:        `select ?s, ?o from ?test where {?s "bought"@[` + feb + `,] ?o};`,
+			bindings: []string{"?s", "?o"},
+			want:     []string{"/u<peter>\t/c<model s>", "/u<peter>\t/c<model x>", "/u<peter>\t/c<model y>"},
+			anchors:  feb + ",",
+		},
+		{
+			q:        `select ?s from ?test where {?s "bought"@[,` + feb + `] /c<mini>};`,
+			bindings: []string{"?s"},
+			want:     []string{"/u<peter>"},
+			anchors:  "," + feb,
+		},
+		{
+			q:        `select ?o from ?test where {/u<peter> "bought"@[` + feb + `,] ?o} before ""@[` + mar + `];`,
+			bindings: []string{"?o"},
+			want:     []string{"/c<model s>", "/c<model x>"},
+			anchors:  feb + "," + mar,
+		},
+		// Object anchor bounds filter the retrieved triples.
+		{
+			q:        `select ?o from ?test where {/l<barcelona> "predicate"@[] "turned"@[` + feb + `,] as ?o};`,
+			bindings: []string{"?o"},
+			want:     []string{`"turned"@[2016-02-01T00:00:00-08:00]`, `"turned"@[2016-03-01T00:00:00-08:00]`, `"turned"@[2016-04-01T00:00:00-08:00]`},
+			anchors:  ",",
+		},
+		{
+			q:        `select ?o from ?test where {/l<barcelona> "predicate"@[] "turned"@[,` + feb + `] as ?o};`,
+			bindings: []string{"?o"},
+			want:     []string{`"turned"@[2016-01-01T00:00:00-08:00]`, `"turned"@[2016-02-01T00:00:00-08:00]`},
+			anchors:  ",",
+		},
+		{
+			q:        `select ?o from ?test where {/l<barcelona> "predicate"@[] "turned"@[` + feb + `,` + mar + `] as ?o};`,
+			bindings: []string{"?o"},
+			want:     []string{`"turned"@[2016-02-01T00:00:00-08:00]`, `"turned"@[2016-03-01T00:00:00-08:00]`},
+			anchors:  ",",
+		},
+		{
+			q:        `select ?s, ?o from ?test where {?s ?p "turned"@[,` + feb + `] as ?o};`,
+			bindings: []string{"?s", "?o"},
+			want:     []string{"/l<barcelona>\t" + `"turned"@[2016-01-01T00:00:00-08:00]`, "/l<barcelona>\t" + `"turned"@[2016-02-01T00:00:00-08:00]`},
+			anchors:  ",",
+		},
+	}
+	for _, entry := range testTable {
+		s := &anchorsStore{Store: populateTestStore(t)}
+		tbl := mustRunQuery(t, s, entry.q)
+		got := rowStrings(tbl, entry.bindings)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %q, want %q", entry.q, got, entry.want)
+		}
+		if len(s.anchors) == 0 {
+			t.Errorf("planner.Execute did not issue any lookup for query %q", entry.q)
+		}
+		for _, a := range s.anchors {
+			if a != entry.anchors {
+				t.Errorf("planner.Execute looked up query %q with anchors %q; want %q", entry.q, a, entry.anchors)
+			}
+		}
+	}
+}
+
+func TestPlannerBoundPredicateBindsAnchor(t *testing.T) {
+	q := `select ?o, ?t from ?test where {/u<peter> "bought"@[2015-01-01T00:00:00-08:00,2017-01-01T00:00:00-08:00] AT ?t ?o};`
+	tbl := mustRunQuery(t, populateTestStore(t), q)
+	if got, want := len(tbl.Rows()), 4; got != want {
+		t.Fatalf("planner.Excecute failed to return the expected number of rows for query %q; got %d want %d\nGot:\n%v\n", q, got, want, tbl)
+	}
+	seen := make(map[string]bool)
+	for _, r := range tbl.Rows() {
+		c, ok := r["?t"]
+		if !ok || c.T == nil {
+			t.Fatalf("planner.Execute failed to bind the anchor ?t in row %v", r)
+		}
+		seen[c.T.String()] = true
+	}
+	if got, want := len(seen), 4; got != want {
+		t.Errorf("planner.Execute returned %d distinct anchors for ?t; want %d\nGot:\n%v\n", got, want, tbl)
+	}
+}
+
+func TestPlannerObjectAnchorBinding(t *testing.T) {
+	s := populateTestStore(t)
+	want := []string{
+		"2016-01-01T00:00:00-08:00",
+		"2016-02-01T00:00:00-08:00",
+		"2016-03-01T00:00:00-08:00",
+		"2016-04-01T00:00:00-08:00",
+	}
+	for _, q := range []string{
+		`select ?t from ?test where {/l<barcelona> "predicate"@[] ?o at ?t};`,
+		`select ?t from ?test where {/l<barcelona> "predicate"@[] ?o as ?x at ?t};`,
+		`select ?t from ?test where {/l<barcelona> "predicate"@[] "turned"@[?t]};`,
+		// Objects other than temporal predicates do not match.
+		`select ?t from ?test where {?s ?p ?o at ?t};`,
+	} {
+		tbl := mustRunQuery(t, s, q)
+		var got []string
+		for _, r := range tbl.Rows() {
+			c, ok := r["?t"]
+			if !ok || c.T == nil {
+				t.Fatalf("planner.Execute failed to bind the object anchor ?t in row %v for query %q", r, q)
+			}
+			got = append(got, c.T.Format(time.RFC3339))
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("planner.Execute returned the wrong anchors for query %q; got %v, want %v", q, got, want)
+		}
+	}
+}
+
+func TestPlannerBindingErrorDoesNotBlock(t *testing.T) {
+	q := `select ?s, ?t from ?test where {?s ?p at ?t ?o};`
+	if _, err := runQuery(t, populateTestStore(t), q); err == nil {
+		t.Errorf("planner.Execute should have failed to bind the anchor of immutable predicates for query %q", q)
+	}
+}
+
+func TestPlannerMinMaxAggregation(t *testing.T) {
+	testTable := []struct {
+		q    string
+		want string
+		err  bool
+	}{
+		{
+			q:    `select ?p, min(?t) as ?first from ?test where {/u<peter> as ?p "bought"@[,] AT ?t ?o} group by ?p;`,
+			want: "2016-01-01T00:00:00-08:00",
+		},
+		{
+			q:    `select ?p, max(?t) as ?last from ?test where {/u<peter> as ?p "bought"@[,] AT ?t ?o} group by ?p;`,
+			want: "2016-04-01T00:00:00-08:00",
+		},
+		{
+			q:    `select ?s, max(?o) as ?last from ?test where {/l<barcelona> as ?s "predicate"@[] ?o} group by ?s;`,
+			want: `"turned"@[2016-04-01T00:00:00-08:00]`,
+		},
+		{
+			// Nodes cannot be compared.
+			q:   `select ?s, max(?o) as ?m from ?test where {?s "parent_of"@[] ?o} group by ?s;`,
+			err: true,
+		},
+	}
+	s := populateTestStore(t)
+	for _, entry := range testTable {
+		tbl, err := runQuery(t, s, entry.q)
+		if entry.err {
+			if err == nil {
+				t.Errorf("planner.Execute should have failed to aggregate incomparable values for query %q", entry.q)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", entry.q, err)
+		}
+		if got, want := len(tbl.Rows()), 1; got != want {
+			t.Fatalf("planner.Excecute failed to return the expected number of rows for query %q; got %d want %d\nGot:\n%v\n", entry.q, got, want, tbl)
+		}
+		b := tbl.Bindings()[1]
+		if got, want := tbl.Rows()[0][b].String(), entry.want; got != want {
+			t.Errorf("planner.Execute returned the wrong aggregation for query %q; got %q, want %q", entry.q, got, want)
+		}
+	}
+}
+
+const priceTriples = `/u<joe> "bought"@[] /i<book>
+	/u<joe> "bought"@[] /i<pen>
+	/u<mary> "bought"@[] /i<lamp>
+	/u<mary> "bought"@[] /i<desk>
+	/i<book> "price"@[] "10"^^type:int64
+	/i<pen> "price"@[] "3"^^type:int64
+	/i<lamp> "price"@[] "20.5"^^type:float64
+	/i<desk> "price"@[] "100"^^type:int64
+	/i<book> "name"@[] "book"^^type:text`
+
+// populatePriceStore returns a store with the price triples in the "?test"
+// graph.
+func populatePriceStore(t *testing.T) storage.Store {
+	return populateTestStoreWith(t, priceTriples)
+}
+
+func TestPlannerSumAvgAggregation(t *testing.T) {
+	s := populatePriceStore(t)
+	testTable := []struct {
+		q    string
+		want map[string]string
+		err  bool
+	}{
+		{
+			q: `SELECT ?owner, SUM(?price) AS ?total FROM ?test WHERE {?owner "bought"@[] ?item . ?item "price"@[] ?price} GROUP BY ?owner;`,
+			want: map[string]string{
+				"/u<joe>":  `"13"^^type:int64`,
+				"/u<mary>": `"120.5"^^type:float64`,
+			},
+		},
+		{
+			q: `SELECT ?owner, AVG(?price) AS ?total FROM ?test WHERE {?owner "bought"@[] ?item . ?item "price"@[] ?price} GROUP BY ?owner;`,
+			want: map[string]string{
+				"/u<joe>":  `"6.5"^^type:float64`,
+				"/u<mary>": `"60.25"^^type:float64`,
+			},
+		},
+		{
+			q:   `SELECT ?item, SUM(?name) AS ?total FROM ?test WHERE {?item "name"@[] ?name} GROUP BY ?item;`,
+			err: true,
+		},
+		{
+			q:   `SELECT ?owner, AVG(?item) AS ?total FROM ?test WHERE {?owner "bought"@[] ?item} GROUP BY ?owner;`,
+			err: true,
+		},
+	}
+	for _, entry := range testTable {
+		tbl, err := runQuery(t, s, entry.q)
+		if entry.err {
+			if err == nil {
+				t.Errorf("planner.Execute should have failed for query %q", entry.q)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", entry.q, err)
+		}
+		if got, want := len(tbl.Rows()), len(entry.want); got != want {
+			t.Fatalf("planner.Excecute failed to return the expected number of rows for query %q; got %d want %d\nGot:\n%v\n", entry.q, got, want, tbl)
+		}
+		for _, r := range tbl.Rows() {
+			if got, want := r["?total"].String(), entry.want[r["?owner"].String()]; got != want {
+				t.Errorf("planner.Execute returned the wrong aggregation for %s in query %q; got %s, want %s", r["?owner"], entry.q, got, want)
+			}
+		}
+	}
+}
+
+const decimalPriceTriples = `/u<joe> "bought"@[] /i<book>
+	/u<joe> "bought"@[] /i<pen>
+	/u<joe> "bought"@[] /i<ink>
+	/u<mary> "bought"@[] /i<lamp>
+	/i<book> "price"@[] "0.10"^^type:decimal
+	/i<pen> "price"@[] "0.2"^^type:decimal
+	/i<ink> "price"@[] "1.00"^^type:decimal
+	/i<lamp> "price"@[] "19.99"^^type:decimal`
+
+func TestPlannerDecimalLiterals(t *testing.T) {
+	s := populateTestStoreWith(t, decimalPriceTriples)
+	testTable := []struct {
+		q    string
+		want []string
+	}{
+		{
+			q:    `SELECT ?owner, SUM(?price) AS ?total FROM ?test WHERE {?owner "bought"@[] ?item . ?item "price"@[] ?price} GROUP BY ?owner ORDER BY ?owner;`,
+			want: []string{`"1.3"^^type:decimal`, `"19.99"^^type:decimal`},
+		},
+		{
+			q:    `SELECT ?owner, AVG(?price) AS ?total FROM ?test WHERE {?owner "bought"@[] ?item . ?item "price"@[] ?price} GROUP BY ?owner ORDER BY ?owner;`,
+			want: []string{`"0.43333333333333333333333333333333"^^type:decimal`, `"19.99"^^type:decimal`},
+		},
+		{
+			q:    `SELECT ?item, ?price FROM ?test WHERE {?item "price"@[] ?price} ORDER BY ?price DESC;`,
+			want: []string{`"19.99"^^type:decimal`, `"1"^^type:decimal`, `"0.2"^^type:decimal`, `"0.1"^^type:decimal`},
+		},
+		{
+			q:    `SELECT ?item, ?price FROM ?test WHERE {?item "price"@[] ?price . FILTER(?price = "1.0"^^type:decimal)};`,
+			want: []string{`"1"^^type:decimal`},
+		},
+		{
+			q:    `SELECT ?item, ?price FROM ?test WHERE {?item "price"@[] "1"^^type:decimal . ?item "price"@[] ?price};`,
+			want: []string{`"1"^^type:decimal`},
+		},
+	}
+	for _, entry := range testTable {
+		tbl, err := runQuery(t, s, entry.q)
+		if err != nil {
+			t.Fatalf("planner.Excecute failed for query %q with error %v", entry.q, err)
+		}
+		var got []string
+		for _, r := range tbl.Rows() {
+			b := tbl.Bindings()[1]
+			got = append(got, r[b].String())
+		}
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong values for query %q; got %v, want %v", entry.q, got, entry.want)
+		}
+	}
+}
+
+func TestPlannerSelectAll(t *testing.T) {
+	s := populatePriceStore(t)
+	tbl := mustRunQuery(t, s, `SELECT * FROM ?test WHERE {?owner "bought"@[] ?item . ?item "price"@[] ?price} ORDER BY ?owner, ?item;`)
+	if got, want := tbl.Bindings(), []string{"?owner", "?item", "?price"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SELECT * returned bindings %v; want %v", got, want)
+	}
+	if got, want := len(tbl.Rows()), 4; got != want {
+		t.Fatalf("SELECT * returned %d rows; want %d\n%s", got, want, tbl)
+	}
+	if got, want := tbl.Rows()[0]["?price"].String(), `"10"^^type:int64`; got != want {
+		t.Errorf("SELECT * returned the wrong first price; got %s, want %s", got, want)
+	}
+	want := mustRunQuery(t, s, `SELECT ?owner, ?item, ?price FROM ?test WHERE {?owner "bought"@[] ?item . ?item "price"@[] ?price} ORDER BY ?owner, ?item;`)
+	if !reflect.DeepEqual(tbl.Rows(), want.Rows()) {
+		t.Errorf("SELECT * returned\n%s\nwant\n%s", tbl, want)
+	}
+}
+
+const typedPurchaseTriples = `/u<joe> "bought"@[] /i<book>
+	/u<joe> "bought"@[] /i<novel>
+	/u<joe> "bought"@[] /i<pen>
+	/u<mary> "bought"@[] /i<lamp>
+	/u<mary> "bought"@[] /i<atlas>
+	/i<book> "is_a"@[] /t<book>
+	/i<novel> "is_a"@[] /t<book>
+	/i<atlas> "is_a"@[] /t<book>
+	/i<pen> "is_a"@[] /t<stationery>
+	/i<lamp> "is_a"@[] /t<furniture>`
+
+func TestPlannerOrderByAggregation(t *testing.T) {
+	s := populateTestStoreWith(t, typedPurchaseTriples+"\n"+priceTriples+"\n/u<joe> \"bought\"@[] /i<ink>")
+	testTable := []struct {
+		q    string
+		want []string
+	}{
+		{
+			q:    `SELECT ?owner, COUNT(?item) AS ?n FROM ?test WHERE {?owner "bought"@[] ?item} GROUP BY ?owner ORDER BY ?n DESC;`,
+			want: []string{"/u<joe>\t\"4\"^^type:int64", "/u<mary>\t\"3\"^^type:int64"},
+		},
+		{
+			q:    `SELECT ?owner, COUNT(?item) AS ?n FROM ?test WHERE {?owner "bought"@[] ?item} GROUP BY ?owner ORDER BY ?n ASC;`,
+			want: []string{"/u<mary>\t\"3\"^^type:int64", "/u<joe>\t\"4\"^^type:int64"},
+		},
+		{
+			q:    `SELECT ?owner, SUM(?price) AS ?n FROM ?test WHERE {?owner "bought"@[] ?item . ?item "price"@[] ?price} GROUP BY ?owner ORDER BY ?n DESC LIMIT 1;`,
+			want: []string{"/u<mary>\t\"120.5\"^^type:float64"},
+		},
+		{
+			q:    `SELECT ?owner, COUNT(DISTINCT ?item) AS ?n FROM ?test WHERE {?owner "bought"@[] ?item} GROUP BY ?owner ORDER BY ?n DESC, ?owner DESC, ?n DESC;`,
+			want: []string{"/u<joe>\t\"4\"^^type:int64", "/u<mary>\t\"3\"^^type:int64"},
+		},
+	}
+	for _, entry := range testTable {
+		tbl := mustRunQuery(t, s, entry.q)
+		if got := rowStrings(tbl, []string{"?owner", "?n"}); !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %q, want %q", entry.q, got, entry.want)
+		}
+	}
+}
+
+const carTriples = `/maker<tesla> "makes"@[] /car<model s>
+	/maker<mini> "makes"@[] /c<mini>
+	/maker<tesla> "makes"@[] /c<model s>
+	/maker<tesla> "makes"@[] /car<model 3>
+	/maker<mini> "makes"@[] /c<cooper>`
+
+func TestPlannerNodeTypeAndID(t *testing.T) {
+	s := populateTestStoreWith(t, carTriples)
+	testTable := []struct {
+		q    string
+		bs   []string
+		want []string
+	}{
+		{
+			q:  `SELECT ?c, TYPE(?c) AS ?t, ID(?c) AS ?i FROM ?test WHERE {?m "makes"@[] ?c} ORDER BY ?t DESC, ?i;`,
+			bs: []string{"?t", "?i", "?c"},
+			want: []string{
+				"\"/car\"^^type:text\t\"model 3\"^^type:text\t/car<model 3>",
+				"\"/car\"^^type:text\t\"model s\"^^type:text\t/car<model s>",
+				"\"/c\"^^type:text\t\"cooper\"^^type:text\t/c<cooper>",
+				"\"/c\"^^type:text\t\"mini\"^^type:text\t/c<mini>",
+				"\"/c\"^^type:text\t\"model s\"^^type:text\t/c<model s>",
+			},
+		},
+		{
+			q:    `SELECT ID(?c) AS ?i FROM ?test WHERE {/maker<tesla> "makes"@[] ?c} ORDER BY ?i;`,
+			bs:   []string{"?i"},
+			want: []string{"\"model 3\"^^type:text", "\"model s\"^^type:text", "\"model s\"^^type:text"},
+		},
+		{
+			q:    `SELECT TYPE(?c) AS ?t, COUNT(?c) AS ?n FROM ?test WHERE {?m "makes"@[] ?c} GROUP BY ?t ORDER BY ?t;`,
+			bs:   []string{"?t", "?n"},
+			want: []string{"\"/c\"^^type:text\t\"3\"^^type:int64", "\"/car\"^^type:text\t\"2\"^^type:int64"},
+		},
+	}
+	for _, entry := range testTable {
+		tbl := mustRunQuery(t, s, entry.q)
+		if got := rowStrings(tbl, entry.bs); !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %q, want %q", entry.q, got, entry.want)
+		}
+	}
+	q := `SELECT TYPE(?p) AS ?t FROM ?test WHERE {?m ?p ?c};`
+	if _, err := runQuery(t, s, q); err == nil {
+		t.Errorf("planner.Execute(%q) should have failed since predicates have no node type", q)
+	}
+}
+
+func TestPlannerAsOf(t *testing.T) {
+	s := populateTestStoreWith(t, originalTriples)
+	testTable := []struct {
+		q    string
+		want []string
+	}{
+		{
+			q:    `SELECT ?o FROM ?test AS OF ""@[2016-02-15T00:00:00-08:00] WHERE {/u<peter> "bought"@[?t] ?o} ORDER BY ?o;`,
+			want: []string{"/c<mini>", "/c<model s>"},
+		},
+		{
+			q:    `SELECT ?o FROM ?test AS OF ""@[2016-02-01T00:00:00-08:00] WHERE {/u<peter> "bought"@[?t] ?o} ORDER BY ?o;`,
+			want: []string{"/c<mini>", "/c<model s>"},
+		},
+		{
+			q:    `SELECT ?o FROM ?test AS OF ""@[2015-01-01T00:00:00-08:00] WHERE {?o "is_a"@[] /t<car>} ORDER BY ?o;`,
+			want: []string{"/c<mini>", "/c<model s>", "/c<model x>", "/c<model y>"},
+		},
+		{
+			q:    `SELECT ?o FROM ?test AS OF ""@[2016-03-15T00:00:00-08:00] WHERE {/u<peter> "bought"@[,2016-01-15T00:00:00-08:00] ?o};`,
+			want: []string{"/c<mini>"},
+		},
+		{
+			q:    `SELECT ?o FROM ?test AS OF ""@[2016-03-15T00:00:00-08:00] WHERE {/u<peter> "bought"@[?t] ?o} BEFORE ""@[2016-01-15T00:00:00-08:00];`,
+			want: []string{"/c<mini>"},
+		},
+	}
+	for _, entry := range testTable {
+		tbl := mustRunQuery(t, s, entry.q)
+		if got := rowStrings(tbl, []string{"?o"}); !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %q, want %q", entry.q, got, entry.want)
+		}
+	}
+}
+
+func TestPlannerPredicateAlternatives(t *testing.T) {
+	ts := `/u<joe> "parent_of"@[] /u<mary>
+		/u<joe> "guardian_of"@[] /u<mary>
+		/u<ann> "guardian_of"@[] /u<peter>
+		/u<peter> "likes"@[] /u<mary>
+		/u<eve> "likes"@[] /u<mary>
+		/u<mary> "bought"@[2016-01-01T00:00:00Z] /c<mini>`
+	s, ctx := populateTestStoreWith(t, ts), context.Background()
+	testTable := []struct {
+		q    string
+		bs   []string
+		want []string
+	}{
+		{
+			q:    `SELECT ?s, ?o FROM ?test WHERE {?s ("parent_of"@[] | "guardian_of"@[]) ?o} ORDER BY ?s, ?o;`,
+			bs:   []string{"?s", "?o"},
+			want: []string{"/u<ann>\t/u<peter>", "/u<joe>\t/u<mary>", "/u<joe>\t/u<mary>"},
+		},
+		{
+			q:    `SELECT DISTINCT ?s, ?o FROM ?test WHERE {?s ("parent_of"@[] | "guardian_of"@[]) ?o} ORDER BY ?s, ?o;`,
+			bs:   []string{"?s", "?o"},
+			want: []string{"/u<ann>\t/u<peter>", "/u<joe>\t/u<mary>"},
+		},
+		{
+			q:    `SELECT ?p FROM ?test WHERE {/u<joe> ("parent_of"@[] | "guardian_of"@[]) AS ?p /u<mary>};`,
+			bs:   []string{"?p"},
+			want: []string{`"parent_of"@[]`, `"guardian_of"@[]`},
+		},
+		{
+			q:    `SELECT ?o FROM ?test WHERE {?s ("likes"@[] | "bought"@[2016-01-01T00:00:00Z]) ?o} ORDER BY ?o;`,
+			bs:   []string{"?o"},
+			want: []string{"/c<mini>", "/u<mary>", "/u<mary>"},
+		},
+		{
+			q:    `SELECT ?x FROM ?test WHERE {/u<ann> ("parent_of"@[] | "guardian_of"@[]) /u<peter> . ?x "likes"@[] /u<mary>} ORDER BY ?x;`,
+			bs:   []string{"?x"},
+			want: []string{"/u<eve>", "/u<peter>"},
+		},
+		{
+			q:    `SELECT ?s, ?g FROM ?test WHERE {?s "likes"@[] ?o . ?g ("parent_of"@[] | "guardian_of"@[]) ?o} ORDER BY ?s;`,
+			bs:   []string{"?s", "?g"},
+			want: []string{"/u<eve>\t/u<joe>", "/u<eve>\t/u<joe>", "/u<peter>\t/u<joe>", "/u<peter>\t/u<joe>"},
+		},
+		{
+			q:    `SELECT ?s FROM ?test WHERE {?s "likes"@[] /u<mary> . !{?x ("parent_of"@[] | "guardian_of"@[]) ?s}};`,
+			bs:   []string{"?s"},
+			want: []string{"/u<eve>"},
+		},
+		{
+			q:    `SELECT ?s FROM ?test WHERE {?s ("parent_of"@[] | "likes"@[]) /u<peter>};`,
+			bs:   []string{"?s"},
+			want: nil,
+		},
+	}
+	for _, entry := range testTable {
+		tbl := mustRunQuery(t, s, entry.q)
+		if got := rowStrings(tbl, entry.bs); !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %q, want %q", entry.q, got, entry.want)
+		}
+	}
+	q := `DELETE WHERE {?s ("parent_of"@[] | "guardian_of"@[]) ?o} FROM ?test;`
+	if _, err := New(ctx, s, parseQuery(t, q), 0, nil); err == nil {
+		t.Errorf("planner.New(%q) should have failed since DELETE WHERE does not support predicate alternatives", q)
+	}
+}
+
+func TestPlannerGroupByMultipleBindings(t *testing.T) {
+	s := populateTestStoreWith(t, typedPurchaseTriples)
+	q := `SELECT ?owner, ?type, COUNT(?item) AS ?n FROM ?test WHERE {?owner "bought"@[] ?item . ?item "is_a"@[] ?type} GROUP BY ?owner, ?type ORDER BY ?owner, ?type;`
+	bs := []string{"?owner", "?type", "?n"}
+	want := []string{
+		"/u<joe>\t/t<book>\t\"2\"^^type:int64",
+		"/u<joe>\t/t<stationery>\t\"1\"^^type:int64",
+		"/u<mary>\t/t<book>\t\"1\"^^type:int64",
+		"/u<mary>\t/t<furniture>\t\"1\"^^type:int64",
+	}
+	if got := rowStrings(mustRunQuery(t, s, q), bs); !reflect.DeepEqual(got, want) {
+		t.Errorf("planner.Execute returned the wrong groups for query %q; got %v, want %v", q, got, want)
+	}
+}
+
+const namedCarTriples = `/c<s> "name"@[] "Model S"^^type:text
+	/c<s2> "name"@[] "MODEL S"^^type:text
+	/c<y> "name"@[] "Model Y"^^type:text
+	/c<s> "is_a"@[] /t<car>
+	/c<s2> "is_a"@[] /t<car>
+	/c<y> "is_a"@[] /t<car>`
+
+func TestPlannerIgnoreCase(t *testing.T) {
+	s := populateTestStoreWith(t, namedCarTriples)
+	testTable := []struct {
+		q    string
+		bs   []string
+		want []string
+	}{
+		{
+			q:  `SELECT ?c FROM ?test WHERE {?c "name"@[] "model s"^^type:text};`,
+			bs: []string{"?c"},
+		},
+		{
+			q:    `SELECT ?c FROM ?test WHERE {?c "name"@[] "model s"^^type:text} ignore_case;`,
+			bs:   []string{"?c"},
+			want: []string{"/c<s2>", "/c<s>"},
+		},
+		{
+			q:    `SELECT ?c, ?n FROM ?test WHERE {?c ?p "model s"^^type:text AS ?n} IGNORE_CASE;`,
+			bs:   []string{"?c", "?n"},
+			want: []string{"/c<s2>\t\"MODEL S\"^^type:text", "/c<s>\t\"Model S\"^^type:text"},
+		},
+		{
+			q:    `SELECT ?c FROM ?test WHERE {?c "is_a"@[] /t<car> . ?c "name"@[] "model s"^^type:text} ignore_case;`,
+			bs:   []string{"?c"},
+			want: []string{"/c<s2>", "/c<s>"},
+		},
+		{
+			q:    `SELECT ?n FROM ?test WHERE {/c<s> "name"@[] "MODEL s"^^type:text . /c<s> "name"@[] ?n} ignore_case;`,
+			bs:   []string{"?n"},
+			want: []string{`"Model S"^^type:text`},
+		},
+		{
+			q:  `SELECT ?n FROM ?test WHERE {/c<s> "name"@[] "MODEL s"^^type:text . /c<s> "name"@[] ?n};`,
+			bs: []string{"?n"},
+		},
+		{
+			q:    `SELECT ?c FROM ?test WHERE {?c "is_a"@[] /t<car> . !{?c "name"@[] "model s"^^type:text}} ignore_case;`,
+			bs:   []string{"?c"},
+			want: []string{"/c<y>"},
+		},
+		{
+			q:    `SELECT ?c FROM ?test WHERE {?c "is_a"@[] /t<car> . not exists {?c "name"@[] "model y"^^type:text}} ignore_case;`,
+			bs:   []string{"?c"},
+			want: []string{"/c<s2>", "/c<s>"},
+		},
+	}
+	for _, entry := range testTable {
+		got := rowStrings(mustRunQuery(t, s, entry.q), entry.bs)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %v, want %v", entry.q, got, entry.want)
+		}
+	}
+}
+
+func TestPlannerFilter(t *testing.T) {
+	prices, purchases := populatePriceStore(t), populateTestStore(t)
+	testTable := []struct {
+		s    storage.Store
+		q    string
+		bs   []string
+		want []string
+		err  bool
+	}{
+		{
+			s:    prices,
+			q:    `SELECT ?item, ?price FROM ?test WHERE {?item "price"@[] ?price . FILTER(?price > "10"^^type:int64)};`,
+			bs:   []string{"?item"},
+			want: []string{"/i<desk>", "/i<lamp>"},
+		},
+		{
+			s:    prices,
+			q:    `SELECT ?item FROM ?test WHERE {?item "price"@[] ?price . FILTER(?price >= "10"^^type:int64)};`,
+			bs:   []string{"?item"},
+			want: []string{"/i<book>", "/i<desk>", "/i<lamp>"},
+		},
+		{
+			s:    prices,
+			q:    `SELECT ?item FROM ?test WHERE {?item "price"@[] ?price . FILTER(?price <= "10"^^type:int64)};`,
+			bs:   []string{"?item"},
+			want: []string{"/i<book>", "/i<pen>"},
+		},
+		{
+			s:    prices,
+			q:    `SELECT ?item FROM ?test WHERE {?item "price"@[] ?price . FILTER(?price != "10"^^type:int64)};`,
+			bs:   []string{"?item"},
+			want: []string{"/i<desk>", "/i<lamp>", "/i<pen>"},
+		},
+		{
+			s:    prices,
+			q:    `SELECT ?item FROM ?test WHERE {?item "name"@[] ?name . FILTER(?name = "book"^^type:text)};`,
+			bs:   []string{"?item"},
+			want: []string{"/i<book>"},
+		},
+		{
+			s:    prices,
+			q:    `SELECT ?owner, ?item FROM ?test WHERE {?owner "bought"@[] ?item . ?item "price"@[] ?price . FILTER((?price > "5"^^type:int64) and (?price < "50"^^type:int64))};`,
+			bs:   []string{"?owner", "?item"},
+			want: []string{"/u<joe>\t/i<book>", "/u<mary>\t/i<lamp>"},
+		},
+		{
+			s:    prices,
+			q:    `SELECT ?owner, SUM(?price) AS ?total FROM ?test WHERE {?owner "bought"@[] ?item . ?item "price"@[] ?price . FILTER(not (?price > "50"^^type:int64))} GROUP BY ?owner;`,
+			bs:   []string{"?owner", "?total"},
+			want: []string{`/u<joe>	"13"^^type:int64`, `/u<mary>	"20.5"^^type:float64`},
+		},
+		{
+			s:    purchases,
+			q:    `SELECT ?o FROM ?test WHERE {/u<peter> "bought"@[,] AT ?t ?o . FILTER(?t > "bought"@[2016-02-01T00:00:00-08:00])};`,
+			bs:   []string{"?o"},
+			want: []string{"/c<model x>", "/c<model y>"},
+		},
+		{
+			s:    purchases,
+			q:    `SELECT ?o1, ?o2 FROM ?test WHERE {/u<peter> "bought"@[?t1] ?o1 . /u<peter> "bought"@[?t2] ?o2 . FILTER(?t1 < ?t2) . FILTER(?o1 = /c<mini>)};`,
+			bs:   []string{"?o1", "?o2"},
+			want: []string{"/c<mini>\t/c<model s>", "/c<mini>\t/c<model x>", "/c<mini>\t/c<model y>"},
+		},
+		{ // Issue 40 (https://github.com/google/badwolf/issues/40)
+			s:    purchases,
+			q:    `SELECT ?item, ?t FROM ?test WHERE {?item "in"@[?t] /room<Bedroom> . FILTER(?t > "2016-04-10T4:22:00Z"^^type:text)};`,
+			bs:   []string{"?item", "?t"},
+			want: []string{"/item/book<000>\t2016-04-10T04:25:00Z"},
+		},
+		{
+			s:    purchases,
+			q:    `SELECT ?o FROM ?test WHERE {/item/book<000> "in"@[?t] ?o . FILTER(?t <= "2016-04-10T04:23:00.000000000Z"^^type:text)};`,
+			bs:   []string{"?o"},
+			want: []string{"/room<Hallway>", "/room<Kitchen>"},
+		},
+		{
+			s:   purchases,
+			q:   `SELECT ?o FROM ?test WHERE {/item/book<000> "in"@[?t] ?o . FILTER(?t > "noon"^^type:text)};`,
+			err: true,
+		},
+		{
+			s:   prices,
+			q:   `SELECT ?item FROM ?test WHERE {?item "price"@[] ?price . FILTER(?price > "10"^^type:text)};`,
+			err: true,
+		},
+		{
+			s:    prices,
+			q:    `SELECT ?item FROM ?test WHERE {?item "name"@[] ?name . FILTER(fuzzy(?name, "bok"^^type:text, "1"^^type:int64))};`,
+			bs:   []string{"?item"},
+			want: []string{"/i<book>"},
+		},
+		{
+			s:  prices,
+			q:  `SELECT ?item FROM ?test WHERE {?item "name"@[] ?name . FILTER(fuzzy(?name, "bok"^^type:text, "0"^^type:int64))};`,
+			bs: []string{"?item"},
+		},
+		{
+			s:   prices,
+			q:   `SELECT ?item FROM ?test WHERE {?item "price"@[] ?price . FILTER(fuzzy(?price, "10"^^type:text, "1"^^type:int64))};`,
+			err: true,
+		},
+		{
+			s:    prices,
+			q:    `SELECT ?item FROM ?test WHERE {?item "name"@[] ?name . FILTER(?name =~ "^b.*k$"^^type:text)};`,
+			bs:   []string{"?item"},
+			want: []string{"/i<book>"},
+		},
+		{
+			s:  prices,
+			q:  `SELECT ?item FROM ?test WHERE {?item "name"@[] ?name . FILTER(?name =~ "^B"^^type:text)};`,
+			bs: []string{"?item"},
+		},
+		{
+			s:   prices,
+			q:   `SELECT ?item FROM ?test WHERE {?item "price"@[] ?price . FILTER(?price =~ "1"^^type:text)};`,
+			err: true,
+		},
+	}
+	for _, entry := range testTable {
+		tbl, err := runQuery(t, entry.s, entry.q)
+		if entry.err {
+			if err == nil {
+				t.Errorf("planner.Execute should have failed for query %q", entry.q)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("planner.Execute failed for query %q with error %v", entry.q, err)
+		}
+		got := rowStrings(tbl, entry.bs)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %v, want %v", entry.q, got, entry.want)
+		}
+	}
+	q := `SELECT ?item FROM ?test WHERE {?item "price"@[] ?price . FILTER((?price > "5"^^type:int64) and (?price < "50"^^type:int64))};`
+	want := "filter rows using\n\tFILTER((?price > \"5\"^^type:int64) and (?price < \"50\"^^type:int64))\n"
+	if got := planQuery(t, prices, q).String(); !strings.Contains(got, want) {
+		t.Errorf("planner.String() for query %q should contain %q; got\n%s", q, want, got)
+	}
+}
+
+// scanOnlyStore hides the optional interfaces implemented by the graphs of
+// the wrapped store.
+type scanOnlyStore struct {
+	storage.Store
+}
+
+// Graph returns the wrapped graph with only the storage.Graph methods.
+func (s *scanOnlyStore) Graph(ctx context.Context, id string) (storage.Graph, error) {
+	g, err := s.Store.Graph(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return struct{ storage.Graph }{g}, nil
+}
+
+func TestPlannerFrequencies(t *testing.T) {
+	s := populateTestStore(t)
+	bs := []string{"?p", "?count"}
+	want := rowStrings(mustRunQuery(t, s, `SELECT ?p, COUNT(?p) AS ?count FROM ?test WHERE {?s ?p ?o} GROUP BY ?p ORDER BY ?count DESC, ?p ASC;`), bs)
+	for _, st := range []storage.Store{s, &scanOnlyStore{s}} {
+		q := `FREQUENCIES(?p) FROM ?test;`
+		if got := rowStrings(mustRunQuery(t, st, q), bs); !reflect.DeepEqual(got, want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %v, want %v", q, got, want)
+		}
+	}
+	q := `FREQUENCIES(?p) FROM ?test LIMIT "1"^^type:int64;`
+	if got := rowStrings(mustRunQuery(t, s, q), bs); !reflect.DeepEqual(got, want[:1]) {
+		t.Errorf("planner.Execute returned the wrong rows for query %q; got %v, want %v", q, got, want[:1])
+	}
+	if got := planQuery(t, s, q).String(); !strings.Contains(got, "count predicates\n") {
+		t.Errorf("planner.String() for query %q should count the predicates; got\n%s", q, got)
+	}
+
+	q = `FREQUENCIES(?s) FROM ?test WHERE {?s "parent_of"@[] ?o};`
+	got := rowStrings(mustRunQuery(t, s, q), []string{"?s", "?count"})
+	if want := []string{"/u<joe>\t\"2\"^^type:int64", "/u<peter>\t\"2\"^^type:int64"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("planner.Execute returned the wrong rows for query %q; got %v, want %v", q, got, want)
+	}
+
+	// Triples present on several graphs are only counted once.
+	ctx := context.Background()
+	for _, n := range []string{"?a", "?b"} {
+		g, err := s.NewGraph(ctx, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadIntoGraph(ctx, g, strings.NewReader(`/u<joe> "parent_of"@[] /u<mary>
+			/u<joe> "knows"@[] /u<`+n[1:]+`>`), literal.DefaultBuilder()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want = []string{"\"knows\"@[]\t\"2\"^^type:int64", "\"parent_of\"@[]\t\"1\"^^type:int64"}
+	for _, st := range []storage.Store{s, &scanOnlyStore{s}} {
+		q := `FREQUENCIES(?p) FROM ?a, ?b;`
+		if got := rowStrings(mustRunQuery(t, st, q), bs); !reflect.DeepEqual(got, want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %v, want %v", q, got, want)
+		}
+	}
+}
+
+func TestPlannerDistinct(t *testing.T) {
+	s := populateTestStore(t)
+	testTable := []struct {
+		q    string
+		bs   []string
+		want []string
+	}{
+		{
+			q:    `SELECT DISTINCT ?s FROM ?test WHERE {?s "parent_of"@[] ?o} ORDER BY ?s;`,
+			bs:   []string{"?s"},
+			want: []string{"/u<joe>", "/u<peter>"},
+		},
+		{
+			q:    `SELECT DISTINCT ?s FROM ?test WHERE {?s "parent_of"@[] ?o} ORDER BY ?s DESC LIMIT "1"^^type:int64;`,
+			bs:   []string{"?s"},
+			want: []string{"/u<peter>"},
+		},
+		{
+			q:    `SELECT DISTINCT ?t FROM ?test WHERE {?c "is_a"@[] ?t};`,
+			bs:   []string{"?t"},
+			want: []string{"/t<car>"},
+		},
+	}
+	for _, entry := range testTable {
+		if got := rowStrings(mustRunQuery(t, s, entry.q), entry.bs); !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %v, want %v", entry.q, got, entry.want)
+		}
+	}
+
+	// The limit cannot be pushed down to the storage lookups since duplicated
+	// rows are only removed after projecting.
+	q := `SELECT DISTINCT ?s FROM ?test WHERE {?s "parent_of"@[] ?o} LIMIT "2"^^type:int64;`
+	if got := mustRunQuery(t, s, q).NumRows(); got != 2 {
+		t.Errorf("planner.Execute returned %d rows for query %q; want 2", got, q)
+	}
+	if got := planQuery(t, s, q).String(); !strings.Contains(got, "remove duplicated rows\n") {
+		t.Errorf("planner.String() for query %q should remove duplicated rows; got\n%s", q, got)
+	}
+
+	// Literals with the same type and value are equal.
+	ps := populatePriceStore(t)
+	mustRunQuery(t, ps, `INSERT DATA INTO ?test {/i<mug> "price"@[] "3"^^type:int64};`)
+	q = `SELECT DISTINCT ?o FROM ?test WHERE {?s "price"@[] ?o};`
+	if got := mustRunQuery(t, ps, q).NumRows(); got != 4 {
+		t.Errorf("planner.Execute returned %d rows for query %q; want 4", got, q)
+	}
+}
+
+func TestPlannerParameters(t *testing.T) {
+	s, ctx := populateTestStore(t), context.Background()
+	bs := []string{"?s", "?p", "?o"}
+	all := rowStrings(mustRunQuery(t, s, `SELECT ?s, ?p, ?o FROM ?test WHERE {?s ?p ?o} ORDER BY ?s, ?p, ?o;`), bs)
+	q := `SELECT ?s, ?p, ?o FROM ?test WHERE {?s ?p ?o} ORDER BY ?s, ?p, ?o LIMIT @n OFFSET @o;`
+	lit := func(tp literal.Type, v interface{}) *literal.Literal {
+		l, err := literal.DefaultBuilder().Build(tp, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+	int64Lit := func(v int64) *literal.Literal {
+		return lit(literal.Int64, v)
+	}
+	plnr := planQuery(t, s, q)
+	cached := NewQueryCache(s, 10, 0).Executor(q, nil, plnr)
+	for _, e := range []Executor{plnr, cached} {
+		pe, ok := e.(ParameterizedExecutor)
+		if !ok {
+			t.Fatalf("%T should implement ParameterizedExecutor", e)
+		}
+		// Sequential pages neither overlap nor skip rows.
+		var got []string
+		for o := int64(0); o < int64(len(all))+3; o += 3 {
+			tbl, err := pe.ExecuteWithParameters(ctx, map[string]interface{}{"@n": int64Lit(3), "@o": int64Lit(o)})
+			if err != nil {
+				t.Fatalf("planner.ExecuteWithParameters failed for query %q with error %v", q, err)
+			}
+			if tbl.NumRows() > 3 {
+				t.Errorf("planner.ExecuteWithParameters returned %d rows; want at most 3", tbl.NumRows())
+			}
+			got = append(got, rowStrings(tbl, bs)...)
+		}
+		if !reflect.DeepEqual(got, all) {
+			t.Errorf("planner.ExecuteWithParameters pages returned %v; want %v", got, all)
+		}
+	}
+
+	for _, ps := range []map[string]interface{}{
+		nil,
+		{"@n": int64Lit(3)},
+		{"@n": int64Lit(3), "@o": int64Lit(-1)},
+		{"@n": int64Lit(-3), "@o": int64Lit(0)},
+		{"@n": lit(literal.Text, "3"), "@o": int64Lit(0)},
+		{"@n": int64Lit(3), "@o": int64Lit(0), "@x": int64Lit(0)},
+	} {
+		if _, err := plnr.(ParameterizedExecutor).ExecuteWithParameters(ctx, ps); err == nil {
+			t.Errorf("planner.ExecuteWithParameters(%v) should have failed for query %q", ps, q)
+		}
+	}
+	if _, err := plnr.Execute(ctx); err == nil {
+		t.Errorf("planner.Execute should have failed to execute query %q without parameter values", q)
+	}
+	if got := plnr.String(); !strings.Contains(got, "skip the first @o rows\nlimit results to @n rows\n") {
+		t.Errorf("planner.String() for query %q should show the parameters; got\n%s", q, got)
+	}
+}
+
+func TestPlannerFilterParameters(t *testing.T) {
+	s, ctx := populateTestStore(t), context.Background()
+	q := `SELECT ?o FROM ?test WHERE {/u<joe> "parent_of"@[] ?o . FILTER(?o = @name)};`
+	plnr := planQuery(t, s, q)
+	pe := plnr.(ParameterizedExecutor)
+	for _, n := range []string{"/u<mary>", "/u<peter>", "/u<john>"} {
+		v, err := node.Parse(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := 0
+		if n != "/u<john>" {
+			want = 1
+		}
+		tbl, err := pe.ExecuteWithParameters(ctx, map[string]interface{}{"@name": v})
+		if err != nil {
+			t.Fatalf("planner.ExecuteWithParameters failed for query %q with error %v", q, err)
+		}
+		if got := rowStrings(tbl, []string{"?o"}); len(got) != want || (want == 1 && got[0] != n) {
+			t.Errorf("planner.ExecuteWithParameters(@name=%s) returned %v for query %q; want %d rows", n, got, q, want)
+		}
+	}
+
+	// Parameters accept predicates and literals, and can be reused.
+	p, err := predicate.Parse(`"parent_of"@[]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q = `SELECT ?s, ?o FROM ?test WHERE {?s ?p ?o . FILTER((?p = @p) and (not(?s = @s)))};`
+	joe, err := node.Parse("/u<joe>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl, err := planQuery(t, s, q).(ParameterizedExecutor).ExecuteWithParameters(ctx, map[string]interface{}{"@p": p, "@s": joe})
+	if err != nil {
+		t.Fatalf("planner.ExecuteWithParameters failed for query %q with error %v", q, err)
+	}
+	if got, want := tbl.NumRows(), 2; got != want {
+		t.Errorf("planner.ExecuteWithParameters returned %d rows for query %q; want %d\nGot:\n%v", got, q, want, tbl)
+	}
+	l, err := literal.DefaultBuilder().Build(literal.Int64, int64(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ps := range []map[string]interface{}{
+		nil,
+		{"@p": p},
+		{"@p": p, "@s": "/u<joe>"},
+		{"@p": p, "@s": (*node.Node)(nil)},
+		{"@p": p, "@s": l},
+	} {
+		if _, err := planQuery(t, s, q).(ParameterizedExecutor).ExecuteWithParameters(ctx, ps); err == nil {
+			t.Errorf("planner.ExecuteWithParameters(%v) should have failed for query %q", ps, q)
+		}
+	}
+
+	// Only queries accept parameters.
+	stm := parseQuery(t, `DELETE WHERE {?s "parent_of"@[] ?o . FILTER(?o = @name)} FROM ?test;`)
+	if _, err := New(ctx, s, stm, 0, nil); err == nil {
+		t.Errorf("planner.New should have rejected parameters on statement %v", stm)
+	}
+}
+
+func TestPlannerUnion(t *testing.T) {
+	s := populateTestStore(t)
+	testTable := []struct {
+		q    string
+		bs   []string
+		want []string
+	}{
+		{
+			q:  `SELECT ?s, ?o FROM ?test WHERE { {?s "parent_of"@[] ?o} UNION {?s "bought"@[,] ?o} };`,
+			bs: []string{"?s", "?o"},
+			want: []string{
+				"/u<joe>\t/u<mary>",
+				"/u<joe>\t/u<peter>",
+				"/u<peter>\t/c<mini>",
+				"/u<peter>\t/c<model s>",
+				"/u<peter>\t/c<model x>",
+				"/u<peter>\t/c<model y>",
+				"/u<peter>\t/u<eve>",
+				"/u<peter>\t/u<john>",
+			},
+		},
+		{
+			q:    `SELECT ?s FROM ?test WHERE { {?s "parent_of"@[] /u<mary>} UNION {?s "bought"@[,] ?o . FILTER(?o = /c<mini>)} UNION {?s "is_a"@[] /t<car> . !{/u<peter> "bought"@[,] ?s}} };`,
+			bs:   []string{"?s"},
+			want: []string{"/u<joe>", "/u<peter>"},
+		},
+		{
+			q:  `SELECT ?s, count(?o) AS ?n FROM ?test WHERE { {?s "parent_of"@[] ?o} UNION {?s "bought"@[,] ?o} } GROUP BY ?s;`,
+			bs: []string{"?s", "?n"},
+			want: []string{
+				`/u<joe>	"2"^^type:int64`,
+				`/u<peter>	"6"^^type:int64`,
+			},
+		},
+		{
+			q:  `SELECT ?s, ?t FROM ?test WHERE { {?s "bought"@[,] ?o . ?o "is_a"@[] ?t} UNION {?s "parent_of"@[] /u<mary> . ?s "parent_of"@[] ?t} };`,
+			bs: []string{"?s", "?t"},
+			want: []string{
+				"/u<joe>\t/u<mary>",
+				"/u<joe>\t/u<peter>",
+				"/u<peter>\t/t<car>",
+				"/u<peter>\t/t<car>",
+				"/u<peter>\t/t<car>",
+				"/u<peter>\t/t<car>",
+			},
+		},
+	}
+	for _, entry := range testTable {
+		got := rowStrings(mustRunQuery(t, s, entry.q), entry.bs)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %v, want %v", entry.q, got, entry.want)
+		}
+	}
+
+	q := `SELECT ?s, ?o FROM ?test WHERE { {?s "parent_of"@[] ?o} UNION {?s "bought"@[,] ?c} };`
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
+	}
+	st := &semantic.Statement{}
+	if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+		t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+	}
+	if _, err := New(context.Background(), s, st, 0, nil); err == nil {
+		t.Errorf("planner.New should have failed for UNION branches missing projected bindings in query %q", q)
+	}
+}
+
+func TestPlannerConstruct(t *testing.T) {
+	s := populateTestStore(t)
+	testTable := []struct {
+		q    string
+		want []string
+	}{
+		{
+			q: `CONSTRUCT {?s "related_to"@[] ?o} FROM ?test WHERE {?s "parent_of"@[] ?o};`,
+			want: []string{
+				`/u<joe>	"related_to"@[]	/u<mary>`,
+				`/u<joe>	"related_to"@[]	/u<peter>`,
+				`/u<peter>	"related_to"@[]	/u<eve>`,
+				`/u<peter>	"related_to"@[]	/u<john>`,
+			},
+		},
+		{
+			q: `CONSTRUCT {?s "owned"@[?t] ?o} FROM ?test WHERE {?s "bought"@[?t] ?o . FILTER(?o = /c<mini>)};`,
+			want: []string{
+				`/u<peter>	"owned"@[2016-01-01T00:00:00-08:00]	/c<mini>`,
+			},
+		},
+		{
+			// Rows of the second branch do not bind ?g, hence they do not
+			// construct any triple.
+			q: `CONSTRUCT {?s "grandparent_of"@[] ?g} FROM ?test WHERE { {?s "parent_of"@[] ?o . ?o "parent_of"@[] ?g} UNION {?s "parent_of"@[] ?o} };`,
+			want: []string{
+				`/u<joe>	"grandparent_of"@[]	/u<eve>`,
+				`/u<joe>	"grandparent_of"@[]	/u<john>`,
+			},
+		},
+		{
+			// Predicates cannot be used as subjects.
+			q: `CONSTRUCT {?o "turned_in"@[] /l<barcelona>} FROM ?test WHERE {/l<barcelona> "predicate"@[] ?o};`,
+		},
+	}
+	for _, entry := range testTable {
+		got := rowStrings(mustRunQuery(t, s, entry.q), []string{"?s", "?p", "?o"})
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong triples for query %q; got %v, want %v", entry.q, got, entry.want)
+		}
+	}
+
+	// Template blank nodes are replaced by a fresh blank node for each row.
+	q := `CONSTRUCT {_:v "parent"@[] ?s . _:v "child"@[] ?o} FROM ?test WHERE {?s "parent_of"@[] ?o};`
+	tbl := mustRunQuery(t, s, q)
+	if got, want := tbl.NumRows(), 8; got != want {
+		t.Fatalf("planner.Execute returned %d triples for query %q; want %d", got, q, want)
+	}
+	bns := make(map[string]int)
+	for _, r := range tbl.Rows() {
+		if got, want := r["?s"].N.Type().String(), "/_"; got != want {
+			t.Errorf("planner.Execute returned subject %v for query %q; want a blank node", r["?s"], q)
+		}
+		bns[r["?s"].String()]++
+	}
+	if got, want := len(bns), 4; got != want {
+		t.Errorf("planner.Execute returned %d blank nodes for query %q; want %d", got, q, want)
+	}
+	for bn, cnt := range bns {
+		if cnt != 2 {
+			t.Errorf("planner.Execute returned blank node %s in %d triples for query %q; want 2", bn, cnt, q)
+		}
+	}
+
+	// NEW_BLANK() mints a fresh blank node on every instantiation, which is
+	// never shared across rows nor across executions.
+	q = `CONSTRUCT {NEW_BLANK() "parent"@[] ?s; "generated_by"@[] NEW_BLANK() . ?s "related_to"@[] new_blank()} FROM ?test WHERE {?s "parent_of"@[] ?o};`
+	seen := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		tbl = mustRunQuery(t, s, q)
+		if got, want := tbl.NumRows(), 4*6; got != want {
+			t.Fatalf("planner.Execute returned %d triples for query %q; want %d", got, q, want)
+		}
+		for _, r := range tbl.Rows() {
+			var n *node.Node
+			switch r["?p"].P.ID() {
+			case "parent":
+				n = r["?s"].N
+			case "generated_by", "related_to":
+				n = r["?o"].N
+			default:
+				continue
+			}
+			if n == nil || n.Type().String() != "/_" {
+				t.Errorf("planner.Execute returned node %v for query %q; want a blank node", n, q)
+			}
+			if seen[n.String()] {
+				t.Errorf("planner.Execute returned blank node %v more than once for query %q", n, q)
+			}
+			seen[n.String()] = true
+		}
+	}
+	if got, want := len(seen), 2*4*3; got != want {
+		t.Errorf("planner.Execute minted %d blank nodes for query %q; want %d", got, q, want)
+	}
+
+	// Reification clauses describe the reified triple.
+	q = `CONSTRUCT {?s "owns"@[] ?o; "kind"@[] ?k} FROM ?test WHERE {?s "bought"@[,] ?o . ?o "is_a"@[] ?k};`
+	tbl = mustRunQuery(t, s, q)
+	if got, want := tbl.NumRows(), 20; got != want {
+		t.Fatalf("planner.Execute returned %d triples for query %q; want %d", got, q, want)
+	}
+	reified := make(map[string]bool)
+	for _, r := range tbl.Rows() {
+		if r["?p"].P.ID() == "_subject" {
+			reified[r["?s"].String()] = true
+		}
+	}
+	kinds := 0
+	for _, r := range tbl.Rows() {
+		if r["?p"].P.ID() != "kind" {
+			continue
+		}
+		kinds++
+		if !reified[r["?s"].String()] || r["?o"].String() != "/t<car>" {
+			t.Errorf("planner.Execute returned unexpected reification triple %v for query %q", r, q)
+		}
+	}
+	if got, want := kinds, 4; got != want {
+		t.Errorf("planner.Execute returned %d reification triples for query %q; want %d", got, q, want)
+	}
+
+	// Constructed triples are stored in the output graphs.
+	ctx := context.Background()
+	out, err := s.NewGraph(ctx, "?out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q = `CONSTRUCT {?s "related_to"@[] ?o} INTO ?out FROM ?test WHERE {?s "parent_of"@[] ?o};`
+	mustRunQuery(t, s, q)
+	trpls := make(chan *triple.Triple)
+	go func() {
+		if err := out.Triples(ctx, storage.DefaultLookup, trpls); err != nil {
+			t.Error(err)
+		}
+	}()
+	cnt := 0
+	for _ = range trpls {
+		cnt++
+	}
+	if got, want := cnt, 4; got != want {
+		t.Errorf("planner.Execute stored %d triples in the output graph for query %q; want %d", got, q, want)
+	}
+}
+
+func TestPlannerConstructConcat(t *testing.T) {
+	s := populatePriceStore(t)
+	testTable := []struct {
+		q    string
+		want []string
+	}{
+		{
+			q: `CONSTRUCT {?i "label"@[] CONCAT(?n, " (", ?id, ")")} FROM ?test WHERE {?i ID ?id "name"@[] ?n};`,
+			want: []string{
+				`/i<book>	"label"@[]	"book (book)"^^type:text`,
+			},
+		},
+		{
+			q: `CONSTRUCT {/u<joe> "label"@[] CONCAT("item ", "two"^^type:text)} FROM ?test WHERE {/u<joe> "bought"@[] ?i};`,
+			want: []string{
+				`/u<joe>	"label"@[]	"item two"^^type:text`,
+				`/u<joe>	"label"@[]	"item two"^^type:text`,
+			},
+		},
+		{
+			// Rows of the second branch do not bind ?n, hence they do not
+			// construct any triple.
+			q: `CONSTRUCT {?i "label"@[] CONCAT("the ", ?n)} FROM ?test WHERE { {?i "name"@[] ?n} UNION {?i "price"@[] ?p} };`,
+			want: []string{
+				`/i<book>	"label"@[]	"the book"^^type:text`,
+			},
+		},
+	}
+	for _, entry := range testTable {
+		got := rowStrings(mustRunQuery(t, s, entry.q), []string{"?s", "?p", "?o"})
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong triples for query %q; got %v, want %v", entry.q, got, entry.want)
+		}
+	}
+
+	// Only texts can be concatenated.
+	q := `CONSTRUCT {?i "label"@[] CONCAT("costs ", ?p)} FROM ?test WHERE {?i "price"@[] ?p};`
+	if _, err := runQuery(t, s, q); err == nil || !strings.Contains(err.Error(), `"?p"`) {
+		t.Errorf("planner.Execute should have failed for non text binding in query %q; got %v", q, err)
+	}
+}
+
+func TestPlannerInsertConstruct(t *testing.T) {
+	s, ctx := populateTestStore(t), context.Background()
+	dest, err := s.NewGraph(ctx, "?dest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := `INSERT INTO ?dest CONSTRUCT {?s "grandparent_of"@[] ?o} FROM ?test WHERE {?s "parent_of"@[] ?x . ?x "parent_of"@[] ?o};`
+	if got := mustRunQuery(t, s, q).NumRows(); got != 0 {
+		t.Errorf("planner.Execute returned %d rows for query %q; want none", got, q)
+	}
+	trpls := make(chan *triple.Triple)
+	go func() {
+		if err := dest.Triples(ctx, storage.DefaultLookup, trpls); err != nil {
+			t.Error(err)
+		}
+	}()
+	var got []string
+	for trpl := range trpls {
+		got = append(got, trpl.String())
+	}
+	sort.Strings(got)
+	want := []string{
+		`/u<joe>	"grandparent_of"@[]	/u<eve>`,
+		`/u<joe>	"grandparent_of"@[]	/u<john>`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("planner.Execute inserted the wrong triples for query %q; got %v, want %v", q, got, want)
+	}
+
+	// The destination graph must exist.
+	q = `INSERT INTO ?missing CONSTRUCT {?s "grandparent_of"@[] ?o} FROM ?test WHERE {?s "parent_of"@[] ?x . ?x "parent_of"@[] ?o};`
+	if _, err := runQuery(t, s, q); err == nil || !strings.Contains(err.Error(), `"?missing"`) {
+		t.Errorf("planner.Execute should have failed for missing destination graph in query %q; got %v", q, err)
+	}
+}
+
+func TestPlannerBlobs(t *testing.T) {
+	ts := `/file<a> "hash"@[] "/w=="^^type:blob
+		/file<b> "hash"@[] "aGVsbG8="^^type:blob
+		/file<c> "hash"@[] "AP8="^^type:blob
+		/file<d> "hash"@[] "[104 101 108 108 111]"^^type:blob`
+	s := populateTestStoreWith(t, ts)
+	testTable := []struct {
+		q    string
+		bs   []string
+		want []string
+	}{
+		{
+			q:    `select ?f from ?test where {?f "hash"@[] "aGVsbG8="^^type:blob} order by ?f;`,
+			bs:   []string{"?f"},
+			want: []string{`/file<b>`, `/file<d>`},
+		},
+		{
+			q:  `select ?f, ?h from ?test where {?f "hash"@[] ?h} order by ?h, ?f;`,
+			bs: []string{"?f", "?h"},
+			want: []string{
+				`/file<c>	"AP8="^^type:blob`,
+				`/file<b>	"aGVsbG8="^^type:blob`,
+				`/file<d>	"aGVsbG8="^^type:blob`,
+				`/file<a>	"/w=="^^type:blob`,
+			},
+		},
+	}
+	for _, entry := range testTable {
+		if got := rowStrings(mustRunQuery(t, s, entry.q), entry.bs); !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %q, want %q", entry.q, got, entry.want)
+		}
+	}
+}
+
+func TestPlannerExecuteCanceled(t *testing.T) {
+	// The Cartesian product of the two clauses has 4 million rows, which takes
+	// seconds to compute.
+	var b bytes.Buffer
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&b, "/u<%d> \"knows\"@[] /u<%d>\n", i, i+1)
+	}
+	s, ctx := populateTestStoreWith(t, b.String()), context.Background()
+	testTable := []struct {
+		q      string
+		cancel func(context.CancelFunc)
+	}{
+		{
+			q:      `select ?s, ?k from ?test where {?s ?p ?o. ?k ?l ?m};`,
+			cancel: func(cancel context.CancelFunc) { cancel() },
+		},
+		{
+			q:      `select ?s, ?m from ?test where {?s ?p ?o. ?k ?l ?m};`,
+			cancel: func(cancel context.CancelFunc) { time.AfterFunc(20*time.Millisecond, cancel) },
+		},
+	}
+	for _, entry := range testTable {
+		st := parseQuery(t, entry.q)
+		cctx, cancel := context.WithCancel(ctx)
+		pln, err := New(cctx, s, st, 0, nil)
+		if err != nil {
+			t.Fatalf("planner.New failed to create a plan for query %q with error %v", entry.q, err)
+		}
+		start := time.Now()
+		entry.cancel(cancel)
+		tbl, err := pln.Execute(cctx)
+		if err != context.Canceled {
+			t.Errorf("planner.Execute returned %v with error %v for query %q; want error %v", tbl, err, entry.q, context.Canceled)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("planner.Execute took %v to return after the context was canceled for query %q", d, entry.q)
+		}
+		cancel()
+	}
+}
+
+func TestPlannerFilterPredicateID(t *testing.T) {
+	testTable := []struct {
+		q    string
+		want []string
+	}{
+		{
+			q: `select ?s, ?p, ?o from ?test where {?s ?p ?o . filter(starts_with(id(?p), "bought"^^type:text))} order by ?o;`,
+			want: []string{
+				`/u<peter>	"bought"@[2016-01-01T00:00:00-08:00]	/c<mini>`,
+				`/u<peter>	"bought"@[2016-02-01T00:00:00-08:00]	/c<model s>`,
+				`/u<peter>	"bought"@[2016-03-01T00:00:00-08:00]	/c<model x>`,
+				`/u<peter>	"bought"@[2016-04-01T00:00:00-08:00]	/c<model y>`,
+			},
+		},
+		{
+			q:    `select ?s, ?p, ?o from ?test where {?s ?p ?o . filter((starts_with(id(?p), "parent"^^type:text)) and (starts_with(id(?o), "j"^^type:text)))};`,
+			want: []string{`/u<peter>	"parent_of"@[]	/u<john>`},
+		},
+		{
+			q:    `select ?s, ?p, ?o from ?test where {?s ?p ?o . filter(starts_with(id(?p), "bou"^^type:text)) . filter(?o = /c<mini>)};`,
+			want: []string{`/u<peter>	"bought"@[2016-01-01T00:00:00-08:00]	/c<mini>`},
+		},
+	}
+	s := populateTestStore(t)
+	for _, entry := range testTable {
+		got := rowStrings(mustRunQuery(t, s, entry.q), []string{"?s", "?p", "?o"})
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %q, want %q", entry.q, got, entry.want)
+		}
+	}
+	if _, err := runQuery(t, s, `select ?p from ?test where {?s ?p ?o . filter(starts_with(?p, "bought"^^type:text))};`); err == nil {
+		t.Error("planner.Execute should have failed to match a predicate without id()")
+	}
+}
+
+func TestPlannerWeightedSample(t *testing.T) {
+	ts := `/u<a> "score"@[] "1"^^type:int64
+		/u<b> "score"@[] "2"^^type:int64
+		/u<c> "score"@[] "7"^^type:float64
+		/u<d> "score"@[] "0"^^type:int64
+		/u<e> "score"@[] "-3"^^type:int64
+		/u<f> "label"@[] "f"^^type:text`
+	s, ctx := populateTestStoreWith(t, ts), context.Background()
+
+	// Rows without a positive weight are never sampled.
+	q := `weighted_sample(?s, ?w, "10"^^type:int64, "1"^^type:int64) from ?test where {?s "score"@[] ?w};`
+	if got := planQuery(t, s, q).String(); !strings.Contains(got, "sample 10 rows weighted by ?w") {
+		t.Errorf("planner.New should sample the rows for query %q; got plan\n%s", q, got)
+	}
+	got := rowStrings(mustRunQuery(t, s, q), []string{"?s"})
+	sort.Strings(got)
+	if want := []string{"/u<a>", "/u<b>", "/u<c>"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("planner.Execute returned the wrong rows for query %q; got %v, want %v", q, got, want)
+	}
+
+	// Samples are reproducible given a seed.
+	q = `weighted_sample(?s, ?w, "2"^^type:int64, "42"^^type:int64) from ?test where {?s "score"@[] ?w};`
+	want := rowStrings(mustRunQuery(t, s, q), []string{"?s", "?w"})
+	if len(want) != 2 {
+		t.Fatalf("planner.Execute returned %d rows for query %q; want 2", len(want), q)
+	}
+	for i := 0; i < 10; i++ {
+		if got := rowStrings(mustRunQuery(t, s, q), []string{"?s", "?w"}); !reflect.DeepEqual(got, want) {
+			t.Errorf("planner.Execute returned a different sample for query %q; got %v, want %v", q, got, want)
+		}
+	}
+
+	// The sampled rows roughly follow the weights over many seeds.
+	q = `weighted_sample(?s, ?w, "1"^^type:int64) from ?test where {?s "score"@[] ?w};`
+	st, runs := parseQuery(t, q), 3000
+	cnts := make(map[string]int)
+	for i := 0; i < runs; i++ {
+		st.SetWeightedSampleSeed(int64(i))
+		tbl, err := planStatement(t, s, st).Execute(ctx)
+		if err != nil {
+			t.Fatalf("planner.Execute failed for query %q with error %v", q, err)
+		}
+		for _, r := range rowStrings(tbl, []string{"?s"}) {
+			cnts[r]++
+		}
+	}
+	for sbj, w := range map[string]float64{"/u<a>": 0.1, "/u<b>": 0.2, "/u<c>": 0.7} {
+		if got := float64(cnts[sbj]) / float64(runs); math.Abs(got-w) > 0.05 {
+			t.Errorf("planner.Execute sampled %s with frequency %.3f over %d seeds; want about %.2f", sbj, got, runs, w)
+		}
+	}
+
+	// Weights must be numeric.
+	q = `weighted_sample(?s, ?w, "1"^^type:int64) from ?test where {?s ?p ?w};`
+	if _, err := runQuery(t, s, q); err == nil {
+		t.Errorf("planner.Execute should have failed to sample non numeric weights for query %q", q)
+	}
+}
+
+func TestPlannerLatest(t *testing.T) {
+	s := populateTestStore(t)
+	testTable := []struct {
+		q    string
+		want []string
+	}{
+		{
+			q: `LATEST "2"^^type:int64 PER ?s OF "bought"@[] FROM ?test;`,
+			want: []string{
+				`/u<peter>	"bought"@[2016-04-01T00:00:00-08:00]	/c<model y>`,
+				`/u<peter>	"bought"@[2016-03-01T00:00:00-08:00]	/c<model x>`,
+			},
+		},
+		{
+			q: `LATEST "1"^^type:int64 PER ?s OF "in"@[] FROM ?test;`,
+			want: []string{
+				`/item/book<000>	"in"@[2016-04-10T04:25:00Z]	/room<Bedroom>`,
+			},
+		},
+		{
+			q: `LATEST "1"^^type:int64 PER ?s OF "bought"@[] FROM ?test BEFORE ""@[2016-02-15T00:00:00-08:00];`,
+			want: []string{
+				`/u<peter>	"bought"@[2016-02-01T00:00:00-08:00]	/c<model s>`,
+			},
+		},
+		{
+			q: `LATEST "2"^^type:int64 PER ?s OF "parent_of"@[] FROM ?test;`,
+		},
+	}
+	for _, entry := range testTable {
+		tbl := mustRunQuery(t, s, entry.q)
+		if got := rowStrings(tbl, []string{"?s", "?p", "?o"}); !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %v, want %v", entry.q, got, entry.want)
+		}
+		if got, want := tbl.Bindings(), []string{"?s", "?p", "?o"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("planner.Execute returned bindings %v for query %q; want %v", got, entry.q, want)
+		}
+	}
+}
+
+func TestPlannerRunningAggregation(t *testing.T) {
+	s, ctx := populateTestStore(t), context.Background()
+	g, err := s.Graph(ctx, "?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := `/c<mini> "price"@[] "20"^^type:int64
+		/c<model s> "price"@[] "70"^^type:int64
+		/c<model x> "price"@[] "80"^^type:int64
+		/c<model y> "price"@[] "40"^^type:int64
+		/u<mary> "bought"@[2016-01-15T00:00:00-08:00] /c<mini>
+		/u<mary> "bought"@[2016-03-15T00:00:00-08:00] /c<model y>`
+	if _, err := io.ReadIntoGraph(ctx, g, bytes.NewBufferString(ps), literal.DefaultBuilder()); err != nil {
+		t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+	}
+	testTable := []struct {
+		q    string
+		bs   []string
+		want []string
+		err  bool
+	}{
+		{
+			q:  `SELECT ?o, SUM(?price) OVER (ORDER BY ?t) AS ?running FROM ?test WHERE {/u<peter> "bought"@[,] AT ?t ?o . ?o "price"@[] ?price} ORDER BY ?running;`,
+			bs: []string{"?o", "?running"},
+			want: []string{
+				`/c<mini>	"20"^^type:int64`,
+				`/c<model s>	"90"^^type:int64`,
+				`/c<model x>	"170"^^type:int64`,
+				`/c<model y>	"210"^^type:int64`,
+			},
+		},
+		{
+			q:  `SELECT ?s, ?o, SUM(?price) OVER (PARTITION BY ?s ORDER BY ?t) AS ?running FROM ?test WHERE {?s "bought"@[,] AT ?t ?o . ?o "price"@[] ?price} ORDER BY ?s, ?running;`,
+			bs: []string{"?s", "?o", "?running"},
+			want: []string{
+				`/u<mary>	/c<mini>	"20"^^type:int64`,
+				`/u<mary>	/c<model y>	"60"^^type:int64`,
+				`/u<peter>	/c<mini>	"20"^^type:int64`,
+				`/u<peter>	/c<model s>	"90"^^type:int64`,
+				`/u<peter>	/c<model x>	"170"^^type:int64`,
+				`/u<peter>	/c<model y>	"210"^^type:int64`,
+			},
+		},
+		{
+			q:  `SELECT ?o, COUNT(?o) OVER (ORDER BY ?t DESC) AS ?n FROM ?test WHERE {/u<peter> "bought"@[,] AT ?t ?o} ORDER BY ?n;`,
+			bs: []string{"?o", "?n"},
+			want: []string{
+				`/c<model y>	"1"^^type:int64`,
+				`/c<model x>	"2"^^type:int64`,
+				`/c<model s>	"3"^^type:int64`,
+				`/c<mini>	"4"^^type:int64`,
+			},
+		},
+		{
+			q:   `SELECT SUM(?o) OVER (ORDER BY ?t) AS ?running FROM ?test WHERE {/u<peter> "bought"@[,] AT ?t ?o};`,
+			err: true,
+		},
+	}
+	for _, entry := range testTable {
+		tbl, err := runQuery(t, s, entry.q)
+		if entry.err {
+			if err == nil {
+				t.Errorf("planner.Execute should have failed for query %q", entry.q)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("planner.Execute failed for query %q with error %v", entry.q, err)
+		}
+		if got := rowStrings(tbl, entry.bs); !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %v, want %v", entry.q, got, entry.want)
+		}
+	}
+}
+
+func TestPlannerUnionsGraphs(t *testing.T) {
+	graphs := map[string]string{
+		"?g1": `/u<joe> "bought"@[] /c<mini>
+			/u<mary> "bought"@[] /c<model x>`,
+		"?g2": `/u<peter> "bought"@[] /c<model s>
+			/u<joe> "bought"@[] /c<mini>`,
+	}
+	s, ctx := memory.NewStore(), context.Background()
+	for n, ts := range graphs {
+		g, err := s.NewGraph(ctx, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadIntoGraph(ctx, g, bytes.NewBufferString(ts), literal.DefaultBuilder()); err != nil {
+			t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+		}
+	}
+	testTable := []struct {
+		q        string
+		multiset bool
+		want     []string
+	}{
+		{
+			q: `select ?s, ?p, ?o from ?g1, ?g2 where {?s ?p ?o};`,
+			want: []string{
+				`/u<joe>	"bought"@[]	/c<mini>`,
+				`/u<mary>	"bought"@[]	/c<model x>`,
+				`/u<peter>	"bought"@[]	/c<model s>`,
+			},
+		},
+		{
+			q:        `select ?s, ?p, ?o from ?g1, ?g2 where {?s ?p ?o};`,
+			multiset: true,
+			want: []string{
+				`/u<joe>	"bought"@[]	/c<mini>`,
+				`/u<joe>	"bought"@[]	/c<mini>`,
+				`/u<mary>	"bought"@[]	/c<model x>`,
+				`/u<peter>	"bought"@[]	/c<model s>`,
+			},
+		},
+		{
+			q:    `select ?s, ?p, ?o from ?g1, ?g2 where {?s ?p /c<mini> as ?o};`,
+			want: []string{`/u<joe>	"bought"@[]	/c<mini>`},
+		},
+		{
+			q: `select ?s, ?p, ?o from ?g1, ?g2 where {/u<joe> "bought"@[] /c<mini>. ?s ?p ?o} order by ?s;`,
+			want: []string{
+				`/u<joe>	"bought"@[]	/c<mini>`,
+				`/u<mary>	"bought"@[]	/c<model x>`,
+				`/u<peter>	"bought"@[]	/c<model s>`,
+			},
+		},
+		{
+			q: `select ?s, ?p, ?o from ?g1, ?g2 where {?s ?p ?o} order by ?s;`,
+			want: []string{
+				`/u<joe>	"bought"@[]	/c<mini>`,
+				`/u<mary>	"bought"@[]	/c<model x>`,
+				`/u<peter>	"bought"@[]	/c<model s>`,
+			},
+		},
+	}
+	for _, entry := range testTable {
+		st := parseQuery(t, entry.q)
+		if entry.multiset {
+			st.SetMultisetGraphs()
+		}
+		tbl, err := planStatement(t, s, st).Execute(ctx)
+		if err != nil {
+			t.Fatalf("planner.Execute failed for query %q with error %v", entry.q, err)
+		}
+		got := rowStrings(tbl, []string{"?s", "?p", "?o"})
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q with multiset %v; got %q, want %q", entry.q, entry.multiset, got, entry.want)
+		}
+	}
+}
+
+func TestPlannerGraphBinding(t *testing.T) {
+	graphs := map[string]string{
+		"?g1": `/u<joe> "bought"@[] /c<mini>
+			/u<mary> "bought"@[] /c<model x>
+			/c<mini> "made_by"@[] /m<bmc>`,
+		"?g2": `/u<peter> "bought"@[] /c<model s>
+			/u<joe> "bought"@[] /c<mini>
+			/c<model x> "made_by"@[] /m<tesla>
+			/c<model s> "made_by"@[] /m<tesla>`,
+	}
+	s, ctx := memory.NewStore(), context.Background()
+	for n, ts := range graphs {
+		g, err := s.NewGraph(ctx, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadIntoGraph(ctx, g, bytes.NewBufferString(ts), literal.DefaultBuilder()); err != nil {
+			t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+		}
+	}
+	testTable := []struct {
+		q    string
+		bs   []string
+		want []string
+	}{
+		{
+			q:  `select ?s, ?o, ?_graph from ?g1, ?g2 where {?s "bought"@[] ?o};`,
+			bs: []string{"?s", "?o", "?_graph"},
+			want: []string{
+				`/u<joe>	/c<mini>	"?g1"^^type:text`,
+				`/u<joe>	/c<mini>	"?g2"^^type:text`,
+				`/u<mary>	/c<model x>	"?g1"^^type:text`,
+				`/u<peter>	/c<model s>	"?g2"^^type:text`,
+			},
+		},
+		{
+			q:  `select ?s, ?_graph from ?g1 where {?s "bought"@[] ?o};`,
+			bs: []string{"?s", "?_graph"},
+			want: []string{
+				`/u<joe>	"?g1"^^type:text`,
+				`/u<mary>	"?g1"^^type:text`,
+			},
+		},
+		{
+			// Only the first clause binds the graph, hence rows combine
+			// triples of different graphs.
+			q:  `select ?s, ?m, ?_graph from ?g1, ?g2 where {?s "bought"@[] ?c . ?c "made_by"@[] ?m};`,
+			bs: []string{"?s", "?m", "?_graph"},
+			want: []string{
+				`/u<joe>	/m<bmc>	"?g1"^^type:text`,
+				`/u<joe>	/m<bmc>	"?g2"^^type:text`,
+				`/u<mary>	/m<tesla>	"?g1"^^type:text`,
+				`/u<peter>	/m<tesla>	"?g2"^^type:text`,
+			},
+		},
+		{
+			q:  `select ?s, ?m from ?g1, ?g2 where {?s "bought"@[] ?c . ?c "made_by"@[] ?m};`,
+			bs: []string{"?s", "?m"},
+			want: []string{
+				`/u<joe>	/m<bmc>`,
+				`/u<mary>	/m<tesla>`,
+				`/u<peter>	/m<tesla>`,
+			},
+		},
+		{
+			q:    `select ?s from ?g1, ?g2 where {?s "bought"@[] ?o . filter(?_graph = "?g2"^^type:text)};`,
+			bs:   []string{"?s"},
+			want: []string{`/u<joe>`, `/u<peter>`},
+		},
+		{
+			q:    `select ?_graph from ?g1, ?g2 where {/u<joe> "bought"@[] /c<mini>};`,
+			bs:   []string{"?_graph"},
+			want: []string{`"?g1"^^type:text`, `"?g2"^^type:text`},
+		},
+		{
+			q:    `select ?_graph from ?g1, ?g2 where {?s "bought"@[] ?o . /c<mini> "made_by"@[] /m<bmc>};`,
+			bs:   []string{"?_graph"},
+			want: []string{`"?g1"^^type:text`, `"?g1"^^type:text`, `"?g2"^^type:text`, `"?g2"^^type:text`},
+		},
+		{
+			// The first clause of each UNION branch binds the graph.
+			q:    `select ?s, ?_graph from ?g1, ?g2 where { {/u<mary> "bought"@[] ?s} union {?s "made_by"@[] /m<tesla> . /u<peter> "bought"@[] ?s} };`,
+			bs:   []string{"?s", "?_graph"},
+			want: []string{`/c<model s>	"?g2"^^type:text`, `/c<model x>	"?g1"^^type:text`},
+		},
+		{
+			q:    `select ?_graph, count(?s) as ?n from ?g1, ?g2 where {?s "bought"@[] ?o} group by ?_graph;`,
+			bs:   []string{"?_graph", "?n"},
+			want: []string{`"?g1"^^type:text	"2"^^type:int64`, `"?g2"^^type:text	"2"^^type:int64`},
+		},
+		{
+			// Negated clauses match triples of any graph.
+			q:    `select ?s, ?_graph from ?g1, ?g2 where {?s "bought"@[] ?o . !{?o "made_by"@[] /m<tesla>}};`,
+			bs:   []string{"?s", "?_graph"},
+			want: []string{`/u<joe>	"?g1"^^type:text`, `/u<joe>	"?g2"^^type:text`},
+		},
+		{
+			q:    `select ?o, ?_graph from ?g1, ?g2 where {/c<mini> "made_by"@[]+ ?o};`,
+			bs:   []string{"?o", "?_graph"},
+			want: []string{`/m<bmc>	"?g1"^^type:text`},
+		},
+	}
+	for _, entry := range testTable {
+		tbl, err := runQuery(t, s, entry.q)
+		if err != nil {
+			t.Fatalf("planner.Execute failed for query %q with error %v", entry.q, err)
+		}
+		got := rowStrings(tbl, entry.bs)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %q, want %q", entry.q, got, entry.want)
+		}
+	}
+}
+
+func TestPlannerMergesSortedGraphs(t *testing.T) {
+	graphs := map[string]string{
+		"?g1": `/u<joe> "bought"@[] /c<mini>
+			/u<mary> "bought"@[] /c<model x>
+			/u<zoe> "bought"@[] /c<beetle>`,
+		"?g2": `/u<peter> "bought"@[] /c<model s>
+			/u<anna> "bought"@[] /c<mini>`,
+		"?g3": `/u<eve> "bought"@[] /c<model y>
+			/u<joe> "bought"@[] /c<mini>`,
+	}
+	s, ctx := memory.NewStore(), context.Background()
+	for n, ts := range graphs {
+		g, err := s.NewGraph(ctx, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadIntoGraph(ctx, g, bytes.NewBufferString(ts), literal.DefaultBuilder()); err != nil {
+			t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+		}
+	}
+	testTable := []struct {
+		w     string
+		cfg   table.SortConfig
+		limit int
+	}{
+		{
+			w:   `{?s "bought"@[] ?o} order by ?o desc, ?s`,
+			cfg: table.SortConfig{{Binding: "?o", Desc: true}, {Binding: "?s"}},
+		},
+		{
+			w:     `{?s "bought"@[] ?o} order by ?s, ?o limit "3"^^type:int64`,
+			cfg:   table.SortConfig{{Binding: "?s"}, {Binding: "?o"}},
+			limit: 3,
+		},
+	}
+	// concat returns the sorted concatenation of the results of each graph.
+	// Unless multiset is set, triples present on several graphs are only
+	// kept once.
+	concat := func(cfg table.SortConfig, limit int, multiset bool) []string {
+		cat, err := table.New([]string{"?s", "?o"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := make(map[string]bool)
+		for _, g := range []string{"?g1", "?g2", "?g3"} {
+			for _, r := range mustRunQuery(t, s, `select ?s, ?o from `+g+` where {?s "bought"@[] ?o};`).Rows() {
+				k := r["?s"].String() + "\t" + r["?o"].String()
+				if !multiset && seen[k] {
+					continue
+				}
+				seen[k] = true
+				cat.AddRow(r)
+			}
+		}
+		cat.Sort(cfg)
+		if limit > 0 {
+			cat.Limit(int64(limit))
+		}
+		return rowStrings(cat, []string{"?s", "?o"})
+	}
+	for _, entry := range testTable {
+		q := `select ?s, ?o from ?g1, ?g2, ?g3 where ` + entry.w + `;`
+		if got := planQuery(t, s, q).String(); !strings.Contains(got, "merge the sorted results of each graph") {
+			t.Errorf("planner.New should have merged the sorted graph results for query %q; got plan\n%s", q, got)
+		}
+		got := rowStrings(mustRunQuery(t, s, q), []string{"?s", "?o"})
+		// The merged output must match sorting the concatenation of the
+		// results of each graph.
+		if want := concat(entry.cfg, entry.limit, false); !reflect.DeepEqual(got, want) {
+			t.Errorf("planner.Execute returned the wrong rows for query %q; got %q, want %q", q, got, want)
+		}
+		// Multiset graphs keep a copy of the triples of each graph.
+		st := parseQuery(t, q)
+		st.SetMultisetGraphs()
+		plnr := planStatement(t, s, st)
+		if got := plnr.String(); !strings.Contains(got, "merge the sorted results of each graph") {
+			t.Errorf("planner.New should have merged the sorted graph results for multiset query %q; got plan\n%s", q, got)
+		}
+		tbl, err := plnr.Execute(ctx)
+		if err != nil {
+			t.Fatalf("planner.Execute failed for query %q with error %v", q, err)
+		}
+		if got, want := rowStrings(tbl, []string{"?s", "?o"}), concat(entry.cfg, entry.limit, true); !reflect.DeepEqual(got, want) {
+			t.Errorf("planner.Execute returned the wrong rows for multiset query %q; got %q, want %q", q, got, want)
+		}
+	}
+	// Aggregations depend on the rows of all the graphs, hence they are not
+	// computed independently for each graph.
+	q := `select ?s, count(?o) over (order by ?s) as ?n from ?g1, ?g2, ?g3 where {?s "bought"@[] ?o} order by ?n desc;`
+	st := parseQuery(t, q)
+	st.SetMultisetGraphs()
+	plnr := planStatement(t, s, st)
+	if got := plnr.String(); strings.Contains(got, "merge the sorted results of each graph") {
+		t.Errorf("planner.New should not merge the sorted graph results for aggregation query %q; got plan\n%s", q, got)
+	}
+	tbl, err := plnr.Execute(ctx)
+	if err != nil {
+		t.Fatalf("planner.Execute failed for query %q with error %v", q, err)
+	}
+	if got, want := rowStrings(tbl, []string{"?s", "?n"})[0], "/u<zoe>\t\"7\"^^type:int64"; got != want {
+		t.Errorf("planner.Execute returned the wrong first row for query %q; got %q, want %q", q, got, want)
+	}
+}
+
+func TestPlannerSchemaQuery(t *testing.T) {
+	testTable := []struct {
+		q    string
+		want []string
+	}{
+		{
+			q: `select ?p, ?domain, ?range from schema(?test);`,
+			want: []string{
+				`"bought"	/u	/c`,
+				`"connects_to"	/room	/room`,
+				`"in"	/item/book	/room`,
+				`"is_a"	/c	/t`,
+				`"parent_of"	/u	/u`,
+				`"predicate"	/l	predicate`,
+			},
+		},
+		{
+			q: `select ?p, ?domain, ?range from schema(?test, "is_a"@[], "bought"@[], "parent_of"@[]);`,
+			want: []string{
+				`"bought"	/u	/c`,
+				`"is_a"	/c	/t`,
+				`"parent_of"	/u	/u`,
+			},
+		},
+		{
+			q: `select ?p, ?range from schema(?test, "predicate"@[], "in"@[]);`,
+			want: []string{
+				`"in"	/room`,
+				`"predicate"	predicate`,
+			},
+		},
+	}
+	s := populateTestStore(t)
+	for _, entry := range testTable {
+		tbl := mustRunQuery(t, s, entry.q)
+		if got := rowStrings(tbl, tbl.Bindings()); !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute returned the wrong schema for query %q; got %q, want %q", entry.q, got, entry.want)
+		}
+	}
+}
+
 func TestTreeTraversalToRoot(t *testing.T) {
 	// Graph traversal data.
 	traversalTriples := `/person<Gavin Belson>  "born in"@[]    /city<Springfield>
@@ -529,15 +3333,7 @@ func TestTreeTraversalToRoot(t *testing.T) {
 										 };`
 
 	// Load traversing data
-	s, ctx := memory.NewStore(), context.Background()
-	g, gErr := s.NewGraph(ctx, "?test")
-	if gErr != nil {
-		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", gErr)
-	}
-	b := bytes.NewBufferString(traversalTriples)
-	if _, err := io.ReadIntoGraph(ctx, g, b, literal.DefaultBuilder()); err != nil {
-		t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
-	}
+	s, ctx := populateTestStoreWith(t, traversalTriples), context.Background()
 	p, pErr := grammar.NewParser(grammar.SemanticBQL())
 	if pErr != nil {
 		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", pErr)
@@ -576,15 +3372,7 @@ func TestChaining(t *testing.T) {
 	                   };`
 
 	// Load traversing data
-	s, ctx := memory.NewStore(), context.Background()
-	g, gErr := s.NewGraph(ctx, "?test")
-	if gErr != nil {
-		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", gErr)
-	}
-	b := bytes.NewBufferString(traversalTriples)
-	if _, err := io.ReadIntoGraph(ctx, g, b, literal.DefaultBuilder()); err != nil {
-		t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
-	}
+	s, ctx := populateTestStoreWith(t, traversalTriples), context.Background()
 	p, pErr := grammar.NewParser(grammar.SemanticBQL())
 	if pErr != nil {
 		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", pErr)
@@ -634,15 +3422,7 @@ func TestReificationResolutionIssue70(t *testing.T) {
 		};`
 
 	// Load traversing data
-	s, ctx := memory.NewStore(), context.Background()
-	g, gErr := s.NewGraph(ctx, "?test")
-	if gErr != nil {
-		t.Fatalf("memory.NewGraph failed to create \"?test\" with error %v", gErr)
-	}
-	b := bytes.NewBufferString(issue70Triples)
-	if _, err := io.ReadIntoGraph(ctx, g, b, literal.DefaultBuilder()); err != nil {
-		t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
-	}
+	s, ctx := populateTestStoreWith(t, issue70Triples), context.Background()
 	p, pErr := grammar.NewParser(grammar.SemanticBQL())
 	if pErr != nil {
 		t.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", pErr)
@@ -667,11 +3447,303 @@ func TestReificationResolutionIssue70(t *testing.T) {
 	}
 }
 
+func TestPlannerReify(t *testing.T) {
+	s := populateTestStoreWith(t, `/_<immutable>	"_subject"@[]	/aid</some/subject/id>
+		/_<immutable>	"_predicate"@[]	"/some/immutable/id"@[]
+		/_<immutable>	"_object"@[]	/aid</some/object/id>
+		/_<immutable>	"_owner"@[2017-05-23T16:41:12.187373-07:00]	/gid<0x9>
+		/_<temporal>	"_subject"@[2017-05-23T16:41:12.187373-07:00]	/aid</some/subject/id>
+		/_<temporal>	"_predicate"@[2017-05-23T16:41:12.187373-07:00]	"/some/temporal/id"@[2017-05-23T16:41:12.187373-07:00]
+		/_<temporal>	"_object"@[2017-05-23T16:41:12.187373-07:00]	"a label"^^type:text
+		/_<temporal>	"_owner"@[2017-05-23T16:41:12.187373-07:00]	/gid<0x6>
+		/_<partial>	"_subject"@[]	/aid</some/subject/id>
+		/_<partial>	"_owner"@[2017-05-23T16:41:12.187373-07:00]	/gid<0x1>`)
+	got, err := mustRunQuery(t, s, `select ?bn, reify(?bn) as ?t from ?test where {?bn "_owner"@[,] ?g} order by ?bn;`).ToText(", ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "?bn, ?t\n" +
+		"/_<immutable>, \"/aid</some/subject/id>\t\"/some/immutable/id\"@[]\t/aid</some/object/id>\"^^type:text\n" +
+		"/_<partial>, <NULL>\n" +
+		"/_<temporal>, \"/aid</some/subject/id>\t\"/some/temporal/id\"@[2017-05-23T16:41:12.187373-07:00]\t\"a label\"^^type:text\"^^type:text\n"
+	if got.String() != want {
+		t.Errorf("planner.Execute returned the wrong reified triples;\nGot:\n%s\nWant:\n%s", got, want)
+	}
+	// Reified triples can be parsed back.
+	for _, r := range mustRunQuery(t, s, `select reify(?bn) as ?t from ?test where {?bn "_owner"@[,] ?g};`).Rows() {
+		c, ok := r["?t"]
+		if !ok {
+			continue
+		}
+		txt, err := c.L.Text()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := triple.Parse(txt, literal.DefaultBuilder()); err != nil {
+			t.Errorf("triple.Parse failed to parse reified triple %q with error %v", txt, err)
+		}
+	}
+	// Nodes reifying no triple are ordered and grouped as unbound values.
+	for _, entry := range []struct {
+		q    string
+		want []string
+	}{
+		{
+			q:    `select ?bn, reify(?bn) as ?t from ?test where {?bn "_owner"@[,] ?g} order by ?t;`,
+			want: []string{"/_<partial>", "/_<immutable>", "/_<temporal>"},
+		},
+		{
+			q:    `select ?bn, reify(?bn) as ?t from ?test where {?bn "_owner"@[,] ?g} order by ?t desc;`,
+			want: []string{"/_<temporal>", "/_<immutable>", "/_<partial>"},
+		},
+	} {
+		if got := rowStrings(mustRunQuery(t, s, entry.q), []string{"?bn"}); !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute(%q) returned rows %v; want %v", entry.q, got, entry.want)
+		}
+	}
+	mustRunQuery(t, s, `insert data into ?test {/_<other> "_owner"@[2017-05-23T16:41:12.187373-07:00] /gid<0x2>};`)
+	q := `select reify(?bn) as ?t, count(?bn) as ?n from ?test where {?bn "_owner"@[,] ?g} group by ?t;`
+	tbl := mustRunQuery(t, s, q)
+	if got, want := tbl.NumRows(), 3; got != want {
+		t.Fatalf("planner.Execute(%q) returned %d rows; want %d", q, got, want)
+	}
+	if r := tbl.Rows()[0]; r["?t"] != nil || r["?n"].String() != `"2"^^type:int64` {
+		t.Errorf("planner.Execute(%q) returned first row %v; want the two unbound nodes grouped together", q, r)
+	}
+	if _, err := runQuery(t, s, `select reify(?p) as ?t from ?test where {?bn ?p ?o};`); err == nil {
+		t.Errorf("planner.Execute should have failed to reify a predicate")
+	}
+}
+
+func TestPlannerOrderByNumbers(t *testing.T) {
+	s := memory.NewStore()
+	mustRunQuery(t, s, `create graph ?test;`)
+	mustRunQuery(t, s, `insert data into ?test {
+		/u<a> "age"@[] "9"^^type:int64 .
+		/u<b> "age"@[] "10"^^type:int64 .
+		/u<c> "age"@[] "-3"^^type:int64 .
+		/u<d> "age"@[] "100"^^type:int64 .
+		/u<e> "age"@[] "-20"^^type:int64
+	};`)
+	for _, entry := range []struct {
+		q    string
+		want []string
+	}{
+		{`select ?s, ?age from ?test where {?s "age"@[] ?age} order by ?age;`, []string{"/u<e>", "/u<c>", "/u<a>", "/u<b>", "/u<d>"}},
+		{`select ?s, ?age from ?test where {?s "age"@[] ?age} order by ?age desc;`, []string{"/u<d>", "/u<b>", "/u<a>", "/u<c>", "/u<e>"}},
+	} {
+		if got := rowStrings(mustRunQuery(t, s, entry.q), []string{"?s"}); !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("planner.Execute(%q) returned rows %v; want %v", entry.q, got, entry.want)
+		}
+	}
+}
+
+// sortedRows returns the sorted text lines of the rows of the table.
+func sortedRows(t *testing.T, tbl *table.Table) []string {
+	b, err := tbl.ToText(", ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls := strings.Split(b.String(), "\n")
+	sort.Strings(ls)
+	return ls
+}
+
+// streamQuery streams the rows of the provided query and returns the
+// bindings and the rows received along with the error returned by Stream.
+func streamQuery(ctx context.Context, t *testing.T, s storage.Store, q string) ([]string, []table.Row, error) {
+	var bs []string
+	start := func(b []string) error {
+		bs = b
+		return nil
+	}
+	rows, errc := make(chan table.Row), make(chan error, 1)
+	go func() {
+		errc <- Stream(ctx, planQuery(t, s, q), start, rows)
+	}()
+	var rs []table.Row
+	for r := range rows {
+		rs = append(rs, r)
+	}
+	return bs, rs, <-errc
+}
+
+func TestPlannerStream(t *testing.T) {
+	s := populateTestStore(t)
+	for _, q := range []string{
+		`select ?s, ?o as ?x from ?test where {?s "parent_of"@[] ?o};`,
+		`select ?s, id(?s) as ?id, coalesce(?o, ?s) as ?c from ?test where {?s "parent_of"@[] ?o};`,
+		`select ?s, ?o from ?test where {?s "parent_of"@[] ?o} order by ?o desc;`,
+		`select ?s, count(?o) as ?n from ?test where {?s "parent_of"@[] ?o} group by ?s;`,
+		`show graphs;`,
+	} {
+		want := mustRunQuery(t, s, q)
+		bs, rs, err := streamQuery(context.Background(), t, s, q)
+		if err != nil {
+			t.Errorf("planner.Stream(%q) failed with error %v", q, err)
+			continue
+		}
+		if !reflect.DeepEqual(bs, want.Bindings()) {
+			t.Errorf("planner.Stream(%q) returned bindings %v; want %v", q, bs, want.Bindings())
+		}
+		got, err := table.New(bs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range rs {
+			got.AddRow(r)
+		}
+		if g, w := sortedRows(t, got), sortedRows(t, want); !reflect.DeepEqual(g, w) {
+			t.Errorf("planner.Stream(%q) returned rows %v; want %v", q, g, w)
+		}
+	}
+
+	q := `select ?s from ?test where {?s ?p ?o} limit "2"^^type:int64;`
+	if _, rs, err := streamQuery(context.Background(), t, s, q); err != nil || len(rs) != 2 {
+		t.Errorf("planner.Stream(%q) returned %d rows, %v; want 2 rows", q, len(rs), err)
+	}
+
+	// Streaming stops once the context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	rows, errc := make(chan table.Row), make(chan error, 1)
+	go func() {
+		errc <- Stream(ctx, planQuery(t, s, `select ?s, ?p, ?o from ?test where {?s ?p ?o};`), func([]string) error { return nil }, rows)
+	}()
+	<-rows
+	cancel()
+	for range rows {
+	}
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("planner.Stream returned error %v after canceling the context; want %v", err, context.Canceled)
+	}
+	// Errors returned by start stop the execution before sending any row.
+	rows = make(chan table.Row, 100)
+	fail := func([]string) error { return errors.New("start failed") }
+	if err := Stream(context.Background(), planQuery(t, s, `select ?s from ?test where {?s ?p ?o};`), fail, rows); err == nil {
+		t.Errorf("planner.Stream should have failed if start fails")
+	}
+	if r, ok := <-rows; ok {
+		t.Errorf("planner.Stream sent row %v after start failed", r)
+	}
+}
+
+func TestPlannerCoalesce(t *testing.T) {
+	s := populateTestStoreWith(t, `/_<full>	"_subject"@[]	/u<joe>
+		/_<full>	"_predicate"@[]	"parent_of"@[]
+		/_<full>	"_object"@[]	/u<mary>
+		/_<full>	"_owner"@[]	/u<joe>
+		/_<partial>	"_subject"@[]	/u<joe>
+		/_<partial>	"_owner"@[]	/u<mary>`)
+	table := []struct {
+		q    string
+		want string
+	}{
+		{
+			q: `select ?bn, coalesce(?o, ?bn) as ?v from ?test where {?bn "_owner"@[] ?o} order by ?bn;`,
+			want: `?bn, ?v
+/_<full>, /u<joe>
+/_<partial>, /u<mary>
+`,
+		},
+		{
+			q: `select ?bn, reify(?bn) as ?t, coalesce(?t, ?bn) as ?v from ?test where {?bn "_owner"@[] ?o} order by ?bn;`,
+			want: `?bn, ?t, ?v
+/_<full>, "/u<joe>	"parent_of"@[]	/u<mary>"^^type:text, "/u<joe>	"parent_of"@[]	/u<mary>"^^type:text
+/_<partial>, <NULL>, /_<partial>
+`,
+		},
+		{
+			q: `select reify(?bn) as ?t, id(?bn) as ?id, coalesce(?t, ?id) as ?v from ?test where {?bn "_owner"@[] ?o} order by ?v;`,
+			want: `?t, ?id, ?v
+"/u<joe>	"parent_of"@[]	/u<mary>"^^type:text, "full"^^type:text, "/u<joe>	"parent_of"@[]	/u<mary>"^^type:text
+<NULL>, "partial"^^type:text, "partial"^^type:text
+`,
+		},
+		{
+			q: `select coalesce(?o, ?bn) as ?v, count(?bn) as ?n from ?test where {?bn "_owner"@[] ?o} group by ?v;`,
+			want: `?v, ?n
+/u<joe>, "1"^^type:int64
+/u<mary>, "1"^^type:int64
+`,
+		},
+		{
+			// Unbound coalesced values sort first and are grouped together.
+			q: `select ?bn, reify(?bn) as ?t, coalesce(?t, ?t) as ?v from ?test where {?bn "_owner"@[] ?o} order by ?v;`,
+			want: `?bn, ?t, ?v
+/_<partial>, <NULL>, <NULL>
+/_<full>, "/u<joe>	"parent_of"@[]	/u<mary>"^^type:text, "/u<joe>	"parent_of"@[]	/u<mary>"^^type:text
+`,
+		},
+		{
+			q: `select ?bn, reify(?bn) as ?t, coalesce(?t, ?t) as ?v from ?test where {?bn "_owner"@[] ?o} order by ?v desc;`,
+			want: `?bn, ?t, ?v
+/_<full>, "/u<joe>	"parent_of"@[]	/u<mary>"^^type:text, "/u<joe>	"parent_of"@[]	/u<mary>"^^type:text
+/_<partial>, <NULL>, <NULL>
+`,
+		},
+		{
+			q: `select reify(?bn) as ?t, coalesce(?t, ?t) as ?v, count(?bn) as ?n from ?test where {?bn ?p ?o} group by ?t, ?v;`,
+			want: `?t, ?v, ?n
+<NULL>, <NULL>, "2"^^type:int64
+"/u<joe>	"parent_of"@[]	/u<mary>"^^type:text, "/u<joe>	"parent_of"@[]	/u<mary>"^^type:text, "4"^^type:int64
+`,
+		},
+	}
+	for _, entry := range table {
+		got, err := mustRunQuery(t, s, entry.q).ToText(", ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != entry.want {
+			t.Errorf("planner.Execute(%q) returned the wrong coalesced values;\nGot:\n%s\nWant:\n%s", entry.q, got, entry.want)
+		}
+	}
+}
+
 // benchmarkQuery is a helper function that runs a specified query on the testing data set for benchmarking purposes.
 func benchmarkQuery(query string, b *testing.B) {
+	benchmarkQueryOnStore(query, populateBenchmarkStore(b, memory.NewStore()), b)
+}
+
+// newBoltBenchmarkStore returns a populated BoltDB store backed by a file in a
+// temporary folder and a function to clean it up once done.
+func newBoltBenchmarkStore(b *testing.B) (storage.Store, func()) {
+	dir, err := ioutil.TempDir("", "badwolf_bolt")
+	if err != nil {
+		b.Fatalf("ioutil.TempDir failed with error %v", err)
+	}
+	s, err := bolt.NewStore(filepath.Join(dir, "bench.db"), 0600)
+	if err != nil {
+		os.RemoveAll(dir)
+		b.Fatalf("bolt.NewStore failed with error %v", err)
+	}
+	return populateBenchmarkStore(b, s), func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// newLevelDBBenchmarkStore returns a populated LevelDB store backed by a
+// temporary folder and a function to clean it up once done.
+func newLevelDBBenchmarkStore(b *testing.B) (storage.Store, func()) {
+	dir, err := ioutil.TempDir("", "badwolf_leveldb")
+	if err != nil {
+		b.Fatalf("ioutil.TempDir failed with error %v", err)
+	}
+	s, err := leveldb.NewStore(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		b.Fatalf("leveldb.NewStore failed with error %v", err)
+	}
+	return populateBenchmarkStore(b, s), func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func benchmarkQueryOnStore(query string, s storage.Store, b *testing.B) {
 	ctx := context.Background()
 
-	s := populateBenchmarkStore(b)
 	p, err := grammar.NewParser(grammar.SemanticBQL())
 	if err != nil {
 		b.Fatalf("grammar.NewParser: should have produced a valid BQL parser with error %v", err)
@@ -710,3 +3782,404 @@ func BenchmarkReg2(b *testing.B) {
 func BenchmarkAs2(b *testing.B) {
 	benchmarkQuery(`select ?s as ?s1, ?p as ?p1, ?o as ?o1 from ?test where {?s ?p ?o};`, b)
 }
+
+// These benchmark tests are used to compare the BoltDB-backed store against the
+// memory one on the same data set.
+func BenchmarkMemoryReg1(b *testing.B) {
+	benchmarkQuery(`select ?p, ?o from ?test where {/u<joe> ?p ?o};`, b)
+}
+
+func BenchmarkBoltReg1(b *testing.B) {
+	s, done := newBoltBenchmarkStore(b)
+	defer done()
+	benchmarkQueryOnStore(`select ?p, ?o from ?test where {/u<joe> ?p ?o};`, s, b)
+}
+
+func BenchmarkMemoryReg2(b *testing.B) {
+	benchmarkQuery(`select ?s, ?p, ?o from ?test where {?s ?p ?o};`, b)
+}
+
+func BenchmarkBoltReg2(b *testing.B) {
+	s, done := newBoltBenchmarkStore(b)
+	defer done()
+	benchmarkQueryOnStore(`select ?s, ?p, ?o from ?test where {?s ?p ?o};`, s, b)
+}
+
+func BenchmarkMemoryJoin(b *testing.B) {
+	benchmarkQuery(`select ?s, ?o from ?test where {?s "parent_of"@[] ?x. ?x "parent_of"@[] ?o};`, b)
+}
+
+func BenchmarkBoltJoin(b *testing.B) {
+	s, done := newBoltBenchmarkStore(b)
+	defer done()
+	benchmarkQueryOnStore(`select ?s, ?o from ?test where {?s "parent_of"@[] ?x. ?x "parent_of"@[] ?o};`, s, b)
+}
+
+// These benchmark tests are used to compare the LevelDB-backed store against
+// the memory one on temporal range queries.
+const (
+	turnedRangeQuery = `select ?o from ?test where {/l<barcelona> "predicate"@[] "turned"@[2016-02-01T00:00:00-08:00,2016-03-01T00:00:00-08:00] as ?o};`
+	boughtRangeQuery = `select ?o from ?test where {/u<peter> "bought"@[2016-02-01T00:00:00-08:00,2016-03-01T00:00:00-08:00] ?o};`
+)
+
+func BenchmarkMemoryTurnedRange(b *testing.B) {
+	benchmarkQuery(turnedRangeQuery, b)
+}
+
+func BenchmarkLevelDBTurnedRange(b *testing.B) {
+	s, done := newLevelDBBenchmarkStore(b)
+	defer done()
+	benchmarkQueryOnStore(turnedRangeQuery, s, b)
+}
+
+func BenchmarkMemoryBoughtRange(b *testing.B) {
+	benchmarkQuery(boughtRangeQuery, b)
+}
+
+func BenchmarkLevelDBBoughtRange(b *testing.B) {
+	s, done := newLevelDBBenchmarkStore(b)
+	defer done()
+	benchmarkQueryOnStore(boughtRangeQuery, s, b)
+}
+
+// concurrencyCounter tracks the number of lookups running concurrently and
+// the total number of lookups run.
+type concurrencyCounter struct {
+	mu              sync.Mutex
+	cur, max, total int
+}
+
+// enter records the start of a lookup that lasts for a while to let other
+// lookups overlap with it.
+func (c *concurrencyCounter) enter() {
+	c.mu.Lock()
+	c.cur++
+	c.total++
+	if c.cur > c.max {
+		c.max = c.cur
+	}
+	c.mu.Unlock()
+	time.Sleep(time.Millisecond)
+}
+
+// exit records the end of a lookup.
+func (c *concurrencyCounter) exit() {
+	c.mu.Lock()
+	c.cur--
+	c.mu.Unlock()
+}
+
+// countingStore wraps the graphs of a store to track their concurrent
+// lookups.
+type countingStore struct {
+	storage.Store
+	c *concurrencyCounter
+}
+
+// Graph returns the wrapped graph.
+func (s *countingStore) Graph(ctx context.Context, id string) (storage.Graph, error) {
+	g, err := s.Store.Graph(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &countingGraph{Graph: g, c: s.c}, nil
+}
+
+// countingGraph tracks the concurrent lookups of a graph.
+type countingGraph struct {
+	storage.Graph
+	c *concurrencyCounter
+}
+
+// Objects tracks the lookup and forwards it to the wrapped graph.
+func (g *countingGraph) Objects(ctx context.Context, s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions, objs chan<- *triple.Object) error {
+	g.c.enter()
+	defer g.c.exit()
+	return g.Graph.Objects(ctx, s, p, lo, objs)
+}
+
+// Subjects tracks the lookup and forwards it to the wrapped graph.
+func (g *countingGraph) Subjects(ctx context.Context, p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions, subjs chan<- *node.Node) error {
+	g.c.enter()
+	defer g.c.exit()
+	return g.Graph.Subjects(ctx, p, o, lo, subjs)
+}
+
+func TestPlannerChannelSize(t *testing.T) {
+	ctx := context.Background()
+	s := populateTestStore(t)
+	for _, q := range []string{
+		`select ?s, ?p, ?o from ?test where {?s ?p ?o} order by ?s, ?p, ?o;`,
+		`select ?s, ?o from ?test where {?s "parent_of"@[] ?x . ?x "parent_of"@[] ?o} order by ?s, ?o;`,
+		`select ?o from ?test where {/u<joe> "parent_of"@[]+ ?o} order by ?o;`,
+		`select ?s, ?o from ?test where {?s "connects_to"@[]* ?o} order by ?s, ?o;`,
+	} {
+		var want string
+		for _, chanSize := range []int{0, 1, 16} {
+			plnr, err := New(ctx, s, parseQuery(t, q), chanSize, nil)
+			if err != nil {
+				t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+			}
+			tbl, err := plnr.Execute(ctx)
+			if err != nil {
+				t.Fatalf("planner.Execute(%q) with channel size %d failed with error %v", q, chanSize, err)
+			}
+			got, err := tbl.ToText(", ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if chanSize == 0 {
+				want = got.String()
+				continue
+			}
+			if got.String() != want {
+				t.Errorf("planner.Execute(%q) with channel size %d returned\n%s\nwant\n%s", q, chanSize, got, want)
+			}
+		}
+	}
+}
+
+func TestPlannerTracer(t *testing.T) {
+	ctx := context.Background()
+	s := populateTestStore(t)
+	var b bytes.Buffer
+	q := `select ?s, ?o from ?test where {?s "parent_of"@[] ?x . ?x "parent_of"@[] ?o} order by ?s limit "1"^^type:int64;`
+	plnr, err := New(ctx, s, parseQuery(t, q), 0, &b)
+	if err != nil {
+		t.Fatalf("planner.New failed to create a valid query plan with error %v", err)
+	}
+	if got := b.String(); got != "" {
+		t.Errorf("planner.New should not trace anything before executing the plan; got %q", got)
+	}
+	if _, err := plnr.Execute(ctx); err != nil {
+		t.Fatalf("planner.Execute(%q) failed with error %v", q, err)
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	for _, l := range lines {
+		if !strings.HasPrefix(l, "[") || !strings.Contains(l, "] ") {
+			t.Errorf("planner.Execute traced %q; want lines prefixed by their timestamp", l)
+		}
+	}
+	for _, want := range []string{
+		`Caching graph instances for graphs [?test]`,
+		`Processing graph clause { ?s "parent_of"@[] ?x }`,
+		`Processing graph clause { ?x "parent_of"@[] ?o }`,
+		`Ordering by [ ?s->ASC ]`,
+		`Limit results to 1`,
+		// Steps report the actual rows they consumed and produced.
+		`Resolved graph clause { ?s "parent_of"@[] ?x }: rows_in=0 rows_out=4 elapsed=`,
+		`Resolved graph clause { ?x "parent_of"@[] ?o }: rows_in=4 rows_out=2 elapsed=`,
+		`Projected rows: rows_in=2 rows_out=2 elapsed=`,
+		`Executed query: bindings=[?s ?o] rows=1 elapsed=`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("planner.Execute did not trace %q; got\n%s", want, b.String())
+		}
+	}
+}
+
+func TestPlannerConcurrencyBudget(t *testing.T) {
+	var trpls bytes.Buffer
+	for i := 0; i < 50; i++ {
+		trpls.WriteString(fmt.Sprintf("/u<root>\t\"parent_of\"@[]\t/u<child%d>\n", i))
+		trpls.WriteString(fmt.Sprintf("/u<child%d>\t\"parent_of\"@[]\t/u<grandchild%d>\n", i, i))
+	}
+	s, ctx := populateTestStoreWith(t, trpls.String()), context.Background()
+	q := `SELECT ?c, ?o FROM ?test WHERE {/u<root> "parent_of"@[] ?c . ?c "parent_of"@[] ?o} ORDER BY ?c;`
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, budget := range []int{1, 3, 0} {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+		}
+		if budget == 0 {
+			budget = DefaultBudget()
+		}
+		c := &concurrencyCounter{}
+		plnr, err := NewWithBudget(ctx, &countingStore{Store: s, c: c}, st, 0, budget, nil)
+		if err != nil {
+			t.Fatalf("planner.NewWithBudget failed to create a valid query plan with error %v", err)
+		}
+		tbl, err := plnr.Execute(ctx)
+		if err != nil {
+			t.Fatalf("planner.Execute failed for query %q with error %v", q, err)
+		}
+		if c.max == 0 || c.max > budget {
+			t.Errorf("planner.Execute run %d lookups concurrently; want between 1 and budget %d", c.max, budget)
+		}
+		if budget == 3 && c.max < 2 {
+			t.Errorf("planner.Execute with budget %d should have run lookups in parallel", budget)
+		}
+		got := rowStrings(tbl, []string{"?c", "?o"})
+		if want == nil {
+			want = got
+		}
+		if len(got) != 50 || !reflect.DeepEqual(got, want) {
+			t.Errorf("planner.Execute with budget %d returned %v; want %v", budget, got, want)
+		}
+	}
+}
+
+func TestPlannerParallelCartesianJoin(t *testing.T) {
+	var trpls bytes.Buffer
+	for i := 0; i < 60; i++ {
+		trpls.WriteString(fmt.Sprintf("/u<u%d>\t\"follows\"@[]\t/u<u%d>\n", i, (i+1)%60))
+	}
+	s, ctx := populateTestStoreWith(t, trpls.String()), context.Background()
+	q := `SELECT ?s, ?o, ?k, ?m FROM ?test WHERE {?s ?p ?o . ?k ?l ?m};`
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, budget := range []int{1, 2, 5, 0} {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", q, err)
+		}
+		plnr, err := NewWithBudget(ctx, s, st, 0, budget, nil)
+		if err != nil {
+			t.Fatalf("planner.NewWithBudget failed to create a valid query plan with error %v", err)
+		}
+		tbl, err := plnr.Execute(ctx)
+		if err != nil {
+			t.Fatalf("planner.Execute failed for query %q with error %v", q, err)
+		}
+		got := rowStrings(tbl, []string{"?s", "?o", "?k", "?m"})
+		sort.Strings(got)
+		if want == nil {
+			want = got
+		}
+		if len(got) != 60*60 || !reflect.DeepEqual(got, want) {
+			t.Errorf("planner.Execute with budget %d returned %d rows different from the serial ones", budget, len(got))
+		}
+	}
+}
+
+func TestPlannerRowLimit(t *testing.T) {
+	var trpls bytes.Buffer
+	for i := 0; i < 30; i++ {
+		trpls.WriteString(fmt.Sprintf("/u<u%d>\t\"follows\"@[]\t/u<u%d>\n", i, (i+1)%30))
+	}
+	s, ctx := populateTestStoreWith(t, trpls.String()), context.Background()
+	table := []struct {
+		q       string
+		maxRows int
+		rows    int
+		fail    bool
+	}{
+		{q: `SELECT ?s, ?k FROM ?test WHERE {?s ?p ?o . ?k ?l ?m};`, maxRows: 0, rows: 900},
+		{q: `SELECT ?s, ?k FROM ?test WHERE {?s ?p ?o . ?k ?l ?m};`, maxRows: 900, rows: 900},
+		{q: `SELECT ?s, ?k FROM ?test WHERE {?s ?p ?o . ?k ?l ?m};`, maxRows: 899, fail: true},
+		// The limit bounds the intermediate rows, not only the returned ones.
+		{q: `SELECT DISTINCT ?p FROM ?test WHERE {?s ?p ?o . ?k ?l ?m};`, maxRows: 100, fail: true},
+		{q: `SELECT DISTINCT ?p FROM ?test WHERE {?s ?p ?o . ?k ?l ?m};`, maxRows: 900, rows: 1},
+		{q: `SELECT ?s, ?m FROM ?test WHERE {?s ?p ?o . ?o ?l ?m};`, maxRows: 30, rows: 30},
+		{q: `SELECT ?s, ?m FROM ?test WHERE {?s ?p ?o . ?o ?l ?m};`, maxRows: 29, fail: true},
+		// Rows dropped by the statement limit do not count.
+		{q: `SELECT ?s, ?k FROM ?test WHERE {?s ?p ?o . ?k ?l ?m} LIMIT "5"^^type:int64;`, maxRows: 35, rows: 5},
+		// NOT EXISTS groups are bounded too.
+		{q: `SELECT ?o FROM ?test WHERE {/u<u0> ?p ?o . NOT EXISTS {?o ?l ?m . ?k ?q ?n}};`, maxRows: 29, fail: true},
+	}
+	for _, entry := range table {
+		plnr, err := NewWithRowLimit(ctx, s, parseQuery(t, entry.q), 0, 0, entry.maxRows, nil)
+		if err != nil {
+			t.Fatalf("planner.NewWithRowLimit failed to create a valid query plan with error %v", err)
+		}
+		tbl, err := plnr.Execute(ctx)
+		if entry.fail {
+			if err == nil || !strings.Contains(err.Error(), "result too large") {
+				t.Errorf("planner.Execute(%q) with row limit %d returned error %v; want a result too large error", entry.q, entry.maxRows, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("planner.Execute(%q) with row limit %d failed with error %v", entry.q, entry.maxRows, err)
+			continue
+		}
+		if got, want := tbl.NumRows(), entry.rows; got != want {
+			t.Errorf("planner.Execute(%q) with row limit %d returned %d rows; want %d", entry.q, entry.maxRows, got, want)
+		}
+	}
+}
+
+func TestPlannerLimitStopsResolvingClauses(t *testing.T) {
+	var trpls bytes.Buffer
+	for i := 0; i < 50; i++ {
+		trpls.WriteString(fmt.Sprintf("/u<root>\t\"parent_of\"@[]\t/u<child%d>\n", i))
+		trpls.WriteString(fmt.Sprintf("/u<child%d>\t\"parent_of\"@[]\t/u<grandchild%d>\n", i, i))
+	}
+	s, ctx := populateTestStoreWith(t, trpls.String()), context.Background()
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first clause takes one lookup, and the second one a lookup per child.
+	pattern := `SELECT ?c, ?o FROM ?test WHERE {/u<root> "parent_of"@[] ?c . ?c "parent_of"@[] ?o}`
+	table := []struct {
+		q       string
+		rows    int
+		lookups int
+	}{
+		{q: pattern + `;`, rows: 50, lookups: 51},
+		{q: pattern + ` LIMIT "2"^^type:int64;`, rows: 2, lookups: 3},
+		{q: pattern + ` LIMIT "0"^^type:int64;`, rows: 0, lookups: 51},
+		// Sorting, grouping, or removing rows needs all of them.
+		{q: pattern + ` ORDER BY ?o LIMIT "2"^^type:int64;`, rows: 2, lookups: 51},
+		{q: `SELECT ?c, count(?o) AS ?n FROM ?test WHERE {/u<root> "parent_of"@[] ?c . ?c "parent_of"@[] ?o} GROUP BY ?c LIMIT "2"^^type:int64;`, rows: 2, lookups: 51},
+		{q: `SELECT DISTINCT ?c FROM ?test WHERE {/u<root> "parent_of"@[] ?c . ?c "parent_of"@[] ?o} LIMIT "2"^^type:int64;`, rows: 2, lookups: 51},
+		{q: `SELECT ?c, ?o FROM ?test WHERE {/u<root> "parent_of"@[] ?c . ?c "parent_of"@[] ?o . !{?o "parent_of"@[] ?x}} LIMIT "2"^^type:int64;`, rows: 2, lookups: 51},
+	}
+	for _, entry := range table {
+		st := &semantic.Statement{}
+		if err := p.Parse(grammar.NewLLk(entry.q, 1), st); err != nil {
+			t.Fatalf("Parser.consume: failed to parse query %q with error %v", entry.q, err)
+		}
+		c := &concurrencyCounter{}
+		plnr, err := NewWithBudget(ctx, &countingStore{Store: s, c: c}, st, 0, 1, nil)
+		if err != nil {
+			t.Fatalf("planner.NewWithBudget failed to create a valid query plan with error %v", err)
+		}
+		tbl, err := plnr.Execute(ctx)
+		if err != nil {
+			t.Fatalf("planner.Execute failed for query %q with error %v", entry.q, err)
+		}
+		if got := tbl.NumRows(); got != entry.rows {
+			t.Errorf("planner.Execute returned %d rows for query %q; want %d", got, entry.q, entry.rows)
+		}
+		if c.total < entry.lookups {
+			t.Errorf("planner.Execute run %d lookups for query %q; want at least %d", c.total, entry.q, entry.lookups)
+		}
+		if entry.lookups < 51 && c.total > entry.lookups {
+			t.Errorf("planner.Execute run %d lookups for query %q; want at most %d", c.total, entry.q, entry.lookups)
+		}
+		for _, r := range rowStrings(tbl, []string{"?c"}) {
+			if !strings.HasPrefix(r, "/u<child") {
+				t.Errorf("planner.Execute returned unexpected row %q for query %q", r, entry.q)
+			}
+		}
+	}
+}
+
+func TestSemaphoreTryAcquire(t *testing.T) {
+	s := make(semaphore, 3)
+	s.acquire()
+	if got, want := s.tryAcquire(5), 2; got != want {
+		t.Errorf("semaphore.tryAcquire(5) acquired %d slots; want %d", got, want)
+	}
+	if got, want := s.tryAcquire(1), 0; got != want {
+		t.Errorf("semaphore.tryAcquire(1) acquired %d slots on a full semaphore; want %d", got, want)
+	}
+	s.release()
+	if got, want := s.tryAcquire(1), 1; got != want {
+		t.Errorf("semaphore.tryAcquire(1) acquired %d slots; want %d", got, want)
+	}
+	var nilSemaphore semaphore
+	if got, want := nilSemaphore.tryAcquire(cap(nilSemaphore)), 0; got != want {
+		t.Errorf("semaphore.tryAcquire on a nil semaphore acquired %d slots; want %d", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+)
+
+// reificationParts lists the predicate IDs of the triples reifying a triple.
+var reificationParts = []string{"_subject", "_predicate", "_object"}
+
+// reifiedTriple returns the triple reified by the provided node, assembled out
+// of the objects of its _subject, _predicate, and _object triples in any of
+// the provided graphs. The time anchors of the reification predicates are
+// ignored, hence both immutable and temporal reifications are resolved. It
+// returns nil if any of the parts is missing, or has several different values.
+func reifiedTriple(ctx context.Context, gs []storage.Graph, n *node.Node, chanSize int) (*triple.Triple, error) {
+	parts := make(map[string]map[string]*triple.Object)
+	for _, id := range reificationParts {
+		parts[id] = make(map[string]*triple.Object)
+	}
+	for _, g := range gs {
+		var (
+			tErr error
+			wg   sync.WaitGroup
+		)
+		ts := make(chan *triple.Triple, chanSize)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tErr = g.TriplesForSubject(ctx, n, storage.DefaultLookup, ts)
+		}()
+		for t := range ts {
+			if os, ok := parts[string(t.Predicate().ID())]; ok {
+				os[t.Object().String()] = t.Object()
+			}
+		}
+		wg.Wait()
+		if tErr != nil {
+			return nil, tErr
+		}
+	}
+	var objs []*triple.Object
+	for _, id := range reificationParts {
+		if len(parts[id]) != 1 {
+			return nil, nil
+		}
+		for _, o := range parts[id] {
+			objs = append(objs, o)
+		}
+	}
+	s, err := objs[0].Node()
+	if err != nil {
+		return nil, nil
+	}
+	p, err := objs[1].Predicate()
+	if err != nil {
+		return nil, nil
+	}
+	return triple.New(s, p, objs[2])
+}
+
+// reifiedTripleCell returns a text literal cell with the provided triple in
+// the tab separated form read by triple.Parse. Nil triples return an unbound
+// cell.
+func reifiedTripleCell(t *triple.Triple) (*table.Cell, error) {
+	if t == nil {
+		return nil, nil
+	}
+	l, err := literal.DefaultBuilder().Build(literal.Text, t.String())
+	if err != nil {
+		return nil, err
+	}
+	return &table.Cell{L: l}, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/triple/literal"
+)
+
+// sampledRow contains a row kept by the weighted reservoir along with its
+// sampling key.
+type sampledRow struct {
+	key float64
+	row table.Row
+}
+
+// sampleReservoir is a min heap of sampled rows keyed by their sampling key,
+// hence the row with the smallest key is the first one to be replaced.
+type sampleReservoir []sampledRow
+
+func (r sampleReservoir) Len() int            { return len(r) }
+func (r sampleReservoir) Less(i, j int) bool  { return r[i].key < r[j].key }
+func (r sampleReservoir) Swap(i, j int)       { r[i], r[j] = r[j], r[i] }
+func (r *sampleReservoir) Push(x interface{}) { *r = append(*r, x.(sampledRow)) }
+func (r *sampleReservoir) Pop() interface{} {
+	old := *r
+	x := old[len(old)-1]
+	*r = old[:len(old)-1]
+	return x
+}
+
+// sampleWeight returns the weight bound to the provided binding of the row.
+func sampleWeight(r table.Row, w string) (float64, error) {
+	c, ok := r[w]
+	if !ok || c.L == nil {
+		return 0, fmt.Errorf("weighted sample requires a literal weight in binding %q; found %v instead", w, c)
+	}
+	switch c.L.Type() {
+	case literal.Int64:
+		v, err := c.L.Int64()
+		return float64(v), err
+	case literal.Float64:
+		return c.L.Float64()
+	case literal.Decimal:
+		v, err := c.L.Decimal()
+		if err != nil {
+			return 0, err
+		}
+		f, _ := v.Float64()
+		return f, nil
+	}
+	return 0, fmt.Errorf("weighted sample requires an int64, float64, or decimal weight in binding %q; found %s instead", w, c.L)
+}
+
+// sampleUniform returns a uniform value in (0, 1] for the provided row given
+// the seed. The value only depends on the seed, the values of the row, and the
+// number of identical rows seen before, hence samples are reproducible no
+// matter the order in which the storage drivers return the triples.
+func sampleUniform(seed int64, r table.Row, bs []string, seen map[string]uint64) float64 {
+	var buf [8]byte
+	h := fnv.New64a()
+	for _, b := range bs {
+		h.Write([]byte(r[b].String()))
+		h.Write([]byte{0})
+	}
+	k := string(h.Sum(nil))
+	binary.LittleEndian.PutUint64(buf[:], seen[k])
+	seen[k]++
+	h.Write(buf[:])
+	binary.LittleEndian.PutUint64(buf[:], uint64(seed))
+	h.Write(buf[:])
+	// Mix the bits with the splitmix64 finalizer, since the high bits of FNV
+	// hashes are poorly distributed for similar inputs.
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return (float64(x>>11) + 1) / (1 << 53)
+}
+
+// weightedSample replaces the rows of the table with n rows sampled without
+// replacement with probability proportional to their weight. It implements
+// the Efraimidis and Spirakis weighted reservoir sampling in a single pass
+// over the rows; each row gets the key log(u)/w for a uniform u in (0, 1]
+// derived from the seed and the row, and the n rows with the largest keys are
+// kept. Rows whose weight is not positive are never sampled. Sampled rows are
+// returned by decreasing key.
+func (p *queryPlan) weightedSample() error {
+	_, w, n := p.stm.WeightedSample()
+	seed, ok := p.stm.WeightedSampleSeed()
+	if !ok {
+		seed = time.Now().UnixNano()
+	}
+	trace(p.tracer, func() []string {
+		return []string{fmt.Sprintf("Sampling %d rows weighted by %s out of %d rows", n, w, p.tbl.NumRows())}
+	})
+	res, bs, seen := &sampleReservoir{}, p.tbl.Bindings(), make(map[string]uint64)
+	for _, r := range p.tbl.Rows() {
+		wv, err := sampleWeight(r, w)
+		if err != nil {
+			return err
+		}
+		if wv <= 0 || math.IsNaN(wv) {
+			continue
+		}
+		k := math.Log(sampleUniform(seed, r, bs, seen)) / wv
+		switch {
+		case int64(res.Len()) < n:
+			heap.Push(res, sampledRow{key: k, row: r})
+		case k > (*res)[0].key:
+			(*res)[0] = sampledRow{key: k, row: r}
+			heap.Fix(res, 0)
+		}
+	}
+	sort.Sort(sort.Reverse(res))
+	t, err := table.New(p.tbl.Bindings())
+	if err != nil {
+		return err
+	}
+	for _, sr := range *res {
+		t.AddRow(sr.row)
+	}
+	p.tbl = t
+	return nil
+}
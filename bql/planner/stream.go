@@ -0,0 +1,149 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/bql/lexer"
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
+)
+
+// Streamer is implemented by the executors able to deliver the resulting rows
+// as they are produced instead of returning the whole table.
+type Streamer interface {
+	Executor
+
+	// Stream runs the plan calling start with the bindings of the resulting
+	// rows, and then sending each row to the provided channel. The channel is
+	// closed once done. Sending blocks until the row is received or the
+	// context is done, hence slow receivers slow down the execution instead of
+	// buffering the rows. Errors returned by start stop the execution.
+	Stream(ctx context.Context, start func([]string) error, rows chan<- table.Row) error
+}
+
+// Stream runs the plan calling start with the bindings of the resulting rows,
+// and then sending each row to the provided channel, which is closed once
+// done. Executors that do not implement Streamer are executed first, and the
+// rows of the resulting table are sent afterwards.
+func Stream(ctx context.Context, e Executor, start func([]string) error, rows chan<- table.Row) error {
+	if s, ok := e.(Streamer); ok {
+		return s.Stream(ctx, start, rows)
+	}
+	defer close(rows)
+	tbl, err := e.Execute(ctx)
+	if err != nil {
+		return err
+	}
+	if err := start(tbl.Bindings()); err != nil {
+		return err
+	}
+	return sendRows(ctx, tbl, rows)
+}
+
+// Stream runs the query calling start with the bindings of the resulting rows,
+// and then sending each row to the provided channel. Rows of queries without
+// ORDER BY, GROUP BY, HAVING, DISTINCT, aggregations, or weighted sampling are
+// projected and sent one at a time once the graph pattern is resolved. Any
+// other query needs all its rows to compute the first one, hence they are
+// sent once the query is fully executed.
+func (p *queryPlan) Stream(ctx context.Context, start func([]string) error, rows chan<- table.Row) error {
+	defer close(rows)
+	_, err := p.execute(ctx, nil, start, rows)
+	return err
+}
+
+// streamable returns true if each row of the resulting table can be
+// projected and returned on its own.
+func (p *queryPlan) streamable() bool {
+	if len(p.stm.GroupByBindings()) > 0 || len(p.stm.OrderByConfig()) > 0 || p.stm.HasHavingClause() || p.stm.IsDistinct() || p.stm.IsWeightedSample() {
+		return false
+	}
+	for _, prj := range p.stm.Projections() {
+		if prj.OP != lexer.ItemError || prj.Window != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// streamRows projects the rows of the table one at a time, and sends each one
+// as soon as it is projected. It stops once the limit is reached; offsets
+// require ORDER BY, hence streamed queries have none. It returns the number
+// of rows sent.
+func (p *queryPlan) streamRows(ctx context.Context, rows chan<- table.Row) (int, error) {
+	reified := make(map[*semantic.Projection]map[string]*table.Cell)
+	n := 0
+	for _, r := range p.tbl.Rows() {
+		if p.stm.IsLimitSet() && int64(n) >= p.rowLimit {
+			break
+		}
+		// Extracted node parts need to be bound first, since they can be
+		// coalesced.
+		for _, prj := range p.stm.Projections() {
+			if prj.Extract == lexer.ItemError {
+				continue
+			}
+			if reified[prj] == nil {
+				reified[prj] = make(map[string]*table.Cell)
+			}
+			if err := p.extractNodePart(ctx, prj, r, reified[prj]); err != nil {
+				return n, err
+			}
+		}
+		pr := make(table.Row)
+		for _, prj := range p.stm.Projections() {
+			if len(prj.Coalesce) > 0 {
+				coalesceRow(prj, r)
+			}
+			// Unbound values are left out of the projected row.
+			switch {
+			case prj.Extract != lexer.ItemError || len(prj.Coalesce) > 0:
+				if c, ok := r[prj.Alias]; ok {
+					pr[prj.Alias] = c
+				}
+			case prj.Alias != "":
+				if c, ok := r[prj.Binding]; ok {
+					pr[prj.Alias] = c
+				}
+			default:
+				if c, ok := r[prj.Binding]; ok {
+					pr[prj.Binding] = c
+				}
+			}
+		}
+		select {
+		case rows <- pr:
+			n++
+		case <-ctx.Done():
+			return n, ctx.Err()
+		}
+	}
+	return n, nil
+}
+
+// sendRows sends the rows of the table to the provided channel until the
+// context is done.
+func sendRows(ctx context.Context, tbl *table.Table, rows chan<- table.Row) error {
+	for _, r := range tbl.Rows() {
+		select {
+		case rows <- r:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/storage"
+)
+
+// ExecuteTx executes the provided INSERT and DELETE statements in order. All
+// the statements are planned before any of them is executed. If the store
+// implements storage.TransactionalStore, the statements run in a transaction
+// and the mutations of all of them are rolled back if any fails. Otherwise,
+// execution stops at the first failing statement and the mutations of the
+// statements already executed are kept.
+func ExecuteTx(ctx context.Context, store storage.Store, stms []*semantic.Statement) error {
+	var (
+		plns   []Executor
+		graphs []string
+	)
+	for i, stm := range stms {
+		if t := stm.Type(); t != semantic.Insert && t != semantic.Delete {
+			return fmt.Errorf("planner.ExecuteTx: statement %d is a %s statement; only INSERT and DELETE statements are allowed", i, t)
+		}
+		pln, err := New(ctx, store, stm, 0, nil)
+		if err != nil {
+			return fmt.Errorf("planner.ExecuteTx: failed to plan statement %d with error %v", i, err)
+		}
+		plns = append(plns, pln)
+		graphs = append(graphs, mutatedGraphs(stm)...)
+	}
+	ts, ok := store.(storage.TransactionalStore)
+	if !ok {
+		return executeAll(ctx, plns)
+	}
+	txCtx, err := ts.Begin(ctx, graphs)
+	if err != nil {
+		return err
+	}
+	if err := executeAll(txCtx, plns); err != nil {
+		if rerr := ts.Rollback(ctx); rerr != nil {
+			return fmt.Errorf("%v; failed to roll back the transaction with error %v", err, rerr)
+		}
+		return err
+	}
+	return ts.Commit(ctx)
+}
+
+// executeAll executes the provided plans in order, stopping at the first one
+// that fails.
+func executeAll(ctx context.Context, plns []Executor) error {
+	for i, pln := range plns {
+		if _, err := pln.Execute(ctx); err != nil {
+			return fmt.Errorf("planner.ExecuteTx: statement %d failed with error %v", i, err)
+		}
+	}
+	return nil
+}
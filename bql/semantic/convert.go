@@ -16,6 +16,7 @@ package semantic
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/google/badwolf/bql/lexer"
 	"github.com/google/badwolf/triple/literal"
@@ -82,6 +83,19 @@ func ToNode(ce ConsumedElement) (*node.Node, error) {
 	return node.Parse(tkn.Text)
 }
 
+// ToNodeType converts the node type constraint found by the lexer into a
+// BadWolf node type.
+func ToNodeType(ce ConsumedElement) (*node.Type, error) {
+	if ce.IsSymbol() {
+		return nil, fmt.Errorf("semantic.ToNodeType cannot convert symbol %v to a node type", ce)
+	}
+	tkn := ce.Token()
+	if tkn.Type != lexer.ItemNodeType {
+		return nil, fmt.Errorf("semantic.ToNodeType cannot convert token type %s to a node type", tkn.Type)
+	}
+	return node.NewType(strings.TrimSuffix(strings.TrimPrefix(tkn.Text, "["), "]"))
+}
+
 // ToPredicate converts the node found by the lexer and converts it into a
 // BadWolf predicate.
 func ToPredicate(ce ConsumedElement) (*predicate.Predicate, error) {
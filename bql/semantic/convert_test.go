@@ -44,6 +44,30 @@ func TestToNode(t *testing.T) {
 	}
 }
 
+func TestToNodeType(t *testing.T) {
+	// Consume a valid node type token.
+	tkn := &lexer.Token{
+		Type: lexer.ItemNodeType,
+		Text: "[/item/book]",
+	}
+	ce := NewConsumedToken(tkn)
+	if nt, err := ToNodeType(ce); err != nil || nt.String() != "/item/book" {
+		t.Errorf("semantic.ToNodeType failed to properly convert %+v; err=%v, type=%v", ce, err, nt)
+	}
+	// Reject invalid tokens.
+	tkn.Text = "[/item/]"
+	ice := NewConsumedToken(tkn)
+	if nt, err := ToNodeType(ice); err == nil {
+		t.Errorf("semantic.ToNodeType should have never produced type %v from invalid text %q", nt, tkn.Text)
+	}
+	// Reject invalid token types.
+	tkn.Type = lexer.ItemNode
+	nce := NewConsumedToken(tkn)
+	if nt, err := ToNodeType(nce); err == nil {
+		t.Errorf("semantic.ToNodeType should have never produced type %v from invalid type %q", nt, tkn.Type)
+	}
+}
+
 func TestToPredicate(t *testing.T) {
 	// Consume a valid node token.
 	tkn := &lexer.Token{
@@ -0,0 +1,206 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semantic
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/badwolf/bql/lexer"
+)
+
+// Dump returns an S-expression rendering of the parsed statement, one section
+// per line, intended for debugging the parser and the planner. Graph pattern
+// clauses are listed in the order the planner resolves them, along with their
+// bindings and specificity. Empty sections are omitted, so the output only
+// depends on what the statement contains.
+func (s *Statement) Dump() string {
+	b := bytes.NewBufferString("(statement ")
+	b.WriteString(s.Type().String())
+	section := func(name string, vals ...string) {
+		b.WriteString("\n  (")
+		b.WriteString(name)
+		for _, v := range vals {
+			b.WriteString(" ")
+			b.WriteString(v)
+		}
+		b.WriteString(")")
+	}
+	if s.distinct {
+		section("distinct")
+	}
+	if s.ignoreCase {
+		section("ignore-case")
+	}
+	if len(s.graphNames) > 0 {
+		section("graphs", s.graphNames...)
+	}
+	if len(s.outputGraphNames) > 0 {
+		section("output-graphs", s.outputGraphNames...)
+	}
+	if s.asOf != nil {
+		section("as-of", s.asOf.Format(time.RFC3339Nano))
+	}
+	for _, t := range s.data {
+		section("data", t.String())
+	}
+	for _, t := range s.nowData {
+		section("data", t.String())
+	}
+	if s.cloneSource != "" {
+		section("clone", s.cloneSource)
+	}
+	if s.importData != "" {
+		section("import", strconv.Quote(s.importData))
+	}
+	for _, c := range s.SortedGraphPatternClauses() {
+		section("clause", dumpClause(c))
+	}
+	for _, c := range s.NegatedGraphPatternClauses() {
+		section("negated", dumpClause(c))
+	}
+	for i, grp := range s.NotExistsGroups() {
+		var cls []string
+		for _, c := range grp {
+			cls = append(cls, "("+dumpClause(c)+")")
+		}
+		section(fmt.Sprintf("not-exists %d", i+1), cls...)
+	}
+	for _, f := range s.filters {
+		section("filter", f.String())
+	}
+	for _, c := range s.constructClauses {
+		section("construct", dumpConstructClause(c))
+	}
+	for _, p := range s.projection {
+		section("projection", dumpProjection(p))
+	}
+	if len(s.groupBy) > 0 {
+		section("group-by", s.groupBy...)
+	}
+	if len(s.orderBy) > 0 {
+		var os []string
+		for _, o := range s.orderBy {
+			if o.Desc {
+				os = append(os, o.Binding+" desc")
+			} else {
+				os = append(os, o.Binding+" asc")
+			}
+		}
+		section("order-by", strings.Join(os, ", "))
+	}
+	if len(s.havingExpression) > 0 {
+		var ts []string
+		for _, ce := range s.havingExpression {
+			ts = append(ts, ce.Token().Text)
+		}
+		section("having", ts...)
+	}
+	switch {
+	case s.limitParam != "":
+		section("limit", s.limitParam)
+	case s.limitSet:
+		section("limit", fmt.Sprint(s.limit))
+	}
+	switch {
+	case s.offsetParam != "":
+		section("offset", s.offsetParam)
+	case s.offset > 0:
+		section("offset", fmt.Sprint(s.offset))
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// dumpClause renders a graph clause with its specificity and the bindings it
+// produces.
+func dumpClause(c *GraphClause) string {
+	return fmt.Sprintf("%d (bindings %s) %s", c.Specificity(), strings.Join(c.orderedBindings(), " "), c.String())
+}
+
+// dumpProjection renders a projection, including its alias and aggregation.
+func dumpProjection(p *Projection) string {
+	b := bytes.NewBufferString(p.Binding)
+	if len(p.Coalesce) > 0 {
+		b.WriteString("coalesce(")
+		b.WriteString(strings.Join(p.Coalesce, ", "))
+		b.WriteString(")")
+	}
+	if p.Alias != "" {
+		b.WriteString(" as ")
+		b.WriteString(p.Alias)
+	}
+	if p.Extract != lexer.ItemError {
+		b.WriteString(" (")
+		b.WriteString(p.Extract.String())
+		b.WriteString(")")
+	}
+	if p.OP != lexer.ItemError {
+		b.WriteString(" (")
+		b.WriteString(p.OP.String())
+		if p.Modifier != lexer.ItemError {
+			b.WriteString(" ")
+			b.WriteString(p.Modifier.String())
+		}
+		b.WriteString(")")
+		if p.Window != nil {
+			b.WriteString(" ")
+			b.WriteString(p.Window.String())
+		}
+	}
+	return b.String()
+}
+
+// dumpConstructClause renders the subject, predicate, and object of a
+// construct clause.
+func dumpConstructClause(c *ConstructClause) string {
+	var s, p, o string
+	switch {
+	case c.S != nil:
+		s = c.S.String()
+	case c.SNewBlank:
+		s = "_"
+	default:
+		s = c.SBinding
+	}
+	switch {
+	case c.P != nil:
+		p = c.P.String()
+	case c.PID != "":
+		p = fmt.Sprintf("%q@[%s]", c.PID, c.PAnchorBinding)
+	default:
+		p = c.PBinding
+	}
+	switch {
+	case c.O != nil:
+		o = c.O.String()
+	case c.OID != "":
+		o = fmt.Sprintf("%q@[%s]", c.OID, c.OAnchorBinding)
+	case c.ONewBlank:
+		o = "_"
+	case len(c.OConcat) > 0:
+		var as []string
+		for _, a := range c.OConcat {
+			as = append(as, a.String())
+		}
+		o = fmt.Sprintf("(concat %s)", strings.Join(as, " "))
+	default:
+		o = c.OBinding
+	}
+	return strings.Join([]string{s, p, o}, " ")
+}
@@ -18,10 +18,15 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/google/badwolf/bql/lexer"
 	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
 )
 
 // Evaluator interface computes the evaluation of a boolean expression.
@@ -58,6 +63,14 @@ const (
 	AND
 	// OR represents 'or'
 	OR
+	// NEQ represents '!='
+	NEQ
+	// LEQ represents '<='
+	LEQ
+	// GEQ represents '>='
+	GEQ
+	// MATCH represents '=~'
+	MATCH
 )
 
 // String returns a readable string of the operation.
@@ -75,6 +88,14 @@ func (o OP) String() string {
 		return "and"
 	case OR:
 		return "or"
+	case NEQ:
+		return "!="
+	case LEQ:
+		return "<="
+	case GEQ:
+		return ">="
+	case MATCH:
+		return "=~"
 	default:
 		return "@UNKNOWN@"
 	}
@@ -117,6 +138,19 @@ func (e *evaluationNode) Evaluate(r table.Row) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	if eL.L != nil && eR.L != nil && (eL.L.Type() == literal.Decimal || eR.L.Type() == literal.Decimal) {
+		// Decimals are compared numerically, hence "1.0" and "1.00" are equal.
+		if cmp, err := table.CompareCells(eL, eR); err == nil {
+			switch e.op {
+			case EQ:
+				return cmp == 0, nil
+			case LT:
+				return cmp < 0, nil
+			case GT:
+				return cmp > 0, nil
+			}
+		}
+	}
 	csEL, csER := cs(eL), cs(eR)
 	switch e.op {
 	case EQ:
@@ -148,6 +182,390 @@ func NewEvaluationExpression(op OP, lB, rB string) (Evaluator, error) {
 	}
 }
 
+// operand represents one of the sides of a comparison. It contains either a
+// binding, a constant value, or a parameter whose value is not bound yet.
+type operand struct {
+	binding string
+	param   string
+	value   *table.Cell
+}
+
+// newOperand returns the operand boxed in the provided token. Parameters take
+// their value from the provided parameter values; if none are provided the
+// parameter remains unbound.
+func newOperand(tkn *lexer.Token, ps map[string]*table.Cell) (operand, error) {
+	switch tkn.Type {
+	case lexer.ItemBinding:
+		return operand{binding: tkn.Text}, nil
+	case lexer.ItemParameter:
+		if ps == nil {
+			return operand{param: tkn.Text}, nil
+		}
+		c, ok := ps[tkn.Text]
+		if !ok || c == nil {
+			return operand{}, fmt.Errorf("missing value for parameter %s", tkn.Text)
+		}
+		return operand{value: c}, nil
+	case lexer.ItemNode:
+		n, err := node.Parse(tkn.Text)
+		if err != nil {
+			return operand{}, err
+		}
+		return operand{value: &table.Cell{N: n}}, nil
+	case lexer.ItemLiteral:
+		l, err := literal.DefaultBuilder().Parse(tkn.Text)
+		if err != nil {
+			return operand{}, err
+		}
+		return operand{value: &table.Cell{L: l}}, nil
+	case lexer.ItemPredicate:
+		p, err := predicate.Parse(tkn.Text)
+		if err != nil {
+			return operand{}, err
+		}
+		return operand{value: &table.Cell{P: p}}, nil
+	default:
+		return operand{}, fmt.Errorf("cannot build a comparison operand for %v", tkn)
+	}
+}
+
+// cell returns the value of the operand for the provided row.
+func (o operand) cell(r table.Row) (*table.Cell, error) {
+	if o.value != nil {
+		return o.value, nil
+	}
+	if o.param != "" {
+		return nil, fmt.Errorf("comparison operations require a value for parameter %s", o.param)
+	}
+	c, ok := r[o.binding]
+	if !ok {
+		return nil, fmt.Errorf("comparison operations require the binding value for %q for row %q to exist", o.binding, r)
+	}
+	return c, nil
+}
+
+// isTime returns true if the cell holds a time anchor or a temporal predicate.
+func isTime(c *table.Cell) bool {
+	return c.T != nil || (c.P != nil && c.P.Type() == predicate.Temporal)
+}
+
+// comparableTimes returns the provided cells replacing a text literal by the
+// time it holds when the other cell is a time anchor or a temporal predicate.
+// Texts are parsed using the RFC3339Nano format used by time anchors; texts
+// that do not hold a time are returned unchanged.
+func comparableTimes(a, b *table.Cell) (*table.Cell, *table.Cell) {
+	asTime := func(c *table.Cell) *table.Cell {
+		if c.L == nil || c.L.Type() != literal.Text {
+			return c
+		}
+		s, err := c.L.Text()
+		if err != nil {
+			return c
+		}
+		t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(s))
+		if err != nil {
+			return c
+		}
+		return &table.Cell{T: &t}
+	}
+	switch {
+	case isTime(a):
+		return a, asTime(b)
+	case isTime(b):
+		return asTime(a), b
+	}
+	return a, b
+}
+
+// comparisonNode represents the typed comparison of two operands. Unlike
+// evaluationNode, it compares numeric literals numerically and time anchors
+// chronologically. Time anchors can also be compared against text literals
+// holding a time in RFC3339Nano format.
+type comparisonNode struct {
+	op OP
+	l  operand
+	r  operand
+}
+
+// Evaluate the expression.
+func (e *comparisonNode) Evaluate(r table.Row) (bool, error) {
+	lc, err := e.l.cell(r)
+	if err != nil {
+		return false, err
+	}
+	rc, err := e.r.cell(r)
+	if err != nil {
+		return false, err
+	}
+	lc, rc = comparableTimes(lc, rc)
+	cmp, err := table.CompareCells(lc, rc)
+	if err != nil {
+		// Values of the same kind that cannot be ordered, for instance nodes,
+		// can still be checked for equality.
+		lk, lst := lc.Kind()
+		rk, rst := rc.Kind()
+		if (e.op != EQ && e.op != NEQ) || lk != rk || lst != rst {
+			return false, fmt.Errorf("cannot evaluate %s %s %s; %v", lc, e.op, rc, err)
+		}
+		return (lc.String() == rc.String()) == (e.op == EQ), nil
+	}
+	switch e.op {
+	case EQ:
+		return cmp == 0, nil
+	case NEQ:
+		return cmp != 0, nil
+	case LT:
+		return cmp < 0, nil
+	case LEQ:
+		return cmp <= 0, nil
+	case GT:
+		return cmp > 0, nil
+	case GEQ:
+		return cmp >= 0, nil
+	default:
+		return false, fmt.Errorf("comparison evaluation require a comparison operation; found %q instead", e.op)
+	}
+}
+
+// newComparisonExpression creates a new evaluator comparing two operands.
+func newComparisonExpression(op OP, l, r operand) (Evaluator, error) {
+	switch op {
+	case EQ, NEQ, LT, LEQ, GT, GEQ:
+		return &comparisonNode{
+			op: op,
+			l:  l,
+			r:  r,
+		}, nil
+	default:
+		return nil, errors.New("comparison expressions require the operation to be one for the follwing '=', '!=', '<', '<=', '>', '>='")
+	}
+}
+
+// fuzzyNode represents the approximate matching of the text literal bound to
+// a binding against a target text. The text matches if its Levenshtein edit
+// distance to the target is at most the provided distance. Computing the
+// distance costs O(n*m) per row, where n and m are the lengths of the bound
+// text and the target; texts whose length differs from the target by more than
+// the distance are discarded without computing it.
+type fuzzyNode struct {
+	binding  string
+	target   []rune
+	distance int
+}
+
+// Evaluate the expression.
+func (e *fuzzyNode) Evaluate(r table.Row) (bool, error) {
+	c, ok := r[e.binding]
+	if !ok {
+		return false, fmt.Errorf("fuzzy matching requires the binding value for %q for row %q to exist", e.binding, r)
+	}
+	if c == nil || c.L == nil || c.L.Type() != literal.Text {
+		return false, fmt.Errorf("fuzzy matching requires %q to be bound to a text literal; found %v instead", e.binding, c)
+	}
+	s, err := c.L.Text()
+	if err != nil {
+		return false, err
+	}
+	return withinEditDistance([]rune(s), e.target, e.distance), nil
+}
+
+// withinEditDistance returns true if the Levenshtein edit distance between the
+// two provided texts is at most d.
+func withinEditDistance(a, b []rune, d int) bool {
+	if diff := len(a) - len(b); diff > d || -diff > d {
+		return false
+	}
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if v := prev[j] + 1; v < cur[j] {
+				cur[j] = v
+			}
+			if v := cur[j-1] + 1; v < cur[j] {
+				cur[j] = v
+			}
+			if cur[j] < rowMin {
+				rowMin = cur[j]
+			}
+		}
+		// The distance never decreases on later rows.
+		if rowMin > d {
+			return false
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)] <= d
+}
+
+// newFuzzyExpression creates a new evaluator for the fuzzy matching of the
+// provided binding against the target text literal within the provided int64
+// literal edit distance.
+func newFuzzyExpression(bTkn, tTkn, dTkn *lexer.Token) (Evaluator, error) {
+	if bTkn.Type != lexer.ItemBinding {
+		return nil, fmt.Errorf("fuzzy matching requires a binding as first argument; found %v instead", bTkn)
+	}
+	if tTkn.Type != lexer.ItemLiteral || dTkn.Type != lexer.ItemLiteral {
+		return nil, fmt.Errorf("fuzzy matching requires literals as target and distance; found %v and %v instead", tTkn, dTkn)
+	}
+	t, err := literal.DefaultBuilder().Parse(tTkn.Text)
+	if err != nil {
+		return nil, err
+	}
+	if t.Type() != literal.Text {
+		return nil, fmt.Errorf("fuzzy matching requires a text literal as target; found %v instead", t)
+	}
+	ts, err := t.Text()
+	if err != nil {
+		return nil, err
+	}
+	d, err := literal.DefaultBuilder().Parse(dTkn.Text)
+	if err != nil {
+		return nil, err
+	}
+	if d.Type() != literal.Int64 {
+		return nil, fmt.Errorf("fuzzy matching requires an int64 literal as distance; found %v instead", d)
+	}
+	di, err := d.Int64()
+	if err != nil {
+		return nil, err
+	}
+	if di < 0 {
+		return nil, fmt.Errorf("fuzzy matching requires a non negative distance; found %d instead", di)
+	}
+	return &fuzzyNode{
+		binding:  bTkn.Text,
+		target:   []rune(ts),
+		distance: int(di),
+	}, nil
+}
+
+// startsWithNode represents the matching of a text against a prefix. The text
+// is either the text literal bound to a binding, or the ID of the node or
+// predicate bound to it when the binding is wrapped in id().
+type startsWithNode struct {
+	binding string
+	id      bool
+	prefix  string
+}
+
+// Evaluate the expression.
+func (e *startsWithNode) Evaluate(r table.Row) (bool, error) {
+	c, ok := r[e.binding]
+	if !ok {
+		return false, fmt.Errorf("prefix matching requires the binding value for %q for row %q to exist", e.binding, r)
+	}
+	var s string
+	switch {
+	case c != nil && e.id && c.P != nil:
+		s = string(c.P.ID())
+	case c != nil && e.id && c.N != nil:
+		s = string(*c.N.ID())
+	case c != nil && !e.id && c.L != nil && c.L.Type() == literal.Text:
+		t, err := c.L.Text()
+		if err != nil {
+			return false, err
+		}
+		s = t
+	case e.id:
+		return false, fmt.Errorf("id() requires %q to be bound to a node or a predicate; found %v instead", e.binding, c)
+	default:
+		return false, fmt.Errorf("prefix matching requires %q to be bound to a text literal; found %v instead", e.binding, c)
+	}
+	return strings.HasPrefix(s, e.prefix), nil
+}
+
+// newStartsWithExpression creates a new evaluator for the prefix matching of
+// the provided binding against the prefix text literal. If id is true the ID
+// of the bound node or predicate is matched instead of a text literal.
+func newStartsWithExpression(bTkn, pTkn *lexer.Token, id bool) (Evaluator, error) {
+	if bTkn.Type != lexer.ItemBinding {
+		return nil, fmt.Errorf("prefix matching requires a binding as first argument; found %v instead", bTkn)
+	}
+	if pTkn.Type != lexer.ItemLiteral {
+		return nil, fmt.Errorf("prefix matching requires a text literal as prefix; found %v instead", pTkn)
+	}
+	l, err := literal.DefaultBuilder().Parse(pTkn.Text)
+	if err != nil {
+		return nil, err
+	}
+	if l.Type() != literal.Text {
+		return nil, fmt.Errorf("prefix matching requires a text literal as prefix; found %v instead", l)
+	}
+	prefix, err := l.Text()
+	if err != nil {
+		return nil, err
+	}
+	return &startsWithNode{
+		binding: bTkn.Text,
+		id:      id,
+		prefix:  prefix,
+	}, nil
+}
+
+// matchNode represents the matching of the text literal bound to a binding
+// against a regular expression. The regular expression is compiled once when
+// the expression is built.
+type matchNode struct {
+	binding string
+	re      *regexp.Regexp
+}
+
+// Evaluate the expression.
+func (e *matchNode) Evaluate(r table.Row) (bool, error) {
+	c, ok := r[e.binding]
+	if !ok {
+		return false, fmt.Errorf("regular expression matching requires the binding value for %q for row %q to exist", e.binding, r)
+	}
+	if c == nil || c.L == nil || c.L.Type() != literal.Text {
+		return false, fmt.Errorf("regular expression matching requires %q to be bound to a text literal; found %v instead", e.binding, c)
+	}
+	s, err := c.L.Text()
+	if err != nil {
+		return false, err
+	}
+	return e.re.MatchString(s), nil
+}
+
+// newMatchExpression creates a new evaluator matching the provided binding
+// against the regular expression contained in the provided text literal.
+func newMatchExpression(bTkn, reTkn *lexer.Token) (Evaluator, error) {
+	if bTkn.Type != lexer.ItemBinding {
+		return nil, fmt.Errorf("regular expression matching requires a binding as left operand; found %v instead", bTkn)
+	}
+	if reTkn.Type != lexer.ItemLiteral {
+		return nil, fmt.Errorf("regular expression matching requires a text literal as right operand; found %v instead", reTkn)
+	}
+	l, err := literal.DefaultBuilder().Parse(reTkn.Text)
+	if err != nil {
+		return nil, err
+	}
+	if l.Type() != literal.Text {
+		return nil, fmt.Errorf("regular expression matching requires a text literal as right operand; found %v instead", l)
+	}
+	expr, err := l.Text()
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %q; %v", expr, err)
+	}
+	return &matchNode{
+		binding: bTkn.Text,
+		re:      re,
+	}, nil
+}
+
 // booleanNode represents the internal representation of one expression.
 type booleanNode struct {
 	op OP
@@ -242,7 +660,22 @@ func NewUnaryBooleanExpression(op OP, lE Evaluator) (Evaluator, error) {
 // NewEvaluator construct an evaluator given a sequence of tokens. It will
 // return a descriptive error if it could build it properly.
 func NewEvaluator(ce []ConsumedElement) (Evaluator, error) {
-	e, tailCEs, err := internalNewEvaluator(ce)
+	return newEvaluator(ce, false, nil)
+}
+
+// NewFilterEvaluator constructs an evaluator for the expression of a filter
+// clause given a sequence of tokens. Besides bindings, comparisons accept
+// nodes, literals, and predicates as operands, and values are compared based on
+// their type. Comparing values of incompatible types fails on evaluation.
+// Parameters are left unbound and fail on evaluation; use the BindParameters
+// method of the filter clause to provide their values.
+func NewFilterEvaluator(ce []ConsumedElement) (Evaluator, error) {
+	return newEvaluator(ce, true, nil)
+}
+
+// newEvaluator builds the evaluator and checks that all tokens were consumed.
+func newEvaluator(ce []ConsumedElement, filter bool, ps map[string]*table.Cell) (Evaluator, error) {
+	e, tailCEs, err := internalNewEvaluator(ce, filter, ps)
 	if err != nil {
 		return nil, err
 	}
@@ -252,8 +685,10 @@ func NewEvaluator(ce []ConsumedElement) (Evaluator, error) {
 	return e, nil
 }
 
-// internalNewEvaluator create and evaluation and returns the left overs.
-func internalNewEvaluator(ce []ConsumedElement) (Evaluator, []ConsumedElement, error) {
+// internalNewEvaluator create and evaluation and returns the left overs. Filter
+// evaluators use typed comparisons that also accept constant and parameter
+// operands.
+func internalNewEvaluator(ce []ConsumedElement, filter bool, ps map[string]*table.Cell) (Evaluator, []ConsumedElement, error) {
 	if len(ce) == 0 {
 		return nil, nil, errors.New("cannot create an evaluator from an empty sequence of tokens")
 	}
@@ -262,7 +697,7 @@ func internalNewEvaluator(ce []ConsumedElement) (Evaluator, []ConsumedElement, e
 
 	// Not token
 	if tkn.Type == lexer.ItemNot {
-		tailEval, tailCEs, err := internalNewEvaluator(tail)
+		tailEval, tailCEs, err := internalNewEvaluator(tail, filter, ps)
 		if err != nil {
 			return nil, tailCEs, err
 		}
@@ -273,8 +708,9 @@ func internalNewEvaluator(ce []ConsumedElement) (Evaluator, []ConsumedElement, e
 		return e, tailCEs, nil
 	}
 
-	// Binding token
-	if tkn.Type == lexer.ItemBinding {
+	// Comparison operand token
+	isConstant := tkn.Type == lexer.ItemNode || tkn.Type == lexer.ItemLiteral || tkn.Type == lexer.ItemPredicate || tkn.Type == lexer.ItemParameter
+	if tkn.Type == lexer.ItemBinding || (filter && isConstant) {
 		if len(tail) < 2 {
 			return nil, nil, fmt.Errorf("cannot create a binary evaluation operand for %v", ce)
 		}
@@ -287,26 +723,94 @@ func internalNewEvaluator(ce []ConsumedElement) (Evaluator, []ConsumedElement, e
 			op = LT
 		case lexer.ItemGT:
 			op = GT
+		case lexer.ItemNEQ:
+			op = NEQ
+		case lexer.ItemLEQ:
+			op = LEQ
+		case lexer.ItemGEQ:
+			op = GEQ
+		case lexer.ItemMatch:
+			op = MATCH
 		default:
 			return nil, nil, fmt.Errorf("cannot create a binary evaluation operand for %v", opTkn)
 		}
+		var res []ConsumedElement
+		if len(tail) > 2 {
+			res = tail[2:]
+		}
+		if filter && op == MATCH {
+			e, err := newMatchExpression(tkn, bndTkn)
+			if err != nil {
+				return nil, nil, err
+			}
+			return e, res, nil
+		}
+		if filter {
+			l, err := newOperand(tkn, ps)
+			if err != nil {
+				return nil, nil, err
+			}
+			r, err := newOperand(bndTkn, ps)
+			if err != nil {
+				return nil, nil, err
+			}
+			e, err := newComparisonExpression(op, l, r)
+			if err != nil {
+				return nil, nil, err
+			}
+			return e, res, nil
+		}
 		if bndTkn.Type == lexer.ItemBinding {
 			e, err := NewEvaluationExpression(op, tkn.Text, bndTkn.Text)
 			if err != nil {
 				return nil, nil, err
 			}
-			var res []ConsumedElement
-			if len(tail) > 2 {
-				res = tail[2:]
-			}
 			return e, res, nil
 		}
 		return nil, nil, fmt.Errorf("cannot build a binary evaluation operand with right operant %v", bndTkn)
 	}
 
+	// Fuzzy matching function token
+	if tkn.Type == lexer.ItemFuzzy && filter {
+		if len(tail) < 7 {
+			return nil, nil, fmt.Errorf("incomplete fuzzy matching expression %v", ce)
+		}
+		if tail[0].Token().Type != lexer.ItemLPar || tail[2].Token().Type != lexer.ItemComma ||
+			tail[4].Token().Type != lexer.ItemComma || tail[6].Token().Type != lexer.ItemRPar {
+			return nil, nil, fmt.Errorf("fuzzy matching expects fuzzy(?binding, target, distance); found %v instead", ce)
+		}
+		e, err := newFuzzyExpression(tail[1].Token(), tail[3].Token(), tail[5].Token())
+		if err != nil {
+			return nil, nil, err
+		}
+		return e, tail[7:], nil
+	}
+
+	// Prefix matching function token
+	if tkn.Type == lexer.ItemStartsWith && filter {
+		if len(tail) < 5 || tail[0].Token().Type != lexer.ItemLPar {
+			return nil, nil, fmt.Errorf("incomplete prefix matching expression %v", ce)
+		}
+		bIdx, rest, id := 1, tail[2:], tail[1].Token().Type == lexer.ItemID
+		if id {
+			if len(tail) < 8 || tail[2].Token().Type != lexer.ItemLPar || tail[4].Token().Type != lexer.ItemRPar {
+				return nil, nil, fmt.Errorf("prefix matching expects starts_with(id(?binding), prefix); found %v instead", ce)
+			}
+			bIdx, rest = 3, tail[5:]
+		}
+		if rest[0].Token().Type != lexer.ItemComma || rest[2].Token().Type != lexer.ItemRPar {
+			return nil, nil, fmt.Errorf("prefix matching expects starts_with(?binding, prefix); found %v instead", ce)
+		}
+		e, err := newStartsWithExpression(tail[bIdx].Token(), rest[1].Token(), id)
+		if err != nil {
+			return nil, nil, err
+		}
+		return e, rest[3:], nil
+	}
+
 	// LPar Token
 	if tkn.Type == lexer.ItemLPar {
-		tailEval, ce, err := internalNewEvaluator(tail)
+		tailEval, ce, err := internalNewEvaluator(tail, filter, ps)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -329,7 +833,7 @@ func internalNewEvaluator(ce []ConsumedElement) (Evaluator, []ConsumedElement, e
 			default:
 				return nil, nil, fmt.Errorf("cannot create a binary boolean evaluation operand for %v", opTkn)
 			}
-			rTailEval, ceResTail, err := internalNewEvaluator(tail[1:])
+			rTailEval, ceResTail, err := internalNewEvaluator(tail[1:], filter, ps)
 			if err != nil {
 				return nil, nil, err
 			}
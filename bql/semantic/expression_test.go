@@ -15,10 +15,14 @@
 package semantic
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/google/badwolf/bql/lexer"
 	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
 )
 
 func TestEvaluationNode(t *testing.T) {
@@ -402,3 +406,319 @@ func TestNewEvaluator(t *testing.T) {
 		}
 	}
 }
+
+// tokenize returns the consumed elements for the tokens of the provided
+// expression.
+func tokenize(t *testing.T, expr string) []ConsumedElement {
+	var ces []ConsumedElement
+	for tkn := range lexer.New(expr, 0) {
+		if tkn.Type == lexer.ItemError {
+			t.Fatalf("lexer failed to tokenize %q with error %s", expr, tkn.ErrorMessage)
+		}
+		if tkn.Type != lexer.ItemEOF {
+			tkn := tkn
+			ces = append(ces, NewConsumedToken(&tkn))
+		}
+	}
+	return ces
+}
+
+func TestNewFilterEvaluator(t *testing.T) {
+	mustLiteral := func(tp literal.Type, v interface{}) *table.Cell {
+		l, err := literal.DefaultBuilder().Build(tp, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &table.Cell{L: l}
+	}
+	mustPredicate := func(s string) *table.Cell {
+		p, err := predicate.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &table.Cell{P: p}
+	}
+	mustNode := func(s string) *table.Cell {
+		n, err := node.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &table.Cell{N: n}
+	}
+	ta, err := mustPredicate(`"foo"@[2016-02-01T00:00:00-08:00]`).P.TimeAnchor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := table.Row{
+		"?int":   mustLiteral(literal.Int64, int64(100)),
+		"?float": mustLiteral(literal.Float64, float64(20.5)),
+		"?text":  mustLiteral(literal.Text, "book"),
+		"?pred":  mustPredicate(`"bought"@[2016-01-01T00:00:00-08:00]`),
+		"?time":  &table.Cell{T: ta},
+		"?node":  mustNode("/u<joe>"),
+	}
+	testTable := []struct {
+		expr string
+		err  bool
+		want bool
+	}{
+		{expr: `?int > "10"^^type:int64`, want: true},
+		{expr: `?int >= "100"^^type:int64`, want: true},
+		{expr: `?int < "100"^^type:int64`, want: false},
+		{expr: `?int <= "100"^^type:int64`, want: true},
+		{expr: `?int = "100"^^type:int64`, want: true},
+		{expr: `?int != "100"^^type:int64`, want: false},
+		{expr: `?float < ?int`, want: true},
+		{expr: `"20.5"^^type:float64 = ?float`, want: true},
+		{expr: `?text = "book"^^type:text`, want: true},
+		{expr: `?text > "pen"^^type:text`, want: false},
+		{expr: `?pred < ?time`, want: true},
+		{expr: `?time > "bought"@[2016-03-01T00:00:00-08:00]`, want: false},
+		{expr: `?time = "2016-02-01T08:00:00Z"^^type:text`, want: true},
+		{expr: `?time > "2016-02-01T7:59:59.999999999Z"^^type:text`, want: true},
+		{expr: `"2016-01-15T00:00:00-08:00"^^type:text >= ?pred`, want: true},
+		{expr: `?time < "2016-01-31T23:00:00-08:00"^^type:text`, want: false},
+		{expr: `?node = ?node`, want: true},
+		{expr: `?node != ?node`, want: false},
+		{expr: `?node = /u<mary>`, want: false},
+		{expr: `(?int > "10"^^type:int64) and (?text = "book"^^type:text)`, want: true},
+		{expr: `not (?int > "10"^^type:int64)`, want: false},
+		{expr: `?text =~ "^bo+k$"^^type:text`, want: true},
+		{expr: `?text =~ "oo"^^type:text`, want: true},
+		{expr: `?text =~ "^B"^^type:text`, want: false},
+		{expr: `?text =~ "(?i)^B"^^type:text`, want: true},
+		{expr: `(?text =~ "^b"^^type:text) and (?int > "10"^^type:int64)`, want: true},
+		// Incompatible types cannot be compared.
+		{expr: `?int > "10"^^type:text`, err: true},
+		{expr: `?text = ?time`, err: true},
+		{expr: `?time > "yesterday"^^type:text`, err: true},
+		{expr: `?time > "2016-02-01"^^type:text`, err: true},
+		{expr: `?node < ?node`, err: true},
+		{expr: `?unknown = ?int`, err: true},
+		// Only text literals can be matched against regular expressions.
+		{expr: `?int =~ "100"^^type:text`, err: true},
+		{expr: `?node =~ "joe"^^type:text`, err: true},
+	}
+	for _, entry := range testTable {
+		eval, err := NewFilterEvaluator(tokenize(t, entry.expr))
+		if err != nil {
+			t.Fatalf("NewFilterEvaluator should have never failed to process %q with error %v", entry.expr, err)
+		}
+		got, err := eval.Evaluate(r)
+		if entry.err {
+			if err == nil {
+				t.Errorf("Evaluate should have failed to evaluate %q; got %v instead", entry.expr, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Evaluate failed to evaluate %q with error %v", entry.expr, err)
+		}
+		if got != entry.want {
+			t.Errorf("Evaluate returned the wrong value for %q; got %v, want %v", entry.expr, got, entry.want)
+		}
+	}
+}
+
+func TestFilterParameters(t *testing.T) {
+	joe, err := node.Parse("/u<joe>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mary, err := node.Parse("/u<mary>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &FilterClause{expression: tokenize(t, `(?o = @name) or (@name = /u<mary>)`)}
+	if got, want := f.Parameters(), []string{"@name"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FilterClause.Parameters returned %v; want %v", got, want)
+	}
+	eval, err := NewFilterEvaluator(f.expression)
+	if err != nil {
+		t.Fatalf("NewFilterEvaluator failed to process %v with error %v", f.expression, err)
+	}
+	r := table.Row{"?o": &table.Cell{N: joe}}
+	if got, err := eval.Evaluate(r); err == nil {
+		t.Errorf("Evaluate should have failed to evaluate an unbound parameter; got %v instead", got)
+	}
+	for _, entry := range []struct {
+		v    *node.Node
+		want bool
+	}{
+		{v: joe, want: true},
+		{v: mary, want: true},
+	} {
+		eval, err := f.BindParameters(map[string]*table.Cell{"@name": {N: entry.v}})
+		if err != nil {
+			t.Fatalf("FilterClause.BindParameters failed with error %v", err)
+		}
+		if got, err := eval.Evaluate(r); err != nil || got != entry.want {
+			t.Errorf("Evaluate with @name=%s returned %v, %v; want %v, nil", entry.v, got, err, entry.want)
+		}
+	}
+	f = &FilterClause{expression: tokenize(t, `?o = @name`)}
+	eval, err = f.BindParameters(map[string]*table.Cell{"@name": {N: mary}})
+	if err != nil {
+		t.Fatalf("FilterClause.BindParameters failed with error %v", err)
+	}
+	if got, err := eval.Evaluate(r); err != nil || got {
+		t.Errorf("Evaluate with @name=%s returned %v, %v; want false, nil", mary, got, err)
+	}
+	if _, err := f.BindParameters(nil); err == nil {
+		t.Errorf("FilterClause.BindParameters should have failed without a value for @name")
+	}
+}
+
+func TestFuzzyFilterEvaluator(t *testing.T) {
+	text, err := literal.DefaultBuilder().Build(literal.Text, "Mary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	num, err := literal.DefaultBuilder().Build(literal.Int64, int64(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := node.Parse("/u<mary>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := table.Row{
+		"?name": &table.Cell{L: text},
+		"?num":  &table.Cell{L: num},
+		"?node": &table.Cell{N: n},
+	}
+	testTable := []struct {
+		expr string
+		err  bool
+		want bool
+	}{
+		{expr: `fuzzy(?name, "Mary"^^type:text, "0"^^type:int64)`, want: true},
+		{expr: `fuzzy(?name, "Marry"^^type:text, "1"^^type:int64)`, want: true},
+		{expr: `fuzzy(?name, "Mar"^^type:text, "1"^^type:int64)`, want: true},
+		{expr: `fuzzy(?name, "Mbry"^^type:text, "1"^^type:int64)`, want: true},
+		{expr: `fuzzy(?name, "Marty"^^type:text, "0"^^type:int64)`, want: false},
+		{expr: `fuzzy(?name, "Moira"^^type:text, "2"^^type:int64)`, want: false},
+		{expr: `fuzzy(?name, "Moira"^^type:text, "3"^^type:int64)`, want: true},
+		{expr: `fuzzy(?name, "Mary Ann"^^type:text, "3"^^type:int64)`, want: false},
+		{expr: `not (fuzzy(?name, "Marry"^^type:text, "1"^^type:int64))`, want: false},
+		{expr: `(fuzzy(?name, "Marry"^^type:text, "1"^^type:int64)) and (?num = "1"^^type:int64)`, want: true},
+		// Only text literals can be fuzzy matched.
+		{expr: `fuzzy(?num, "1"^^type:text, "1"^^type:int64)`, err: true},
+		{expr: `fuzzy(?node, "Mary"^^type:text, "1"^^type:int64)`, err: true},
+		{expr: `fuzzy(?unknown, "Mary"^^type:text, "1"^^type:int64)`, err: true},
+	}
+	for _, entry := range testTable {
+		eval, err := NewFilterEvaluator(tokenize(t, entry.expr))
+		if err != nil {
+			t.Fatalf("NewFilterEvaluator should have never failed to process %q with error %v", entry.expr, err)
+		}
+		got, err := eval.Evaluate(r)
+		if entry.err {
+			if err == nil {
+				t.Errorf("Evaluate should have failed to evaluate %q; got %v instead", entry.expr, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Evaluate failed to evaluate %q with error %v", entry.expr, err)
+		}
+		if got != entry.want {
+			t.Errorf("Evaluate returned the wrong value for %q; got %v, want %v", entry.expr, got, entry.want)
+		}
+	}
+
+	for _, expr := range []string{
+		`fuzzy(?name, "1"^^type:int64, "1"^^type:int64)`,
+		`fuzzy(?name, "Mary"^^type:text, "1"^^type:text)`,
+		`fuzzy(?name, "Mary"^^type:text, "-1"^^type:int64)`,
+		`fuzzy("Mary"^^type:text, "Mary"^^type:text, "1"^^type:int64)`,
+		`fuzzy(?name, "Mary"^^type:text)`,
+	} {
+		if _, err := NewFilterEvaluator(tokenize(t, expr)); err == nil {
+			t.Errorf("NewFilterEvaluator should have failed to process %q", expr)
+		}
+	}
+}
+
+func TestStartsWithFilterEvaluator(t *testing.T) {
+	text, err := literal.DefaultBuilder().Build(literal.Text, "Mary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := node.Parse("/u<mary>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := predicate.Parse(`"bought"@[2016-01-01T00:00:00-08:00]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := table.Row{
+		"?name": &table.Cell{L: text},
+		"?node": &table.Cell{N: n},
+		"?pred": &table.Cell{P: p},
+	}
+	testTable := []struct {
+		expr string
+		err  bool
+		want bool
+	}{
+		{expr: `starts_with(?name, "Ma"^^type:text)`, want: true},
+		{expr: `starts_with(?name, ""^^type:text)`, want: true},
+		{expr: `starts_with(?name, "ma"^^type:text)`, want: false},
+		{expr: `starts_with(id(?pred), "bou"^^type:text)`, want: true},
+		{expr: `starts_with(id(?pred), "parent"^^type:text)`, want: false},
+		{expr: `starts_with(id(?node), "mar"^^type:text)`, want: true},
+		{expr: `not (starts_with(id(?pred), "bought"^^type:text))`, want: false},
+		{expr: `(starts_with(id(?pred), "bought"^^type:text)) and (starts_with(?name, "M"^^type:text))`, want: true},
+		// Only text literals can be matched directly, and only nodes and
+		// predicates have IDs.
+		{expr: `starts_with(?pred, "bought"^^type:text)`, err: true},
+		{expr: `starts_with(id(?name), "Ma"^^type:text)`, err: true},
+		{expr: `starts_with(?unknown, "Ma"^^type:text)`, err: true},
+	}
+	for _, entry := range testTable {
+		eval, err := NewFilterEvaluator(tokenize(t, entry.expr))
+		if err != nil {
+			t.Fatalf("NewFilterEvaluator should have never failed to process %q with error %v", entry.expr, err)
+		}
+		got, err := eval.Evaluate(r)
+		if entry.err {
+			if err == nil {
+				t.Errorf("Evaluate should have failed to evaluate %q; got %v instead", entry.expr, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Evaluate failed to evaluate %q with error %v", entry.expr, err)
+		}
+		if got != entry.want {
+			t.Errorf("Evaluate returned the wrong value for %q; got %v, want %v", entry.expr, got, entry.want)
+		}
+	}
+
+	for _, expr := range []string{
+		`starts_with(?name, "1"^^type:int64)`,
+		`starts_with(?name, ?other)`,
+		`starts_with("Mary"^^type:text, "Ma"^^type:text)`,
+		`starts_with(id(?name, "Ma"^^type:text)`,
+		`starts_with(?name)`,
+	} {
+		if _, err := NewFilterEvaluator(tokenize(t, expr)); err == nil {
+			t.Errorf("NewFilterEvaluator should have failed to process %q", expr)
+		}
+	}
+}
+
+func TestNewFilterEvaluatorRejectsInvalidMatches(t *testing.T) {
+	for _, expr := range []string{
+		`?text =~ "("^^type:text`,
+		`?text =~ "1"^^type:int64`,
+		`?text =~ ?other`,
+		`"book"^^type:text =~ "^b"^^type:text`,
+	} {
+		if _, err := NewFilterEvaluator(tokenize(t, expr)); err == nil {
+			t.Errorf("NewFilterEvaluator should have failed to process %q", expr)
+		}
+	}
+}
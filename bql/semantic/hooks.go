@@ -17,6 +17,7 @@ package semantic
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -42,6 +43,10 @@ var (
 
 	// boundRegexp contains the regular expression for not fully defined predicate bounds.
 	boundRegexp *regexp.Regexp = regexp.MustCompile(`^"(.+)"@\["?([^\]"]*)"?,"?([^\]"]*)"?\]$`)
+
+	// exactBoundRegexp contains the regular expression for predicate bounds
+	// matching an exact time anchor.
+	exactBoundRegexp *regexp.Regexp = regexp.MustCompile(`^"(.+)"@\[="?([^\]",]*)"?\]$`)
 )
 
 // DataAccumulatorHook returns the singleton for data accumulation.
@@ -54,6 +59,24 @@ func GraphAccumulatorHook() ElementHook {
 	return graphAccumulator()
 }
 
+// ImportDataHook returns the singleton for collecting the serialized triples
+// of an import statement.
+func ImportDataHook() ElementHook {
+	return importData()
+}
+
+// CloneSourceHook returns the singleton for collecting the graph copied by a
+// create statement.
+func CloneSourceHook() ElementHook {
+	return cloneSource()
+}
+
+// OutputGraphAccumulatorHook returns the singleton for accumulating the graphs
+// where the constructed triples get stored.
+func OutputGraphAccumulatorHook() ElementHook {
+	return graphNamesAccumulator((*Statement).AddOutputGraph)
+}
+
 // WhereInitWorkingClauseHook returns the singleton for graph accumulation.
 func WhereInitWorkingClauseHook() ClauseHook {
 	return whereInitWorkingClause()
@@ -64,6 +87,12 @@ func WhereNextWorkingClauseHook() ClauseHook {
 	return whereNextWorkingClause()
 }
 
+// WhereNegatedWorkingClauseHook returns the singleton for marking the working
+// graph clause as negated.
+func WhereNegatedWorkingClauseHook() ClauseHook {
+	return whereNegatedWorkingClause()
+}
+
 // WhereSubjectClauseHook returns the singleton for working clause hooks that
 // populates the subject.
 func WhereSubjectClauseHook() ElementHook {
@@ -82,12 +111,42 @@ func WhereObjectClauseHook() ElementHook {
 	return whereObjectClause()
 }
 
+// WhereFilterClauseHook returns the singleton for working filter hooks that
+// delimit the filter clauses and build their evaluators.
+func WhereFilterClauseHook() ElementHook {
+	return whereFilterClause()
+}
+
+// WhereFilterExpressionHook returns the singleton for collecting the tokens
+// that form a filter expression.
+func WhereFilterExpressionHook() ElementHook {
+	return whereFilterExpression()
+}
+
+// WhereUnionClauseHook returns the singleton for tracking the branches of
+// UNION graph patterns.
+func WhereUnionClauseHook() ElementHook {
+	return whereUnionClause()
+}
+
+// WhereNotExistsClauseHook returns the singleton for tracking the groups of
+// NOT EXISTS graph patterns.
+func WhereNotExistsClauseHook() ElementHook {
+	return whereNotExistsClause()
+}
+
 // VarAccumulatorHook returns the singleton for accumulating variable
 // projections.
 func VarAccumulatorHook() ElementHook {
 	return varAccumulator()
 }
 
+// WindowAccumulatorHook returns the singleton for accumulating the partition
+// and order bindings of window aggregations.
+func WindowAccumulatorHook() ElementHook {
+	return windowAccumulator()
+}
+
 // VarBindingsGraphChecker returns the singleton for checking a query statement
 // for valid bindings in the select variables.
 func VarBindingsGraphChecker() ClauseHook {
@@ -135,11 +194,22 @@ func LimitCollection() ElementHook {
 	return limitCollection()
 }
 
+// OffsetCollection returns the offset collection hook.
+func OffsetCollection() ElementHook {
+	return offsetCollection()
+}
+
 // CollectGlobalBounds returns the global temporary bounds hook.
 func CollectGlobalBounds() ElementHook {
 	return collectGlobalBounds()
 }
 
+// AsOfHook returns the hook that collects the time of the view of the queried
+// graphs.
+func AsOfHook() ElementHook {
+	return asOf()
+}
+
 // InitWorkingConstructClauseHook returns the singleton for clause accumulation within the construct statement.
 func InitWorkingConstructClauseHook() ClauseHook {
 	return InitWorkingConstructClause()
@@ -186,6 +256,47 @@ func ReificationObjectClauseHook() ElementHook {
 	return reificationObjectClause()
 }
 
+// SchemaQueryClauseHook returns the singleton for marking the statement as a
+// schema query.
+func SchemaQueryClauseHook() ClauseHook {
+	return schemaQueryClause()
+}
+
+// FrequenciesQueryHook returns the singleton for setting up frequencies
+// queries.
+func FrequenciesQueryHook() ElementHook {
+	return frequenciesQuery()
+}
+
+// LatestQueryHook returns the singleton for setting up latest queries.
+func LatestQueryHook() ElementHook {
+	return latestQuery()
+}
+
+// WeightedSampleQueryHook returns the singleton for setting up weighted sample
+// queries.
+func WeightedSampleQueryHook() ElementHook {
+	return weightedSampleQuery()
+}
+
+// SelectDistinctHook returns the singleton for marking a query as only
+// returning distinct rows.
+func SelectDistinctHook() ElementHook {
+	return selectDistinct()
+}
+
+// IgnoreCaseHook returns the singleton for marking a query as matching text
+// literals regardless of their case.
+func IgnoreCaseHook() ElementHook {
+	return ignoreCase()
+}
+
+// SchemaAccumulatorHook returns the singleton for collecting the graphs and
+// predicates of a schema query.
+func SchemaAccumulatorHook() ElementHook {
+	return schemaAccumulator()
+}
+
 // TypeBindingClauseHook returns a ClauseHook that sets the binding type.
 func TypeBindingClauseHook(t StatementType) ClauseHook {
 	var f ClauseHook
@@ -204,6 +315,7 @@ func dataAccumulator(b literal.Builder) ElementHook {
 		s    *node.Node
 		p    *predicate.Predicate
 		o    *triple.Object
+		pNow bool
 	)
 
 	hook = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
@@ -229,25 +341,34 @@ func dataAccumulator(b literal.Builder) ElementHook {
 			if tkn.Type != lexer.ItemPredicate {
 				return nil, fmt.Errorf("hook.DataAccumulator requires a predicate to create a predicate, got %v instead", tkn)
 			}
-			tmp, err := predicate.Parse(tkn.Text)
+			text, now := trimNowAnchor(tkn.Text)
+			tmp, err := predicate.Parse(text)
 			if err != nil {
 				return nil, err
 			}
-			p = tmp
+			p, pNow = tmp, now
 			return hook, nil
 		}
 		if o == nil {
-			tmp, err := triple.ParseObject(tkn.Text, b)
+			text, oNow := tkn.Text, false
+			if tkn.Type == lexer.ItemPredicate {
+				text, oNow = trimNowAnchor(text)
+			}
+			tmp, err := triple.ParseObject(text, b)
 			if err != nil {
 				return nil, err
 			}
 			o = tmp
-			trpl, err := triple.New(s, p, o)
-			if err != nil {
-				return nil, err
+			if pNow || oNow {
+				st.addNowData(&NowTriple{s: s, p: p, pNow: pNow, o: o, oNow: oNow})
+			} else {
+				trpl, err := triple.New(s, p, o)
+				if err != nil {
+					return nil, err
+				}
+				st.AddData(trpl)
 			}
-			st.AddData(trpl)
-			s, p, o = nil, nil, nil
+			s, p, o, pNow = nil, nil, nil, false
 			return hook, nil
 		}
 		return nil, fmt.Errorf("hook.DataAccumulator has failed to flush the triple %s, %s, %s", s, p, o)
@@ -255,9 +376,71 @@ func dataAccumulator(b literal.Builder) ElementHook {
 	return hook
 }
 
+// nowAnchor is the time anchor of the DATA predicates anchored at the time the
+// statement is executed.
+const nowAnchor = "@[NOW()]"
+
+// trimNowAnchor returns the provided predicate as an immutable one and true if
+// it is anchored at NOW(). Otherwise it returns the predicate unchanged.
+func trimNowAnchor(text string) (string, bool) {
+	if len(text) < len(nowAnchor) || !strings.EqualFold(text[len(text)-len(nowAnchor):], nowAnchor) {
+		return text, false
+	}
+	return text[:len(text)-len(nowAnchor)] + "@[]", true
+}
+
+// cloneSource returns an element hook that collects the graph copied into the
+// graphs created by a create statement.
+func cloneSource() ElementHook {
+	var hook ElementHook
+	hook = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return hook, nil
+		}
+		tkn := ce.Token()
+		if tkn.Type != lexer.ItemBinding {
+			return hook, nil
+		}
+		if st.CloneSource() != "" {
+			return nil, fmt.Errorf("hook.CloneSource: the source graph has already been set to %s; found %s", st.CloneSource(), tkn.Text)
+		}
+		st.SetCloneSource(tkn.Text)
+		return hook, nil
+	}
+	return hook
+}
+
+// importData returns an element hook that unquotes the serialized triples of
+// an import statement. The quoted payload may span several lines.
+func importData() ElementHook {
+	var hook ElementHook
+	hook = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return hook, nil
+		}
+		tkn := ce.Token()
+		if tkn.Type != lexer.ItemString {
+			return hook, nil
+		}
+		d, err := strconv.Unquote(strings.Replace(tkn.Text, "\n", `\n`, -1))
+		if err != nil {
+			return nil, fmt.Errorf("hook.ImportData failed to unquote the imported triples %s with error %v", tkn.Text, err)
+		}
+		st.SetImportData(d)
+		return hook, nil
+	}
+	return hook
+}
+
 // graphAccumulator returns an element hook that keeps track of the graphs
 // listed in a statement.
 func graphAccumulator() ElementHook {
+	return graphNamesAccumulator((*Statement).AddGraph)
+}
+
+// graphNamesAccumulator returns an element hook that adds the graph bindings
+// found to the statement using the provided function.
+func graphNamesAccumulator(add func(*Statement, string)) ElementHook {
 	var hook ElementHook
 	hook = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
 		if ce.IsSymbol() {
@@ -268,7 +451,7 @@ func graphAccumulator() ElementHook {
 		case lexer.ItemComma:
 			return hook, nil
 		case lexer.ItemBinding:
-			st.AddGraph(strings.TrimSpace(tkn.Text))
+			add(st, strings.TrimSpace(tkn.Text))
 			return hook, nil
 		default:
 			return nil, fmt.Errorf("hook.GrapAccumulator requires a binding to refer to a graph, got %v instead", tkn)
@@ -277,6 +460,202 @@ func graphAccumulator() ElementHook {
 	return hook
 }
 
+// schemaQueryClause returns a clause hook that marks the statement as a schema
+// query.
+func schemaQueryClause() ClauseHook {
+	var f ClauseHook
+	f = func(s *Statement, _ Symbol) (ClauseHook, error) {
+		s.SetSchemaQuery()
+		return f, nil
+	}
+	return f
+}
+
+// frequenciesQuery returns an element hook that sets up the statement to count
+// the values of the binding listed on a frequencies query.
+func frequenciesQuery() ElementHook {
+	var hook ElementHook
+	hook = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return hook, nil
+		}
+		tkn := ce.Token()
+		if tkn.Type != lexer.ItemBinding {
+			return hook, nil
+		}
+		if tkn.Text == FrequencyCountBinding {
+			return nil, fmt.Errorf("hook.FrequenciesQuery cannot count binding %s since it is used to return the counts", tkn.Text)
+		}
+		st.SetFrequenciesQuery(tkn.Text)
+		return hook, nil
+	}
+	return hook
+}
+
+// latestQuery returns an element hook that sets up the statement to return
+// the newest triples of each subject for the number of triples, the subject
+// binding, and the predicate listed on a latest query.
+func latestQuery() ElementHook {
+	var (
+		hook ElementHook
+		n    int64
+		b    string
+	)
+	hook = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return hook, nil
+		}
+		tkn := ce.Token()
+		switch tkn.Type {
+		case lexer.ItemLiteral:
+			l, err := literal.DefaultBuilder().Parse(tkn.Text)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the number of triples per subject %q with error %v", tkn.Text, err)
+			}
+			if l.Type() != literal.Int64 {
+				return nil, fmt.Errorf("hook.LatestQuery requires an int64 number of triples per subject; found %s instead", l)
+			}
+			if n, err = l.Int64(); err != nil {
+				return nil, err
+			}
+			if n <= 0 {
+				return nil, fmt.Errorf("hook.LatestQuery requires a positive number of triples per subject; found %d instead", n)
+			}
+		case lexer.ItemBinding:
+			if tkn.Text == LatestPredicateBinding || tkn.Text == LatestObjectBinding {
+				return nil, fmt.Errorf("hook.LatestQuery cannot return the subjects in binding %s since it is used to return the predicates and objects", tkn.Text)
+			}
+			b = tkn.Text
+		case lexer.ItemPredicate:
+			p, err := predicate.Parse(tkn.Text)
+			if err != nil {
+				return nil, err
+			}
+			if p.Type() != predicate.Immutable {
+				return nil, fmt.Errorf("hook.LatestQuery requires a predicate without time anchor like \"%s\"@[]; found %s instead", p.ID(), p)
+			}
+			st.SetLatestQuery(b, p.ID(), n)
+		}
+		return hook, nil
+	}
+	return hook
+}
+
+// weightedSampleQuery returns an element hook that sets up the statement to
+// sample rows given the sampled binding, the weight binding, the number of
+// rows, and the optional seed listed on a weighted sample query.
+func weightedSampleQuery() ElementHook {
+	var (
+		hook   ElementHook
+		bs, ls []string
+	)
+	hook = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return hook, nil
+		}
+		tkn := ce.Token()
+		switch tkn.Type {
+		case lexer.ItemWeightedSample:
+			bs, ls = nil, nil
+		case lexer.ItemBinding:
+			bs = append(bs, tkn.Text)
+		case lexer.ItemLiteral:
+			ls = append(ls, tkn.Text)
+		case lexer.ItemRPar:
+			if len(bs) != 2 || bs[0] == bs[1] {
+				return nil, fmt.Errorf("hook.WeightedSampleQuery requires two different bindings for the sampled values and their weights; found %v instead", bs)
+			}
+			var vs []int64
+			for _, t := range ls {
+				l, err := literal.DefaultBuilder().Parse(t)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse weighted sample argument %q with error %v", t, err)
+				}
+				if l.Type() != literal.Int64 {
+					return nil, fmt.Errorf("hook.WeightedSampleQuery requires int64 arguments; found %s instead", l)
+				}
+				v, err := l.Int64()
+				if err != nil {
+					return nil, err
+				}
+				vs = append(vs, v)
+			}
+			if vs[0] <= 0 {
+				return nil, fmt.Errorf("hook.WeightedSampleQuery requires a positive number of rows to sample; found %d instead", vs[0])
+			}
+			st.SetWeightedSample(bs[0], bs[1], vs[0])
+			if len(vs) > 1 {
+				st.SetWeightedSampleSeed(vs[1])
+			}
+		}
+		return hook, nil
+	}
+	return hook
+}
+
+// selectDistinct marks the statement as distinct when the distinct modifier
+// follows the select keyword.
+func selectDistinct() ElementHook {
+	var f func(st *Statement, ce ConsumedElement) (ElementHook, error)
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		if ce.token.Type != lexer.ItemDistinct {
+			return nil, fmt.Errorf("select modifier requires distinct; found %v instead", ce.token)
+		}
+		st.SetDistinct()
+		return f, nil
+	}
+	return f
+}
+
+// ignoreCase marks the statement as matching the text literals of its graph
+// pattern regardless of their case.
+func ignoreCase() ElementHook {
+	var f func(st *Statement, ce ConsumedElement) (ElementHook, error)
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		if ce.token.Type != lexer.ItemIgnoreCase {
+			return nil, fmt.Errorf("text matching modifier requires ignore_case; found %v instead", ce.token)
+		}
+		st.SetIgnoreCase()
+		return f, nil
+	}
+	return f
+}
+
+// schemaAccumulator returns an element hook that keeps track of the graphs
+// and the predicates listed on a schema query.
+func schemaAccumulator() ElementHook {
+	var hook ElementHook
+	hook = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return hook, nil
+		}
+		tkn := ce.Token()
+		switch tkn.Type {
+		case lexer.ItemComma:
+			return hook, nil
+		case lexer.ItemBinding:
+			st.AddGraph(strings.TrimSpace(tkn.Text))
+			return hook, nil
+		case lexer.ItemPredicate:
+			p, err := predicate.Parse(tkn.Text)
+			if err != nil {
+				return nil, fmt.Errorf("hook.SchemaAccumulator failed to parse predicate %q with error %v", tkn.Text, err)
+			}
+			st.AddSchemaPredicate(p.ID())
+			return hook, nil
+		default:
+			return nil, fmt.Errorf("hook.SchemaAccumulator requires a binding or a predicate, got %v instead", tkn)
+		}
+	}
+	return hook
+}
+
 // whereNextWorkingClause returns a clause hook to close the current graphs
 // clause and starts a new working one.
 func whereNextWorkingClause() ClauseHook {
@@ -298,6 +677,16 @@ func whereInitWorkingClause() ClauseHook {
 	return f
 }
 
+// whereNegatedWorkingClause marks the current working graph clause as negated.
+func whereNegatedWorkingClause() ClauseHook {
+	var f ClauseHook
+	f = func(s *Statement, _ Symbol) (ClauseHook, error) {
+		s.WorkingClause().Negated = true
+		return f, nil
+	}
+	return f
+}
+
 // whereSubjectClause returns an element hook that updates the subject
 // modifiers on the working graph clause.
 func whereSubjectClause() ElementHook {
@@ -323,6 +712,17 @@ func whereSubjectClause() ElementHook {
 			c.S = n
 			lastNopToken = nil
 			return f, nil
+		case lexer.ItemNodeType:
+			if c.SBinding == "" || c.SNodeType != nil {
+				return nil, fmt.Errorf("node type constraint %s requires a subject binding without constraints; current %v", tkn.Text, c)
+			}
+			nt, err := ToNodeType(ce)
+			if err != nil {
+				return nil, err
+			}
+			c.SNodeType = nt
+			lastNopToken = nil
+			return f, nil
 		case lexer.ItemBinding:
 			if lastNopToken == nil {
 				if c.SBinding != "" {
@@ -402,11 +802,20 @@ func processPredicateBound(ce ConsumedElement) (string, string, string, *time.Ti
 		pUpperBound      *time.Time
 	)
 	raw := ce.Token().Text
-	cmps := boundRegexp.FindAllStringSubmatch(raw, 2)
-	if len(cmps) != 1 || (len(cmps) == 1 && len(cmps[0]) != 4) {
-		return "", "", "", nil, nil, false, fmt.Errorf("failed to extract partially defined predicate bound %q, got %v instead", raw, cmps)
+	var id, tl, tu string
+	if ecmps := exactBoundRegexp.FindStringSubmatch(raw); ecmps != nil {
+		// Exact anchors are bounds whose lower and upper bounds are the same.
+		id, tl, tu = ecmps[1], ecmps[2], ecmps[2]
+		if strings.TrimSpace(tl) == "" {
+			return "", "", "", nil, nil, false, fmt.Errorf("missing exact time anchor in predicate bound %s", raw)
+		}
+	} else {
+		cmps := boundRegexp.FindAllStringSubmatch(raw, 2)
+		if len(cmps) != 1 || (len(cmps) == 1 && len(cmps[0]) != 4) {
+			return "", "", "", nil, nil, false, fmt.Errorf("failed to extract partially defined predicate bound %q, got %v instead", raw, cmps)
+		}
+		id, tl, tu = cmps[0][1], cmps[0][2], cmps[0][3]
 	}
-	id, tl, tu := cmps[0][1], cmps[0][2], cmps[0][3]
 	pID = id
 	// Lower bound processing.
 	if strings.Index(tl, "?") != -1 {
@@ -443,6 +852,32 @@ func processPredicateBound(ce ConsumedElement) (string, string, string, *time.Ti
 	return pID, pLowerBoundAlias, pUpperBoundAlias, pLowerBound, pUpperBound, true, nil
 }
 
+// setPredicatePrefix sets the predicate of the graph clause to match the
+// predicates whose ID starts with the ID of the provided predicate. Fully
+// specified predicates only match immutable predicates, or temporal
+// predicates anchored at the same time.
+func setPredicatePrefix(c *GraphClause, ce ConsumedElement) error {
+	p, pID, pAnchorBinding, pTemporal, err := processPredicate(ce)
+	if err != nil {
+		return err
+	}
+	if p != nil {
+		pID = string(p.ID())
+		if pTemporal {
+			ta, err := p.TimeAnchor()
+			if err != nil {
+				return err
+			}
+			c.PLowerBound, c.PUpperBound = ta, ta
+		}
+	}
+	if pID == "" {
+		return fmt.Errorf("predicate prefix %s cannot be empty on graph clause %s", ce.Token().Text, c)
+	}
+	c.PID, c.PAnchorBinding, c.PTemporal = pID, pAnchorBinding, pTemporal
+	return nil
+}
+
 // wherePredicateClause returns an element hook that updates the predicate
 // modifiers on the working graph clause.
 func wherePredicateClause() ElementHook {
@@ -457,17 +892,52 @@ func wherePredicateClause() ElementHook {
 		tkn := ce.Token()
 		c := st.WorkingClause()
 		switch tkn.Type {
+		case lexer.ItemPrefix:
+			lastNopToken = nil
+			c.PIDPrefix = true
+			return f, nil
 		case lexer.ItemPredicate:
+			if c.PIDPrefix {
+				lastNopToken = nil
+				return f, setPredicatePrefix(c, ce)
+			}
+			alternative := lastNopToken != nil && (lastNopToken.Type == lexer.ItemLPar || lastNopToken.Type == lexer.ItemPipe)
 			lastNopToken = nil
-			if c.P != nil {
+			if c.P != nil && !alternative {
 				return nil, fmt.Errorf("invalid predicate %s on graph clause since already set to %s", tkn.Text, c.P)
 			}
 			p, pID, pAnchorBinding, pTemporal, err := processPredicate(ce)
 			if err != nil {
 				return nil, err
 			}
+			if alternative {
+				if p == nil {
+					return nil, fmt.Errorf("predicate alternative %s requires a fully specified predicate on graph clause %s", tkn.Text, c)
+				}
+				for _, ap := range c.PAlternatives {
+					if ap.String() == p.String() {
+						return nil, fmt.Errorf("predicate alternative %s is repeated on graph clause %s", tkn.Text, c)
+					}
+				}
+				c.PAlternatives = append(c.PAlternatives, p)
+				if c.P != nil {
+					return f, nil
+				}
+			}
 			c.P, c.PID, c.PAnchorBinding, c.PTemporal = p, pID, pAnchorBinding, pTemporal
 			return f, nil
+		case lexer.ItemRPar:
+			lastNopToken = nil
+			return f, nil
+		case lexer.ItemPlus, lexer.ItemStar:
+			if c.P == nil {
+				return nil, fmt.Errorf("path quantifier %s requires a fully specified predicate on graph clause %s", tkn.Text, c)
+			}
+			c.PPath = OneOrMore
+			if tkn.Type == lexer.ItemStar {
+				c.PPath = ZeroOrMore
+			}
+			return f, nil
 		case lexer.ItemPredicateBound:
 			lastNopToken = nil
 			if c.PLowerBound != nil || c.PUpperBound != nil || c.PLowerBoundAlias != "" || c.PUpperBoundAlias != "" {
@@ -487,6 +957,9 @@ func wherePredicateClause() ElementHook {
 				c.PBinding = tkn.Text
 				return f, nil
 			}
+			if c.PPath != SingleStep {
+				return nil, fmt.Errorf("path predicate %s%s cannot be bound to %q since it matches several triples", c.P, c.PPath, tkn.Text)
+			}
 			switch lastNopToken.Type {
 			case lexer.ItemAs:
 				if c.PAlias != "" {
@@ -568,6 +1041,17 @@ func whereObjectClause() ElementHook {
 			}
 			c.OID, c.OLowerBoundAlias, c.OUpperBoundAlias, c.OLowerBound, c.OUpperBound, c.OTemporal = oID, oLowerBoundAlias, oUpperBoundAlias, oLowerBound, oUpperBound, oTemp
 			return f, nil
+		case lexer.ItemNodeType:
+			lastNopToken = nil
+			if c.OBinding == "" || c.ONodeType != nil {
+				return nil, fmt.Errorf("node type constraint %s requires an object binding without constraints; current %v", tkn.Text, c)
+			}
+			nt, err := ToNodeType(ce)
+			if err != nil {
+				return nil, err
+			}
+			c.ONodeType = nt
+			return f, nil
 		case lexer.ItemBinding:
 			if lastNopToken == nil {
 				if c.OBinding != "" {
@@ -626,7 +1110,11 @@ func varAccumulator() ElementHook {
 		p := st.WorkingProjection()
 		switch tkn.Type {
 		case lexer.ItemBinding:
-			if p.Binding == "" {
+			if p.Coalesce != nil && p.Binding == "" && (lastNopToken == nil || lastNopToken.Type != lexer.ItemAs) {
+				p.Coalesce = append(p.Coalesce, tkn.Text)
+				break
+			}
+			if p.Binding == "" && p.Coalesce == nil {
 				p.Binding = tkn.Text
 			} else {
 				if lastNopToken != nil && lastNopToken.Type == lexer.ItemAs {
@@ -639,12 +1127,22 @@ func varAccumulator() ElementHook {
 			}
 		case lexer.ItemAs:
 			lastNopToken = tkn
-		case lexer.ItemSum, lexer.ItemCount:
+		case lexer.ItemSum, lexer.ItemCount, lexer.ItemMin, lexer.ItemMax, lexer.ItemAvg:
 			p.OP = tkn.Type
+		case lexer.ItemType, lexer.ItemID, lexer.ItemReify:
+			p.Extract = tkn.Type
 		case lexer.ItemDistinct:
 			p.Modifier = tkn.Type
+		case lexer.ItemCoalesce:
+			p.Coalesce = []string{}
 		case lexer.ItemComma:
+			if p.Coalesce != nil && p.Alias == "" {
+				// Commas separating the bindings of a COALESCE.
+				break
+			}
 			st.AddWorkingProjection()
+		case lexer.ItemStar:
+			st.SetProjectAll()
 		default:
 			lastNopToken = nil
 		}
@@ -653,6 +1151,49 @@ func varAccumulator() ElementHook {
 	return f
 }
 
+// windowAccumulator returns an element hook that collects the partition and
+// order bindings of the window on the working projection.
+func windowAccumulator() ElementHook {
+	var (
+		inPartition bool
+		f           func(st *Statement, ce ConsumedElement) (ElementHook, error)
+	)
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		tkn := ce.Token()
+		p := st.WorkingProjection()
+		switch tkn.Type {
+		case lexer.ItemOver:
+			if p.Window != nil {
+				return nil, fmt.Errorf("window for projection %s has already being assigned", p)
+			}
+			p.Window, inPartition = &Window{}, false
+		case lexer.ItemPartition:
+			inPartition = true
+		case lexer.ItemOrder:
+			inPartition = false
+		case lexer.ItemBinding:
+			if p.Window == nil {
+				return nil, fmt.Errorf("binding %q found outside a window definition for projection %s", tkn.Text, p)
+			}
+			if inPartition {
+				p.Window.PartitionBy = append(p.Window.PartitionBy, tkn.Text)
+			} else {
+				p.Window.OrderBy = append(p.Window.OrderBy, table.SortConfig{{Binding: tkn.Text}}...)
+			}
+		case lexer.ItemAsc, lexer.ItemDesc:
+			if p.Window == nil || len(p.Window.OrderBy) == 0 {
+				return nil, fmt.Errorf("sorting direction %s found without a window order binding for projection %s", tkn.Type, p)
+			}
+			p.Window.OrderBy[len(p.Window.OrderBy)-1].Desc = tkn.Type == lexer.ItemDesc
+		}
+		return f, nil
+	}
+	return f
+}
+
 // bindingsGraphChecker validate that all input bindings are provided by the
 // graph pattern.
 func bindingsGraphChecker() ClauseHook {
@@ -660,12 +1201,25 @@ func bindingsGraphChecker() ClauseHook {
 	f = func(s *Statement, _ Symbol) (ClauseHook, error) {
 		// Force working projection flush.
 		s.AddWorkingProjection()
+		if err := s.expandProjectAll(); err != nil {
+			return nil, err
+		}
+		if err := s.bindGraphs(); err != nil {
+			return nil, err
+		}
 		bs := s.BindingsMap()
 		for _, b := range s.InputBindings() {
 			if _, ok := bs[b]; !ok {
 				return nil, fmt.Errorf("specified binding %s not found in where clause, only %v bindings are available", b, s.Bindings())
 			}
 		}
+		for _, fc := range s.Filters() {
+			for _, b := range fc.Bindings() {
+				if _, ok := bs[b]; !ok {
+					return nil, fmt.Errorf("filter binding %s in %s not found in where clause, only %v bindings are available", b, fc, s.Bindings())
+				}
+			}
+		}
 		return f, nil
 	}
 	return f
@@ -714,6 +1268,12 @@ func groupByBindingsChecker() ClauseHook {
 			if idxs[idx] {
 				continue
 			}
+			if prj.Window != nil {
+				if len(s.groupBy) > 0 {
+					return nil, fmt.Errorf("window aggregation %s cannot be combined with a GROUP BY clause", prj.Alias)
+				}
+				continue
+			}
 			if len(s.groupBy) > 0 && prj.OP == lexer.ItemError {
 				return nil, fmt.Errorf("Binding %q not listed on GROUP BY requires an aggregation function", prj.Binding)
 			}
@@ -752,7 +1312,8 @@ func orderByBindings() ElementHook {
 }
 
 // orderByBindingsChecker checks that all order by bindings are valid output
-// bindings.
+// bindings. Aliases are output bindings, hence results can be ordered by the
+// values of aggregations.
 func orderByBindingsChecker() ClauseHook {
 	var f ClauseHook
 	f = func(s *Statement, _ Symbol) (ClauseHook, error) {
@@ -761,28 +1322,26 @@ func orderByBindingsChecker() ClauseHook {
 		for _, out := range s.OutputBindings() {
 			outs[out] = true
 		}
-		seen, dups := make(map[string]bool), false
+		seen, dedup := make(map[string]bool), table.SortConfig{}
 		for _, cfg := range s.orderBy {
 			// Check there are no contradictions
 			if b, ok := seen[cfg.Binding]; ok {
 				if b != cfg.Desc {
 					return nil, fmt.Errorf("inconsisting sorting direction for %q binding", cfg.Binding)
 				}
-				dups = true
 			} else {
 				seen[cfg.Binding] = cfg.Desc
+				dedup = append(dedup, cfg)
 			}
 			// Check that the binding exist.
 			if _, ok := outs[cfg.Binding]; !ok {
 				return nil, fmt.Errorf("order by binding %q unknown; available bindings are %v", cfg.Binding, s.OutputBindings())
 			}
 		}
-		// If dups exist rewrite the order by SortConfig.
-		if dups {
-			s.orderBy = table.SortConfig{}
-			for b, d := range seen {
-				s.orderBy = append(s.orderBy, table.SortConfig{{Binding: b, Desc: d}}...)
-			}
+		// If dups exist rewrite the order by SortConfig keeping the order of
+		// the first appearance of each binding.
+		if len(dedup) != len(s.orderBy) {
+			s.orderBy = dedup
 		}
 		return f, nil
 	}
@@ -804,6 +1363,95 @@ func havingExpression() ElementHook {
 	return f
 }
 
+// whereFilterClause delimits the filter clause and, once the closing
+// parenthesis is found, builds the evaluator for the collected expression.
+func whereFilterClause() ElementHook {
+	var f func(st *Statement, ce ConsumedElement) (ElementHook, error)
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		switch ce.token.Type {
+		case lexer.ItemFilter:
+			st.workingFilter = nil
+		case lexer.ItemRPar:
+			eval, err := NewFilterEvaluator(st.workingFilter)
+			if err != nil {
+				return nil, err
+			}
+			st.filters = append(st.filters, &FilterClause{
+				expression: st.workingFilter,
+				evaluator:  eval,
+				branch:     st.workingBranch(),
+			})
+			st.workingFilter = nil
+		}
+		return f, nil
+	}
+	return f
+}
+
+// whereUnionClause returns an element hook that tracks the UNION branch the
+// graph clauses being parsed belong to. UNION graph patterns cannot be nested
+// nor combined with other clauses.
+func whereUnionClause() ElementHook {
+	var f func(st *Statement, ce ConsumedElement) (ElementHook, error)
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		switch ce.token.Type {
+		case lexer.ItemLBracket:
+			if st.inUnionBranch {
+				return nil, fmt.Errorf("nested UNION graph patterns are not supported")
+			}
+			if st.unionBranches == 0 && (len(st.pattern) > 0 || len(st.filters) > 0) {
+				return nil, fmt.Errorf("UNION graph patterns cannot be combined with other clauses")
+			}
+			st.unionBranches++
+			st.inUnionBranch = true
+		case lexer.ItemRBracket:
+			st.inUnionBranch = false
+		}
+		return f, nil
+	}
+	return f
+}
+
+// whereNotExistsClause returns an element hook that tracks the NOT EXISTS
+// group the nested graph clauses belong to.
+func whereNotExistsClause() ElementHook {
+	var f func(st *Statement, ce ConsumedElement) (ElementHook, error)
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		switch ce.token.Type {
+		case lexer.ItemLBracket:
+			st.notExistsGroups++
+			st.inNotExistsGroup = true
+		case lexer.ItemRBracket:
+			st.inNotExistsGroup = false
+		}
+		return f, nil
+	}
+	return f
+}
+
+// whereFilterExpression collects the tokens that form the working filter
+// expression.
+func whereFilterExpression() ElementHook {
+	var f func(st *Statement, ce ConsumedElement) (ElementHook, error)
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		st.workingFilter = append(st.workingFilter, ce)
+		return f, nil
+	}
+	return f
+}
+
 // havingExpressionBuilder given the collected tokens that forms the having
 // clause expression, it builds the expression to use when filtering values
 // on the final result table.
@@ -831,21 +1479,74 @@ func limitCollection() ElementHook {
 		if ce.IsSymbol() || ce.token.Type == lexer.ItemLimit {
 			return f, nil
 		}
-		if ce.token.Type != lexer.ItemLiteral {
-			return nil, fmt.Errorf("limit clause required an int64 literal; found %v instead", ce.token)
+		if ce.token.Type == lexer.ItemParameter {
+			st.limitSet, st.limitParam = true, ce.token.Text
+			return f, nil
+		}
+		if ce.token.Type != lexer.ItemLiteral && ce.token.Type != lexer.ItemNumber {
+			return nil, fmt.Errorf("limit clause required an integer, an int64 literal, or a parameter; found %v instead", ce.token)
 		}
-		l, err := literal.DefaultBuilder().Parse(ce.token.Text)
+		lv, err := tokenInt64("limit", ce.token)
+		if err != nil {
+			return nil, err
+		}
+		st.limitSet, st.limit = true, lv
+		return f, nil
+	}
+	return f
+}
+
+// tokenInt64 returns the value of the provided number or int64 literal token.
+// The clause name is only used to build the error messages.
+func tokenInt64(clause string, tkn *lexer.Token) (int64, error) {
+	if tkn.Type == lexer.ItemNumber {
+		v, err := strconv.ParseInt(tkn.Text, 10, 64)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse limit literal %q with error %v", ce.token.Text, err)
+			return 0, fmt.Errorf("failed to parse %s number %q with error %v", clause, tkn.Text, err)
+		}
+		return v, nil
+	}
+	l, err := literal.DefaultBuilder().Parse(tkn.Text)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s literal %q with error %v", clause, tkn.Text, err)
+	}
+	if l.Type() != literal.Int64 {
+		return 0, fmt.Errorf("%s required an int64 value; found %s instead", clause, l)
+	}
+	v, err := l.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve the int64 value for literal %v with error %v", l, err)
+	}
+	return v, nil
+}
+
+// offsetCollection collects the offset provided on the offset clause. Offsets
+// are only deterministic if the results are sorted, hence an ORDER BY clause
+// is required.
+func offsetCollection() ElementHook {
+	var f func(st *Statement, ce ConsumedElement) (ElementHook, error)
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() || ce.token.Type == lexer.ItemOffset {
+			return f, nil
 		}
-		if l.Type() != literal.Int64 {
-			return nil, fmt.Errorf("limit required an int64 value; found %s instead", l)
+		if ce.token.Type != lexer.ItemLiteral && ce.token.Type != lexer.ItemNumber && ce.token.Type != lexer.ItemParameter {
+			return nil, fmt.Errorf("offset clause required an integer, an int64 literal, or a parameter; found %v instead", ce.token)
 		}
-		lv, err := l.Int64()
+		if len(st.orderBy) == 0 {
+			return nil, fmt.Errorf("offset clause requires an order by clause to return deterministic results")
+		}
+		if ce.token.Type == lexer.ItemParameter {
+			st.offsetParam = ce.token.Text
+			return f, nil
+		}
+		ov, err := tokenInt64("offset", ce.token)
 		if err != nil {
-			return nil, fmt.Errorf("failed to retrieve the int64 value for literal %v with error %v", l, err)
+			return nil, err
 		}
-		st.limitSet, st.limit = true, lv
+		if ov < 0 {
+			return nil, fmt.Errorf("offset required a non negative value; found %d instead", ov)
+		}
+		st.offset = ov
 		return f, nil
 	}
 	return f
@@ -907,6 +1608,38 @@ func collectGlobalBounds() ElementHook {
 	return f
 }
 
+// asOf returns an element hook that sets the time of the view of the queried
+// graphs from the anchor of the predicate following the AS OF keywords.
+func asOf() ElementHook {
+	var f ElementHook
+	f = func(st *Statement, ce ConsumedElement) (ElementHook, error) {
+		if ce.IsSymbol() {
+			return f, nil
+		}
+		tkn := ce.Token()
+		if tkn.Type != lexer.ItemPredicate {
+			return f, nil
+		}
+		if st.AsOf() != nil {
+			return nil, fmt.Errorf("as of time already set to %v; found %s instead", st.AsOf(), tkn.Text)
+		}
+		p, err := predicate.Parse(tkn.Text)
+		if err != nil {
+			return nil, err
+		}
+		if p.ID() != "" {
+			return nil, fmt.Errorf("as of time does not accept individual predicate IDs; found %s instead", p)
+		}
+		ta, err := p.TimeAnchor()
+		if err != nil {
+			return nil, err
+		}
+		st.SetAsOf(ta)
+		return f, nil
+	}
+	return f
+}
+
 // InitWorkingConstructClause returns a clause hook to initialize a new working
 // construct clause.
 func InitWorkingConstructClause() ClauseHook {
@@ -955,6 +1688,8 @@ func constructSubjectClause() ElementHook {
 			c.S = n
 		case lexer.ItemBinding:
 			c.SBinding = tkn.Text
+		case lexer.ItemNewBlank:
+			c.SNewBlank = true
 		}
 		return f, nil
 	}
@@ -1005,6 +1740,9 @@ func constructObjectClause() ElementHook {
 		}
 		tkn := ce.Token()
 		c := st.WorkingConstructClause()
+		if c.OConcat != nil {
+			return f, addConcatArgument(c, tkn)
+		}
 		if c.O != nil {
 			return nil, fmt.Errorf("invalid object %v in construct clause, object already set to %v", tkn.Text, c.O)
 		}
@@ -1035,12 +1773,43 @@ func constructObjectClause() ElementHook {
 			}
 		case lexer.ItemBinding:
 			c.OBinding = tkn.Text
+		case lexer.ItemNewBlank:
+			c.ONewBlank = true
+		case lexer.ItemConcat:
+			c.OConcat = []*ConcatArgument{}
 		}
 		return f, nil
 	}
 	return f
 }
 
+// addConcatArgument adds the binding or constant text of the provided token
+// to the CONCAT object of the construct clause. Constants can be either plain
+// strings or text literals.
+func addConcatArgument(c *ConstructClause, tkn *lexer.Token) error {
+	switch tkn.Type {
+	case lexer.ItemBinding:
+		c.OConcat = append(c.OConcat, &ConcatArgument{Binding: tkn.Text})
+	case lexer.ItemString:
+		txt, err := strconv.Unquote(tkn.Text)
+		if err != nil {
+			return fmt.Errorf("invalid text %s in construct clause concat; %v", tkn.Text, err)
+		}
+		c.OConcat = append(c.OConcat, &ConcatArgument{Text: txt})
+	case lexer.ItemLiteral:
+		l, err := literal.DefaultBuilder().Parse(tkn.Text)
+		if err != nil {
+			return err
+		}
+		txt, err := l.Text()
+		if err != nil {
+			return fmt.Errorf("concat in construct clause requires text literals; found %s instead", tkn.Text)
+		}
+		c.OConcat = append(c.OConcat, &ConcatArgument{Text: txt})
+	}
+	return nil
+}
+
 // NextWorkingReificationClause returns a clause hook to close the current reifcation
 // clause and start a new reification clause within the working construct clause.
 func NextWorkingReificationClause() ClauseHook {
@@ -1122,6 +1891,8 @@ func reificationObjectClause() ElementHook {
 			}
 		case lexer.ItemBinding:
 			c.OBinding = tkn.Text
+		case lexer.ItemNewBlank:
+			c.ONewBlank = true
 		}
 		return f, nil
 	}
@@ -91,6 +91,90 @@ func TestDataAccumulatorHook(t *testing.T) {
 	}
 }
 
+func TestDataAccumulatorHookNowAnchors(t *testing.T) {
+	st := &Statement{}
+	ces := []ConsumedElement{
+		NewConsumedToken(&lexer.Token{
+			Type: lexer.ItemNode,
+			Text: "/_<s>",
+		}),
+		NewConsumedToken(&lexer.Token{
+			Type: lexer.ItemPredicate,
+			Text: `"p"@[NOW()]`,
+		}),
+		NewConsumedToken(&lexer.Token{
+			Type: lexer.ItemNode,
+			Text: "/_<o>",
+		}),
+		NewConsumedToken(&lexer.Token{
+			Type: lexer.ItemNode,
+			Text: "/_<s>",
+		}),
+		NewConsumedToken(&lexer.Token{
+			Type: lexer.ItemPredicate,
+			Text: `"p"@[]`,
+		}),
+		NewConsumedToken(&lexer.Token{
+			Type: lexer.ItemPredicate,
+			Text: `"q"@[now()]`,
+		}),
+		NewConsumedToken(&lexer.Token{
+			Type: lexer.ItemNode,
+			Text: "/_<s>",
+		}),
+		NewConsumedToken(&lexer.Token{
+			Type: lexer.ItemPredicate,
+			Text: `"p"@[]`,
+		}),
+		NewConsumedToken(&lexer.Token{
+			Type: lexer.ItemNode,
+			Text: "/_<o>",
+		}),
+	}
+	var (
+		hook ElementHook
+		err  error
+	)
+	hook = dataAccumulator(literal.DefaultBuilder())
+	for _, ce := range ces {
+		hook, err = hook(st, ce)
+		if err != nil {
+			t.Errorf("semantic.DataAccumulator hook should have never failed for %v with error %v", ce, err)
+		}
+	}
+	if got, want := len(st.Data()), 1; got != want {
+		t.Errorf("semantic.DataAccumulator hook produced %d fully anchored triples; want %d", got, want)
+	}
+	now := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	data, err := st.DataAt(now)
+	if err != nil {
+		t.Fatalf("Statement.DataAt(%v) failed with error %v", now, err)
+	}
+	var got []string
+	for _, trpl := range data {
+		got = append(got, trpl.String())
+	}
+	want := []string{
+		"/_<s>\t\"p\"@[]\t/_<o>",
+		"/_<s>\t\"p\"@[2026-03-01T12:00:00Z]\t/_<o>",
+		"/_<s>\t\"p\"@[]\t\"q\"@[2026-03-01T12:00:00Z]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Statement.DataAt(%v) returned %q; want %q", now, got, want)
+	}
+	got = nil
+	for _, n := range st.NowData() {
+		got = append(got, n.String())
+	}
+	want = []string{
+		"/_<s>\t\"p\"@[NOW()]\t/_<o>",
+		"/_<s>\t\"p\"@[]\t\"q\"@[NOW()]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Statement.NowData returned %q; want %q", got, want)
+	}
+}
+
 func TestSemanticAcceptInsertDelete(t *testing.T) {
 	st := &Statement{}
 	ces := []ConsumedElement{
@@ -207,6 +291,10 @@ func TestWhereSubjectClauseHook(t *testing.T) {
 	if err != nil {
 		t.Fatalf("node.Parse failed with error %v", err)
 	}
+	rt, err := node.NewType("/room")
+	if err != nil {
+		t.Fatalf("node.NewType failed with error %v", err)
+	}
 	runTabulatedClauseHookTest(t, "semantic.whereSubjectClause", f, []testClauseTable{
 		{
 			valid: true,
@@ -302,6 +390,45 @@ func TestWhereSubjectClauseHook(t *testing.T) {
 				SIDAlias:   "?bar3",
 			},
 		},
+		{
+			valid: true,
+			id:    "binding_node_type_example",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemBinding,
+					Text: "?foo",
+				}),
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemNodeType,
+					Text: "[/room]",
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: &GraphClause{
+				SBinding:  "?foo",
+				SNodeType: rt,
+			},
+		},
+		{
+			valid: false,
+			id:    "node_type_without_binding",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemNode,
+					Text: "/_<foo>",
+				}),
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemNodeType,
+					Text: "[/room]",
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: &GraphClause{},
+		},
 	})
 }
 
@@ -519,6 +646,55 @@ func TestWherePredicateClauseHook(t *testing.T) {
 				PTemporal:    true,
 			},
 		},
+		{
+			valid: true,
+			id:    "valid exact bound with date",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemPredicateBound,
+					Text: `"foo"@[=2015-07-19T13:12:04.669618843-07:00]`,
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: &GraphClause{
+				PID:         "foo",
+				PLowerBound: &tlb,
+				PUpperBound: &tlb,
+				PTemporal:   true,
+			},
+		},
+		{
+			valid: true,
+			id:    "valid exact bound with binding",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemPredicateBound,
+					Text: `"foo"@[=?t]`,
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: &GraphClause{
+				PID:              "foo",
+				PLowerBoundAlias: "?t",
+				PUpperBoundAlias: "?t",
+				PTemporal:        true,
+			},
+		},
+		{
+			valid: false,
+			id:    "invalid empty exact bound",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemPredicateBound,
+					Text: `"foo"@[=]`,
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: &GraphClause{},
+		},
 		{
 			valid: false,
 			id:    "invalid bound with dates",
@@ -950,6 +1126,45 @@ func TestWhereObjectClauseHook(t *testing.T) {
 				O:      l,
 				OAlias: "?bar"},
 		},
+		{
+			valid: true,
+			id:    "binding_node_type_example",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemBinding,
+					Text: "?foo",
+				}),
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemNodeType,
+					Text: "[/_]",
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: &GraphClause{
+				OBinding:  "?foo",
+				ONodeType: node.Type(),
+			},
+		},
+		{
+			valid: false,
+			id:    "node_type_without_binding",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemNode,
+					Text: "/_<foo>",
+				}),
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemNodeType,
+					Text: "[/_]",
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: &GraphClause{},
+		},
 	})
 }
 
@@ -1163,6 +1378,46 @@ func TestVarAccumulatorHook(t *testing.T) {
 				Modifier: lexer.ItemDistinct,
 			},
 		},
+		{
+			valid: true,
+			id:    "coalesce vars with alias",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemCoalesce,
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemLPar,
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemBinding,
+					Text: "?foo",
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemComma,
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemBinding,
+					Text: "?bar",
+				}),
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemRPar,
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemAs,
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemBinding,
+					Text: "?baz",
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: &Projection{
+				Alias:    "?baz",
+				Coalesce: []string{"?foo", "?bar"},
+			},
+		},
 	})
 }
 
@@ -1266,6 +1521,220 @@ func TestGroupByBindings(t *testing.T) {
 	}
 }
 
+func TestWhereUnionClauseHook(t *testing.T) {
+	f := whereUnionClause()
+	tkn := func(tt lexer.TokenType) ConsumedElement {
+		return NewConsumedToken(&lexer.Token{Type: tt})
+	}
+	st := &Statement{}
+	st.ResetWorkingGraphClause()
+	for _, ce := range []ConsumedElement{
+		tkn(lexer.ItemLBracket),
+		NewConsumedSymbol("FOO"),
+		tkn(lexer.ItemRBracket),
+		tkn(lexer.ItemUnion),
+		tkn(lexer.ItemLBracket),
+	} {
+		if _, err := f(st, ce); err != nil {
+			t.Fatalf("semantic.whereUnionClause should never fail with error %v", err)
+		}
+	}
+	st.WorkingClause().SBinding = "?s"
+	st.AddWorkingGraphClause()
+	if _, err := f(st, tkn(lexer.ItemLBracket)); err == nil {
+		t.Errorf("semantic.whereUnionClause should reject nested UNION graph patterns")
+	}
+	if _, err := f(st, tkn(lexer.ItemRBracket)); err != nil {
+		t.Fatalf("semantic.whereUnionClause should never fail with error %v", err)
+	}
+	if got, want := st.UnionBranches(), 2; got != want {
+		t.Errorf("semantic.whereUnionClause returned %d branches; want %d", got, want)
+	}
+	if got, want := st.GraphPatternClauses()[0].Branch, 2; got != want {
+		t.Errorf("semantic.whereUnionClause assigned the clause to branch %d; want %d", got, want)
+	}
+
+	st = &Statement{pattern: []*GraphClause{{SBinding: "?s"}}}
+	if _, err := f(st, tkn(lexer.ItemLBracket)); err == nil {
+		t.Errorf("semantic.whereUnionClause should reject UNION graph patterns combined with other clauses")
+	}
+}
+
+func TestWhereNotExistsClauseHook(t *testing.T) {
+	f := whereNotExistsClause()
+	tkn := func(tt lexer.TokenType) ConsumedElement {
+		return NewConsumedToken(&lexer.Token{Type: tt})
+	}
+	st := &Statement{}
+	st.ResetWorkingGraphClause()
+	st.WorkingClause().SBinding = "?s"
+	st.AddWorkingGraphClause()
+	for _, ce := range []ConsumedElement{
+		tkn(lexer.ItemNot),
+		tkn(lexer.ItemExists),
+		tkn(lexer.ItemLBracket),
+		NewConsumedSymbol("FOO"),
+	} {
+		if _, err := f(st, ce); err != nil {
+			t.Fatalf("semantic.whereNotExistsClause should never fail with error %v", err)
+		}
+	}
+	st.WorkingClause().SBinding = "?s"
+	st.WorkingClause().OBinding = "?o"
+	st.AddWorkingGraphClause()
+	if _, err := f(st, tkn(lexer.ItemRBracket)); err != nil {
+		t.Fatalf("semantic.whereNotExistsClause should never fail with error %v", err)
+	}
+	st.WorkingClause().OBinding = "?o"
+	st.AddWorkingGraphClause()
+	var got []int
+	for _, cls := range st.GraphPatternClauses() {
+		got = append(got, cls.NotExists)
+	}
+	if want := []int{0, 1, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("semantic.whereNotExistsClause assigned the clauses to groups %v; want %v", got, want)
+	}
+	if grps := st.NotExistsGroups(); len(grps) != 1 || len(grps[0]) != 1 || grps[0][0].OBinding != "?o" {
+		t.Errorf("semantic.NotExistsGroups returned %v; want a single group with the nested clause", grps)
+	}
+	if got, want := len(st.SortedGraphPatternClauses()), 2; got != want {
+		t.Errorf("semantic.SortedGraphPatternClauses returned %d clauses; want %d without the NOT EXISTS group", got, want)
+	}
+}
+
+func TestSelectDistinctHook(t *testing.T) {
+	f, st := selectDistinct(), &Statement{}
+	if _, err := f(st, NewConsumedSymbol("SELECT_DISTINCT")); err != nil {
+		t.Fatalf("semantic.selectDistinct should never fail on symbols; got error %v", err)
+	}
+	if st.IsDistinct() {
+		t.Errorf("semantic.selectDistinct should not mark the statement as distinct before consuming the modifier")
+	}
+	if _, err := f(st, NewConsumedToken(&lexer.Token{Type: lexer.ItemDistinct, Text: "distinct"})); err != nil {
+		t.Fatalf("semantic.selectDistinct failed to consume the distinct modifier with error %v", err)
+	}
+	if !st.IsDistinct() {
+		t.Errorf("semantic.selectDistinct should have marked the statement as distinct")
+	}
+	if _, err := f(st, NewConsumedToken(&lexer.Token{Type: lexer.ItemBinding, Text: "?s"})); err == nil {
+		t.Errorf("semantic.selectDistinct should have failed to consume a binding")
+	}
+}
+
+func TestIgnoreCaseHook(t *testing.T) {
+	f, st := ignoreCase(), &Statement{}
+	l, err := literal.DefaultBuilder().Parse(`"Model S"^^type:text`)
+	if err != nil {
+		t.Fatalf("literal.Parse failed with error %v", err)
+	}
+	st.ResetWorkingGraphClause()
+	st.WorkingClause().O = triple.NewLiteralObject(l)
+	st.AddWorkingGraphClause()
+	st.WorkingClause().OBinding = "?o"
+	st.AddWorkingGraphClause()
+	if got, want := len(st.GraphPatternClauses()), 2; got != want {
+		t.Fatalf("semantic.AddWorkingGraphClause returned %d clauses; want %d", got, want)
+	}
+	if _, err := f(st, NewConsumedSymbol("TEXT_MATCHING")); err != nil {
+		t.Fatalf("semantic.ignoreCase should never fail on symbols; got error %v", err)
+	}
+	if st.IgnoresCase() {
+		t.Errorf("semantic.ignoreCase should not mark the statement before consuming the modifier")
+	}
+	if _, err := f(st, NewConsumedToken(&lexer.Token{Type: lexer.ItemIgnoreCase, Text: "ignore_case"})); err != nil {
+		t.Fatalf("semantic.ignoreCase failed to consume the ignore_case modifier with error %v", err)
+	}
+	if !st.IgnoresCase() {
+		t.Errorf("semantic.ignoreCase should have marked the statement as ignoring case")
+	}
+	if cls := st.GraphPatternClauses()[0]; !cls.OIgnoreCase {
+		t.Errorf("semantic.ignoreCase should have marked clause %v as ignoring case", cls)
+	}
+	if cls := st.GraphPatternClauses()[1]; cls.OIgnoreCase {
+		t.Errorf("semantic.ignoreCase should not have marked clause %v without a text literal object as ignoring case", cls)
+	}
+	if _, err := f(st, NewConsumedToken(&lexer.Token{Type: lexer.ItemBinding, Text: "?s"})); err == nil {
+		t.Errorf("semantic.ignoreCase should have failed to consume a binding")
+	}
+}
+
+func TestFrequenciesQueryHook(t *testing.T) {
+	f := frequenciesQuery()
+	tkn := func(tt lexer.TokenType, txt string) ConsumedElement {
+		return NewConsumedToken(&lexer.Token{Type: tt, Text: txt})
+	}
+	st := &Statement{}
+	for _, ce := range []ConsumedElement{
+		tkn(lexer.ItemFrequencies, "frequencies"),
+		tkn(lexer.ItemLPar, "("),
+		tkn(lexer.ItemBinding, "?p"),
+		tkn(lexer.ItemRPar, ")"),
+		tkn(lexer.ItemFrom, "from"),
+		NewConsumedSymbol("GRAPHS"),
+	} {
+		if _, err := f(st, ce); err != nil {
+			t.Fatalf("semantic.frequenciesQuery should never fail with error %v", err)
+		}
+	}
+	if got, want := st.FrequenciesBinding(), "?p"; got != want {
+		t.Errorf("semantic.frequenciesQuery counted binding %q; want %q", got, want)
+	}
+	if got, want := st.OutputBindings(), []string{"?p", FrequencyCountBinding}; !reflect.DeepEqual(got, want) {
+		t.Errorf("semantic.frequenciesQuery returned output bindings %v; want %v", got, want)
+	}
+	if got, want := st.GroupByBindings(), []string{"?p"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("semantic.frequenciesQuery returned group by bindings %v; want %v", got, want)
+	}
+	want := table.SortConfig{{Binding: FrequencyCountBinding, Desc: true}, {Binding: "?p"}}
+	if got := st.OrderByConfig(); !reflect.DeepEqual(got, want) {
+		t.Errorf("semantic.frequenciesQuery returned order %s; want %s", got, want)
+	}
+	if _, err := f(&Statement{}, tkn(lexer.ItemBinding, FrequencyCountBinding)); err == nil {
+		t.Errorf("semantic.frequenciesQuery should reject counting %s", FrequencyCountBinding)
+	}
+}
+
+func TestWindowAccumulatorHook(t *testing.T) {
+	f := windowAccumulator()
+	tkn := func(tt lexer.TokenType, txt string) ConsumedElement {
+		return NewConsumedToken(&lexer.Token{Type: tt, Text: txt})
+	}
+	st := &Statement{}
+	ces := []ConsumedElement{
+		NewConsumedSymbol("FOO"),
+		tkn(lexer.ItemOver, "over"),
+		tkn(lexer.ItemLPar, "("),
+		NewConsumedSymbol("FOO"),
+		tkn(lexer.ItemPartition, "partition"),
+		tkn(lexer.ItemBy, "by"),
+		tkn(lexer.ItemBinding, "?s"),
+		tkn(lexer.ItemComma, ","),
+		tkn(lexer.ItemBinding, "?p"),
+		tkn(lexer.ItemOrder, "order"),
+		tkn(lexer.ItemBy, "by"),
+		tkn(lexer.ItemBinding, "?t"),
+		tkn(lexer.ItemDesc, "desc"),
+		tkn(lexer.ItemComma, ","),
+		tkn(lexer.ItemBinding, "?o"),
+		tkn(lexer.ItemRPar, ")"),
+	}
+	for _, ce := range ces {
+		if _, err := f(st, ce); err != nil {
+			t.Fatalf("semantic.windowAccumulator should never fail with error %v", err)
+		}
+	}
+	want := &Window{
+		PartitionBy: []string{"?s", "?p"},
+		OrderBy:     table.SortConfig{{Binding: "?t", Desc: true}, {Binding: "?o"}},
+	}
+	if got := st.WorkingProjection().Window; !reflect.DeepEqual(got, want) {
+		t.Errorf("semantic.windowAccumulator collected the wrong window; got %v, want %v", got, want)
+	}
+	if _, err := f(st, tkn(lexer.ItemOver, "over")); err == nil {
+		t.Errorf("semantic.windowAccumulator should reject a second window for the same projection")
+	}
+}
+
 func TestGroupByBindingsChecker(t *testing.T) {
 	f := groupByBindingsChecker()
 	testTable := []struct {
@@ -1331,6 +1800,27 @@ func TestGroupByBindingsChecker(t *testing.T) {
 			},
 			want: false,
 		},
+		{
+			id: "window aggregation",
+			s: &Statement{
+				projection: []*Projection{
+					{Binding: "?foo"},
+					{Binding: "?bar", Alias: "?running", OP: lexer.ItemSum, Window: &Window{OrderBy: table.SortConfig{{Binding: "?foo"}}}},
+				},
+			},
+			want: true,
+		},
+		{
+			id: "window aggregation with group by",
+			s: &Statement{
+				projection: []*Projection{
+					{Binding: "?foo"},
+					{Binding: "?bar", Alias: "?running", OP: lexer.ItemSum, Window: &Window{OrderBy: table.SortConfig{{Binding: "?foo"}}}},
+				},
+				groupBy: []string{"?foo"},
+			},
+			want: false,
+		},
 	}
 	for _, entry := range testTable {
 		if _, err := f(entry.s, Symbol("FOO")); (err == nil) != entry.want {
@@ -1444,12 +1934,50 @@ func TestOrderByBindingsChecker(t *testing.T) {
 			},
 			want: false,
 		},
+		{
+			id: "aggregation alias",
+			s: &Statement{
+				projection: []*Projection{
+					{Binding: "?foo"},
+					{Binding: "?bar", Alias: "?n", OP: lexer.ItemCount},
+				},
+				orderBy: table.SortConfig{{Binding: "?n", Desc: true}},
+			},
+			want: true,
+		},
+		{
+			id: "aggregated binding",
+			s: &Statement{
+				projection: []*Projection{
+					{Binding: "?foo"},
+					{Binding: "?bar", Alias: "?n", OP: lexer.ItemCount},
+				},
+				orderBy: table.SortConfig{{Binding: "?bar"}},
+			},
+			want: false,
+		},
 	}
 	for _, entry := range testTable {
 		if _, err := f(entry.s, Symbol("FOO")); (err == nil) != entry.want {
 			t.Errorf("semantic.orderByBindingsChecker invalid order by statement %#v for case %q; %v", entry.s, entry.id, err)
 		}
 	}
+	// Repeated bindings are removed keeping the order of their first
+	// appearance.
+	s := &Statement{
+		projection: []*Projection{
+			{Binding: "?foo"},
+			{Binding: "?bar", Alias: "?n", OP: lexer.ItemCount},
+			{Binding: "?baz"},
+		},
+		orderBy: table.SortConfig{{Binding: "?n", Desc: true}, {Binding: "?foo"}, {Binding: "?baz"}, {Binding: "?n", Desc: true}, {Binding: "?foo"}},
+	}
+	if _, err := f(s, Symbol("FOO")); err != nil {
+		t.Fatalf("semantic.orderByBindingsChecker failed with error %v", err)
+	}
+	if got, want := s.OrderByConfig(), (table.SortConfig{{Binding: "?n", Desc: true}, {Binding: "?foo"}, {Binding: "?baz"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("semantic.orderByBindingsChecker rewrote the order by clause as %v; want %v", got, want)
+	}
 }
 
 func TestHavingExpression(t *testing.T) {
@@ -1743,6 +2271,17 @@ func TestLimitCollection(t *testing.T) {
 			},
 			want: 1234,
 		},
+		{
+			in: []ConsumedElement{
+				NewConsumedSymbol("FOO"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemNumber,
+					Text: "42",
+				}),
+				NewConsumedSymbol("FOO"),
+			},
+			want: 42,
+		},
 	}
 	st := &Statement{}
 	for _, entry := range testTable {
@@ -1759,6 +2298,60 @@ func TestLimitCollection(t *testing.T) {
 	}
 }
 
+func TestOffsetCollection(t *testing.T) {
+	f := offsetCollection()
+	in := []ConsumedElement{
+		NewConsumedSymbol("FOO"),
+		NewConsumedToken(&lexer.Token{
+			Type: lexer.ItemOffset,
+			Text: "offset",
+		}),
+		NewConsumedToken(&lexer.Token{
+			Type: lexer.ItemLiteral,
+			Text: `"20"^^type:int64`,
+		}),
+		NewConsumedSymbol("FOO"),
+	}
+	// Offsets require sorted results.
+	st := &Statement{}
+	if _, err := f(st, in[2]); err == nil {
+		t.Errorf("semantic.offsetCollection should fail without an order by clause")
+	}
+	st.orderBy = table.SortConfig{{Binding: "?foo"}}
+	for _, ce := range in {
+		if _, err := f(st, ce); err != nil {
+			t.Errorf("semantic.offsetCollection should never fail with error %v", err)
+		}
+	}
+	if got, want := st.Offset(), int64(20); got != want {
+		t.Errorf("semantic.offsetCollection failed to collect the expected value; got %v, want %v", got, want)
+	}
+}
+
+func TestLimitAndOffsetParameters(t *testing.T) {
+	param := NewConsumedToken(&lexer.Token{Type: lexer.ItemParameter, Text: "@n"})
+	st := &Statement{}
+	if _, err := limitCollection()(st, param); err != nil {
+		t.Fatalf("semantic.limitCollection failed to collect a parameter with error %v", err)
+	}
+	if !st.IsLimitSet() || st.LimitParameter() != "@n" {
+		t.Errorf("semantic.limitCollection collected limit parameter %q (%v); want \"@n\" (true)", st.LimitParameter(), st.IsLimitSet())
+	}
+	if _, err := offsetCollection()(st, param); err == nil {
+		t.Errorf("semantic.offsetCollection should fail to collect a parameter without an order by clause")
+	}
+	st.orderBy = table.SortConfig{{Binding: "?foo"}}
+	if _, err := offsetCollection()(st, param); err != nil {
+		t.Fatalf("semantic.offsetCollection failed to collect a parameter with error %v", err)
+	}
+	if got, want := st.OffsetParameter(), "@n"; got != want {
+		t.Errorf("semantic.offsetCollection collected offset parameter %q; want %q", got, want)
+	}
+	if got, want := st.Parameters(), []string{"@n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Statement.Parameters returned %v; want %v", got, want)
+	}
+}
+
 func TestCollectGlobalBounds(t *testing.T) {
 	f := collectGlobalBounds()
 	date := "2015-07-19T13:12:04.669618843-07:00"
@@ -2032,6 +2625,28 @@ func TestConstructSubjectClauseHook(t *testing.T) {
 				SBinding:   "?foo",
 			},
 		},
+		{
+			valid: true,
+			id:    "valid new blank node",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("CONSTRUCT_TRIPLES"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemNewBlank,
+					Text: "NEW_BLANK",
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemLPar,
+					Text: "(",
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemRPar,
+					Text: ")",
+				}),
+			},
+			want: &ConstructClause{
+				SNewBlank: true,
+			},
+		},
 		{
 			valid: false,
 			id:    "invalid node and binding",
@@ -2202,6 +2817,98 @@ func TestConstructObjectClauseHook(t *testing.T) {
 				O: bno,
 			},
 		},
+		{
+			valid: true,
+			id:    "valid new blank node object",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("CONSTRUCT_OBJECT"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemNewBlank,
+					Text: "NEW_BLANK",
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemLPar,
+					Text: "(",
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemRPar,
+					Text: ")",
+				}),
+			},
+			want: &ConstructClause{
+				ONewBlank: true,
+			},
+		},
+		{
+			valid: true,
+			id:    "valid concat object",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("CONSTRUCT_OBJECT"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemConcat,
+					Text: "CONCAT",
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemLPar,
+					Text: "(",
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemBinding,
+					Text: "?first",
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemComma,
+					Text: ",",
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemString,
+					Text: `" "`,
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemComma,
+					Text: ",",
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemLiteral,
+					Text: `"Jr."^^type:text`,
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemRPar,
+					Text: ")",
+				}),
+			},
+			want: &ConstructClause{
+				OConcat: []*ConcatArgument{
+					{Binding: "?first"},
+					{Text: " "},
+					{Text: "Jr."},
+				},
+			},
+		},
+		{
+			valid: false,
+			id:    "invalid non text concat argument",
+			ces: []ConsumedElement{
+				NewConsumedSymbol("CONSTRUCT_OBJECT"),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemConcat,
+					Text: "CONCAT",
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemLPar,
+					Text: "(",
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemLiteral,
+					Text: `"1"^^type:int64`,
+				}),
+				NewConsumedToken(&lexer.Token{
+					Type: lexer.ItemRPar,
+					Text: ")",
+				}),
+			},
+			want: &ConstructClause{},
+		},
 		{
 			valid: true,
 			id:    "valid literal object",
@@ -21,14 +21,18 @@ package semantic
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/badwolf/bql/lexer"
 	"github.com/google/badwolf/bql/table"
 	"github.com/google/badwolf/storage"
 	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
 	"github.com/google/badwolf/triple/node"
 	"github.com/google/badwolf/triple/predicate"
 )
@@ -49,6 +53,14 @@ const (
 	Drop
 	// Construct statement.
 	Construct
+	// Import statement.
+	Import
+	// Show statement.
+	Show
+	// Describe statement.
+	Describe
+	// Truncate statement.
+	Truncate
 )
 
 // String provides a readable version of the StatementType.
@@ -66,17 +78,53 @@ func (t StatementType) String() string {
 		return "DROP"
 	case Construct:
 		return "CONSTRUCT"
+	case Import:
+		return "IMPORT"
+	case Show:
+		return "SHOW"
+	case Describe:
+		return "DESCRIBE"
+	case Truncate:
+		return "TRUNCATE"
 	default:
 		return "UNKNOWN"
 	}
 }
 
+// PathQuantifier describes how many times the predicate of a graph clause can
+// be traversed to go from the subject to the object.
+type PathQuantifier int8
+
+const (
+	// SingleStep clauses match a single triple.
+	SingleStep PathQuantifier = iota
+	// OneOrMore clauses match paths of one or more triples.
+	OneOrMore
+	// ZeroOrMore clauses match paths of zero or more triples.
+	ZeroOrMore
+)
+
+// String provides the BQL quantifier of the PathQuantifier.
+func (q PathQuantifier) String() string {
+	switch q {
+	case OneOrMore:
+		return "+"
+	case ZeroOrMore:
+		return "*"
+	default:
+		return ""
+	}
+}
+
 // Statement contains all the semantic information extract from the parsing
 type Statement struct {
 	sType                     StatementType
 	graphNames                []string
 	graphs                    []storage.Graph
 	data                      []*triple.Triple
+	nowData                   []*NowTriple
+	importData                string
+	cloneSource               string
 	pattern                   []*GraphClause
 	workingClause             *GraphClause
 	constructClauses          []*ConstructClause
@@ -87,9 +135,35 @@ type Statement struct {
 	orderBy                   table.SortConfig
 	havingExpression          []ConsumedElement
 	havingExpressionEvaluator Evaluator
+	filters                   []*FilterClause
+	workingFilter             []ConsumedElement
+	unionBranches             int
+	inUnionBranch             bool
+	notExistsGroups           int
+	inNotExistsGroup          bool
 	limitSet                  bool
 	limit                     int64
+	limitParam                string
+	offset                    int64
+	offsetParam               string
 	lookupOptions             storage.LookupOptions
+	asOf                      *time.Time
+	schema                    bool
+	schemaPredicates          []predicate.ID
+	frequencies               string
+	latestBinding             string
+	latestPredicate           predicate.ID
+	latestCount               int64
+	sampleBinding             string
+	sampleWeight              string
+	sampleCount               int64
+	sampleSeed                int64
+	sampleSeeded              bool
+	distinct                  bool
+	projectAll                bool
+	ignoreCase                bool
+	multisetGraphs            bool
+	outputGraphNames          []string
 }
 
 // GraphClause represents a clause of a graph pattern in a where clause.
@@ -99,6 +173,9 @@ type GraphClause struct {
 	SAlias     string
 	STypeAlias string
 	SIDAlias   string
+	// SNodeType is the type the nodes bound to the subject binding need to be
+	// covariant with, if any.
+	SNodeType *node.Type
 
 	P                *predicate.Predicate
 	PID              string
@@ -112,6 +189,15 @@ type GraphClause struct {
 	PLowerBoundAlias string
 	PUpperBoundAlias string
 	PTemporal        bool
+	// PIDPrefix is true if PID only needs to be a prefix of the ID of the
+	// matched predicates.
+	PIDPrefix bool
+	// PPath is the quantifier of the predicate for clauses matching paths
+	// instead of single triples.
+	PPath PathQuantifier
+	// PAlternatives contains the candidate predicates of clauses matching any
+	// of several predicates. P is set to the first of them.
+	PAlternatives []*predicate.Predicate
 
 	O                *triple.Object
 	OBinding         string
@@ -126,12 +212,38 @@ type GraphClause struct {
 	OLowerBoundAlias string
 	OUpperBoundAlias string
 	OTemporal        bool
+	// ONodeType is the type the nodes bound to the object binding need to be
+	// covariant with, if any. Objects that are not nodes do not match.
+	ONodeType *node.Type
+	// OIgnoreCase is true if the text literal object of the clause also
+	// matches the text literals that only differ from it in case.
+	OIgnoreCase bool
+
+	// GBinding is the binding for the name of the graph each triple matching
+	// the clause was read from, if any.
+	GBinding string
+
+	// Negated is true if the clause was negated inline in the graph pattern.
+	// Bindings in negated clauses do not escape the clause.
+	Negated bool
+
+	// Branch is the UNION branch the clause belongs to, starting at 1. Clauses
+	// outside of a UNION belong to branch 0.
+	Branch int
+
+	// NotExists is the NOT EXISTS group the clause belongs to, starting at 1.
+	// Clauses outside of NOT EXISTS groups belong to group 0. Bindings in NOT
+	// EXISTS groups do not escape the group.
+	NotExists int
 }
 
 // ConstructClause represents a singular clause within a construct statement.
 type ConstructClause struct {
 	S        *node.Node
 	SBinding string
+	// SNewBlank is set if a fresh blank node is minted as subject of each
+	// instantiation of the clause.
+	SNewBlank bool
 
 	P              *predicate.Predicate
 	PBinding       string
@@ -144,11 +256,32 @@ type ConstructClause struct {
 	OID            string
 	OAnchorBinding string
 	OTemporal      bool
+	// ONewBlank is set if a fresh blank node is minted as object of each
+	// instantiation of the clause.
+	ONewBlank bool
+	// OConcat contains the arguments concatenated into the text literal
+	// object of each instantiation of the clause, if set using CONCAT.
+	OConcat []*ConcatArgument
 
 	reificationClauses        []*ReificationClause
 	workingReificationClause  *ReificationClause
 }
 
+// ConcatArgument represents an argument of a CONCAT construct object. It is
+// either a binding, or a constant text if the binding is empty.
+type ConcatArgument struct {
+	Binding string
+	Text    string
+}
+
+// String returns the BQL form of the argument.
+func (a *ConcatArgument) String() string {
+	if a.Binding != "" {
+		return a.Binding
+	}
+	return strconv.Quote(a.Text)
+}
+
 // ReificationClause represents a clause used to reify a triple.
 type ReificationClause struct {
 	P              *predicate.Predicate
@@ -162,11 +295,90 @@ type ReificationClause struct {
 	OID            string
 	OAnchorBinding string
 	OTemporal      bool
+	// ONewBlank is set if a fresh blank node is minted as object of each
+	// instantiation of the clause.
+	ONewBlank bool
+}
+
+// FilterClause represents a filter expression in a where clause. Filters are
+// evaluated on the rows while resolving the graph pattern.
+type FilterClause struct {
+	expression []ConsumedElement
+	evaluator  Evaluator
+	branch     int
+}
+
+// String returns a readable representation of a filter clause.
+func (f *FilterClause) String() string {
+	b := bytes.NewBufferString("FILTER(")
+	for i, ce := range f.expression {
+		tkn := ce.Token()
+		prev := lexer.ItemError
+		if i > 0 {
+			prev = f.expression[i-1].Token().Type
+		}
+		if i > 0 && tkn.Type != lexer.ItemRPar && tkn.Type != lexer.ItemComma && prev != lexer.ItemLPar && prev != lexer.ItemFuzzy && prev != lexer.ItemStartsWith && prev != lexer.ItemID {
+			b.WriteString(" ")
+		}
+		b.WriteString(tkn.Text)
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// Bindings returns the bindings used in the filter expression.
+func (f *FilterClause) Bindings() []string {
+	var bs []string
+	seen := make(map[string]bool)
+	for _, ce := range f.expression {
+		if tkn := ce.Token(); tkn.Type == lexer.ItemBinding && !seen[tkn.Text] {
+			seen[tkn.Text] = true
+			bs = append(bs, tkn.Text)
+		}
+	}
+	return bs
+}
+
+// Evaluator returns the evaluator constructed for the filter expression.
+func (f *FilterClause) Evaluator() Evaluator {
+	return f.evaluator
+}
+
+// Parameters returns the parameters used in the filter expression.
+func (f *FilterClause) Parameters() []string {
+	var ps []string
+	seen := make(map[string]bool)
+	for _, ce := range f.expression {
+		if tkn := ce.Token(); tkn.Type == lexer.ItemParameter && !seen[tkn.Text] {
+			seen[tkn.Text] = true
+			ps = append(ps, tkn.Text)
+		}
+	}
+	return ps
+}
+
+// BindParameters returns an evaluator for the filter expression that uses the
+// provided values for its parameters. It fails if the value of any of the
+// parameters of the expression is missing.
+func (f *FilterClause) BindParameters(ps map[string]*table.Cell) (Evaluator, error) {
+	if ps == nil {
+		ps = map[string]*table.Cell{}
+	}
+	return newEvaluator(f.expression, true, ps)
+}
+
+// Branch returns the UNION branch the filter belongs to. Filters outside of a
+// UNION belong to branch 0.
+func (f *FilterClause) Branch() int {
+	return f.branch
 }
 
 // String returns a readable representation of a graph clause.
 func (c *GraphClause) String() string {
 	b := bytes.NewBufferString("{ ")
+	if c.Negated {
+		b = bytes.NewBufferString("!{ ")
+	}
 
 	// Subject section.
 	if c.S != nil {
@@ -174,6 +386,11 @@ func (c *GraphClause) String() string {
 	} else {
 		b.WriteString(c.SBinding)
 	}
+	if c.SNodeType != nil {
+		b.WriteString("[")
+		b.WriteString(c.SNodeType.String())
+		b.WriteString("]")
+	}
 	if c.SAlias != "" {
 		b.WriteString(" AS ")
 		b.WriteString(c.SAlias)
@@ -189,9 +406,19 @@ func (c *GraphClause) String() string {
 
 	// Predicate section.
 	predicate := false
-	if c.P != nil {
+	if len(c.PAlternatives) > 0 {
+		var ps []string
+		for _, p := range c.PAlternatives {
+			ps = append(ps, p.String())
+		}
+		b.WriteString(" (")
+		b.WriteString(strings.Join(ps, " | "))
+		b.WriteString(")")
+		predicate = true
+	} else if c.P != nil {
 		b.WriteString(" ")
 		b.WriteString(c.P.String())
+		b.WriteString(c.PPath.String())
 		predicate = true
 	}
 	if c.PBinding != "" {
@@ -199,7 +426,12 @@ func (c *GraphClause) String() string {
 		b.WriteString(c.PBinding)
 	}
 	if c.PID != "" {
-		b.WriteString(" \"")
+		if c.PIDPrefix {
+			b.WriteString(" PREFIX(")
+		} else {
+			b.WriteString(" ")
+		}
+		b.WriteString("\"")
 		b.WriteString(c.PID)
 		b.WriteString("\"")
 	}
@@ -232,6 +464,13 @@ func (c *GraphClause) String() string {
 				}
 			}
 			b.WriteString("]")
+			if c.PAnchorBinding == "" && c.PAnchorAlias != "" {
+				b.WriteString(" at ")
+				b.WriteString(c.PAnchorAlias)
+			}
+		}
+		if c.PIDPrefix {
+			b.WriteString(")")
 		}
 	}
 
@@ -256,6 +495,11 @@ func (c *GraphClause) String() string {
 		b.WriteString(c.OBinding)
 		object = true
 	}
+	if c.ONodeType != nil {
+		b.WriteString("[")
+		b.WriteString(c.ONodeType.String())
+		b.WriteString("]")
+	}
 	if c.OAlias != "" {
 		b.WriteString(" AS ")
 		b.WriteString(c.OAlias)
@@ -341,30 +585,31 @@ func (c *GraphClause) Specificity() int {
 // BindingsMap returns the binding map fo he graph clause.
 func (c *GraphClause) BindingsMap() map[string]int {
 	bm := make(map[string]int)
-
-	addToBindings(bm, c.SBinding)
-	addToBindings(bm, c.SAlias)
-	addToBindings(bm, c.STypeAlias)
-	addToBindings(bm, c.SIDAlias)
-	addToBindings(bm, c.PAlias)
-	addToBindings(bm, c.PAnchorBinding)
-	addToBindings(bm, c.PBinding)
-	addToBindings(bm, c.PLowerBoundAlias)
-	addToBindings(bm, c.PUpperBoundAlias)
-	addToBindings(bm, c.PIDAlias)
-	addToBindings(bm, c.PAnchorAlias)
-	addToBindings(bm, c.OBinding)
-	addToBindings(bm, c.OAlias)
-	addToBindings(bm, c.OTypeAlias)
-	addToBindings(bm, c.OIDAlias)
-	addToBindings(bm, c.OAnchorAlias)
-	addToBindings(bm, c.OAnchorBinding)
-	addToBindings(bm, c.OLowerBoundAlias)
-	addToBindings(bm, c.OUpperBoundAlias)
-
+	for _, b := range c.orderedBindings() {
+		addToBindings(bm, b)
+	}
 	return bm
 }
 
+// orderedBindings returns the bindings of the graph clause in the order they
+// are written in the clause. Repeated bindings are listed once per use.
+func (c *GraphClause) orderedBindings() []string {
+	var bs []string
+	for _, b := range []string{
+		c.SBinding, c.SAlias, c.STypeAlias, c.SIDAlias,
+		c.PBinding, c.PAnchorBinding, c.PLowerBoundAlias, c.PUpperBoundAlias,
+		c.PAlias, c.PIDAlias, c.PAnchorAlias,
+		c.OBinding, c.OAnchorBinding, c.OLowerBoundAlias, c.OUpperBoundAlias,
+		c.OAlias, c.OTypeAlias, c.OIDAlias, c.OAnchorAlias,
+		c.GBinding,
+	} {
+		if b != "" {
+			bs = append(bs, b)
+		}
+	}
+	return bs
+}
+
 // Bindings returns the list of unique bindings listed int he graph clause.
 func (c *GraphClause) Bindings() []string {
 	var bs []string
@@ -404,20 +649,35 @@ func (s *Statement) AddGraph(g string) {
 	s.graphNames = append(s.graphNames, g)
 }
 
+// AddOutputGraph adds a graph where the triples built by a construct
+// statement get stored.
+func (s *Statement) AddOutputGraph(g string) {
+	s.outputGraphNames = append(s.outputGraphNames, g)
+}
+
+// OutputGraphNames returns the list of graphs where the triples built by a
+// construct statement get stored.
+func (s *Statement) OutputGraphNames() []string {
+	return s.outputGraphNames
+}
+
 // Graphs returns the list of graphs listed on the statement.
 func (s *Statement) Graphs() []storage.Graph {
 	return s.graphs
 }
 
-// Init initialize the graphs givne the graph names.
+// Init initialize the graphs givne the graph names. Initializing the statement
+// again replaces the previously initialized graphs.
 func (s *Statement) Init(ctx context.Context, st storage.Store) error {
+	var gs []storage.Graph
 	for _, gn := range s.graphNames {
 		g, err := st.Graph(ctx, gn)
 		if err != nil {
 			return err
 		}
-		s.graphs = append(s.graphs, g)
+		gs = append(gs, g)
 	}
+	s.graphs = gs
 	return nil
 }
 
@@ -431,11 +691,111 @@ func (s *Statement) AddData(d *triple.Triple) {
 	s.data = append(s.data, d)
 }
 
-// Data returns the data available for the given statement.
+// Data returns the data available for the given statement. It does not
+// include the triples anchored at NOW(); see DataAt.
 func (s *Statement) Data() []*triple.Triple {
 	return s.data
 }
 
+// NowTriple is a triple of a DATA clause whose predicate, whose object
+// predicate, or both are anchored at NOW(). The anchor is only known when the
+// statement is executed.
+type NowTriple struct {
+	s    *node.Node
+	p    *predicate.Predicate
+	pNow bool
+	o    *triple.Object
+	oNow bool
+}
+
+// String returns a readable representation of the triple, keeping the NOW()
+// anchors unresolved.
+func (n *NowTriple) String() string {
+	p, o := n.p.String(), n.o.String()
+	if n.pNow {
+		p = fmt.Sprintf("%q@[NOW()]", n.p.ID())
+	}
+	if n.oNow {
+		op, _ := n.o.Predicate()
+		o = fmt.Sprintf("%q@[NOW()]", op.ID())
+	}
+	return fmt.Sprintf("%s\t%s\t%s", n.s, p, o)
+}
+
+// At returns the triple with its NOW() anchors set to the provided time.
+func (n *NowTriple) At(now time.Time) (*triple.Triple, error) {
+	p, o := n.p, n.o
+	if n.pNow {
+		tp, err := predicate.NewTemporal(string(p.ID()), now)
+		if err != nil {
+			return nil, err
+		}
+		p = tp
+	}
+	if n.oNow {
+		op, err := o.Predicate()
+		if err != nil {
+			return nil, err
+		}
+		top, err := predicate.NewTemporal(string(op.ID()), now)
+		if err != nil {
+			return nil, err
+		}
+		o = triple.NewPredicateObject(top)
+	}
+	return triple.New(n.s, p, o)
+}
+
+// addNowData adds a triple anchored at NOW() to the statement's data.
+func (s *Statement) addNowData(n *NowTriple) {
+	s.nowData = append(s.nowData, n)
+}
+
+// NowData returns the triples of the statement's data anchored at NOW().
+func (s *Statement) NowData() []*NowTriple {
+	return s.nowData
+}
+
+// DataAt returns all the data of the statement, with the NOW() anchors set to
+// the provided time. All the triples anchored at NOW() share the same time.
+func (s *Statement) DataAt(now time.Time) ([]*triple.Triple, error) {
+	if len(s.nowData) == 0 {
+		return s.data, nil
+	}
+	d := append([]*triple.Triple{}, s.data...)
+	for _, n := range s.nowData {
+		t, err := n.At(now)
+		if err != nil {
+			return nil, err
+		}
+		d = append(d, t)
+	}
+	return d, nil
+}
+
+// SetImportData sets the serialized triples loaded by an import statement.
+func (s *Statement) SetImportData(d string) {
+	s.importData = d
+}
+
+// ImportData returns the serialized triples loaded by an import statement, one
+// triple per line.
+func (s *Statement) ImportData() string {
+	return s.importData
+}
+
+// SetCloneSource sets the graph copied into the graphs created by a create
+// statement.
+func (s *Statement) SetCloneSource(g string) {
+	s.cloneSource = g
+}
+
+// CloneSource returns the graph copied into the graphs created by a create
+// statement, or an empty string if they are created empty.
+func (s *Statement) CloneSource() string {
+	return s.cloneSource
+}
+
 // GraphPatternClauses returns the list of graph pattern clauses
 func (s *Statement) GraphPatternClauses() []*GraphClause {
 	return s.pattern
@@ -455,11 +815,30 @@ func (s *Statement) WorkingClause() *GraphClause {
 // clauses that form the graph pattern.
 func (s *Statement) AddWorkingGraphClause() {
 	if s.workingClause != nil && !s.workingClause.IsEmpty() {
+		s.workingClause.Branch = s.workingBranch()
+		if s.inNotExistsGroup {
+			s.workingClause.NotExists = s.notExistsGroups
+		}
 		s.pattern = append(s.pattern, s.workingClause)
 	}
 	s.ResetWorkingGraphClause()
 }
 
+// workingBranch returns the UNION branch being parsed, or 0 if the parser is
+// not inside a UNION branch.
+func (s *Statement) workingBranch() int {
+	if !s.inUnionBranch {
+		return 0
+	}
+	return s.unionBranches
+}
+
+// UnionBranches returns the number of branches of the UNION graph pattern. It
+// returns 0 if the graph pattern is not a UNION.
+func (s *Statement) UnionBranches() int {
+	return s.unionBranches
+}
+
 // Projection returns the available projections in the statement.
 func (s *Statement) Projection() []*Projection {
 	return s.projection
@@ -492,32 +871,202 @@ func addToBindings(bs map[string]int, b string) {
 	}
 }
 
+// SchemaBindings contains the bindings made available by schema queries: the
+// predicate, its domain, and its range.
+var SchemaBindings = []string{"?p", "?domain", "?range"}
+
+// GraphBinding is the pseudo-binding that provides the name of the graph the
+// triples matching the graph pattern were read from.
+const GraphBinding = "?_graph"
+
+// bindGraphs makes the first clause of the graph pattern bind GraphBinding if
+// the statement uses it. Only one clause binds it, hence the other clauses
+// still match triples of any of the graphs and rows combine the same triples
+// as without the binding. If all the clauses belong to UNION branches, the
+// first clause of each branch binds it. Bindings of negated clauses and NOT
+// EXISTS groups do not escape them, hence those clauses never bind it.
+func (s *Statement) bindGraphs() error {
+	used := false
+	for _, b := range s.InputBindings() {
+		used = used || b == GraphBinding
+	}
+	for _, f := range s.filters {
+		for _, b := range f.Bindings() {
+			used = used || b == GraphBinding
+		}
+	}
+	if !used {
+		return nil
+	}
+	for _, cls := range s.pattern {
+		if _, ok := cls.BindingsMap()[GraphBinding]; ok && cls.GBinding == "" {
+			return fmt.Errorf("binding %s is reserved for the name of the graph triples are read from and cannot be used in clause %s", GraphBinding, cls)
+		}
+	}
+	var first []*GraphClause
+	bound := make(map[int]bool)
+	for _, cls := range s.pattern {
+		if cls.Negated || cls.NotExists != 0 || bound[cls.Branch] {
+			continue
+		}
+		if cls.Branch == 0 {
+			first = []*GraphClause{cls}
+			break
+		}
+		first = append(first, cls)
+		bound[cls.Branch] = true
+	}
+	for _, cls := range first {
+		cls.GBinding = GraphBinding
+	}
+	return nil
+}
+
+// SetSchemaQuery marks the statement as a schema query.
+func (s *Statement) SetSchemaQuery() {
+	s.schema = true
+}
+
+// IsSchemaQuery returns true if the statement queries the schema of the
+// graphs instead of a graph pattern.
+func (s *Statement) IsSchemaQuery() bool {
+	return s.schema
+}
+
+// AddSchemaPredicate adds a predicate ID to limit the schema query to.
+func (s *Statement) AddSchemaPredicate(id predicate.ID) {
+	s.schemaPredicates = append(s.schemaPredicates, id)
+}
+
+// SchemaPredicates returns the predicate IDs the schema query is limited to.
+// If empty, all predicates should be reported.
+func (s *Statement) SchemaPredicates() []predicate.ID {
+	return s.schemaPredicates
+}
+
+// FrequencyCountBinding contains the binding where frequencies queries return
+// the number of occurrences of each value.
+const FrequencyCountBinding = "?count"
+
+// SetFrequenciesQuery sets up the statement to return the distinct values
+// bound to the provided binding along with their number of occurrences,
+// sorted by number of occurrences in descending order.
+func (s *Statement) SetFrequenciesQuery(b string) {
+	s.frequencies = b
+	s.projection = []*Projection{
+		{Binding: b},
+		{Binding: b, Alias: FrequencyCountBinding, OP: lexer.ItemCount},
+	}
+	s.groupBy = []string{b}
+	s.orderBy = table.SortConfig{
+		{Binding: FrequencyCountBinding, Desc: true},
+		{Binding: b},
+	}
+}
+
+// FrequenciesBinding returns the binding whose values a frequencies query
+// counts. It returns an empty string if the statement is not a frequencies
+// query.
+func (s *Statement) FrequenciesBinding() string {
+	return s.frequencies
+}
+
+// Bindings where latest queries return the predicate and the object of the
+// newest triples of each subject.
+const (
+	LatestPredicateBinding = "?p"
+	LatestObjectBinding    = "?o"
+)
+
+// SetLatestQuery sets up the statement to return, for each subject, the n
+// triples of the predicate ID with the newest time anchors. Subjects are
+// returned under the provided binding, and the predicates and objects under
+// LatestPredicateBinding and LatestObjectBinding.
+func (s *Statement) SetLatestQuery(b string, id predicate.ID, n int64) {
+	s.latestBinding, s.latestPredicate, s.latestCount = b, id, n
+	s.projection = []*Projection{
+		{Binding: b},
+		{Binding: LatestPredicateBinding},
+		{Binding: LatestObjectBinding},
+	}
+}
+
+// IsLatestQuery returns true if the statement queries the newest triples of
+// each subject.
+func (s *Statement) IsLatestQuery() bool {
+	return s.latestBinding != ""
+}
+
+// LatestQuery returns the subject binding, the predicate ID, and the number of
+// triples per subject of a latest query.
+func (s *Statement) LatestQuery() (string, predicate.ID, int64) {
+	return s.latestBinding, s.latestPredicate, s.latestCount
+}
+
+// SetWeightedSample sets up the statement to return n rows of the graph
+// pattern sampled with probability proportional to the numeric value bound to
+// the weight binding. The sampled rows are projected on the provided binding
+// and the weight binding.
+func (s *Statement) SetWeightedSample(b, w string, n int64) {
+	s.sampleBinding, s.sampleWeight, s.sampleCount = b, w, n
+	s.projection = []*Projection{
+		{Binding: b},
+		{Binding: w},
+	}
+}
+
+// SetWeightedSampleSeed sets the seed used to sample the rows, which makes
+// samples reproducible.
+func (s *Statement) SetWeightedSampleSeed(seed int64) {
+	s.sampleSeed, s.sampleSeeded = seed, true
+}
+
+// IsWeightedSample returns true if the statement samples the rows of the
+// graph pattern.
+func (s *Statement) IsWeightedSample() bool {
+	return s.sampleBinding != ""
+}
+
+// WeightedSample returns the sampled binding, the weight binding, and the
+// number of rows to sample of a weighted sample query.
+func (s *Statement) WeightedSample() (string, string, int64) {
+	return s.sampleBinding, s.sampleWeight, s.sampleCount
+}
+
+// WeightedSampleSeed returns the seed used to sample the rows and true if one
+// was provided.
+func (s *Statement) WeightedSampleSeed() (int64, bool) {
+	return s.sampleSeed, s.sampleSeeded
+}
+
 // BindingsMap returns the set of bindings available on the graph clauses for the
 // statement.
 func (s *Statement) BindingsMap() map[string]int {
 	bm := make(map[string]int)
 
+	if s.schema {
+		for _, b := range SchemaBindings {
+			addToBindings(bm, b)
+		}
+	}
+
+	// Frequencies queries without a graph pattern count the predicates of the
+	// graphs.
+	if s.frequencies != "" && len(s.pattern) == 0 {
+		addToBindings(bm, s.frequencies)
+	}
+
+	if s.latestBinding != "" {
+		addToBindings(bm, s.latestBinding)
+		addToBindings(bm, LatestPredicateBinding)
+		addToBindings(bm, LatestObjectBinding)
+	}
+
 	for _, cls := range s.pattern {
-		if cls != nil {
-			addToBindings(bm, cls.SBinding)
-			addToBindings(bm, cls.SAlias)
-			addToBindings(bm, cls.STypeAlias)
-			addToBindings(bm, cls.SIDAlias)
-			addToBindings(bm, cls.PAlias)
-			addToBindings(bm, cls.PAnchorBinding)
-			addToBindings(bm, cls.PBinding)
-			addToBindings(bm, cls.PLowerBoundAlias)
-			addToBindings(bm, cls.PUpperBoundAlias)
-			addToBindings(bm, cls.PIDAlias)
-			addToBindings(bm, cls.PAnchorAlias)
-			addToBindings(bm, cls.OBinding)
-			addToBindings(bm, cls.OAlias)
-			addToBindings(bm, cls.OTypeAlias)
-			addToBindings(bm, cls.OIDAlias)
-			addToBindings(bm, cls.OAnchorAlias)
-			addToBindings(bm, cls.OAnchorBinding)
-			addToBindings(bm, cls.OLowerBoundAlias)
-			addToBindings(bm, cls.OUpperBoundAlias)
+		if cls != nil && !cls.Negated && cls.NotExists == 0 {
+			for _, b := range cls.orderedBindings() {
+				addToBindings(bm, b)
+			}
 		}
 	}
 	return bm
@@ -556,7 +1105,7 @@ func (s *Statement) SortedGraphPatternClauses() []*GraphClause {
 	var ptrns []*GraphClause
 	// Filter empty clauses.
 	for _, cls := range s.pattern {
-		if cls != nil && !cls.IsEmpty() {
+		if cls != nil && !cls.IsEmpty() && !cls.Negated && cls.NotExists == 0 {
 			ptrns = append(ptrns, cls)
 		}
 	}
@@ -564,6 +1113,34 @@ func (s *Statement) SortedGraphPatternClauses() []*GraphClause {
 	return ptrns
 }
 
+// NegatedGraphPatternClauses returns the list of inline negated graph pattern
+// clauses.
+func (s *Statement) NegatedGraphPatternClauses() []*GraphClause {
+	var ptrns []*GraphClause
+	for _, cls := range s.pattern {
+		if cls != nil && cls.Negated {
+			ptrns = append(ptrns, cls)
+		}
+	}
+	return ptrns
+}
+
+// NotExistsGroups returns the clauses of each NOT EXISTS group in the order
+// the groups appear in the graph pattern.
+func (s *Statement) NotExistsGroups() [][]*GraphClause {
+	var grps [][]*GraphClause
+	for i := 1; i <= s.notExistsGroups; i++ {
+		var ptrns []*GraphClause
+		for _, cls := range s.pattern {
+			if cls != nil && cls.NotExists == i {
+				ptrns = append(ptrns, cls)
+			}
+		}
+		grps = append(grps, ptrns)
+	}
+	return grps
+}
+
 // Projection contains the information required to project the outcome of
 // querying with GraphClauses. It also contains the information of what
 // aggregation function should be used.
@@ -572,13 +1149,59 @@ type Projection struct {
 	Alias    string
 	OP       lexer.TokenType // The information about what function to use.
 	Modifier lexer.TokenType // The modifier for the selected op.
+	Window   *Window         // The window of a running aggregation, if any.
+	Extract  lexer.TokenType // TYPE or ID if only that part of the node is projected, or REIFY for the triple it reifies.
+	Coalesce []string        // The bindings of a COALESCE, if any; the first bound one is projected.
+}
+
+// Window contains the partition and order of a running aggregation. The
+// aggregation of a row accumulates all the rows of its partition from the
+// first one up to the current one in the window order.
+type Window struct {
+	PartitionBy []string
+	OrderBy     table.SortConfig
+}
+
+// String returns a readable form of the window.
+func (w *Window) String() string {
+	b := bytes.NewBufferString("over (")
+	if len(w.PartitionBy) > 0 {
+		b.WriteString("partition by ")
+		b.WriteString(strings.Join(w.PartitionBy, ", "))
+		b.WriteString(" ")
+	}
+	b.WriteString("order by ")
+	for i, o := range w.OrderBy {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(o.Binding)
+		if o.Desc {
+			b.WriteString(" desc")
+		}
+	}
+	b.WriteString(")")
+	return b.String()
 }
 
 // String returns a readable form of the projection.
 func (p *Projection) String() string {
 	b := bytes.NewBufferString(p.Binding)
+	if len(p.Coalesce) > 0 {
+		b.WriteString("coalesce(")
+		b.WriteString(strings.Join(p.Coalesce, ", "))
+		b.WriteString(")")
+	}
 	b.WriteString(" as ")
-	b.WriteString(p.Binding)
+	if p.Alias != "" {
+		b.WriteString(p.Alias)
+	} else {
+		b.WriteString(p.Binding)
+	}
+	if p.Extract != lexer.ItemError {
+		b.WriteString(" via ")
+		b.WriteString(p.Extract.String())
+	}
 	if p.OP != lexer.ItemError {
 		b.WriteString(" via ")
 		b.WriteString(p.OP.String())
@@ -586,13 +1209,17 @@ func (p *Projection) String() string {
 			b.WriteString(" ")
 			b.WriteString(p.Modifier.String())
 		}
+		if p.Window != nil {
+			b.WriteString(" ")
+			b.WriteString(p.Window.String())
+		}
 	}
 	return b.String()
 }
 
 // IsEmpty checks if the given projection is empty.
 func (p *Projection) IsEmpty() bool {
-	return p.Binding == "" && p.Alias == "" && p.OP == lexer.ItemError && p.Modifier == lexer.ItemError
+	return p.Binding == "" && p.Alias == "" && p.OP == lexer.ItemError && p.Modifier == lexer.ItemError && p.Window == nil && p.Extract == lexer.ItemError && p.Coalesce == nil
 }
 
 // ResetProjection resets the current working variable projection.
@@ -622,6 +1249,18 @@ func (s *Statement) Projections() []*Projection {
 	return s.projection
 }
 
+// isExtractAlias returns true if the binding is the alias of a TYPE, ID, or
+// REIFY projection. Those aliases can be coalesced since they are bound
+// before COALESCE projections are evaluated.
+func (s *Statement) isExtractAlias(b string) bool {
+	for _, p := range s.projection {
+		if p.Extract != lexer.ItemError && p.Alias == b {
+			return true
+		}
+	}
+	return false
+}
+
 // InputBindings returns the list of incoming bindings feed from a where clause.
 func (s *Statement) InputBindings() []string {
 	var res []string
@@ -629,6 +1268,17 @@ func (s *Statement) InputBindings() []string {
 		if p.Binding != "" {
 			res = append(res, p.Binding)
 		}
+		for _, b := range p.Coalesce {
+			if !s.isExtractAlias(b) {
+				res = append(res, b)
+			}
+		}
+		if p.Window != nil {
+			res = append(res, p.Window.PartitionBy...)
+			for _, o := range p.Window.OrderBy {
+				res = append(res, o.Binding)
+			}
+		}
 	}
 	for _, c := range s.constructClauses {
 		if c.SBinding != "" {
@@ -646,6 +1296,11 @@ func (s *Statement) InputBindings() []string {
 		if c.OAnchorBinding != "" {
 			res = append(res, c.OAnchorBinding)
 		}
+		for _, a := range c.OConcat {
+			if a.Binding != "" {
+				res = append(res, a.Binding)
+			}
+		}
 		for _, r := range c.reificationClauses {
 			if r.PBinding != "" {
 				res = append(res, r.PBinding)
@@ -679,6 +1334,55 @@ func (s *Statement) OutputBindings() []string {
 	return res
 }
 
+// StatementInfo contains the metadata of a parsed statement that can be
+// inspected without executing it.
+type StatementInfo struct {
+	// Type is the type of the statement.
+	Type StatementType
+	// Mutation is true if executing the statement changes the store.
+	Mutation bool
+	// GraphNames contains the graphs the statement reads or, for create, drop,
+	// insert, and delete statements, the graphs it changes.
+	GraphNames []string
+	// OutputGraphNames contains the graphs constructed triples are stored in.
+	OutputGraphNames []string
+	// InputBindings contains the bindings used from the graph pattern.
+	InputBindings []string
+	// OutputBindings contains the columns of the returned table in order.
+	OutputBindings []string
+	// HasAggregations is true if any projection aggregates its binding.
+	HasAggregations bool
+	// HasOrderBy is true if the statement sorts the returned rows.
+	HasOrderBy bool
+}
+
+// Describe returns the metadata of the statement. The returned values are
+// copies, hence changing them does not alter the statement.
+func (s *Statement) Describe() StatementInfo {
+	cp := func(ss []string) []string {
+		if len(ss) == 0 {
+			return nil
+		}
+		return append([]string(nil), ss...)
+	}
+	info := StatementInfo{
+		Type:             s.Type(),
+		Mutation:         s.Type() != Query && s.Type() != Show && s.Type() != Describe && !(s.Type() == Construct && len(s.OutputGraphNames()) == 0),
+		GraphNames:       cp(s.GraphNames()),
+		OutputGraphNames: cp(s.OutputGraphNames()),
+		InputBindings:    s.InputBindings(),
+		OutputBindings:   s.OutputBindings(),
+		HasOrderBy:       len(s.OrderByConfig()) > 0,
+	}
+	for _, p := range s.projection {
+		if p.OP != lexer.ItemError {
+			info.HasAggregations = true
+			break
+		}
+	}
+	return info
+}
+
 // GroupByBindings returns the bindings used on the group by statement.
 func (s *Statement) GroupByBindings() []string {
 	return s.groupBy
@@ -690,6 +1394,11 @@ func (s *Statement) OrderByConfig() table.SortConfig {
 	return s.orderBy
 }
 
+// Filters returns the filter clauses available in the where clause.
+func (s *Statement) Filters() []*FilterClause {
+	return s.filters
+}
+
 // HasHavingClause returns true if there is a having clause.
 func (s *Statement) HasHavingClause() bool {
 	return len(s.havingExpression) > 0
@@ -711,13 +1420,156 @@ func (s *Statement) Limit() int64 {
 	return s.limit
 }
 
+// SetDistinct marks the statement as only returning distinct projected rows.
+func (s *Statement) SetDistinct() {
+	s.distinct = true
+}
+
+// IsDistinct returns true if duplicated projected rows should be removed from
+// the results.
+func (s *Statement) IsDistinct() bool {
+	return s.distinct
+}
+
+// SetProjectAll marks the statement as projecting all the bindings of its
+// graph pattern, as requested by SELECT *.
+func (s *Statement) SetProjectAll() {
+	s.projectAll = true
+}
+
+// ProjectsAll returns true if the statement projects all the bindings of its
+// graph pattern.
+func (s *Statement) ProjectsAll() bool {
+	return s.projectAll
+}
+
+// expandProjectAll replaces the projections of statements that project all
+// the bindings of the graph pattern with one projection per binding, in order
+// of first appearance in the graph pattern. Bindings of negated clauses and
+// NOT EXISTS groups do not escape them, hence they are not projected.
+func (s *Statement) expandProjectAll() error {
+	if !s.projectAll {
+		return nil
+	}
+	if len(s.projection) > 0 {
+		return fmt.Errorf("SELECT * cannot be combined with other projections; found %v", s.projection)
+	}
+	seen := make(map[string]bool)
+	for _, cls := range s.pattern {
+		if cls == nil || cls.Negated || cls.NotExists != 0 {
+			continue
+		}
+		for _, b := range cls.orderedBindings() {
+			if !seen[b] {
+				seen[b] = true
+				s.projection = append(s.projection, &Projection{Binding: b})
+			}
+		}
+	}
+	if len(s.projection) == 0 {
+		return fmt.Errorf("SELECT * requires the graph pattern to have at least one binding")
+	}
+	return nil
+}
+
+// SetIgnoreCase marks the statement as matching the text literal objects of
+// its graph pattern regardless of their case. Only the literals written on the
+// clauses are affected; values bound while solving the pattern still need to
+// match exactly.
+func (s *Statement) SetIgnoreCase() {
+	s.ignoreCase = true
+	for _, cls := range s.pattern {
+		if cls.O == nil {
+			continue
+		}
+		if l, err := cls.O.Literal(); err == nil && l.Type() == literal.Text {
+			cls.OIgnoreCase = true
+		}
+	}
+}
+
+// IgnoresCase returns true if the text literal objects of the graph pattern
+// match regardless of their case.
+func (s *Statement) IgnoresCase() bool {
+	return s.ignoreCase
+}
+
+// SetMultisetGraphs marks the statement as keeping one copy of each triple
+// per graph it is found on. By default, triples present on several of the
+// graphs of the statement are only scanned once.
+func (s *Statement) SetMultisetGraphs() {
+	s.multisetGraphs = true
+}
+
+// IsMultisetGraphs returns true if triples present on several graphs should
+// be scanned once per graph.
+func (s *Statement) IsMultisetGraphs() bool {
+	return s.multisetGraphs
+}
+
+// Offset returns the number of rows to skip set in the offset clause.
+func (s *Statement) Offset() int64 {
+	return s.offset
+}
+
+// LimitParameter returns the name of the parameter that provides the limit
+// value when the query is executed. It returns an empty string if the limit
+// clause uses a literal.
+func (s *Statement) LimitParameter() string {
+	return s.limitParam
+}
+
+// OffsetParameter returns the name of the parameter that provides the number
+// of rows to skip when the query is executed. It returns an empty string if
+// the offset clause uses a literal.
+func (s *Statement) OffsetParameter() string {
+	return s.offsetParam
+}
+
+// Parameters returns the names of the parameters whose values need to be
+// provided when the statement is executed. The limit and offset parameters
+// come first, followed by the parameters of the filters in order.
+func (s *Statement) Parameters() []string {
+	var ps []string
+	seen := make(map[string]bool)
+	add := func(p string) {
+		if p != "" && !seen[p] {
+			seen[p] = true
+			ps = append(ps, p)
+		}
+	}
+	add(s.limitParam)
+	add(s.offsetParam)
+	for _, f := range s.filters {
+		for _, p := range f.Parameters() {
+			add(p)
+		}
+	}
+	return ps
+}
+
 // GlobalLookupOptions returns the global lookup options available in the
-// statement.
+// statement. The as of time, if any, tightens the upper anchor.
 func (s *Statement) GlobalLookupOptions() *storage.LookupOptions {
 	lo := s.lookupOptions
+	if s.asOf != nil && (lo.UpperAnchor == nil || s.asOf.Before(*lo.UpperAnchor)) {
+		lo.UpperAnchor = s.asOf
+	}
 	return &lo
 }
 
+// SetAsOf sets the time of the view of the queried graphs. Only the temporal
+// triples anchored at or before it are considered.
+func (s *Statement) SetAsOf(t *time.Time) {
+	s.asOf = t
+}
+
+// AsOf returns the time of the view of the queried graphs, or nil if the
+// statement queries the graphs as they are.
+func (s *Statement) AsOf() *time.Time {
+	return s.asOf
+}
+
 // ConstructClauses returns the list of construct clauses in the statement.
 func (s *Statement) ConstructClauses() []*ConstructClause {
 	return s.constructClauses
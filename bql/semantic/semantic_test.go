@@ -18,6 +18,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/google/badwolf/bql/lexer"
 	"github.com/google/badwolf/triple"
 	"github.com/google/badwolf/triple/literal"
 	"github.com/google/badwolf/triple/node"
@@ -71,6 +72,31 @@ func TestGraphClauseSpecificity(t *testing.T) {
 	}
 }
 
+func TestGraphClauseString(t *testing.T) {
+	table := []struct {
+		gc   *GraphClause
+		want string
+	}{
+		{
+			gc:   &GraphClause{SBinding: "?s", PID: "bought", PAnchorBinding: "?a", PAnchorAlias: "?t", PTemporal: true, OBinding: "?o"},
+			want: `{ ?s "bought"@[?a at ?t] ?o }`,
+		},
+		{
+			gc:   &GraphClause{SBinding: "?s", PID: "bought", PLowerBoundAlias: "?l", PUpperBoundAlias: "?u", PAnchorAlias: "?t", PTemporal: true, OBinding: "?o"},
+			want: `{ ?s "bought"@[?l,?u] at ?t ?o }`,
+		},
+		{
+			gc:   &GraphClause{SBinding: "?s", PID: "bought", PTemporal: true, OBinding: "?o", Negated: true},
+			want: `!{ ?s "bought"@[,] ?o }`,
+		},
+	}
+	for _, entry := range table {
+		if got, want := entry.gc.String(), entry.want; got != want {
+			t.Errorf("semantic.GraphClause.String failed to return the proper value; got %s, want %s", got, want)
+		}
+	}
+}
+
 func TestGraphClauseManipulation(t *testing.T) {
 	st := &Statement{}
 	if st.WorkingClause() != nil {
@@ -190,6 +216,23 @@ func TestProjectionIsEmpty(t *testing.T) {
 	}
 }
 
+func TestProjectionString(t *testing.T) {
+	table := []struct {
+		p    *Projection
+		want string
+	}{
+		{&Projection{Binding: "?foo"}, "?foo as ?foo"},
+		{&Projection{Binding: "?foo", Alias: "?bar"}, "?foo as ?bar"},
+		{&Projection{Binding: "?foo", Alias: "?bar", OP: lexer.ItemCount}, "?foo as ?bar via COUNT"},
+		{&Projection{Alias: "?bar", Coalesce: []string{"?foo", "?baz"}}, "coalesce(?foo, ?baz) as ?bar"},
+	}
+	for _, entry := range table {
+		if got := entry.p.String(); got != entry.want {
+			t.Errorf("semantic.Projection.String returned %q; want %q", got, entry.want)
+		}
+	}
+}
+
 func TestConstructClauseManipulation(t *testing.T) {
 	st := &Statement{}
 	if st.WorkingConstructClause() != nil {
@@ -17,13 +17,23 @@ package table
 
 import (
 	"bytes"
+	"container/heap"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"math"
+	"math/big"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/context"
 
 	"github.com/google/badwolf/triple/literal"
 	"github.com/google/badwolf/triple/node"
@@ -87,6 +97,17 @@ func (c *Cell) String() string {
 	return "<NULL>"
 }
 
+// isEmpty returns true if the cell does not contain any value.
+func (c *Cell) isEmpty() bool {
+	return c.S == nil && c.N == nil && c.P == nil && c.L == nil && c.T == nil
+}
+
+// isUnbound returns true if the cell is missing from the row or does not
+// contain any value.
+func isUnbound(c *Cell) bool {
+	return c == nil || c.isEmpty()
+}
+
 // Row represents a collection of cells.
 type Row map[string]*Cell
 
@@ -101,7 +122,7 @@ func (r Row) ToTextLine(res *bytes.Buffer, bs []string, sep string) error {
 	for _, b := range bs {
 		cnt--
 		v := "<NULL>"
-		if c, ok := r[b]; ok {
+		if c, ok := r[b]; ok && c != nil {
 			v = c.String()
 		}
 		if _, err := res.WriteString(v); err != nil {
@@ -144,6 +165,30 @@ func (t *Table) Rows() []Row {
 	return t.Data
 }
 
+// Copy returns a copy of the table. Rows can be added, removed, or updated on
+// the copy without affecting the original table. Cells are shared, hence they
+// should not be modified in place.
+func (t *Table) Copy() *Table {
+	c := &Table{
+		AvailableBindings: append([]string{}, t.AvailableBindings...),
+		mbs:               make(map[string]bool, len(t.mbs)),
+	}
+	for b := range t.mbs {
+		c.mbs[b] = true
+	}
+	if t.Data != nil {
+		c.Data = make([]Row, len(t.Data))
+	}
+	for i, r := range t.Data {
+		nr := make(Row, len(r))
+		for k, v := range r {
+			nr[k] = v
+		}
+		c.Data[i] = nr
+	}
+	return c
+}
+
 // AddBindings add the new bindings provided to the table.
 func (t *Table) AddBindings(bs []string) {
 	for _, b := range bs {
@@ -239,9 +284,61 @@ func MergeRows(ms []Row) Row {
 
 // DotProduct does the dot product with the provided table
 func (t *Table) DotProduct(t2 *Table) error {
+	return t.DotProductContext(context.Background(), t2)
+}
+
+// DotProductContext does the dot product with the provided table. The product
+// of large tables can take a long time, hence the context is checked while
+// the rows are merged; if the context is done the table is left unchanged and
+// the context error is returned.
+func (t *Table) DotProductContext(ctx context.Context, t2 *Table) error {
+	return t.DotProductConcurrently(ctx, t2, 1)
+}
+
+// DotProductConcurrently works like DotProductContext, but the rows of the
+// table are partitioned among up to the provided number of workers that merge
+// them with the rows of the provided table concurrently. Each worker merges at
+// least dotProductCheckRows rows, and the resulting rows are in the same
+// order as the ones returned by DotProductContext.
+func (t *Table) DotProductConcurrently(ctx context.Context, t2 *Table, workers int) error {
 	if !disjointBinding(t.mbs, t2.mbs) {
 		return fmt.Errorf("DotProduct operations requires disjoint bindingts; instead got %v and %v", t.mbs, t2.mbs)
 	}
+	// Compute the data.
+	size := len(t.Data) * len(t2.Data)
+	data := make([]Row, size, size) // Preallocate resulting table.
+	if n := (size + dotProductCheckRows - 1) / dotProductCheckRows; workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		if err := dotProductRows(ctx, data, t.Data, t2.Data); err != nil {
+			return err
+		}
+	} else {
+		var (
+			wg   sync.WaitGroup
+			errs = make([]error, workers)
+		)
+		chunk := (len(t.Data) + workers - 1) / workers
+		for w, lo := 0, 0; lo < len(t.Data); w, lo = w+1, lo+chunk {
+			hi := lo + chunk
+			if hi > len(t.Data) {
+				hi = len(t.Data)
+			}
+			wg.Add(1)
+			go func(w, lo, hi int) {
+				defer wg.Done()
+				errs[w] = dotProductRows(ctx, data[lo*len(t2.Data):hi*len(t2.Data)], t.Data[lo:hi], t2.Data)
+			}(w, lo, hi)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+	}
+	t.Data = data
 	// Update the table metadata.
 	m := make(map[string]bool)
 	for k := range t.mbs {
@@ -255,19 +352,31 @@ func (t *Table) DotProduct(t2 *Table) error {
 	for k := range t.mbs {
 		t.AvailableBindings = append(t.AvailableBindings, k)
 	}
-	// Update the data.
-	td := t.Data
-	cnt, size := 0, len(td)*len(t2.Data)
-	t.Data = make([]Row, size, size) // Preallocate resulting table.
-	for _, r1 := range td {
-		for _, r2 := range t2.Data {
-			t.Data[cnt] = MergeRows([]Row{r1, r2})
+	return nil
+}
+
+// dotProductRows merges each of the rows in r1s with all the rows in r2s into
+// the provided data, which should be able to hold all the merged rows.
+func dotProductRows(ctx context.Context, data, r1s, r2s []Row) error {
+	cnt := 0
+	for _, r1 := range r1s {
+		for _, r2 := range r2s {
+			if cnt%dotProductCheckRows == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			data[cnt] = MergeRows([]Row{r1, r2})
 			cnt++
 		}
 	}
 	return nil
 }
 
+// dotProductCheckRows is the number of rows merged by a dot product between
+// checks of the context.
+const dotProductCheckRows = 1024
+
 // DeleteRow removes the row at position i from the table. This should be used
 // carefully. If you are planning to delete a large volume of rows consider
 // creating a new table and just copy the rows you need. This operation relies
@@ -296,6 +405,17 @@ func (t *Table) Limit(i int64) {
 	}
 }
 
+// Offset drops the initial ith rows.
+func (t *Table) Offset(i int64) {
+	if int64(len(t.Data)) <= i {
+		t.Data = nil
+		return
+	}
+	td := make([]Row, int64(len(t.Data))-i)
+	copy(td, t.Data[i:])
+	t.Data = td
+}
+
 // SortConfig contains the sorting information. Contains the binding order
 // to use while sorting as well as the direction for each of them to use.
 type SortConfig []struct {
@@ -357,13 +477,16 @@ func rowLess(ri, rj Row, c SortConfig) bool {
 		return false
 	}
 	cfg, last := c[0], len(c) == 1
-	ci, ok := ri[cfg.Binding]
-	if !ok {
-		log.Fatalf("Could not retrieve binding %q! %v %v", cfg.Binding, ri, rj)
-	}
-	cj, ok := rj[cfg.Binding]
-	if !ok {
-		log.Fatalf("Could not retrieve binding %q! %v %v", cfg.Binding, ri, rj)
+	ci, cj := ri[cfg.Binding], rj[cfg.Binding]
+	if ui, uj := isUnbound(ci), isUnbound(cj); ui || uj {
+		// Unbound values sort before any bound value.
+		if ui == uj {
+			if last {
+				return false
+			}
+			return rowLess(ri, rj, c[1:])
+		}
+		return ui != cfg.Desc
 	}
 	si, sj := "", ""
 	// Check if it has a string.
@@ -378,15 +501,20 @@ func rowLess(ri, rj Row, c SortConfig) bool {
 	if ci.P != nil && cj.P != nil {
 		si, sj = ci.P.String(), cj.P.String()
 	}
-	// Check if it has a literal.
-	if ci.L != nil && cj.L != nil {
-		si, sj = ci.L.ToComparableString(), cj.L.ToComparableString()
-	}
 	// Check if it has a time anchor.
 	if ci.T != nil && cj.T != nil {
 		si, sj = ci.T.Format(time.RFC3339Nano), cj.T.Format(time.RFC3339Nano)
 	}
 	l := stringLess(si, sj, cfg.Desc)
+	if ci.L != nil && cj.L != nil || ci.T != nil && cj.T != nil {
+		// Literals and time anchors are compared by value, since their
+		// comparable strings do not sort negative values, mixed types, or
+		// times in different zones properly.
+		l, _ = compareValues(ci, cj)
+		if cfg.Desc {
+			l *= -1
+		}
+	}
 	if l < 0 {
 		return true
 	}
@@ -396,6 +524,15 @@ func rowLess(ri, rj Row, c SortConfig) bool {
 	return rowLess(ri, rj, c[1:])
 }
 
+// compareValues compares two cells like CompareCells, but literals of any
+// type are compared following the total order of literal.Literal.Compare.
+func compareValues(a, b *Cell) (int, error) {
+	if a.L != nil && b.L != nil {
+		return a.L.Compare(b.L)
+	}
+	return CompareCells(a, b)
+}
+
 // Less returns true if the i row is less than j one.
 func (c bySortConfig) Less(i, j int) bool {
 	ri, rj, cfg := c.rows[i], c.rows[j], c.cfg
@@ -410,6 +547,89 @@ func (t *Table) Sort(cfg SortConfig) {
 	sort.Sort(bySortConfig{t.Data, cfg})
 }
 
+// mergeHead contains the current head row of one of the merged streams.
+type mergeHead struct {
+	row Row
+	idx int
+}
+
+// mergeHeap implements heap.Interface to sort the heads of the merged streams.
+// Ties are broken by stream index to keep the merge deterministic.
+type mergeHeap struct {
+	heads []mergeHead
+	cfg   SortConfig
+}
+
+func (h *mergeHeap) Len() int {
+	return len(h.heads)
+}
+
+func (h *mergeHeap) Less(i, j int) bool {
+	hi, hj := h.heads[i], h.heads[j]
+	if rowLess(hi.row, hj.row, h.cfg) {
+		return true
+	}
+	if rowLess(hj.row, hi.row, h.cfg) {
+		return false
+	}
+	return hi.idx < hj.idx
+}
+
+func (h *mergeHeap) Swap(i, j int) {
+	h.heads[i], h.heads[j] = h.heads[j], h.heads[i]
+}
+
+func (h *mergeHeap) Push(x interface{}) {
+	h.heads = append(h.heads, x.(mergeHead))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	n := len(h.heads)
+	x := h.heads[n-1]
+	h.heads = h.heads[:n-1]
+	return x
+}
+
+// MergeSorted k-way merges the provided row streams into out. Each of the
+// input streams must already be sorted using the provided sort configuration;
+// the rows pushed to out are then globally sorted. Only the head row of each
+// input stream is retained, hence memory scales with the number of streams
+// instead of the total number of rows. The merge stops early once done is
+// closed; producers feeding the input streams should also stop on done to
+// avoid blocking forever. The output channel is always closed on return.
+func MergeSorted(done <-chan struct{}, cfg SortConfig, ins []<-chan Row, out chan<- Row) {
+	defer close(out)
+	recv := func(in <-chan Row) (Row, bool) {
+		select {
+		case r, ok := <-in:
+			return r, ok
+		case <-done:
+			return nil, false
+		}
+	}
+	h := &mergeHeap{cfg: cfg}
+	for i, in := range ins {
+		if r, ok := recv(in); ok {
+			h.heads = append(h.heads, mergeHead{row: r, idx: i})
+		}
+	}
+	heap.Init(h)
+	for h.Len() > 0 {
+		top := h.heads[0]
+		select {
+		case out <- top.row:
+		case <-done:
+			return
+		}
+		if r, ok := recv(ins[top.idx]); ok {
+			h.heads[0] = mergeHead{row: r, idx: top.idx}
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+}
+
 // Accumulator type represents a generic accumulator for independent values
 // expressed as the element of the array slice. Returns the values after being
 // accumulated. If the wrong type is passed in, it will crash casting the
@@ -422,6 +642,58 @@ type Accumulator interface {
 	Reset()
 }
 
+// toLiteral returns the literal contained in the accumulated value. Values can
+// be provided as literals or as table cells holding a literal.
+func toLiteral(v interface{}) (*literal.Literal, error) {
+	switch l := v.(type) {
+	case *literal.Literal:
+		return l, nil
+	case *Cell:
+		if l != nil && l.L != nil {
+			return l.L, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot accumulate non literal value %v", v)
+}
+
+// toFloat64 returns the float64 value of an int64, float64, or decimal
+// literal.
+func toFloat64(l *literal.Literal) (float64, error) {
+	switch l.Type() {
+	case literal.Int64:
+		iv, err := l.Int64()
+		return float64(iv), err
+	case literal.Decimal:
+		dv, err := l.Decimal()
+		if err != nil {
+			return 0, err
+		}
+		fv, _ := dv.Float64()
+		return fv, nil
+	}
+	return l.Float64()
+}
+
+// toRat returns the exact value of an int64, float64, or decimal literal. It
+// returns nil for infinite and NaN float64 values.
+func toRat(l *literal.Literal) (*big.Rat, error) {
+	switch l.Type() {
+	case literal.Int64:
+		iv, err := l.Int64()
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Rat).SetInt64(iv), nil
+	case literal.Decimal:
+		return l.Decimal()
+	}
+	fv, err := l.Float64()
+	if err != nil || math.IsInf(fv, 0) || math.IsNaN(fv) {
+		return nil, err
+	}
+	return new(big.Rat).SetFloat64(fv), nil
+}
+
 // sumInt64 implements an accumulator that sum int64 values.
 type sumInt64 struct {
 	initialState int64
@@ -430,7 +702,10 @@ type sumInt64 struct {
 
 // Accumulate takes the given value and accumulates it to the current state.
 func (s *sumInt64) Accumulate(v interface{}) (interface{}, error) {
-	l := v.(*literal.Literal)
+	l, err := toLiteral(v)
+	if err != nil {
+		return s.state, err
+	}
 	iv, err := l.Int64()
 	if err != nil {
 		return s.state, err
@@ -456,9 +731,13 @@ type sumFloat64 struct {
 }
 
 // Accumulate takes the given value and accumulates it to the current state.
+// int64 literals are also accepted and converted to float64.
 func (s *sumFloat64) Accumulate(v interface{}) (interface{}, error) {
-	l := v.(*literal.Literal)
-	iv, err := l.Float64()
+	l, err := toLiteral(v)
+	if err != nil {
+		return s.state, err
+	}
+	iv, err := toFloat64(l)
 	if err != nil {
 		return s.state, err
 	}
@@ -476,6 +755,153 @@ func NewSumFloat64LiteralAccumulator(s float64) Accumulator {
 	return &sumFloat64{s, s}
 }
 
+// sumNumeric implements an accumulator that sums int64, float64, and decimal
+// values. The sum is a float64 if a float64 value was accumulated, a decimal
+// if a decimal value was accumulated, and an int64 otherwise.
+type sumNumeric struct {
+	isFloat   bool
+	isDecimal bool
+	iState    int64
+	fState    float64
+	dState    big.Rat
+}
+
+// Accumulate takes the given value and accumulates it to the current state.
+func (s *sumNumeric) Accumulate(v interface{}) (interface{}, error) {
+	l, err := toLiteral(v)
+	if err != nil {
+		return s.sum(), err
+	}
+	switch l.Type() {
+	case literal.Int64:
+		iv, err := l.Int64()
+		if err != nil {
+			return s.sum(), err
+		}
+		s.iState += iv
+		s.fState += float64(iv)
+		s.dState.Add(&s.dState, new(big.Rat).SetInt64(iv))
+		return s.sum(), nil
+	case literal.Decimal:
+		dv, err := l.Decimal()
+		if err != nil {
+			return s.sum(), err
+		}
+		fv, _ := dv.Float64()
+		s.isDecimal = true
+		s.fState += fv
+		s.dState.Add(&s.dState, dv)
+		return s.sum(), nil
+	}
+	fv, err := l.Float64()
+	if err != nil {
+		return s.sum(), err
+	}
+	s.isFloat = true
+	s.fState += fv
+	return s.sum(), nil
+}
+
+// sum returns the current sum as an int64, a float64, or a *big.Rat.
+func (s *sumNumeric) sum() interface{} {
+	switch {
+	case s.isFloat:
+		return s.fState
+	case s.isDecimal:
+		return new(big.Rat).Set(&s.dState)
+	}
+	return s.iState
+}
+
+// Resets the current state back to the original one.
+func (s *sumNumeric) Reset() {
+	s.isFloat, s.isDecimal, s.iState, s.fState = false, false, 0, 0
+	s.dState.SetInt64(0)
+}
+
+// NewSumNumericLiteralAccumulator accumulates the int64, float64, and decimal
+// types of a literal. Each group sum is a float64 literal if any of the values
+// of the group is a float64 literal, a decimal literal if any of them is a
+// decimal literal, and an int64 literal otherwise. Decimal sums are exact.
+func NewSumNumericLiteralAccumulator() Accumulator {
+	return &sumNumeric{}
+}
+
+// avgDecimalDigits contains the number of fractional digits kept when the
+// average of decimal values has no finite decimal representation.
+const avgDecimalDigits = 32
+
+// avgFloat64 implements an accumulator that averages int64, float64, and
+// decimal values. The average is computed as a float64 unless decimal values
+// and no float64 values were accumulated, in which case it is computed as a
+// decimal.
+type avgFloat64 struct {
+	isFloat   bool
+	isDecimal bool
+	sum       float64
+	dSum      big.Rat
+	cnt       int64
+}
+
+// Accumulate takes the given value and accumulates it to the current state.
+func (a *avgFloat64) Accumulate(v interface{}) (interface{}, error) {
+	l, err := toLiteral(v)
+	if err != nil {
+		return a.avg(), err
+	}
+	fv, err := toFloat64(l)
+	if err != nil {
+		return a.avg(), err
+	}
+	switch l.Type() {
+	case literal.Float64:
+		a.isFloat = true
+	case literal.Decimal:
+		a.isDecimal = true
+	}
+	if !a.isFloat {
+		dv, err := toRat(l)
+		if err != nil {
+			return a.avg(), err
+		}
+		a.dSum.Add(&a.dSum, dv)
+	}
+	a.sum += fv
+	a.cnt++
+	return a.avg(), nil
+}
+
+// avg returns the current average as a float64 or a *big.Rat. Decimal
+// averages without a finite decimal representation are rounded to
+// avgDecimalDigits fractional digits.
+func (a *avgFloat64) avg() interface{} {
+	if a.cnt == 0 {
+		return float64(0)
+	}
+	if a.isFloat || !a.isDecimal {
+		return a.sum / float64(a.cnt)
+	}
+	r := new(big.Rat).Quo(&a.dSum, new(big.Rat).SetInt64(a.cnt))
+	if _, err := literal.DefaultBuilder().Build(literal.Decimal, r); err != nil {
+		// The average has no finite decimal representation.
+		r.SetString(r.FloatString(avgDecimalDigits))
+	}
+	return r
+}
+
+// Resets the current state back to the original one.
+func (a *avgFloat64) Reset() {
+	a.isFloat, a.isDecimal, a.sum, a.cnt = false, false, 0, 0
+	a.dSum.SetInt64(0)
+}
+
+// NewAvgFloat64LiteralAccumulator averages the int64, float64, and decimal
+// types of a literal. Groups that contain decimal literals but no float64
+// literals are averaged exactly as decimals.
+func NewAvgFloat64LiteralAccumulator() Accumulator {
+	return &avgFloat64{}
+}
+
 // countAcc implements an accumulator that count accumulation occurrences.
 type countAcc struct {
 	state int64
@@ -520,6 +946,140 @@ func NewCountDistinctAccumulator() Accumulator {
 	return &countDistinctAcc{make(map[string]int64)}
 }
 
+// cellTime returns the time of a cell holding either a time anchor or a
+// temporal predicate.
+func cellTime(c *Cell) (*time.Time, bool) {
+	if c.T != nil {
+		return c.T, true
+	}
+	if c.P != nil && c.P.Type() == predicate.Temporal {
+		if ta, err := c.P.TimeAnchor(); err == nil {
+			return ta, true
+		}
+	}
+	return nil, false
+}
+
+// CompareCells returns a negative value if a is smaller than b, zero if they
+// are equal, and a positive value otherwise. The ordering is defined as
+// follows: int64, float64, and decimal literals are compared numerically among
+// them, exactly if any of them is a decimal, text literals are compared
+// lexicographically, boolean literals sort false before true, and time anchors
+// and temporal predicates are compared by their time anchor. Any other combination of cells cannot be compared and returns
+// an error.
+func CompareCells(a, b *Cell) (int, error) {
+	if a.L != nil && b.L != nil {
+		ta, tb := a.L.Type(), b.L.Type()
+		isNum := func(t literal.Type) bool {
+			return t == literal.Int64 || t == literal.Float64 || t == literal.Decimal
+		}
+		switch {
+		case ta == literal.Int64 && tb == literal.Int64:
+			va, _ := a.L.Int64()
+			vb, _ := b.L.Int64()
+			switch {
+			case va < vb:
+				return -1, nil
+			case va > vb:
+				return 1, nil
+			}
+			return 0, nil
+		case isNum(ta) && isNum(tb):
+			if ta == literal.Decimal || tb == literal.Decimal {
+				ra, _ := toRat(a.L)
+				rb, _ := toRat(b.L)
+				if ra != nil && rb != nil {
+					return ra.Cmp(rb), nil
+				}
+			}
+			va, _ := toFloat64(a.L)
+			vb, _ := toFloat64(b.L)
+			switch {
+			case va < vb:
+				return -1, nil
+			case va > vb:
+				return 1, nil
+			}
+			return 0, nil
+		case ta == literal.Text && tb == literal.Text:
+			va, _ := a.L.Text()
+			vb, _ := b.L.Text()
+			return strings.Compare(va, vb), nil
+		case ta == literal.Bool && tb == literal.Bool:
+			va, _ := a.L.Bool()
+			vb, _ := b.L.Bool()
+			switch {
+			case va == vb:
+				return 0, nil
+			case vb:
+				return -1, nil
+			}
+			return 1, nil
+		}
+		return 0, fmt.Errorf("cannot compare literals of type %s and %s", ta, tb)
+	}
+	if ta, ok := cellTime(a); ok {
+		if tb, ok := cellTime(b); ok {
+			switch {
+			case ta.Before(*tb):
+				return -1, nil
+			case ta.After(*tb):
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare values %s and %s", a, b)
+}
+
+// minMaxAcc implements an accumulator that keeps the minimum or the maximum
+// cell seen so far.
+type minMaxAcc struct {
+	max   bool
+	state *Cell
+}
+
+// Accumulate takes the given value and accumulates it to the current state.
+func (m *minMaxAcc) Accumulate(v interface{}) (interface{}, error) {
+	c, ok := v.(*Cell)
+	if !ok {
+		return m.state, fmt.Errorf("min and max accumulators require cells, got %v instead", v)
+	}
+	if c == nil || (c.S == nil && c.N == nil && c.P == nil && c.L == nil && c.T == nil) {
+		// Unbound values are ignored.
+		return m.state, nil
+	}
+	if m.state == nil {
+		m.state = c
+		return m.state, nil
+	}
+	cmp, err := compareValues(c, m.state)
+	if err != nil {
+		return m.state, err
+	}
+	if (m.max && cmp > 0) || (!m.max && cmp < 0) {
+		m.state = c
+	}
+	return m.state, nil
+}
+
+// Resets the current state back to the original one.
+func (m *minMaxAcc) Reset() {
+	m.state = nil
+}
+
+// NewMinAccumulator keeps the smallest cell accumulated. Literals are ordered
+// as defined by literal.Literal.Compare, and other cells by CompareCells.
+func NewMinAccumulator() Accumulator {
+	return &minMaxAcc{max: false}
+}
+
+// NewMaxAccumulator keeps the biggest cell accumulated. Literals are ordered
+// as defined by literal.Literal.Compare, and other cells by CompareCells.
+func NewMaxAccumulator() Accumulator {
+	return &minMaxAcc{max: true}
+}
+
 // groupRangeReduce takes a sorted range and generates a new row containing
 // the aggregated columns and the non aggregated ones.
 func (t *Table) groupRangeReduce(i, j int, alias map[string]string, acc map[string]Accumulator) (Row, error) {
@@ -559,7 +1119,7 @@ func (t *Table) groupRangeReduce(i, j int, alias map[string]string, acc map[stri
 			if !ok {
 				return nil, fmt.Errorf("aggregated bindings require and alias; binding %s missing alias", b)
 			}
-			// Accumulators currently only can return numeric literals.
+			// Accumulators currently only can return numeric literals or cells.
 			switch acc.(type) {
 			case int64:
 				l, err := literal.DefaultBuilder().Build(literal.Int64, acc)
@@ -573,6 +1133,18 @@ func (t *Table) groupRangeReduce(i, j int, alias map[string]string, acc map[stri
 					return nil, err
 				}
 				newRow[a] = &Cell{L: l}
+			case *big.Rat:
+				l, err := literal.DefaultBuilder().Build(literal.Decimal, acc)
+				if err != nil {
+					return nil, err
+				}
+				newRow[a] = &Cell{L: l}
+			case *Cell:
+				if c := acc.(*Cell); c != nil {
+					newRow[a] = c
+				} else {
+					newRow[a] = &Cell{}
+				}
 			default:
 				return nil, fmt.Errorf("aggregation of binding %s returned unknown value %v or type", b, acc)
 			}
@@ -631,23 +1203,11 @@ func (t *Table) fullGroupRangeReduce(i, j int, acc map[string]map[string]AliasAc
 			if app.Acc == nil {
 				newRow[app.OutAlias] = v
 			} else {
-				// Accumulators currently only can return numeric literals.
-				switch vaccs[app.InAlias][app.OutAlias].(type) {
-				case int64:
-					l, err := literal.DefaultBuilder().Build(literal.Int64, vaccs[app.InAlias][app.OutAlias])
-					if err != nil {
-						return nil, err
-					}
-					newRow[app.OutAlias] = &Cell{L: l}
-				case float64:
-					l, err := literal.DefaultBuilder().Build(literal.Float64, vaccs[app.InAlias][app.OutAlias])
-					if err != nil {
-						return nil, err
-					}
-					newRow[app.OutAlias] = &Cell{L: l}
-				default:
-					return nil, fmt.Errorf("aggregation of binding %s returned unknown value %v or type", b, acc)
+				c, err := accumulatedCell(vaccs[app.InAlias][app.OutAlias])
+				if err != nil {
+					return nil, fmt.Errorf("aggregation of binding %s failed: %v", b, err)
 				}
+				newRow[app.OutAlias] = c
 			}
 		}
 	}
@@ -657,6 +1217,38 @@ func (t *Table) fullGroupRangeReduce(i, j int, acc map[string]map[string]AliasAc
 	return newRow, nil
 }
 
+// accumulatedCell returns the cell for the provided accumulated value.
+func accumulatedCell(v interface{}) (*Cell, error) {
+	// Accumulators currently only can return numeric literals or cells.
+	switch av := v.(type) {
+	case int64:
+		l, err := literal.DefaultBuilder().Build(literal.Int64, av)
+		if err != nil {
+			return nil, err
+		}
+		return &Cell{L: l}, nil
+	case float64:
+		l, err := literal.DefaultBuilder().Build(literal.Float64, av)
+		if err != nil {
+			return nil, err
+		}
+		return &Cell{L: l}, nil
+	case *big.Rat:
+		l, err := literal.DefaultBuilder().Build(literal.Decimal, av)
+		if err != nil {
+			return nil, err
+		}
+		return &Cell{L: l}, nil
+	case *Cell:
+		if av != nil {
+			return av, nil
+		}
+		return &Cell{}, nil
+	default:
+		return nil, fmt.Errorf("unknown accumulated value %v or type", v)
+	}
+}
+
 // toMap converts a list of alias and acc pairs into a nested map. The first
 // key is the input binding, the second one is the output binding.
 func toMap(aaps []AliasAccPair) map[string]map[string]AliasAccPair {
@@ -707,7 +1299,10 @@ func (t *Table) Reduce(cfg SortConfig, aaps []AliasAccPair) error {
 	id := func(r Row) string {
 		res := bytes.NewBufferString("")
 		for _, c := range cfg {
-			res.WriteString(r[c.Binding].String())
+			// Unbound values are grouped together.
+			if v := r[c.Binding]; !isUnbound(v) {
+				res.WriteString(v.String())
+			}
 			res.WriteString(";")
 		}
 		return res.String()
@@ -748,6 +1343,56 @@ func (t *Table) Reduce(cfg SortConfig, aaps []AliasAccPair) error {
 	return nil
 }
 
+// RunningAccumulate sorts the table by the partition bindings and the provided
+// sort configuration and adds the running aggregation of the aap input binding
+// as its output binding. The running aggregation of a row accumulates all the
+// rows of its partition up to the row itself; the accumulator is reset at the
+// start of each partition.
+func (t *Table) RunningAccumulate(partition []string, cfg SortConfig, aap AliasAccPair) error {
+	if !t.mbs[aap.InAlias] {
+		return fmt.Errorf("table.RunningAccumulate unknown binding %q; available bindings %v", aap.InAlias, t.AvailableBindings)
+	}
+	scfg := SortConfig{}
+	for _, b := range partition {
+		if !t.mbs[b] {
+			return fmt.Errorf("table.RunningAccumulate unknown partition binding %q; available bindings %v", b, t.AvailableBindings)
+		}
+		scfg = append(scfg, SortConfig{{Binding: b}}...)
+	}
+	for _, c := range cfg {
+		if !t.mbs[c.Binding] {
+			return fmt.Errorf("table.RunningAccumulate unknown order binding %q; available bindings %v", c.Binding, t.AvailableBindings)
+		}
+	}
+	t.Sort(append(scfg, cfg...))
+	id := func(r Row) string {
+		res := bytes.NewBufferString("")
+		for _, b := range partition {
+			res.WriteString(r[b].String())
+			res.WriteString(";")
+		}
+		return res.String()
+	}
+	last := ""
+	for idx, r := range t.Data {
+		if current := id(r); idx == 0 || current != last {
+			aap.Acc.Reset()
+			last = current
+		}
+		av, err := aap.Acc.Accumulate(r[aap.InAlias])
+		if err != nil {
+			return err
+		}
+		c, err := accumulatedCell(av)
+		if err != nil {
+			return fmt.Errorf("running aggregation of binding %s failed: %v", aap.InAlias, err)
+		}
+		r[aap.OutAlias] = c
+	}
+	t.AddBindings([]string{aap.OutAlias})
+	return nil
+}
+
 // Filter removes all the rows where the provided function returns true.
 func (t *Table) Filter(f func(Row) bool) {
 	var newData []Row
@@ -759,6 +1404,37 @@ func (t *Table) Filter(f func(Row) bool) {
 	t.Data = newData
 }
 
+// Distinct removes the duplicated rows of the table. Two rows are duplicated
+// if all the cells of the available bindings box the same kind of value with
+// the same serialization; hence, literals with the same type and value are
+// equal. The first occurrence of each row is kept, preserving the relative
+// order of the remaining rows.
+func (t *Table) Distinct() {
+	var (
+		newData []Row
+		key     bytes.Buffer
+	)
+	seen := make(map[string]bool)
+	for _, r := range t.Data {
+		key.Reset()
+		for _, b := range t.AvailableBindings {
+			c := r[b]
+			k, _ := c.Kind()
+			key.WriteString(k)
+			key.WriteByte(0)
+			if c != nil {
+				key.WriteString(c.String())
+			}
+			key.WriteByte(0)
+		}
+		if k := key.String(); !seen[k] {
+			seen[k] = true
+			newData = append(newData, r)
+		}
+	}
+	t.Data = newData
+}
+
 // ToText convert the table into a readable text versions. It requires the
 // separator to be used between cells.
 func (t *Table) ToText(sep string) (*bytes.Buffer, error) {
@@ -781,6 +1457,118 @@ func (t *Table) ToText(sep string) (*bytes.Buffer, error) {
 	return res, nil
 }
 
+// ToCSV writes the table as RFC 4180 comma separated values. The first line
+// contains the bindings of the table, and each row is written on its own line
+// using the readable representation of its cells. Lines end in CRLF as RFC
+// 4180 requires. Time anchors are written in RFC3339 and unbound cells are
+// written as empty fields.
+func (t *Table) ToCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	if err := cw.Write(t.AvailableBindings); err != nil {
+		return err
+	}
+	rec := make([]string, len(t.AvailableBindings))
+	for _, r := range t.Data {
+		for i, b := range t.AvailableBindings {
+			rec[i] = ""
+			if c, ok := r[b]; ok && c != nil && !c.isEmpty() {
+				rec[i] = c.String()
+			}
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Hash returns a SHA-256 digest of the bindings and rows of the table. Tables
+// with the same bindings and the same rows in the same order share the hash.
+func (t *Table) Hash() ([]byte, error) {
+	b, err := t.ToText("\t")
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256(b.Bytes())
+	return h[:], nil
+}
+
+// DefaultCellWidth is the default maximum number of characters of the cells
+// rendered by ToGrid.
+const DefaultCellWidth = 40
+
+// gridCell returns the text of the cell as rendered on a grid. Unbound cells
+// are empty, control characters are escaped to keep the row on a single line,
+// and values longer than width characters are truncated ending with an
+// ellipsis. Non positive widths do not truncate the value.
+func gridCell(c *Cell, width int) string {
+	v := ""
+	if c != nil && !c.isEmpty() {
+		v = strings.NewReplacer("\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(c.String())
+	}
+	rs := []rune(v)
+	if width <= 0 || len(rs) <= width {
+		return v
+	}
+	if width < 4 {
+		return string(rs[:width])
+	}
+	return string(rs[:width-3]) + "..."
+}
+
+// ToGrid renders the table as an ASCII grid with the bindings of the table as
+// the header and the values of each binding aligned in a column. Cells longer
+// than width characters are truncated ending with an ellipsis; non positive
+// widths render the full values. Tables without bindings render as an empty
+// string.
+func (t *Table) ToGrid(width int) string {
+	bs := t.AvailableBindings
+	if len(bs) == 0 {
+		return ""
+	}
+	ws := make([]int, len(bs))
+	for i, b := range bs {
+		ws[i] = utf8.RuneCountInString(b)
+	}
+	vs := make([][]string, len(t.Data))
+	for i, r := range t.Data {
+		vs[i] = make([]string, len(bs))
+		for j, b := range bs {
+			v := gridCell(r[b], width)
+			if n := utf8.RuneCountInString(v); n > ws[j] {
+				ws[j] = n
+			}
+			vs[i][j] = v
+		}
+	}
+	res := &bytes.Buffer{}
+	border := func() {
+		for _, w := range ws {
+			res.WriteString("+")
+			res.WriteString(strings.Repeat("-", w+2))
+		}
+		res.WriteString("+\n")
+	}
+	line := func(cs []string) {
+		for i, c := range cs {
+			res.WriteString("| ")
+			res.WriteString(c)
+			res.WriteString(strings.Repeat(" ", ws[i]-utf8.RuneCountInString(c)+1))
+		}
+		res.WriteString("|\n")
+	}
+	border()
+	line(bs)
+	border()
+	for _, r := range vs {
+		line(r)
+	}
+	border()
+	return res.String()
+}
+
 // String attempts to force serialize the table into a string.
 func (t *Table) String() string {
 	b, err := t.ToText("\t")
@@ -790,66 +1578,220 @@ func (t *Table) String() string {
 	return b.String()
 }
 
-// ToJSON convert the table intotext versions. It requires the
-// separator to be used between cells JSON.
-func (t *Table) ToJSON(w io.Writer) {
-	w.Write([]byte(`{ "bindings": [`))
+// MixedKind is the kind reported for columns that contain values of different
+// kinds across rows.
+const MixedKind = "mixed"
+
+// Kind returns the kind of value boxed in the cell. The kinds use the same
+// names used as keys on the JSON serialization of a cell. Literals also
+// return the literal type as subtype.
+func (c *Cell) Kind() (string, string) {
+	switch {
+	case c == nil:
+		return "", ""
+	case c.S != nil:
+		return "string", ""
+	case c.N != nil:
+		return "node", ""
+	case c.P != nil:
+		return "pred", ""
+	case c.L != nil:
+		return "lit", c.L.Type().String()
+	case c.T != nil:
+		return "anchor", ""
+	}
+	return "", ""
+}
 
-	if len(t.AvailableBindings) > 0 {
-		w.Write([]byte(`"`))
-		w.Write([]byte(strings.Join(t.AvailableBindings, `", "`)))
-		w.Write([]byte(`"`))
+// ColumnKind infers the kind of the values bound to the provided binding based
+// on the actual values available on the table. If rows contain values of
+// different kinds, or literals of different types, MixedKind is returned as
+// kind and the subtype is empty. Unbound cells are ignored.
+func (t *Table) ColumnKind(b string) (string, string) {
+	kind, sub, first := "", "", true
+	for _, r := range t.Data {
+		c, ok := r[b]
+		if !ok || c == nil {
+			continue
+		}
+		k, st := c.Kind()
+		if first {
+			kind, sub, first = k, st, false
+			continue
+		}
+		if k != kind {
+			return MixedKind, ""
+		}
+		if st != sub {
+			sub = MixedKind
+		}
 	}
+	return kind, sub
+}
 
-	w.Write([]byte(`], "rows": [`))
+// ToJSON serializes the table into JSON. The serialization is an object with
+// a "bindings" array listing the available bindings and a "rows" array. Each
+// row maps the binding names to typed cells such as
+//
+//	{"type": "node", "value": "/u<joe>"}
+//
+// where the type is the kind of value boxed in the cell as returned by Kind.
+// Temporal predicate cells also provide the "anchor" time. Unbound cells are
+// serialized as null.
+func (t *Table) ToJSON(w io.Writer) error {
+	return t.toJSON(w, false)
+}
 
-	rc := len(t.Data)
-	for _, r := range t.Data {
-		if len(r) > 0 {
-			w.Write([]byte(`{ `))
-
-			cc := len(t.AvailableBindings)
-			for _, k := range t.AvailableBindings {
-				if k != "" {
-					c := r[k]
-					w.Write([]byte(`"`))
-					w.Write([]byte(k))
-					w.Write([]byte(`": {"`))
-
-					if c.S != nil {
-						w.Write([]byte(`string": "`))
-						w.Write([]byte(strings.Replace(*c.S, `"`, `\"`, -1)))
-					} else if c.N != nil {
-						w.Write([]byte(`node": "`))
-						w.Write([]byte(strings.Replace(c.N.String(), `"`, `\"`, -1)))
-					} else if c.P != nil {
-						w.Write([]byte(`pred": "`))
-						w.Write([]byte(strings.Replace(c.P.String(), `"`, `\"`, -1)))
-
-					} else if c.L != nil {
-						w.Write([]byte(`lit": "`))
-						w.Write([]byte(strings.Replace(c.L.String(), `"`, `\"`, -1)))
-
-					} else if c.T != nil {
-						w.Write([]byte(`anchor": "`))
-						w.Write([]byte(strings.Replace(c.T.Format(time.RFC3339Nano), `"`, `\"`, -1)))
-					}
-
-					w.Write([]byte(`"}`))
-					if cc > 1 {
-						w.Write([]byte(`,`))
-					}
-					w.Write([]byte(` `))
-				}
-				cc--
+// ToJSONWithMetadata works like ToJSON, but it also adds a metadata header
+// describing the inferred kind of each column and the number of rows in the
+// table.
+func (t *Table) ToJSONWithMetadata(w io.Writer) error {
+	return t.toJSON(w, true)
+}
+
+// jsonCell contains the JSON serialization of a cell.
+type jsonCell struct {
+	Type   string `json:"type"`
+	Value  string `json:"value"`
+	Anchor string `json:"anchor,omitempty"`
+}
+
+// toJSONCell returns the JSON serialization of the cell. Unbound cells
+// return nil.
+func toJSONCell(c *Cell) (*jsonCell, error) {
+	k, _ := c.Kind()
+	switch k {
+	case "string":
+		return &jsonCell{Type: k, Value: *c.S}, nil
+	case "node":
+		return &jsonCell{Type: k, Value: c.N.String()}, nil
+	case "pred":
+		jc := &jsonCell{Type: k, Value: c.P.String()}
+		if c.P.Type() == predicate.Temporal {
+			ta, err := c.P.TimeAnchor()
+			if err != nil {
+				return nil, err
 			}
-			w.Write([]byte(` }`))
-			if rc > 1 {
-				w.Write([]byte(`, `))
+			jc.Anchor = ta.Format(time.RFC3339Nano)
+		}
+		return jc, nil
+	case "lit":
+		return &jsonCell{Type: k, Value: c.L.String()}, nil
+	case "anchor":
+		return &jsonCell{Type: k, Value: c.T.Format(time.RFC3339Nano)}, nil
+	}
+	return nil, nil
+}
+
+// writeJSONMetadata writes the metadata header for the table.
+func (t *Table) writeJSONMetadata(b *bytes.Buffer) error {
+	b.WriteString(`"metadata": { "rows": `)
+	b.WriteString(strconv.Itoa(len(t.Data)))
+	b.WriteString(`, "columns": [`)
+	for i, bnd := range t.AvailableBindings {
+		if i > 0 {
+			b.WriteString(`, `)
+		}
+		k, st := t.ColumnKind(bnd)
+		b.WriteString(`{ "binding": `)
+		if err := writeJSONValue(b, bnd); err != nil {
+			return err
+		}
+		b.WriteString(`, "kind": `)
+		if err := writeJSONValue(b, k); err != nil {
+			return err
+		}
+		if st != "" {
+			b.WriteString(`, "type": `)
+			if err := writeJSONValue(b, st); err != nil {
+				return err
 			}
 		}
-		rc--
+		b.WriteString(` }`)
+	}
+	b.WriteString(`] }, `)
+	return nil
+}
+
+// writeJSONValue writes the JSON encoding of the provided value. HTML
+// characters are not escaped since they are common in node and predicate
+// values.
+func writeJSONValue(b *bytes.Buffer, v interface{}) error {
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	// Encode terminates each value with a newline.
+	b.Truncate(b.Len() - 1)
+	return nil
+}
+
+// toJSON serializes the table into JSON optionally adding the metadata header.
+// The whole table is serialized before writing it, hence nothing is written
+// if the table cannot be serialized.
+func (t *Table) toJSON(w io.Writer, metadata bool) error {
+	var b bytes.Buffer
+	b.WriteString(`{ `)
+	if metadata {
+		if err := t.writeJSONMetadata(&b); err != nil {
+			return err
+		}
+	}
+	b.WriteString(`"bindings": `)
+	bnds := t.AvailableBindings
+	if bnds == nil {
+		bnds = []string{}
+	}
+	if err := writeJSONValue(&b, bnds); err != nil {
+		return err
+	}
+	b.WriteString(`, "rows": [`)
+	for i, r := range t.Data {
+		if i > 0 {
+			b.WriteString(`, `)
+		}
+		if err := writeJSONRow(&b, t.AvailableBindings, r); err != nil {
+			return fmt.Errorf("table.ToJSON %v", err)
+		}
+	}
+	b.WriteString(`] }`)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// ToJSON serializes the provided bindings of the row into a JSON object
+// mapping each binding to its typed cell, as Table.ToJSON does for each row of
+// a table. Bindings missing from the row are serialized as null.
+func (r Row) ToJSON(w io.Writer, bs []string) error {
+	var b bytes.Buffer
+	if err := writeJSONRow(&b, bs, r); err != nil {
+		return fmt.Errorf("table.Row.ToJSON %v", err)
 	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
 
-	w.Write([]byte(`] }`))
+// writeJSONRow writes the JSON object mapping the provided bindings to the
+// typed cells of the row.
+func writeJSONRow(b *bytes.Buffer, bindings []string, r Row) error {
+	b.WriteString(`{ `)
+	for j, k := range bindings {
+		if j > 0 {
+			b.WriteString(`, `)
+		}
+		jc, err := toJSONCell(r[k])
+		if err != nil {
+			return fmt.Errorf("failed to serialize binding %q with error %v", k, err)
+		}
+		if err := writeJSONValue(b, k); err != nil {
+			return err
+		}
+		b.WriteString(`: `)
+		if err := writeJSONValue(b, jc); err != nil {
+			return err
+		}
+	}
+	b.WriteString(` }`)
+	return nil
 }
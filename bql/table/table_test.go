@@ -16,6 +16,8 @@ package table
 
 import (
 	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
@@ -23,6 +25,8 @@ import (
 	"testing"
 	"time"
 
+	"golang.org/x/net/context"
+
 	"github.com/google/badwolf/triple/literal"
 	"github.com/google/badwolf/triple/node"
 	"github.com/google/badwolf/triple/predicate"
@@ -170,6 +174,86 @@ func TestTableToText(t *testing.T) {
 	}
 }
 
+func TestTableToCSV(t *testing.T) {
+	n, err := node.Parse("/u<john>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := predicate.Parse(`"bought"@[2016-01-01T00:00:00-08:00]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := literal.DefaultBuilder().Build(literal.Text, `a, "quoted" text`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	tbl, err := New([]string{"?n", "?p", "?l", "?t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.AddRow(Row{"?n": &Cell{N: n}, "?p": &Cell{P: p}, "?l": &Cell{L: l}, "?t": &Cell{T: &tm}})
+	tbl.AddRow(Row{"?n": &Cell{N: n}, "?p": &Cell{}})
+	var b bytes.Buffer
+	if err := tbl.ToCSV(&b); err != nil {
+		t.Fatalf("tbl.ToCSV failed with error %v", err)
+	}
+	want := "?n,?p,?l,?t\r\n" +
+		`/u<john>,"""bought""@[2016-01-01T00:00:00-08:00]","""a, ""quoted"" text""^^type:text",2016-01-01T00:00:00Z` + "\r\n" +
+		"/u<john>,,,\r\n"
+	if got := b.String(); got != want {
+		t.Errorf("tbl.ToCSV failed to serialize the table;\nGot:\n%s\nWant:\n%s", got, want)
+	}
+	recs, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatalf("csv.ReadAll failed to read the serialized table with error %v", err)
+	}
+	if got, want := recs[1], []string{n.String(), p.String(), l.String(), tm.Format(time.RFC3339)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tbl.ToCSV did not round trip the row; got %q, want %q", got, want)
+	}
+}
+
+func TestTableToGrid(t *testing.T) {
+	n, err := node.Parse("/room<Fire Escape>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	tbl, err := New([]string{"?room", "?t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.AddRow(Row{"?room": &Cell{N: n}, "?t": &Cell{T: &tm}})
+	tbl.AddRow(Row{"?room": &Cell{S: CellString("a\nb")}})
+	want := `+--------------------+----------------------+
+| ?room              | ?t                   |
++--------------------+----------------------+
+| /room<Fire Escape> | 2016-01-01T00:00:00Z |
+| a\nb               |                      |
++--------------------+----------------------+
+`
+	if got := tbl.ToGrid(0); got != want {
+		t.Errorf("tbl.ToGrid(0) failed to render the table;\nGot:\n%s\nWant:\n%s", got, want)
+	}
+	want = `+------------+------------+
+| ?room      | ?t         |
++------------+------------+
+| /room<F... | 2016-01... |
+| a\nb       |            |
++------------+------------+
+`
+	if got := tbl.ToGrid(10); got != want {
+		t.Errorf("tbl.ToGrid(10) failed to render the table;\nGot:\n%s\nWant:\n%s", got, want)
+	}
+	empty, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := empty.ToGrid(10); got != "" {
+		t.Errorf("tbl.ToGrid(10) should render tables without bindings as empty; got %q", got)
+	}
+}
+
 func TestEqualBindings(t *testing.T) {
 	testTable := []struct {
 		b1   map[string]bool
@@ -455,6 +539,51 @@ func TestDotProductContent(t *testing.T) {
 	}
 }
 
+func TestDotProductContextCanceled(t *testing.T) {
+	t1, t2 := testDotTable(t, []string{"?foo"}, 3), testDotTable(t, []string{"?bar"}, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := t1.DotProductContext(ctx, t2); err != context.Canceled {
+		t.Errorf("DotProductContext returned error %v for a canceled context; want %v", err, context.Canceled)
+	}
+	if got, want := len(t1.Rows()), 3; got != want {
+		t.Errorf("DotProductContext should have left the rows unchanged; got %d rows, want %d", got, want)
+	}
+	if got, want := t1.Bindings(), []string{"?foo"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DotProductContext should have left the bindings unchanged; got %v, want %v", got, want)
+	}
+}
+
+func TestDotProductConcurrently(t *testing.T) {
+	// Enough rows to be partitioned among several workers.
+	want, t2 := testDotTable(t, []string{"?foo"}, 50), testDotTable(t, []string{"?bar"}, 300)
+	if err := want.DotProduct(t2); err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{0, 1, 3, 7, 100} {
+		got := testDotTable(t, []string{"?foo"}, 50)
+		if err := got.DotProductConcurrently(context.Background(), t2, workers); err != nil {
+			t.Errorf("DotProductConcurrently with %d workers failed with error %v", workers, err)
+			continue
+		}
+		if !reflect.DeepEqual(got.Rows(), want.Rows()) {
+			t.Errorf("DotProductConcurrently with %d workers returned different rows than DotProduct", workers)
+		}
+		if got, want := len(got.Bindings()), 2; got != want {
+			t.Errorf("DotProductConcurrently with %d workers returned %d bindings; want %d", workers, got, want)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	t1 := testDotTable(t, []string{"?foo"}, 50)
+	if err := t1.DotProductConcurrently(ctx, t2, 4); err != context.Canceled {
+		t.Errorf("DotProductConcurrently returned error %v for a canceled context; want %v", err, context.Canceled)
+	}
+	if got, want := len(t1.Rows()), 50; got != want {
+		t.Errorf("DotProductConcurrently should have left the rows unchanged; got %d rows, want %d", got, want)
+	}
+}
+
 func TestDeleteRow(t *testing.T) {
 	testTable := []struct {
 		t   *Table
@@ -534,6 +663,29 @@ func Limit(t *testing.T) {
 	}
 }
 
+func TestOffset(t *testing.T) {
+	testTable := []struct {
+		in   int64
+		want int
+	}{
+		{0, 3},
+		{1, 2},
+		{3, 0},
+		{100, 0},
+	}
+	for _, entry := range testTable {
+		tbl := testDotTable(t, []string{"?foo"}, 3)
+		first := tbl.Rows()[len(tbl.Rows())-entry.want:]
+		tbl.Offset(entry.in)
+		if got, want := len(tbl.Rows()), entry.want; got != want {
+			t.Errorf("Failed to offset a table by %d; got %d rows, want %d", entry.in, got, want)
+		}
+		if entry.want > 0 && !reflect.DeepEqual(tbl.Rows(), first) {
+			t.Errorf("Failed to offset a table by %d; got %v, want %v", entry.in, tbl.Rows(), first)
+		}
+	}
+}
+
 func TestStringLess(t *testing.T) {
 	testTable := []struct {
 		i    string
@@ -661,6 +813,183 @@ func TestSumAccumulators(t *testing.T) {
 	}
 }
 
+func TestAvgAccumulator(t *testing.T) {
+	var (
+		av interface{}
+		aa = NewAvgFloat64LiteralAccumulator()
+	)
+	for i := int64(0); i < 4; i++ {
+		l, _ := literal.DefaultBuilder().Build(literal.Int64, i)
+		av, _ = aa.Accumulate(&Cell{L: l})
+	}
+	if got, want := av.(float64), float64(1.5); got != want {
+		t.Errorf("Avg accumulator failed; got %f, want %f", got, want)
+	}
+	l, _ := literal.DefaultBuilder().Build(literal.Text, "foo")
+	if _, err := aa.Accumulate(&Cell{L: l}); err == nil {
+		t.Errorf("Avg accumulator should have failed to accumulate a text literal")
+	}
+	aa.Reset()
+	l, _ = literal.DefaultBuilder().Build(literal.Float64, float64(2.5))
+	if av, _ = aa.Accumulate(l); av.(float64) != 2.5 {
+		t.Errorf("Avg accumulator failed to reset; got %v, want 2.5", av)
+	}
+}
+
+func TestSumNumericAccumulator(t *testing.T) {
+	var (
+		sv interface{}
+		sa = NewSumNumericLiteralAccumulator()
+	)
+	for i := int64(0); i < 5; i++ {
+		l, _ := literal.DefaultBuilder().Build(literal.Int64, i)
+		sv, _ = sa.Accumulate(&Cell{L: l})
+	}
+	if got, want := sv.(int64), int64(10); got != want {
+		t.Errorf("Sum numeric accumulator failed; got %d, want %d", got, want)
+	}
+	l, _ := literal.DefaultBuilder().Build(literal.Float64, float64(0.5))
+	if sv, _ = sa.Accumulate(l); sv.(float64) != 10.5 {
+		t.Errorf("Sum numeric accumulator failed to switch to float64; got %v, want 10.5", sv)
+	}
+	sa.Reset()
+	l, _ = literal.DefaultBuilder().Build(literal.Int64, int64(2))
+	if sv, _ = sa.Accumulate(l); sv.(int64) != 2 {
+		t.Errorf("Sum numeric accumulator failed to reset; got %v, want 2", sv)
+	}
+}
+
+func TestDecimalAccumulators(t *testing.T) {
+	dl := func(v string) *literal.Literal {
+		l, err := literal.DefaultBuilder().Parse(`"` + v + `"^^type:decimal`)
+		if err != nil {
+			t.Fatalf("literal.Parse failed to parse decimal %q with error %v", v, err)
+		}
+		return l
+	}
+	asDecimal := func(v interface{}) string {
+		c, err := accumulatedCell(v)
+		if err != nil {
+			t.Fatalf("accumulatedCell(%v) failed with error %v", v, err)
+		}
+		return c.String()
+	}
+	var (
+		sv, av interface{}
+		sa     = NewSumNumericLiteralAccumulator()
+		aa     = NewAvgFloat64LiteralAccumulator()
+	)
+	for i := 0; i < 10; i++ {
+		sv, _ = sa.Accumulate(&Cell{L: dl("0.1")})
+		av, _ = aa.Accumulate(&Cell{L: dl("0.1")})
+	}
+	if got, want := asDecimal(sv), `"1"^^type:decimal`; got != want {
+		t.Errorf("Sum numeric accumulator failed to add decimals exactly; got %s, want %s", got, want)
+	}
+	if got, want := asDecimal(av), `"0.1"^^type:decimal`; got != want {
+		t.Errorf("Avg accumulator failed to average decimals exactly; got %s, want %s", got, want)
+	}
+	l, _ := literal.DefaultBuilder().Build(literal.Int64, int64(2))
+	if sv, _ = sa.Accumulate(l); asDecimal(sv) != `"3"^^type:decimal` {
+		t.Errorf("Sum numeric accumulator failed to add an int64 to a decimal sum; got %v, want 3", sv)
+	}
+	l, _ = literal.DefaultBuilder().Build(literal.Float64, float64(0.5))
+	if sv, _ = sa.Accumulate(l); sv.(float64) != 3.5 {
+		t.Errorf("Sum numeric accumulator failed to switch to float64; got %v, want 3.5", sv)
+	}
+	aa.Reset()
+	for _, v := range []string{"1", "1", "2"} {
+		av, _ = aa.Accumulate(dl(v))
+	}
+	if got, want := asDecimal(av), `"1.33333333333333333333333333333333"^^type:decimal`; got != want {
+		t.Errorf("Avg accumulator failed to round a decimal average; got %s, want %s", got, want)
+	}
+}
+
+func TestSortDecimals(t *testing.T) {
+	tbl, err := New([]string{"?d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"10", "-2.5", "0.10", "-10", "2", "0.05"} {
+		l, err := literal.DefaultBuilder().Parse(`"` + v + `"^^type:decimal`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tbl.AddRow(Row{"?d": &Cell{L: l}})
+	}
+	for _, desc := range []bool{false, true} {
+		tbl.Sort(SortConfig{{"?d", desc}})
+		var got []string
+		for _, r := range tbl.Rows() {
+			got = append(got, strings.TrimSuffix(r["?d"].String(), "^^type:decimal"))
+		}
+		want := []string{`"-10"`, `"-2.5"`, `"0.05"`, `"0.1"`, `"2"`, `"10"`}
+		if desc {
+			for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+				want[i], want[j] = want[j], want[i]
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("table.Sort(desc=%v) sorted decimals as %v; want %v", desc, got, want)
+		}
+	}
+}
+
+func TestSortNumbersAndTimes(t *testing.T) {
+	tbl, err := New([]string{"?n", "?t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range []string{`"9"^^type:int64`, `"10"^^type:int64`, `"-2"^^type:int64`, `"-10"^^type:int64`, `"2.5"^^type:float64`, `"-0.5"^^type:float64`} {
+		l, err := literal.DefaultBuilder().Parse(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Times in different zones and with fractional seconds.
+		ts := time.Date(2016, 1, 1, 0, 0, i, i%2*5e8, time.FixedZone("", (i%3-1)*3600))
+		tbl.AddRow(Row{"?n": &Cell{L: l}, "?t": &Cell{T: &ts}})
+	}
+	tbl.Sort(SortConfig{{"?n", false}})
+	var got []string
+	for _, r := range tbl.Rows() {
+		got = append(got, r["?n"].String())
+	}
+	want := []string{`"-10"^^type:int64`, `"-2"^^type:int64`, `"-0.5"^^type:float64`, `"2.5"^^type:float64`, `"9"^^type:int64`, `"10"^^type:int64`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("table.Sort sorted numbers as %v; want %v", got, want)
+	}
+	tbl.Sort(SortConfig{{"?t", true}})
+	for i, r := range tbl.Rows()[1:] {
+		if prev := tbl.Rows()[i]["?t"].T; !prev.After(*r["?t"].T) {
+			t.Errorf("table.Sort(desc) sorted time %v before %v", prev, r["?t"].T)
+		}
+	}
+}
+
+func TestSortMixedLiterals(t *testing.T) {
+	tbl, err := New([]string{"?o"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{`"b"^^type:text`, `"2.5"^^type:float64`, `"true"^^type:bool`, `"a"^^type:text`, `"3"^^type:int64`, `"false"^^type:bool`, `"2.5"^^type:decimal`} {
+		l, err := literal.DefaultBuilder().Parse(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tbl.AddRow(Row{"?o": &Cell{L: l}})
+	}
+	tbl.Sort(SortConfig{{"?o", false}})
+	var got []string
+	for _, r := range tbl.Rows() {
+		got = append(got, r["?o"].String())
+	}
+	want := []string{`"false"^^type:bool`, `"true"^^type:bool`, `"2.5"^^type:float64`, `"2.5"^^type:decimal`, `"3"^^type:int64`, `"a"^^type:text`, `"b"^^type:text`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("table.Sort sorted literals as %v; want %v", got, want)
+	}
+}
+
 func TestCountAccumulators(t *testing.T) {
 	// Count accumulator.
 	var (
@@ -687,6 +1016,94 @@ func TestCountAccumulators(t *testing.T) {
 	}
 }
 
+func TestCompareCells(t *testing.T) {
+	lc := func(t literal.Type, v interface{}) *Cell {
+		l, _ := literal.DefaultBuilder().Build(t, v)
+		return &Cell{L: l}
+	}
+	dc := func(v string) *Cell {
+		l, _ := literal.DefaultBuilder().Parse(`"` + v + `"^^type:decimal`)
+		return &Cell{L: l}
+	}
+	t1, t2 := time.Unix(0, 0), time.Unix(1, 0)
+	p1, _ := predicate.NewTemporal("foo", t1)
+	n, _ := node.Parse("/foo<bar>")
+	testTable := []struct {
+		a, b *Cell
+		want int
+		err  bool
+	}{
+		{a: lc(literal.Int64, int64(1)), b: lc(literal.Int64, int64(2)), want: -1},
+		{a: lc(literal.Int64, int64(2)), b: lc(literal.Float64, float64(1.5)), want: 1},
+		{a: lc(literal.Float64, float64(1)), b: lc(literal.Int64, int64(1)), want: 0},
+		{a: lc(literal.Text, "abc"), b: lc(literal.Text, "abd"), want: -1},
+		{a: lc(literal.Bool, true), b: lc(literal.Bool, false), want: 1},
+		{a: &Cell{T: &t2}, b: &Cell{T: &t1}, want: 1},
+		{a: &Cell{P: p1}, b: &Cell{T: &t2}, want: -1},
+		{a: dc("1.0"), b: dc("1.00"), want: 0},
+		{a: dc("0.3"), b: dc("0.30000000000000000001"), want: -1},
+		{a: dc("2.5"), b: lc(literal.Int64, int64(2)), want: 1},
+		{a: lc(literal.Float64, float64(0.1)), b: dc("0.1"), want: 1},
+		{a: dc("-1"), b: lc(literal.Float64, float64(-1)), want: 0},
+		{a: lc(literal.Text, "1"), b: lc(literal.Int64, int64(1)), err: true},
+		{a: &Cell{N: n}, b: &Cell{N: n}, err: true},
+		{a: &Cell{T: &t1}, b: lc(literal.Int64, int64(1)), err: true},
+	}
+	for _, entry := range testTable {
+		got, err := CompareCells(entry.a, entry.b)
+		if entry.err {
+			if err == nil {
+				t.Errorf("CompareCells(%v, %v) should have failed", entry.a, entry.b)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("CompareCells(%v, %v) failed with error %v", entry.a, entry.b, err)
+		}
+		if got != entry.want {
+			t.Errorf("CompareCells(%v, %v) returned %d; want %d", entry.a, entry.b, got, entry.want)
+		}
+	}
+}
+
+func TestMinMaxAccumulators(t *testing.T) {
+	var (
+		mnv, mxv interface{}
+		mna      = NewMinAccumulator()
+		mxa      = NewMaxAccumulator()
+	)
+	for _, i := range []int64{3, 1, 4, 1, 5} {
+		l, _ := literal.DefaultBuilder().Build(literal.Int64, i)
+		mnv, _ = mna.Accumulate(&Cell{L: l})
+		mxv, _ = mxa.Accumulate(&Cell{L: l})
+	}
+	// Unbound values are ignored.
+	mnv, _ = mna.Accumulate(&Cell{})
+	mxv, _ = mxa.Accumulate(&Cell{})
+	if got, want := mnv.(*Cell).String(), `"1"^^type:int64`; got != want {
+		t.Errorf("Min accumulator failed; got %s, want %s", got, want)
+	}
+	if got, want := mxv.(*Cell).String(), `"5"^^type:int64`; got != want {
+		t.Errorf("Max accumulator failed; got %s, want %s", got, want)
+	}
+	// Literals of different types follow the order of literal.Compare.
+	l, _ := literal.DefaultBuilder().Build(literal.Text, "foo")
+	if v, err := mxa.Accumulate(&Cell{L: l}); err != nil || v.(*Cell).L != l {
+		t.Errorf("Max accumulator failed to accumulate a text literal; got %v, %v", v, err)
+	}
+	b, _ := literal.DefaultBuilder().Build(literal.Bool, true)
+	if v, err := mna.Accumulate(&Cell{L: b}); err != nil || v.(*Cell).L != b {
+		t.Errorf("Min accumulator failed to accumulate a bool literal; got %v, %v", v, err)
+	}
+	if _, err := mxa.Accumulate(&Cell{S: CellString("foo")}); err == nil {
+		t.Errorf("Max accumulator should have failed to accumulate incomparable values")
+	}
+	mxa.Reset()
+	if v, err := mxa.Accumulate(&Cell{L: l}); err != nil || v.(*Cell).L != l {
+		t.Errorf("Max accumulator failed to reset; got %v, %v", v, err)
+	}
+}
+
 func TestGroupRangeReduce(t *testing.T) {
 	int64LiteralCell := func(i int64) *Cell {
 		l, _ := literal.DefaultBuilder().Build(literal.Int64, i)
@@ -1056,6 +1473,57 @@ func TestTableReduce(t *testing.T) {
 	}
 }
 
+func TestRunningAccumulate(t *testing.T) {
+	tbl, err := New([]string{"?owner", "?t", "?amount"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []struct {
+		owner, t string
+		amount   int64
+	}{
+		{"b", "2", 5},
+		{"a", "3", 30},
+		{"a", "1", 10},
+		{"b", "1", 1},
+		{"a", "2", 20},
+	} {
+		l, err := literal.DefaultBuilder().Build(literal.Int64, r.amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tbl.AddRow(Row{
+			"?owner":  &Cell{S: CellString(r.owner)},
+			"?t":      &Cell{S: CellString(r.t)},
+			"?amount": &Cell{L: l},
+		})
+	}
+	aap := AliasAccPair{InAlias: "?amount", OutAlias: "?running", Acc: NewSumNumericLiteralAccumulator()}
+	if err := tbl.RunningAccumulate([]string{"?owner"}, SortConfig{{Binding: "?t"}}, aap); err != nil {
+		t.Fatalf("table.RunningAccumulate failed with error %v", err)
+	}
+	if !tbl.HasBinding("?running") {
+		t.Errorf("table.RunningAccumulate should have added the %q binding; got %v", "?running", tbl.Bindings())
+	}
+	var got []string
+	for _, r := range tbl.Rows() {
+		got = append(got, r["?owner"].String()+r["?t"].String()+"="+r["?running"].String())
+	}
+	want := []string{
+		`a1="10"^^type:int64`,
+		`a2="30"^^type:int64`,
+		`a3="60"^^type:int64`,
+		`b1="1"^^type:int64`,
+		`b2="6"^^type:int64`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("table.RunningAccumulate returned the wrong running sums; got %v, want %v", got, want)
+	}
+	if err := tbl.RunningAccumulate(nil, SortConfig{{Binding: "?unknown"}}, aap); err == nil {
+		t.Errorf("table.RunningAccumulate should fail for unknown order bindings")
+	}
+}
+
 func TestFilter(t *testing.T) {
 	table := func() *Table {
 		return &Table{
@@ -1135,3 +1603,322 @@ func TestFilter(t *testing.T) {
 		}
 	}
 }
+
+func TestColumnKind(t *testing.T) {
+	n, err := node.Parse("/u<joe>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := literal.DefaultBuilder().Build(literal.Int64, int64(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := literal.DefaultBuilder().Build(literal.Float64, float64(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl, err := New([]string{"?n", "?i", "?l", "?m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.AddRow(Row{
+		"?n": &Cell{N: n},
+		"?i": &Cell{L: i},
+		"?l": &Cell{L: i},
+		"?m": &Cell{N: n},
+	})
+	tbl.AddRow(Row{
+		"?n": &Cell{N: n},
+		"?i": &Cell{L: i},
+		"?l": &Cell{L: f},
+		"?m": &Cell{L: f},
+	})
+	testTable := []struct {
+		b, kind, sub string
+	}{
+		{"?n", "node", ""},
+		{"?i", "lit", "int64"},
+		{"?l", "lit", MixedKind},
+		{"?m", MixedKind, ""},
+		{"?unknown", "", ""},
+	}
+	for _, entry := range testTable {
+		if k, st := tbl.ColumnKind(entry.b); k != entry.kind || st != entry.sub {
+			t.Errorf("table.ColumnKind(%q) returned (%q, %q); want (%q, %q)", entry.b, k, st, entry.kind, entry.sub)
+		}
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	n, err := node.Parse("/u<joe>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := predicate.Parse(`"bought"@[2016-01-01T00:00:00-08:00]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := literal.DefaultBuilder().Parse(`"1"^^type:int64`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl, err := New([]string{"?n", "?p", "?l"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.AddRow(Row{
+		"?n": &Cell{N: n},
+		"?p": &Cell{P: p},
+		"?l": &Cell{L: l},
+	})
+	tbl.AddRow(Row{
+		"?n": &Cell{N: n},
+	})
+
+	b := &bytes.Buffer{}
+	if err := tbl.ToJSON(b); err != nil {
+		t.Fatalf("table.ToJSON failed with error %v", err)
+	}
+	want := `{ "bindings": ["?n","?p","?l"], "rows": [` +
+		`{ "?n": {"type":"node","value":"/u<joe>"}, "?p": {"type":"pred","value":"\"bought\"@[2016-01-01T00:00:00-08:00]","anchor":"2016-01-01T00:00:00-08:00"}, "?l": {"type":"lit","value":"\"1\"^^type:int64"} }, ` +
+		`{ "?n": {"type":"node","value":"/u<joe>"}, "?p": null, "?l": null }] }`
+	if got := b.String(); got != want {
+		t.Errorf("table.ToJSON returned\n%s\nwant\n%s", got, want)
+	}
+
+	var got struct {
+		Bindings []string
+		Rows     []map[string]*struct {
+			Type, Value, Anchor string
+		}
+	}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("table.ToJSON returned invalid JSON %s; %v", b, err)
+	}
+	if len(got.Bindings) != 3 || len(got.Rows) != 2 {
+		t.Fatalf("table.ToJSON returned %d bindings and %d rows; want 3 and 2", len(got.Bindings), len(got.Rows))
+	}
+	if c := got.Rows[0]["?p"]; c == nil || c.Value != p.String() {
+		t.Errorf("table.ToJSON returned predicate cell %v; want value %q", c, p)
+	}
+	if c, ok := got.Rows[1]["?l"]; !ok || c != nil {
+		t.Errorf("table.ToJSON should serialize unbound cells as null; got %v", c)
+	}
+}
+
+func TestRowToJSON(t *testing.T) {
+	n, err := node.Parse("/u<joe>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &bytes.Buffer{}
+	if err := (Row{"?n": &Cell{N: n}, "?x": &Cell{N: n}}).ToJSON(b, []string{"?n", "?l"}); err != nil {
+		t.Fatalf("table.Row.ToJSON failed with error %v", err)
+	}
+	if got, want := b.String(), `{ "?n": {"type":"node","value":"/u<joe>"}, "?l": null }`; got != want {
+		t.Errorf("table.Row.ToJSON returned\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestToJSONWithMetadata(t *testing.T) {
+	n, err := node.Parse("/u<joe>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := literal.DefaultBuilder().Build(literal.Int64, int64(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl, err := New([]string{"?n", "?i"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.AddRow(Row{
+		"?n": &Cell{N: n},
+		"?i": &Cell{L: i},
+	})
+
+	plain, meta := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := tbl.ToJSON(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.ToJSONWithMetadata(meta); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(plain.String(), `"metadata"`) {
+		t.Errorf("table.ToJSON should not include metadata; got %s", plain)
+	}
+	want := `{ "metadata": { "rows": 1, "columns": [{ "binding": "?n", "kind": "node" }, { "binding": "?i", "kind": "lit", "type": "int64" }] }, "bindings": `
+	if got := meta.String(); !strings.HasPrefix(got, want) {
+		t.Errorf("table.ToJSONWithMetadata returned the wrong header; got %s, want prefix %s", got, want)
+	}
+	if got, want := strings.TrimPrefix(meta.String(), want), strings.TrimPrefix(plain.String(), `{ "bindings": `); got != want {
+		t.Errorf("table.ToJSONWithMetadata should serialize the same data as ToJSON; got %s, want %s", got, want)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	tbl, err := New([]string{"?s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.AddRow(Row{"?s": &Cell{S: CellString("a")}})
+	tbl.AddRow(Row{"?s": &Cell{S: CellString("b")}})
+	want := tbl.String()
+	c := tbl.Copy()
+	if got := c.String(); got != want {
+		t.Errorf("table.Copy returned %q; want %q", got, want)
+	}
+	c.AddBindings([]string{"?o"})
+	c.Data[0]["?s"] = &Cell{S: CellString("c")}
+	c.Limit(1)
+	if got := tbl.String(); got != want {
+		t.Errorf("table.Copy should not share state with the original table; got %q, want %q", got, want)
+	}
+}
+
+func TestDistinct(t *testing.T) {
+	tbl, err := New([]string{"?s", "?o"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lit := func(v int64) *Cell {
+		l, err := literal.DefaultBuilder().Build(literal.Int64, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Cell{L: l}
+	}
+	n, err := node.Parse("/u<a>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl.AddRow(Row{"?s": &Cell{S: CellString("b")}, "?o": lit(1)})
+	tbl.AddRow(Row{"?s": &Cell{S: CellString("/u<a>")}, "?o": lit(2)})
+	tbl.AddRow(Row{"?s": &Cell{S: CellString("b")}, "?o": lit(1)})
+	tbl.AddRow(Row{"?s": &Cell{N: n}, "?o": lit(2)})
+	tbl.AddRow(Row{"?s": &Cell{S: CellString("b")}})
+	tbl.AddRow(Row{"?s": &Cell{S: CellString("/u<a>")}, "?o": lit(2)})
+	tbl.AddRow(Row{"?s": &Cell{S: CellString("b")}})
+	tbl.Distinct()
+	var got []string
+	for _, r := range tbl.Rows() {
+		var b bytes.Buffer
+		if err := r.ToTextLine(&b, tbl.Bindings(), "\t"); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, b.String())
+	}
+	want := []string{
+		"b\t\"1\"^^type:int64",
+		"/u<a>\t\"2\"^^type:int64",
+		"/u<a>\t\"2\"^^type:int64",
+		"b\t<NULL>",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("table.Distinct returned rows %q; want %q", got, want)
+	}
+	if kind, _ := tbl.Data[2]["?s"].Kind(); kind != "node" {
+		t.Errorf("table.Distinct should not consider strings and nodes equal; got kind %q for the third row", kind)
+	}
+}
+
+func TestHash(t *testing.T) {
+	newTable := func(ss ...string) *Table {
+		tbl, err := New([]string{"?s"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range ss {
+			tbl.AddRow(Row{"?s": &Cell{S: CellString(s)}})
+		}
+		return tbl
+	}
+	hash := func(tbl *Table) []byte {
+		h, err := tbl.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	if got, want := hash(newTable("a", "b")), hash(newTable("a", "b")); !bytes.Equal(got, want) {
+		t.Errorf("table.Hash should return the same hash for equal tables; got %x, want %x", got, want)
+	}
+	if got, want := hash(newTable("a", "b")), hash(newTable("b", "a")); bytes.Equal(got, want) {
+		t.Errorf("table.Hash should return different hashes for different row orders; got %x for both", got)
+	}
+	tbl := newTable("a")
+	h := hash(tbl)
+	tbl.AddRow(Row{"?s": &Cell{S: CellString("b")}})
+	if got := hash(tbl); bytes.Equal(got, h) {
+		t.Errorf("table.Hash should change after adding a row; got %x for both", got)
+	}
+}
+
+func TestMergeSorted(t *testing.T) {
+	cfg := SortConfig{{Binding: "?s"}, {Binding: "?o", Desc: true}}
+	var ins []<-chan Row
+	all, _ := New([]string{"?s", "?o"})
+	for g := 0; g < 4; g++ {
+		tbl, _ := New([]string{"?s", "?o"})
+		for i := 0; i < 25; i++ {
+			r := Row{
+				"?s": &Cell{S: CellString(fmt.Sprintf("%02d", (i*7+g*3)%10))},
+				"?o": &Cell{S: CellString(fmt.Sprintf("%02d", (i*g)%13))},
+			}
+			tbl.AddRow(r)
+			all.AddRow(r)
+		}
+		tbl.Sort(cfg)
+		ch := make(chan Row)
+		go func(rs []Row) {
+			for _, r := range rs {
+				ch <- r
+			}
+			close(ch)
+		}(tbl.Rows())
+		ins = append(ins, ch)
+	}
+	// An empty stream should not affect the merge.
+	empty := make(chan Row)
+	close(empty)
+	ins = append(ins, empty)
+
+	out := make(chan Row)
+	go MergeSorted(nil, cfg, ins, out)
+	var got []string
+	for r := range out {
+		got = append(got, r["?s"].String()+"/"+r["?o"].String())
+	}
+	all.Sort(cfg)
+	var want []string
+	for _, r := range all.Rows() {
+		want = append(want, r["?s"].String()+"/"+r["?o"].String())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeSorted returned %v; want %v", got, want)
+	}
+}
+
+func TestMergeSortedStopsOnDone(t *testing.T) {
+	cfg := SortConfig{{Binding: "?s"}}
+	done := make(chan struct{})
+	in := make(chan Row)
+	go func() {
+		defer close(in)
+		for i := 0; ; i++ {
+			select {
+			case in <- Row{"?s": &Cell{S: CellString(fmt.Sprintf("%05d", i))}}:
+			case <-done:
+				return
+			}
+		}
+	}()
+	out := make(chan Row)
+	go MergeSorted(done, cfg, []<-chan Row{in}, out)
+	<-out
+	close(done)
+	// The output channel must be closed once the merge stops.
+	for _ = range out {
+	}
+}
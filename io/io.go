@@ -33,10 +33,46 @@ import (
 // reader is interpret as text. Each line represents one triple using the
 // standard serialized format. ReadIntoGraph will stop if fails to Parse
 // a triple on the stream. The triples read till then would have also been
-// added to the graph. The int value returns the number of triples added.
+// added to the graph. Triples are added to the graph in batches of
+// DefaultBatchSize. The int value returns the number of triples added.
 func ReadIntoGraph(ctx context.Context, g storage.Graph, r io.Reader, b literal.Builder) (int, error) {
-	cnt, scanner := 0, bufio.NewScanner(r)
+	return readBatches(r, b, DefaultBatchSize, func(ts []*triple.Triple) error {
+		return g.AddTriples(ctx, ts)
+	})
+}
+
+// ReadOptions allows to specify the behavior of ReadIntoGraphWithOptions.
+type ReadOptions struct {
+	// DeferIndexes loads the triples without maintaining the secondary indexes
+	// of graphs implementing storage.BulkLoader and rebuilds them in one batch
+	// once all triples are read. Other graphs load the triples as usual.
+	DeferIndexes bool
+	// BatchSize is the number of triples added to the graph at once. A batch
+	// size of one adds the triples one at a time. Non positive values use
+	// DefaultBatchSize.
+	BatchSize int
+}
+
+// DefaultBatchSize is the default number of triples added to a graph at once.
+const DefaultBatchSize = 10000
+
+// readBatches parses the triples of the provided reader and calls add for
+// each batch of at most bs of them. Reading stops on the first triple that
+// fails to parse or the first batch that fails to be added. The triples
+// parsed before a parsing error are added before returning. It returns the
+// number of triples read.
+func readBatches(r io.Reader, b literal.Builder, bs int, add func([]*triple.Triple) error) (int, error) {
+	cnt, scanner, batch := 0, bufio.NewScanner(r), make([]*triple.Triple, 0, bs)
 	scanner.Split(bufio.ScanLines)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := add(batch)
+		batch = batch[:0]
+		return err
+	}
+	var rErr error
 	for scanner.Scan() {
 		text := strings.TrimSpace(scanner.Text())
 		if text == "" {
@@ -44,12 +80,48 @@ func ReadIntoGraph(ctx context.Context, g storage.Graph, r io.Reader, b literal.
 		}
 		t, err := triple.Parse(text, b)
 		if err != nil {
-			return cnt, err
+			rErr = err
+			break
 		}
+		batch = append(batch, t)
 		cnt++
-		g.AddTriples(ctx, []*triple.Triple{t})
+		if len(batch) == bs {
+			if rErr = flush(); rErr != nil {
+				return cnt, rErr
+			}
+		}
 	}
-	return cnt, nil
+	if err := flush(); err != nil && rErr == nil {
+		rErr = err
+	}
+	return cnt, rErr
+}
+
+// ReadIntoGraphWithOptions works like ReadIntoGraph, but it honors the
+// provided read options. When indexes are deferred, they are rebuilt before
+// returning even if a triple fails to parse, hence the graph is always left
+// ready to be queried.
+func ReadIntoGraphWithOptions(ctx context.Context, g storage.Graph, r io.Reader, b literal.Builder, opts *ReadOptions) (int, error) {
+	if opts == nil {
+		return ReadIntoGraph(ctx, g, r, b)
+	}
+	bs := opts.BatchSize
+	if bs <= 0 {
+		bs = DefaultBatchSize
+	}
+	bl, ok := g.(storage.BulkLoader)
+	if !opts.DeferIndexes || !ok {
+		return readBatches(r, b, bs, func(ts []*triple.Triple) error {
+			return g.AddTriples(ctx, ts)
+		})
+	}
+	cnt, rErr := readBatches(r, b, bs, func(ts []*triple.Triple) error {
+		return bl.AddTriplesDeferringIndexes(ctx, ts)
+	})
+	if err := bl.RebuildIndexes(ctx); err != nil && rErr == nil {
+		rErr = err
+	}
+	return cnt, rErr
 }
 
 // WriteGraph serializes the graph into the writer where each triple is
@@ -87,3 +159,36 @@ func WriteGraph(ctx context.Context, w io.Writer, g storage.Graph) (int, error)
 	}
 	return cnt, nil
 }
+
+// WriteOptions allows to specify the behavior of WriteGraphWithOptions.
+type WriteOptions struct {
+	// Sorted writes the triples in their canonical order, hence two graphs
+	// holding the same triples are always serialized into identical bytes.
+	// Triples are sorted by subject, then predicate ID, then object, and
+	// finally time anchor. Subjects and objects are compared using their
+	// serialized text. Immutable predicates go before temporal ones, and
+	// temporal predicates are sorted chronologically by their anchor.
+	Sorted bool
+	// SpillThreshold is the maximum number of triples held in memory while
+	// sorting. Once reached, sorted runs are spilled into temporary files and
+	// merged back when writing. Non positive values use
+	// DefaultSpillThreshold.
+	SpillThreshold int
+}
+
+// DefaultSpillThreshold is the default number of triples kept in memory
+// before spilling sorted runs into temporary files.
+const DefaultSpillThreshold = 1000000
+
+// WriteGraphWithOptions works like WriteGraph, but it honors the provided
+// write options.
+func WriteGraphWithOptions(ctx context.Context, w io.Writer, g storage.Graph, opts *WriteOptions) (int, error) {
+	if opts == nil || !opts.Sorted {
+		return WriteGraph(ctx, w, g)
+	}
+	st := opts.SpillThreshold
+	if st <= 0 {
+		st = DefaultSpillThreshold
+	}
+	return writeSortedGraph(ctx, w, g, st)
+}
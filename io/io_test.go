@@ -17,11 +17,18 @@ package io
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
 	"golang.org/x/net/context"
 
 	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/bolt"
 	"github.com/google/badwolf/storage/memory"
 	"github.com/google/badwolf/triple"
 	"github.com/google/badwolf/triple/literal"
@@ -136,3 +143,390 @@ func TestSerializationContents(t *testing.T) {
 		t.Errorf("Failed to unmarshal marshaled the right number of triples, %d != %d != 6", gs, gos)
 	}
 }
+
+func TestReadIntoGraphWithBoundedBuilder(t *testing.T) {
+	ctx := context.Background()
+	g, err := memory.NewStore().NewGraph(ctx, "test")
+	if err != nil {
+		t.Fatalf("memory.NewStore().NewGraph should have never failed to create a graph")
+	}
+	in := "/u<john>\t\"name\"@[]\t\"John\"^^type:text\n" +
+		"/u<mary>\t\"name\"@[]\t\"" + strings.Repeat("M", 100) + "\"^^type:text\n" +
+		"/u<kim>\t\"name\"@[]\t\"Kim\"^^type:text\n"
+	cnt, err := ReadIntoGraph(ctx, g, strings.NewReader(in), literal.NewBoundedBuilder(64))
+	if err == nil || !strings.Contains(err.Error(), "the maximum is 64 bytes") {
+		t.Errorf("io.ReadIntoGraph returned error %v; want the over-long literal to be rejected", err)
+	}
+	if cnt != 1 {
+		t.Errorf("io.ReadIntoGraph read %d triples; want only the one before the over-long literal", cnt)
+	}
+}
+
+func TestReadIntoGraphWithOptionsDefersIndexes(t *testing.T) {
+	var buffer bytes.Buffer
+	ts, ctx := getTestTriples(t), context.Background()
+	for _, trpl := range ts {
+		buffer.WriteString(fmt.Sprintf("%s\n", trpl.String()))
+	}
+	g, err := memory.NewStore().NewGraph(ctx, "test")
+	if err != nil {
+		t.Fatalf("memory.NewStore().NewGraph should have never failed to create a graph")
+	}
+	cnt, err := ReadIntoGraphWithOptions(ctx, g, &buffer, literal.DefaultBuilder(), &ReadOptions{DeferIndexes: true, BatchSize: 4})
+	if err != nil {
+		t.Fatalf("io.ReadIntoGraphWithOptions failed to read %s with error %v", buffer.String(), err)
+	}
+	if cnt != 6 {
+		t.Errorf("io.ReadIntoGraphWithOptions should have been able to read 6 triples not %d", cnt)
+	}
+	// The secondary indexes must be available once the load returns.
+	objs := make(chan *triple.Object)
+	go func() {
+		if err := g.Objects(ctx, ts[0].Subject(), ts[0].Predicate(), storage.DefaultLookup, objs); err != nil {
+			t.Errorf("g.Objects failed to retrieve objects with error %v", err)
+		}
+	}()
+	got := 0
+	for range objs {
+		got++
+	}
+	if got != 3 {
+		t.Errorf("g.Objects returned %d objects after a deferred load; want 3", got)
+	}
+}
+
+// batchingGraph records the size of each batch of triples added to the
+// wrapped graph.
+type batchingGraph struct {
+	storage.Graph
+	batches []int
+}
+
+// AddTriples records the batch size and forwards the triples to the wrapped
+// graph.
+func (g *batchingGraph) AddTriples(ctx context.Context, ts []*triple.Triple) error {
+	g.batches = append(g.batches, len(ts))
+	return g.Graph.AddTriples(ctx, ts)
+}
+
+func TestReadIntoGraphWithOptionsBatchesTriples(t *testing.T) {
+	var buffer bytes.Buffer
+	ts, ctx := getTestTriples(t), context.Background()
+	for _, trpl := range ts {
+		buffer.WriteString(fmt.Sprintf("%s\n", trpl.String()))
+	}
+	buffer.WriteString("not a triple\n")
+	mg, err := memory.NewStore().NewGraph(ctx, "test")
+	if err != nil {
+		t.Fatalf("memory.NewStore().NewGraph should have never failed to create a graph")
+	}
+	g := &batchingGraph{Graph: mg}
+	cnt, err := ReadIntoGraphWithOptions(ctx, g, &buffer, literal.DefaultBuilder(), &ReadOptions{BatchSize: 4})
+	if err == nil {
+		t.Errorf("io.ReadIntoGraphWithOptions should have failed to parse the last line")
+	}
+	if cnt != 6 {
+		t.Errorf("io.ReadIntoGraphWithOptions should have been able to read 6 triples not %d", cnt)
+	}
+	// The triples read before the parsing error are still added.
+	if want := []int{4, 2}; !reflect.DeepEqual(g.batches, want) {
+		t.Errorf("io.ReadIntoGraphWithOptions added batches of %v triples; want %v", g.batches, want)
+	}
+	objs := make(chan *triple.Object)
+	go func() {
+		if err := g.Objects(ctx, ts[3].Subject(), ts[3].Predicate(), storage.DefaultLookup, objs); err != nil {
+			t.Errorf("g.Objects failed to retrieve objects with error %v", err)
+		}
+	}()
+	got := 0
+	for range objs {
+		got++
+	}
+	if got != 3 {
+		t.Errorf("g.Objects returned %d objects after a batched load; want 3", got)
+	}
+}
+
+func TestWriteGraphWithOptionsSorted(t *testing.T) {
+	ctx := context.Background()
+	ts := getTestTriples(t)
+	for _, s := range []string{
+		"/u<john>\t\"met\"@[2016-01-01T00:00:00Z]\t/u<mary>",
+		"/u<john>\t\"met\"@[2015-01-01T00:00:00-08:00]\t/u<mary>",
+		"/u<john>\t\"met\"@[]\t/u<mary>",
+		"/u<alice>\t\"name\"@[]\t\"alice\"^^type:text",
+	} {
+		trpl, err := triple.Parse(s, literal.DefaultBuilder())
+		if err != nil {
+			t.Fatalf("triple.Parse failed to parse valid triple %s with error %v", s, err)
+		}
+		ts = append(ts, trpl)
+	}
+	want := []string{
+		"/u<alice>\t\"name\"@[]\t\"alice\"^^type:text",
+		"/u<john>\t\"knows\"@[]\t/u<alice>",
+		"/u<john>\t\"knows\"@[]\t/u<mary>",
+		"/u<john>\t\"knows\"@[]\t/u<peter>",
+		"/u<john>\t\"met\"@[]\t/u<mary>",
+		"/u<john>\t\"met\"@[2015-01-01T00:00:00-08:00]\t/u<mary>",
+		"/u<john>\t\"met\"@[2016-01-01T00:00:00Z]\t/u<mary>",
+		"/u<mary>\t\"knows\"@[]\t/u<alice>",
+		"/u<mary>\t\"knows\"@[]\t/u<andrew>",
+		"/u<mary>\t\"knows\"@[]\t/u<kim>",
+	}
+	table := []struct {
+		opts    *WriteOptions
+		reverse bool
+	}{
+		{opts: &WriteOptions{Sorted: true}},
+		{opts: &WriteOptions{Sorted: true}, reverse: true},
+		{opts: &WriteOptions{Sorted: true, SpillThreshold: 3}},
+		{opts: &WriteOptions{Sorted: true, SpillThreshold: 3}, reverse: true},
+		{opts: &WriteOptions{Sorted: true, SpillThreshold: 1}},
+	}
+	for _, entry := range table {
+		var buffer bytes.Buffer
+		g, err := memory.NewStore().NewGraph(ctx, "test")
+		if err != nil {
+			t.Fatalf("memory.NewStore().NewGraph should have never failed to create a graph")
+		}
+		for i := range ts {
+			trpl := ts[i]
+			if entry.reverse {
+				trpl = ts[len(ts)-1-i]
+			}
+			if err := g.AddTriples(ctx, []*triple.Triple{trpl}); err != nil {
+				t.Fatalf("storage.AddTriples should have not fail to add triple %v with error %v", trpl, err)
+			}
+		}
+		cnt, err := WriteGraphWithOptions(ctx, &buffer, g, entry.opts)
+		if err != nil {
+			t.Errorf("io.WriteGraphWithOptions(%+v) failed with error %v", entry.opts, err)
+			continue
+		}
+		if cnt != len(want) {
+			t.Errorf("io.WriteGraphWithOptions(%+v) wrote %d triples; want %d", entry.opts, cnt, len(want))
+		}
+		if got, want := buffer.String(), strings.Join(want, "\n")+"\n"; got != want {
+			t.Errorf("io.WriteGraphWithOptions(%+v) wrote\n%s\nwant\n%s", entry.opts, got, want)
+		}
+	}
+}
+
+func TestWriteRDFStar(t *testing.T) {
+	ctx := context.Background()
+	g, err := memory.NewStore().NewGraph(ctx, "test")
+	if err != nil {
+		t.Fatalf("memory.NewStore().NewGraph should have never failed to create a graph")
+	}
+	var ts []*triple.Triple
+	for _, s := range []string{
+		// An asserted temporal triple reified with an annotation.
+		"/user<John>\t\"met\"@[2006-01-02T15:04:05Z]\t/user<Mary>",
+		"/_<m1>\t\"_subject\"@[2006-01-02T15:04:05Z]\t/user<John>",
+		"/_<m1>\t\"_predicate\"@[2006-01-02T15:04:05Z]\t\"met\"@[2006-01-02T15:04:05Z]",
+		"/_<m1>\t\"_object\"@[2006-01-02T15:04:05Z]\t/user<Mary>",
+		"/_<m1>\t\"location\"@[]\t/city<New York>",
+		// The same triple a year later is not collapsed with the first one.
+		"/user<John>\t\"met\"@[2007-01-02T15:04:05Z]\t/user<Mary>",
+		// A reified triple that is not asserted.
+		"/_<k1>\t\"_subject\"@[]\t/user<Mary>",
+		"/_<k1>\t\"_predicate\"@[]\t\"knows\"@[]",
+		"/_<k1>\t\"_object\"@[]\t\"Al \"the\" pal\"^^type:text",
+		"/user<Peter>\t\"doubts\"@[]\t/_<k1>",
+		// An incomplete reification is written as plain triples.
+		"/_<x:1>\t\"_subject\"@[]\t/user<Mary>",
+		// Literals.
+		"/user<Mary>\t\"age\"@[]\t\"42\"^^type:int64",
+		"/user<Mary>\t\"height\"@[]\t\"1.5\"^^type:float64",
+		"/user<Mary>\t\"active\"@[]\t\"true\"^^type:bool",
+		"/user<Mary>\t\"avatar\"@[]\t\"aGVsbG8=\"^^type:blob",
+		"/user<Mary>\t\"balance\"@[]\t\"19.990\"^^type:decimal",
+	} {
+		trpl, err := triple.Parse(s, literal.DefaultBuilder())
+		if err != nil {
+			t.Fatalf("triple.Parse failed to parse valid triple %s with error %v", s, err)
+		}
+		ts = append(ts, trpl)
+	}
+	if err := g.AddTriples(ctx, ts); err != nil {
+		t.Fatalf("storage.AddTriples failed with error %v", err)
+	}
+	var buffer bytes.Buffer
+	cnt, err := WriteRDFStar(ctx, &buffer, g, &RDFStarOptions{Namespace: "http://example.org/"})
+	if err != nil {
+		t.Fatalf("io.WriteRDFStar failed with error %v", err)
+	}
+	const (
+		met   = `<< <http://example.org/node/user/John> <http://example.org/predicate/met/2006-01-02T15:04:05Z> <http://example.org/node/user/Mary> >>`
+		knows = `<< <http://example.org/node/user/Mary> <http://example.org/predicate/knows> "Al \"the\" pal" >>`
+	)
+	want := []string{
+		`<http://example.org/node/user/John> <http://example.org/predicate/met/2006-01-02T15:04:05Z> <http://example.org/node/user/Mary> .`,
+		`<http://example.org/node/user/John> <http://example.org/predicate/met/2007-01-02T15:04:05Z> <http://example.org/node/user/Mary> .`,
+		`<< <http://example.org/node/user/John> <http://example.org/predicate/met/2007-01-02T15:04:05Z> <http://example.org/node/user/Mary> >> <http://example.org/timeAnchor> "2007-01-02T15:04:05Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .`,
+		`<http://example.org/node/user/Mary> <http://example.org/predicate/active> "true"^^<http://www.w3.org/2001/XMLSchema#boolean> .`,
+		`<http://example.org/node/user/Mary> <http://example.org/predicate/age> "42"^^<http://www.w3.org/2001/XMLSchema#long> .`,
+		`<http://example.org/node/user/Mary> <http://example.org/predicate/avatar> "aGVsbG8="^^<http://www.w3.org/2001/XMLSchema#base64Binary> .`,
+		`<http://example.org/node/user/Mary> <http://example.org/predicate/balance> "19.99"^^<http://www.w3.org/2001/XMLSchema#decimal> .`,
+		`<http://example.org/node/user/Mary> <http://example.org/predicate/height> "1.5"^^<http://www.w3.org/2001/XMLSchema#double> .`,
+		`<http://example.org/node/user/Peter> <http://example.org/predicate/doubts> ` + knows + ` .`,
+		met + ` <http://example.org/timeAnchor> "2006-01-02T15:04:05Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .`,
+		met + ` <http://example.org/predicate/location> <http://example.org/node/city/New%20York> .`,
+		`_:x783a31 <http://example.org/predicate/_subject> <http://example.org/node/user/Mary> .`,
+	}
+	sort.Strings(want)
+	got := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("io.WriteRDFStar returned the wrong statements;\ngot\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if cnt != len(want) {
+		t.Errorf("io.WriteRDFStar wrote %d statements; want %d", cnt, len(want))
+	}
+}
+
+func TestNQuadsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	quads := map[string][]string{
+		"?family": {
+			"/u<john>\t\"knows\"@[]\t/u<mary>",
+			"/u<john>\t\"met\"@[2016-04-10T04:21:00.123456789Z]\t/u<mary>",
+			"/_<c175b457>\t\"_predicate\"@[2016-04-10T04:21:00.123456789-07:00]\t\"met\"@[2016-04-10T04:21:00.123456789-07:00]",
+		},
+		`?sales "2016"`: {
+			"/u<mary>\t\"bought\"@[]\t/c<model s>",
+			"/u<mary>\t\"note\"@[]\t\"paid\tin \"cash\"\"^^type:text",
+		},
+	}
+	src, dst := memory.NewStore(), memory.NewStore()
+	var buffer bytes.Buffer
+	for name, ss := range quads {
+		g, err := src.NewGraph(ctx, name)
+		if err != nil {
+			t.Fatalf("memory.NewStore().NewGraph should have never failed to create a graph")
+		}
+		for _, s := range ss {
+			trpl, err := triple.Parse(s, literal.DefaultBuilder())
+			if err != nil {
+				t.Fatalf("triple.Parse failed to parse valid triple %s with error %v", s, err)
+			}
+			if err := g.AddTriples(ctx, []*triple.Triple{trpl}); err != nil {
+				t.Fatalf("storage.AddTriples failed with error %v", err)
+			}
+		}
+		if err := WriteGraphAsNQuads(ctx, g, name, &buffer); err != nil {
+			t.Fatalf("io.WriteGraphAsNQuads failed with error %v", err)
+		}
+	}
+	cnt, err := ReadNQuadsIntoStore(ctx, dst, &buffer, literal.DefaultBuilder())
+	if err != nil {
+		t.Fatalf("io.ReadNQuadsIntoStore failed with error %v", err)
+	}
+	if got, want := cnt, 5; got != want {
+		t.Errorf("io.ReadNQuadsIntoStore read %d triples; want %d", got, want)
+	}
+	for name, want := range quads {
+		g, err := dst.Graph(ctx, name)
+		if err != nil {
+			t.Fatalf("io.ReadNQuadsIntoStore failed to create graph %q with error %v", name, err)
+		}
+		ts := make(chan *triple.Triple)
+		go func() {
+			if err := g.Triples(ctx, storage.DefaultLookup, ts); err != nil {
+				t.Errorf("g.Triples failed to retrieve triples with error %v", err)
+			}
+		}()
+		var got []string
+		for trpl := range ts {
+			got = append(got, trpl.String())
+		}
+		sort.Strings(got)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("io.ReadNQuadsIntoStore returned the wrong triples for graph %q; got %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestReadNQuadsIntoStoreRejectsMissingGraphNames(t *testing.T) {
+	b := bytes.NewBufferString("/u<john>\t\"knows\"@[]\t/u<mary>\t\"?family\"\n/u<john>\t\"knows\"@[]\t/u<peter>\n")
+	s, ctx := memory.NewStore(), context.Background()
+	cnt, err := ReadNQuadsIntoStore(ctx, s, b, literal.DefaultBuilder())
+	if err == nil {
+		t.Errorf("io.ReadNQuadsIntoStore should have failed to read a triple without graph name")
+	}
+	if got, want := cnt, 1; got != want {
+		t.Errorf("io.ReadNQuadsIntoStore read %d triples; want %d", got, want)
+	}
+	if _, err := s.Graph(ctx, "?family"); err != nil {
+		t.Errorf("io.ReadNQuadsIntoStore should have added the quads read before failing; %v", err)
+	}
+}
+
+// benchmarkReadIntoGraph loads n triples into a new graph using the provided
+// read options. newStore returns the store to load the triples into, and a
+// function cleaning it up once the load is done.
+func benchmarkReadIntoGraph(b *testing.B, n int, newStore func() (storage.Store, func()), opts *ReadOptions) {
+	var dump bytes.Buffer
+	for i := 0; i < n; i++ {
+		dump.WriteString(fmt.Sprintf("/u<%d>\t\"knows\"@[]\t/u<%d>\n", i%1000, i))
+	}
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s, done := newStore()
+		g, err := s.NewGraph(ctx, "?test")
+		if err != nil {
+			b.Fatalf("%s.NewGraph failed to create \"?test\" with error %v", s.Name(ctx), err)
+		}
+		if _, err := ReadIntoGraphWithOptions(ctx, g, bytes.NewReader(dump.Bytes()), literal.DefaultBuilder(), opts); err != nil {
+			b.Fatalf("io.ReadIntoGraphWithOptions failed to read the triples with error %v", err)
+		}
+		done()
+	}
+}
+
+// newMemoryLoadStore returns an empty memory store.
+func newMemoryLoadStore() (storage.Store, func()) {
+	return memory.NewStore(), func() {}
+}
+
+// newBoltLoadStore returns an empty BoltDB-backed store on a temporary folder
+// and a function to clean it up.
+func newBoltLoadStore(b *testing.B) func() (storage.Store, func()) {
+	return func() (storage.Store, func()) {
+		dir, err := ioutil.TempDir("", "badwolf_bolt")
+		if err != nil {
+			b.Fatalf("ioutil.TempDir failed with error %v", err)
+		}
+		s, err := bolt.NewStore(filepath.Join(dir, "load.db"), 0600)
+		if err != nil {
+			os.RemoveAll(dir)
+			b.Fatalf("bolt.NewStore failed with error %v", err)
+		}
+		return s, func() {
+			s.Close()
+			os.RemoveAll(dir)
+		}
+	}
+}
+
+// These benchmark tests are used to compare adding the triples one at a time
+// against adding them in batches. Stores committing each addition on its own,
+// like the BoltDB-backed one, benefit the most from batching.
+func BenchmarkMemoryReadIntoGraphOneByOne(b *testing.B) {
+	benchmarkReadIntoGraph(b, 30000, newMemoryLoadStore, &ReadOptions{BatchSize: 1})
+}
+
+func BenchmarkMemoryReadIntoGraphBatched(b *testing.B) {
+	benchmarkReadIntoGraph(b, 30000, newMemoryLoadStore, nil)
+}
+
+func BenchmarkBoltReadIntoGraphOneByOne(b *testing.B) {
+	benchmarkReadIntoGraph(b, 30000, newBoltLoadStore(b), &ReadOptions{BatchSize: 1})
+}
+
+func BenchmarkBoltReadIntoGraphBatched(b *testing.B) {
+	benchmarkReadIntoGraph(b, 30000, newBoltLoadStore(b), nil)
+}
@@ -0,0 +1,143 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package io
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+)
+
+// WriteGraphAsNQuads serializes the graph into the writer where each triple
+// is marshaled into a separate line followed by the quoted graph name as its
+// fourth tab separated component. Triples use the same serialized format
+// ReadIntoGraph reads, hence time anchors are written in RFC3339Nano and
+// round-trip exactly. If there is an error writing the serialization will
+// stop.
+func WriteGraphAsNQuads(ctx context.Context, g storage.Graph, graphName string, w io.Writer) error {
+	var (
+		wg   sync.WaitGroup
+		tErr error
+		wErr error
+	)
+	label, ts := strconv.Quote(graphName), make(chan *triple.Triple)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tErr = g.Triples(ctx, storage.DefaultLookup, ts)
+	}()
+	for t := range ts {
+		if wErr != nil {
+			continue
+		}
+		if _, err := io.WriteString(w, fmt.Sprintf("%s\t%s\n", t.String(), label)); err != nil {
+			wErr = err
+		}
+	}
+	wg.Wait()
+	if tErr != nil {
+		return tErr
+	}
+	return wErr
+}
+
+// parseQuad parses a line written by WriteGraphAsNQuads and returns the
+// triple and the name of the graph it belongs to. Quoted graph names never
+// contain raw tabs, hence the last tab followed by a quote starts the name.
+func parseQuad(line string, b literal.Builder) (*triple.Triple, string, error) {
+	raw := strings.TrimSpace(line)
+	idx := strings.LastIndex(raw, "\t\"")
+	if idx < 0 {
+		return nil, "", fmt.Errorf("io.parseQuad could not find the graph name in %s", raw)
+	}
+	name, err := strconv.Unquote(raw[idx+1:])
+	if err != nil {
+		return nil, "", fmt.Errorf("io.parseQuad failed to parse graph name %s with error %v", raw[idx+1:], err)
+	}
+	t, err := triple.Parse(raw[:idx], b)
+	if err != nil {
+		return nil, "", err
+	}
+	return t, name, nil
+}
+
+// ReadNQuadsIntoStore reads the quads written by WriteGraphAsNQuads out of
+// the provided reader and adds each triple to the graph named by its fourth
+// component. Graphs missing from the store are created. Triples are added to
+// each graph in batches of DefaultBatchSize. ReadNQuadsIntoStore will stop if
+// it fails to parse a quad on the stream; the quads read till then would have
+// also been added to their graphs. The int value returns the number of
+// triples added.
+func ReadNQuadsIntoStore(ctx context.Context, s storage.Store, r io.Reader, b literal.Builder) (int, error) {
+	var names []string
+	gs, batches := make(map[string]storage.Graph), make(map[string][]*triple.Triple)
+	flush := func(name string) error {
+		if len(batches[name]) == 0 {
+			return nil
+		}
+		g, ok := gs[name]
+		if !ok {
+			var err error
+			if g, err = s.Graph(ctx, name); err != nil {
+				if g, err = s.NewGraph(ctx, name); err != nil {
+					return err
+				}
+			}
+			gs[name] = g
+		}
+		err := g.AddTriples(ctx, batches[name])
+		batches[name] = batches[name][:0]
+		return err
+	}
+	cnt, scanner := 0, bufio.NewScanner(r)
+	scanner.Split(bufio.ScanLines)
+	var rErr error
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		t, name, err := parseQuad(text, b)
+		if err != nil {
+			rErr = err
+			break
+		}
+		if _, ok := batches[name]; !ok {
+			names = append(names, name)
+		}
+		batches[name] = append(batches[name], t)
+		cnt++
+		if len(batches[name]) == DefaultBatchSize {
+			if rErr = flush(name); rErr != nil {
+				return cnt, rErr
+			}
+		}
+	}
+	for _, name := range names {
+		if err := flush(name); err != nil && rErr == nil {
+			rErr = err
+		}
+	}
+	return cnt, rErr
+}
@@ -0,0 +1,403 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package io
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// DefaultRDFStarNamespace is the namespace used to build the IRIs of nodes
+// and predicates when no other namespace is provided.
+const DefaultRDFStarNamespace = "urn:badwolf:"
+
+// xsd contains the namespace of the XML schema datatypes.
+const xsd = "http://www.w3.org/2001/XMLSchema#"
+
+// RDFStarOptions allows to specify the behavior of WriteRDFStar.
+type RDFStarOptions struct {
+	// Namespace is the prefix of all the IRIs minted for nodes, predicates,
+	// and time anchors. If empty, DefaultRDFStarNamespace is used.
+	Namespace string
+}
+
+// reification contains the parts of a triple reified on a blank node.
+type reification struct {
+	s, p, o  int
+	subject  *node.Node
+	pred     *predicate.Predicate
+	object   *triple.Object
+	asserted bool
+}
+
+// complete returns true if the reification has exactly one subject, predicate
+// and object.
+func (r *reification) complete() bool {
+	return r.s == 1 && r.p == 1 && r.o == 1 && r.subject != nil && r.pred != nil && r.object != nil
+}
+
+// rdfStarWriter serializes triples into N-Triples-star statements.
+type rdfStarWriter struct {
+	ns   string
+	reif map[string]*reification
+}
+
+// isReificationPart returns the reification the triple is part of, if the
+// triple is one of the _subject, _predicate, or _object triples of a complete
+// reification.
+func (w *rdfStarWriter) isReificationPart(t *triple.Triple) (*reification, bool) {
+	switch t.Predicate().ID() {
+	case "_subject", "_predicate", "_object":
+	default:
+		return nil, false
+	}
+	r, ok := w.reif[t.Subject().String()]
+	if !ok || !r.complete() {
+		return nil, false
+	}
+	return r, true
+}
+
+// collect records the reification triples.
+func (w *rdfStarWriter) collect(t *triple.Triple) {
+	s := t.Subject()
+	if s.Type().String() != "/_" {
+		return
+	}
+	k := s.String()
+	r, ok := w.reif[k]
+	if !ok {
+		r = &reification{}
+	}
+	switch t.Predicate().ID() {
+	case "_subject":
+		r.s++
+		if n, err := t.Object().Node(); err == nil {
+			r.subject = n
+		}
+	case "_predicate":
+		r.p++
+		if p, err := t.Object().Predicate(); err == nil {
+			r.pred = p
+		}
+	case "_object":
+		r.o++
+		r.object = t.Object()
+	default:
+		return
+	}
+	w.reif[k] = r
+}
+
+// nodeTerm returns the RDF-star term for the node. Blank nodes holding a
+// complete reification are replaced by the quoted reified triple.
+func (w *rdfStarWriter) nodeTerm(n *node.Node, visited map[string]bool) (string, error) {
+	if n.Type().String() != "/_" {
+		var segs []string
+		for _, s := range strings.Split(strings.TrimPrefix(n.Type().String(), "/"), "/") {
+			segs = append(segs, url.PathEscape(s))
+		}
+		return "<" + w.ns + "node/" + strings.Join(segs, "/") + "/" + url.PathEscape(n.ID().String()) + ">", nil
+	}
+	k := n.String()
+	if r, ok := w.reif[k]; ok && r.complete() {
+		if visited[k] {
+			return "", fmt.Errorf("io.WriteRDFStar: cyclic reification on blank node %s", n)
+		}
+		visited[k] = true
+		defer delete(visited, k)
+		return w.quoted(r.subject, r.pred, r.object, visited)
+	}
+	return blankNodeLabel(n.ID().String()), nil
+}
+
+// blankNodeLabel returns the N-Triples label of the blank node ID. IDs only
+// containing letters, digits, '-', and '_' are prefixed with 'b'; any other ID
+// is hex encoded and prefixed with 'x', hence labels never collide.
+func blankNodeLabel(id string) string {
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return "_:x" + hex.EncodeToString([]byte(id))
+		}
+	}
+	return "_:b" + id
+}
+
+// predicateTerm returns the IRI for the predicate. The time anchor of
+// temporal predicates is appended as an extra path segment, hence triples only
+// differing on their time anchor are not collapsed into the same statement.
+func (w *rdfStarWriter) predicateTerm(p *predicate.Predicate) string {
+	iri := w.ns + "predicate/" + url.PathEscape(string(p.ID()))
+	if ta, err := p.TimeAnchor(); err == nil {
+		iri += "/" + url.PathEscape(ta.Format(time.RFC3339Nano))
+	}
+	return "<" + iri + ">"
+}
+
+// objectTerm returns the RDF-star term for the object.
+func (w *rdfStarWriter) objectTerm(o *triple.Object, visited map[string]bool) (string, error) {
+	if n, err := o.Node(); err == nil {
+		return w.nodeTerm(n, visited)
+	}
+	if p, err := o.Predicate(); err == nil {
+		return w.predicateTerm(p), nil
+	}
+	l, err := o.Literal()
+	if err != nil {
+		return "", fmt.Errorf("io.WriteRDFStar: unknown object type in object %s", o)
+	}
+	return literalTerm(l)
+}
+
+// quoted returns the quoted triple term for the provided subject, predicate,
+// and object.
+func (w *rdfStarWriter) quoted(s *node.Node, p *predicate.Predicate, o *triple.Object, visited map[string]bool) (string, error) {
+	st, err := w.nodeTerm(s, visited)
+	if err != nil {
+		return "", err
+	}
+	ot, err := w.objectTerm(o, visited)
+	if err != nil {
+		return "", err
+	}
+	return "<< " + st + " " + w.predicateTerm(p) + " " + ot + " >>", nil
+}
+
+// anchors returns the annotation statements describing the time anchors of
+// the predicate and the object of the quoted triple.
+func (w *rdfStarWriter) anchors(q string, p *predicate.Predicate, o *triple.Object) []string {
+	var res []string
+	if ta, err := p.TimeAnchor(); err == nil {
+		res = append(res, q+" <"+w.ns+"timeAnchor> "+dateTimeTerm(ta)+" .")
+	}
+	if op, err := o.Predicate(); err == nil {
+		if ta, err := op.TimeAnchor(); err == nil {
+			res = append(res, q+" <"+w.ns+"objectTimeAnchor> "+dateTimeTerm(ta)+" .")
+		}
+	}
+	return res
+}
+
+// dateTimeTerm returns the xsd:dateTime literal for the time anchor.
+func dateTimeTerm(t *time.Time) string {
+	return `"` + t.Format(time.RFC3339Nano) + `"^^<` + xsd + `dateTime>`
+}
+
+// literalTerm returns the RDF literal for the BadWolf literal.
+func literalTerm(l *literal.Literal) (string, error) {
+	switch l.Type() {
+	case literal.Bool:
+		v, err := l.Bool()
+		if err != nil {
+			return "", err
+		}
+		return `"` + strconv.FormatBool(v) + `"^^<` + xsd + `boolean>`, nil
+	case literal.Int64:
+		v, err := l.Int64()
+		if err != nil {
+			return "", err
+		}
+		return `"` + strconv.FormatInt(v, 10) + `"^^<` + xsd + `long>`, nil
+	case literal.Float64:
+		v, err := l.Float64()
+		if err != nil {
+			return "", err
+		}
+		var s string
+		switch {
+		case math.IsInf(v, 1):
+			s = "INF"
+		case math.IsInf(v, -1):
+			s = "-INF"
+		case math.IsNaN(v):
+			s = "NaN"
+		default:
+			s = strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		return `"` + s + `"^^<` + xsd + `double>`, nil
+	case literal.Text:
+		v, err := l.Text()
+		if err != nil {
+			return "", err
+		}
+		return `"` + escapeString(v) + `"`, nil
+	case literal.Blob:
+		v, err := l.Blob()
+		if err != nil {
+			return "", err
+		}
+		return `"` + base64.StdEncoding.EncodeToString(v) + `"^^<` + xsd + `base64Binary>`, nil
+	case literal.Decimal:
+		// Decimal literals are printed using their canonical decimal notation.
+		return strings.TrimSuffix(l.String(), "^^type:decimal") + `^^<` + xsd + `decimal>`, nil
+	}
+	return "", fmt.Errorf("io.WriteRDFStar: unsupported literal type in literal %s", l)
+}
+
+// escapeString escapes the string following the N-Triples string literal
+// rules.
+func escapeString(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// checkAsserted records which of the complete reifications are about triples
+// asserted in the graph.
+func (w *rdfStarWriter) checkAsserted(ctx context.Context, g storage.Graph) error {
+	for _, r := range w.reif {
+		if !r.complete() {
+			continue
+		}
+		t, err := triple.New(r.subject, r.pred, r.object)
+		if err != nil {
+			return err
+		}
+		if r.asserted, err = g.Exist(ctx, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statements returns the N-Triples-star statements for the provided triple.
+// The _subject, _predicate, and _object triples of complete reifications are
+// not serialized. The time anchors of a reified triple are annotated once on
+// the _predicate triple, unless the reified triple is asserted in the graph
+// and hence annotated with it.
+func (w *rdfStarWriter) statements(t *triple.Triple) ([]string, error) {
+	visited := make(map[string]bool)
+	if r, ok := w.isReificationPart(t); ok {
+		if t.Predicate().ID() != "_predicate" || r.asserted {
+			return nil, nil
+		}
+		q, err := w.quoted(r.subject, r.pred, r.object, visited)
+		if err != nil {
+			return nil, err
+		}
+		return w.anchors(q, r.pred, r.object), nil
+	}
+	st, err := w.nodeTerm(t.Subject(), visited)
+	if err != nil {
+		return nil, err
+	}
+	ot, err := w.objectTerm(t.Object(), visited)
+	if err != nil {
+		return nil, err
+	}
+	pt := w.predicateTerm(t.Predicate())
+	res := []string{st + " " + pt + " " + ot + " ."}
+	return append(res, w.anchors("<< "+st+" "+pt+" "+ot+" >>", t.Predicate(), t.Object())...), nil
+}
+
+// scanTriples calls f for each triple of the graph. The triples channel is
+// always drained.
+func scanTriples(ctx context.Context, g storage.Graph, f func(*triple.Triple) error) error {
+	var (
+		wg   sync.WaitGroup
+		tErr error
+		fErr error
+	)
+	ts := make(chan *triple.Triple)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tErr = g.Triples(ctx, storage.DefaultLookup, ts)
+	}()
+	for t := range ts {
+		if fErr != nil {
+			continue
+		}
+		fErr = f(t)
+	}
+	wg.Wait()
+	if tErr != nil {
+		return tErr
+	}
+	return fErr
+}
+
+// WriteRDFStar serializes the graph into the writer using N-Triples-star,
+// one statement per line. Reified triples are written as quoted triples, and
+// the triples about their blank nodes become annotations of the quoted
+// triple. Time anchors are part of the predicate IRIs, and are also written as
+// annotations of the quoted statement.
+// The graph is scanned twice; the first scan only keeps the reification
+// triples in memory. It returns the number of statements written.
+func WriteRDFStar(ctx context.Context, w io.Writer, g storage.Graph, opts *RDFStarOptions) (int, error) {
+	rw := &rdfStarWriter{
+		ns:   DefaultRDFStarNamespace,
+		reif: make(map[string]*reification),
+	}
+	if opts != nil && opts.Namespace != "" {
+		rw.ns = opts.Namespace
+	}
+	if err := scanTriples(ctx, g, func(t *triple.Triple) error {
+		rw.collect(t)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	if err := rw.checkAsserted(ctx, g); err != nil {
+		return 0, err
+	}
+	cnt := 0
+	err := scanTriples(ctx, g, func(t *triple.Triple) error {
+		ss, err := rw.statements(t)
+		if err != nil {
+			return err
+		}
+		for _, s := range ss {
+			if _, err := io.WriteString(w, s+"\n"); err != nil {
+				return err
+			}
+			cnt++
+		}
+		return nil
+	})
+	return cnt, err
+}
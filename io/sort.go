@@ -0,0 +1,243 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package io
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+)
+
+// record is a serialized triple along with its canonical sort key.
+type record struct {
+	key  string
+	line string
+}
+
+// newRecord returns the record for the provided triple. The key joins the
+// subject, predicate ID, object, and time anchor using NUL separators, which
+// cannot be part of any of them. Anchors are encoded as fixed width
+// hexadecimal offsets, hence they sort chronologically, and immutable
+// predicates use an empty anchor that sorts before any temporal one.
+func newRecord(t *triple.Triple) record {
+	ta := ""
+	if a, err := t.Predicate().TimeAnchor(); err == nil {
+		ta = fmt.Sprintf("%016x", uint64(a.UnixNano())^(1<<63))
+	}
+	return record{
+		key:  strings.Join([]string{t.Subject().String(), string(t.Predicate().ID()), t.Object().String(), ta}, "\x00"),
+		line: t.String(),
+	}
+}
+
+// less returns true if r goes before r2. Records with the same key are
+// ordered by their serialized text to keep the order stable.
+func (r record) less(r2 record) bool {
+	if r.key != r2.key {
+		return r.key < r2.key
+	}
+	return r.line < r2.line
+}
+
+// records allows sorting a slice of records.
+type records []record
+
+func (rs records) Len() int           { return len(rs) }
+func (rs records) Less(i, j int) bool { return rs[i].less(rs[j]) }
+func (rs records) Swap(i, j int)      { rs[i], rs[j] = rs[j], rs[i] }
+
+// run is a sorted sequence of records spilled into a temporary file. Each
+// record is stored as two lines: the key followed by the serialized triple.
+type run struct {
+	f   *os.File
+	s   *bufio.Scanner
+	cur record
+}
+
+// spill sorts the provided records and writes them into a new temporary file.
+func spill(rs records) (*run, error) {
+	sort.Sort(rs)
+	f, err := ioutil.TempFile("", "badwolf-sort-")
+	if err != nil {
+		return nil, err
+	}
+	r := &run{f: f}
+	bw := bufio.NewWriter(f)
+	for _, rc := range rs {
+		if _, err := fmt.Fprintf(bw, "%s\n%s\n", rc.key, rc.line); err != nil {
+			r.close()
+			return nil, err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		r.close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		r.close()
+		return nil, err
+	}
+	r.s = bufio.NewScanner(f)
+	r.s.Buffer(nil, 64*1024*1024)
+	return r, nil
+}
+
+// next advances to the following record in the run. It returns false once the
+// run is exhausted.
+func (r *run) next() (bool, error) {
+	if !r.s.Scan() {
+		return false, r.s.Err()
+	}
+	k := r.s.Text()
+	if !r.s.Scan() {
+		if err := r.s.Err(); err != nil {
+			return false, err
+		}
+		return false, fmt.Errorf("io.WriteGraphWithOptions found a truncated sorted run in %s", r.f.Name())
+	}
+	r.cur = record{key: k, line: r.s.Text()}
+	return true, nil
+}
+
+// close releases the temporary file backing the run.
+func (r *run) close() {
+	r.f.Close()
+	os.Remove(r.f.Name())
+}
+
+// runHeap keeps the runs being merged ordered by their current record.
+type runHeap []*run
+
+func (h runHeap) Len() int            { return len(h) }
+func (h runHeap) Less(i, j int) bool  { return h[i].cur.less(h[j].cur) }
+func (h runHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(*run)) }
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// writeSortedGraph serializes the graph into the writer in canonical order.
+// At most st records are kept in memory; larger graphs are sorted in runs
+// spilled into temporary files which are merged while writing.
+func writeSortedGraph(ctx context.Context, w io.Writer, g storage.Graph, st int) (int, error) {
+	var (
+		wg   sync.WaitGroup
+		tErr error
+		sErr error
+		rs   records
+		runs []*run
+	)
+	defer func() {
+		for _, r := range runs {
+			r.close()
+		}
+	}()
+	ts := make(chan *triple.Triple)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tErr = g.Triples(ctx, storage.DefaultLookup, ts)
+	}()
+	for t := range ts {
+		if sErr != nil {
+			continue
+		}
+		rs = append(rs, newRecord(t))
+		if len(rs) >= st {
+			r, err := spill(rs)
+			if err != nil {
+				sErr = err
+				continue
+			}
+			runs, rs = append(runs, r), rs[:0]
+		}
+	}
+	wg.Wait()
+	if tErr != nil {
+		return 0, tErr
+	}
+	if sErr != nil {
+		return 0, sErr
+	}
+
+	bw := bufio.NewWriter(w)
+	cnt := 0
+	write := func(rc record) error {
+		if _, err := fmt.Fprintf(bw, "%s\n", rc.line); err != nil {
+			return err
+		}
+		cnt++
+		return nil
+	}
+	if len(runs) == 0 {
+		sort.Sort(rs)
+		for _, rc := range rs {
+			if err := write(rc); err != nil {
+				return 0, err
+			}
+		}
+	} else {
+		if len(rs) > 0 {
+			r, err := spill(rs)
+			if err != nil {
+				return 0, err
+			}
+			runs = append(runs, r)
+		}
+		h := &runHeap{}
+		for _, r := range runs {
+			ok, err := r.next()
+			if err != nil {
+				return 0, err
+			}
+			if ok {
+				heap.Push(h, r)
+			}
+		}
+		for h.Len() > 0 {
+			r := (*h)[0]
+			if err := write(r.cur); err != nil {
+				return 0, err
+			}
+			ok, err := r.next()
+			if err != nil {
+				return 0, err
+			}
+			if ok {
+				heap.Fix(h, 0)
+			} else {
+				heap.Pop(h)
+			}
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+	return cnt, nil
+}
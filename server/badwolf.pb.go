@@ -0,0 +1,909 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: badwolf.proto
+
+package server
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Error_Code int32
+
+const (
+	Error_UNKNOWN Error_Code = 0
+	// PARSE errors are returned for statements that are not valid BQL.
+	Error_PARSE Error_Code = 1
+	// PLAN errors are returned when a plan cannot be built for the statement.
+	Error_PLAN Error_Code = 2
+	// EXECUTION errors are returned when the plan fails to run.
+	Error_EXECUTION Error_Code = 3
+)
+
+// Enum value maps for Error_Code.
+var (
+	Error_Code_name = map[int32]string{
+		0: "UNKNOWN",
+		1: "PARSE",
+		2: "PLAN",
+		3: "EXECUTION",
+	}
+	Error_Code_value = map[string]int32{
+		"UNKNOWN":   0,
+		"PARSE":     1,
+		"PLAN":      2,
+		"EXECUTION": 3,
+	}
+)
+
+func (x Error_Code) Enum() *Error_Code {
+	p := new(Error_Code)
+	*p = x
+	return p
+}
+
+func (x Error_Code) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Error_Code) Descriptor() protoreflect.EnumDescriptor {
+	return file_badwolf_proto_enumTypes[0].Descriptor()
+}
+
+func (Error_Code) Type() protoreflect.EnumType {
+	return &file_badwolf_proto_enumTypes[0]
+}
+
+func (x Error_Code) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Error_Code.Descriptor instead.
+func (Error_Code) EnumDescriptor() ([]byte, []int) {
+	return file_badwolf_proto_rawDescGZIP(), []int{8, 0}
+}
+
+// ExecuteRequest contains the BQL statement to run.
+type ExecuteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bql           string                 `protobuf:"bytes,1,opt,name=bql,proto3" json:"bql,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecuteRequest) Reset() {
+	*x = ExecuteRequest{}
+	mi := &file_badwolf_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecuteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecuteRequest) ProtoMessage() {}
+
+func (x *ExecuteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_badwolf_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecuteRequest.ProtoReflect.Descriptor instead.
+func (*ExecuteRequest) Descriptor() ([]byte, []int) {
+	return file_badwolf_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ExecuteRequest) GetBql() string {
+	if x != nil {
+		return x.Bql
+	}
+	return ""
+}
+
+// ExecuteResponse contains one of the parts of the result of a statement.
+type ExecuteResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Response:
+	//
+	//	*ExecuteResponse_Header
+	//	*ExecuteResponse_Row
+	//	*ExecuteResponse_Error
+	Response      isExecuteResponse_Response `protobuf_oneof:"response"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecuteResponse) Reset() {
+	*x = ExecuteResponse{}
+	mi := &file_badwolf_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecuteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecuteResponse) ProtoMessage() {}
+
+func (x *ExecuteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_badwolf_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecuteResponse.ProtoReflect.Descriptor instead.
+func (*ExecuteResponse) Descriptor() ([]byte, []int) {
+	return file_badwolf_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ExecuteResponse) GetResponse() isExecuteResponse_Response {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+func (x *ExecuteResponse) GetHeader() *Header {
+	if x != nil {
+		if x, ok := x.Response.(*ExecuteResponse_Header); ok {
+			return x.Header
+		}
+	}
+	return nil
+}
+
+func (x *ExecuteResponse) GetRow() *Row {
+	if x != nil {
+		if x, ok := x.Response.(*ExecuteResponse_Row); ok {
+			return x.Row
+		}
+	}
+	return nil
+}
+
+func (x *ExecuteResponse) GetError() *Error {
+	if x != nil {
+		if x, ok := x.Response.(*ExecuteResponse_Error); ok {
+			return x.Error
+		}
+	}
+	return nil
+}
+
+type isExecuteResponse_Response interface {
+	isExecuteResponse_Response()
+}
+
+type ExecuteResponse_Header struct {
+	Header *Header `protobuf:"bytes,1,opt,name=header,proto3,oneof"`
+}
+
+type ExecuteResponse_Row struct {
+	Row *Row `protobuf:"bytes,2,opt,name=row,proto3,oneof"`
+}
+
+type ExecuteResponse_Error struct {
+	Error *Error `protobuf:"bytes,3,opt,name=error,proto3,oneof"`
+}
+
+func (*ExecuteResponse_Header) isExecuteResponse_Response() {}
+
+func (*ExecuteResponse_Row) isExecuteResponse_Response() {}
+
+func (*ExecuteResponse_Error) isExecuteResponse_Response() {}
+
+// Header lists the bindings of the result table in the order the cells of
+// each row are returned.
+type Header struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bindings      []string               `protobuf:"bytes,1,rep,name=bindings,proto3" json:"bindings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Header) Reset() {
+	*x = Header{}
+	mi := &file_badwolf_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Header) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Header) ProtoMessage() {}
+
+func (x *Header) ProtoReflect() protoreflect.Message {
+	mi := &file_badwolf_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Header.ProtoReflect.Descriptor instead.
+func (*Header) Descriptor() ([]byte, []int) {
+	return file_badwolf_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Header) GetBindings() []string {
+	if x != nil {
+		return x.Bindings
+	}
+	return nil
+}
+
+// Row contains the cells of a row of the result table. Cells are returned in
+// the order of the header bindings.
+type Row struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cells         []*Cell                `protobuf:"bytes,1,rep,name=cells,proto3" json:"cells,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Row) Reset() {
+	*x = Row{}
+	mi := &file_badwolf_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Row) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Row) ProtoMessage() {}
+
+func (x *Row) ProtoReflect() protoreflect.Message {
+	mi := &file_badwolf_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Row.ProtoReflect.Descriptor instead.
+func (*Row) Descriptor() ([]byte, []int) {
+	return file_badwolf_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Row) GetCells() []*Cell {
+	if x != nil {
+		return x.Cells
+	}
+	return nil
+}
+
+// Cell contains a typed value of a row. A cell with no value represents a
+// binding with no value in the row.
+type Cell struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Value:
+	//
+	//	*Cell_Text
+	//	*Cell_Node
+	//	*Cell_Predicate
+	//	*Cell_Literal
+	//	*Cell_Time
+	Value         isCell_Value `protobuf_oneof:"value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Cell) Reset() {
+	*x = Cell{}
+	mi := &file_badwolf_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Cell) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Cell) ProtoMessage() {}
+
+func (x *Cell) ProtoReflect() protoreflect.Message {
+	mi := &file_badwolf_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Cell.ProtoReflect.Descriptor instead.
+func (*Cell) Descriptor() ([]byte, []int) {
+	return file_badwolf_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Cell) GetValue() isCell_Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Cell) GetText() string {
+	if x != nil {
+		if x, ok := x.Value.(*Cell_Text); ok {
+			return x.Text
+		}
+	}
+	return ""
+}
+
+func (x *Cell) GetNode() *Node {
+	if x != nil {
+		if x, ok := x.Value.(*Cell_Node); ok {
+			return x.Node
+		}
+	}
+	return nil
+}
+
+func (x *Cell) GetPredicate() *Predicate {
+	if x != nil {
+		if x, ok := x.Value.(*Cell_Predicate); ok {
+			return x.Predicate
+		}
+	}
+	return nil
+}
+
+func (x *Cell) GetLiteral() *Literal {
+	if x != nil {
+		if x, ok := x.Value.(*Cell_Literal); ok {
+			return x.Literal
+		}
+	}
+	return nil
+}
+
+func (x *Cell) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		if x, ok := x.Value.(*Cell_Time); ok {
+			return x.Time
+		}
+	}
+	return nil
+}
+
+type isCell_Value interface {
+	isCell_Value()
+}
+
+type Cell_Text struct {
+	Text string `protobuf:"bytes,1,opt,name=text,proto3,oneof"`
+}
+
+type Cell_Node struct {
+	Node *Node `protobuf:"bytes,2,opt,name=node,proto3,oneof"`
+}
+
+type Cell_Predicate struct {
+	Predicate *Predicate `protobuf:"bytes,3,opt,name=predicate,proto3,oneof"`
+}
+
+type Cell_Literal struct {
+	Literal *Literal `protobuf:"bytes,4,opt,name=literal,proto3,oneof"`
+}
+
+type Cell_Time struct {
+	Time *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=time,proto3,oneof"`
+}
+
+func (*Cell_Text) isCell_Value() {}
+
+func (*Cell_Node) isCell_Value() {}
+
+func (*Cell_Predicate) isCell_Value() {}
+
+func (*Cell_Literal) isCell_Value() {}
+
+func (*Cell_Time) isCell_Value() {}
+
+// Node represents a BadWolf node.
+type Node struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Node) Reset() {
+	*x = Node{}
+	mi := &file_badwolf_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Node) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Node) ProtoMessage() {}
+
+func (x *Node) ProtoReflect() protoreflect.Message {
+	mi := &file_badwolf_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Node.ProtoReflect.Descriptor instead.
+func (*Node) Descriptor() ([]byte, []int) {
+	return file_badwolf_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Node) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Node) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+// Predicate represents a BadWolf predicate. The anchor is only set for
+// temporal predicates.
+type Predicate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Anchor        *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=anchor,proto3" json:"anchor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Predicate) Reset() {
+	*x = Predicate{}
+	mi := &file_badwolf_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Predicate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Predicate) ProtoMessage() {}
+
+func (x *Predicate) ProtoReflect() protoreflect.Message {
+	mi := &file_badwolf_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Predicate.ProtoReflect.Descriptor instead.
+func (*Predicate) Descriptor() ([]byte, []int) {
+	return file_badwolf_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Predicate) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Predicate) GetAnchor() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Anchor
+	}
+	return nil
+}
+
+// Literal represents a BadWolf literal. Decimals are encoded as their exact
+// decimal string, for instance "-12.375".
+type Literal struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Value:
+	//
+	//	*Literal_Bool
+	//	*Literal_Int64
+	//	*Literal_Float64
+	//	*Literal_Text
+	//	*Literal_Blob
+	//	*Literal_Decimal
+	Value         isLiteral_Value `protobuf_oneof:"value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Literal) Reset() {
+	*x = Literal{}
+	mi := &file_badwolf_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Literal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Literal) ProtoMessage() {}
+
+func (x *Literal) ProtoReflect() protoreflect.Message {
+	mi := &file_badwolf_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Literal.ProtoReflect.Descriptor instead.
+func (*Literal) Descriptor() ([]byte, []int) {
+	return file_badwolf_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Literal) GetValue() isLiteral_Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Literal) GetBool() bool {
+	if x != nil {
+		if x, ok := x.Value.(*Literal_Bool); ok {
+			return x.Bool
+		}
+	}
+	return false
+}
+
+func (x *Literal) GetInt64() int64 {
+	if x != nil {
+		if x, ok := x.Value.(*Literal_Int64); ok {
+			return x.Int64
+		}
+	}
+	return 0
+}
+
+func (x *Literal) GetFloat64() float64 {
+	if x != nil {
+		if x, ok := x.Value.(*Literal_Float64); ok {
+			return x.Float64
+		}
+	}
+	return 0
+}
+
+func (x *Literal) GetText() string {
+	if x != nil {
+		if x, ok := x.Value.(*Literal_Text); ok {
+			return x.Text
+		}
+	}
+	return ""
+}
+
+func (x *Literal) GetBlob() []byte {
+	if x != nil {
+		if x, ok := x.Value.(*Literal_Blob); ok {
+			return x.Blob
+		}
+	}
+	return nil
+}
+
+func (x *Literal) GetDecimal() string {
+	if x != nil {
+		if x, ok := x.Value.(*Literal_Decimal); ok {
+			return x.Decimal
+		}
+	}
+	return ""
+}
+
+type isLiteral_Value interface {
+	isLiteral_Value()
+}
+
+type Literal_Bool struct {
+	Bool bool `protobuf:"varint,1,opt,name=bool,proto3,oneof"`
+}
+
+type Literal_Int64 struct {
+	Int64 int64 `protobuf:"varint,2,opt,name=int64,proto3,oneof"`
+}
+
+type Literal_Float64 struct {
+	Float64 float64 `protobuf:"fixed64,3,opt,name=float64,proto3,oneof"`
+}
+
+type Literal_Text struct {
+	Text string `protobuf:"bytes,4,opt,name=text,proto3,oneof"`
+}
+
+type Literal_Blob struct {
+	Blob []byte `protobuf:"bytes,5,opt,name=blob,proto3,oneof"`
+}
+
+type Literal_Decimal struct {
+	Decimal string `protobuf:"bytes,6,opt,name=decimal,proto3,oneof"`
+}
+
+func (*Literal_Bool) isLiteral_Value() {}
+
+func (*Literal_Int64) isLiteral_Value() {}
+
+func (*Literal_Float64) isLiteral_Value() {}
+
+func (*Literal_Text) isLiteral_Value() {}
+
+func (*Literal_Blob) isLiteral_Value() {}
+
+func (*Literal_Decimal) isLiteral_Value() {}
+
+// Error describes why a statement failed.
+type Error struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          Error_Code             `protobuf:"varint,1,opt,name=code,proto3,enum=badwolf.server.Error_Code" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	mi := &file_badwolf_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_badwolf_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_badwolf_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Error) GetCode() Error_Code {
+	if x != nil {
+		return x.Code
+	}
+	return Error_UNKNOWN
+}
+
+func (x *Error) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+var File_badwolf_proto protoreflect.FileDescriptor
+
+const file_badwolf_proto_rawDesc = "" +
+	"\n" +
+	"\rbadwolf.proto\x12\x0ebadwolf.server\x1a\x1fgoogle/protobuf/timestamp.proto\"\"\n" +
+	"\x0eExecuteRequest\x12\x10\n" +
+	"\x03bql\x18\x01 \x01(\tR\x03bql\"\xa7\x01\n" +
+	"\x0fExecuteResponse\x120\n" +
+	"\x06header\x18\x01 \x01(\v2\x16.badwolf.server.HeaderH\x00R\x06header\x12'\n" +
+	"\x03row\x18\x02 \x01(\v2\x13.badwolf.server.RowH\x00R\x03row\x12-\n" +
+	"\x05error\x18\x03 \x01(\v2\x15.badwolf.server.ErrorH\x00R\x05errorB\n" +
+	"\n" +
+	"\bresponse\"$\n" +
+	"\x06Header\x12\x1a\n" +
+	"\bbindings\x18\x01 \x03(\tR\bbindings\"1\n" +
+	"\x03Row\x12*\n" +
+	"\x05cells\x18\x01 \x03(\v2\x14.badwolf.server.CellR\x05cells\"\xf3\x01\n" +
+	"\x04Cell\x12\x14\n" +
+	"\x04text\x18\x01 \x01(\tH\x00R\x04text\x12*\n" +
+	"\x04node\x18\x02 \x01(\v2\x14.badwolf.server.NodeH\x00R\x04node\x129\n" +
+	"\tpredicate\x18\x03 \x01(\v2\x19.badwolf.server.PredicateH\x00R\tpredicate\x123\n" +
+	"\aliteral\x18\x04 \x01(\v2\x17.badwolf.server.LiteralH\x00R\aliteral\x120\n" +
+	"\x04time\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampH\x00R\x04timeB\a\n" +
+	"\x05value\"*\n" +
+	"\x04Node\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\"O\n" +
+	"\tPredicate\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x122\n" +
+	"\x06anchor\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x06anchor\"\xa4\x01\n" +
+	"\aLiteral\x12\x14\n" +
+	"\x04bool\x18\x01 \x01(\bH\x00R\x04bool\x12\x16\n" +
+	"\x05int64\x18\x02 \x01(\x03H\x00R\x05int64\x12\x1a\n" +
+	"\afloat64\x18\x03 \x01(\x01H\x00R\afloat64\x12\x14\n" +
+	"\x04text\x18\x04 \x01(\tH\x00R\x04text\x12\x14\n" +
+	"\x04blob\x18\x05 \x01(\fH\x00R\x04blob\x12\x1a\n" +
+	"\adecimal\x18\x06 \x01(\tH\x00R\adecimalB\a\n" +
+	"\x05value\"\x8a\x01\n" +
+	"\x05Error\x12.\n" +
+	"\x04code\x18\x01 \x01(\x0e2\x1a.badwolf.server.Error.CodeR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"7\n" +
+	"\x04Code\x12\v\n" +
+	"\aUNKNOWN\x10\x00\x12\t\n" +
+	"\x05PARSE\x10\x01\x12\b\n" +
+	"\x04PLAN\x10\x02\x12\r\n" +
+	"\tEXECUTION\x10\x032W\n" +
+	"\aBadWolf\x12L\n" +
+	"\aExecute\x12\x1e.badwolf.server.ExecuteRequest\x1a\x1f.badwolf.server.ExecuteResponse0\x01B\"Z github.com/google/badwolf/serverb\x06proto3"
+
+var (
+	file_badwolf_proto_rawDescOnce sync.Once
+	file_badwolf_proto_rawDescData []byte
+)
+
+func file_badwolf_proto_rawDescGZIP() []byte {
+	file_badwolf_proto_rawDescOnce.Do(func() {
+		file_badwolf_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_badwolf_proto_rawDesc), len(file_badwolf_proto_rawDesc)))
+	})
+	return file_badwolf_proto_rawDescData
+}
+
+var file_badwolf_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_badwolf_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_badwolf_proto_goTypes = []any{
+	(Error_Code)(0),               // 0: badwolf.server.Error.Code
+	(*ExecuteRequest)(nil),        // 1: badwolf.server.ExecuteRequest
+	(*ExecuteResponse)(nil),       // 2: badwolf.server.ExecuteResponse
+	(*Header)(nil),                // 3: badwolf.server.Header
+	(*Row)(nil),                   // 4: badwolf.server.Row
+	(*Cell)(nil),                  // 5: badwolf.server.Cell
+	(*Node)(nil),                  // 6: badwolf.server.Node
+	(*Predicate)(nil),             // 7: badwolf.server.Predicate
+	(*Literal)(nil),               // 8: badwolf.server.Literal
+	(*Error)(nil),                 // 9: badwolf.server.Error
+	(*timestamppb.Timestamp)(nil), // 10: google.protobuf.Timestamp
+}
+var file_badwolf_proto_depIdxs = []int32{
+	3,  // 0: badwolf.server.ExecuteResponse.header:type_name -> badwolf.server.Header
+	4,  // 1: badwolf.server.ExecuteResponse.row:type_name -> badwolf.server.Row
+	9,  // 2: badwolf.server.ExecuteResponse.error:type_name -> badwolf.server.Error
+	5,  // 3: badwolf.server.Row.cells:type_name -> badwolf.server.Cell
+	6,  // 4: badwolf.server.Cell.node:type_name -> badwolf.server.Node
+	7,  // 5: badwolf.server.Cell.predicate:type_name -> badwolf.server.Predicate
+	8,  // 6: badwolf.server.Cell.literal:type_name -> badwolf.server.Literal
+	10, // 7: badwolf.server.Cell.time:type_name -> google.protobuf.Timestamp
+	10, // 8: badwolf.server.Predicate.anchor:type_name -> google.protobuf.Timestamp
+	0,  // 9: badwolf.server.Error.code:type_name -> badwolf.server.Error.Code
+	1,  // 10: badwolf.server.BadWolf.Execute:input_type -> badwolf.server.ExecuteRequest
+	2,  // 11: badwolf.server.BadWolf.Execute:output_type -> badwolf.server.ExecuteResponse
+	11, // [11:12] is the sub-list for method output_type
+	10, // [10:11] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_badwolf_proto_init() }
+func file_badwolf_proto_init() {
+	if File_badwolf_proto != nil {
+		return
+	}
+	file_badwolf_proto_msgTypes[1].OneofWrappers = []any{
+		(*ExecuteResponse_Header)(nil),
+		(*ExecuteResponse_Row)(nil),
+		(*ExecuteResponse_Error)(nil),
+	}
+	file_badwolf_proto_msgTypes[4].OneofWrappers = []any{
+		(*Cell_Text)(nil),
+		(*Cell_Node)(nil),
+		(*Cell_Predicate)(nil),
+		(*Cell_Literal)(nil),
+		(*Cell_Time)(nil),
+	}
+	file_badwolf_proto_msgTypes[7].OneofWrappers = []any{
+		(*Literal_Bool)(nil),
+		(*Literal_Int64)(nil),
+		(*Literal_Float64)(nil),
+		(*Literal_Text)(nil),
+		(*Literal_Blob)(nil),
+		(*Literal_Decimal)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_badwolf_proto_rawDesc), len(file_badwolf_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_badwolf_proto_goTypes,
+		DependencyIndexes: file_badwolf_proto_depIdxs,
+		EnumInfos:         file_badwolf_proto_enumTypes,
+		MessageInfos:      file_badwolf_proto_msgTypes,
+	}.Build()
+	File_badwolf_proto = out.File
+	file_badwolf_proto_goTypes = nil
+	file_badwolf_proto_depIdxs = nil
+}
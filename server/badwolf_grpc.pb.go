@@ -0,0 +1,150 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: badwolf.proto
+
+package server
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	BadWolf_Execute_FullMethodName = "/badwolf.server.BadWolf/Execute"
+)
+
+// BadWolfClient is the client API for BadWolf service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// BadWolf runs BQL statements against a storage.Store.
+type BadWolfClient interface {
+	// Execute parses and runs the provided BQL statement. A successful query
+	// streams back a header with the bindings of the result table followed by
+	// one message per row. A statement that cannot be parsed, planned, or
+	// executed streams back a single error message instead.
+	Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExecuteResponse], error)
+}
+
+type badWolfClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBadWolfClient(cc grpc.ClientConnInterface) BadWolfClient {
+	return &badWolfClient{cc}
+}
+
+func (c *badWolfClient) Execute(ctx context.Context, in *ExecuteRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExecuteResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &BadWolf_ServiceDesc.Streams[0], BadWolf_Execute_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExecuteRequest, ExecuteResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BadWolf_ExecuteClient = grpc.ServerStreamingClient[ExecuteResponse]
+
+// BadWolfServer is the server API for BadWolf service.
+// All implementations must embed UnimplementedBadWolfServer
+// for forward compatibility.
+//
+// BadWolf runs BQL statements against a storage.Store.
+type BadWolfServer interface {
+	// Execute parses and runs the provided BQL statement. A successful query
+	// streams back a header with the bindings of the result table followed by
+	// one message per row. A statement that cannot be parsed, planned, or
+	// executed streams back a single error message instead.
+	Execute(*ExecuteRequest, grpc.ServerStreamingServer[ExecuteResponse]) error
+	mustEmbedUnimplementedBadWolfServer()
+}
+
+// UnimplementedBadWolfServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedBadWolfServer struct{}
+
+func (UnimplementedBadWolfServer) Execute(*ExecuteRequest, grpc.ServerStreamingServer[ExecuteResponse]) error {
+	return status.Error(codes.Unimplemented, "method Execute not implemented")
+}
+func (UnimplementedBadWolfServer) mustEmbedUnimplementedBadWolfServer() {}
+func (UnimplementedBadWolfServer) testEmbeddedByValue()                 {}
+
+// UnsafeBadWolfServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BadWolfServer will
+// result in compilation errors.
+type UnsafeBadWolfServer interface {
+	mustEmbedUnimplementedBadWolfServer()
+}
+
+func RegisterBadWolfServer(s grpc.ServiceRegistrar, srv BadWolfServer) {
+	// If the following call panics, it indicates UnimplementedBadWolfServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&BadWolf_ServiceDesc, srv)
+}
+
+func _BadWolf_Execute_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExecuteRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BadWolfServer).Execute(m, &grpc.GenericServerStream[ExecuteRequest, ExecuteResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type BadWolf_ExecuteServer = grpc.ServerStreamingServer[ExecuteResponse]
+
+// BadWolf_ServiceDesc is the grpc.ServiceDesc for BadWolf service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var BadWolf_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "badwolf.server.BadWolf",
+	HandlerType: (*BadWolfServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Execute",
+			Handler:       _BadWolf_Execute_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "badwolf.proto",
+}
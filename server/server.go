@@ -0,0 +1,227 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative badwolf.proto
+
+// Package server exposes the BQL planner as a gRPC service. The service
+// parses the received BQL statements, runs them against a storage.Store, and
+// streams back the rows of the result table as typed cells.
+package server
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/google/badwolf/bql/grammar"
+	"github.com/google/badwolf/bql/planner"
+	"github.com/google/badwolf/bql/semantic"
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// parseMu serializes parsing. The semantic hooks of the BQL grammar are
+// singletons that keep state while a statement is parsed, hence statements
+// cannot be parsed concurrently.
+var parseMu sync.Mutex
+
+// Server implements the BadWolf gRPC service on top of a storage.Store.
+type Server struct {
+	UnimplementedBadWolfServer
+
+	store    storage.Store
+	chanSize int
+	parser   *grammar.Parser
+}
+
+// New returns a server that runs the received statements against the provided
+// store. The channel size is passed to the planner.
+func New(store storage.Store, chanSize int) (*Server, error) {
+	p, err := grammar.NewParser(grammar.SemanticBQL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize the BQL parser; %v", err)
+	}
+	return &Server{
+		store:    store,
+		chanSize: chanSize,
+		parser:   p,
+	}, nil
+}
+
+// Execute parses and runs the requested statement and streams back the header
+// and the rows of the result as they are produced by the planner. Statements
+// that fail to parse, plan, or execute are reported with a single error
+// response; errors found once rows were already sent are reported with an
+// error response after them. Canceling the call cancels the context passed to
+// the planner and the storage driver, and the call returns the status of the
+// context.
+func (s *Server) Execute(req *ExecuteRequest, stream grpc.ServerStreamingServer[ExecuteResponse]) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	stm := &semantic.Statement{}
+	parseMu.Lock()
+	err := s.parser.Parse(grammar.NewLLk(req.GetBql(), 1), stm)
+	parseMu.Unlock()
+	if err != nil {
+		return sendError(stream, Error_PARSE, err)
+	}
+	pln, err := planner.New(ctx, s.store, stm, s.chanSize, nil)
+	if err != nil {
+		return sendError(stream, Error_PLAN, err)
+	}
+	var (
+		bs      []string
+		sendErr error
+	)
+	start := func(b []string) error {
+		bs = b
+		sendErr = sendHeader(stream, b)
+		return sendErr
+	}
+	rows, errc := make(chan table.Row), make(chan error, 1)
+	go func() {
+		errc <- planner.Stream(ctx, pln, start, rows)
+	}()
+	for r := range rows {
+		if sendErr != nil {
+			// Keep draining the rows until the planner notices the canceled
+			// context.
+			continue
+		}
+		if sendErr = sendRow(stream, bs, r); sendErr != nil {
+			cancel()
+		}
+	}
+	err = <-errc
+	if sendErr != nil {
+		return sendErr
+	}
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if err != nil {
+		return sendError(stream, Error_EXECUTION, err)
+	}
+	return nil
+}
+
+// sendError streams back the error response for the statement.
+func sendError(stream grpc.ServerStreamingServer[ExecuteResponse], code Error_Code, err error) error {
+	return stream.Send(&ExecuteResponse{
+		Response: &ExecuteResponse_Error{
+			Error: &Error{
+				Code:    code,
+				Message: err.Error(),
+			},
+		},
+	})
+}
+
+// sendHeader streams back the header with the provided bindings.
+func sendHeader(stream grpc.ServerStreamingServer[ExecuteResponse], bs []string) error {
+	return stream.Send(&ExecuteResponse{
+		Response: &ExecuteResponse_Header{
+			Header: &Header{Bindings: bs},
+		},
+	})
+}
+
+// sendRow streams back the cells of the provided row in the order of the
+// provided bindings.
+func sendRow(stream grpc.ServerStreamingServer[ExecuteResponse], bs []string, r table.Row) error {
+	row := &Row{}
+	for _, b := range bs {
+		c, err := NewCell(r[b])
+		if err != nil {
+			return err
+		}
+		row.Cells = append(row.Cells, c)
+	}
+	return stream.Send(&ExecuteResponse{
+		Response: &ExecuteResponse_Row{Row: row},
+	})
+}
+
+// NewCell returns the typed protocol buffer representation of the provided
+// table cell. A nil cell returns a cell with no value.
+func NewCell(c *table.Cell) (*Cell, error) {
+	switch {
+	case c == nil:
+		return &Cell{}, nil
+	case c.S != nil:
+		return &Cell{Value: &Cell_Text{Text: *c.S}}, nil
+	case c.N != nil:
+		return &Cell{Value: &Cell_Node{Node: &Node{
+			Type: c.N.Type().String(),
+			Id:   c.N.ID().String(),
+		}}}, nil
+	case c.P != nil:
+		p := &Predicate{Id: string(c.P.ID())}
+		if c.P.Type() == predicate.Temporal {
+			ta, err := c.P.TimeAnchor()
+			if err != nil {
+				return nil, err
+			}
+			p.Anchor = timestamppb.New(*ta)
+		}
+		return &Cell{Value: &Cell_Predicate{Predicate: p}}, nil
+	case c.L != nil:
+		l, err := newLiteral(c.L)
+		if err != nil {
+			return nil, err
+		}
+		return &Cell{Value: &Cell_Literal{Literal: l}}, nil
+	case c.T != nil:
+		return &Cell{Value: &Cell_Time{Time: timestamppb.New(*c.T)}}, nil
+	default:
+		return &Cell{}, nil
+	}
+}
+
+// newLiteral returns the typed protocol buffer representation of the provided
+// literal.
+func newLiteral(l *literal.Literal) (*Literal, error) {
+	switch l.Type() {
+	case literal.Bool:
+		v, err := l.Bool()
+		return &Literal{Value: &Literal_Bool{Bool: v}}, err
+	case literal.Int64:
+		v, err := l.Int64()
+		return &Literal{Value: &Literal_Int64{Int64: v}}, err
+	case literal.Float64:
+		v, err := l.Float64()
+		return &Literal{Value: &Literal_Float64{Float64: v}}, err
+	case literal.Text:
+		v, err := l.Text()
+		return &Literal{Value: &Literal_Text{Text: v}}, err
+	case literal.Blob:
+		v, err := l.Blob()
+		return &Literal{Value: &Literal_Blob{Blob: v}}, err
+	case literal.Decimal:
+		v, err := l.Decimal()
+		if err != nil {
+			return nil, err
+		}
+		n, _ := v.FloatPrec()
+		return &Literal{Value: &Literal_Decimal{Decimal: v.FloatString(n)}}, nil
+	default:
+		return nil, fmt.Errorf("unknown literal type %v in %v", l.Type(), l)
+	}
+}
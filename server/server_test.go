@@ -0,0 +1,254 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	bio "github.com/google/badwolf/io"
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple/literal"
+)
+
+const testTriples = `/u<joe> "parent_of"@[] /u<mary>
+	/u<joe> "age"@[] "42"^^type:int64
+	/u<peter> "bought"@[2016-01-01T00:00:00Z] /c<mini>
+	/c<mini> "price"@[] "-12.3750"^^type:decimal`
+
+func populateTestStore(t *testing.T) storage.Store {
+	s, ctx := memory.NewStore(), context.Background()
+	g, err := s.NewGraph(ctx, "?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bio.ReadIntoGraph(ctx, g, bytes.NewBufferString(testTriples), literal.DefaultBuilder()); err != nil {
+		t.Fatalf("io.ReadIntoGraph failed to read test graph with error %v", err)
+	}
+	return s
+}
+
+// newTestClient starts a server for the provided store listening on an in
+// memory connection and returns a client connected to it.
+func newTestClient(t *testing.T, s storage.Store) BadWolfClient {
+	srv, err := New(s, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	RegisterBadWolfServer(gs, srv)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewBadWolfClient(conn)
+}
+
+// execute runs the provided query and returns all the streamed responses.
+func execute(ctx context.Context, c BadWolfClient, q string) ([]*ExecuteResponse, error) {
+	stream, err := c.Execute(ctx, &ExecuteRequest{Bql: q})
+	if err != nil {
+		return nil, err
+	}
+	var res []*ExecuteResponse
+	for {
+		r, err := stream.Recv()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return res, err
+		}
+		res = append(res, r)
+	}
+}
+
+func TestExecute(t *testing.T) {
+	c, ctx := newTestClient(t, populateTestStore(t)), context.Background()
+	res, err := execute(ctx, c, `select ?p, ?o from ?test where {/u<joe> ?p ?o} order by ?p;`)
+	if err != nil {
+		t.Fatalf("Execute failed with error %v", err)
+	}
+	want := []*ExecuteResponse{
+		{Response: &ExecuteResponse_Header{Header: &Header{Bindings: []string{"?p", "?o"}}}},
+		{Response: &ExecuteResponse_Row{Row: &Row{Cells: []*Cell{
+			{Value: &Cell_Predicate{Predicate: &Predicate{Id: "age"}}},
+			{Value: &Cell_Literal{Literal: &Literal{Value: &Literal_Int64{Int64: 42}}}},
+		}}}},
+		{Response: &ExecuteResponse_Row{Row: &Row{Cells: []*Cell{
+			{Value: &Cell_Predicate{Predicate: &Predicate{Id: "parent_of"}}},
+			{Value: &Cell_Node{Node: &Node{Type: "/u", Id: "mary"}}},
+		}}}},
+	}
+	if len(res) != len(want) {
+		t.Fatalf("Execute returned %d responses; want %d", len(res), len(want))
+	}
+	for i := range want {
+		if !proto.Equal(res[i], want[i]) {
+			t.Errorf("Execute returned the wrong response %d; got %v, want %v", i, res[i], want[i])
+		}
+	}
+
+	res, err = execute(ctx, c, `select ?p from ?test where {/u<peter> ?p /c<mini>};`)
+	if err != nil {
+		t.Fatalf("Execute failed with error %v", err)
+	}
+	anchor := &Predicate{Id: "bought", Anchor: timestamppb.New(time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC))}
+	if len(res) != 2 || !proto.Equal(res[1].GetRow().GetCells()[0].GetPredicate(), anchor) {
+		t.Errorf("Execute returned %v; want a row with predicate %v", res, anchor)
+	}
+
+	res, err = execute(ctx, c, `select ?o from ?test where {/c<mini> "price"@[] ?o};`)
+	if err != nil {
+		t.Fatalf("Execute failed with error %v", err)
+	}
+	price := &Literal{Value: &Literal_Decimal{Decimal: "-12.375"}}
+	if len(res) != 2 || !proto.Equal(res[1].GetRow().GetCells()[0].GetLiteral(), price) {
+		t.Errorf("Execute returned %v; want a row with decimal literal %v", res, price)
+	}
+}
+
+func TestExecuteConcurrently(t *testing.T) {
+	c, ctx := newTestClient(t, populateTestStore(t)), context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := execute(ctx, c, `select ?o from ?test where {/u<joe> "parent_of"@[] ?o};`)
+			if err != nil || len(res) != 2 || res[1].GetRow() == nil {
+				t.Errorf("Execute returned %v with error %v; want a header and one row", res, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestExecuteErrors(t *testing.T) {
+	c, ctx := newTestClient(t, populateTestStore(t)), context.Background()
+	testTable := []struct {
+		q    string
+		code Error_Code
+	}{
+		{q: `select ?s from ?test where {?s ?p};`, code: Error_PARSE},
+		{q: `not bql at all`, code: Error_PARSE},
+		{q: `select ?s, ?o from ?test where { {?s "parent_of"@[] ?o} union {?s "bought"@[,] ?c} };`, code: Error_PLAN},
+		{q: `select ?s from ?unknown where {?s ?p ?o};`, code: Error_EXECUTION},
+		{q: `select ?s from ?test where {?s "age"@[] ?o . filter(?o > "1"^^type:text)};`, code: Error_EXECUTION},
+	}
+	for _, entry := range testTable {
+		res, err := execute(ctx, c, entry.q)
+		if err != nil {
+			t.Errorf("Execute should have returned a structured error for %q; got %v instead", entry.q, err)
+			continue
+		}
+		if len(res) != 1 || res[0].GetError() == nil {
+			t.Errorf("Execute should have returned a single error response for %q; got %v instead", entry.q, res)
+			continue
+		}
+		if got := res[0].GetError(); got.GetCode() != entry.code || got.GetMessage() == "" {
+			t.Errorf("Execute returned error %v for %q; want code %v with a message", got, entry.q, entry.code)
+		}
+	}
+}
+
+func TestExecuteCanceled(t *testing.T) {
+	c := newTestClient(t, populateTestStore(t))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := execute(ctx, c, `select ?s from ?test where {?s ?p ?o};`); status.Code(err) != codes.Canceled {
+		t.Errorf("Execute returned error %v for a canceled call; want code %v", err, codes.Canceled)
+	}
+}
+
+// fakeStream collects the responses sent by the server for the provided
+// context.
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	res []*ExecuteResponse
+}
+
+func (f *fakeStream) Context() context.Context { return f.ctx }
+
+func (f *fakeStream) Send(r *ExecuteResponse) error {
+	f.res = append(f.res, r)
+	return nil
+}
+
+func TestExecuteCanceledPlan(t *testing.T) {
+	srv, err := New(populateTestStore(t), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stream := &fakeStream{ctx: ctx}
+	err = srv.Execute(&ExecuteRequest{Bql: `select ?s from ?test where {?s ?p ?o};`}, stream)
+	if status.Code(err) != codes.Canceled {
+		t.Errorf("Execute returned error %v for a canceled context; want code %v", err, codes.Canceled)
+	}
+	if len(stream.res) != 0 {
+		t.Errorf("Execute should have not sent responses for a canceled context; got %v", stream.res)
+	}
+}
+
+// failingStream fails to send any response after the header.
+type failingStream struct {
+	fakeStream
+}
+
+func (f *failingStream) Send(r *ExecuteResponse) error {
+	if r.GetHeader() == nil {
+		return errors.New("send failed")
+	}
+	return f.fakeStream.Send(r)
+}
+
+func TestExecuteSendFails(t *testing.T) {
+	srv, err := New(populateTestStore(t), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := &failingStream{fakeStream{ctx: context.Background()}}
+	err = srv.Execute(&ExecuteRequest{Bql: `select ?s, ?p, ?o from ?test where {?s ?p ?o};`}, stream)
+	if err == nil || err.Error() != "send failed" {
+		t.Errorf("Execute returned error %v after failing to send a row; want the send error", err)
+	}
+	if len(stream.res) != 1 || stream.res[0].GetHeader() == nil {
+		t.Errorf("Execute should have only sent the header; got %v", stream.res)
+	}
+}
@@ -0,0 +1,624 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bolt provides a persistent implementation of the storage.Store and
+// storage.Graph interfaces backed by a BoltDB file.
+//
+// Each graph is stored in its own top level bucket. The graph bucket contains
+// a bucket with all the triples keyed by their UUID, and one bucket for each
+// of the indices used to answer lookups. Index keys are the concatenation of
+// the UUIDs of the indexed triple components followed by the triple UUID.
+//
+// Lookups stream the triples while holding a read-only transaction. Callers
+// that stop consuming the channel early should cancel the context to release
+// the transaction, since long lived read transactions block writers that need
+// to grow the file.
+package bolt
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	bdb "go.etcd.io/bbolt"
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+var (
+	// Bucket names used inside a graph bucket.
+	triplesBucket = []byte("t")
+	idxS          = []byte("s")
+	idxP          = []byte("p")
+	idxO          = []byte("o")
+	idxSP         = []byte("sp")
+	idxPO         = []byte("po")
+	idxSO         = []byte("so")
+
+	indices = [][]byte{idxS, idxP, idxO, idxSP, idxPO, idxSO}
+)
+
+// Store implements storage.Store on top of a BoltDB file.
+type Store struct {
+	path string
+	db   *bdb.DB
+}
+
+// NewStore opens, or creates if it does not exist, the BoltDB file in the
+// provided path and returns a store backed by it. The store should be closed
+// once it is no longer needed.
+func NewStore(path string, mode os.FileMode) (*Store, error) {
+	db, err := bdb.Open(path, mode, &bdb.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("bolt.NewStore(%q): failed to open with error %v", path, err)
+	}
+	return &Store{
+		path: path,
+		db:   db,
+	}, nil
+}
+
+// Close releases the underlying BoltDB file.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Name returns the ID of the backend being used.
+func (s *Store) Name(ctx context.Context) string {
+	return "BOLT"
+}
+
+// Version returns the version of the driver implementation.
+func (s *Store) Version(ctx context.Context) string {
+	return "0.1.vcli"
+}
+
+// Generation returns the current generation of the store. BoltDB assigns an
+// increasing ID to every committed read-write transaction, hence the ID seen
+// by a read-only transaction changes on any mutation.
+func (s *Store) Generation(ctx context.Context) (uint64, error) {
+	var gen uint64
+	err := s.db.View(func(tx *bdb.Tx) error {
+		gen = uint64(tx.ID())
+		return nil
+	})
+	return gen, err
+}
+
+// NewGraph creates a new graph. Creating an already existing graph
+// should return an error.
+func (s *Store) NewGraph(ctx context.Context, id string) (storage.Graph, error) {
+	err := s.db.Update(func(tx *bdb.Tx) error {
+		if tx.Bucket([]byte(id)) != nil {
+			return fmt.Errorf("bolt.NewGraph(%q): graph already exists", id)
+		}
+		gb, err := tx.CreateBucket([]byte(id))
+		if err != nil {
+			return fmt.Errorf("bolt.NewGraph(%q): failed to create graph with error %v", id, err)
+		}
+		for _, b := range append([][]byte{triplesBucket}, indices...) {
+			if _, err := gb.CreateBucket(b); err != nil {
+				return fmt.Errorf("bolt.NewGraph(%q): failed to create index %q with error %v", id, b, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &graph{id: id, db: s.db}, nil
+}
+
+// Graph returns an existing graph if available. Getting a non existing
+// graph should return an error.
+func (s *Store) Graph(ctx context.Context, id string) (storage.Graph, error) {
+	err := s.db.View(func(tx *bdb.Tx) error {
+		if tx.Bucket([]byte(id)) == nil {
+			return fmt.Errorf("bolt.Graph(%q): graph does not exist", id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &graph{id: id, db: s.db}, nil
+}
+
+// DeleteGraph deletes an existing graph. Deleting a non existing graph
+// should return an error.
+func (s *Store) DeleteGraph(ctx context.Context, id string) error {
+	return s.db.Update(func(tx *bdb.Tx) error {
+		if err := tx.DeleteBucket([]byte(id)); err != nil {
+			if err == bdb.ErrBucketNotFound {
+				return fmt.Errorf("bolt.DeleteGraph(%q): graph does not exist", id)
+			}
+			return fmt.Errorf("bolt.DeleteGraph(%q): failed to delete graph with error %v", id, err)
+		}
+		return nil
+	})
+}
+
+// GraphNames returns the current available graph names in the store.
+func (s *Store) GraphNames(ctx context.Context, names chan<- string) error {
+	if names == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(names)
+	return s.db.View(func(tx *bdb.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bdb.Bucket) error {
+			select {
+			case names <- string(name):
+				return nil
+			case <-done(ctx):
+				return ctx.Err()
+			}
+		})
+	})
+}
+
+// graph provides a BoltDB-based persistent implementation of the graph API.
+type graph struct {
+	id string
+	db *bdb.DB
+}
+
+// ID returns the id for this graph.
+func (g *graph) ID(ctx context.Context) string {
+	return g.id
+}
+
+// bucket returns the bucket for the graph or an error if it no longer exists.
+func (g *graph) bucket(tx *bdb.Tx) (*bdb.Bucket, error) {
+	b := tx.Bucket([]byte(g.id))
+	if b == nil {
+		return nil, fmt.Errorf("bolt graph %q does not exist", g.id)
+	}
+	return b, nil
+}
+
+// indexKeys returns the keys to use on each of the indices for the provided
+// triple.
+func indexKeys(t *triple.Triple) map[string][]byte {
+	tUUID := []byte(t.UUID())
+	sUUID := []byte(t.Subject().UUID())
+	pUUID := []byte(t.Predicate().UUID())
+	oUUID := []byte(t.Object().UUID())
+	key := func(ps ...[]byte) []byte {
+		return bytes.Join(append(ps, tUUID), nil)
+	}
+	return map[string][]byte{
+		string(idxS):  key(sUUID),
+		string(idxP):  key(pUUID),
+		string(idxO):  key(oUUID),
+		string(idxSP): key(sUUID, pUUID),
+		string(idxPO): key(pUUID, oUUID),
+		string(idxSO): key(sUUID, oUUID),
+	}
+}
+
+// AddTriples adds the triples to the storage. Adding a triple that already
+// exists should not fail.
+func (g *graph) AddTriples(ctx context.Context, ts []*triple.Triple) error {
+	return g.db.Update(func(tx *bdb.Tx) error {
+		gb, err := g.bucket(tx)
+		if err != nil {
+			return err
+		}
+		for _, t := range ts {
+			if err := gb.Bucket(triplesBucket).Put([]byte(t.UUID()), []byte(t.String())); err != nil {
+				return err
+			}
+			for idx, k := range indexKeys(t) {
+				if err := gb.Bucket([]byte(idx)).Put(k, nil); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveTriples removes the triples from the storage. Removing triples that
+// are not present on the store should not fail.
+func (g *graph) RemoveTriples(ctx context.Context, ts []*triple.Triple) error {
+	return g.db.Update(func(tx *bdb.Tx) error {
+		gb, err := g.bucket(tx)
+		if err != nil {
+			return err
+		}
+		for _, t := range ts {
+			if err := gb.Bucket(triplesBucket).Delete([]byte(t.UUID())); err != nil {
+				return err
+			}
+			for idx, k := range indexKeys(t) {
+				if err := gb.Bucket([]byte(idx)).Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// CompareAndSwap replaces the expected triple with the new one if the
+// expected triple is stored. The check and the swap run on the same update
+// transaction.
+func (g *graph) CompareAndSwap(ctx context.Context, expected, new *triple.Triple) (bool, error) {
+	swapped := false
+	err := g.db.Update(func(tx *bdb.Tx) error {
+		gb, err := g.bucket(tx)
+		if err != nil {
+			return err
+		}
+		tb := gb.Bucket(triplesBucket)
+		if tb.Get([]byte(expected.UUID())) == nil {
+			return nil
+		}
+		if err := tb.Delete([]byte(expected.UUID())); err != nil {
+			return err
+		}
+		for idx, k := range indexKeys(expected) {
+			if err := gb.Bucket([]byte(idx)).Delete(k); err != nil {
+				return err
+			}
+		}
+		if err := tb.Put([]byte(new.UUID()), []byte(new.String())); err != nil {
+			return err
+		}
+		for idx, k := range indexKeys(new) {
+			if err := gb.Bucket([]byte(idx)).Put(k, nil); err != nil {
+				return err
+			}
+		}
+		swapped = true
+		return nil
+	})
+	return swapped, err
+}
+
+// checker provides the mechanics to check if a predicate/triple should be
+// considered on a certain operation.
+type checker struct {
+	max bool
+	c   int
+	o   *storage.LookupOptions
+}
+
+// newChecker creates a new checker for a given LookupOptions configuration.
+func newChecker(o *storage.LookupOptions) *checker {
+	return &checker{
+		max: o.MaxElements > 0,
+		c:   o.MaxElements,
+		o:   o,
+	}
+}
+
+// done returns true if no more elements should be returned.
+func (c *checker) done() bool {
+	return c.max && c.c <= 0
+}
+
+// CheckAndUpdate checks if a predicate should be considered and it also updates
+// the internal state in case counts are needed.
+func (c *checker) CheckAndUpdate(p *predicate.Predicate) bool {
+	if c.done() {
+		return false
+	}
+	if p.Type() == predicate.Immutable {
+		c.c--
+		return true
+	}
+	t, _ := p.TimeAnchor()
+	if c.o.LowerAnchor != nil && t.Before(*c.o.LowerAnchor) {
+		return false
+	}
+	if c.o.UpperAnchor != nil && t.After(*c.o.UpperAnchor) {
+		return false
+	}
+	c.c--
+	return true
+}
+
+// done returns the channel closed when the provided context is done. A nil
+// context is never done.
+func done(ctx context.Context) <-chan struct{} {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Done()
+}
+
+// scan iterates over all the triples in the provided index whose key starts
+// with the provided prefix and that satisfy the lookup options. Triples are
+// retrieved one at a time while iterating the index cursor, hence the graph is
+// never loaded in memory unless LatestOnly is set, since the latest versions
+// are only known once all the triples are scanned. The iteration stops as soon
+// as f returns an error.
+func (g *graph) scan(ctx context.Context, idx, prefix []byte, lo *storage.LookupOptions, f func(*triple.Triple) error) error {
+	if lo.LatestOnly {
+		return storage.ScanLatest(lo, func(lo *storage.LookupOptions, f func(*triple.Triple) error) error {
+			return g.scan(ctx, idx, prefix, lo, f)
+		}, f)
+	}
+	return g.db.View(func(tx *bdb.Tx) error {
+		gb, err := g.bucket(tx)
+		if err != nil {
+			return err
+		}
+		tb, ckr := gb.Bucket(triplesBucket), newChecker(lo)
+		c := gb.Bucket(idx).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix) && !ckr.done(); k, _ = c.Next() {
+			v := tb.Get(k[len(prefix):])
+			if v == nil {
+				return fmt.Errorf("bolt graph %q has a dangling index entry in %q", g.id, idx)
+			}
+			t, err := triple.Parse(string(v), literal.DefaultBuilder())
+			if err != nil {
+				return err
+			}
+			if ckr.CheckAndUpdate(t.Predicate()) {
+				if err := f(t); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Objects pushes to the provided channel the objects for the given object and
+// predicate. The function does not return immediately.
+func (g *graph) Objects(ctx context.Context, s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions, objs chan<- *triple.Object) error {
+	if objs == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(objs)
+	prefix := bytes.Join([][]byte{[]byte(s.UUID()), []byte(p.UUID())}, nil)
+	return g.scan(ctx, idxSP, prefix, lo, func(t *triple.Triple) error {
+		select {
+		case objs <- t.Object():
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// Subjects pushes to the provided channel the subjects for the give predicate
+// and object. The function does not return immediately.
+func (g *graph) Subjects(ctx context.Context, p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions, subs chan<- *node.Node) error {
+	if subs == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(subs)
+	prefix := bytes.Join([][]byte{[]byte(p.UUID()), []byte(o.UUID())}, nil)
+	return g.scan(ctx, idxPO, prefix, lo, func(t *triple.Triple) error {
+		select {
+		case subs <- t.Subject():
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// PredicatesForSubject pushes to the provided channel all the predicates
+// known for the given subject. The function does not return immediately.
+func (g *graph) PredicatesForSubject(ctx context.Context, s *node.Node, lo *storage.LookupOptions, prds chan<- *predicate.Predicate) error {
+	if prds == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(prds)
+	return g.scan(ctx, idxS, []byte(s.UUID()), lo, func(t *triple.Triple) error {
+		select {
+		case prds <- t.Predicate():
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// PredicatesForObject pushes to the provided channel all the predicates known
+// for the given object. The function does not return immediately.
+func (g *graph) PredicatesForObject(ctx context.Context, o *triple.Object, lo *storage.LookupOptions, prds chan<- *predicate.Predicate) error {
+	if prds == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(prds)
+	return g.scan(ctx, idxO, []byte(o.UUID()), lo, func(t *triple.Triple) error {
+		select {
+		case prds <- t.Predicate():
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// PredicatesForSubjectAndObject pushes to the provided channel all predicates
+// available for the given subject and object. The function does not return
+// immediately.
+func (g *graph) PredicatesForSubjectAndObject(ctx context.Context, s *node.Node, o *triple.Object, lo *storage.LookupOptions, prds chan<- *predicate.Predicate) error {
+	if prds == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(prds)
+	prefix := bytes.Join([][]byte{[]byte(s.UUID()), []byte(o.UUID())}, nil)
+	return g.scan(ctx, idxSO, prefix, lo, func(t *triple.Triple) error {
+		select {
+		case prds <- t.Predicate():
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// TriplesForSubject pushes to the provided channel all triples available for
+// the given subject. The function does not return immediately.
+func (g *graph) TriplesForSubject(ctx context.Context, s *node.Node, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	return g.scan(ctx, idxS, []byte(s.UUID()), lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// TriplesForPredicate pushes to the provided channel all triples available
+// for the given predicate. The function does not return immediately.
+func (g *graph) TriplesForPredicate(ctx context.Context, p *predicate.Predicate, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	return g.scan(ctx, idxP, []byte(p.UUID()), lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// TriplesForObject pushes to the provided channel all triples available for
+// the given object. The function does not return immediately.
+func (g *graph) TriplesForObject(ctx context.Context, o *triple.Object, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	return g.scan(ctx, idxO, []byte(o.UUID()), lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// TriplesForSubjectAndPredicate pushes to the provided channel all triples
+// available for the given subject and predicate. The function does not return
+// immediately.
+func (g *graph) TriplesForSubjectAndPredicate(ctx context.Context, s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	prefix := bytes.Join([][]byte{[]byte(s.UUID()), []byte(p.UUID())}, nil)
+	return g.scan(ctx, idxSP, prefix, lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// TriplesForPredicateAndObject pushes to the provided channel all triples
+// available for the given predicate and object. The function does not return
+// immediately.
+func (g *graph) TriplesForPredicateAndObject(ctx context.Context, p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	prefix := bytes.Join([][]byte{[]byte(p.UUID()), []byte(o.UUID())}, nil)
+	return g.scan(ctx, idxPO, prefix, lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// Exist checks if the provided triple exists on the store.
+func (g *graph) Exist(ctx context.Context, t *triple.Triple) (bool, error) {
+	exist := false
+	err := g.db.View(func(tx *bdb.Tx) error {
+		gb, err := g.bucket(tx)
+		if err != nil {
+			return err
+		}
+		exist = gb.Bucket(triplesBucket).Get([]byte(t.UUID())) != nil
+		return nil
+	})
+	return exist, err
+}
+
+// Triples pushes to the provided channel all available triples in the graph.
+// The function does not return immediately. Triples are streamed while
+// iterating over the graph, hence the graph is never loaded in memory unless
+// LatestOnly is set.
+func (g *graph) Triples(ctx context.Context, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	f := func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	}
+	if lo.LatestOnly {
+		return storage.ScanLatest(lo, g.scanAll, f)
+	}
+	return g.scanAll(lo, f)
+}
+
+// scanAll iterates over all the triples in the graph that satisfy the lookup
+// options. The iteration stops as soon as f returns an error.
+func (g *graph) scanAll(lo *storage.LookupOptions, f func(*triple.Triple) error) error {
+	return g.db.View(func(tx *bdb.Tx) error {
+		gb, err := g.bucket(tx)
+		if err != nil {
+			return err
+		}
+		ckr := newChecker(lo)
+		c := gb.Bucket(triplesBucket).Cursor()
+		for k, v := c.First(); k != nil && !ckr.done(); k, v = c.Next() {
+			t, err := triple.Parse(string(v), literal.DefaultBuilder())
+			if err != nil {
+				return err
+			}
+			if ckr.CheckAndUpdate(t.Predicate()) {
+				if err := f(t); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
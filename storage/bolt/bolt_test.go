@@ -0,0 +1,465 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bolt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// newTestStore returns a store backed by a file in a temporary folder and
+// a function to clean it up once done.
+func newTestStore(t *testing.T) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "badwolf_bolt")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir failed with error %v", err)
+	}
+	s, err := NewStore(filepath.Join(dir, "test.db"), 0600)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("bolt.NewStore failed with error %v", err)
+	}
+	return s, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestBoltStore(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+	ctx := context.Background()
+	// Create a new graph.
+	if _, err := s.NewGraph(ctx, "test"); err != nil {
+		t.Errorf("boltStore.NewGraph: should never fail to crate a graph; %s", err)
+	}
+	// Create an already existing graph.
+	if _, err := s.NewGraph(ctx, "test"); err == nil {
+		t.Errorf("boltStore.NewGraph: should never succeed to create an existing graph")
+	}
+	// Get an existing graph.
+	if _, err := s.Graph(ctx, "test"); err != nil {
+		t.Errorf("boltStore.Graph: should never fail to get an existing graph; %s", err)
+	}
+	// Delete an existing graph.
+	if err := s.DeleteGraph(ctx, "test"); err != nil {
+		t.Errorf("boltStore.DeleteGraph: should never fail to delete an existing graph; %s", err)
+	}
+	// Get a non existing graph.
+	if _, err := s.Graph(ctx, "test"); err == nil {
+		t.Errorf("boltStore.Graph: should never succeed to get a non existing graph")
+	}
+	// Delete a non existing graph.
+	if err := s.DeleteGraph(ctx, "test"); err == nil {
+		t.Errorf("boltStore.DeleteGraph: should never succed to delete a non existing graph")
+	}
+}
+
+func TestGraphNames(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+	gs, ctx := []string{"?foo", "?bar", "?test"}, context.Background()
+	for _, g := range gs {
+		if _, err := s.NewGraph(ctx, g); err != nil {
+			t.Errorf("boltStore.NewGraph: should never fail to crate a graph %s; %s", g, err)
+		}
+	}
+	gns := make(chan string, len(gs))
+	if err := s.GraphNames(ctx, gns); err != nil {
+		t.Errorf("boltStore.GraphNames: failed with error %v", err)
+	}
+	got := make(map[string]bool)
+	for g := range gns {
+		got[g] = true
+	}
+	for _, g := range gs {
+		if !got[g] {
+			t.Errorf("boltStore.GraphNames: failed to return graph %q; got %v", g, got)
+		}
+	}
+	if len(got) != len(gs) {
+		t.Errorf("boltStore.GraphNames: failed to return %d graphs; got %v", len(gs), got)
+	}
+}
+
+func TestConcurrentNewAndDeleteGraph(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("?g%d", i%5)
+			if _, err := s.NewGraph(ctx, id); err == nil {
+				s.DeleteGraph(ctx, id)
+			}
+		}(i)
+	}
+	wg.Wait()
+	gns := make(chan string, 20)
+	if err := s.GraphNames(ctx, gns); err != nil {
+		t.Fatal(err)
+	}
+	for g := range gns {
+		t.Errorf("boltStore.GraphNames: graph %q should have been deleted", g)
+	}
+}
+
+func createTriples(t *testing.T, ss []string) []*triple.Triple {
+	ts := []*triple.Triple{}
+	for _, s := range ss {
+		trpl, err := triple.Parse(s, literal.DefaultBuilder())
+		if err != nil {
+			t.Errorf("triple.Parse failed to parse valid triple %s with error %v", s, err)
+			continue
+		}
+		ts = append(ts, trpl)
+	}
+	return ts
+}
+
+func getTestTriples(t *testing.T) []*triple.Triple {
+	return createTriples(t, []string{
+		"/u<john>\t\"knows\"@[]\t/u<mary>",
+		"/u<john>\t\"knows\"@[]\t/u<peter>",
+		"/u<john>\t\"knows\"@[]\t/u<alice>",
+		"/u<mary>\t\"knows\"@[]\t/u<andrew>",
+		"/u<mary>\t\"knows\"@[]\t/u<kim>",
+		"/u<mary>\t\"knows\"@[]\t/u<alice>",
+	})
+}
+
+// newTestGraph returns a graph populated with the provided triples.
+func newTestGraph(t *testing.T, s *Store, ts []*triple.Triple) storage.Graph {
+	ctx := context.Background()
+	g, err := s.NewGraph(ctx, "?test")
+	if err != nil {
+		t.Fatalf("boltStore.NewGraph failed with error %v", err)
+	}
+	if err := g.AddTriples(ctx, ts); err != nil {
+		t.Fatalf("g.AddTriples(_) failed to add test triples with error %v", err)
+	}
+	return g
+}
+
+func TestAddRemoveTriples(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+	ts, ctx := getTestTriples(t), context.Background()
+	g := newTestGraph(t, s, ts)
+	for _, tr := range ts {
+		if b, err := g.Exist(ctx, tr); err != nil || !b {
+			t.Errorf("g.Exist(%s) should have returned true; got %v, %v", tr, b, err)
+		}
+	}
+	if err := g.RemoveTriples(ctx, ts); err != nil {
+		t.Errorf("g.RemoveTriples(_) failed to remove test triples with error %v", err)
+	}
+	for _, tr := range ts {
+		if b, err := g.Exist(ctx, tr); err != nil || b {
+			t.Errorf("g.Exist(%s) should have returned false; got %v, %v", tr, b, err)
+		}
+	}
+	trpls := make(chan *triple.Triple, 100)
+	if err := g.TriplesForSubject(ctx, ts[0].Subject(), storage.DefaultLookup, trpls); err != nil {
+		t.Fatal(err)
+	}
+	for tr := range trpls {
+		t.Errorf("g.TriplesForSubject(%s) returned removed triple %s", ts[0].Subject(), tr)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+	ts, ctx := getTestTriples(t), context.Background()
+	g := newTestGraph(t, s, ts[:1])
+	if ok, err := g.CompareAndSwap(ctx, ts[0], ts[1]); err != nil || !ok {
+		t.Errorf("g.CompareAndSwap(%s, %s) returned %v, %v; want true, nil", ts[0], ts[1], ok, err)
+	}
+	if b, err := g.Exist(ctx, ts[0]); err != nil || b {
+		t.Errorf("g.Exist(%s) should have returned false after the swap; got %v, %v", ts[0], b, err)
+	}
+	trpls := make(chan *triple.Triple, 100)
+	if err := g.TriplesForSubject(ctx, ts[1].Subject(), storage.DefaultLookup, trpls); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for tr := range trpls {
+		got = append(got, tr.String())
+	}
+	if len(got) != 1 || got[0] != ts[1].String() {
+		t.Errorf("g.TriplesForSubject(%s) returned %v after the swap; want [%s]", ts[1].Subject(), got, ts[1])
+	}
+	// Missing expected triples do not swap.
+	if ok, err := g.CompareAndSwap(ctx, ts[0], ts[2]); err != nil || ok {
+		t.Errorf("g.CompareAndSwap(%s, %s) returned %v, %v; want false, nil", ts[0], ts[2], ok, err)
+	}
+	if b, err := g.Exist(ctx, ts[2]); err != nil || b {
+		t.Errorf("g.Exist(%s) should have returned false; got %v, %v", ts[2], b, err)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badwolf_bolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path, ts, ctx := filepath.Join(dir, "test.db"), getTestTriples(t), context.Background()
+	s, err := NewStore(path, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTestGraph(t, s, ts)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s, err = NewStore(path, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	g, err := s.Graph(ctx, "?test")
+	if err != nil {
+		t.Fatalf("boltStore.Graph failed to reopen persisted graph with error %v", err)
+	}
+	for _, tr := range ts {
+		if b, err := g.Exist(ctx, tr); err != nil || !b {
+			t.Errorf("g.Exist(%s) should have returned true after reopening; got %v, %v", tr, b, err)
+		}
+	}
+}
+
+func TestLookups(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+	ts, ctx := getTestTriples(t), context.Background()
+	g := newTestGraph(t, s, ts)
+	john, mary := ts[0].Subject(), ts[3].Subject()
+	knows, alice := ts[0].Predicate(), ts[2].Object()
+
+	countNodes := func(f func(chan<- *node.Node) error) int {
+		c := make(chan *node.Node, 100)
+		if err := f(c); err != nil {
+			t.Fatal(err)
+		}
+		cnt := 0
+		for _ = range c {
+			cnt++
+		}
+		return cnt
+	}
+	countObjects := func(f func(chan<- *triple.Object) error) int {
+		c := make(chan *triple.Object, 100)
+		if err := f(c); err != nil {
+			t.Fatal(err)
+		}
+		cnt := 0
+		for _ = range c {
+			cnt++
+		}
+		return cnt
+	}
+	countPredicates := func(f func(chan<- *predicate.Predicate) error) int {
+		c := make(chan *predicate.Predicate, 100)
+		if err := f(c); err != nil {
+			t.Fatal(err)
+		}
+		cnt := 0
+		for _ = range c {
+			cnt++
+		}
+		return cnt
+	}
+	countTriples := func(f func(chan<- *triple.Triple) error) int {
+		c := make(chan *triple.Triple, 100)
+		if err := f(c); err != nil {
+			t.Fatal(err)
+		}
+		cnt := 0
+		for _ = range c {
+			cnt++
+		}
+		return cnt
+	}
+
+	lo := storage.DefaultLookup
+	table := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"Objects", countObjects(func(c chan<- *triple.Object) error { return g.Objects(ctx, john, knows, lo, c) }), 3},
+		{"Subjects", countNodes(func(c chan<- *node.Node) error { return g.Subjects(ctx, knows, alice, lo, c) }), 2},
+		{"PredicatesForSubject", countPredicates(func(c chan<- *predicate.Predicate) error { return g.PredicatesForSubject(ctx, mary, lo, c) }), 3},
+		{"PredicatesForObject", countPredicates(func(c chan<- *predicate.Predicate) error { return g.PredicatesForObject(ctx, alice, lo, c) }), 2},
+		{"PredicatesForSubjectAndObject", countPredicates(func(c chan<- *predicate.Predicate) error {
+			return g.PredicatesForSubjectAndObject(ctx, john, alice, lo, c)
+		}), 1},
+		{"TriplesForSubject", countTriples(func(c chan<- *triple.Triple) error { return g.TriplesForSubject(ctx, john, lo, c) }), 3},
+		{"TriplesForPredicate", countTriples(func(c chan<- *triple.Triple) error { return g.TriplesForPredicate(ctx, knows, lo, c) }), 6},
+		{"TriplesForObject", countTriples(func(c chan<- *triple.Triple) error { return g.TriplesForObject(ctx, alice, lo, c) }), 2},
+		{"TriplesForSubjectAndPredicate", countTriples(func(c chan<- *triple.Triple) error {
+			return g.TriplesForSubjectAndPredicate(ctx, mary, knows, lo, c)
+		}), 3},
+		{"TriplesForPredicateAndObject", countTriples(func(c chan<- *triple.Triple) error {
+			return g.TriplesForPredicateAndObject(ctx, knows, alice, lo, c)
+		}), 2},
+		{"Triples", countTriples(func(c chan<- *triple.Triple) error { return g.Triples(ctx, lo, c) }), 6},
+		{"TriplesWithLimit", countTriples(func(c chan<- *triple.Triple) error {
+			return g.Triples(ctx, &storage.LookupOptions{MaxElements: 4}, c)
+		}), 4},
+	}
+	for _, entry := range table {
+		if entry.got != entry.want {
+			t.Errorf("g.%s returned %d elements; want %d", entry.name, entry.got, entry.want)
+		}
+	}
+}
+
+func TestLookupStopsOnCancel(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+	ts := getTestTriples(t)
+	g := newTestGraph(t, s, ts)
+	ctx, cancel := context.WithCancel(context.Background())
+	// Nobody reads from the channel, hence only the cancelation can unblock
+	// the lookup.
+	trpls, errs := make(chan *triple.Triple), make(chan error, 1)
+	go func() {
+		errs <- g.Triples(ctx, storage.DefaultLookup, trpls)
+	}()
+	cancel()
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Errorf("g.Triples should have returned an error once the context was canceled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("g.Triples failed to stop once the context was canceled")
+	}
+	// The read transaction must have been released.
+	if err := g.RemoveTriples(context.Background(), ts); err != nil {
+		t.Errorf("g.RemoveTriples failed after canceling a lookup with error %v", err)
+	}
+}
+
+func mustParse(t string) *time.Time {
+	r, err := time.Parse(time.RFC3339Nano, t)
+	if err != nil {
+		panic(err)
+	}
+	return &r
+}
+
+func TestTriplesForObjectWithLimit(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+	ts := createTriples(t, []string{
+		"/u<bob>\t\"kissed\"@[2015-01-01T00:00:00-09:00]\t/u<mary>",
+		"/u<bob>\t\"kissed\"@[2015-02-01T00:00:00-09:00]\t/u<mary>",
+		"/u<bob>\t\"kissed\"@[2015-03-01T00:00:00-09:00]\t/u<mary>",
+		"/u<bob>\t\"kissed\"@[2015-04-01T00:00:00-09:00]\t/u<mary>",
+		"/u<bob>\t\"kissed\"@[2015-05-01T00:00:00-09:00]\t/u<mary>",
+		"/u<bob>\t\"kissed\"@[2015-06-01T00:00:00-09:00]\t/u<mary>",
+	})
+	ctx := context.Background()
+	g := newTestGraph(t, s, ts)
+	trpls := make(chan *triple.Triple, 100)
+	lo := &storage.LookupOptions{
+		MaxElements: 2,
+		LowerAnchor: mustParse("2015-04-01T00:00:00-08:00"),
+		UpperAnchor: mustParse("2015-06-01T00:00:00-10:00"),
+	}
+	if err := g.TriplesForObject(ctx, ts[0].Object(), lo, trpls); err != nil {
+		t.Errorf("g.TriplesForObject(%s) failed with error %v", ts[0].Object(), err)
+	}
+	cnt := 0
+	for tr := range trpls {
+		ta, err := tr.Predicate().TimeAnchor()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if ta.Before(*lo.LowerAnchor) || ta.After(*lo.UpperAnchor) {
+			t.Errorf("g.TriplesForObject(%s) unexpected triple receved: %s", ts[0].Object(), tr)
+		}
+		cnt++
+	}
+	if cnt != lo.MaxElements {
+		t.Errorf("g.TriplesForObject(%s) failed to retrieve 2 triples, got %d instead", ts[0].Object(), cnt)
+	}
+}
+
+func TestGeneration(t *testing.T) {
+	s, done := newTestStore(t)
+	defer done()
+	ctx := context.Background()
+	gen := func() uint64 {
+		g, err := s.Generation(ctx)
+		if err != nil {
+			t.Fatalf("boltStore.Generation failed with error %v", err)
+		}
+		return g
+	}
+	g0 := gen()
+	if got := gen(); got != g0 {
+		t.Errorf("boltStore.Generation should not change without mutations; got %d, want %d", got, g0)
+	}
+	g, err := s.NewGraph(ctx, "?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g1 := gen()
+	if g1 == g0 {
+		t.Errorf("boltStore.Generation should change after creating a graph")
+	}
+	if err := g.AddTriples(ctx, getTestTriples(t)); err != nil {
+		t.Fatal(err)
+	}
+	g2 := gen()
+	if g2 == g1 {
+		t.Errorf("boltStore.Generation should change after adding triples")
+	}
+	if err := g.RemoveTriples(ctx, getTestTriples(t)); err != nil {
+		t.Fatal(err)
+	}
+	g3 := gen()
+	if g3 == g2 {
+		t.Errorf("boltStore.Generation should change after removing triples")
+	}
+	if err := s.DeleteGraph(ctx, "?test"); err != nil {
+		t.Fatal(err)
+	}
+	if got := gen(); got == g3 {
+		t.Errorf("boltStore.Generation should change after deleting a graph")
+	}
+}
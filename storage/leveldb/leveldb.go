@@ -0,0 +1,759 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package leveldb provides a persistent implementation of the storage.Store
+// and storage.Graph interfaces backed by a LevelDB database.
+//
+// All graphs share the same sorted key space. Every key of a graph starts
+// with the length prefixed graph ID followed by the index the key belongs to.
+// Besides the indices keyed by the UUIDs of the triple components, the
+// anchored indices are keyed by the UUID of the predicate ID followed by the
+// time anchor of the predicate. Lookups with time bounds that do not fix the
+// predicate seek directly to the lower bound of each predicate ID and skip the
+// rest of its anchors once the upper bound is passed, instead of streaming and
+// filtering all the triples.
+//
+// Index values contain the triple itself, hence lookups never need to read
+// more than one key per returned triple. Lookups stream the triples while
+// iterating over an implicit snapshot of the database.
+package leveldb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+	ldb "github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// Key spaces of the database.
+const (
+	graphsSpace = byte('g')
+	dataSpace   = byte('d')
+)
+
+// Indices of a graph. Several lookups share the same index.
+const (
+	// idxTriples is keyed by triple UUID.
+	idxTriples = byte('t')
+	// idxP is keyed by predicate UUID.
+	idxP = byte('p')
+	// idxSP is keyed by subject and predicate UUIDs.
+	idxSP = byte('s')
+	// idxPO is keyed by predicate and object UUIDs.
+	idxPO = byte('o')
+	// idxSA is keyed by subject UUID and predicate anchor.
+	idxSA = byte('S')
+	// idxOA is keyed by object UUID and predicate anchor.
+	idxOA = byte('O')
+	// idxSOA is keyed by subject and object UUIDs and predicate anchor.
+	idxSOA = byte('B')
+	// idxA is keyed by predicate anchor.
+	idxA = byte('A')
+)
+
+// Kinds of predicates in anchored keys. Immutable predicates sort before the
+// temporal ones of the same predicate ID.
+const (
+	immutableKind = byte(0)
+	temporalKind  = byte(1)
+)
+
+// Store implements storage.Store on top of a LevelDB database.
+type Store struct {
+	path string
+	db   *ldb.DB
+
+	// mu serializes the mutations, since compare and swap operations need to
+	// read and write atomically.
+	mu  sync.Mutex
+	gen uint64
+}
+
+// NewStore opens, or creates if it does not exist, the LevelDB database in the
+// provided folder and returns a store backed by it. The store should be closed
+// once it is no longer needed.
+func NewStore(path string) (*Store, error) {
+	db, err := ldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("leveldb.NewStore(%q): failed to open with error %v", path, err)
+	}
+	return &Store{
+		path: path,
+		db:   db,
+	}, nil
+}
+
+// Close releases the underlying LevelDB database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Name returns the ID of the backend being used.
+func (s *Store) Name(ctx context.Context) string {
+	return "LEVELDB"
+}
+
+// Version returns the version of the driver implementation.
+func (s *Store) Version(ctx context.Context) string {
+	return "0.1.vcli"
+}
+
+// Generation returns the current generation of the store. The generation
+// increases on every mutation done through the store since it was opened.
+func (s *Store) Generation(ctx context.Context) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gen, nil
+}
+
+// graphKey returns the key recording the existence of the provided graph.
+func graphKey(id string) []byte {
+	return append([]byte{graphsSpace}, id...)
+}
+
+// graphPrefix returns the prefix of all the keys of the provided graph.
+func graphPrefix(id string) []byte {
+	b := make([]byte, 1+binary.MaxVarintLen64, 1+binary.MaxVarintLen64+len(id))
+	b[0] = dataSpace
+	n := binary.PutUvarint(b[1:], uint64(len(id)))
+	return append(b[:1+n], id...)
+}
+
+// exists returns true if the provided graph exists.
+func (s *Store) exists(id string) (bool, error) {
+	return s.db.Has(graphKey(id), nil)
+}
+
+// NewGraph creates a new graph. Creating an already existing graph
+// should return an error.
+func (s *Store) NewGraph(ctx context.Context, id string) (storage.Graph, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ok, err := s.exists(id)
+	if err != nil {
+		return nil, fmt.Errorf("leveldb.NewGraph(%q): failed to create graph with error %v", id, err)
+	}
+	if ok {
+		return nil, fmt.Errorf("leveldb.NewGraph(%q): graph already exists", id)
+	}
+	if err := s.db.Put(graphKey(id), nil, nil); err != nil {
+		return nil, fmt.Errorf("leveldb.NewGraph(%q): failed to create graph with error %v", id, err)
+	}
+	s.gen++
+	return &graph{id: id, prefix: graphPrefix(id), s: s}, nil
+}
+
+// Graph returns an existing graph if available. Getting a non existing
+// graph should return an error.
+func (s *Store) Graph(ctx context.Context, id string) (storage.Graph, error) {
+	ok, err := s.exists(id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("leveldb.Graph(%q): graph does not exist", id)
+	}
+	return &graph{id: id, prefix: graphPrefix(id), s: s}, nil
+}
+
+// DeleteGraph deletes an existing graph. Deleting a non existing graph
+// should return an error.
+func (s *Store) DeleteGraph(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ok, err := s.exists(id)
+	if err != nil {
+		return fmt.Errorf("leveldb.DeleteGraph(%q): failed to delete graph with error %v", id, err)
+	}
+	if !ok {
+		return fmt.Errorf("leveldb.DeleteGraph(%q): graph does not exist", id)
+	}
+	b := &ldb.Batch{}
+	b.Delete(graphKey(id))
+	it := s.db.NewIterator(util.BytesPrefix(graphPrefix(id)), nil)
+	for it.Next() {
+		b.Delete(append([]byte(nil), it.Key()...))
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		return fmt.Errorf("leveldb.DeleteGraph(%q): failed to delete graph with error %v", id, err)
+	}
+	if err := s.db.Write(b, nil); err != nil {
+		return fmt.Errorf("leveldb.DeleteGraph(%q): failed to delete graph with error %v", id, err)
+	}
+	s.gen++
+	return nil
+}
+
+// GraphNames returns the current available graph names in the store.
+func (s *Store) GraphNames(ctx context.Context, names chan<- string) error {
+	if names == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(names)
+	it := s.db.NewIterator(util.BytesPrefix([]byte{graphsSpace}), nil)
+	defer it.Release()
+	for it.Next() {
+		select {
+		case names <- string(it.Key()[1:]):
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	}
+	return it.Error()
+}
+
+// graph provides a LevelDB-based persistent implementation of the graph API.
+type graph struct {
+	id     string
+	prefix []byte
+	s      *Store
+}
+
+// ID returns the id for this graph.
+func (g *graph) ID(ctx context.Context) string {
+	return g.id
+}
+
+// check returns an error if the graph no longer exists.
+func (g *graph) check() error {
+	ok, err := g.s.exists(g.id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("leveldb graph %q does not exist", g.id)
+	}
+	return nil
+}
+
+// key returns the key in the provided index of the graph made of the
+// provided parts.
+func (g *graph) key(idx byte, ps ...[]byte) []byte {
+	n := len(g.prefix) + 1
+	for _, p := range ps {
+		n += len(p)
+	}
+	k := make([]byte, 0, n)
+	k = append(append(k, g.prefix...), idx)
+	for _, p := range ps {
+		k = append(k, p...)
+	}
+	return k
+}
+
+// predicateIDUUID returns the UUID of the ID of the provided predicate, which
+// is shared by all the anchors of a temporal predicate.
+func predicateIDUUID(p *predicate.Predicate) []byte {
+	return []byte(uuid.NewSHA1(uuid.NIL, []byte(p.ID())))
+}
+
+// encodeAnchor returns the encoding of the provided time that sorts as the
+// time does.
+func encodeAnchor(t time.Time) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint64(b, uint64(t.Unix())^(1<<63))
+	binary.BigEndian.PutUint32(b[8:], uint32(t.Nanosecond()))
+	return b
+}
+
+// anchorPart returns the part of the anchored keys that follows the predicate
+// ID UUID.
+func anchorPart(p *predicate.Predicate) []byte {
+	ta, err := p.TimeAnchor()
+	if err != nil {
+		return []byte{immutableKind}
+	}
+	return append([]byte{temporalKind}, encodeAnchor(*ta)...)
+}
+
+// indexKeys returns the keys on each of the indices for the provided triple.
+func (g *graph) indexKeys(t *triple.Triple) [][]byte {
+	tUUID := []byte(t.UUID())
+	sUUID := []byte(t.Subject().UUID())
+	pUUID := []byte(t.Predicate().UUID())
+	oUUID := []byte(t.Object().UUID())
+	pid, anchor := predicateIDUUID(t.Predicate()), anchorPart(t.Predicate())
+	return [][]byte{
+		g.key(idxTriples, tUUID),
+		g.key(idxP, pUUID, tUUID),
+		g.key(idxSP, sUUID, pUUID, tUUID),
+		g.key(idxPO, pUUID, oUUID, tUUID),
+		g.key(idxSA, sUUID, pid, anchor, tUUID),
+		g.key(idxOA, oUUID, pid, anchor, tUUID),
+		g.key(idxSOA, sUUID, oUUID, pid, anchor, tUUID),
+		g.key(idxA, pid, anchor, tUUID),
+	}
+}
+
+// put adds the provided triple to the batch.
+func (g *graph) put(b *ldb.Batch, t *triple.Triple) {
+	v := []byte(t.String())
+	for _, k := range g.indexKeys(t) {
+		b.Put(k, v)
+	}
+}
+
+// delete removes the provided triple from the batch.
+func (g *graph) delete(b *ldb.Batch, t *triple.Triple) {
+	for _, k := range g.indexKeys(t) {
+		b.Delete(k)
+	}
+}
+
+// AddTriples adds the triples to the storage. Adding a triple that already
+// exists should not fail.
+func (g *graph) AddTriples(ctx context.Context, ts []*triple.Triple) error {
+	g.s.mu.Lock()
+	defer g.s.mu.Unlock()
+	if err := g.check(); err != nil {
+		return err
+	}
+	b := &ldb.Batch{}
+	for _, t := range ts {
+		g.put(b, t)
+	}
+	if err := g.s.db.Write(b, nil); err != nil {
+		return err
+	}
+	g.s.gen++
+	return nil
+}
+
+// RemoveTriples removes the triples from the storage. Removing triples that
+// are not present on the store should not fail.
+func (g *graph) RemoveTriples(ctx context.Context, ts []*triple.Triple) error {
+	g.s.mu.Lock()
+	defer g.s.mu.Unlock()
+	if err := g.check(); err != nil {
+		return err
+	}
+	b := &ldb.Batch{}
+	for _, t := range ts {
+		g.delete(b, t)
+	}
+	if err := g.s.db.Write(b, nil); err != nil {
+		return err
+	}
+	g.s.gen++
+	return nil
+}
+
+// CompareAndSwap replaces the expected triple with the new one if the
+// expected triple is stored. The check and the swap run while holding the
+// store mutation lock, and the swap is written as a single batch.
+func (g *graph) CompareAndSwap(ctx context.Context, expected, new *triple.Triple) (bool, error) {
+	g.s.mu.Lock()
+	defer g.s.mu.Unlock()
+	if err := g.check(); err != nil {
+		return false, err
+	}
+	ok, err := g.s.db.Has(g.key(idxTriples, []byte(expected.UUID())), nil)
+	if err != nil || !ok {
+		return false, err
+	}
+	b := &ldb.Batch{}
+	g.delete(b, expected)
+	g.put(b, new)
+	if err := g.s.db.Write(b, nil); err != nil {
+		return false, err
+	}
+	g.s.gen++
+	return true, nil
+}
+
+// checker provides the mechanics to check if a predicate/triple should be
+// considered on a certain operation.
+type checker struct {
+	max bool
+	c   int
+	o   *storage.LookupOptions
+}
+
+// newChecker creates a new checker for a given LookupOptions configuration.
+func newChecker(o *storage.LookupOptions) *checker {
+	return &checker{
+		max: o.MaxElements > 0,
+		c:   o.MaxElements,
+		o:   o,
+	}
+}
+
+// done returns true if no more elements should be returned.
+func (c *checker) done() bool {
+	return c.max && c.c <= 0
+}
+
+// CheckAndUpdate checks if a predicate should be considered and it also updates
+// the internal state in case counts are needed.
+func (c *checker) CheckAndUpdate(p *predicate.Predicate) bool {
+	if c.done() {
+		return false
+	}
+	if p.Type() == predicate.Immutable {
+		c.c--
+		return true
+	}
+	t, _ := p.TimeAnchor()
+	if c.o.LowerAnchor != nil && t.Before(*c.o.LowerAnchor) {
+		return false
+	}
+	if c.o.UpperAnchor != nil && t.After(*c.o.UpperAnchor) {
+		return false
+	}
+	c.c--
+	return true
+}
+
+// done returns the channel closed when the provided context is done. A nil
+// context is never done.
+func done(ctx context.Context) <-chan struct{} {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Done()
+}
+
+// bounded returns true if the lookup options constrain the time anchors.
+func bounded(lo *storage.LookupOptions) bool {
+	return lo.LowerAnchor != nil || lo.UpperAnchor != nil
+}
+
+// nextPrefix returns the smallest key greater than all the keys starting with
+// the provided prefix. It returns nil if there is no such key.
+func nextPrefix(prefix []byte) []byte {
+	n := append([]byte(nil), prefix...)
+	for i := len(n) - 1; i >= 0; i-- {
+		n[i]++
+		if n[i] != 0 {
+			return n[:i+1]
+		}
+	}
+	return nil
+}
+
+// emit parses the provided index value and hands it to f if it satisfies the
+// lookup options.
+func emit(v []byte, ckr *checker, f func(*triple.Triple) error) error {
+	t, err := triple.Parse(string(v), literal.DefaultBuilder())
+	if err != nil {
+		return err
+	}
+	if ckr.CheckAndUpdate(t.Predicate()) {
+		return f(t)
+	}
+	return nil
+}
+
+// scan iterates over all the triples in the graph whose key starts with the
+// provided prefix and that satisfy the lookup options. The iteration stops as
+// soon as f returns an error. LatestOnly lookups scan all the triples before
+// providing the latest versions to f.
+func (g *graph) scan(ctx context.Context, prefix []byte, lo *storage.LookupOptions, f func(*triple.Triple) error) error {
+	if lo.LatestOnly {
+		return storage.ScanLatest(lo, func(lo *storage.LookupOptions, f func(*triple.Triple) error) error {
+			return g.scan(ctx, prefix, lo, f)
+		}, f)
+	}
+	if err := g.check(); err != nil {
+		return err
+	}
+	it := g.s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer it.Release()
+	for ckr := newChecker(lo); !ckr.done() && it.Next(); {
+		if err := emit(it.Value(), ckr, f); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// scanAnchored iterates over all the triples in the anchored index entries
+// starting with the provided prefix that satisfy the lookup options. Within
+// each predicate ID, the immutable triples are always returned, while the
+// temporal ones are only read from the lower bound until the upper bound is
+// passed. The iteration stops as soon as f returns an error.
+func (g *graph) scanAnchored(ctx context.Context, prefix []byte, lo *storage.LookupOptions, f func(*triple.Triple) error) error {
+	if lo.LatestOnly {
+		return storage.ScanLatest(lo, func(lo *storage.LookupOptions, f func(*triple.Triple) error) error {
+			return g.scanAnchored(ctx, prefix, lo, f)
+		}, f)
+	}
+	if !bounded(lo) {
+		return g.scan(ctx, prefix, lo, f)
+	}
+	if err := g.check(); err != nil {
+		return err
+	}
+	var lower, upper []byte
+	if lo.LowerAnchor != nil {
+		lower = encodeAnchor(*lo.LowerAnchor)
+	}
+	if lo.UpperAnchor != nil {
+		upper = encodeAnchor(*lo.UpperAnchor)
+	}
+	ckr := newChecker(lo)
+	it := g.s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer it.Release()
+	pidEnd := len(prefix) + 16
+	for ok := it.Next(); ok && !ckr.done(); {
+		select {
+		case <-done(ctx):
+			return ctx.Err()
+		default:
+		}
+		k := it.Key()
+		if len(k) < pidEnd+1 {
+			return fmt.Errorf("leveldb graph %q has a malformed anchored index entry", g.id)
+		}
+		if k[pidEnd] == temporalKind {
+			if len(k) < pidEnd+13 {
+				return fmt.Errorf("leveldb graph %q has a malformed anchored index entry", g.id)
+			}
+			anchor := k[pidEnd+1 : pidEnd+13]
+			if lower != nil && bytes.Compare(anchor, lower) < 0 {
+				ok = it.Seek(bytes.Join([][]byte{k[:pidEnd], {temporalKind}, lower}, nil))
+				continue
+			}
+			if upper != nil && bytes.Compare(anchor, upper) > 0 {
+				np := nextPrefix(k[:pidEnd])
+				if np == nil || !bytes.HasPrefix(np, prefix) {
+					break
+				}
+				ok = it.Seek(np)
+				continue
+			}
+		}
+		if err := emit(it.Value(), ckr, f); err != nil {
+			return err
+		}
+		ok = it.Next()
+	}
+	return it.Error()
+}
+
+// Objects pushes to the provided channel the objects for the given object and
+// predicate. The function does not return immediately.
+func (g *graph) Objects(ctx context.Context, s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions, objs chan<- *triple.Object) error {
+	if objs == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(objs)
+	return g.scan(ctx, g.key(idxSP, []byte(s.UUID()), []byte(p.UUID())), lo, func(t *triple.Triple) error {
+		select {
+		case objs <- t.Object():
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// Subjects pushes to the provided channel the subjects for the give predicate
+// and object. The function does not return immediately.
+func (g *graph) Subjects(ctx context.Context, p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions, subs chan<- *node.Node) error {
+	if subs == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(subs)
+	return g.scan(ctx, g.key(idxPO, []byte(p.UUID()), []byte(o.UUID())), lo, func(t *triple.Triple) error {
+		select {
+		case subs <- t.Subject():
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// PredicatesForSubject pushes to the provided channel all the predicates
+// known for the given subject. The function does not return immediately.
+func (g *graph) PredicatesForSubject(ctx context.Context, s *node.Node, lo *storage.LookupOptions, prds chan<- *predicate.Predicate) error {
+	if prds == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(prds)
+	return g.scanAnchored(ctx, g.key(idxSA, []byte(s.UUID())), lo, func(t *triple.Triple) error {
+		select {
+		case prds <- t.Predicate():
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// PredicatesForObject pushes to the provided channel all the predicates known
+// for the given object. The function does not return immediately.
+func (g *graph) PredicatesForObject(ctx context.Context, o *triple.Object, lo *storage.LookupOptions, prds chan<- *predicate.Predicate) error {
+	if prds == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(prds)
+	return g.scanAnchored(ctx, g.key(idxOA, []byte(o.UUID())), lo, func(t *triple.Triple) error {
+		select {
+		case prds <- t.Predicate():
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// PredicatesForSubjectAndObject pushes to the provided channel all predicates
+// available for the given subject and object. The function does not return
+// immediately.
+func (g *graph) PredicatesForSubjectAndObject(ctx context.Context, s *node.Node, o *triple.Object, lo *storage.LookupOptions, prds chan<- *predicate.Predicate) error {
+	if prds == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(prds)
+	return g.scanAnchored(ctx, g.key(idxSOA, []byte(s.UUID()), []byte(o.UUID())), lo, func(t *triple.Triple) error {
+		select {
+		case prds <- t.Predicate():
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// TriplesForSubject pushes to the provided channel all triples available for
+// the given subject. The function does not return immediately.
+func (g *graph) TriplesForSubject(ctx context.Context, s *node.Node, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	return g.scanAnchored(ctx, g.key(idxSA, []byte(s.UUID())), lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// TriplesForPredicate pushes to the provided channel all triples available
+// for the given predicate. The function does not return immediately.
+func (g *graph) TriplesForPredicate(ctx context.Context, p *predicate.Predicate, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	return g.scan(ctx, g.key(idxP, []byte(p.UUID())), lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// TriplesForObject pushes to the provided channel all triples available for
+// the given object. The function does not return immediately.
+func (g *graph) TriplesForObject(ctx context.Context, o *triple.Object, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	return g.scanAnchored(ctx, g.key(idxOA, []byte(o.UUID())), lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// TriplesForSubjectAndPredicate pushes to the provided channel all triples
+// available for the given subject and predicate. The function does not return
+// immediately.
+func (g *graph) TriplesForSubjectAndPredicate(ctx context.Context, s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	return g.scan(ctx, g.key(idxSP, []byte(s.UUID()), []byte(p.UUID())), lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// TriplesForPredicateAndObject pushes to the provided channel all triples
+// available for the given predicate and object. The function does not return
+// immediately.
+func (g *graph) TriplesForPredicateAndObject(ctx context.Context, p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	return g.scan(ctx, g.key(idxPO, []byte(p.UUID()), []byte(o.UUID())), lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// Exist checks if the provided triple exists on the store.
+func (g *graph) Exist(ctx context.Context, t *triple.Triple) (bool, error) {
+	if err := g.check(); err != nil {
+		return false, err
+	}
+	return g.s.db.Has(g.key(idxTriples, []byte(t.UUID())), nil)
+}
+
+// Triples pushes to the provided channel all available triples in the graph.
+// The function does not return immediately. Lookups with time bounds only
+// read the temporal triples within the bounds from the anchored index.
+func (g *graph) Triples(ctx context.Context, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	prefix := g.key(idxTriples)
+	if bounded(lo) {
+		prefix = g.key(idxA)
+	}
+	return g.scanAnchored(ctx, prefix, lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
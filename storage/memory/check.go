@@ -0,0 +1,133 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"fmt"
+	"sort"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// IndexChecker is implemented by the memory stores. It allows verifying that
+// the indices of the graphs did not drift apart, for instance after a burst of
+// concurrent mutations.
+type IndexChecker interface {
+	// Check returns an error describing the first discrepancy found between
+	// the master index of a graph and any of its secondary indices.
+	Check(ctx context.Context) error
+}
+
+// secondaryIndex describes one of the secondary indices of a graph and how the
+// key of the entry holding a triple is built out of the UUIDs of its parts.
+type secondaryIndex struct {
+	name    string
+	entries map[string]map[string]*triple.Triple
+	key     func(s, p, o string) string
+}
+
+// Check verifies that all the secondary indices of every graph in the store
+// hold the same triples as the master index, each one filed under the entry
+// matching its subject, predicate, and object, and that the time index holds
+// every temporal triple sorted by time anchor. Graphs are checked in ID order
+// while holding their read lock, and the first discrepancy is reported naming
+// the graph, the triple, and the index.
+func (s *memoryStore) Check(ctx context.Context) error {
+	s.rwmu.RLock()
+	var ids []string
+	gs := make(map[string]*memory, len(s.graphs))
+	for id, g := range s.graphs {
+		ids = append(ids, id)
+		gs[id] = g.(*memory)
+	}
+	s.rwmu.RUnlock()
+	sort.Strings(ids)
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := gs[id].check(); err != nil {
+			return fmt.Errorf("memory.Check(%q): %v", id, err)
+		}
+	}
+	return nil
+}
+
+// check verifies the indices of the graph.
+func (m *memory) check() error {
+	m.rLockIndexes()
+	defer m.rwmu.RUnlock()
+	idxs := []secondaryIndex{
+		{"subject", m.idxS, func(s, p, o string) string { return s }},
+		{"predicate", m.idxP, func(s, p, o string) string { return p }},
+		{"object", m.idxO, func(s, p, o string) string { return o }},
+		{"subject and predicate", m.idxSP, func(s, p, o string) string { return s + p }},
+		{"predicate and object", m.idxPO, func(s, p, o string) string { return p + o }},
+		{"subject and object", m.idxSO, func(s, p, o string) string { return s + o }},
+	}
+	for k, t := range m.idx {
+		if k != UUIDToByteString(t.UUID()) {
+			return fmt.Errorf("triple %s is filed under the wrong key of the master index", t)
+		}
+		s, p, o := partUUIDs(t)
+		for _, idx := range idxs {
+			if _, ok := idx.entries[idx.key(s, p, o)][k]; !ok {
+				return fmt.Errorf("triple %s is missing from the %s index", t, idx.name)
+			}
+		}
+		if t.Predicate().Type() == predicate.Temporal {
+			if _, ok := timeIndexPosition(m.idxT[string(t.Predicate().ID())][s], t); !ok {
+				return fmt.Errorf("triple %s is missing from the time index", t)
+			}
+		}
+	}
+	for _, idx := range idxs {
+		for ek, e := range idx.entries {
+			for k, t := range e {
+				if _, ok := m.idx[k]; !ok {
+					return fmt.Errorf("triple %s of the %s index is missing from the master index", t, idx.name)
+				}
+				if s, p, o := partUUIDs(t); ek != idx.key(s, p, o) {
+					return fmt.Errorf("triple %s is filed under the wrong entry of the %s index", t, idx.name)
+				}
+			}
+		}
+	}
+	for id, ss := range m.idxT {
+		for s, ts := range ss {
+			for i, t := range ts {
+				if _, ok := m.idx[UUIDToByteString(t.UUID())]; !ok {
+					return fmt.Errorf("triple %s of the time index is missing from the master index", t)
+				}
+				if string(t.Predicate().ID()) != id || UUIDToByteString(t.Subject().UUID()) != s {
+					return fmt.Errorf("triple %s is filed under the wrong entry of the time index", t)
+				}
+				if i > 0 && !timeIndexLess(ts[i-1], t) {
+					return fmt.Errorf("triple %s is out of order on the time index", t)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// partUUIDs returns the byte string UUIDs of the subject, predicate, and
+// object of the provided triple.
+func partUUIDs(t *triple.Triple) (string, string, string) {
+	return UUIDToByteString(t.Subject().UUID()), UUIDToByteString(t.Predicate().UUID()), UUIDToByteString(t.Object().UUID())
+}
@@ -0,0 +1,184 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// ClosureMaterializer is implemented by the memory stores. It allows
+// materializing the transitive closure of a predicate to answer repeated
+// reachability queries without traversing the graph each time.
+type ClosureMaterializer interface {
+	// MaterializeClosure returns the transitive closure of the provided
+	// predicate in the graph with the provided ID.
+	MaterializeClosure(ctx context.Context, id string, p *predicate.Predicate) (*Closure, error)
+}
+
+// Closure contains the materialized transitive closure of a predicate in a
+// graph. Each node that is the subject of a triple with the predicate reaches
+// all the nodes that can be visited following one or more triples with the
+// predicate; for instance, the closure of "parent_of" contains all the
+// ancestor and descendant pairs of a family tree.
+//
+// The closure is invalidated lazily. Adding or removing triples with the
+// predicate bumps the version of the predicate in the graph, and the next
+// query on a closure computed for an older version recomputes the whole
+// closure before answering. Mutations of other predicates never invalidate
+// the closure. Queries fail once the graph is deleted from the store.
+type Closure struct {
+	s     *memoryStore
+	id    string
+	g     *memory
+	p     *predicate.Predicate
+	pUUID string
+
+	mu    sync.Mutex
+	valid bool
+	gen   uint64
+	reach map[string]map[string]*node.Node
+}
+
+// MaterializeClosure computes the transitive closure of the provided predicate
+// in the graph with the provided ID. Only triples with node objects and the
+// exact same predicate, including its time anchor, are followed.
+func (s *memoryStore) MaterializeClosure(ctx context.Context, id string, p *predicate.Predicate) (*Closure, error) {
+	sg, err := s.Graph(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	c := &Closure{
+		s:     s,
+		id:    id,
+		g:     sg.(*memory),
+		p:     p,
+		pUUID: UUIDToByteString(p.UUID()),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.refresh(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Predicate returns the predicate whose closure is materialized.
+func (c *Closure) Predicate() *predicate.Predicate {
+	return c.p
+}
+
+// refresh recomputes the closure if the triples of the predicate changed since
+// it was last computed. It assumes the closure lock is already held.
+func (c *Closure) refresh() error {
+	c.s.rwmu.RLock()
+	sg, _ := c.s.graphs[c.id].(*memory)
+	c.s.rwmu.RUnlock()
+	if sg != c.g {
+		return fmt.Errorf("memory.Closure: graph %q does not exist", c.id)
+	}
+	m := c.g
+	m.rLockIndexes()
+	defer m.rwmu.RUnlock()
+	gen := m.pgen[c.pUUID]
+	if c.valid && c.gen == gen {
+		return nil
+	}
+	// Build the adjacency lists and traverse them breadth first from each
+	// subject.
+	adj := make(map[string][]*node.Node)
+	for _, t := range m.idxP[c.pUUID] {
+		o, err := t.Object().Node()
+		if err != nil {
+			continue
+		}
+		k := UUIDToByteString(t.Subject().UUID())
+		adj[k] = append(adj[k], o)
+	}
+	reach := make(map[string]map[string]*node.Node, len(adj))
+	for k := range adj {
+		seen := make(map[string]*node.Node)
+		pending := append([]*node.Node{}, adj[k]...)
+		for len(pending) > 0 {
+			n := pending[0]
+			pending = pending[1:]
+			nk := UUIDToByteString(n.UUID())
+			if _, ok := seen[nk]; ok {
+				continue
+			}
+			seen[nk] = n
+			pending = append(pending, adj[nk]...)
+		}
+		reach[k] = seen
+	}
+	c.reach, c.gen, c.valid = reach, gen, true
+	return nil
+}
+
+// Reaches returns true if the node to can be reached from the node from
+// following one or more triples with the predicate.
+func (c *Closure) Reaches(ctx context.Context, from, to *node.Node) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.refresh(); err != nil {
+		return false, err
+	}
+	_, ok := c.reach[UUIDToByteString(from.UUID())][UUIDToByteString(to.UUID())]
+	return ok, nil
+}
+
+// Reachable returns all the nodes that can be reached from the provided node
+// following one or more triples with the predicate. Nodes are returned sorted
+// by their string representation.
+func (c *Closure) Reachable(ctx context.Context, from *node.Node) ([]*node.Node, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.refresh(); err != nil {
+		return nil, err
+	}
+	var res []*node.Node
+	for _, n := range c.reach[UUIDToByteString(from.UUID())] {
+		res = append(res, n)
+	}
+	sort.Sort(nodesByString(res))
+	return res, nil
+}
+
+// Len returns the number of node pairs in the closure.
+func (c *Closure) Len(ctx context.Context) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.refresh(); err != nil {
+		return 0, err
+	}
+	cnt := 0
+	for _, ns := range c.reach {
+		cnt += len(ns)
+	}
+	return cnt, nil
+}
+
+// nodesByString sorts nodes by their string representation.
+type nodesByString []*node.Node
+
+func (ns nodesByString) Len() int           { return len(ns) }
+func (ns nodesByString) Less(i, j int) bool { return ns[i].String() < ns[j].String() }
+func (ns nodesByString) Swap(i, j int)      { ns[i], ns[j] = ns[j], ns[i] }
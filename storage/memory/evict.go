@@ -0,0 +1,226 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"container/heap"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// evictor keeps the eviction order of the triples of a store created by
+// NewStoreWithLimit. The order is kept lazily: removing a triple from a graph
+// does not touch the queue, and entries of triples that are no longer stored
+// are dropped when popped or when the queue is compacted.
+type evictor struct {
+	mu    sync.Mutex
+	limit int
+	seq   uint64
+	q     evictionQueue
+	// latest contains the sequence number of the last entry queued for each
+	// triple of each graph. Older entries of the same triple are stale.
+	latest map[*memory]map[string]uint64
+}
+
+// evictionEntry is a queued triple of a graph.
+type evictionEntry struct {
+	g        *memory
+	key      string
+	temporal bool
+	anchor   time.Time
+	seq      uint64
+}
+
+// evictionQueue is a min-heap of entries sorted by eviction order. Immutable
+// triples sort before temporal ones, temporal triples sort by time anchor, and
+// the insertion order breaks the remaining ties.
+type evictionQueue []*evictionEntry
+
+func (q evictionQueue) Len() int { return len(q) }
+
+func (q evictionQueue) Less(i, j int) bool {
+	a, b := q[i], q[j]
+	if a.temporal != b.temporal {
+		return !a.temporal
+	}
+	if a.temporal && !a.anchor.Equal(b.anchor) {
+		return a.anchor.Before(b.anchor)
+	}
+	return a.seq < b.seq
+}
+
+func (q evictionQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *evictionQueue) Push(x interface{}) { *q = append(*q, x.(*evictionEntry)) }
+
+func (q *evictionQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// push queues the triple of the provided graph. It assumes the evictor lock is
+// already held.
+func (ev *evictor) push(m *memory, t *triple.Triple) {
+	ev.seq++
+	e := &evictionEntry{
+		g:   m,
+		key: UUIDToByteString(t.UUID()),
+		seq: ev.seq,
+	}
+	if t.Predicate().Type() == predicate.Temporal {
+		ta, err := t.Predicate().TimeAnchor()
+		if err == nil {
+			e.temporal, e.anchor = true, *ta
+		}
+	}
+	if _, ok := ev.latest[m]; !ok {
+		ev.latest[m] = make(map[string]uint64)
+	}
+	ev.latest[m][e.key] = e.seq
+	heap.Push(&ev.q, e)
+}
+
+// untracked returns the triples of the provided list that are not stored in
+// the graph yet, or nil if the store has no limit. It assumes the graph lock
+// is already held.
+func (m *memory) untracked(ts []*triple.Triple) []*triple.Triple {
+	if m.store.ev == nil {
+		return nil
+	}
+	var res []*triple.Triple
+	seen := make(map[string]bool, len(ts))
+	for _, t := range ts {
+		k := UUIDToByteString(t.UUID())
+		if _, ok := m.idx[k]; ok || seen[k] {
+			continue
+		}
+		seen[k] = true
+		res = append(res, t)
+	}
+	return res
+}
+
+// admit queues the triples newly added to the provided graph and evicts the
+// oldest triples of the store until it is back within its limit. It must be
+// called without holding the graph lock.
+func (s *memoryStore) admit(m *memory, ts []*triple.Triple) {
+	if s.ev == nil || len(ts) == 0 {
+		return
+	}
+	s.ev.mu.Lock()
+	defer s.ev.mu.Unlock()
+	for _, t := range ts {
+		s.ev.push(m, t)
+	}
+	s.evict()
+}
+
+// retrack queues the triples of the provided graph that have no eviction
+// entry, such as the ones brought back by Rollback, and evicts the oldest
+// triples of the store until it is back within its limit. It must be called
+// without holding the graph lock.
+func (s *memoryStore) retrack(m *memory) {
+	if s.ev == nil {
+		return
+	}
+	s.ev.mu.Lock()
+	defer s.ev.mu.Unlock()
+	m.rwmu.RLock()
+	for k, t := range m.idx {
+		if s.ev.latest[m][k] == 0 {
+			s.ev.push(m, t)
+		}
+	}
+	m.rwmu.RUnlock()
+	s.evict()
+}
+
+// evict removes the oldest triples of the store until the number of triples
+// across all graphs is within the limit. Triples of read-only graphs are
+// never evicted. It assumes the evictor lock is already held.
+func (s *memoryStore) evict() {
+	ev := s.ev
+	registered, n := make(map[*memory]bool), 0
+	s.rwmu.RLock()
+	for _, g := range s.graphs {
+		m := g.(*memory)
+		registered[m] = true
+		m.rwmu.RLock()
+		n += len(m.idx)
+		m.rwmu.RUnlock()
+	}
+	s.rwmu.RUnlock()
+	for m := range ev.latest {
+		if !registered[m] {
+			delete(ev.latest, m)
+		}
+	}
+
+	var kept []*evictionEntry
+	for n > ev.limit && ev.q.Len() > 0 {
+		e := heap.Pop(&ev.q).(*evictionEntry)
+		if ev.latest[e.g][e.key] != e.seq {
+			continue
+		}
+		e.g.rwmu.Lock()
+		_, ok := e.g.idx[e.key]
+		switch {
+		case !ok:
+			delete(ev.latest[e.g], e.key)
+		case e.g.readOnly:
+			kept = append(kept, e)
+		default:
+			e.g.removeTriple(e.g.idx[e.key])
+			atomic.AddUint64(e.g.gen, 1)
+			delete(ev.latest[e.g], e.key)
+			n--
+		}
+		e.g.rwmu.Unlock()
+	}
+	for _, e := range kept {
+		heap.Push(&ev.q, e)
+	}
+	if ev.q.Len() > 2*ev.limit+initialAllocation {
+		s.compact()
+	}
+}
+
+// compact drops the queued entries of triples that are no longer stored. It
+// assumes the evictor lock is already held.
+func (s *memoryStore) compact() {
+	ev := s.ev
+	q := evictionQueue{}
+	for _, e := range ev.q {
+		if ev.latest[e.g][e.key] != e.seq {
+			continue
+		}
+		e.g.rwmu.RLock()
+		_, ok := e.g.idx[e.key]
+		e.g.rwmu.RUnlock()
+		if !ok {
+			delete(ev.latest[e.g], e.key)
+			continue
+		}
+		q = append(q, e)
+	}
+	heap.Init(&q)
+	ev.q = q
+}
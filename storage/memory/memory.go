@@ -17,8 +17,11 @@
 package memory
 
 import (
+	"encoding/hex"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 
 	"golang.org/x/net/context"
 
@@ -38,17 +41,76 @@ func init() {
 }
 
 type memoryStore struct {
+	// gen must be kept first to guarantee the 64-bit alignment required by
+	// sync/atomic.
+	gen    uint64
 	graphs map[string]storage.Graph
 	rwmu   sync.RWMutex
+	// txc holds a token while a transaction is running.
+	txc chan struct{}
+	// tx is the running transaction. It is nil if no transaction is running.
+	tx *memoryTx
+	// ev keeps the eviction order of the triples. It is nil if the store has
+	// no limit.
+	ev *evictor
+}
+
+// memoryTx contains the snapshots of the graphs of a transaction. done is
+// closed once the transaction ends.
+type memoryTx struct {
+	snapshots []*graphSnapshot
+	done      chan struct{}
+}
+
+// txKey is the context key of the transaction returned by Begin.
+type txKey struct{}
+
+// txFromContext returns the transaction carried by the provided context, or
+// nil if there is none.
+func txFromContext(ctx context.Context) *memoryTx {
+	if ctx == nil {
+		return nil
+	}
+	tx, _ := ctx.Value(txKey{}).(*memoryTx)
+	return tx
+}
+
+// graphSnapshot contains the triples a graph held when a transaction started.
+type graphSnapshot struct {
+	g   *memory
+	idx map[string]*triple.Triple
 }
 
 // NewStore creates a new memory store.
 func NewStore() storage.Store {
 	return &memoryStore{
 		graphs: make(map[string]storage.Graph),
+		txc:    make(chan struct{}, 1),
 	}
 }
 
+// NewStoreWithLimit creates a new memory store that holds at most maxTriples
+// triples across all its graphs. Once an insert takes the store over the limit,
+// the oldest triples are evicted until it is back within the limit. Immutable
+// triples carry no time anchor, hence they are the oldest and are evicted
+// first in insertion order. Temporal triples are evicted next by time anchor,
+// and triples sharing the same anchor in insertion order. Inserting a triple
+// already stored does not change its position. A non positive limit creates a
+// store without limit.
+func NewStoreWithLimit(maxTriples int) storage.Store {
+	s := &memoryStore{
+		graphs: make(map[string]storage.Graph),
+		txc:    make(chan struct{}, 1),
+	}
+	if maxTriples > 0 {
+		s.ev = &evictor{
+			limit:  maxTriples,
+			latest: make(map[*memory]map[string]uint64),
+		}
+	}
+	return s
+}
+
 // Name returns the ID of the backend being used.
 func (s *memoryStore) Name(ctx context.Context) string {
 	return "VOLATILE"
@@ -59,10 +121,18 @@ func (s *memoryStore) Version(ctx context.Context) string {
 	return "0.1.vcli"
 }
 
-// NewGraph creates a new graph.
-func (s *memoryStore) NewGraph(ctx context.Context, id string) (storage.Graph, error) {
-	g := &memory{
+// Generation returns the current generation of the store. It is bumped on
+// any mutation of the store or its graphs.
+func (s *memoryStore) Generation(ctx context.Context) (uint64, error) {
+	return atomic.LoadUint64(&s.gen), nil
+}
+
+// newMemory returns a new empty graph of the store that is not registered yet.
+func (s *memoryStore) newMemory(id string) *memory {
+	return &memory{
 		id:    id,
+		store: s,
+		gen:   &s.gen,
 		idx:   make(map[string]*triple.Triple, initialAllocation),
 		idxS:  make(map[string]map[string]*triple.Triple, initialAllocation),
 		idxP:  make(map[string]map[string]*triple.Triple, initialAllocation),
@@ -70,14 +140,21 @@ func (s *memoryStore) NewGraph(ctx context.Context, id string) (storage.Graph, e
 		idxSP: make(map[string]map[string]*triple.Triple, initialAllocation),
 		idxPO: make(map[string]map[string]*triple.Triple, initialAllocation),
 		idxSO: make(map[string]map[string]*triple.Triple, initialAllocation),
+		pgen:  make(map[string]uint64),
+		idxT:  make(map[string]map[string][]*triple.Triple, initialAllocation),
 	}
+}
 
+// NewGraph creates a new graph.
+func (s *memoryStore) NewGraph(ctx context.Context, id string) (storage.Graph, error) {
+	g := s.newMemory(id)
 	s.rwmu.Lock()
 	defer s.rwmu.Unlock()
 	if _, ok := s.graphs[id]; ok {
 		return nil, fmt.Errorf("memory.NewGraph(%q): graph already exists", id)
 	}
 	s.graphs[id] = g
+	atomic.AddUint64(&s.gen, 1)
 	return g, nil
 }
 
@@ -93,15 +170,49 @@ func (s *memoryStore) Graph(ctx context.Context, id string) (storage.Graph, erro
 }
 
 // DeleteGraph deletes an existing graph. Deleting a non existing graph
-// should return an error.
+// should return an error. Read-only graphs cannot be deleted.
 func (s *memoryStore) DeleteGraph(ctx context.Context, id string) error {
 	s.rwmu.Lock()
 	defer s.rwmu.Unlock()
-	if _, ok := s.graphs[id]; ok {
-		delete(s.graphs, id)
-		return nil
+	g, ok := s.graphs[id]
+	if !ok {
+		return fmt.Errorf("memory.DeleteGraph(%q): graph does not exist", id)
+	}
+	m := g.(*memory)
+	m.rwmu.RLock()
+	err := m.checkWritable("DeleteGraph")
+	m.rwmu.RUnlock()
+	if err != nil {
+		return err
+	}
+	delete(s.graphs, id)
+	atomic.AddUint64(&s.gen, 1)
+	return nil
+}
+
+// CloneGraph creates the dst graph holding a copy of the triples the src graph
+// holds when called. The clone has its own indices, hence later mutations of
+// either graph do not affect the other one; only the triples, which are
+// immutable, are shared. The clone is writable and has no metadata regardless
+// of the marks of src.
+func (s *memoryStore) CloneGraph(ctx context.Context, src, dst string) error {
+	g, err := s.Graph(ctx, src)
+	if err != nil {
+		return err
+	}
+	c := s.newMemory(dst)
+	c.idx = g.(*memory).snapshot().idx
+	c.rebuildIndexes()
+	s.rwmu.Lock()
+	if _, ok := s.graphs[dst]; ok {
+		s.rwmu.Unlock()
+		return fmt.Errorf("memory.CloneGraph(%q, %q): graph %q already exists", src, dst, dst)
 	}
-	return fmt.Errorf("memory.DeleteGraph(%q): graph does not exist", id)
+	s.graphs[dst] = c
+	atomic.AddUint64(&s.gen, 1)
+	s.rwmu.Unlock()
+	s.retrack(c)
+	return nil
 }
 
 // GraphNames returns the current available graph names in the store.
@@ -118,9 +229,154 @@ func (s *memoryStore) GraphNames(ctx context.Context, names chan<- string) error
 	return nil
 }
 
+// SetReadOnly marks the graph as read-only, or writable again if ro is false.
+// Mutations of read-only graphs fail without changing any triple.
+func (s *memoryStore) SetReadOnly(ctx context.Context, id string, ro bool) error {
+	g, err := s.Graph(ctx, id)
+	if err != nil {
+		return err
+	}
+	m := g.(*memory)
+	m.rwmu.Lock()
+	defer m.rwmu.Unlock()
+	m.readOnly = ro
+	return nil
+}
+
+// ReadOnly returns true if the graph is marked as read-only.
+func (s *memoryStore) ReadOnly(ctx context.Context, id string) (bool, error) {
+	g, err := s.Graph(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	m := g.(*memory)
+	m.rwmu.RLock()
+	defer m.rwmu.RUnlock()
+	return m.readOnly, nil
+}
+
+// SetGraphMetadata sets the value of the metadata key of the graph. An empty
+// value removes the key. The metadata of read-only graphs can be changed,
+// since it does not change any triple.
+func (s *memoryStore) SetGraphMetadata(ctx context.Context, id, key, value string) error {
+	g, err := s.Graph(ctx, id)
+	if err != nil {
+		return err
+	}
+	m := g.(*memory)
+	m.rwmu.Lock()
+	defer m.rwmu.Unlock()
+	if value == "" {
+		delete(m.metadata, key)
+		return nil
+	}
+	if m.metadata == nil {
+		m.metadata = make(map[string]string)
+	}
+	m.metadata[key] = value
+	return nil
+}
+
+// GraphMetadata returns a copy of the metadata of the graph.
+func (s *memoryStore) GraphMetadata(ctx context.Context, id string) (map[string]string, error) {
+	g, err := s.Graph(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	m := g.(*memory)
+	m.rwmu.RLock()
+	defer m.rwmu.RUnlock()
+	md := make(map[string]string, len(m.metadata))
+	for k, v := range m.metadata {
+		md[k] = v
+	}
+	return md, nil
+}
+
+// Begin starts a transaction snapshotting the triples of the provided graphs.
+// It blocks until the running transaction, if any, ends, or returns the error
+// of ctx once it is done. The returned context identifies the transaction;
+// other writers of the snapshotted graphs block until the transaction ends,
+// hence Rollback only reverts the mutations made with the returned context.
+func (s *memoryStore) Begin(ctx context.Context, graphs []string) (context.Context, error) {
+	select {
+	case s.txc <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	s.rwmu.Lock()
+	defer s.rwmu.Unlock()
+	tx, seen := &memoryTx{done: make(chan struct{})}, make(map[string]bool)
+	for _, id := range graphs {
+		g, ok := s.graphs[id]
+		if !ok || seen[id] {
+			continue
+		}
+		seen[id] = true
+		m := g.(*memory)
+		m.rwmu.Lock()
+		m.tx = tx
+		m.rwmu.Unlock()
+		tx.snapshots = append(tx.snapshots, m.snapshot())
+	}
+	s.tx = tx
+	return context.WithValue(ctx, txKey{}, tx), nil
+}
+
+// Commit drops the snapshots of the running transaction.
+func (s *memoryStore) Commit(ctx context.Context) error {
+	tx, err := s.endTx("Commit")
+	if err != nil {
+		return err
+	}
+	s.release(tx)
+	return nil
+}
+
+// Rollback restores the snapshotted graphs of the running transaction.
+func (s *memoryStore) Rollback(ctx context.Context) error {
+	tx, err := s.endTx("Rollback")
+	if err != nil {
+		return err
+	}
+	for _, sn := range tx.snapshots {
+		sn.g.restore(sn.idx)
+		s.retrack(sn.g)
+	}
+	s.release(tx)
+	return nil
+}
+
+// endTx returns the running transaction and clears it. It fails if no
+// transaction is running.
+func (s *memoryStore) endTx(op string) (*memoryTx, error) {
+	s.rwmu.Lock()
+	defer s.rwmu.Unlock()
+	if s.tx == nil {
+		return nil, fmt.Errorf("memory.%s: no transaction is running", op)
+	}
+	tx := s.tx
+	s.tx = nil
+	return tx, nil
+}
+
+// release unblocks the writers of the graphs of the provided transaction
+// and lets the next transaction begin.
+func (s *memoryStore) release(tx *memoryTx) {
+	for _, sn := range tx.snapshots {
+		sn.g.rwmu.Lock()
+		sn.g.tx = nil
+		sn.g.rwmu.Unlock()
+	}
+	close(tx.done)
+	<-s.txc
+}
+
 // memory provides an memory-based volatile implementation of the graph API.
 type memory struct {
 	id    string
+	store *memoryStore
+	gen   *uint64
 	rwmu  sync.RWMutex
 	idx   map[string]*triple.Triple
 	idxS  map[string]map[string]*triple.Triple
@@ -129,6 +385,64 @@ type memory struct {
 	idxSP map[string]map[string]*triple.Triple
 	idxPO map[string]map[string]*triple.Triple
 	idxSO map[string]map[string]*triple.Triple
+	// stale is true if triples were added to the master index without
+	// updating the secondary indices.
+	stale bool
+	// pgen tracks the version of the triples of each predicate. It is bumped
+	// every time a triple is added to or removed from the predicate index.
+	pgen map[string]uint64
+	// idxT is the time index of the temporal triples. It keeps the triples of
+	// each predicate ID and subject sorted by time anchor using timeIndexLess.
+	idxT map[string]map[string][]*triple.Triple
+	// unsortedT contains the time index entries triples were appended to
+	// since they were last sorted. Mutations sort them once before releasing
+	// the write lock.
+	unsortedT map[timeIndexKey]bool
+	// readOnly is true if the triples of the graph cannot be mutated.
+	readOnly bool
+	// metadata contains the key/value metadata attached to the graph.
+	metadata map[string]string
+	// tx is the running transaction that snapshotted the graph. Only the
+	// writers using its context may mutate the graph until it ends.
+	tx *memoryTx
+	// sorted contains the keys of the master index in ascending order as of
+	// the store generation sortedGen, hence pages do not sort the keys on
+	// every call. Both are guarded by sortedMu.
+	sortedMu  sync.Mutex
+	sorted    []string
+	sortedGen uint64
+}
+
+// checkWritable returns an error if the graph is read-only. It assumes the
+// lock is already held.
+func (m *memory) checkWritable(op string) error {
+	if m.readOnly {
+		return fmt.Errorf("memory.%s(%q): graph is read-only", op, m.id)
+	}
+	return nil
+}
+
+// lockForWrite acquires the write lock once no transaction other than the one
+// of ctx snapshotted the graph, and checks that the graph is writable. It
+// returns the error of ctx if it is done while waiting. The lock is only held
+// if no error is returned.
+func (m *memory) lockForWrite(ctx context.Context, op string) error {
+	m.rwmu.Lock()
+	for m.tx != nil && m.tx != txFromContext(ctx) {
+		done := m.tx.done
+		m.rwmu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		m.rwmu.Lock()
+	}
+	if err := m.checkWritable(op); err != nil {
+		m.rwmu.Unlock()
+		return err
+	}
+	return nil
 }
 
 // ID returns the id for this graph.
@@ -136,89 +450,503 @@ func (m *memory) ID(ctx context.Context) string {
 	return m.id
 }
 
-// AddTriples adds the triples to the storage.
+// AddTriples adds the triples to the storage. It is safe to call it
+// concurrently, and adding a triple already stored is a no-op; triples are
+// keyed by their UUID, hence the graph holds a single copy of each one.
 func (m *memory) AddTriples(ctx context.Context, ts []*triple.Triple) error {
-	m.rwmu.Lock()
+	var added []*triple.Triple
+	defer func() { m.store.admit(m, added) }()
+	if err := m.lockForWrite(ctx, "AddTriples"); err != nil {
+		return err
+	}
 	defer m.rwmu.Unlock()
+	added = m.untracked(ts)
 	for _, t := range ts {
-		suuid := UUIDToByteString(t.UUID())
+		if _, ok := m.idx[UUIDToByteString(t.UUID())]; ok {
+			// Stored triples are already indexed, or will be once the stale
+			// indices are rebuilt; the predicate versions are left untouched.
+			continue
+		}
+		m.addTriple(t)
+	}
+	m.sortTimeIndex()
+	atomic.AddUint64(m.gen, 1)
+	return nil
+}
+
+// AddTriplesWithOptions adds the triples to the storage honoring the provided
+// insert options. The superseded triples are removed while holding the graph
+// lock, hence the cleanup is atomic.
+func (m *memory) AddTriplesWithOptions(ctx context.Context, ts []*triple.Triple, opts *storage.InsertOptions) (int, error) {
+	var added []*triple.Triple
+	defer func() { m.store.admit(m, added) }()
+	if err := m.lockForWrite(ctx, "AddTriplesWithOptions"); err != nil {
+		return 0, err
+	}
+	defer m.rwmu.Unlock()
+	added = m.untracked(ts)
+	if m.stale {
+		m.rebuildIndexes()
+	}
+	defer atomic.AddUint64(m.gen, 1)
+	defer m.sortTimeIndex()
+	cnt := 0
+	for _, t := range ts {
+		if !opts.KeepsLatest(t.Predicate()) {
+			m.addTriple(t)
+			continue
+		}
+		ta, err := t.Predicate().TimeAnchor()
+		if err != nil {
+			return cnt, err
+		}
 		sUUID := UUIDToByteString(t.Subject().UUID())
-		pUUID := UUIDToByteString(t.Predicate().UUID())
 		oUUID := UUIDToByteString(t.Object().UUID())
-		// Update master index
-		m.idx[suuid] = t
-
-		if _, ok := m.idxS[sUUID]; !ok {
-			m.idxS[sUUID] = make(map[string]*triple.Triple)
+		superseded, keep := []*triple.Triple{}, true
+		for _, st := range m.idxSO[sUUID+oUUID] {
+			sp := st.Predicate()
+			if sp.ID() != t.Predicate().ID() || sp.Type() != predicate.Temporal {
+				continue
+			}
+			sta, err := sp.TimeAnchor()
+			if err != nil {
+				return cnt, err
+			}
+			if sta.Before(*ta) {
+				superseded = append(superseded, st)
+			} else {
+				keep = false
+			}
 		}
-		m.idxS[sUUID][suuid] = t
-
-		if _, ok := m.idxP[pUUID]; !ok {
-			m.idxP[pUUID] = make(map[string]*triple.Triple)
+		for _, st := range superseded {
+			m.removeTriple(st)
+			cnt++
 		}
-		m.idxP[pUUID][suuid] = t
-
-		if _, ok := m.idxO[oUUID]; !ok {
-			m.idxO[oUUID] = make(map[string]*triple.Triple)
+		// The incoming triple is dropped if a newer one is already stored.
+		if keep {
+			m.addTriple(t)
 		}
-		m.idxO[oUUID][suuid] = t
+	}
+	return cnt, nil
+}
+
+// AddTriplesDeferringIndexes adds the triples to the master index only. The
+// secondary indices are rebuilt by RebuildIndexes or by the first lookup that
+// requires them.
+func (m *memory) AddTriplesDeferringIndexes(ctx context.Context, ts []*triple.Triple) error {
+	var added []*triple.Triple
+	defer func() { m.store.admit(m, added) }()
+	if err := m.lockForWrite(ctx, "AddTriplesDeferringIndexes"); err != nil {
+		return err
+	}
+	defer m.rwmu.Unlock()
+	added = m.untracked(ts)
+	for _, t := range ts {
+		m.idx[UUIDToByteString(t.UUID())] = t
+	}
+	m.stale = true
+	atomic.AddUint64(m.gen, 1)
+	return nil
+}
+
+// RebuildIndexes builds all the secondary indices out of the master index.
+func (m *memory) RebuildIndexes(ctx context.Context) error {
+	m.rwmu.Lock()
+	defer m.rwmu.Unlock()
+	m.rebuildIndexes()
+	return nil
+}
 
-		key := sUUID + pUUID
-		if _, ok := m.idxSP[key]; !ok {
-			m.idxSP[key] = make(map[string]*triple.Triple)
+// rebuildIndexes drops and rebuilds all the secondary indices. Each index is
+// sized after the number of keys it held before, bounded by the number of
+// triples, since most of them are expected to be kept. It assumes the write
+// lock is already held.
+func (m *memory) rebuildIndexes() {
+	n := len(m.idx)
+	hint := func(keys int) int {
+		if keys > n {
+			return n
 		}
-		m.idxSP[key][suuid] = t
+		return keys
+	}
+	m.idxS = make(map[string]map[string]*triple.Triple, hint(len(m.idxS)))
+	m.idxP = make(map[string]map[string]*triple.Triple, hint(len(m.idxP)))
+	m.idxO = make(map[string]map[string]*triple.Triple, hint(len(m.idxO)))
+	m.idxSP = make(map[string]map[string]*triple.Triple, hint(len(m.idxSP)))
+	m.idxPO = make(map[string]map[string]*triple.Triple, hint(len(m.idxPO)))
+	m.idxSO = make(map[string]map[string]*triple.Triple, hint(len(m.idxSO)))
+	m.idxT = make(map[string]map[string][]*triple.Triple, hint(len(m.idxT)))
+	m.unsortedT = nil
+	for _, t := range m.idx {
+		m.addTriple(t)
+	}
+	m.sortTimeIndex()
+	m.stale = false
+}
+
+// snapshot returns a copy of the master index of the graph.
+func (m *memory) snapshot() *graphSnapshot {
+	m.rwmu.RLock()
+	defer m.rwmu.RUnlock()
+	idx := make(map[string]*triple.Triple, len(m.idx))
+	for k, t := range m.idx {
+		idx[k] = t
+	}
+	return &graphSnapshot{g: m, idx: idx}
+}
+
+// restore replaces the triples of the graph with the ones of the provided
+// master index and rebuilds the secondary indices out of them. The versions of
+// all the predicates are bumped since any of them may have changed.
+func (m *memory) restore(idx map[string]*triple.Triple) {
+	m.rwmu.Lock()
+	defer m.rwmu.Unlock()
+	for p := range m.pgen {
+		m.pgen[p]++
+	}
+	m.idx = idx
+	m.rebuildIndexes()
+	atomic.AddUint64(m.gen, 1)
+}
 
-		key = pUUID + oUUID
-		if _, ok := m.idxPO[key]; !ok {
-			m.idxPO[key] = make(map[string]*triple.Triple)
+// rLockIndexes acquires the read lock making sure the secondary indices are
+// up to date. Stale indices are rebuilt before granting the read lock.
+func (m *memory) rLockIndexes() {
+	m.rwmu.RLock()
+	for m.stale {
+		m.rwmu.RUnlock()
+		m.rwmu.Lock()
+		if m.stale {
+			m.rebuildIndexes()
 		}
-		m.idxPO[key][suuid] = t
+		m.rwmu.Unlock()
+		m.rwmu.RLock()
+	}
+}
+
+// addTriple adds the triple to all the indices. It assumes the write lock is
+// already held.
+func (m *memory) addTriple(t *triple.Triple) {
+	suuid := UUIDToByteString(t.UUID())
+	sUUID := UUIDToByteString(t.Subject().UUID())
+	pUUID := UUIDToByteString(t.Predicate().UUID())
+	oUUID := UUIDToByteString(t.Object().UUID())
+	// Update master index
+	m.idx[suuid] = t
+
+	if _, ok := m.idxS[sUUID]; !ok {
+		m.idxS[sUUID] = make(map[string]*triple.Triple)
+	}
+	m.idxS[sUUID][suuid] = t
+
+	if _, ok := m.idxP[pUUID]; !ok {
+		m.idxP[pUUID] = make(map[string]*triple.Triple)
+	}
+	m.idxP[pUUID][suuid] = t
+	m.pgen[pUUID]++
+
+	if _, ok := m.idxO[oUUID]; !ok {
+		m.idxO[oUUID] = make(map[string]*triple.Triple)
+	}
+	m.idxO[oUUID][suuid] = t
 
-		key = sUUID + oUUID
-		if _, ok := m.idxSO[key]; !ok {
-			m.idxSO[key] = make(map[string]*triple.Triple)
+	key := sUUID + pUUID
+	if _, ok := m.idxSP[key]; !ok {
+		m.idxSP[key] = make(map[string]*triple.Triple)
+	}
+	m.idxSP[key][suuid] = t
+
+	key = pUUID + oUUID
+	if _, ok := m.idxPO[key]; !ok {
+		m.idxPO[key] = make(map[string]*triple.Triple)
+	}
+	m.idxPO[key][suuid] = t
+
+	key = sUUID + oUUID
+	if _, ok := m.idxSO[key]; !ok {
+		m.idxSO[key] = make(map[string]*triple.Triple)
+	}
+	m.idxSO[key][suuid] = t
+
+	m.addToTimeIndex(t, sUUID)
+}
+
+// timeIndexLess returns true if triple a sorts before triple b on the time
+// index. Triples are sorted by time anchor, and triples sharing the same time
+// anchor in reverse object order; hence walking the index backward returns the
+// newest triples first and ties sorted by object. The UUID breaks the
+// remaining ties.
+func timeIndexLess(a, b *triple.Triple) bool {
+	ta, _ := a.Predicate().TimeAnchor()
+	tb, _ := b.Predicate().TimeAnchor()
+	if !ta.Equal(*tb) {
+		return ta.Before(*tb)
+	}
+	oa, ob := a.Object().String(), b.Object().String()
+	if oa != ob {
+		return oa > ob
+	}
+	return UUIDToByteString(a.UUID()) < UUIDToByteString(b.UUID())
+}
+
+// timeIndexTriples sorts the triples of a time index entry using
+// timeIndexLess.
+type timeIndexTriples []*triple.Triple
+
+func (ts timeIndexTriples) Len() int           { return len(ts) }
+func (ts timeIndexTriples) Less(i, j int) bool { return timeIndexLess(ts[i], ts[j]) }
+func (ts timeIndexTriples) Swap(i, j int)      { ts[i], ts[j] = ts[j], ts[i] }
+
+// timeIndexKey identifies a time index entry by predicate ID and subject.
+type timeIndexKey struct {
+	id, s string
+}
+
+// timeIndexPosition returns the position of the triple on the provided time
+// index entry and whether the triple is already there.
+func timeIndexPosition(ts []*triple.Triple, t *triple.Triple) (int, bool) {
+	i := sort.Search(len(ts), func(i int) bool {
+		return !timeIndexLess(ts[i], t)
+	})
+	return i, i < len(ts) && UUIDToByteString(ts[i].UUID()) == UUIDToByteString(t.UUID())
+}
+
+// addToTimeIndex appends temporal triples to the time index. The entry is
+// left unsorted until sortTimeIndex is called, hence a batch sorts each entry
+// once instead of shifting it on every insertion. It assumes the write lock is
+// already held.
+func (m *memory) addToTimeIndex(t *triple.Triple, sUUID string) {
+	if t.Predicate().Type() != predicate.Temporal {
+		return
+	}
+	id := string(t.Predicate().ID())
+	if _, ok := m.idxT[id]; !ok {
+		m.idxT[id] = make(map[string][]*triple.Triple)
+	}
+	if m.unsortedT == nil {
+		m.unsortedT = make(map[timeIndexKey]bool)
+	}
+	m.idxT[id][sUUID] = append(m.idxT[id][sUUID], t)
+	m.unsortedT[timeIndexKey{id, sUUID}] = true
+}
+
+// sortTimeIndex sorts the time index entries triples were appended to,
+// dropping the triples appended more than once. It assumes the write lock is
+// already held.
+func (m *memory) sortTimeIndex() {
+	for k := range m.unsortedT {
+		m.sortTimeIndexEntry(k)
+	}
+}
+
+// sortTimeIndexEntry sorts the provided time index entry if triples were
+// appended to it. It assumes the write lock is already held.
+func (m *memory) sortTimeIndexEntry(k timeIndexKey) {
+	if !m.unsortedT[k] {
+		return
+	}
+	delete(m.unsortedT, k)
+	ts := m.idxT[k.id][k.s]
+	sort.Sort(timeIndexTriples(ts))
+	// Copies of the same triple are adjacent since the UUID breaks the ties.
+	j := 0
+	for i, t := range ts {
+		if i > 0 && UUIDToByteString(t.UUID()) == UUIDToByteString(ts[j-1].UUID()) {
+			continue
 		}
-		m.idxSO[key][suuid] = t
+		ts[j] = t
+		j++
 	}
-	return nil
+	m.idxT[k.id][k.s] = ts[:j]
+}
+
+// removeFromTimeIndex removes temporal triples from the time index. It assumes
+// the write lock is already held.
+func (m *memory) removeFromTimeIndex(t *triple.Triple, sUUID string) {
+	if t.Predicate().Type() != predicate.Temporal {
+		return
+	}
+	id := string(t.Predicate().ID())
+	m.sortTimeIndexEntry(timeIndexKey{id, sUUID})
+	ts := m.idxT[id][sUUID]
+	i, ok := timeIndexPosition(ts, t)
+	if !ok {
+		return
+	}
+	ts = append(ts[:i], ts[i+1:]...)
+	if len(ts) > 0 {
+		m.idxT[id][sUUID] = ts
+		return
+	}
+	delete(m.idxT[id], sUUID)
+	if len(m.idxT[id]) == 0 {
+		delete(m.idxT, id)
+	}
+}
+
+// CompareAndSwap replaces the expected triple with the new one if the
+// expected triple is stored. The check and the swap happen while holding the
+// graph write lock.
+func (m *memory) CompareAndSwap(ctx context.Context, expected, new *triple.Triple) (bool, error) {
+	var added []*triple.Triple
+	defer func() { m.store.admit(m, added) }()
+	if err := m.lockForWrite(ctx, "CompareAndSwap"); err != nil {
+		return false, err
+	}
+	defer m.rwmu.Unlock()
+	if _, ok := m.idx[UUIDToByteString(expected.UUID())]; !ok {
+		return false, nil
+	}
+	added = m.untracked([]*triple.Triple{new})
+	m.removeTriple(expected)
+	m.addTriple(new)
+	m.sortTimeIndex()
+	atomic.AddUint64(m.gen, 1)
+	return true, nil
 }
 
-// RemoveTriples removes the triples from the storage.
+// RemoveTriples removes the triples from the storage. The whole batch is
+// removed while holding the graph lock, hence readers either see all the
+// triples or none of them.
 func (m *memory) RemoveTriples(ctx context.Context, ts []*triple.Triple) error {
+	if err := m.lockForWrite(ctx, "RemoveTriples"); err != nil {
+		return err
+	}
+	defer m.rwmu.Unlock()
 	for _, t := range ts {
-		suuid := UUIDToByteString(t.UUID())
-		sUUID := UUIDToByteString(t.Subject().UUID())
-		pUUID := UUIDToByteString(t.Predicate().UUID())
-		oUUID := UUIDToByteString(t.Object().UUID())
-		// Update master index
-		m.rwmu.Lock()
-		delete(m.idx, suuid)
-		delete(m.idxS[sUUID], suuid)
-		delete(m.idxP[pUUID], suuid)
-		delete(m.idxO[oUUID], suuid)
+		m.removeTriple(t)
+	}
+	atomic.AddUint64(m.gen, 1)
+	return nil
+}
 
-		key := sUUID + pUUID
-		delete(m.idxSP[key], suuid)
-		if len(m.idxSP[key]) == 0 {
-			delete(m.idxSP, key)
-		}
+// Clear removes all the triples of the graph by resetting its indices. The
+// graph keeps its metadata and read-only mark, and the versions of all the
+// predicates are bumped.
+func (m *memory) Clear(ctx context.Context) error {
+	if err := m.lockForWrite(ctx, "Clear"); err != nil {
+		return err
+	}
+	defer m.rwmu.Unlock()
+	for p := range m.pgen {
+		m.pgen[p]++
+	}
+	m.idx = make(map[string]*triple.Triple)
+	m.rebuildIndexes()
+	atomic.AddUint64(m.gen, 1)
+	return nil
+}
 
-		key = pUUID + oUUID
-		delete(m.idxPO[key], suuid)
-		if len(m.idxPO[key]) == 0 {
-			delete(m.idxPO, key)
+// RemoveMatching removes the triples matching the provided lookup within the
+// lookup options anchors. The matching triples are looked up on the most
+// specific index entry and removed while holding the graph lock, hence readers
+// either see all of them or none.
+func (m *memory) RemoveMatching(ctx context.Context, lookup *storage.CardinalityLookup, lo *storage.LookupOptions) (int, error) {
+	if err := m.lockForWrite(ctx, "RemoveMatching"); err != nil {
+		return 0, err
+	}
+	defer m.rwmu.Unlock()
+	if m.stale {
+		m.rebuildIndexes()
+	}
+	var rts []*triple.Triple
+	for _, t := range candidates(m.entry(lookup), lo) {
+		if lookup.Matches(t) && lo.InTimeRange(t.Predicate()) {
+			rts = append(rts, t)
 		}
+	}
+	for _, t := range rts {
+		m.removeTriple(t)
+	}
+	if len(rts) > 0 {
+		atomic.AddUint64(m.gen, 1)
+	}
+	return len(rts), nil
+}
 
-		key = sUUID + oUUID
-		delete(m.idxSO[key], suuid)
-		if len(m.idxSO[key]) == 0 {
-			delete(m.idxSO, key)
-		}
+// entry returns the most specific index entry containing the triples matching
+// the provided lookup. It assumes the lock is already held.
+func (m *memory) entry(lookup *storage.CardinalityLookup) map[string]*triple.Triple {
+	var sUUID, pUUID, oUUID string
+	if lookup.S != nil {
+		sUUID = UUIDToByteString(lookup.S.UUID())
+	}
+	if lookup.P != nil {
+		pUUID = UUIDToByteString(lookup.P.UUID())
+	}
+	if lookup.O != nil {
+		oUUID = UUIDToByteString(lookup.O.UUID())
+	}
+	switch {
+	case lookup.S != nil && lookup.P != nil:
+		return m.idxSP[sUUID+pUUID]
+	case lookup.P != nil && lookup.O != nil:
+		return m.idxPO[pUUID+oUUID]
+	case lookup.S != nil && lookup.O != nil:
+		return m.idxSO[sUUID+oUUID]
+	case lookup.S != nil:
+		return m.idxS[sUUID]
+	case lookup.P != nil:
+		return m.idxP[pUUID]
+	case lookup.O != nil:
+		return m.idxO[oUUID]
+	default:
+		return m.idx
+	}
+}
 
-		m.rwmu.Unlock()
+// candidates returns the triples of the provided index entry a lookup with the
+// provided options may return. Unless LatestOnly is set, that is the whole
+// entry; otherwise, only the latest version of each triple within the time
+// anchors is returned.
+func candidates(ts map[string]*triple.Triple, lo *storage.LookupOptions) map[string]*triple.Triple {
+	if !lo.LatestOnly {
+		return ts
 	}
-	return nil
+	in := make([]*triple.Triple, 0, len(ts))
+	for _, t := range ts {
+		in = append(in, t)
+	}
+	res := make(map[string]*triple.Triple, len(in))
+	for _, t := range storage.LatestVersions(in, lo) {
+		res[UUIDToByteString(t.UUID())] = t
+	}
+	return res
+}
+
+// removeTriple removes the triple from all the indices. It assumes the write
+// lock is already held.
+func (m *memory) removeTriple(t *triple.Triple) {
+	suuid := UUIDToByteString(t.UUID())
+	sUUID := UUIDToByteString(t.Subject().UUID())
+	pUUID := UUIDToByteString(t.Predicate().UUID())
+	oUUID := UUIDToByteString(t.Object().UUID())
+	// Update master index
+	delete(m.idx, suuid)
+	delete(m.idxS[sUUID], suuid)
+	delete(m.idxP[pUUID], suuid)
+	m.pgen[pUUID]++
+	delete(m.idxO[oUUID], suuid)
+
+	key := sUUID + pUUID
+	delete(m.idxSP[key], suuid)
+	if len(m.idxSP[key]) == 0 {
+		delete(m.idxSP, key)
+	}
+
+	key = pUUID + oUUID
+	delete(m.idxPO[key], suuid)
+	if len(m.idxPO[key]) == 0 {
+		delete(m.idxPO, key)
+	}
+
+	key = sUUID + oUUID
+	delete(m.idxSO[key], suuid)
+	if len(m.idxSO[key]) == 0 {
+		delete(m.idxSO, key)
+	}
+
+	m.removeFromTimeIndex(t, sUUID)
 }
 
 // checker provides the mechanics to check if a predicate/triple should be
@@ -259,6 +987,15 @@ func (c *checker) CheckAndUpdate(p *predicate.Predicate) bool {
 	return true
 }
 
+// done returns the channel closed when the provided context is done. A nil
+// context is never done.
+func done(ctx context.Context) <-chan struct{} {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Done()
+}
+
 // Objects published the objects for the give object and predicate to the
 // provided channel.
 func (m *memory) Objects(ctx context.Context, s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions, objs chan<- *triple.Object) error {
@@ -266,14 +1003,18 @@ func (m *memory) Objects(ctx context.Context, s *node.Node, p *predicate.Predica
 	sUUID := UUIDToByteString(s.UUID())
 	pUUID := UUIDToByteString(p.UUID())
 	spIdx := sUUID + pUUID
-	m.rwmu.RLock()
+	m.rLockIndexes()
 	defer m.rwmu.RUnlock()
 	defer close(objs)
 
 	ckr := newChecker(lo)
-	for _, t := range m.idxSP[spIdx] {
+	for _, t := range candidates(m.idxSP[spIdx], lo) {
 		if ckr.CheckAndUpdate(t.Predicate()) {
-			objs <- t.Object()
+			select {
+			case objs <- t.Object():
+			case <-done(ctx):
+				return ctx.Err()
+			}
 		}
 	}
 	return nil
@@ -288,14 +1029,18 @@ func (m *memory) Subjects(ctx context.Context, p *predicate.Predicate, o *triple
 	pUUID := UUIDToByteString(p.UUID())
 	oUUID := UUIDToByteString(o.UUID())
 	poIdx := pUUID + oUUID
-	m.rwmu.RLock()
+	m.rLockIndexes()
 	defer m.rwmu.RUnlock()
 	defer close(subjs)
 
 	ckr := newChecker(lo)
-	for _, t := range m.idxPO[poIdx] {
+	for _, t := range candidates(m.idxPO[poIdx], lo) {
 		if ckr.CheckAndUpdate(t.Predicate()) {
-			subjs <- t.Subject()
+			select {
+			case subjs <- t.Subject():
+			case <-done(ctx):
+				return ctx.Err()
+			}
 		}
 	}
 	return nil
@@ -310,14 +1055,18 @@ func (m *memory) PredicatesForSubjectAndObject(ctx context.Context, s *node.Node
 	sUUID := UUIDToByteString(s.UUID())
 	oUUID := UUIDToByteString(o.UUID())
 	soIdx := sUUID + oUUID
-	m.rwmu.RLock()
+	m.rLockIndexes()
 	defer m.rwmu.RUnlock()
 	defer close(prds)
 
 	ckr := newChecker(lo)
-	for _, t := range m.idxSO[soIdx] {
+	for _, t := range candidates(m.idxSO[soIdx], lo) {
 		if ckr.CheckAndUpdate(t.Predicate()) {
-			prds <- t.Predicate()
+			select {
+			case prds <- t.Predicate():
+			case <-done(ctx):
+				return ctx.Err()
+			}
 		}
 	}
 	return nil
@@ -330,13 +1079,17 @@ func (m *memory) PredicatesForSubject(ctx context.Context, s *node.Node, lo *sto
 		return fmt.Errorf("cannot provide an empty channel")
 	}
 	sUUID := UUIDToByteString(s.UUID())
-	m.rwmu.RLock()
+	m.rLockIndexes()
 	defer m.rwmu.RUnlock()
 	defer close(prds)
 	ckr := newChecker(lo)
-	for _, t := range m.idxS[sUUID] {
+	for _, t := range candidates(m.idxS[sUUID], lo) {
 		if ckr.CheckAndUpdate(t.Predicate()) {
-			prds <- t.Predicate()
+			select {
+			case prds <- t.Predicate():
+			case <-done(ctx):
+				return ctx.Err()
+			}
 		}
 	}
 	return nil
@@ -349,13 +1102,17 @@ func (m *memory) PredicatesForObject(ctx context.Context, o *triple.Object, lo *
 		return fmt.Errorf("cannot provide an empty channel")
 	}
 	oUUID := UUIDToByteString(o.UUID())
-	m.rwmu.RLock()
+	m.rLockIndexes()
 	defer m.rwmu.RUnlock()
 	defer close(prds)
 	ckr := newChecker(lo)
-	for _, t := range m.idxO[oUUID] {
+	for _, t := range candidates(m.idxO[oUUID], lo) {
 		if ckr.CheckAndUpdate(t.Predicate()) {
-			prds <- t.Predicate()
+			select {
+			case prds <- t.Predicate():
+			case <-done(ctx):
+				return ctx.Err()
+			}
 		}
 	}
 	return nil
@@ -368,14 +1125,18 @@ func (m *memory) TriplesForSubject(ctx context.Context, s *node.Node, lo *storag
 		return fmt.Errorf("cannot provide an empty channel")
 	}
 	sUUID := UUIDToByteString(s.UUID())
-	m.rwmu.RLock()
+	m.rLockIndexes()
 	defer m.rwmu.RUnlock()
 	defer close(trpls)
 
 	ckr := newChecker(lo)
-	for _, t := range m.idxS[sUUID] {
+	for _, t := range candidates(m.idxS[sUUID], lo) {
 		if ckr.CheckAndUpdate(t.Predicate()) {
-			trpls <- t
+			select {
+			case trpls <- t:
+			case <-done(ctx):
+				return ctx.Err()
+			}
 		}
 	}
 	return nil
@@ -388,14 +1149,18 @@ func (m *memory) TriplesForPredicate(ctx context.Context, p *predicate.Predicate
 		return fmt.Errorf("cannot provide an empty channel")
 	}
 	pUUID := UUIDToByteString(p.UUID())
-	m.rwmu.RLock()
+	m.rLockIndexes()
 	defer m.rwmu.RUnlock()
 	defer close(trpls)
 
 	ckr := newChecker(lo)
-	for _, t := range m.idxP[pUUID] {
+	for _, t := range candidates(m.idxP[pUUID], lo) {
 		if ckr.CheckAndUpdate(t.Predicate()) {
-			trpls <- t
+			select {
+			case trpls <- t:
+			case <-done(ctx):
+				return ctx.Err()
+			}
 		}
 	}
 	return nil
@@ -408,14 +1173,18 @@ func (m *memory) TriplesForObject(ctx context.Context, o *triple.Object, lo *sto
 		return fmt.Errorf("cannot provide an empty channel")
 	}
 	oUUID := UUIDToByteString(o.UUID())
-	m.rwmu.RLock()
+	m.rLockIndexes()
 	defer m.rwmu.RUnlock()
 	defer close(trpls)
 
 	ckr := newChecker(lo)
-	for _, t := range m.idxO[oUUID] {
+	for _, t := range candidates(m.idxO[oUUID], lo) {
 		if ckr.CheckAndUpdate(t.Predicate()) {
-			trpls <- t
+			select {
+			case trpls <- t:
+			case <-done(ctx):
+				return ctx.Err()
+			}
 		}
 	}
 	return nil
@@ -430,14 +1199,18 @@ func (m *memory) TriplesForSubjectAndPredicate(ctx context.Context, s *node.Node
 	sUUID := UUIDToByteString(s.UUID())
 	pUUID := UUIDToByteString(p.UUID())
 	spIdx := sUUID + pUUID
-	m.rwmu.RLock()
+	m.rLockIndexes()
 	defer m.rwmu.RUnlock()
 	defer close(trpls)
 
 	ckr := newChecker(lo)
-	for _, t := range m.idxSP[spIdx] {
+	for _, t := range candidates(m.idxSP[spIdx], lo) {
 		if ckr.CheckAndUpdate(t.Predicate()) {
-			trpls <- t
+			select {
+			case trpls <- t:
+			case <-done(ctx):
+				return ctx.Err()
+			}
 		}
 	}
 	return nil
@@ -452,14 +1225,18 @@ func (m *memory) TriplesForPredicateAndObject(ctx context.Context, p *predicate.
 	pUUID := UUIDToByteString(p.UUID())
 	oUUID := UUIDToByteString(o.UUID())
 	poIdx := pUUID + oUUID
-	m.rwmu.RLock()
+	m.rLockIndexes()
 	defer m.rwmu.RUnlock()
 	defer close(trpls)
 
 	ckr := newChecker(lo)
-	for _, t := range m.idxPO[poIdx] {
+	for _, t := range candidates(m.idxPO[poIdx], lo) {
 		if ckr.CheckAndUpdate(t.Predicate()) {
-			trpls <- t
+			select {
+			case trpls <- t:
+			case <-done(ctx):
+				return ctx.Err()
+			}
 		}
 	}
 	return nil
@@ -474,6 +1251,17 @@ func (m *memory) Exist(ctx context.Context, t *triple.Triple) (bool, error) {
 	return ok, nil
 }
 
+// ExistTriples checks if each of the provided triples exists on the store.
+func (m *memory) ExistTriples(ctx context.Context, ts []*triple.Triple) ([]bool, error) {
+	res := make([]bool, len(ts))
+	m.rwmu.RLock()
+	defer m.rwmu.RUnlock()
+	for i, t := range ts {
+		_, res[i] = m.idx[UUIDToByteString(t.UUID())]
+	}
+	return res, nil
+}
+
 // Triples allows to iterate over all available triples by pushing them to the
 // provided channel.
 func (m *memory) Triples(ctx context.Context, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
@@ -485,10 +1273,296 @@ func (m *memory) Triples(ctx context.Context, lo *storage.LookupOptions, trpls c
 	defer close(trpls)
 
 	ckr := newChecker(lo)
-	for _, t := range m.idx {
+	for _, t := range candidates(m.idx, lo) {
 		if ckr.CheckAndUpdate(t.Predicate()) {
-			trpls <- t
+			select {
+			case trpls <- t:
+			case <-done(ctx):
+				return ctx.Err()
+			}
 		}
 	}
 	return nil
 }
+
+// PredicateCounts returns the number of triples for each predicate in the
+// graph using the sizes of the predicate index entries.
+func (m *memory) PredicateCounts(ctx context.Context) ([]*storage.PredicateCount, error) {
+	m.rLockIndexes()
+	defer m.rwmu.RUnlock()
+	var pcs []*storage.PredicateCount
+	for _, ts := range m.idxP {
+		for _, t := range ts {
+			pcs = append(pcs, &storage.PredicateCount{
+				P:     t.Predicate(),
+				Count: len(ts),
+			})
+			break
+		}
+	}
+	return pcs, nil
+}
+
+// Cardinality returns the number of triples matching the provided lookup
+// using the sizes of the index entries. Lookups by predicate ID without a
+// predicate count the matching triples of the subject or object index entries,
+// or the sizes of the predicate index entries with that ID.
+func (m *memory) Cardinality(ctx context.Context, lookup *storage.CardinalityLookup) (int, error) {
+	m.rLockIndexes()
+	defer m.rwmu.RUnlock()
+	var sUUID, pUUID, oUUID string
+	if lookup.S != nil {
+		sUUID = UUIDToByteString(lookup.S.UUID())
+	}
+	if lookup.P != nil {
+		pUUID = UUIDToByteString(lookup.P.UUID())
+	}
+	if lookup.O != nil {
+		oUUID = UUIDToByteString(lookup.O.UUID())
+	}
+	switch {
+	case lookup.P == nil && lookup.PID != "":
+		ts := m.idx
+		if lookup.S != nil {
+			ts = m.idxS[sUUID]
+		}
+		if lookup.O != nil {
+			ts = m.idxO[oUUID]
+			if lookup.S != nil {
+				ts = m.idxSO[sUUID+oUUID]
+			}
+		}
+		if lookup.S == nil && lookup.O == nil {
+			cnt := 0
+			for _, pts := range m.idxP {
+				for _, t := range pts {
+					if string(t.Predicate().ID()) == lookup.PID {
+						cnt += len(pts)
+					}
+					break
+				}
+			}
+			return cnt, nil
+		}
+		cnt := 0
+		for _, t := range ts {
+			if string(t.Predicate().ID()) == lookup.PID {
+				cnt++
+			}
+		}
+		return cnt, nil
+	case lookup.S != nil && lookup.P != nil && lookup.O != nil:
+		for _, t := range m.idxSP[sUUID+pUUID] {
+			if UUIDToByteString(t.Object().UUID()) == oUUID {
+				return 1, nil
+			}
+		}
+		return 0, nil
+	case lookup.S != nil && lookup.P != nil:
+		return len(m.idxSP[sUUID+pUUID]), nil
+	case lookup.P != nil && lookup.O != nil:
+		return len(m.idxPO[pUUID+oUUID]), nil
+	case lookup.S != nil && lookup.O != nil:
+		return len(m.idxSO[sUUID+oUUID]), nil
+	case lookup.S != nil:
+		return len(m.idxS[sUUID]), nil
+	case lookup.P != nil:
+		return len(m.idxP[pUUID]), nil
+	case lookup.O != nil:
+		return len(m.idxO[oUUID]), nil
+	default:
+		return len(m.idx), nil
+	}
+}
+
+// Count returns the number of triples matching the provided lookup within the
+// lookup options anchors. LatestOnly lookups count the latest versions of the
+// matching triples. Other unbounded lookups use the sizes of the index entries
+// as Cardinality does. Lookups with a predicate match all or none of its
+// triples. Otherwise, the triples of the subject or object index entries are
+// checked against the anchors, or the whole entries of the predicate index if
+// neither is provided.
+func (m *memory) Count(ctx context.Context, lookup *storage.CardinalityLookup, lo *storage.LookupOptions) (int, error) {
+	var (
+		cnt int
+		err error
+	)
+	switch {
+	case lo.LatestOnly:
+		cnt = m.countLatest(lookup, lo)
+	case lo.LowerAnchor == nil && lo.UpperAnchor == nil:
+		cnt, err = m.Cardinality(ctx, lookup)
+	case lookup.P != nil:
+		if lo.InTimeRange(lookup.P) {
+			cnt, err = m.Cardinality(ctx, lookup)
+		}
+	default:
+		cnt = m.countInTimeRange(lookup, lo)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if lo.MaxElements > 0 && cnt > lo.MaxElements {
+		cnt = lo.MaxElements
+	}
+	return cnt, nil
+}
+
+// countInTimeRange counts the triples matching the provided lookup without a
+// predicate within the lookup options anchors.
+func (m *memory) countInTimeRange(lookup *storage.CardinalityLookup, lo *storage.LookupOptions) int {
+	m.rLockIndexes()
+	defer m.rwmu.RUnlock()
+	var ts map[string]*triple.Triple
+	switch {
+	case lookup.S != nil && lookup.O != nil:
+		ts = m.idxSO[UUIDToByteString(lookup.S.UUID())+UUIDToByteString(lookup.O.UUID())]
+	case lookup.S != nil:
+		ts = m.idxS[UUIDToByteString(lookup.S.UUID())]
+	case lookup.O != nil:
+		ts = m.idxO[UUIDToByteString(lookup.O.UUID())]
+	default:
+		// All the triples of a predicate index entry share the same predicate.
+		cnt := 0
+		for _, pts := range m.idxP {
+			for _, t := range pts {
+				if lookup.Matches(t) && lo.InTimeRange(t.Predicate()) {
+					cnt += len(pts)
+				}
+				break
+			}
+		}
+		return cnt
+	}
+	cnt := 0
+	for _, t := range ts {
+		if lookup.Matches(t) && lo.InTimeRange(t.Predicate()) {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// countLatest counts the latest versions of the triples matching the provided
+// lookup within the lookup options anchors.
+func (m *memory) countLatest(lookup *storage.CardinalityLookup, lo *storage.LookupOptions) int {
+	m.rLockIndexes()
+	defer m.rwmu.RUnlock()
+	cnt := 0
+	for _, t := range candidates(m.entry(lookup), lo) {
+		if lookup.Matches(t) {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// LatestTriples returns, for each subject, the n triples of the predicate ID
+// with the newest time anchors within the lookup options anchors. The time
+// index of each subject is walked backward, stopping after n triples; only
+// the subjects are sorted.
+func (m *memory) LatestTriples(ctx context.Context, id string, n int, lo *storage.LookupOptions) ([]*triple.Triple, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("memory.LatestTriples requires a positive number of triples per subject; got %d", n)
+	}
+	m.rLockIndexes()
+	defer m.rwmu.RUnlock()
+	var ents []timeIndexEntry
+	for _, ts := range m.idxT[id] {
+		ents = append(ents, timeIndexEntry{s: ts[0].Subject().String(), ts: ts})
+	}
+	sort.Sort(timeIndexEntries(ents))
+	var res []*triple.Triple
+	for _, e := range ents {
+		// Skip the triples newer than the upper anchor.
+		last := len(e.ts)
+		if lo.UpperAnchor != nil {
+			last = sort.Search(len(e.ts), func(i int) bool {
+				ta, _ := e.ts[i].Predicate().TimeAnchor()
+				return ta.After(*lo.UpperAnchor)
+			})
+		}
+		for i := last - 1; i >= 0 && i >= last-n; i-- {
+			ta, _ := e.ts[i].Predicate().TimeAnchor()
+			if lo.LowerAnchor != nil && ta.Before(*lo.LowerAnchor) {
+				break
+			}
+			res = append(res, e.ts[i])
+		}
+	}
+	return res, nil
+}
+
+// timeIndexEntry contains the time index entry of a subject.
+type timeIndexEntry struct {
+	s  string
+	ts []*triple.Triple
+}
+
+// timeIndexEntries sorts time index entries by subject.
+type timeIndexEntries []timeIndexEntry
+
+func (es timeIndexEntries) Len() int           { return len(es) }
+func (es timeIndexEntries) Less(i, j int) bool { return es[i].s < es[j].s }
+func (es timeIndexEntries) Swap(i, j int)      { es[i], es[j] = es[j], es[i] }
+
+// TriplesPage returns up to max triples of the graph after the position
+// encoded in the page token. Triples are returned sorted by their UUID and the
+// token encodes the UUID of the last triple returned, hence tokens remain
+// valid across mutations. Each page requires a pass over the whole graph.
+func (m *memory) TriplesPage(ctx context.Context, lo *storage.LookupOptions, pageToken string, max int) ([]*triple.Triple, string, error) {
+	if max <= 0 {
+		return nil, "", fmt.Errorf("memory.TriplesPage requires a positive page size; got %d", max)
+	}
+	after := ""
+	if pageToken != "" {
+		b, err := hex.DecodeString(pageToken)
+		if err != nil || len(b) != 16 {
+			return nil, "", fmt.Errorf("memory.TriplesPage got invalid page token %q", pageToken)
+		}
+		after = string(b)
+	}
+	// The page size supersedes the maximum number of elements to return.
+	plo := *lo
+	plo.MaxElements = 0
+	ckr := newChecker(&plo)
+
+	m.rwmu.RLock()
+	defer m.rwmu.RUnlock()
+	keys, cs := m.sortedKeys(), candidates(m.idx, &plo)
+	i := sort.SearchStrings(keys, after)
+	if i < len(keys) && keys[i] == after {
+		i++
+	}
+	var ts []*triple.Triple
+	last, next := "", ""
+	for ; i < len(keys); i++ {
+		t, ok := cs[keys[i]]
+		if !ok || !ckr.CheckAndUpdate(t.Predicate()) {
+			continue
+		}
+		if len(ts) == max {
+			next = hex.EncodeToString([]byte(last))
+			break
+		}
+		ts, last = append(ts, t), keys[i]
+	}
+	return ts, next, nil
+}
+
+// sortedKeys returns the keys of the master index in ascending order. The
+// keys are only sorted again once the graph may have been mutated. It assumes
+// the read lock is already held.
+func (m *memory) sortedKeys() []string {
+	m.sortedMu.Lock()
+	defer m.sortedMu.Unlock()
+	if gen := atomic.LoadUint64(m.gen); m.sorted == nil || m.sortedGen != gen {
+		keys := make([]string, 0, len(m.idx))
+		for k := range m.idx {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		m.sorted, m.sortedGen = keys, gen
+	}
+	return m.sorted
+}
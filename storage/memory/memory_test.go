@@ -15,6 +15,11 @@
 package memory
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -197,6 +202,61 @@ func TestAddRemoveTriples(t *testing.T) {
 	}
 }
 
+func TestAddTriplesWithOptionsKeepsLatest(t *testing.T) {
+	ctx := context.Background()
+	g, _ := NewStore().NewGraph(ctx, "test")
+	if err := g.AddTriples(ctx, createTriples(t, []string{
+		"/u<john>\t\"status\"@[2016-01-01T00:00:00Z]\t/s<online>",
+		"/u<john>\t\"status\"@[2016-02-01T00:00:00Z]\t/s<online>",
+		"/u<john>\t\"status\"@[2016-01-01T00:00:00Z]\t/s<offline>",
+		"/u<john>\t\"knows\"@[]\t/u<mary>",
+	})); err != nil {
+		t.Fatalf("g.AddTriples(_) failed to add test triples with error %v", err)
+	}
+	opts := &storage.InsertOptions{
+		KeepLatest: map[predicate.ID]bool{"status": true, "knows": true},
+	}
+	oi, ok := g.(storage.OptionsInserter)
+	if !ok {
+		t.Fatalf("memory graph should implement storage.OptionsInserter")
+	}
+	n, err := oi.AddTriplesWithOptions(ctx, createTriples(t, []string{
+		"/u<john>\t\"status\"@[2016-03-01T00:00:00Z]\t/s<online>",
+		"/u<john>\t\"status\"@[2015-01-01T00:00:00Z]\t/s<offline>",
+		"/u<john>\t\"knows\"@[]\t/u<mary>",
+		"/u<john>\t\"knows\"@[]\t/u<peter>",
+	}), opts)
+	if err != nil {
+		t.Fatalf("g.AddTriplesWithOptions(_) failed with error %v", err)
+	}
+	if got, want := n, 2; got != want {
+		t.Errorf("g.AddTriplesWithOptions(_) returned the wrong number of superseded triples; got %d, want %d", got, want)
+	}
+	trpls := make(chan *triple.Triple)
+	go func() {
+		if err := g.Triples(ctx, storage.DefaultLookup, trpls); err != nil {
+			t.Errorf("g.Triples(_) failed with error %v", err)
+		}
+	}()
+	got := make(map[string]bool)
+	for trpl := range trpls {
+		got[trpl.String()] = true
+	}
+	for _, want := range []string{
+		"/u<john>\t\"status\"@[2016-03-01T00:00:00Z]\t/s<online>",
+		"/u<john>\t\"status\"@[2016-01-01T00:00:00Z]\t/s<offline>",
+		"/u<john>\t\"knows\"@[]\t/u<mary>",
+		"/u<john>\t\"knows\"@[]\t/u<peter>",
+	} {
+		if !got[want] {
+			t.Errorf("g.AddTriplesWithOptions(_) failed to retain triple %q; got %v", want, got)
+		}
+	}
+	if len(got) != 4 {
+		t.Errorf("g.AddTriplesWithOptions(_) retained the wrong triples; got %v", got)
+	}
+}
+
 func TestObjects(t *testing.T) {
 	ts, ctx := getTestTriples(t), context.Background()
 	g, _ := NewStore().NewGraph(ctx, "test")
@@ -524,3 +584,1528 @@ func TestTriples(t *testing.T) {
 		t.Errorf("g.TriplesForPredicateAndObject(%s, %s) failed to retrieve 1 predicates, got %d instead", ts[0].Predicate(), ts[0].Object(), cnt)
 	}
 }
+
+func TestGeneration(t *testing.T) {
+	s, ctx := NewStore().(*memoryStore), context.Background()
+	gen := func() uint64 {
+		g, err := s.Generation(ctx)
+		if err != nil {
+			t.Fatalf("memoryStore.Generation failed with error %v", err)
+		}
+		return g
+	}
+	g0 := gen()
+	if got := gen(); got != g0 {
+		t.Errorf("memoryStore.Generation should not change without mutations; got %d, want %d", got, g0)
+	}
+	g, err := s.NewGraph(ctx, "?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g1 := gen()
+	if g1 == g0 {
+		t.Errorf("memoryStore.Generation should change after creating a graph")
+	}
+	if err := g.AddTriples(ctx, getTestTriples(t)); err != nil {
+		t.Fatal(err)
+	}
+	g2 := gen()
+	if g2 == g1 {
+		t.Errorf("memoryStore.Generation should change after adding triples")
+	}
+	if err := g.RemoveTriples(ctx, getTestTriples(t)); err != nil {
+		t.Fatal(err)
+	}
+	g3 := gen()
+	if g3 == g2 {
+		t.Errorf("memoryStore.Generation should change after removing triples")
+	}
+	if err := s.DeleteGraph(ctx, "?test"); err != nil {
+		t.Fatal(err)
+	}
+	if got := gen(); got == g3 {
+		t.Errorf("memoryStore.Generation should change after deleting a graph")
+	}
+}
+
+func TestOrphanNodes(t *testing.T) {
+	ctx := context.Background()
+	rooms := []string{
+		"/room<Hallway>\t\"connects_to\"@[]\t/room<Kitchen>",
+		"/room<Kitchen>\t\"connects_to\"@[]\t/room<Hallway>",
+		"/room<Kitchen>\t\"connects_to\"@[]\t/room<Bedroom>",
+		"/room<Bedroom>\t\"connects_to\"@[]\t/room<Kitchen>",
+		"/room<Bedroom>\t\"connects_to\"@[]\t/room<Fire Escape>",
+		"/room<Fire Escape>\t\"connects_to\"@[]\t/room<Kitchen>",
+		"/room<Kitchen>\t\"size\"@[]\t\"12\"^^type:int64",
+	}
+	g, _ := NewStore().NewGraph(ctx, "test")
+	if err := g.AddTriples(ctx, createTriples(t, rooms)); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []storage.OrphanMode{storage.OrphanSubjects, storage.OrphanObjects} {
+		ns, err := storage.OrphanNodes(ctx, g, m)
+		if err != nil {
+			t.Fatalf("storage.OrphanNodes(_, _, %s) failed with error %v", m, err)
+		}
+		if len(ns) != 0 {
+			t.Errorf("storage.OrphanNodes(_, _, %s) should not find orphans in a connected graph; got %v", m, ns)
+		}
+	}
+	if err := g.AddTriples(ctx, createTriples(t, []string{
+		"/room<Attic>\t\"connects_to\"@[]\t/room<Hallway>",
+		"/room<Hallway>\t\"connects_to\"@[]\t/room<Closet>",
+	})); err != nil {
+		t.Fatal(err)
+	}
+	for m, want := range map[storage.OrphanMode]string{
+		storage.OrphanSubjects: "/room<Attic>",
+		storage.OrphanObjects:  "/room<Closet>",
+	} {
+		ns, err := storage.OrphanNodes(ctx, g, m)
+		if err != nil {
+			t.Fatalf("storage.OrphanNodes(_, _, %s) failed with error %v", m, err)
+		}
+		if len(ns) != 1 || ns[0].String() != want {
+			t.Errorf("storage.OrphanNodes(_, _, %s) returned %v; want [%s]", m, ns, want)
+		}
+	}
+	if _, err := storage.OrphanNodes(ctx, g, storage.OrphanMode(-1)); err == nil {
+		t.Errorf("storage.OrphanNodes should fail for unknown modes")
+	}
+}
+
+func TestDeferredIndexes(t *testing.T) {
+	ts, ctx := getTestTriples(t), context.Background()
+	g, _ := NewStore().NewGraph(ctx, "test")
+	bl := g.(storage.BulkLoader)
+	if err := bl.AddTriplesDeferringIndexes(ctx, ts); err != nil {
+		t.Fatalf("g.AddTriplesDeferringIndexes(_) failed to add test triples with error %v", err)
+	}
+	// Lookups on stale indexes trigger the rebuild.
+	trpls := make(chan *triple.Triple)
+	go func() {
+		if err := g.TriplesForSubject(ctx, ts[0].Subject(), storage.DefaultLookup, trpls); err != nil {
+			t.Errorf("g.TriplesForSubject(_) failed with error %v", err)
+		}
+	}()
+	cnt := 0
+	for range trpls {
+		cnt++
+	}
+	if cnt != 3 {
+		t.Errorf("g.TriplesForSubject(_) returned %d triples on stale indexes; want 3", cnt)
+	}
+	if err := bl.AddTriplesDeferringIndexes(ctx, ts[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := bl.RebuildIndexes(ctx); err != nil {
+		t.Fatalf("g.RebuildIndexes(_) failed with error %v", err)
+	}
+	m := g.(*memory)
+	if m.stale || len(m.idxS) != 2 || len(m.idxSP) != 2 || len(m.idxO) != 5 {
+		t.Errorf("g.RebuildIndexes(_) built the wrong indices; got %d subjects, %d subject predicates, %d objects", len(m.idxS), len(m.idxSP), len(m.idxO))
+	}
+}
+
+func TestPredicateCounts(t *testing.T) {
+	ts, ctx := getTestTriples(t), context.Background()
+	g, _ := NewStore().NewGraph(ctx, "test")
+	if err := g.AddTriples(ctx, ts); err != nil {
+		t.Fatalf("g.AddTriples(_) failed to add test triples with error %v", err)
+	}
+	pcs, err := g.(storage.PredicateCounter).PredicateCounts(ctx)
+	if err != nil {
+		t.Fatalf("g.PredicateCounts(_) failed with error %v", err)
+	}
+	got := make(map[string]int)
+	for _, pc := range pcs {
+		got[pc.P.String()] = pc.Count
+	}
+	want := make(map[string]int)
+	for _, t := range ts {
+		want[t.Predicate().String()]++
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("g.PredicateCounts(_) returned %v; want %v", got, want)
+	}
+}
+
+func TestCardinality(t *testing.T) {
+	ts := append(getTestTriples(t), createTriples(t, []string{
+		"/u<john>\t\"met\"@[2016-01-01T00:00:00Z]\t/u<mary>",
+		"/u<john>\t\"met\"@[2016-02-01T00:00:00Z]\t/u<mary>",
+		"/u<mary>\t\"met\"@[2016-02-01T00:00:00Z]\t/u<kim>",
+		"/u<kim>\t\"knows\"@[]\t/u<mary>",
+	})...)
+	ctx := context.Background()
+	g, _ := NewStore().NewGraph(ctx, "test")
+	if err := g.AddTriples(ctx, ts); err != nil {
+		t.Fatalf("g.AddTriples(_) failed to add test triples with error %v", err)
+	}
+	ce := g.(storage.CardinalityEstimator)
+	matches := func(l *storage.CardinalityLookup, trpl *triple.Triple) bool {
+		return (l.S == nil || l.S.String() == trpl.Subject().String()) &&
+			(l.P == nil || l.P.String() == trpl.Predicate().String()) &&
+			(l.PID == "" || l.PID == string(trpl.Predicate().ID())) &&
+			(l.O == nil || l.O.String() == trpl.Object().String())
+	}
+	for _, trpl := range ts {
+		// Try all the combinations of subject, predicate or predicate ID, and
+		// object of the triple.
+		for mask := 0; mask < 12; mask++ {
+			l := &storage.CardinalityLookup{}
+			if mask&1 != 0 {
+				l.S = trpl.Subject()
+			}
+			if mask&2 != 0 {
+				l.O = trpl.Object()
+			}
+			switch mask >> 2 {
+			case 1:
+				l.P = trpl.Predicate()
+			case 2:
+				l.PID = string(trpl.Predicate().ID())
+			}
+			want := 0
+			for _, ot := range ts {
+				if matches(l, ot) {
+					want++
+				}
+			}
+			got, err := ce.Cardinality(ctx, l)
+			if err != nil {
+				t.Fatalf("g.Cardinality(_, %v) failed with error %v", l, err)
+			}
+			if got != want {
+				t.Errorf("g.Cardinality(_, %+v) returned %d; want %d", l, got, want)
+			}
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	ts := append(getTestTriples(t), createTriples(t, []string{
+		"/u<john>\t\"met\"@[2016-01-01T00:00:00Z]\t/u<mary>",
+		"/u<john>\t\"met\"@[2016-02-01T00:00:00Z]\t/u<mary>",
+		"/u<mary>\t\"met\"@[2016-02-01T00:00:00Z]\t/u<kim>",
+		"/u<mary>\t\"met\"@[2016-03-01T00:00:00Z]\t/u<john>",
+		"/u<kim>\t\"knows\"@[]\t/u<mary>",
+	})...)
+	ctx := context.Background()
+	g, _ := NewStore().NewGraph(ctx, "test")
+	if err := g.AddTriples(ctx, ts); err != nil {
+		t.Fatalf("g.AddTriples(_) failed to add test triples with error %v", err)
+	}
+	lower := time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC)
+	upper := time.Date(2016, 2, 15, 0, 0, 0, 0, time.UTC)
+	los := []*storage.LookupOptions{
+		storage.DefaultLookup,
+		{LowerAnchor: &lower},
+		{UpperAnchor: &upper},
+		{LowerAnchor: &lower, UpperAnchor: &upper},
+		{LowerAnchor: &lower, MaxElements: 2},
+	}
+	for _, lo := range los {
+		for _, trpl := range ts {
+			// Try all the combinations of subject, predicate or predicate ID,
+			// and object of the triple.
+			for mask := 0; mask < 12; mask++ {
+				l := &storage.CardinalityLookup{}
+				if mask&1 != 0 {
+					l.S = trpl.Subject()
+				}
+				if mask&2 != 0 {
+					l.O = trpl.Object()
+				}
+				switch mask >> 2 {
+				case 1:
+					l.P = trpl.Predicate()
+				case 2:
+					l.PID = string(trpl.Predicate().ID())
+				}
+				want := 0
+				for _, ot := range ts {
+					if l.Matches(ot) && lo.InTimeRange(ot.Predicate()) {
+						want++
+					}
+				}
+				if lo.MaxElements > 0 && want > lo.MaxElements {
+					want = lo.MaxElements
+				}
+				for _, cg := range []storage.Graph{g, scanOnlyGraph{g}} {
+					got, err := storage.CountTriples(ctx, cg, l, lo)
+					if err != nil {
+						t.Fatalf("storage.CountTriples(_, %T, %+v, %v) failed with error %v", cg, l, lo, err)
+					}
+					if got != want {
+						t.Errorf("storage.CountTriples(_, %T, %+v, %v) returned %d; want %d", cg, l, lo, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRemoveMatching(t *testing.T) {
+	ts := append(getTestTriples(t), createTriples(t, []string{
+		"/u<john>\t\"met\"@[2016-01-01T00:00:00Z]\t/u<mary>",
+		"/u<john>\t\"met\"@[2016-02-01T00:00:00Z]\t/u<mary>",
+		"/u<mary>\t\"met\"@[2016-02-01T00:00:00Z]\t/u<kim>",
+		"/u<mary>\t\"met\"@[2016-03-01T00:00:00Z]\t/u<john>",
+		"/u<kim>\t\"knows\"@[]\t/u<mary>",
+	})...)
+	ctx := context.Background()
+	lower := time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC)
+	upper := time.Date(2016, 2, 15, 0, 0, 0, 0, time.UTC)
+	mary, _ := node.Parse("/u<mary>")
+	testTable := []struct {
+		l  *storage.CardinalityLookup
+		lo *storage.LookupOptions
+	}{
+		{l: &storage.CardinalityLookup{PID: "met"}, lo: storage.DefaultLookup},
+		{l: &storage.CardinalityLookup{PID: "met"}, lo: &storage.LookupOptions{UpperAnchor: &upper}},
+		{l: &storage.CardinalityLookup{PID: "met"}, lo: &storage.LookupOptions{LowerAnchor: &lower, UpperAnchor: &upper, MaxElements: 1}},
+		{l: &storage.CardinalityLookup{S: mary}, lo: &storage.LookupOptions{LowerAnchor: &lower}},
+		{l: &storage.CardinalityLookup{O: triple.NewNodeObject(mary)}, lo: storage.DefaultLookup},
+		{l: &storage.CardinalityLookup{P: ts[len(ts)-2].Predicate()}, lo: storage.DefaultLookup},
+		{l: &storage.CardinalityLookup{}, lo: &storage.LookupOptions{LowerAnchor: &lower}},
+	}
+	for _, entry := range testTable {
+		want := 0
+		for _, ot := range ts {
+			if entry.l.Matches(ot) && entry.lo.InTimeRange(ot.Predicate()) {
+				want++
+			}
+		}
+		for _, wrap := range []bool{false, true} {
+			var g storage.Graph
+			g, _ = NewStore().NewGraph(ctx, "test")
+			if err := g.AddTriples(ctx, ts); err != nil {
+				t.Fatalf("g.AddTriples(_) failed to add test triples with error %v", err)
+			}
+			if wrap {
+				g = scanOnlyGraph{g}
+			}
+			got, err := storage.RemoveMatching(ctx, g, entry.l, entry.lo)
+			if err != nil {
+				t.Fatalf("storage.RemoveMatching(_, %T, %+v, %v) failed with error %v", g, entry.l, entry.lo, err)
+			}
+			if got != want {
+				t.Errorf("storage.RemoveMatching(_, %T, %+v, %v) returned %d; want %d", g, entry.l, entry.lo, got, want)
+			}
+			for _, ot := range ts {
+				b, err := g.Exist(ctx, ot)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if removed := entry.l.Matches(ot) && entry.lo.InTimeRange(ot.Predicate()); b == removed {
+					t.Errorf("storage.RemoveMatching(_, %T, %+v, %v) left the wrong triples; %s exists %v", g, entry.l, entry.lo, ot, b)
+				}
+			}
+		}
+	}
+}
+
+func TestLatestOnlyLookups(t *testing.T) {
+	ts := createTriples(t, []string{
+		"/l<barcelona>\t\"predicate\"@[]\t\"turned\"@[2016-01-01T00:00:00-08:00]",
+		"/l<barcelona>\t\"predicate\"@[]\t\"turned\"@[2016-02-01T00:00:00-08:00]",
+		"/l<barcelona>\t\"predicate\"@[]\t\"turned\"@[2016-03-01T00:00:00-08:00]",
+		"/l<barcelona>\t\"predicate\"@[]\t\"turned\"@[2016-04-01T00:00:00-08:00]",
+		"/u<peter>\t\"bought\"@[2016-01-01T00:00:00-08:00]\t/c<mini>",
+		"/u<peter>\t\"bought\"@[2016-02-01T00:00:00-08:00]\t/c<mini>",
+		"/u<peter>\t\"bought\"@[2016-03-01T00:00:00-08:00]\t/c<model s>",
+		"/u<peter>\t\"knows\"@[]\t/u<mary>",
+	})
+	ctx := context.Background()
+	g, _ := NewStore().NewGraph(ctx, "test")
+	if err := g.AddTriples(ctx, ts); err != nil {
+		t.Fatalf("g.AddTriples(_) failed to add test triples with error %v", err)
+	}
+	upper := time.Date(2016, 2, 15, 0, 0, 0, 0, time.UTC)
+	testTable := []struct {
+		lo   *storage.LookupOptions
+		want []*triple.Triple
+	}{
+		{lo: storage.DefaultLookup, want: ts},
+		{lo: &storage.LookupOptions{LatestOnly: true}, want: []*triple.Triple{ts[3], ts[5], ts[6], ts[7]}},
+		{lo: &storage.LookupOptions{UpperAnchor: &upper, LatestOnly: true}, want: []*triple.Triple{ts[1], ts[5], ts[7]}},
+	}
+	set := func(ts []*triple.Triple) map[string]bool {
+		res := make(map[string]bool)
+		for _, t := range ts {
+			res[t.String()] = true
+		}
+		return res
+	}
+	for _, entry := range testTable {
+		trpls := make(chan *triple.Triple, 100)
+		if err := g.Triples(ctx, entry.lo, trpls); err != nil {
+			t.Fatalf("g.Triples(%v) failed with error %v", entry.lo, err)
+		}
+		var got []*triple.Triple
+		for tr := range trpls {
+			got = append(got, tr)
+		}
+		if !reflect.DeepEqual(set(got), set(entry.want)) {
+			t.Errorf("g.Triples(%v) returned %v; want %v", entry.lo, got, entry.want)
+		}
+		n, err := storage.CountTriples(ctx, g, &storage.CardinalityLookup{}, entry.lo)
+		if err != nil {
+			t.Fatalf("storage.CountTriples(%v) failed with error %v", entry.lo, err)
+		}
+		if n != len(entry.want) {
+			t.Errorf("storage.CountTriples(%v) returned %d; want %d", entry.lo, n, len(entry.want))
+		}
+	}
+
+	// Only the latest time barcelona turned is returned.
+	barcelona, _ := node.Parse("/l<barcelona>")
+	objs := make(chan *triple.Object, 10)
+	if err := g.Objects(ctx, barcelona, ts[0].Predicate(), &storage.LookupOptions{LatestOnly: true}, objs); err != nil {
+		t.Fatalf("g.Objects(%v) failed with error %v", barcelona, err)
+	}
+	var got []string
+	for o := range objs {
+		got = append(got, o.String())
+	}
+	if want := []string{ts[3].Object().String()}; !reflect.DeepEqual(got, want) {
+		t.Errorf("g.Objects(%v) returned %v; want %v", barcelona, got, want)
+	}
+}
+
+func TestExistTriples(t *testing.T) {
+	ts := createTriples(t, []string{
+		"/u<john>\t\"met\"@[2016-01-01T00:00:00Z]\t/u<mary>",
+		"/u<kim>\t\"knows\"@[]\t/u<mary>",
+	})
+	ctx := context.Background()
+	g, _ := NewStore().NewGraph(ctx, "test")
+	if err := g.AddTriples(ctx, ts); err != nil {
+		t.Fatalf("g.AddTriples(_) failed to add test triples with error %v", err)
+	}
+	// Triples only exist if they also match the time anchor.
+	qs := append(ts, createTriples(t, []string{
+		"/u<john>\t\"met\"@[2016-02-01T00:00:00Z]\t/u<mary>",
+		"/u<kim>\t\"knows\"@[]\t/u<john>",
+		"/u<kim>\t\"knows\"@[]\t/u<mary>",
+	})...)
+	want := []bool{true, true, false, false, true}
+	for _, eg := range []storage.Graph{g, scanOnlyGraph{g}} {
+		got, err := storage.ExistTriples(ctx, eg, qs)
+		if err != nil {
+			t.Fatalf("storage.ExistTriples(_, %T, _) failed with error %v", eg, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("storage.ExistTriples(_, %T, _) returned %v; want %v", eg, got, want)
+		}
+		got, err = storage.ExistTriples(ctx, eg, nil)
+		if err != nil || len(got) != 0 {
+			t.Errorf("storage.ExistTriples(_, %T, nil) returned %v, %v; want no flags", eg, got, err)
+		}
+	}
+}
+
+func TestTriplesPage(t *testing.T) {
+	ts, ctx := getTestTriples(t), context.Background()
+	g, _ := NewStore().NewGraph(ctx, "test")
+	if err := g.AddTriples(ctx, ts[:4]); err != nil {
+		t.Fatalf("g.AddTriples(_) failed to add test triples with error %v", err)
+	}
+	pgr := g.(storage.TriplesPager)
+	seen, tkn, pages := make(map[string]bool), "", 0
+	for {
+		page, next, err := pgr.TriplesPage(ctx, &storage.LookupOptions{}, tkn, 3)
+		if err != nil {
+			t.Fatalf("g.TriplesPage(_) failed with error %v", err)
+		}
+		if len(page) > 3 {
+			t.Errorf("g.TriplesPage(_) returned %d triples; want at most 3", len(page))
+		}
+		for _, trpl := range page {
+			if seen[trpl.String()] {
+				t.Errorf("g.TriplesPage(_) returned %s more than once", trpl)
+			}
+			seen[trpl.String()] = true
+		}
+		if pages++; pages == 1 {
+			// Tokens remain valid while the graph is mutated.
+			if err := g.AddTriples(ctx, ts[4:]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if next == "" {
+			break
+		}
+		tkn = next
+	}
+	if pages < 2 {
+		t.Errorf("g.TriplesPage(_) returned %d pages; want at least 2", pages)
+	}
+	if len(seen) < 4 || len(seen) > len(ts) {
+		t.Errorf("g.TriplesPage(_) returned %d distinct triples; want between 4 and %d", len(seen), len(ts))
+	}
+
+	page, next, err := pgr.TriplesPage(ctx, &storage.LookupOptions{}, "", len(ts))
+	if err != nil || len(page) != len(ts) || next != "" {
+		t.Errorf("g.TriplesPage(_) returned %d triples and token %q with error %v; want all %d triples in one page", len(page), next, err, len(ts))
+	}
+	// Pages reflect the triples removed since the previous page.
+	if err := g.RemoveTriples(ctx, ts[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if page, _, err := pgr.TriplesPage(ctx, &storage.LookupOptions{}, "", len(ts)); err != nil || len(page) != len(ts)-1 {
+		t.Errorf("g.TriplesPage(_) returned %d triples with error %v after a removal; want %d", len(page), err, len(ts)-1)
+	}
+	for _, tkn := range []string{"not a token", "00ff"} {
+		if _, _, err := pgr.TriplesPage(ctx, &storage.LookupOptions{}, tkn, 1); err == nil {
+			t.Errorf("g.TriplesPage(_) should have rejected page token %q", tkn)
+		}
+	}
+	if _, _, err := pgr.TriplesPage(ctx, &storage.LookupOptions{}, "", 0); err == nil {
+		t.Errorf("g.TriplesPage(_) should have rejected an empty page size")
+	}
+}
+
+func TestTriplesCanceled(t *testing.T) {
+	ts, ctx := getTestTriples(t), context.Background()
+	g, _ := NewStore().NewGraph(ctx, "test")
+	if err := g.AddTriples(ctx, ts); err != nil {
+		t.Fatalf("g.AddTriples(_) failed to add test triples with error %v", err)
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	trpls := make(chan *triple.Triple)
+	if err := g.Triples(cctx, storage.DefaultLookup, trpls); err != context.Canceled {
+		t.Errorf("g.Triples(_) returned error %v for a canceled context; want %v", err, context.Canceled)
+	}
+	if _, ok := <-trpls; ok {
+		t.Errorf("g.Triples(_) should have closed the channel without sending triples")
+	}
+	p, err := predicate.NewImmutable("knows")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trpls = make(chan *triple.Triple)
+	if err := g.TriplesForPredicate(cctx, p, storage.DefaultLookup, trpls); err != context.Canceled {
+		t.Errorf("g.TriplesForPredicate(_) returned error %v for a canceled context; want %v", err, context.Canceled)
+	}
+}
+
+func TestAddTriplesConcurrently(t *testing.T) {
+	ctx := context.Background()
+	g, _ := NewStore().NewGraph(ctx, "test")
+	var ss []string
+	for i := 0; i < 50; i++ {
+		ss = append(ss, fmt.Sprintf("/u<u%d>\t\"knows\"@[]\t/u<u%d>", i, i+1))
+		ss = append(ss, fmt.Sprintf("/u<u%d>\t\"met\"@[2016-01-%02dT00:00:00Z]\t/u<u%d>", i, i%28+1, i+1))
+	}
+	ts := createTriples(t, ss)
+	// Each goroutine adds an overlapping window of the triples, one triple at a
+	// time and then all of them at once.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := ts[i*len(ts)/20:]
+			if len(w) > 20 {
+				w = w[:20]
+			}
+			for _, trpl := range w {
+				if err := g.AddTriples(ctx, []*triple.Triple{trpl}); err != nil {
+					t.Error(err)
+				}
+			}
+			if err := g.AddTriples(ctx, w); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	trpls := make(chan *triple.Triple)
+	go func() {
+		if err := g.Triples(ctx, storage.DefaultLookup, trpls); err != nil {
+			t.Error(err)
+		}
+	}()
+	cnt := 0
+	for range trpls {
+		cnt++
+	}
+	if got, want := cnt, len(ts); got != want {
+		t.Errorf("g.Triples returned %d triples after adding them concurrently; want %d", got, want)
+	}
+	n, err := g.(storage.TripleCounter).Count(ctx, &storage.CardinalityLookup{PID: "met"}, &storage.LookupOptions{})
+	if err != nil || n != len(ts)/2 {
+		t.Errorf("g.Count returned %d, %v for the temporal triples; want %d, nil", n, err, len(ts)/2)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	g, _ := NewStore().NewGraph(ctx, "test")
+	ts := createTriples(t, []string{
+		"/job<1>\t\"status\"@[]\t\"pending\"^^type:text",
+		"/job<1>\t\"status\"@[]\t\"running\"^^type:text",
+		"/job<1>\t\"status\"@[]\t\"done\"^^type:text",
+	})
+	pending, running, done := ts[0], ts[1], ts[2]
+	if err := g.AddTriples(ctx, []*triple.Triple{pending}); err != nil {
+		t.Fatal(err)
+	}
+	// Only one of the concurrent swaps of the same expected triple succeeds.
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		swaps int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := g.CompareAndSwap(ctx, pending, running)
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				mu.Lock()
+				swaps++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if swaps != 1 {
+		t.Errorf("memory.CompareAndSwap succeeded %d times for the same expected triple; want 1", swaps)
+	}
+	for _, entry := range []struct {
+		trpl *triple.Triple
+		want bool
+	}{
+		{pending, false},
+		{running, true},
+	} {
+		if got, err := g.Exist(ctx, entry.trpl); err != nil || got != entry.want {
+			t.Errorf("memory.Exist(%s) returned %v, %v after the swap; want %v", entry.trpl, got, err, entry.want)
+		}
+	}
+	// Missing expected triples do not swap.
+	if ok, err := g.CompareAndSwap(ctx, pending, done); err != nil || ok {
+		t.Errorf("memory.CompareAndSwap(%s, %s) returned %v, %v; want false, nil", pending, done, ok, err)
+	}
+	if got, _ := g.Exist(ctx, done); got {
+		t.Errorf("memory.CompareAndSwap should not have added %s", done)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore().(*memoryStore)
+	g, _ := s.NewGraph(ctx, "?test")
+	ts := getTestTriples(t)
+	if err := g.AddTriples(ctx, ts[:3]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetReadOnly(ctx, "?test", true); err != nil {
+		t.Fatalf("memoryStore.SetReadOnly failed with error %v", err)
+	}
+	if ro, err := s.ReadOnly(ctx, "?test"); err != nil || !ro {
+		t.Errorf("memoryStore.ReadOnly returned %v, %v; want true, nil", ro, err)
+	}
+	m := g.(*memory)
+	for _, entry := range []struct {
+		op string
+		f  func() error
+	}{
+		{"AddTriples", func() error { return g.AddTriples(ctx, ts[3:]) }},
+		{"AddTriplesWithOptions", func() error { _, err := m.AddTriplesWithOptions(ctx, ts[3:], storage.DefaultInsert); return err }},
+		{"AddTriplesDeferringIndexes", func() error { return m.AddTriplesDeferringIndexes(ctx, ts[3:]) }},
+		{"RemoveTriples", func() error { return g.RemoveTriples(ctx, ts[:1]) }},
+		{"CompareAndSwap", func() error { _, err := g.CompareAndSwap(ctx, ts[0], ts[3]); return err }},
+		{"DeleteGraph", func() error { return s.DeleteGraph(ctx, "?test") }},
+	} {
+		if err := entry.f(); err == nil {
+			t.Errorf("memory.%s should have failed on a read-only graph", entry.op)
+		}
+	}
+	for i, trpl := range ts {
+		if got, _ := g.Exist(ctx, trpl); got != (i < 3) {
+			t.Errorf("memory.Exist(%s) returned %v on a read-only graph; want %v", trpl, got, i < 3)
+		}
+	}
+	if _, err := s.Graph(ctx, "?test"); err != nil {
+		t.Errorf("memoryStore.Graph failed after deleting a read-only graph with error %v", err)
+	}
+	if err := s.SetReadOnly(ctx, "?test", false); err != nil {
+		t.Fatalf("memoryStore.SetReadOnly failed with error %v", err)
+	}
+	if err := g.AddTriples(ctx, ts[3:]); err != nil {
+		t.Errorf("memory.AddTriples should not fail once the graph is writable again; got error %v", err)
+	}
+	if err := s.SetReadOnly(ctx, "?missing", true); err == nil {
+		t.Error("memoryStore.SetReadOnly should have failed for a non existing graph")
+	}
+	if _, err := s.ReadOnly(ctx, "?missing"); err == nil {
+		t.Error("memoryStore.ReadOnly should have failed for a non existing graph")
+	}
+}
+
+func TestGraphMetadata(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore().(*memoryStore)
+	if _, err := s.NewGraph(ctx, "?test"); err != nil {
+		t.Fatal(err)
+	}
+	if md, err := s.GraphMetadata(ctx, "?test"); err != nil || len(md) != 0 {
+		t.Errorf("memoryStore.GraphMetadata returned %v, %v; want no metadata", md, err)
+	}
+	for _, kv := range [][]string{{"owner", "joe"}, {"description", "family tree"}, {"owner", "mary"}} {
+		if err := s.SetGraphMetadata(ctx, "?test", kv[0], kv[1]); err != nil {
+			t.Fatalf("memoryStore.SetGraphMetadata failed with error %v", err)
+		}
+	}
+	md, err := s.GraphMetadata(ctx, "?test")
+	if err != nil {
+		t.Fatalf("memoryStore.GraphMetadata failed with error %v", err)
+	}
+	if want := map[string]string{"owner": "mary", "description": "family tree"}; !reflect.DeepEqual(md, want) {
+		t.Errorf("memoryStore.GraphMetadata returned %v; want %v", md, want)
+	}
+	// The returned metadata is a copy.
+	md["owner"] = "peter"
+	if err := s.SetGraphMetadata(ctx, "?test", "description", ""); err != nil {
+		t.Fatalf("memoryStore.SetGraphMetadata failed with error %v", err)
+	}
+	md, err = s.GraphMetadata(ctx, "?test")
+	if err != nil {
+		t.Fatalf("memoryStore.GraphMetadata failed with error %v", err)
+	}
+	if want := map[string]string{"owner": "mary"}; !reflect.DeepEqual(md, want) {
+		t.Errorf("memoryStore.GraphMetadata returned %v after removing a key; want %v", md, want)
+	}
+	// Deleting the graph removes its metadata.
+	if err := s.DeleteGraph(ctx, "?test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.NewGraph(ctx, "?test"); err != nil {
+		t.Fatal(err)
+	}
+	if md, err := s.GraphMetadata(ctx, "?test"); err != nil || len(md) != 0 {
+		t.Errorf("memoryStore.GraphMetadata returned %v, %v for a recreated graph; want no metadata", md, err)
+	}
+	if err := s.SetGraphMetadata(ctx, "?missing", "owner", "joe"); err == nil {
+		t.Error("memoryStore.SetGraphMetadata should have failed for a non existing graph")
+	}
+	if _, err := s.GraphMetadata(ctx, "?missing"); err == nil {
+		t.Error("memoryStore.GraphMetadata should have failed for a non existing graph")
+	}
+}
+
+func TestCloneGraph(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore().(*memoryStore)
+	g, _ := s.NewGraph(ctx, "?test")
+	ts := getTestTriples(t)
+	if err := g.AddTriples(ctx, ts[:4]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CloneGraph(ctx, "?test", "?copy"); err != nil {
+		t.Fatalf("memoryStore.CloneGraph failed with error %v", err)
+	}
+	c, err := s.Graph(ctx, "?copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if toAdd, toRemove, err := storage.Diff(ctx, c, g, storage.DefaultLookup); err != nil || len(toAdd) != 0 || len(toRemove) != 0 {
+		t.Errorf("storage.Diff returned %v, %v, %v for the clone; want no differences", toAdd, toRemove, err)
+	}
+	// Mutations of either graph do not affect the other one.
+	if err := g.RemoveTriples(ctx, ts[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddTriples(ctx, ts[4:]); err != nil {
+		t.Fatal(err)
+	}
+	for i, trpl := range ts {
+		if got, _ := g.Exist(ctx, trpl); got != (i > 0 && i < 4) {
+			t.Errorf("memory.Exist(%s) returned %v on the source graph; want %v", trpl, got, i > 0 && i < 4)
+		}
+		if got, _ := c.Exist(ctx, trpl); !got {
+			t.Errorf("memory.Exist(%s) returned false on the cloned graph; want true", trpl)
+		}
+	}
+	// Cloning into an existing graph fails without changing it.
+	if err := s.CloneGraph(ctx, "?copy", "?test"); err == nil {
+		t.Error("memoryStore.CloneGraph should have failed for an existing destination graph")
+	}
+	if got, _ := g.Exist(ctx, ts[0]); got {
+		t.Errorf("memoryStore.CloneGraph changed the existing destination graph")
+	}
+	if err := s.CloneGraph(ctx, "?missing", "?other"); err == nil {
+		t.Error("memoryStore.CloneGraph should have failed for a non existing source graph")
+	}
+	if _, err := s.Graph(ctx, "?other"); err == nil {
+		t.Error("memoryStore.CloneGraph should have not created a graph out of a non existing source graph")
+	}
+}
+
+func TestCloneGraphWithoutCloner(t *testing.T) {
+	ctx := context.Background()
+	s := &failingStore{Store: NewStore()}
+	g, _ := s.NewGraph(ctx, "?test")
+	ts := getTestTriples(t)
+	if err := g.AddTriples(ctx, ts); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.CloneGraph(ctx, s, "?test", "?copy"); err != nil {
+		t.Fatalf("storage.CloneGraph failed with error %v", err)
+	}
+	c, err := s.Graph(ctx, "?copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if toAdd, toRemove, err := storage.Diff(ctx, c, g, storage.DefaultLookup); err != nil || len(toAdd) != 0 || len(toRemove) != 0 {
+		t.Errorf("storage.Diff returned %v, %v, %v for the clone; want no differences", toAdd, toRemove, err)
+	}
+	if err := storage.CloneGraph(ctx, s, "?test", "?copy"); err == nil {
+		t.Error("storage.CloneGraph should have failed for an existing destination graph")
+	}
+	// Failing to add the triples deletes the new graph.
+	s.fails = 1
+	if err := storage.CloneGraph(ctx, s, "?test", "?failed"); err == nil {
+		t.Error("storage.CloneGraph should have failed to add the triples")
+	}
+	if _, err := s.Store.Graph(ctx, "?failed"); err == nil {
+		t.Error("storage.CloneGraph should have deleted the graph it failed to populate")
+	}
+}
+
+func TestClearGraph(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore().(*memoryStore)
+	g, _ := s.NewGraph(ctx, "?test")
+	ts := getTestTriples(t)
+	if err := g.AddTriples(ctx, ts); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetGraphMetadata(ctx, "?test", "owner", "joe"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.ClearGraph(ctx, g); err != nil {
+		t.Fatalf("storage.ClearGraph failed with error %v", err)
+	}
+	for _, trpl := range ts {
+		if got, _ := g.Exist(ctx, trpl); got {
+			t.Errorf("memory.Exist(%s) returned true after clearing the graph; want false", trpl)
+		}
+	}
+	if n, err := storage.CountTriples(ctx, g, &storage.CardinalityLookup{PID: "knows"}, storage.DefaultLookup); err != nil || n != 0 {
+		t.Errorf("storage.CountTriples returned %d, %v after clearing the graph; want 0, nil", n, err)
+	}
+	if md, err := s.GraphMetadata(ctx, "?test"); err != nil || md["owner"] != "joe" {
+		t.Errorf("memoryStore.GraphMetadata returned %v, %v after clearing the graph; want the metadata kept", md, err)
+	}
+	// Cleared graphs can be populated again.
+	if err := g.AddTriples(ctx, ts[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := g.Exist(ctx, ts[0]); !got {
+		t.Errorf("memory.Exist(%s) returned false after adding it to the cleared graph; want true", ts[0])
+	}
+	// Graphs not implementing storage.GraphClearer remove their triples one
+	// by one.
+	if err := storage.ClearGraph(ctx, struct{ storage.Graph }{g}); err != nil {
+		t.Fatalf("storage.ClearGraph failed with error %v", err)
+	}
+	if got, _ := g.Exist(ctx, ts[0]); got {
+		t.Errorf("memory.Exist(%s) returned true after clearing the graph; want false", ts[0])
+	}
+	// Read-only graphs cannot be cleared.
+	if err := g.AddTriples(ctx, ts[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetReadOnly(ctx, "?test", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.ClearGraph(ctx, g); err == nil {
+		t.Error("storage.ClearGraph should have failed for a read-only graph")
+	}
+	if got, _ := g.Exist(ctx, ts[0]); !got {
+		t.Errorf("storage.ClearGraph removed triples of a read-only graph")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore().(*memoryStore)
+	g, _ := s.NewGraph(ctx, "?test")
+	ts := append(getTestTriples(t), createTriples(t, []string{
+		"/u<john>\t\"bought\"@[2016-01-01T00:00:00-08:00]\t/c<mini>",
+		"/u<john>\t\"bought\"@[2016-02-01T00:00:00-08:00]\t/c<model s>",
+	})...)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			g.AddTriples(ctx, ts)
+			g.RemoveTriples(ctx, ts[i%len(ts):i%len(ts)+1])
+		}(i)
+	}
+	wg.Wait()
+	if err := g.AddTriples(ctx, ts); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Check(ctx); err != nil {
+		t.Fatalf("memoryStore.Check failed after concurrent mutations with error %v", err)
+	}
+	m := g.(*memory)
+	k := UUIDToByteString(ts[0].UUID())
+	pUUID := UUIDToByteString(ts[0].Predicate().UUID())
+
+	// A triple missing from a secondary index.
+	delete(m.idxP[pUUID], k)
+	err := s.Check(ctx)
+	if err == nil || !strings.Contains(err.Error(), ts[0].String()) || !strings.Contains(err.Error(), "missing from the predicate index") {
+		t.Errorf("memoryStore.Check returned %v; want the triple missing from the predicate index", err)
+	}
+	m.idxP[pUUID][k] = ts[0]
+
+	// A triple only present on a secondary index.
+	delete(m.idx, k)
+	err = s.Check(ctx)
+	if err == nil || !strings.Contains(err.Error(), ts[0].String()) || !strings.Contains(err.Error(), "missing from the master index") {
+		t.Errorf("memoryStore.Check returned %v; want the triple missing from the master index", err)
+	}
+	m.idx[k] = ts[0]
+
+	// A triple filed under the entry of another object.
+	oUUID := UUIDToByteString(ts[1].Object().UUID())
+	m.idxO[oUUID][k] = ts[0]
+	err = s.Check(ctx)
+	if err == nil || !strings.Contains(err.Error(), ts[0].String()) || !strings.Contains(err.Error(), "wrong entry of the object index") {
+		t.Errorf("memoryStore.Check returned %v; want the triple filed under the wrong entry of the object index", err)
+	}
+	delete(m.idxO[oUUID], k)
+
+	// Temporal triples out of order on the time index.
+	sUUID := UUIDToByteString(ts[6].Subject().UUID())
+	tts := m.idxT["bought"][sUUID]
+	tts[0], tts[1] = tts[1], tts[0]
+	if err := s.Check(ctx); err == nil || !strings.Contains(err.Error(), "time index") {
+		t.Errorf("memoryStore.Check returned %v; want a time index discrepancy", err)
+	}
+	tts[0], tts[1] = tts[1], tts[0]
+	if err := s.Check(ctx); err != nil {
+		t.Errorf("memoryStore.Check failed after restoring the indices with error %v", err)
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore().(*memoryStore)
+	g, _ := s.NewGraph(ctx, "?test")
+	ts := getTestTriples(t)
+	if err := g.AddTriples(ctx, ts[:3]); err != nil {
+		t.Fatal(err)
+	}
+	count := func() int {
+		n, err := g.(*memory).Count(ctx, &storage.CardinalityLookup{PID: "knows"}, storage.DefaultLookup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// Rollback reverts both the insertions and the removals.
+	txCtx, err := s.Begin(ctx, []string{"?test", "?missing"})
+	if err != nil {
+		t.Fatalf("memoryStore.Begin failed with error %v", err)
+	}
+	if err := g.AddTriples(txCtx, ts[3:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.RemoveTriples(txCtx, ts[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := count(), 5; got != want {
+		t.Errorf("graph should contain %d triples before the rollback; got %d", want, got)
+	}
+	if err := s.Rollback(ctx); err != nil {
+		t.Fatalf("memoryStore.Rollback failed with error %v", err)
+	}
+	if got, want := count(), 3; got != want {
+		t.Errorf("graph should contain %d triples after the rollback; got %d", want, got)
+	}
+	for i, trpl := range ts {
+		if got, _ := g.Exist(ctx, trpl); got != (i < 3) {
+			t.Errorf("memory.Exist(%s) returned %v after the rollback; want %v", trpl, got, i < 3)
+		}
+	}
+
+	// Commit keeps the mutations.
+	txCtx, err = s.Begin(ctx, []string{"?test"})
+	if err != nil {
+		t.Fatalf("memoryStore.Begin failed with error %v", err)
+	}
+	if err := g.AddTriples(txCtx, ts[3:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Commit(ctx); err != nil {
+		t.Fatalf("memoryStore.Commit failed with error %v", err)
+	}
+	if got, want := count(), 6; got != want {
+		t.Errorf("graph should contain %d triples after the commit; got %d", want, got)
+	}
+
+	// Ending a transaction that is not running fails.
+	if err := s.Commit(ctx); err == nil {
+		t.Error("memoryStore.Commit should have failed without a running transaction")
+	}
+	if err := s.Rollback(ctx); err == nil {
+		t.Error("memoryStore.Rollback should have failed without a running transaction")
+	}
+}
+
+func TestTransactionBlocksOtherWriters(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore().(*memoryStore)
+	g, _ := s.NewGraph(ctx, "?test")
+	ts := getTestTriples(t)
+	txCtx, err := s.Begin(ctx, []string{"?test"})
+	if err != nil {
+		t.Fatalf("memoryStore.Begin failed with error %v", err)
+	}
+
+	// Writers outside the transaction wait until it ends, or until their
+	// context is done.
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := g.AddTriples(cctx, ts[:1]); err != context.DeadlineExceeded {
+		t.Errorf("memory.AddTriples outside the transaction returned %v; want %v", err, context.DeadlineExceeded)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- g.AddTriples(ctx, ts[1:2]) }()
+
+	// Begin stops waiting for the running transaction once ctx is done.
+	bctx, bcancel := context.WithCancel(ctx)
+	bcancel()
+	if _, err := s.Begin(bctx, []string{"?test"}); err != context.Canceled {
+		t.Errorf("memoryStore.Begin returned %v while another transaction was running; want %v", err, context.Canceled)
+	}
+
+	if err := g.AddTriples(txCtx, ts[2:3]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Rollback(ctx); err != nil {
+		t.Fatalf("memoryStore.Rollback failed with error %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("memory.AddTriples outside the transaction failed with error %v", err)
+	}
+	for i, trpl := range ts[:3] {
+		if got, _ := g.Exist(ctx, trpl); got != (i == 1) {
+			t.Errorf("memory.Exist(%s) returned %v after the rollback; want %v", trpl, got, i == 1)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	a, _ := s.NewGraph(ctx, "a")
+	b, _ := s.NewGraph(ctx, "b")
+	if err := a.AddTriples(ctx, createTriples(t, []string{
+		"/u<joe>\t\"parent_of\"@[]\t/u<mary>",
+		"/u<joe>\t\"parent_of\"@[]\t/u<peter>",
+		"/u<peter>\t\"bought\"@[2016-01-01T00:00:00Z]\t/c<mini>",
+		"/u<peter>\t\"bought\"@[2016-02-01T00:00:00Z]\t/c<model s>",
+		"/u<mary>\t\"age\"@[]\t\"30\"^^type:int64",
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddTriples(ctx, createTriples(t, []string{
+		"/u<joe>\t\"parent_of\"@[]\t/u<mary>",
+		"/u<joe>\t\"parent_of\"@[]\t/u<eve>",
+		"/u<peter>\t\"bought\"@[2016-01-01T00:00:00Z]\t/c<mini>",
+		"/u<peter>\t\"bought\"@[2016-03-01T00:00:00Z]\t/c<model s>",
+		"/u<mary>\t\"age\"@[]\t\"31\"^^type:int64",
+	})); err != nil {
+		t.Fatal(err)
+	}
+	strs := func(ts []*triple.Triple) []string {
+		var res []string
+		for _, t := range ts {
+			res = append(res, t.String())
+		}
+		return res
+	}
+	toAdd, toRemove, err := storage.Diff(ctx, a, b, storage.DefaultLookup)
+	if err != nil {
+		t.Fatalf("storage.Diff failed with error %v", err)
+	}
+	wantAdd := []string{
+		"/u<joe>\t\"parent_of\"@[]\t/u<eve>",
+		"/u<mary>\t\"age\"@[]\t\"31\"^^type:int64",
+		"/u<peter>\t\"bought\"@[2016-03-01T00:00:00Z]\t/c<model s>",
+	}
+	if got := strs(toAdd); !reflect.DeepEqual(got, wantAdd) {
+		t.Errorf("storage.Diff returned triples to add %q; want %q", got, wantAdd)
+	}
+	wantRemove := []string{
+		"/u<joe>\t\"parent_of\"@[]\t/u<peter>",
+		"/u<mary>\t\"age\"@[]\t\"30\"^^type:int64",
+		"/u<peter>\t\"bought\"@[2016-02-01T00:00:00Z]\t/c<model s>",
+	}
+	if got := strs(toRemove); !reflect.DeepEqual(got, wantRemove) {
+		t.Errorf("storage.Diff returned triples to remove %q; want %q", got, wantRemove)
+	}
+
+	// Lookup options restrict the compared triples.
+	ub := time.Date(2016, 1, 15, 0, 0, 0, 0, time.UTC)
+	lAdd, lRemove, err := storage.Diff(ctx, a, b, &storage.LookupOptions{UpperAnchor: &ub})
+	if err != nil {
+		t.Fatalf("storage.Diff failed with error %v", err)
+	}
+	if got, want := strs(lAdd), wantAdd[:2]; !reflect.DeepEqual(got, want) {
+		t.Errorf("storage.Diff(_, _, _, %v) returned triples to add %q; want %q", ub, got, want)
+	}
+	if got, want := strs(lRemove), wantRemove[:2]; !reflect.DeepEqual(got, want) {
+		t.Errorf("storage.Diff(_, _, _, %v) returned triples to remove %q; want %q", ub, got, want)
+	}
+
+	// Applying the difference makes both graphs equal.
+	if err := a.AddTriples(ctx, toAdd); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.RemoveTriples(ctx, toRemove); err != nil {
+		t.Fatal(err)
+	}
+	toAdd, toRemove, err = storage.Diff(ctx, a, b, storage.DefaultLookup)
+	if err != nil {
+		t.Fatalf("storage.Diff failed with error %v", err)
+	}
+	if len(toAdd) != 0 || len(toRemove) != 0 {
+		t.Errorf("storage.Diff should not find differences between equal graphs; got %v to add and %v to remove", toAdd, toRemove)
+	}
+}
+
+// scanOnlyGraph hides the optional interfaces of the wrapped graph.
+type scanOnlyGraph struct {
+	storage.Graph
+}
+
+// waitForReplica waits until both graphs hold the same triples.
+func waitForReplica(t *testing.T, a, b storage.Graph) {
+	ctx := context.Background()
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		toAdd, toRemove, err := storage.Diff(ctx, a, b, storage.DefaultLookup)
+		if err != nil {
+			t.Fatalf("storage.Diff failed with error %v", err)
+		}
+		if len(toAdd) == 0 && len(toRemove) == 0 {
+			return
+		}
+	}
+	t.Fatalf("the replica of graph %q never caught up", b.ID(ctx))
+}
+
+// failingStore wraps a store making the first writes to its graphs fail.
+type failingStore struct {
+	storage.Store
+	mu    sync.Mutex
+	fails int
+}
+
+func (s *failingStore) NewGraph(ctx context.Context, id string) (storage.Graph, error) {
+	g, err := s.Store.NewGraph(ctx, id)
+	return &failingGraph{Graph: g, s: s}, err
+}
+
+func (s *failingStore) Graph(ctx context.Context, id string) (storage.Graph, error) {
+	g, err := s.Store.Graph(ctx, id)
+	return &failingGraph{Graph: g, s: s}, err
+}
+
+// failingGraph fails to add triples while its store has failures left.
+type failingGraph struct {
+	storage.Graph
+	s *failingStore
+}
+
+func (g *failingGraph) AddTriples(ctx context.Context, ts []*triple.Triple) error {
+	g.s.mu.Lock()
+	defer g.s.mu.Unlock()
+	if g.s.fails > 0 {
+		g.s.fails--
+		return errors.New("failing write")
+	}
+	return g.Graph.AddTriples(ctx, ts)
+}
+
+func TestReplicate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	src, dst := NewStore(), NewStore()
+	sg, _ := src.NewGraph(ctx, "?test")
+	if err := sg.AddTriples(ctx, getTestTriples(t)); err != nil {
+		t.Fatal(err)
+	}
+	o := &storage.ReplicateOptions{PollInterval: time.Millisecond}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- storage.ReplicateWithOptions(ctx, src, dst, "?test", o)
+	}()
+
+	// The initial snapshot creates the graph on the destination.
+	var dg storage.Graph
+	for start := time.Now(); dg == nil && time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		dg, _ = dst.Graph(ctx, "?test")
+	}
+	if dg == nil {
+		t.Fatal("storage.Replicate never created the replica graph")
+	}
+	waitForReplica(t, dg, sg)
+
+	// Later mutations of the source are propagated.
+	ts := createTriples(t, []string{
+		"/u<eve>\t\"knows\"@[]\t/u<mary>",
+		"/u<eve>\t\"knows\"@[]\t/u<john>",
+	})
+	if err := sg.AddTriples(ctx, ts); err != nil {
+		t.Fatal(err)
+	}
+	if err := sg.RemoveTriples(ctx, getTestTriples(t)[:2]); err != nil {
+		t.Fatal(err)
+	}
+	waitForReplica(t, dg, sg)
+
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("storage.Replicate returned error %v; want %v", err, context.Canceled)
+	}
+}
+
+func TestReplicateRetriesWrites(t *testing.T) {
+	src := NewStore()
+	sg, _ := src.NewGraph(context.Background(), "?test")
+	if err := sg.AddTriples(context.Background(), getTestTriples(t)); err != nil {
+		t.Fatal(err)
+	}
+	testTable := []struct {
+		fails   int
+		retries int
+		err     bool
+	}{
+		{fails: 2, retries: 2},
+		{fails: 3, retries: 2, err: true},
+	}
+	for _, entry := range testTable {
+		ctx, cancel := context.WithCancel(context.Background())
+		dst := &failingStore{Store: NewStore(), fails: entry.fails}
+		o := &storage.ReplicateOptions{
+			PollInterval: time.Millisecond,
+			Retries:      entry.retries,
+			RetryDelay:   time.Millisecond,
+		}
+		errc := make(chan error, 1)
+		go func() {
+			errc <- storage.ReplicateWithOptions(ctx, src, dst, "?test", o)
+		}()
+		if entry.err {
+			if err := <-errc; err == nil || err == context.Canceled {
+				t.Errorf("storage.Replicate should have failed after %d retries for %d failures; got %v", entry.retries, entry.fails, err)
+			}
+			cancel()
+			continue
+		}
+		dg, err := dst.Graph(ctx, "?test")
+		for start := time.Now(); err != nil && time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+			dg, err = dst.Graph(ctx, "?test")
+		}
+		if err != nil {
+			t.Fatal("storage.Replicate never created the replica graph")
+		}
+		waitForReplica(t, dg, sg)
+		cancel()
+		if err := <-errc; err != context.Canceled {
+			t.Errorf("storage.Replicate returned error %v; want %v", err, context.Canceled)
+		}
+	}
+}
+
+func TestLatestTriples(t *testing.T) {
+	ctx := context.Background()
+	g, _ := NewStore().NewGraph(ctx, "test")
+	if err := g.AddTriples(ctx, createTriples(t, []string{
+		"/u<peter>\t\"bought\"@[2016-01-01T00:00:00Z]\t/c<mini>",
+		"/u<peter>\t\"bought\"@[2016-02-01T00:00:00Z]\t/c<model s>",
+		"/u<peter>\t\"bought\"@[2016-03-01T00:00:00Z]\t/c<model x>",
+		"/u<peter>\t\"bought\"@[2016-03-01T00:00:00Z]\t/c<model 3>",
+		"/u<joe>\t\"bought\"@[2016-01-01T00:00:00Z]\t/c<mini>",
+		"/u<joe>\t\"bought\"@[]\t/c<bike>",
+		"/u<joe>\t\"sold\"@[2016-05-01T00:00:00Z]\t/c<mini>",
+	})); err != nil {
+		t.Fatal(err)
+	}
+	upper := time.Date(2016, 2, 15, 0, 0, 0, 0, time.UTC)
+	testTable := []struct {
+		n    int
+		lo   *storage.LookupOptions
+		want []string
+	}{
+		{
+			n:  2,
+			lo: storage.DefaultLookup,
+			want: []string{
+				"/u<joe>\t\"bought\"@[2016-01-01T00:00:00Z]\t/c<mini>",
+				"/u<peter>\t\"bought\"@[2016-03-01T00:00:00Z]\t/c<model 3>",
+				"/u<peter>\t\"bought\"@[2016-03-01T00:00:00Z]\t/c<model x>",
+			},
+		},
+		{
+			n:  1,
+			lo: &storage.LookupOptions{UpperAnchor: &upper},
+			want: []string{
+				"/u<joe>\t\"bought\"@[2016-01-01T00:00:00Z]\t/c<mini>",
+				"/u<peter>\t\"bought\"@[2016-02-01T00:00:00Z]\t/c<model s>",
+			},
+		},
+	}
+	for _, entry := range testTable {
+		for _, lg := range []storage.Graph{g, scanOnlyGraph{g}} {
+			ts, err := storage.LatestTriples(ctx, lg, "bought", entry.n, entry.lo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, trpl := range ts {
+				got = append(got, trpl.String())
+			}
+			if !reflect.DeepEqual(got, entry.want) {
+				t.Errorf("storage.LatestTriples(_, %T, %d, %v) returned %v; want %v", lg, entry.n, entry.lo, got, entry.want)
+			}
+		}
+	}
+
+	// Removed triples are dropped from the time index.
+	if err := g.RemoveTriples(ctx, createTriples(t, []string{
+		"/u<peter>\t\"bought\"@[2016-03-01T00:00:00Z]\t/c<model x>",
+		"/u<peter>\t\"bought\"@[2016-03-01T00:00:00Z]\t/c<model 3>",
+	})); err != nil {
+		t.Fatal(err)
+	}
+	ts, err := g.(storage.LatestLister).LatestTriples(ctx, "bought", 1, storage.DefaultLookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(ts), 2; got != want || ts[1].String() != "/u<peter>\t\"bought\"@[2016-02-01T00:00:00Z]\t/c<model s>" {
+		t.Errorf("memory.LatestTriples returned %v after removing the newest triples", ts)
+	}
+	// Triples added twice in the same batch are indexed once.
+	mx := createTriples(t, []string{"/u<peter>\t\"bought\"@[2016-03-01T00:00:00Z]\t/c<model x>"})
+	if _, err := g.(storage.OptionsInserter).AddTriplesWithOptions(ctx, append(mx, mx...), storage.DefaultInsert); err != nil {
+		t.Fatal(err)
+	}
+	ts, err = g.(storage.LatestLister).LatestTriples(ctx, "bought", 2, storage.DefaultLookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(ts), 3; got != want || ts[2].String() != "/u<peter>\t\"bought\"@[2016-02-01T00:00:00Z]\t/c<model s>" {
+		t.Errorf("memory.LatestTriples returned %v after adding the same triple twice", ts)
+	}
+	if _, err := storage.LatestTriples(ctx, g, "bought", 0, storage.DefaultLookup); err == nil {
+		t.Errorf("storage.LatestTriples should fail for non positive number of triples")
+	}
+}
+
+func TestMaterializeClosure(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	g, _ := s.NewGraph(ctx, "family")
+	if err := g.AddTriples(ctx, createTriples(t, []string{
+		"/u<joe>\t\"parent_of\"@[]\t/u<mary>",
+		"/u<joe>\t\"parent_of\"@[]\t/u<peter>",
+		"/u<peter>\t\"parent_of\"@[]\t/u<john>",
+		"/u<peter>\t\"parent_of\"@[]\t/u<eve>",
+		"/u<eve>\t\"parent_of\"@[]\t/u<kim>",
+		"/u<joe>\t\"knows\"@[]\t/u<alice>",
+	})); err != nil {
+		t.Fatal(err)
+	}
+	p, err := predicate.NewImmutable("parent_of")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := s.(ClosureMaterializer).MaterializeClosure(ctx, "family", p)
+	if err != nil {
+		t.Fatalf("memoryStore.MaterializeClosure failed with error %v", err)
+	}
+	n := func(s string) *node.Node {
+		nd, err := node.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nd
+	}
+	reachable := func(from string) []string {
+		ns, err := c.Reachable(ctx, n(from))
+		if err != nil {
+			t.Fatalf("Closure.Reachable(%s) failed with error %v", from, err)
+		}
+		var res []string
+		for _, nd := range ns {
+			res = append(res, nd.String())
+		}
+		return res
+	}
+	if got, want := reachable("/u<joe>"), []string{"/u<eve>", "/u<john>", "/u<kim>", "/u<mary>", "/u<peter>"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Closure.Reachable(/u<joe>) returned %v; want %v", got, want)
+	}
+	if got := reachable("/u<kim>"); len(got) != 0 {
+		t.Errorf("Closure.Reachable(/u<kim>) returned %v; want no nodes", got)
+	}
+	if got, err := c.Len(ctx); err != nil || got != 9 {
+		t.Errorf("Closure.Len returned %d, %v; want 9 ancestor pairs", got, err)
+	}
+	if ok, err := c.Reaches(ctx, n("/u<joe>"), n("/u<alice>")); err != nil || ok {
+		t.Errorf("Closure.Reaches(/u<joe>, /u<alice>) returned %v, %v; should not follow other predicates", ok, err)
+	}
+
+	// Mutations of other predicates keep the closure.
+	gen := c.gen
+	if err := g.AddTriples(ctx, createTriples(t, []string{"/u<kim>\t\"knows\"@[]\t/u<joe>"})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Len(ctx); err != nil || c.gen != gen {
+		t.Errorf("Closure should not be recomputed after mutating other predicates; got error %v", err)
+	}
+
+	// Mutations of the predicate invalidate the closure.
+	if err := g.RemoveTriples(ctx, createTriples(t, []string{"/u<joe>\t\"parent_of\"@[]\t/u<peter>"})); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := reachable("/u<joe>"), []string{"/u<mary>"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Closure.Reachable(/u<joe>) returned %v after removing an edge; want %v", got, want)
+	}
+	if err := g.AddTriples(ctx, createTriples(t, []string{"/u<kim>\t\"parent_of\"@[]\t/u<joe>"})); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := c.Reaches(ctx, n("/u<kim>"), n("/u<mary>")); err != nil || !ok {
+		t.Errorf("Closure.Reaches(/u<kim>, /u<mary>) returned %v, %v after adding an edge; want true", ok, err)
+	}
+	// Cycles terminate.
+	if err := g.AddTriples(ctx, createTriples(t, []string{"/u<mary>\t\"parent_of\"@[]\t/u<kim>"})); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := c.Reaches(ctx, n("/u<kim>"), n("/u<kim>")); err != nil || !ok {
+		t.Errorf("Closure.Reaches(/u<kim>, /u<kim>) returned %v, %v on a cycle; want true", ok, err)
+	}
+
+	if err := s.DeleteGraph(ctx, "family"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Len(ctx); err == nil {
+		t.Errorf("Closure.Len should fail once the graph is deleted")
+	}
+	if _, err := s.(ClosureMaterializer).MaterializeClosure(ctx, "family", p); err == nil {
+		t.Errorf("memoryStore.MaterializeClosure should fail for unknown graphs")
+	}
+}
+
+func TestStoreWithLimit(t *testing.T) {
+	ctx := context.Background()
+	s := NewStoreWithLimit(4)
+	a, err := s.NewGraph(ctx, "?a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.NewGraph(ctx, "?b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := func() map[string]bool {
+		got := make(map[string]bool)
+		for _, g := range []storage.Graph{a, b} {
+			trpls := make(chan *triple.Triple)
+			go func() {
+				if err := g.Triples(ctx, storage.DefaultLookup, trpls); err != nil {
+					t.Errorf("g.Triples(_) failed with error %v", err)
+				}
+			}()
+			for trpl := range trpls {
+				got[g.ID(ctx)+" "+trpl.String()] = true
+			}
+		}
+		return got
+	}
+	want := func(ss ...string) map[string]bool {
+		res := make(map[string]bool)
+		for _, s := range ss {
+			res[s] = true
+		}
+		return res
+	}
+	add := func(g storage.Graph, ss ...string) {
+		if err := g.AddTriples(ctx, createTriples(t, ss)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const (
+		i1 = "/u<joe>\t\"parent_of\"@[]\t/u<mary>"
+		i2 = "/u<joe>\t\"parent_of\"@[]\t/u<peter>"
+		t0 = "/u<joe>\t\"status\"@[2015-01-01T00:00:00Z]\t/s<away>"
+		t1 = "/u<joe>\t\"status\"@[2016-01-01T00:00:00Z]\t/s<online>"
+		t2 = "/u<joe>\t\"status\"@[2016-02-01T00:00:00Z]\t/s<offline>"
+		t3 = "/u<joe>\t\"status\"@[2016-03-01T00:00:00Z]\t/s<online>"
+		t4 = "/u<joe>\t\"status\"@[2016-04-01T00:00:00Z]\t/s<offline>"
+		t5 = "/u<joe>\t\"status\"@[2017-01-01T00:00:00Z]\t/s<online>"
+		t6 = "/u<joe>\t\"status\"@[2018-01-01T00:00:00Z]\t/s<offline>"
+		t7 = "/u<joe>\t\"status\"@[2014-01-01T00:00:00Z]\t/s<online>"
+		t8 = "/u<joe>\t\"status\"@[2019-01-01T00:00:00Z]\t/s<online>"
+	)
+
+	// Immutable triples are evicted first, across graphs.
+	add(a, i1, t3, t1)
+	add(b, t2, i2)
+	if got, w := contents(), want("?a "+t3, "?a "+t1, "?b "+t2, "?b "+i2); !reflect.DeepEqual(got, w) {
+		t.Errorf("NewStoreWithLimit failed to evict the oldest immutable triple; got %v, want %v", got, w)
+	}
+	add(b, t0)
+	if got, w := contents(), want("?a "+t3, "?a "+t1, "?b "+t2, "?b "+t0); !reflect.DeepEqual(got, w) {
+		t.Errorf("NewStoreWithLimit failed to evict the last immutable triple; got %v, want %v", got, w)
+	}
+
+	// Temporal triples are then evicted by time anchor.
+	add(a, t4)
+	if got, w := contents(), want("?a "+t3, "?a "+t1, "?b "+t2, "?a "+t4); !reflect.DeepEqual(got, w) {
+		t.Errorf("NewStoreWithLimit failed to evict the oldest temporal triple; got %v, want %v", got, w)
+	}
+
+	// Stored triples keep their position, and removed ones free room.
+	add(a, t1)
+	if err := a.RemoveTriples(ctx, createTriples(t, []string{t3})); err != nil {
+		t.Fatal(err)
+	}
+	add(a, t5)
+	add(b, t6)
+	if got, w := contents(), want("?b "+t2, "?a "+t4, "?a "+t5, "?b "+t6); !reflect.DeepEqual(got, w) {
+		t.Errorf("NewStoreWithLimit failed to evict after removals; got %v, want %v", got, w)
+	}
+
+	// Inserting a triple older than all the stored ones evicts it right away.
+	add(a, t7)
+	if got, w := contents(), want("?b "+t2, "?a "+t4, "?a "+t5, "?b "+t6); !reflect.DeepEqual(got, w) {
+		t.Errorf("NewStoreWithLimit failed to evict the inserted triple; got %v, want %v", got, w)
+	}
+
+	// Triples of read-only graphs are never evicted.
+	if err := s.(*memoryStore).SetReadOnly(ctx, "?b", true); err != nil {
+		t.Fatal(err)
+	}
+	add(a, t8)
+	if got, w := contents(), want("?b "+t2, "?a "+t5, "?b "+t6, "?a "+t8); !reflect.DeepEqual(got, w) {
+		t.Errorf("NewStoreWithLimit failed to skip the read-only graph; got %v, want %v", got, w)
+	}
+}
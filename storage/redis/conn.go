@@ -0,0 +1,224 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// maxIdle is the maximum number of idle connections kept by a pool.
+const maxIdle = 8
+
+// redisError is an error reply returned by the Redis server.
+type redisError string
+
+// Error returns the message sent by the server.
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// conn is a connection to a Redis server speaking the RESP protocol. Replies
+// are returned as strings for simple and bulk strings, int64 for integers, nil
+// for null replies, []interface{} for arrays, and redisError for errors
+// nested in arrays.
+type conn struct {
+	nc net.Conn
+	r  *bufio.Reader
+	w  *bufio.Writer
+}
+
+// dial opens a new connection to the provided address.
+func dial(addr string) (*conn, error) {
+	nc, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{
+		nc: nc,
+		r:  bufio.NewReader(nc),
+		w:  bufio.NewWriter(nc),
+	}, nil
+}
+
+// close closes the underlying network connection.
+func (c *conn) close() error {
+	return c.nc.Close()
+}
+
+// send buffers the provided command without waiting for its reply.
+func (c *conn) send(args ...string) error {
+	if _, err := fmt.Fprintf(c.w, "*%d\r\n", len(args)); err != nil {
+		return err
+	}
+	for _, a := range args {
+		if _, err := fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(a), a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush writes all the buffered commands to the server.
+func (c *conn) flush() error {
+	return c.w.Flush()
+}
+
+// receive reads the next reply. Top level error replies are returned as
+// errors of type redisError.
+func (c *conn) receive() (interface{}, error) {
+	v, err := c.readReply()
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := v.(redisError); ok {
+		return nil, e
+	}
+	return v, nil
+}
+
+// do sends the provided command and returns its reply.
+func (c *conn) do(args ...string) (interface{}, error) {
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	if err := c.flush(); err != nil {
+		return nil, err
+	}
+	return c.receive()
+}
+
+// readLine reads a line stripping the trailing CRLF.
+func (c *conn) readLine() (string, error) {
+	l, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(l) < 2 || l[len(l)-2] != '\r' {
+		return "", fmt.Errorf("redis: malformed reply line %q", l)
+	}
+	return l[:len(l)-2], nil
+}
+
+// readReply reads and decodes the next reply.
+func (c *conn) readReply() (interface{}, error) {
+	l, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if l == "" {
+		return nil, fmt.Errorf("redis: empty reply line")
+	}
+	switch l[0] {
+	case '+':
+		return l[1:], nil
+	case '-':
+		return redisError(l[1:]), nil
+	case ':':
+		return strconv.ParseInt(l[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(l[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk string length %q", l)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return string(b[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(l[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array length %q", l)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		vs := make([]interface{}, n)
+		for i := range vs {
+			if vs[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return vs, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", l)
+}
+
+// pool keeps idle connections to a Redis server so they can be reused.
+type pool struct {
+	addr string
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+// get returns an idle connection or dials a new one.
+func (p *pool) get() (*conn, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("redis: the store is closed")
+	}
+	if n := len(p.idle); n > 0 {
+		c := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return c, nil
+	}
+	p.mu.Unlock()
+	return dial(p.addr)
+}
+
+// put returns the connection to the pool. Connections that failed with
+// errors other than replies from the server may be left in the middle of a
+// reply, hence they are closed instead.
+func (p *pool) put(c *conn, err error) {
+	if _, ok := err.(redisError); err != nil && !ok {
+		c.close()
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || len(p.idle) >= maxIdle {
+		c.close()
+		return
+	}
+	p.idle = append(p.idle, c)
+}
+
+// close closes all the idle connections. Connections in use are closed once
+// returned.
+func (p *pool) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	var err error
+	for _, c := range p.idle {
+		if cErr := c.close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	p.idle = nil
+	return err
+}
@@ -0,0 +1,518 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReceive(t *testing.T) {
+	table := []struct {
+		raw  string
+		want interface{}
+		err  bool
+	}{
+		{raw: "+OK\r\n", want: "OK"},
+		{raw: "-ERR wrong type\r\n", err: true},
+		{raw: ":42\r\n", want: int64(42)},
+		{raw: "$5\r\nhe\r\no\r\n", want: "he\r\no"},
+		{raw: "$0\r\n\r\n", want: ""},
+		{raw: "$-1\r\n", want: nil},
+		{raw: "*-1\r\n", want: nil},
+		{raw: "*3\r\n$1\r\na\r\n:1\r\n-ERR nested\r\n", want: []interface{}{"a", int64(1), redisError("ERR nested")}},
+		{raw: "*2\r\n*1\r\n+a\r\n$-1\r\n", want: []interface{}{[]interface{}{"a"}, nil}},
+		{raw: "?\r\n", err: true},
+		{raw: "+OK\n", err: true},
+	}
+	for _, entry := range table {
+		c := &conn{r: bufio.NewReader(strings.NewReader(entry.raw))}
+		got, err := c.receive()
+		if entry.err {
+			if err == nil {
+				t.Errorf("conn.receive(%q) should have failed; got %v", entry.raw, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("conn.receive(%q) failed with error %v", entry.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, entry.want) {
+			t.Errorf("conn.receive(%q) returned %#v; want %#v", entry.raw, got, entry.want)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	r, w := io.Pipe()
+	go func() {
+		c := &conn{w: bufio.NewWriter(w)}
+		c.send("SET", "k", "a b\r\n")
+		c.flush()
+		w.Close()
+	}()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$5\r\na b\r\n\r\n"; got != want {
+		t.Errorf("conn.send returned %q; want %q", got, want)
+	}
+}
+
+// fakeServer is a Redis server keeping its data in memory that implements
+// the subset of commands used by the store.
+type fakeServer struct {
+	l net.Listener
+
+	mu       sync.Mutex
+	data     map[string]interface{}
+	versions map[string]int
+}
+
+// newFakeServer starts a new fake server listening on a local port.
+func newFakeServer(t *testing.T) *fakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen failed with error %v", err)
+	}
+	f := &fakeServer{
+		l:        l,
+		data:     make(map[string]interface{}),
+		versions: make(map[string]int),
+	}
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(nc)
+		}
+	}()
+	return f
+}
+
+// addr returns the address the server listens on.
+func (f *fakeServer) addr() string {
+	return f.l.Addr().String()
+}
+
+// close stops accepting connections.
+func (f *fakeServer) close() {
+	f.l.Close()
+}
+
+// keys returns the sorted keys stored on the server.
+func (f *fakeServer) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ks []string
+	for k := range f.data {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+// session holds the transaction state of a connection.
+type session struct {
+	watched map[string]int
+	multi   bool
+	queued  [][]string
+}
+
+// serve runs the commands received on the provided connection.
+func (f *fakeServer) serve(nc net.Conn) {
+	defer nc.Close()
+	c := &conn{nc: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	ss := &session{}
+	for {
+		v, err := c.readReply()
+		if err != nil {
+			return
+		}
+		args, err := strs(v)
+		if err != nil || len(args) == 0 {
+			return
+		}
+		writeReply(c.w, f.run(ss, args))
+		if err := c.flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeReply encodes the provided reply.
+func writeReply(w *bufio.Writer, v interface{}) {
+	switch r := v.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case redisError:
+		fmt.Fprintf(w, "-%s\r\n", r)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", r)
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(r), r)
+	case []interface{}:
+		if r == nil {
+			w.WriteString("*-1\r\n")
+			return
+		}
+		fmt.Fprintf(w, "*%d\r\n", len(r))
+		for _, e := range r {
+			writeReply(w, e)
+		}
+	case []string:
+		fmt.Fprintf(w, "*%d\r\n", len(r))
+		for _, e := range r {
+			writeReply(w, e)
+		}
+	}
+}
+
+// run runs the provided command for the session.
+func (f *fakeServer) run(ss *session, args []string) interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cmd := strings.ToUpper(args[0])
+	switch cmd {
+	case "MULTI":
+		ss.multi, ss.queued = true, nil
+		return "OK"
+	case "EXEC":
+		defer func() {
+			ss.watched, ss.multi, ss.queued = nil, false, nil
+		}()
+		for k, v := range ss.watched {
+			if f.versions[k] != v {
+				return []interface{}(nil)
+			}
+		}
+		rs := []interface{}{}
+		for _, q := range ss.queued {
+			rs = append(rs, f.exec(ss, q))
+		}
+		return rs
+	}
+	if ss.multi {
+		ss.queued = append(ss.queued, args)
+		return "QUEUED"
+	}
+	return f.exec(ss, args)
+}
+
+// touch records the modification of the provided key.
+func (f *fakeServer) touch(k string) {
+	f.versions[k]++
+}
+
+// set returns the set stored on the provided key, creating it if requested.
+func (f *fakeServer) set(k string, create bool) map[string]bool {
+	s, ok := f.data[k].(map[string]bool)
+	if !ok && create {
+		s = make(map[string]bool)
+		f.data[k] = s
+	}
+	return s
+}
+
+// zset returns the sorted set stored on the provided key, creating it if
+// requested.
+func (f *fakeServer) zset(k string, create bool) map[string]float64 {
+	s, ok := f.data[k].(map[string]float64)
+	if !ok && create {
+		s = make(map[string]float64)
+		f.data[k] = s
+	}
+	return s
+}
+
+// hash returns the hash stored on the provided key, creating it if requested.
+func (f *fakeServer) hash(k string, create bool) map[string]string {
+	h, ok := f.data[k].(map[string]string)
+	if !ok && create {
+		h = make(map[string]string)
+		f.data[k] = h
+	}
+	return h
+}
+
+// dropEmpty removes the provided key if it holds an empty collection.
+func (f *fakeServer) dropEmpty(k string) {
+	switch v := f.data[k].(type) {
+	case map[string]bool:
+		if len(v) == 0 {
+			delete(f.data, k)
+		}
+	case map[string]float64:
+		if len(v) == 0 {
+			delete(f.data, k)
+		}
+	case map[string]string:
+		if len(v) == 0 {
+			delete(f.data, k)
+		}
+	}
+}
+
+// parseScore parses a score bound.
+func parseScore(s string) float64 {
+	switch s {
+	case "-inf":
+		return math.Inf(-1)
+	case "+inf":
+		return math.Inf(1)
+	}
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// page returns the elements of the sorted list starting at the provided
+// cursor and the cursor of the next page.
+func page(es []string, cursor string, args []string) (string, []string) {
+	n := 10
+	for i := 0; i+1 < len(args); i++ {
+		if strings.ToUpper(args[i]) == "COUNT" {
+			n, _ = strconv.Atoi(args[i+1])
+		}
+	}
+	start, _ := strconv.Atoi(cursor)
+	if start >= len(es) {
+		return "0", nil
+	}
+	end, next := start+n, strconv.Itoa(start+n)
+	if end >= len(es) {
+		end, next = len(es), "0"
+	}
+	return next, es[start:end]
+}
+
+// byScore sorts the members of a sorted set by score, and by member for
+// equal scores.
+type byScore struct {
+	ms []string
+	z  map[string]float64
+}
+
+func (b byScore) Len() int      { return len(b.ms) }
+func (b byScore) Swap(i, j int) { b.ms[i], b.ms[j] = b.ms[j], b.ms[i] }
+func (b byScore) Less(i, j int) bool {
+	if b.z[b.ms[i]] != b.z[b.ms[j]] {
+		return b.z[b.ms[i]] < b.z[b.ms[j]]
+	}
+	return b.ms[i] < b.ms[j]
+}
+
+// exec runs the provided command.
+func (f *fakeServer) exec(ss *session, args []string) interface{} {
+	switch cmd, k := strings.ToUpper(args[0]), ""; cmd {
+	case "PING":
+		return "PONG"
+	case "WATCH":
+		if ss.watched == nil {
+			ss.watched = make(map[string]int)
+		}
+		for _, k := range args[1:] {
+			ss.watched[k] = f.versions[k]
+		}
+		return "OK"
+	case "UNWATCH":
+		ss.watched = nil
+		return "OK"
+	case "SCAN":
+		var match string
+		for i := 2; i+1 < len(args); i++ {
+			if strings.ToUpper(args[i]) == "MATCH" {
+				match = args[i+1]
+			}
+		}
+		// Only trailing wildcards are supported.
+		prefix := strings.Replace(strings.TrimSuffix(match, "*"), "\\", "", -1)
+		var ks []string
+		for k := range f.data {
+			if strings.HasPrefix(k, prefix) {
+				ks = append(ks, k)
+			}
+		}
+		sort.Strings(ks)
+		next, es := page(ks, args[1], args[2:])
+		return []interface{}{next, es}
+	default:
+		if len(args) < 2 {
+			return redisError("ERR wrong number of arguments")
+		}
+		k = args[1]
+		switch cmd {
+		case "GET":
+			if v, ok := f.data[k].(string); ok {
+				return v
+			}
+			return nil
+		case "SET":
+			if _, ok := f.data[k]; ok && len(args) > 3 && strings.ToUpper(args[3]) == "NX" {
+				return nil
+			}
+			f.data[k] = args[2]
+			f.touch(k)
+			return "OK"
+		case "DEL":
+			n := int64(0)
+			for _, k := range args[1:] {
+				if _, ok := f.data[k]; ok {
+					delete(f.data, k)
+					f.touch(k)
+					n++
+				}
+			}
+			return n
+		case "INCR":
+			v, _ := f.data[k].(string)
+			n, _ := strconv.ParseInt(v, 10, 64)
+			f.data[k] = strconv.FormatInt(n+1, 10)
+			f.touch(k)
+			return n + 1
+		case "SADD", "SREM":
+			s, n := f.set(k, cmd == "SADD"), int64(0)
+			for _, m := range args[2:] {
+				if s[m] != (cmd == "SADD") {
+					n++
+				}
+				if cmd == "SADD" {
+					s[m] = true
+				} else {
+					delete(s, m)
+				}
+			}
+			f.dropEmpty(k)
+			f.touch(k)
+			return n
+		case "SSCAN":
+			var es []string
+			for m := range f.set(k, false) {
+				es = append(es, m)
+			}
+			sort.Strings(es)
+			next, es := page(es, args[2], args[3:])
+			return []interface{}{next, es}
+		case "HSET":
+			h, n := f.hash(k, true), int64(0)
+			for i := 2; i+1 < len(args); i += 2 {
+				if _, ok := h[args[i]]; !ok {
+					n++
+				}
+				h[args[i]] = args[i+1]
+			}
+			f.touch(k)
+			return n
+		case "HDEL":
+			h, n := f.hash(k, false), int64(0)
+			for _, m := range args[2:] {
+				if _, ok := h[m]; ok {
+					delete(h, m)
+					n++
+				}
+			}
+			f.dropEmpty(k)
+			f.touch(k)
+			return n
+		case "HEXISTS":
+			if _, ok := f.hash(k, false)[args[2]]; ok {
+				return int64(1)
+			}
+			return int64(0)
+		case "HMGET":
+			h, rs := f.hash(k, false), []interface{}{}
+			for _, m := range args[2:] {
+				if v, ok := h[m]; ok {
+					rs = append(rs, v)
+				} else {
+					rs = append(rs, nil)
+				}
+			}
+			return rs
+		case "ZADD":
+			z, n := f.zset(k, true), int64(0)
+			for i := 2; i+1 < len(args); i += 2 {
+				if _, ok := z[args[i+1]]; !ok {
+					n++
+				}
+				z[args[i+1]] = parseScore(args[i])
+			}
+			f.touch(k)
+			return n
+		case "ZREM":
+			z, n := f.zset(k, false), int64(0)
+			for _, m := range args[2:] {
+				if _, ok := z[m]; ok {
+					delete(z, m)
+					n++
+				}
+			}
+			f.dropEmpty(k)
+			f.touch(k)
+			return n
+		case "ZSCAN":
+			z := f.zset(k, false)
+			var ms []string
+			for m := range z {
+				ms = append(ms, m)
+			}
+			sort.Strings(ms)
+			next, ms := page(ms, args[2], args[3:])
+			var es []string
+			for _, m := range ms {
+				es = append(es, m, strconv.FormatFloat(z[m], 'g', -1, 64))
+			}
+			return []interface{}{next, es}
+		case "ZCOUNT":
+			z, min, max, n := f.zset(k, false), parseScore(args[2]), parseScore(args[3]), int64(0)
+			for _, s := range z {
+				if s >= min && s <= max {
+					n++
+				}
+			}
+			return n
+		case "ZRANGE":
+			z := f.zset(k, false)
+			var ms []string
+			for m := range z {
+				ms = append(ms, m)
+			}
+			sort.Sort(byScore{ms: ms, z: z})
+			start, _ := strconv.Atoi(args[2])
+			stop, _ := strconv.Atoi(args[3])
+			if stop >= len(ms) {
+				stop = len(ms) - 1
+			}
+			if start > stop {
+				return []string{}
+			}
+			return ms[start : stop+1]
+		}
+	}
+	return redisError("ERR unknown command " + args[0])
+}
@@ -0,0 +1,969 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redis provides an implementation of the storage.Store and
+// storage.Graph interfaces backed by a Redis server, which lets several
+// processes share the same ephemeral graphs.
+//
+// All the keys of a store start with its namespace. Each graph is recorded by
+// a key holding a random token that is set only if missing, hence only one of
+// several processes creating the same graph succeeds. The data of a graph
+// lives under the namespace of its token, so a deleted graph never leaks its
+// triples into a graph later created with the same name. Every mutation runs
+// in a MULTI/EXEC transaction that watches the graph key, hence triples are
+// never added to a graph deleted meanwhile by another process.
+//
+// The triples are stored in a hash keyed by the triple UUID. Besides the sets
+// indexing the triple UUIDs by their components, the indices that do not fix
+// the predicate are sorted sets scored by the time anchor of the predicate.
+// Lookups with time bounds on those indices use ZCOUNT to find the ranks of the
+// anchors within the bounds and only read those ranks with ZRANGE, while the
+// rest of lookups iterate with SSCAN and ZSCAN, so large graphs are streamed
+// without blocking the server.
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pborman/uuid"
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// pageSize is the number of elements requested on each round trip while
+// iterating over an index.
+const pageSize = "100"
+
+// Indices of a graph. Several lookups share the same index.
+const (
+	// idxTriples is a hash of the triples keyed by triple UUID.
+	idxTriples = "t"
+	// idxA is a sorted set of all the triples.
+	idxA = "a"
+	// idxP is a set keyed by predicate UUID.
+	idxP = "p"
+	// idxSP is a set keyed by subject and predicate UUIDs.
+	idxSP = "sp"
+	// idxPO is a set keyed by predicate and object UUIDs.
+	idxPO = "po"
+	// idxS is a sorted set keyed by subject UUID.
+	idxS = "s"
+	// idxO is a sorted set keyed by object UUID.
+	idxO = "o"
+	// idxSO is a sorted set keyed by subject and object UUIDs.
+	idxSO = "so"
+)
+
+// Store implements storage.Store on top of a Redis server.
+type Store struct {
+	ns   string
+	pool *pool
+}
+
+// NewStore connects to the Redis server on the provided address and returns a
+// store keeping all its keys under the provided namespace. Stores sharing the
+// namespace on the same server share their graphs. The store should be closed
+// once it is no longer needed.
+func NewStore(addr, namespace string) (*Store, error) {
+	s := &Store{
+		ns:   namespace,
+		pool: &pool{addr: addr},
+	}
+	if _, err := s.do("PING"); err != nil {
+		return nil, fmt.Errorf("redis.NewStore(%q): failed to connect with error %v", addr, err)
+	}
+	return s, nil
+}
+
+// Close releases the connections to the Redis server.
+func (s *Store) Close() error {
+	return s.pool.close()
+}
+
+// do runs a single command on a pooled connection.
+func (s *Store) do(args ...string) (interface{}, error) {
+	c, err := s.pool.get()
+	if err != nil {
+		return nil, err
+	}
+	v, err := c.do(args...)
+	s.pool.put(c, err)
+	return v, err
+}
+
+// key returns the key of the store made of the provided parts.
+func (s *Store) key(parts ...string) string {
+	return s.ns + ":" + strings.Join(parts, ":")
+}
+
+// graphKey returns the key holding the token of the provided graph.
+func (s *Store) graphKey(id string) string {
+	return s.key("graph", id)
+}
+
+// Name returns the ID of the backend being used.
+func (s *Store) Name(ctx context.Context) string {
+	return "REDIS"
+}
+
+// Version returns the version of the driver implementation.
+func (s *Store) Version(ctx context.Context) string {
+	return "0.1.vcli"
+}
+
+// Generation returns the current generation of the store. The generation is
+// shared by all the stores using the same namespace and increases on every
+// mutation done through any of them.
+func (s *Store) Generation(ctx context.Context) (uint64, error) {
+	v, err := s.do("GET", s.key("gen"))
+	if err != nil || v == nil {
+		return 0, err
+	}
+	str, _ := v.(string)
+	return strconv.ParseUint(str, 10, 64)
+}
+
+// exec runs the provided commands in a MULTI/EXEC transaction and returns the
+// replies of each command.
+func exec(c *conn, cmds [][]string) ([]interface{}, error) {
+	if err := c.send("MULTI"); err != nil {
+		return nil, err
+	}
+	for _, cmd := range cmds {
+		if err := c.send(cmd...); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.send("EXEC"); err != nil {
+		return nil, err
+	}
+	if err := c.flush(); err != nil {
+		return nil, err
+	}
+	// Consume the replies to MULTI and to each queued command, and only return
+	// the first error once all the replies are read.
+	var qErr error
+	for i := 0; i <= len(cmds); i++ {
+		if _, err := c.receive(); err != nil && qErr == nil {
+			qErr = err
+		}
+	}
+	v, err := c.receive()
+	if qErr != nil {
+		return nil, qErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		// The transaction was aborted since a watched key changed.
+		return nil, nil
+	}
+	rs, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected EXEC reply %v", v)
+	}
+	for _, r := range rs {
+		if e, ok := r.(redisError); ok {
+			return nil, e
+		}
+	}
+	return rs, nil
+}
+
+// NewGraph creates a new graph. Creating an already existing graph
+// should return an error.
+func (s *Store) NewGraph(ctx context.Context, id string) (storage.Graph, error) {
+	token := uuid.New()
+	c, err := s.pool.get()
+	if err != nil {
+		return nil, fmt.Errorf("redis.NewGraph(%q): failed to create graph with error %v", id, err)
+	}
+	rs, err := exec(c, [][]string{
+		{"SET", s.graphKey(id), token, "NX"},
+		{"SADD", s.key("graphs"), id},
+		{"INCR", s.key("gen")},
+	})
+	s.pool.put(c, err)
+	if err != nil {
+		return nil, fmt.Errorf("redis.NewGraph(%q): failed to create graph with error %v", id, err)
+	}
+	if rs == nil || rs[0] == nil {
+		return nil, fmt.Errorf("redis.NewGraph(%q): graph already exists", id)
+	}
+	return s.newGraph(id, token), nil
+}
+
+// newGraph returns the graph for the provided ID and token.
+func (s *Store) newGraph(id, token string) *graph {
+	return &graph{
+		id:     id,
+		token:  token,
+		prefix: s.key("data", token) + ":",
+		s:      s,
+	}
+}
+
+// Graph returns an existing graph if available. Getting a non existing
+// graph should return an error.
+func (s *Store) Graph(ctx context.Context, id string) (storage.Graph, error) {
+	v, err := s.do("GET", s.graphKey(id))
+	if err != nil {
+		return nil, err
+	}
+	token, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("redis.Graph(%q): graph does not exist", id)
+	}
+	return s.newGraph(id, token), nil
+}
+
+// DeleteGraph deletes an existing graph. Deleting a non existing graph
+// should return an error. The keys of the graph data are removed after the
+// graph is deleted.
+func (s *Store) DeleteGraph(ctx context.Context, id string) error {
+	c, err := s.pool.get()
+	if err != nil {
+		return fmt.Errorf("redis.DeleteGraph(%q): failed to delete graph with error %v", id, err)
+	}
+	defer func() {
+		s.pool.put(c, err)
+	}()
+	rs, err := exec(c, [][]string{
+		{"GET", s.graphKey(id)},
+		{"DEL", s.graphKey(id)},
+		{"SREM", s.key("graphs"), id},
+		{"INCR", s.key("gen")},
+	})
+	if err != nil {
+		return fmt.Errorf("redis.DeleteGraph(%q): failed to delete graph with error %v", id, err)
+	}
+	token, ok := rs[0].(string)
+	if !ok {
+		return fmt.Errorf("redis.DeleteGraph(%q): graph does not exist", id)
+	}
+	if err = s.deleteData(c, token); err != nil {
+		return fmt.Errorf("redis.DeleteGraph(%q): failed to delete graph data with error %v", id, err)
+	}
+	return nil
+}
+
+// escapePattern escapes the characters with special meaning in the patterns
+// used by SCAN.
+func escapePattern(p string) string {
+	var b strings.Builder
+	for _, r := range p {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// deleteData removes all the keys in the namespace of the provided token.
+func (s *Store) deleteData(c *conn, token string) error {
+	match := escapePattern(s.key("data", token)+":") + "*"
+	for cursor := "0"; ; {
+		v, err := c.do("SCAN", cursor, "MATCH", match, "COUNT", pageSize)
+		if err != nil {
+			return err
+		}
+		var ks []string
+		if cursor, ks, err = scanReply(v); err != nil {
+			return err
+		}
+		if len(ks) > 0 {
+			if _, err := c.do(append([]string{"DEL"}, ks...)...); err != nil {
+				return err
+			}
+		}
+		if cursor == "0" {
+			return nil
+		}
+	}
+}
+
+// scanReply decodes the cursor and the elements returned by the SCAN family
+// of commands.
+func scanReply(v interface{}) (string, []string, error) {
+	rs, ok := v.([]interface{})
+	if !ok || len(rs) != 2 {
+		return "", nil, fmt.Errorf("redis: unexpected scan reply %v", v)
+	}
+	cursor, ok := rs[0].(string)
+	if !ok {
+		return "", nil, fmt.Errorf("redis: unexpected scan cursor %v", rs[0])
+	}
+	es, err := strs(rs[1])
+	return cursor, es, err
+}
+
+// strs decodes an array reply made of strings.
+func strs(v interface{}) ([]string, error) {
+	rs, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected array reply %v", v)
+	}
+	res := make([]string, 0, len(rs))
+	for _, r := range rs {
+		s, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("redis: unexpected array element %v", r)
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// GraphNames returns the current available graph names in the store.
+func (s *Store) GraphNames(ctx context.Context, names chan<- string) error {
+	if names == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(names)
+	c, err := s.pool.get()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		s.pool.put(c, err)
+	}()
+	// SSCAN may return an element more than once.
+	seen := make(map[string]bool)
+	for cursor := "0"; ; {
+		var v interface{}
+		if v, err = c.do("SSCAN", s.key("graphs"), cursor, "COUNT", pageSize); err != nil {
+			return err
+		}
+		var ns []string
+		if cursor, ns, err = scanReply(v); err != nil {
+			return err
+		}
+		for _, n := range ns {
+			if seen[n] {
+				continue
+			}
+			seen[n] = true
+			select {
+			case names <- n:
+			case <-done(ctx):
+				return ctx.Err()
+			}
+		}
+		if cursor == "0" {
+			return nil
+		}
+	}
+}
+
+// graph provides a Redis-based implementation of the graph API.
+type graph struct {
+	id     string
+	token  string
+	prefix string
+	s      *Store
+}
+
+// ID returns the id for this graph.
+func (g *graph) ID(ctx context.Context) string {
+	return g.id
+}
+
+// key returns the key in the provided index of the graph made of the
+// provided parts.
+func (g *graph) key(idx string, ps ...uuid.UUID) string {
+	k := g.prefix + idx
+	for _, p := range ps {
+		k += ":" + p.String()
+	}
+	return k
+}
+
+// check returns an error if the graph no longer exists.
+func (g *graph) check(c *conn) error {
+	v, err := c.do("GET", g.s.graphKey(g.id))
+	if err != nil {
+		return err
+	}
+	if token, ok := v.(string); !ok || token != g.token {
+		return fmt.Errorf("redis graph %q does not exist", g.id)
+	}
+	return nil
+}
+
+// score returns the score of the provided triple on the sorted indices.
+// Immutable predicates are scored -inf, so they sort before any anchor.
+// Anchors are scored by their Unix time in seconds, since scores cannot
+// represent nanoseconds exactly; lookups filter the sub-second anchors.
+func score(t *triple.Triple) string {
+	ta, err := t.Predicate().TimeAnchor()
+	if err != nil {
+		return "-inf"
+	}
+	return strconv.FormatInt(ta.Unix(), 10)
+}
+
+// indexCommands returns the commands that add, or remove if add is false, the
+// provided triple to each of the indices.
+func (g *graph) indexCommands(t *triple.Triple, add bool) [][]string {
+	sUUID, pUUID, oUUID := t.Subject().UUID(), t.Predicate().UUID(), t.Object().UUID()
+	tUUID, sc := t.UUID().String(), score(t)
+	sets := []string{g.key(idxP, pUUID), g.key(idxSP, sUUID, pUUID), g.key(idxPO, pUUID, oUUID)}
+	zsets := []string{g.key(idxA), g.key(idxS, sUUID), g.key(idxO, oUUID), g.key(idxSO, sUUID, oUUID)}
+	var cmds [][]string
+	if add {
+		cmds = append(cmds, []string{"HSET", g.key(idxTriples), tUUID, t.String()})
+		for _, k := range sets {
+			cmds = append(cmds, []string{"SADD", k, tUUID})
+		}
+		for _, k := range zsets {
+			cmds = append(cmds, []string{"ZADD", k, sc, tUUID})
+		}
+		return cmds
+	}
+	cmds = append(cmds, []string{"HDEL", g.key(idxTriples), tUUID})
+	for _, k := range sets {
+		cmds = append(cmds, []string{"SREM", k, tUUID})
+	}
+	for _, k := range zsets {
+		cmds = append(cmds, []string{"ZREM", k, tUUID})
+	}
+	return cmds
+}
+
+// update runs the commands returned by f in a transaction that is aborted if
+// the graph is deleted or any of the provided keys changes meanwhile. f can
+// read the watched keys using the provided connection, and no transaction is
+// run if it returns no commands. Aborted transactions are retried until they
+// succeed or the context is done.
+func (g *graph) update(ctx context.Context, f func(c *conn) ([][]string, error), watch ...string) (err error) {
+	c, err := g.s.pool.get()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		g.s.pool.put(c, err)
+	}()
+	for {
+		if _, err = c.do(append([]string{"WATCH", g.s.graphKey(g.id)}, watch...)...); err != nil {
+			return err
+		}
+		var cmds [][]string
+		if err = g.check(c); err == nil {
+			cmds, err = f(c)
+		}
+		if err != nil || len(cmds) == 0 {
+			if _, uErr := c.do("UNWATCH"); uErr != nil && err == nil {
+				err = uErr
+			}
+			return err
+		}
+		var rs []interface{}
+		if rs, err = exec(c, append(cmds, []string{"INCR", g.s.key("gen")})); err != nil || rs != nil {
+			return err
+		}
+		select {
+		case <-done(ctx):
+			return ctx.Err()
+		default:
+		}
+	}
+}
+
+// AddTriples adds the triples to the storage. Adding a triple that already
+// exists should not fail.
+func (g *graph) AddTriples(ctx context.Context, ts []*triple.Triple) error {
+	return g.update(ctx, func(c *conn) ([][]string, error) {
+		var cmds [][]string
+		for _, t := range ts {
+			cmds = append(cmds, g.indexCommands(t, true)...)
+		}
+		return cmds, nil
+	})
+}
+
+// RemoveTriples removes the triples from the storage. Removing triples that
+// are not present on the store should not fail.
+func (g *graph) RemoveTriples(ctx context.Context, ts []*triple.Triple) error {
+	return g.update(ctx, func(c *conn) ([][]string, error) {
+		var cmds [][]string
+		for _, t := range ts {
+			cmds = append(cmds, g.indexCommands(t, false)...)
+		}
+		return cmds, nil
+	})
+}
+
+// CompareAndSwap replaces the expected triple with the new one if the
+// expected triple is stored. The transaction watches the triples hash, hence
+// concurrent mutations from other processes make the check run again.
+func (g *graph) CompareAndSwap(ctx context.Context, expected, new *triple.Triple) (bool, error) {
+	swapped := false
+	err := g.update(ctx, func(c *conn) ([][]string, error) {
+		v, err := c.do("HEXISTS", g.key(idxTriples), expected.UUID().String())
+		if err != nil {
+			return nil, err
+		}
+		if swapped = v == int64(1); !swapped {
+			return nil, nil
+		}
+		return append(g.indexCommands(expected, false), g.indexCommands(new, true)...), nil
+	}, g.key(idxTriples))
+	if err != nil {
+		return false, err
+	}
+	return swapped, nil
+}
+
+// checker provides the mechanics to check if a predicate/triple should be
+// considered on a certain operation.
+type checker struct {
+	max bool
+	c   int
+	o   *storage.LookupOptions
+}
+
+// newChecker creates a new checker for a given LookupOptions configuration.
+func newChecker(o *storage.LookupOptions) *checker {
+	return &checker{
+		max: o.MaxElements > 0,
+		c:   o.MaxElements,
+		o:   o,
+	}
+}
+
+// done returns true if no more elements should be returned.
+func (c *checker) done() bool {
+	return c.max && c.c <= 0
+}
+
+// CheckAndUpdate checks if a predicate should be considered and it also updates
+// the internal state in case counts are needed.
+func (c *checker) CheckAndUpdate(p *predicate.Predicate) bool {
+	if c.done() {
+		return false
+	}
+	if p.Type() == predicate.Immutable {
+		c.c--
+		return true
+	}
+	t, _ := p.TimeAnchor()
+	if c.o.LowerAnchor != nil && t.Before(*c.o.LowerAnchor) {
+		return false
+	}
+	if c.o.UpperAnchor != nil && t.After(*c.o.UpperAnchor) {
+		return false
+	}
+	c.c--
+	return true
+}
+
+// done returns the channel closed when the provided context is done. A nil
+// context is never done.
+func done(ctx context.Context) <-chan struct{} {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Done()
+}
+
+// bounded returns true if the lookup options constrain the time anchors.
+func bounded(lo *storage.LookupOptions) bool {
+	return lo.LowerAnchor != nil || lo.UpperAnchor != nil
+}
+
+// emit reads the triples with the provided UUIDs and hands the ones that
+// satisfy the lookup options to f. Triples removed since their UUIDs were
+// read are skipped.
+func (g *graph) emit(c *conn, ids []string, ckr *checker, f func(*triple.Triple) error) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	v, err := c.do(append([]string{"HMGET", g.key(idxTriples)}, ids...)...)
+	if err != nil {
+		return err
+	}
+	vs, ok := v.([]interface{})
+	if !ok {
+		return fmt.Errorf("redis: unexpected HMGET reply %v", v)
+	}
+	for _, r := range vs {
+		if ckr.done() {
+			return nil
+		}
+		s, ok := r.(string)
+		if !ok {
+			continue
+		}
+		t, err := triple.Parse(s, literal.DefaultBuilder())
+		if err != nil {
+			return err
+		}
+		if ckr.CheckAndUpdate(t.Predicate()) {
+			if err := f(t); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lookup iterates over all the triples in the provided index that satisfy the
+// lookup options. Sorted indices are only read within the time bounds. The
+// iteration stops as soon as f returns an error. LatestOnly lookups read all
+// the triples before providing the latest versions to f.
+func (g *graph) lookup(ctx context.Context, key string, sorted bool, lo *storage.LookupOptions, f func(*triple.Triple) error) (err error) {
+	if lo.LatestOnly {
+		return storage.ScanLatest(lo, func(lo *storage.LookupOptions, f func(*triple.Triple) error) error {
+			return g.lookup(ctx, key, sorted, lo, f)
+		}, f)
+	}
+	c, err := g.s.pool.get()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		g.s.pool.put(c, err)
+	}()
+	if err = g.check(c); err != nil {
+		return err
+	}
+	if sorted && bounded(lo) {
+		return g.lookupRange(ctx, c, key, lo, f)
+	}
+	cmd, step := "SSCAN", 1
+	if sorted {
+		cmd, step = "ZSCAN", 2
+	}
+	// The SCAN family of commands may return an element more than once.
+	ckr, seen := newChecker(lo), make(map[string]bool)
+	for cursor := "0"; !ckr.done(); {
+		var v interface{}
+		if v, err = c.do(cmd, key, cursor, "COUNT", pageSize); err != nil {
+			return err
+		}
+		var es, ids []string
+		if cursor, es, err = scanReply(v); err != nil {
+			return err
+		}
+		for i := 0; i < len(es); i += step {
+			if !seen[es[i]] {
+				seen[es[i]] = true
+				ids = append(ids, es[i])
+			}
+		}
+		if err = g.emit(c, ids, ckr, f); err != nil {
+			return err
+		}
+		if cursor == "0" {
+			break
+		}
+		select {
+		case <-done(ctx):
+			return ctx.Err()
+		default:
+		}
+	}
+	return nil
+}
+
+// lookupRange iterates over the triples of the provided sorted index whose
+// score is within the time bounds of the lookup options. Immutable triples are
+// always returned. The ranks of each range are computed once with ZCOUNT and
+// read page by page with ZRANGE, which unlike a LIMIT offset does not walk the
+// already read members again on every page.
+func (g *graph) lookupRange(ctx context.Context, c *conn, key string, lo *storage.LookupOptions, f func(*triple.Triple) error) error {
+	upper := "+inf"
+	if lo.UpperAnchor != nil {
+		upper = strconv.FormatInt(lo.UpperAnchor.Unix(), 10)
+	}
+	ranges := [][2]string{{"-inf", upper}}
+	if lo.LowerAnchor != nil {
+		ranges = [][2]string{{"-inf", "-inf"}, {strconv.FormatInt(lo.LowerAnchor.Unix(), 10), upper}}
+	}
+	ckr := newChecker(lo)
+	size, err := strconv.ParseInt(pageSize, 10, 64)
+	if err != nil {
+		return err
+	}
+	for _, r := range ranges {
+		// The members within the range are the last cnt members of the ones
+		// scored up to the upper bound.
+		hi, err := zcount(c, key, "-inf", r[1])
+		if err != nil {
+			return err
+		}
+		cnt, err := zcount(c, key, r[0], r[1])
+		if err != nil {
+			return err
+		}
+		for i := hi - cnt; i < hi && !ckr.done(); i += size {
+			last := i + size - 1
+			if last >= hi {
+				last = hi - 1
+			}
+			v, err := c.do("ZRANGE", key, strconv.FormatInt(i, 10), strconv.FormatInt(last, 10))
+			if err != nil {
+				return err
+			}
+			ids, err := strs(v)
+			if err != nil {
+				return err
+			}
+			if err := g.emit(c, ids, ckr, f); err != nil {
+				return err
+			}
+			select {
+			case <-done(ctx):
+				return ctx.Err()
+			default:
+			}
+		}
+	}
+	return nil
+}
+
+// zcount returns the number of members of the sorted set whose score is within
+// the provided bounds.
+func zcount(c *conn, key, min, max string) (int64, error) {
+	v, err := c.do("ZCOUNT", key, min, max)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected ZCOUNT reply %v", v)
+	}
+	return n, nil
+}
+
+// Objects pushes to the provided channel the objects for the given object and
+// predicate. The function does not return immediately.
+func (g *graph) Objects(ctx context.Context, s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions, objs chan<- *triple.Object) error {
+	if objs == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(objs)
+	return g.lookup(ctx, g.key(idxSP, s.UUID(), p.UUID()), false, lo, func(t *triple.Triple) error {
+		select {
+		case objs <- t.Object():
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// Subjects pushes to the provided channel the subjects for the give predicate
+// and object. The function does not return immediately.
+func (g *graph) Subjects(ctx context.Context, p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions, subs chan<- *node.Node) error {
+	if subs == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(subs)
+	return g.lookup(ctx, g.key(idxPO, p.UUID(), o.UUID()), false, lo, func(t *triple.Triple) error {
+		select {
+		case subs <- t.Subject():
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// PredicatesForSubject pushes to the provided channel all the predicates
+// known for the given subject. The function does not return immediately.
+func (g *graph) PredicatesForSubject(ctx context.Context, s *node.Node, lo *storage.LookupOptions, prds chan<- *predicate.Predicate) error {
+	if prds == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(prds)
+	return g.lookup(ctx, g.key(idxS, s.UUID()), true, lo, func(t *triple.Triple) error {
+		select {
+		case prds <- t.Predicate():
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// PredicatesForObject pushes to the provided channel all the predicates known
+// for the given object. The function does not return immediately.
+func (g *graph) PredicatesForObject(ctx context.Context, o *triple.Object, lo *storage.LookupOptions, prds chan<- *predicate.Predicate) error {
+	if prds == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(prds)
+	return g.lookup(ctx, g.key(idxO, o.UUID()), true, lo, func(t *triple.Triple) error {
+		select {
+		case prds <- t.Predicate():
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// PredicatesForSubjectAndObject pushes to the provided channel all predicates
+// available for the given subject and object. The function does not return
+// immediately.
+func (g *graph) PredicatesForSubjectAndObject(ctx context.Context, s *node.Node, o *triple.Object, lo *storage.LookupOptions, prds chan<- *predicate.Predicate) error {
+	if prds == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(prds)
+	return g.lookup(ctx, g.key(idxSO, s.UUID(), o.UUID()), true, lo, func(t *triple.Triple) error {
+		select {
+		case prds <- t.Predicate():
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// TriplesForSubject pushes to the provided channel all triples available for
+// the given subject. The function does not return immediately.
+func (g *graph) TriplesForSubject(ctx context.Context, s *node.Node, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	return g.lookup(ctx, g.key(idxS, s.UUID()), true, lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// TriplesForPredicate pushes to the provided channel all triples available
+// for the given predicate. The function does not return immediately.
+func (g *graph) TriplesForPredicate(ctx context.Context, p *predicate.Predicate, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	return g.lookup(ctx, g.key(idxP, p.UUID()), false, lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// TriplesForObject pushes to the provided channel all triples available for
+// the given object. The function does not return immediately.
+func (g *graph) TriplesForObject(ctx context.Context, o *triple.Object, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	return g.lookup(ctx, g.key(idxO, o.UUID()), true, lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// TriplesForSubjectAndPredicate pushes to the provided channel all triples
+// available for the given subject and predicate. The function does not return
+// immediately.
+func (g *graph) TriplesForSubjectAndPredicate(ctx context.Context, s *node.Node, p *predicate.Predicate, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	return g.lookup(ctx, g.key(idxSP, s.UUID(), p.UUID()), false, lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// TriplesForPredicateAndObject pushes to the provided channel all triples
+// available for the given predicate and object. The function does not return
+// immediately.
+func (g *graph) TriplesForPredicateAndObject(ctx context.Context, p *predicate.Predicate, o *triple.Object, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	return g.lookup(ctx, g.key(idxPO, p.UUID(), o.UUID()), false, lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
+
+// Exist checks if the provided triple exists on the store.
+func (g *graph) Exist(ctx context.Context, t *triple.Triple) (bool, error) {
+	c, err := g.s.pool.get()
+	if err != nil {
+		return false, err
+	}
+	v, err := c.do("HEXISTS", g.key(idxTriples), t.UUID().String())
+	if err == nil {
+		// The graph is checked last, so triples of a graph deleted meanwhile
+		// are not reported.
+		err = g.check(c)
+	}
+	g.s.pool.put(c, err)
+	if err != nil {
+		return false, err
+	}
+	return v == int64(1), nil
+}
+
+// Triples pushes to the provided channel all available triples in the graph.
+// The function does not return immediately. Lookups without time bounds read
+// the triples using ZSCAN, and lookups with time bounds only read the temporal
+// triples within the bounds.
+func (g *graph) Triples(ctx context.Context, lo *storage.LookupOptions, trpls chan<- *triple.Triple) error {
+	if trpls == nil {
+		return fmt.Errorf("cannot provide an empty channel")
+	}
+	defer close(trpls)
+	return g.lookup(ctx, g.key(idxA), true, lo, func(t *triple.Triple) error {
+		select {
+		case trpls <- t:
+			return nil
+		case <-done(ctx):
+			return ctx.Err()
+		}
+	})
+}
@@ -0,0 +1,803 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/google/badwolf/storage"
+	"github.com/google/badwolf/storage/memory"
+	"github.com/google/badwolf/triple"
+	"github.com/google/badwolf/triple/literal"
+	"github.com/google/badwolf/triple/node"
+	"github.com/google/badwolf/triple/predicate"
+)
+
+// newTestStore returns a store connected to a fake Redis server, the server,
+// and a function to clean them up once done.
+func newTestStore(t *testing.T) (*Store, *fakeServer, func()) {
+	f := newFakeServer(t)
+	s, err := NewStore(f.addr(), "badwolf")
+	if err != nil {
+		f.close()
+		t.Fatalf("redis.NewStore failed with error %v", err)
+	}
+	return s, f, func() {
+		s.Close()
+		f.close()
+	}
+}
+
+// newSharedStore returns another store connected to the same server, as
+// another process would.
+func newSharedStore(t *testing.T, f *fakeServer, namespace string) *Store {
+	s, err := NewStore(f.addr(), namespace)
+	if err != nil {
+		t.Fatalf("redis.NewStore failed with error %v", err)
+	}
+	return s
+}
+
+func TestNewStoreFailsWithoutServer(t *testing.T) {
+	f := newFakeServer(t)
+	addr := f.addr()
+	f.close()
+	if _, err := NewStore(addr, "badwolf"); err == nil {
+		t.Errorf("redis.NewStore(%q) should have failed to connect to a closed server", addr)
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	s, _, done := newTestStore(t)
+	defer done()
+	ctx := context.Background()
+	// Create a new graph.
+	if _, err := s.NewGraph(ctx, "test"); err != nil {
+		t.Errorf("redisStore.NewGraph: should never fail to crate a graph; %s", err)
+	}
+	// Create an already existing graph.
+	if _, err := s.NewGraph(ctx, "test"); err == nil {
+		t.Errorf("redisStore.NewGraph: should never succeed to create an existing graph")
+	}
+	// Get an existing graph.
+	if _, err := s.Graph(ctx, "test"); err != nil {
+		t.Errorf("redisStore.Graph: should never fail to get an existing graph; %s", err)
+	}
+	// Delete an existing graph.
+	if err := s.DeleteGraph(ctx, "test"); err != nil {
+		t.Errorf("redisStore.DeleteGraph: should never fail to delete an existing graph; %s", err)
+	}
+	// Get a non existing graph.
+	if _, err := s.Graph(ctx, "test"); err == nil {
+		t.Errorf("redisStore.Graph: should never succeed to get a non existing graph")
+	}
+	// Delete a non existing graph.
+	if err := s.DeleteGraph(ctx, "test"); err == nil {
+		t.Errorf("redisStore.DeleteGraph: should never succed to delete a non existing graph")
+	}
+}
+
+func TestGraphNames(t *testing.T) {
+	s, _, done := newTestStore(t)
+	defer done()
+	gs, ctx := []string{"?foo", "?bar", "?test"}, context.Background()
+	for _, g := range gs {
+		if _, err := s.NewGraph(ctx, g); err != nil {
+			t.Errorf("redisStore.NewGraph: should never fail to crate a graph %s; %s", g, err)
+		}
+	}
+	gns := make(chan string, len(gs))
+	if err := s.GraphNames(ctx, gns); err != nil {
+		t.Errorf("redisStore.GraphNames: failed with error %v", err)
+	}
+	got := make(map[string]bool)
+	for g := range gns {
+		got[g] = true
+	}
+	for _, g := range gs {
+		if !got[g] {
+			t.Errorf("redisStore.GraphNames: failed to return graph %q; got %v", g, got)
+		}
+	}
+	if len(got) != len(gs) {
+		t.Errorf("redisStore.GraphNames: failed to return %d graphs; got %v", len(gs), got)
+	}
+}
+
+func TestConcurrentNewAndDeleteGraph(t *testing.T) {
+	s, f, done := newTestStore(t)
+	defer done()
+	// Half of the goroutines use another store, as another process would.
+	o := newSharedStore(t, f, "badwolf")
+	defer o.Close()
+	ctx := context.Background()
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created = make(map[string]int)
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			st, id := s, fmt.Sprintf("?g%d", i%5)
+			if i%2 == 0 {
+				st = o
+			}
+			if _, err := st.NewGraph(ctx, id); err == nil {
+				mu.Lock()
+				created[id]++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < 5; i++ {
+		if id := fmt.Sprintf("?g%d", i); created[id] != 1 {
+			t.Errorf("redisStore.NewGraph: graph %q should have been created once; created %d times", id, created[id])
+		}
+	}
+	var deleted [5]int
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			st := s
+			if i%2 == 0 {
+				st = o
+			}
+			if err := st.DeleteGraph(ctx, fmt.Sprintf("?g%d", i%5)); err == nil {
+				mu.Lock()
+				deleted[i%5]++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, d := range deleted {
+		if d != 1 {
+			t.Errorf("redisStore.DeleteGraph: graph \"?g%d\" should have been deleted once; deleted %d times", i, d)
+		}
+	}
+	gns := make(chan string, 20)
+	if err := s.GraphNames(ctx, gns); err != nil {
+		t.Fatal(err)
+	}
+	for g := range gns {
+		t.Errorf("redisStore.GraphNames: graph %q should have been deleted", g)
+	}
+}
+
+func createTriples(t *testing.T, ss []string) []*triple.Triple {
+	ts := []*triple.Triple{}
+	for _, s := range ss {
+		trpl, err := triple.Parse(s, literal.DefaultBuilder())
+		if err != nil {
+			t.Errorf("triple.Parse failed to parse valid triple %s with error %v", s, err)
+			continue
+		}
+		ts = append(ts, trpl)
+	}
+	return ts
+}
+
+func getTestTriples(t *testing.T) []*triple.Triple {
+	return createTriples(t, []string{
+		"/u<john>\t\"knows\"@[]\t/u<mary>",
+		"/u<john>\t\"knows\"@[]\t/u<peter>",
+		"/u<john>\t\"knows\"@[]\t/u<alice>",
+		"/u<mary>\t\"knows\"@[]\t/u<andrew>",
+		"/u<mary>\t\"knows\"@[]\t/u<kim>",
+		"/u<mary>\t\"knows\"@[]\t/u<alice>",
+	})
+}
+
+// newTestGraph returns a graph populated with the provided triples.
+func newTestGraph(t *testing.T, s *Store, ts []*triple.Triple) storage.Graph {
+	ctx := context.Background()
+	g, err := s.NewGraph(ctx, "?test")
+	if err != nil {
+		t.Fatalf("redisStore.NewGraph failed with error %v", err)
+	}
+	if err := g.AddTriples(ctx, ts); err != nil {
+		t.Fatalf("g.AddTriples(_) failed to add test triples with error %v", err)
+	}
+	return g
+}
+
+func TestAddRemoveTriples(t *testing.T) {
+	s, _, done := newTestStore(t)
+	defer done()
+	ts, ctx := getTestTriples(t), context.Background()
+	g := newTestGraph(t, s, ts)
+	for _, tr := range ts {
+		if b, err := g.Exist(ctx, tr); err != nil || !b {
+			t.Errorf("g.Exist(%s) should have returned true; got %v, %v", tr, b, err)
+		}
+	}
+	if err := g.RemoveTriples(ctx, ts); err != nil {
+		t.Errorf("g.RemoveTriples(_) failed to remove test triples with error %v", err)
+	}
+	for _, tr := range ts {
+		if b, err := g.Exist(ctx, tr); err != nil || b {
+			t.Errorf("g.Exist(%s) should have returned false; got %v, %v", tr, b, err)
+		}
+	}
+	trpls := make(chan *triple.Triple, 100)
+	if err := g.TriplesForSubject(ctx, ts[0].Subject(), storage.DefaultLookup, trpls); err != nil {
+		t.Fatal(err)
+	}
+	for tr := range trpls {
+		t.Errorf("g.TriplesForSubject(%s) returned removed triple %s", ts[0].Subject(), tr)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	s, _, done := newTestStore(t)
+	defer done()
+	ts, ctx := getTestTriples(t), context.Background()
+	g := newTestGraph(t, s, ts[:1])
+	if ok, err := g.CompareAndSwap(ctx, ts[0], ts[1]); err != nil || !ok {
+		t.Errorf("g.CompareAndSwap(%s, %s) returned %v, %v; want true, nil", ts[0], ts[1], ok, err)
+	}
+	if b, err := g.Exist(ctx, ts[0]); err != nil || b {
+		t.Errorf("g.Exist(%s) should have returned false after the swap; got %v, %v", ts[0], b, err)
+	}
+	trpls := make(chan *triple.Triple, 100)
+	if err := g.TriplesForSubject(ctx, ts[1].Subject(), storage.DefaultLookup, trpls); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for tr := range trpls {
+		got = append(got, tr.String())
+	}
+	if len(got) != 1 || got[0] != ts[1].String() {
+		t.Errorf("g.TriplesForSubject(%s) returned %v after the swap; want [%s]", ts[1].Subject(), got, ts[1])
+	}
+	// Missing expected triples do not swap.
+	if ok, err := g.CompareAndSwap(ctx, ts[0], ts[2]); err != nil || ok {
+		t.Errorf("g.CompareAndSwap(%s, %s) returned %v, %v; want false, nil", ts[0], ts[2], ok, err)
+	}
+	if b, err := g.Exist(ctx, ts[2]); err != nil || b {
+		t.Errorf("g.Exist(%s) should have returned false; got %v, %v", ts[2], b, err)
+	}
+}
+
+func TestConcurrentCompareAndSwap(t *testing.T) {
+	s, f, done := newTestStore(t)
+	defer done()
+	o := newSharedStore(t, f, "badwolf")
+	defer o.Close()
+	ts, ctx := getTestTriples(t), context.Background()
+	newTestGraph(t, s, ts[:1])
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		swapped int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			st := s
+			if i%2 == 0 {
+				st = o
+			}
+			g, err := st.Graph(ctx, "?test")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ok, err := g.CompareAndSwap(ctx, ts[0], ts[1+i%5])
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				mu.Lock()
+				swapped++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if swapped != 1 {
+		t.Errorf("g.CompareAndSwap(%s, _) should have only swapped once; swapped %d times", ts[0], swapped)
+	}
+}
+
+func TestSharedGraphs(t *testing.T) {
+	s, f, done := newTestStore(t)
+	defer done()
+	shared, other := newSharedStore(t, f, "badwolf"), newSharedStore(t, f, "other")
+	defer shared.Close()
+	defer other.Close()
+	ts, ctx := getTestTriples(t), context.Background()
+	newTestGraph(t, s, ts)
+	g, err := shared.Graph(ctx, "?test")
+	if err != nil {
+		t.Fatalf("redisStore.Graph failed to get a graph created by another store with error %v", err)
+	}
+	for _, tr := range ts {
+		if b, err := g.Exist(ctx, tr); err != nil || !b {
+			t.Errorf("g.Exist(%s) should have returned true on the shared store; got %v, %v", tr, b, err)
+		}
+	}
+	gen, err := s.Generation(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := shared.Generation(ctx); err != nil || got != gen {
+		t.Errorf("redisStore.Generation returned %d, %v on the shared store; want %d, nil", got, err, gen)
+	}
+	// Other namespaces do not see the graph.
+	if _, err := other.Graph(ctx, "?test"); err == nil {
+		t.Errorf("redisStore.Graph should have failed to get a graph of another namespace")
+	}
+	if _, err := other.NewGraph(ctx, "?test"); err != nil {
+		t.Errorf("redisStore.NewGraph failed to create a graph named as one of another namespace with error %v", err)
+	}
+}
+
+func TestDeletedGraphs(t *testing.T) {
+	s, f, done := newTestStore(t)
+	defer done()
+	ts, ctx := getTestTriples(t), context.Background()
+	g := newTestGraph(t, s, ts)
+	if err := s.DeleteGraph(ctx, "?test"); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range f.keys() {
+		if strings.HasPrefix(k, "badwolf:data:") {
+			t.Errorf("redisStore.DeleteGraph left key %q behind", k)
+		}
+	}
+	// Recreating the graph does not revive the deleted handle nor its triples.
+	ng, err := s.NewGraph(ctx, "?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.AddTriples(ctx, ts); err == nil {
+		t.Errorf("g.AddTriples should have failed on a deleted graph")
+	}
+	if _, err := g.Exist(ctx, ts[0]); err == nil {
+		t.Errorf("g.Exist should have failed on a deleted graph")
+	}
+	trpls := make(chan *triple.Triple, 100)
+	if err := g.Triples(ctx, storage.DefaultLookup, trpls); err == nil {
+		t.Errorf("g.Triples should have failed on a deleted graph")
+	}
+	if b, err := ng.Exist(ctx, ts[0]); err != nil || b {
+		t.Errorf("g.Exist(%s) should have returned false on the recreated graph; got %v, %v", ts[0], b, err)
+	}
+}
+
+func TestLookups(t *testing.T) {
+	s, _, done := newTestStore(t)
+	defer done()
+	ts, ctx := getTestTriples(t), context.Background()
+	g := newTestGraph(t, s, ts)
+	john, mary := ts[0].Subject(), ts[3].Subject()
+	knows, alice := ts[0].Predicate(), ts[2].Object()
+
+	countNodes := func(f func(chan<- *node.Node) error) int {
+		c := make(chan *node.Node, 100)
+		if err := f(c); err != nil {
+			t.Fatal(err)
+		}
+		cnt := 0
+		for _ = range c {
+			cnt++
+		}
+		return cnt
+	}
+	countObjects := func(f func(chan<- *triple.Object) error) int {
+		c := make(chan *triple.Object, 100)
+		if err := f(c); err != nil {
+			t.Fatal(err)
+		}
+		cnt := 0
+		for _ = range c {
+			cnt++
+		}
+		return cnt
+	}
+	countPredicates := func(f func(chan<- *predicate.Predicate) error) int {
+		c := make(chan *predicate.Predicate, 100)
+		if err := f(c); err != nil {
+			t.Fatal(err)
+		}
+		cnt := 0
+		for _ = range c {
+			cnt++
+		}
+		return cnt
+	}
+	countTriples := func(f func(chan<- *triple.Triple) error) int {
+		c := make(chan *triple.Triple, 100)
+		if err := f(c); err != nil {
+			t.Fatal(err)
+		}
+		cnt := 0
+		for _ = range c {
+			cnt++
+		}
+		return cnt
+	}
+
+	lo := storage.DefaultLookup
+	table := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"Objects", countObjects(func(c chan<- *triple.Object) error { return g.Objects(ctx, john, knows, lo, c) }), 3},
+		{"Subjects", countNodes(func(c chan<- *node.Node) error { return g.Subjects(ctx, knows, alice, lo, c) }), 2},
+		{"PredicatesForSubject", countPredicates(func(c chan<- *predicate.Predicate) error { return g.PredicatesForSubject(ctx, mary, lo, c) }), 3},
+		{"PredicatesForObject", countPredicates(func(c chan<- *predicate.Predicate) error { return g.PredicatesForObject(ctx, alice, lo, c) }), 2},
+		{"PredicatesForSubjectAndObject", countPredicates(func(c chan<- *predicate.Predicate) error {
+			return g.PredicatesForSubjectAndObject(ctx, john, alice, lo, c)
+		}), 1},
+		{"TriplesForSubject", countTriples(func(c chan<- *triple.Triple) error { return g.TriplesForSubject(ctx, john, lo, c) }), 3},
+		{"TriplesForPredicate", countTriples(func(c chan<- *triple.Triple) error { return g.TriplesForPredicate(ctx, knows, lo, c) }), 6},
+		{"TriplesForObject", countTriples(func(c chan<- *triple.Triple) error { return g.TriplesForObject(ctx, alice, lo, c) }), 2},
+		{"TriplesForSubjectAndPredicate", countTriples(func(c chan<- *triple.Triple) error {
+			return g.TriplesForSubjectAndPredicate(ctx, mary, knows, lo, c)
+		}), 3},
+		{"TriplesForPredicateAndObject", countTriples(func(c chan<- *triple.Triple) error {
+			return g.TriplesForPredicateAndObject(ctx, knows, alice, lo, c)
+		}), 2},
+		{"Triples", countTriples(func(c chan<- *triple.Triple) error { return g.Triples(ctx, lo, c) }), 6},
+		{"TriplesWithLimit", countTriples(func(c chan<- *triple.Triple) error {
+			return g.Triples(ctx, &storage.LookupOptions{MaxElements: 4}, c)
+		}), 4},
+	}
+	for _, entry := range table {
+		if entry.got != entry.want {
+			t.Errorf("g.%s returned %d elements; want %d", entry.name, entry.got, entry.want)
+		}
+	}
+}
+
+func TestLookupStopsOnCancel(t *testing.T) {
+	s, _, done := newTestStore(t)
+	defer done()
+	ts := getTestTriples(t)
+	g := newTestGraph(t, s, ts)
+	ctx, cancel := context.WithCancel(context.Background())
+	// Nobody reads from the channel, hence only the cancelation can unblock
+	// the lookup.
+	trpls, errs := make(chan *triple.Triple), make(chan error, 1)
+	go func() {
+		errs <- g.Triples(ctx, storage.DefaultLookup, trpls)
+	}()
+	cancel()
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Errorf("g.Triples should have returned an error once the context was canceled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("g.Triples failed to stop once the context was canceled")
+	}
+	if err := g.RemoveTriples(context.Background(), ts); err != nil {
+		t.Errorf("g.RemoveTriples failed after canceling a lookup with error %v", err)
+	}
+}
+
+func mustParse(t string) *time.Time {
+	r, err := time.Parse(time.RFC3339Nano, t)
+	if err != nil {
+		panic(err)
+	}
+	return &r
+}
+
+func TestTriplesForObjectWithLimit(t *testing.T) {
+	s, _, done := newTestStore(t)
+	defer done()
+	ts := createTriples(t, []string{
+		"/u<bob>\t\"kissed\"@[2015-01-01T00:00:00-09:00]\t/u<mary>",
+		"/u<bob>\t\"kissed\"@[2015-02-01T00:00:00-09:00]\t/u<mary>",
+		"/u<bob>\t\"kissed\"@[2015-03-01T00:00:00-09:00]\t/u<mary>",
+		"/u<bob>\t\"kissed\"@[2015-04-01T00:00:00-09:00]\t/u<mary>",
+		"/u<bob>\t\"kissed\"@[2015-05-01T00:00:00-09:00]\t/u<mary>",
+		"/u<bob>\t\"kissed\"@[2015-06-01T00:00:00-09:00]\t/u<mary>",
+	})
+	ctx := context.Background()
+	g := newTestGraph(t, s, ts)
+	trpls := make(chan *triple.Triple, 100)
+	lo := &storage.LookupOptions{
+		MaxElements: 2,
+		LowerAnchor: mustParse("2015-04-01T00:00:00-08:00"),
+		UpperAnchor: mustParse("2015-06-01T00:00:00-10:00"),
+	}
+	if err := g.TriplesForObject(ctx, ts[0].Object(), lo, trpls); err != nil {
+		t.Errorf("g.TriplesForObject(%s) failed with error %v", ts[0].Object(), err)
+	}
+	cnt := 0
+	for tr := range trpls {
+		ta, err := tr.Predicate().TimeAnchor()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if ta.Before(*lo.LowerAnchor) || ta.After(*lo.UpperAnchor) {
+			t.Errorf("g.TriplesForObject(%s) unexpected triple receved: %s", ts[0].Object(), tr)
+		}
+		cnt++
+	}
+	if cnt != lo.MaxElements {
+		t.Errorf("g.TriplesForObject(%s) failed to retrieve 2 triples, got %d instead", ts[0].Object(), cnt)
+	}
+}
+
+func TestGeneration(t *testing.T) {
+	s, _, done := newTestStore(t)
+	defer done()
+	ctx := context.Background()
+	gen := func() uint64 {
+		g, err := s.Generation(ctx)
+		if err != nil {
+			t.Fatalf("redisStore.Generation failed with error %v", err)
+		}
+		return g
+	}
+	g0 := gen()
+	if got := gen(); got != g0 {
+		t.Errorf("redisStore.Generation should not change without mutations; got %d, want %d", got, g0)
+	}
+	g, err := s.NewGraph(ctx, "?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g1 := gen()
+	if g1 == g0 {
+		t.Errorf("redisStore.Generation should change after creating a graph")
+	}
+	if err := g.AddTriples(ctx, getTestTriples(t)); err != nil {
+		t.Fatal(err)
+	}
+	g2 := gen()
+	if g2 == g1 {
+		t.Errorf("redisStore.Generation should change after adding triples")
+	}
+	if err := g.RemoveTriples(ctx, getTestTriples(t)); err != nil {
+		t.Fatal(err)
+	}
+	g3 := gen()
+	if g3 == g2 {
+		t.Errorf("redisStore.Generation should change after removing triples")
+	}
+	if err := s.DeleteGraph(ctx, "?test"); err != nil {
+		t.Fatal(err)
+	}
+	if got := gen(); got == g3 {
+		t.Errorf("redisStore.Generation should change after deleting a graph")
+	}
+}
+
+// getAnchoredTestTriples returns triples mixing immutable and temporal
+// predicates sharing their IDs across several subjects and objects. Some
+// anchors only differ in their fraction of second, and the whole graph spans
+// more than one page.
+func getAnchoredTestTriples(t *testing.T) []*triple.Triple {
+	var ss []string
+	for _, s := range []string{"/u<bob>", "/u<alice>"} {
+		for _, o := range []string{"/u<mary>", "/u<kim>"} {
+			ss = append(ss, fmt.Sprintf("%s\t\"knows\"@[]\t%s", s, o))
+			for m := 1; m <= 12; m++ {
+				ss = append(ss, fmt.Sprintf("%s\t\"kissed\"@[2015-%02d-01T00:00:00-09:00]\t%s", s, m, o))
+				ss = append(ss, fmt.Sprintf("%s\t\"kissed\"@[2015-%02d-01T00:00:00.5-09:00]\t%s", s, m, o))
+				ss = append(ss, fmt.Sprintf("%s\t\"met\"@[2015-%02d-15T00:00:00-09:00]\t%s", s, m, o))
+			}
+		}
+	}
+	// Same ID, immutable and temporal.
+	ss = append(ss, "/u<bob>\t\"met\"@[]\t/u<mary>")
+	return createTriples(t, ss)
+}
+
+// sortedStrings collects and sorts the string representation of the values
+// returned by the provided lookup.
+func sortedStrings(t *testing.T, f func(chan<- fmt.Stringer) error) []string {
+	c := make(chan fmt.Stringer, 1000)
+	if err := f(c); err != nil {
+		t.Fatal(err)
+	}
+	var res []string
+	for v := range c {
+		res = append(res, v.String())
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestAnchoredLookupsMatchMemoryStore(t *testing.T) {
+	s, _, done := newTestStore(t)
+	defer done()
+	ts, ctx := getAnchoredTestTriples(t), context.Background()
+	rg := newTestGraph(t, s, ts)
+	mg, err := memory.NewStore().NewGraph(ctx, "?test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mg.AddTriples(ctx, ts); err != nil {
+		t.Fatal(err)
+	}
+	bob, mary := ts[0].Subject(), ts[0].Object()
+	bounds := []*storage.LookupOptions{
+		{},
+		{LowerAnchor: mustParse("2015-03-01T00:00:00-09:00")},
+		{LowerAnchor: mustParse("2015-03-01T00:00:00.2-09:00")},
+		{UpperAnchor: mustParse("2015-03-01T00:00:00-09:00")},
+		{UpperAnchor: mustParse("2015-03-01T00:00:00.7-09:00")},
+		{LowerAnchor: mustParse("2015-02-15T00:00:00-09:00"), UpperAnchor: mustParse("2015-04-01T02:00:00-07:00")},
+		{LowerAnchor: mustParse("2015-03-02T00:00:00-09:00"), UpperAnchor: mustParse("2015-03-03T00:00:00-09:00")},
+		{LowerAnchor: mustParse("2016-01-01T00:00:00Z")},
+		{UpperAnchor: mustParse("2014-01-01T00:00:00Z")},
+		{LowerAnchor: mustParse("2015-01-01T00:00:00Z"), UpperAnchor: mustParse("2015-12-01T00:00:00Z"), MaxElements: 3},
+		{LatestOnly: true},
+		{UpperAnchor: mustParse("2015-03-01T00:00:00-09:00"), LatestOnly: true},
+	}
+	lookups := []struct {
+		name string
+		f    func(g storage.Graph, lo *storage.LookupOptions) func(chan<- fmt.Stringer) error
+	}{
+		{"TriplesForSubject", func(g storage.Graph, lo *storage.LookupOptions) func(chan<- fmt.Stringer) error {
+			return func(c chan<- fmt.Stringer) error {
+				return forwardTriples(c, func(trpls chan<- *triple.Triple) error {
+					return g.TriplesForSubject(ctx, bob, lo, trpls)
+				})
+			}
+		}},
+		{"TriplesForObject", func(g storage.Graph, lo *storage.LookupOptions) func(chan<- fmt.Stringer) error {
+			return func(c chan<- fmt.Stringer) error {
+				return forwardTriples(c, func(trpls chan<- *triple.Triple) error {
+					return g.TriplesForObject(ctx, mary, lo, trpls)
+				})
+			}
+		}},
+		{"Triples", func(g storage.Graph, lo *storage.LookupOptions) func(chan<- fmt.Stringer) error {
+			return func(c chan<- fmt.Stringer) error {
+				return forwardTriples(c, func(trpls chan<- *triple.Triple) error {
+					return g.Triples(ctx, lo, trpls)
+				})
+			}
+		}},
+		{"PredicatesForSubject", func(g storage.Graph, lo *storage.LookupOptions) func(chan<- fmt.Stringer) error {
+			return func(c chan<- fmt.Stringer) error {
+				return forwardPredicates(c, func(prds chan<- *predicate.Predicate) error {
+					return g.PredicatesForSubject(ctx, bob, lo, prds)
+				})
+			}
+		}},
+		{"PredicatesForObject", func(g storage.Graph, lo *storage.LookupOptions) func(chan<- fmt.Stringer) error {
+			return func(c chan<- fmt.Stringer) error {
+				return forwardPredicates(c, func(prds chan<- *predicate.Predicate) error {
+					return g.PredicatesForObject(ctx, mary, lo, prds)
+				})
+			}
+		}},
+		{"PredicatesForSubjectAndObject", func(g storage.Graph, lo *storage.LookupOptions) func(chan<- fmt.Stringer) error {
+			return func(c chan<- fmt.Stringer) error {
+				return forwardPredicates(c, func(prds chan<- *predicate.Predicate) error {
+					return g.PredicatesForSubjectAndObject(ctx, bob, mary, lo, prds)
+				})
+			}
+		}},
+	}
+	for _, l := range lookups {
+		for _, lo := range bounds {
+			got, want := sortedStrings(t, l.f(rg, lo)), sortedStrings(t, l.f(mg, lo))
+			if lo.MaxElements > 0 {
+				// Limited lookups may return any of the matching values.
+				if len(got) != len(want) {
+					t.Errorf("g.%s(%+v) returned %d values; want %d", l.name, lo, len(got), len(want))
+				}
+				continue
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("g.%s(%+v) returned\n%v\nwant\n%v", l.name, lo, got, want)
+			}
+		}
+	}
+}
+
+// forwardTriples forwards the triples returned by the provided lookup.
+func forwardTriples(c chan<- fmt.Stringer, f func(chan<- *triple.Triple) error) error {
+	defer close(c)
+	trpls, errc := make(chan *triple.Triple), make(chan error, 1)
+	go func() {
+		errc <- f(trpls)
+	}()
+	for t := range trpls {
+		c <- t
+	}
+	return <-errc
+}
+
+// forwardPredicates forwards the predicates returned by the provided lookup.
+func forwardPredicates(c chan<- fmt.Stringer, f func(chan<- *predicate.Predicate) error) error {
+	defer close(c)
+	prds, errc := make(chan *predicate.Predicate), make(chan error, 1)
+	go func() {
+		errc <- f(prds)
+	}()
+	for p := range prds {
+		c <- p
+	}
+	return <-errc
+}
+
+func TestLookupsSpanSeveralPages(t *testing.T) {
+	s, _, done := newTestStore(t)
+	defer done()
+	ctx := context.Background()
+	n, err := node.Parse("/l<barcelona>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ts []*triple.Triple
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 350; i++ {
+		p, err := predicate.NewTemporal("turned", start.Add(time.Duration(i)*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr, err := triple.New(n, p, triple.NewNodeObject(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts = append(ts, tr)
+	}
+	g := newTestGraph(t, s, ts)
+	lower, upper := start.Add(20*time.Hour), start.Add(270*time.Hour)
+	table := []struct {
+		lo   *storage.LookupOptions
+		want int
+	}{
+		{lo: storage.DefaultLookup, want: 350},
+		{lo: &storage.LookupOptions{LowerAnchor: &lower}, want: 330},
+		{lo: &storage.LookupOptions{LowerAnchor: &lower, UpperAnchor: &upper}, want: 251},
+		{lo: &storage.LookupOptions{LowerAnchor: &lower, MaxElements: 150}, want: 150},
+	}
+	for _, entry := range table {
+		for name, f := range map[string]func(chan<- *triple.Triple) error{
+			"Triples":           func(c chan<- *triple.Triple) error { return g.Triples(ctx, entry.lo, c) },
+			"TriplesForSubject": func(c chan<- *triple.Triple) error { return g.TriplesForSubject(ctx, n, entry.lo, c) },
+			"TriplesForObject":  func(c chan<- *triple.Triple) error { return g.TriplesForObject(ctx, ts[0].Object(), entry.lo, c) },
+		} {
+			trpls := make(chan *triple.Triple, 1000)
+			if err := f(trpls); err != nil {
+				t.Fatal(err)
+			}
+			cnt := 0
+			for _ = range trpls {
+				cnt++
+			}
+			if cnt != entry.want {
+				t.Errorf("g.%s(%+v) returned %d triples; want %d", name, entry.lo, cnt, entry.want)
+			}
+		}
+	}
+}
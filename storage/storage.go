@@ -17,6 +17,8 @@ package storage
 
 import (
 	"bytes"
+	"fmt"
+	"sort"
 	"strconv"
 	"time"
 
@@ -37,6 +39,14 @@ type LookupOptions struct {
 
 	// UpperAnchor, if provided, represents the upper time anchor to be considered.
 	UpperAnchor *time.Time
+
+	// LatestOnly, if set, only returns the latest version of each triple
+	// within the time anchors. Versions of a triple share the subject, the
+	// predicate ID, and the object, and only differ in their time anchor. The
+	// anchor of the object is used instead when the predicate is immutable and
+	// the object is a temporal predicate, and it also needs to be within the
+	// time anchors. Other triples are always returned.
+	LatestOnly bool
 }
 
 // String returns a readable version of the LookupOptions instance.
@@ -55,6 +65,9 @@ func (l *LookupOptions) String() string {
 	} else {
 		b.WriteString("nil")
 	}
+	if l.LatestOnly {
+		b.WriteString(", latest_only")
+	}
 	b.WriteString(">")
 	return b.String()
 }
@@ -62,6 +75,274 @@ func (l *LookupOptions) String() string {
 // DefaultLookup provides the default lookup behavior.
 var DefaultLookup = &LookupOptions{}
 
+// InsertOptions allows to specify the behavior of the insert operations.
+type InsertOptions struct {
+	// KeepLatest lists the IDs of the predicates configured as "keep latest".
+	// For a given subject, predicate ID, and object, only the triple with the
+	// newest time anchor is retained and older ones are removed. Immutable
+	// predicates are unaffected.
+	KeepLatest map[predicate.ID]bool
+}
+
+// KeepsLatest returns true if only the latest triple should be kept for the
+// provided predicate.
+func (i *InsertOptions) KeepsLatest(p *predicate.Predicate) bool {
+	return i != nil && p.Type() == predicate.Temporal && i.KeepLatest[p.ID()]
+}
+
+// DefaultInsert provides the default insert behavior.
+var DefaultInsert = &InsertOptions{}
+
+// OptionsInserter is an optional interface that graphs can implement to honor
+// insert options.
+type OptionsInserter interface {
+	// AddTriplesWithOptions adds the triples to the storage honoring the
+	// provided insert options. The removal of superseded triples must be
+	// atomic with the insertion. It returns the number of stored triples
+	// removed because they were superseded. Incoming triples dropped because a
+	// newer one is already stored are not counted.
+	AddTriplesWithOptions(ctx context.Context, ts []*triple.Triple, opts *InsertOptions) (int, error)
+}
+
+// BulkLoader is an optional interface that graphs can implement to defer the
+// maintenance of their secondary indexes while importing triples, and build
+// them once the import is done.
+type BulkLoader interface {
+	// AddTriplesDeferringIndexes adds the triples to the primary storage of the
+	// graph without updating its secondary indexes. Lookups issued before the
+	// indexes are rebuilt must either fail or rebuild them first.
+	AddTriplesDeferringIndexes(ctx context.Context, ts []*triple.Triple) error
+
+	// RebuildIndexes builds all the secondary indexes of the graph in one
+	// batch out of its primary storage.
+	RebuildIndexes(ctx context.Context) error
+}
+
+// TriplesPager is an optional interface that graphs can implement to return
+// their triples one page at a time instead of streaming all of them.
+type TriplesPager interface {
+	// TriplesPage returns up to max triples matching the lookup options along
+	// with the token to retrieve the next page. An empty page token returns
+	// the first page, and an empty next token signals the last page. Tokens
+	// are opaque and encode a position in a stable order of the triples
+	// instead of a snapshot of the graph. Hence, tokens remain valid while the
+	// graph is mutated, pages never return triples already returned on
+	// earlier pages, and triples added or removed while paging are only
+	// reflected on the pages after their position.
+	TriplesPage(ctx context.Context, lo *LookupOptions, pageToken string, max int) ([]*triple.Triple, string, error)
+}
+
+// PredicateCounter is an optional interface that graphs can implement to count
+// the triples of each predicate out of their indexes instead of scanning all
+// the triples.
+type PredicateCounter interface {
+	// PredicateCounts returns the number of triples for each predicate in the
+	// graph.
+	PredicateCounts(ctx context.Context) ([]*PredicateCount, error)
+}
+
+// PredicateCount contains the number of triples of a predicate.
+type PredicateCount struct {
+	P     *predicate.Predicate
+	Count int
+}
+
+// CardinalityEstimator is an optional interface that graphs can implement to
+// estimate the number of triples matching a pattern out of their indexes
+// without retrieving them. The planner uses the estimates to order the clauses
+// of a query.
+type CardinalityEstimator interface {
+	// Cardinality returns the estimated number of triples matching the
+	// provided lookup.
+	Cardinality(ctx context.Context, lookup *CardinalityLookup) (int, error)
+}
+
+// CardinalityLookup describes the fixed parts of a triple pattern. Nil values
+// match any value. If the predicate is nil but the predicate ID is not empty,
+// the lookup matches all the predicates with that ID regardless of their time
+// anchor.
+type CardinalityLookup struct {
+	S   *node.Node
+	P   *predicate.Predicate
+	PID string
+	O   *triple.Object
+}
+
+// LatestLister is an optional interface implemented by graphs keeping a time
+// index of their temporal triples. Graphs implementing it return the newest
+// triples of each subject without scanning all of them.
+type LatestLister interface {
+	// LatestTriples returns, for each subject, the n triples of the predicate
+	// ID with the newest time anchors within the lookup options anchors.
+	// Immutable triples are ignored. Triples are sorted by subject, newest
+	// first within each subject; triples sharing the same subject and time
+	// anchor are sorted by object.
+	LatestTriples(ctx context.Context, id string, n int, lo *LookupOptions) ([]*triple.Triple, error)
+}
+
+// GenerationCounter is an optional interface that stores can implement to
+// expose a counter bumped on any mutation of the store or its graphs. The
+// generation never decreases, and two calls returning the same generation
+// guarantee that no data changed between them.
+type GenerationCounter interface {
+	// Generation returns the current generation of the store.
+	Generation(ctx context.Context) (uint64, error)
+}
+
+// ReadOnlyMarker is an optional interface that stores can implement to mark
+// graphs as read-only. Adding or removing triples of a read-only graph, or
+// deleting it, must fail without changing any triple.
+type ReadOnlyMarker interface {
+	// SetReadOnly marks the graph as read-only, or writable again if ro is
+	// false. Marking a non existing graph should return an error.
+	SetReadOnly(ctx context.Context, id string, ro bool) error
+
+	// ReadOnly returns true if the graph is marked as read-only. Checking a
+	// non existing graph should return an error.
+	ReadOnly(ctx context.Context, id string) (bool, error)
+}
+
+// GraphMetadataStore is an optional interface that stores can implement to
+// attach key/value metadata to their graphs, such as their owner, creation
+// time, or description. The metadata is removed along with the graph.
+type GraphMetadataStore interface {
+	// SetGraphMetadata sets the value of the metadata key of the graph. An
+	// empty value removes the key. Setting the metadata of a non existing graph
+	// should return an error.
+	SetGraphMetadata(ctx context.Context, id, key, value string) error
+
+	// GraphMetadata returns a copy of the metadata of the graph. Getting the
+	// metadata of a non existing graph should return an error.
+	GraphMetadata(ctx context.Context, id string) (map[string]string, error)
+}
+
+// GraphMetadata returns the metadata of the graph. Graphs of stores that do
+// not implement GraphMetadataStore have no metadata; getting the metadata of a
+// non existing graph fails regardless.
+func GraphMetadata(ctx context.Context, s Store, id string) (map[string]string, error) {
+	if gms, ok := s.(GraphMetadataStore); ok {
+		return gms.GraphMetadata(ctx, id)
+	}
+	if _, err := s.Graph(ctx, id); err != nil {
+		return nil, err
+	}
+	return map[string]string{}, nil
+}
+
+// GraphCloner is an optional interface that stores can implement to copy a
+// graph without streaming its triples.
+type GraphCloner interface {
+	// CloneGraph creates the dst graph holding a copy of all the triples of
+	// the src graph. Later mutations of either graph must not affect the
+	// other one. Cloning a non existing graph, or into an already existing
+	// one, should return an error without changing any graph.
+	CloneGraph(ctx context.Context, src, dst string) error
+}
+
+// CloneGraph creates the dst graph of the store holding a copy of all the
+// triples of the src graph. Stores that do not implement GraphCloner get the
+// triples of src retrieved first and then added to the newly created dst
+// graph, which is deleted again if adding the triples fails. Cloning into an
+// already existing graph fails without changing it.
+func CloneGraph(ctx context.Context, s Store, src, dst string) error {
+	if gc, ok := s.(GraphCloner); ok {
+		return gc.CloneGraph(ctx, src, dst)
+	}
+	sg, err := s.Graph(ctx, src)
+	if err != nil {
+		return err
+	}
+	ts, errc := make(chan *triple.Triple), make(chan error, 1)
+	go func() {
+		errc <- sg.Triples(ctx, DefaultLookup, ts)
+	}()
+	var trpls []*triple.Triple
+	for t := range ts {
+		trpls = append(trpls, t)
+	}
+	if err := <-errc; err != nil {
+		return err
+	}
+	dg, err := s.NewGraph(ctx, dst)
+	if err != nil {
+		return err
+	}
+	if err := dg.AddTriples(ctx, trpls); err != nil {
+		s.DeleteGraph(ctx, dst)
+		return fmt.Errorf("failed to clone graph %q into %q; %v", src, dst, err)
+	}
+	return nil
+}
+
+// GraphNames returns the names of the graphs of the store sorted
+// alphabetically.
+func GraphNames(ctx context.Context, s Store) ([]string, error) {
+	names, errc := make(chan string), make(chan error, 1)
+	go func() {
+		errc <- s.GraphNames(ctx, names)
+	}()
+	var res []string
+	for n := range names {
+		res = append(res, n)
+	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// GraphDescription summarizes the contents of a graph.
+type GraphDescription struct {
+	// Triples is the number of triples of the graph.
+	Triples int
+	// Predicates is the number of distinct predicate IDs of the graph. The
+	// versions of a temporal predicate share the same ID.
+	Predicates int
+	// Subjects is the number of distinct subjects of the graph.
+	Subjects int
+}
+
+// DescribeGraph returns the number of triples, distinct predicate IDs, and
+// distinct subjects of the graph. It streams all the triples of the graph once.
+func DescribeGraph(ctx context.Context, g Graph) (*GraphDescription, error) {
+	ts, errc := make(chan *triple.Triple), make(chan error, 1)
+	go func() {
+		errc <- g.Triples(ctx, DefaultLookup, ts)
+	}()
+	d := &GraphDescription{}
+	ps, ss := make(map[predicate.ID]bool), make(map[string]bool)
+	for t := range ts {
+		d.Triples++
+		ps[t.Predicate().ID()] = true
+		ss[t.Subject().String()] = true
+	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	d.Predicates, d.Subjects = len(ps), len(ss)
+	return d, nil
+}
+
+// TransactionalStore is an optional interface that stores can implement to
+// apply the mutations of several statements atomically. A store runs at most
+// one transaction at a time; Begin blocks until the running one is committed
+// or rolled back, or until ctx is done.
+type TransactionalStore interface {
+	// Begin starts a transaction that may mutate the provided graphs. Graphs
+	// that do not exist are ignored. The mutations of the transaction must be
+	// made with the returned context; other writers of the graphs block until
+	// the transaction ends.
+	Begin(ctx context.Context, graphs []string) (context.Context, error)
+
+	// Commit keeps the mutations made since Begin and ends the transaction.
+	Commit(ctx context.Context) error
+
+	// Rollback reverts the graphs provided to Begin to the triples they held
+	// when the transaction started and ends the transaction.
+	Rollback(ctx context.Context) error
+}
+
 // Store interface describes the low lever API that allows to create new graphs.
 type Store interface {
 	// Name returns the ID of the backend being used.
@@ -105,6 +386,11 @@ type Graph interface {
 	// are not present on the store should not fail.
 	RemoveTriples(ctx context.Context, ts []*triple.Triple) error
 
+	// CompareAndSwap atomically replaces the expected triple with the new one
+	// if, and only if, the expected triple is currently stored. It returns
+	// whether the swap happened; a missing expected triple is not an error.
+	CompareAndSwap(ctx context.Context, expected, new *triple.Triple) (bool, error)
+
 	// Objects pushes to the provided channel the objects for the given object and
 	// predicate. The function does not return immediately.
 	//
@@ -242,3 +528,580 @@ type Graph interface {
 	// elements in the channel.
 	Triples(ctx context.Context, lo *LookupOptions, trpls chan<- *triple.Triple) error
 }
+
+// TripleCounter is an optional interface that graphs can implement to count
+// the triples matching a pattern without retrieving them. Unlike the estimates
+// of CardinalityEstimator, counts are exact and honor the time anchor bounds
+// of the lookup options.
+type TripleCounter interface {
+	// Count returns the number of triples matching the provided lookup whose
+	// time anchors are within the lookup options anchors. Immutable triples
+	// always match the anchors. If the lookup options provide a max number of
+	// elements, the count is capped to it.
+	Count(ctx context.Context, lookup *CardinalityLookup, lo *LookupOptions) (int, error)
+}
+
+// TriplesExister is an optional interface that graphs can implement to check
+// the presence of many triples in a single call.
+type TriplesExister interface {
+	// ExistTriples returns, for each of the provided triples, whether it exists
+	// on the graph. Triples need to match on subject, predicate, including its
+	// time anchor, and object.
+	ExistTriples(ctx context.Context, ts []*triple.Triple) ([]bool, error)
+}
+
+// MatchRemover is an optional interface that graphs can implement to remove
+// all the triples matching a pattern in a single call.
+type MatchRemover interface {
+	// RemoveMatching removes the triples matching the provided lookup whose
+	// time anchors are within the lookup options anchors, and returns how many
+	// were removed. Immutable triples always match the anchors. The max number
+	// of elements of the lookup options is ignored.
+	RemoveMatching(ctx context.Context, lookup *CardinalityLookup, lo *LookupOptions) (int, error)
+}
+
+// GraphClearer is an optional interface that graphs can implement to remove
+// all their triples in a single call.
+type GraphClearer interface {
+	// Clear removes all the triples of the graph. The graph itself and its
+	// metadata are kept.
+	Clear(ctx context.Context) error
+}
+
+// OrphanMode selects which kind of orphan nodes OrphanNodes returns.
+type OrphanMode int8
+
+const (
+	// OrphanSubjects selects the nodes that appear only as subjects, hence they
+	// have no incoming edges.
+	OrphanSubjects OrphanMode = iota
+	// OrphanObjects selects the nodes that appear only as objects, hence they
+	// have no outgoing edges.
+	OrphanObjects
+)
+
+// String returns a readable version of the orphan mode.
+func (m OrphanMode) String() string {
+	switch m {
+	case OrphanSubjects:
+		return "subjects"
+	case OrphanObjects:
+		return "objects"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// OrphanNodes returns the nodes of the graph that appear only as subjects or
+// only as objects depending on the provided mode. It streams all the triples of
+// the graph once. Nodes are returned sorted by their string representation.
+func OrphanNodes(ctx context.Context, g Graph, m OrphanMode) ([]*node.Node, error) {
+	if m != OrphanSubjects && m != OrphanObjects {
+		return nil, fmt.Errorf("storage.OrphanNodes: unknown orphan mode %d", m)
+	}
+	ts, errc := make(chan *triple.Triple), make(chan error, 1)
+	go func() {
+		errc <- g.Triples(ctx, DefaultLookup, ts)
+	}()
+	subs, objs := make(map[string]*node.Node), make(map[string]*node.Node)
+	for t := range ts {
+		s := t.Subject()
+		subs[s.String()] = s
+		if o, err := t.Object().Node(); err == nil {
+			objs[o.String()] = o
+		}
+	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	in, out := subs, objs
+	if m == OrphanObjects {
+		in, out = objs, subs
+	}
+	var ks []string
+	for k := range in {
+		if _, ok := out[k]; !ok {
+			ks = append(ks, k)
+		}
+	}
+	sort.Strings(ks)
+	var res []*node.Node
+	for _, k := range ks {
+		res = append(res, in[k])
+	}
+	return res, nil
+}
+
+// Diff returns the triples that need to be added to and removed from graph a
+// to make it hold the same triples as graph b. Only the triples satisfying the
+// provided lookup options are compared. Triples are equal only if they share
+// the same subject, predicate ID, time anchor, and object. Only the UUIDs of
+// the triples of graph a are kept while graph b is streamed, and then graph a
+// is streamed again to collect the triples missing from b; hence, neither
+// graph is fully held in memory besides the differences found. The graphs
+// should not be mutated while computing the difference. Both returned slices
+// are sorted by the triple string representation.
+func Diff(ctx context.Context, a, b Graph, lo *LookupOptions) ([]*triple.Triple, []*triple.Triple, error) {
+	stream := func(g Graph, f func(*triple.Triple)) error {
+		ts, errc := make(chan *triple.Triple), make(chan error, 1)
+		go func() {
+			errc <- g.Triples(ctx, lo, ts)
+		}()
+		for t := range ts {
+			f(t)
+		}
+		return <-errc
+	}
+	// inA tracks whether each triple of a was also found in b.
+	inA := make(map[string]bool)
+	if err := stream(a, func(t *triple.Triple) {
+		inA[string(t.UUID())] = false
+	}); err != nil {
+		return nil, nil, err
+	}
+	var toAdd []*triple.Triple
+	if err := stream(b, func(t *triple.Triple) {
+		k := string(t.UUID())
+		if _, ok := inA[k]; ok {
+			inA[k] = true
+			return
+		}
+		toAdd = append(toAdd, t)
+	}); err != nil {
+		return nil, nil, err
+	}
+	var toRemove []*triple.Triple
+	if err := stream(a, func(t *triple.Triple) {
+		if !inA[string(t.UUID())] {
+			toRemove = append(toRemove, t)
+		}
+	}); err != nil {
+		return nil, nil, err
+	}
+	sort.Sort(triplesByString(toAdd))
+	sort.Sort(triplesByString(toRemove))
+	return toAdd, toRemove, nil
+}
+
+// triplesByString sorts triples by their string representation.
+type triplesByString []*triple.Triple
+
+func (ts triplesByString) Len() int           { return len(ts) }
+func (ts triplesByString) Less(i, j int) bool { return ts[i].String() < ts[j].String() }
+func (ts triplesByString) Swap(i, j int)      { ts[i], ts[j] = ts[j], ts[i] }
+
+// LatestTriples returns, for each subject, the n triples of the predicate ID
+// with the newest time anchors within the lookup options anchors. Graphs
+// implementing LatestLister answer out of their time index; all the triples of
+// any other graph are scanned. The returned triples are sorted as
+// LatestLister.LatestTriples describes.
+func LatestTriples(ctx context.Context, g Graph, id string, n int, lo *LookupOptions) ([]*triple.Triple, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("storage.LatestTriples: the number of triples per subject should be positive; got %d", n)
+	}
+	if ll, ok := g.(LatestLister); ok {
+		return ll.LatestTriples(ctx, id, n, lo)
+	}
+	ts, errc := make(chan *triple.Triple), make(chan error, 1)
+	go func() {
+		errc <- g.Triples(ctx, lo, ts)
+	}()
+	var res []*triple.Triple
+	for t := range ts {
+		if p := t.Predicate(); p.Type() == predicate.Temporal && string(p.ID()) == id {
+			res = append(res, t)
+		}
+	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	return KeepLatest(res, n), nil
+}
+
+// InTimeRange returns true if the time anchor of the provided predicate is
+// within the lookup options anchors. Immutable predicates are always in range.
+func (l *LookupOptions) InTimeRange(p *predicate.Predicate) bool {
+	if p.Type() == predicate.Immutable {
+		return true
+	}
+	t, err := p.TimeAnchor()
+	if err != nil {
+		return false
+	}
+	if l.LowerAnchor != nil && t.Before(*l.LowerAnchor) {
+		return false
+	}
+	if l.UpperAnchor != nil && t.After(*l.UpperAnchor) {
+		return false
+	}
+	return true
+}
+
+// Matches returns true if the provided triple matches the fixed parts of the
+// lookup.
+func (l *CardinalityLookup) Matches(t *triple.Triple) bool {
+	if l.S != nil && l.S.String() != t.Subject().String() {
+		return false
+	}
+	if l.P != nil && l.P.String() != t.Predicate().String() {
+		return false
+	}
+	if l.P == nil && l.PID != "" && string(t.Predicate().ID()) != l.PID {
+		return false
+	}
+	if l.O != nil && l.O.String() != t.Object().String() {
+		return false
+	}
+	return true
+}
+
+// CountTriples returns the number of triples of the graph matching the
+// provided lookup within the lookup options anchors. Graphs implementing
+// TripleCounter count them out of their indexes; the matching triples of any
+// other graph are retrieved and counted. The count is capped to the max number
+// of elements of the lookup options if provided.
+func CountTriples(ctx context.Context, g Graph, lookup *CardinalityLookup, lo *LookupOptions) (int, error) {
+	if tc, ok := g.(TripleCounter); ok {
+		return tc.Count(ctx, lookup, lo)
+	}
+	cnt := 0
+	if err := forEachMatch(ctx, g, lookup, lo, func(*triple.Triple) { cnt++ }); err != nil {
+		return 0, err
+	}
+	if lo.MaxElements > 0 && cnt > lo.MaxElements {
+		cnt = lo.MaxElements
+	}
+	return cnt, nil
+}
+
+// RemoveMatching removes the triples of the graph matching the provided lookup
+// within the lookup options anchors, and returns how many were removed. Graphs
+// implementing MatchRemover remove them in a single call; the matching triples
+// of any other graph are retrieved first and then removed with RemoveTriples.
+// The max number of elements of the lookup options is ignored.
+func RemoveMatching(ctx context.Context, g Graph, lookup *CardinalityLookup, lo *LookupOptions) (int, error) {
+	if mr, ok := g.(MatchRemover); ok {
+		return mr.RemoveMatching(ctx, lookup, lo)
+	}
+	var ts []*triple.Triple
+	if err := forEachMatch(ctx, g, lookup, lo, func(t *triple.Triple) { ts = append(ts, t) }); err != nil {
+		return 0, err
+	}
+	if len(ts) == 0 {
+		return 0, nil
+	}
+	if err := g.RemoveTriples(ctx, ts); err != nil {
+		return 0, err
+	}
+	return len(ts), nil
+}
+
+// ClearGraph removes all the triples of the graph, keeping the graph and its
+// metadata. Graphs implementing GraphClearer are cleared in a single call; the
+// triples of any other graph are removed as if matching an empty lookup using
+// RemoveMatching.
+func ClearGraph(ctx context.Context, g Graph) error {
+	if gc, ok := g.(GraphClearer); ok {
+		return gc.Clear(ctx)
+	}
+	_, err := RemoveMatching(ctx, g, &CardinalityLookup{}, DefaultLookup)
+	return err
+}
+
+// forEachMatch retrieves the triples of the graph matching the provided lookup
+// within the lookup options anchors and calls f for each of them. The matches
+// are filtered once retrieved, hence the max number of elements of the lookup
+// options is ignored.
+func forEachMatch(ctx context.Context, g Graph, lookup *CardinalityLookup, lo *LookupOptions, f func(*triple.Triple)) error {
+	nlo := *lo
+	nlo.MaxElements = 0
+	ts, errc := make(chan *triple.Triple), make(chan error, 1)
+	go func() {
+		switch {
+		case lookup.S != nil && lookup.P != nil:
+			errc <- g.TriplesForSubjectAndPredicate(ctx, lookup.S, lookup.P, &nlo, ts)
+		case lookup.P != nil && lookup.O != nil:
+			errc <- g.TriplesForPredicateAndObject(ctx, lookup.P, lookup.O, &nlo, ts)
+		case lookup.P != nil:
+			errc <- g.TriplesForPredicate(ctx, lookup.P, &nlo, ts)
+		case lookup.S != nil:
+			errc <- g.TriplesForSubject(ctx, lookup.S, &nlo, ts)
+		case lookup.O != nil:
+			errc <- g.TriplesForObject(ctx, lookup.O, &nlo, ts)
+		default:
+			errc <- g.Triples(ctx, &nlo, ts)
+		}
+	}()
+	for t := range ts {
+		if lookup.Matches(t) && nlo.InTimeRange(t.Predicate()) {
+			f(t)
+		}
+	}
+	return <-errc
+}
+
+// ExistTriples returns, for each of the provided triples, whether it exists on
+// the graph. Graphs implementing TriplesExister check all of them in a single
+// call; any other graph is checked one triple at a time.
+func ExistTriples(ctx context.Context, g Graph, ts []*triple.Triple) ([]bool, error) {
+	if te, ok := g.(TriplesExister); ok {
+		return te.ExistTriples(ctx, ts)
+	}
+	res := make([]bool, len(ts))
+	for i, t := range ts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		b, err := g.Exist(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = b
+	}
+	return res, nil
+}
+
+// KeepLatest sorts the temporal triples as LatestLister.LatestTriples
+// describes and keeps the n newest triples of each subject. Duplicated triples
+// are only kept once. It allows merging the latest triples of several graphs.
+func KeepLatest(ts []*triple.Triple, n int) []*triple.Triple {
+	sort.Sort(latestFirst(ts))
+	var (
+		res  []*triple.Triple
+		last string
+		cnt  int
+	)
+	for i, t := range ts {
+		if i > 0 && string(ts[i-1].UUID()) == string(t.UUID()) {
+			continue
+		}
+		if s := t.Subject().String(); s != last {
+			last, cnt = s, 0
+		}
+		if cnt < n {
+			res = append(res, t)
+			cnt++
+		}
+	}
+	return res
+}
+
+// latestFirst sorts temporal triples by subject, newest first within each
+// subject, and by object if they share the same time anchor.
+type latestFirst []*triple.Triple
+
+func (ts latestFirst) Len() int      { return len(ts) }
+func (ts latestFirst) Swap(i, j int) { ts[i], ts[j] = ts[j], ts[i] }
+func (ts latestFirst) Less(i, j int) bool {
+	si, sj := ts[i].Subject().String(), ts[j].Subject().String()
+	if si != sj {
+		return si < sj
+	}
+	ti, _ := ts[i].Predicate().TimeAnchor()
+	tj, _ := ts[j].Predicate().TimeAnchor()
+	if !ti.Equal(*tj) {
+		return ti.After(*tj)
+	}
+	return ts[i].Object().String() < ts[j].Object().String()
+}
+
+// versionKey returns the key shared by all the versions of the provided
+// triple and the time anchor of the version. It returns false if the triple
+// has no time anchor.
+func versionKey(t *triple.Triple) (string, time.Time, bool) {
+	p := t.Predicate()
+	if ta, err := p.TimeAnchor(); err == nil {
+		return t.Subject().String() + "\t" + string(p.ID()) + "\t" + t.Object().String(), *ta, true
+	}
+	if op, err := t.Object().Predicate(); err == nil && op.Type() == predicate.Temporal {
+		ta, _ := op.TimeAnchor()
+		return t.Subject().String() + "\t" + p.String() + "\t" + string(op.ID()), *ta, true
+	}
+	return "", time.Time{}, false
+}
+
+// LatestVersions returns the provided triples keeping only the latest version
+// of each of them within the lookup options anchors, as LookupOptions.LatestOnly
+// describes. Triples keep the position of the first version provided, and
+// triples without time anchors are always kept.
+func LatestVersions(ts []*triple.Triple, lo *LookupOptions) []*triple.Triple {
+	var (
+		res     []*triple.Triple
+		anchors []time.Time
+		pos     = make(map[string]int)
+	)
+	for _, t := range ts {
+		k, ta, ok := versionKey(t)
+		if !ok {
+			res, anchors = append(res, t), append(anchors, time.Time{})
+			continue
+		}
+		if (lo.LowerAnchor != nil && ta.Before(*lo.LowerAnchor)) || (lo.UpperAnchor != nil && ta.After(*lo.UpperAnchor)) {
+			continue
+		}
+		i, ok := pos[k]
+		switch {
+		case !ok:
+			pos[k] = len(res)
+			res, anchors = append(res, t), append(anchors, ta)
+		case ta.After(anchors[i]):
+			res[i], anchors[i] = t, ta
+		}
+	}
+	return res
+}
+
+// ScanLatest allows drivers streaming triples to resolve lookups with
+// LatestOnly set. It calls scan with the same lookup options without the flag
+// and the maximum number of elements, and then provides f with the latest
+// versions of the scanned triples, up to the maximum number of elements.
+func ScanLatest(lo *LookupOptions, scan func(*LookupOptions, func(*triple.Triple) error) error, f func(*triple.Triple) error) error {
+	nlo := *lo
+	nlo.MaxElements, nlo.LatestOnly = 0, false
+	var ts []*triple.Triple
+	if err := scan(&nlo, func(t *triple.Triple) error {
+		ts = append(ts, t)
+		return nil
+	}); err != nil {
+		return err
+	}
+	for i, t := range LatestVersions(ts, lo) {
+		if lo.MaxElements > 0 && i >= lo.MaxElements {
+			break
+		}
+		if err := f(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplicateOptions allows to specify how a graph is replicated.
+type ReplicateOptions struct {
+	// PollInterval is the time waited between checks for changes on the
+	// source store.
+	PollInterval time.Duration
+
+	// Retries is the number of times a failed write to the destination graph
+	// is retried before replication stops.
+	Retries int
+
+	// RetryDelay is the time waited before the first retry of a failed write.
+	// The delay is doubled after each retry.
+	RetryDelay time.Duration
+}
+
+// DefaultReplicateOptions provides the default replication behavior.
+var DefaultReplicateOptions = &ReplicateOptions{
+	PollInterval: time.Second,
+	Retries:      5,
+	RetryDelay:   100 * time.Millisecond,
+}
+
+// Replicate keeps the graph of the destination store in sync with the graph of
+// the source store using the default replication options. See
+// ReplicateWithOptions for details.
+func Replicate(ctx context.Context, src, dst Store, graph string) error {
+	return ReplicateWithOptions(ctx, src, dst, graph, DefaultReplicateOptions)
+}
+
+// ReplicateWithOptions copies the graph of the source store to the destination
+// store, creating it if needed, and then keeps propagating the changes of the
+// source graph until the context is done. Changes are detected by polling the
+// source store. Stores implementing GenerationCounter are only compared when
+// their generation changes, while other stores are compared on every poll.
+//
+// Each round uses Diff to make the destination graph hold the same triples as
+// the source graph, hence the destination is eventually consistent with the
+// source. Mutations made while a round runs bump the generation and are picked
+// up by the next round, and triples added to the destination graph by other
+// writers are removed. The initial copy into a newly created graph uses bulk
+// loading if the destination graph implements BulkLoader.
+//
+// Adding and removing triples is idempotent, so failed writes to the
+// destination graph are retried as indicated by the options. Replication
+// stops and returns the error once the retries are exhausted; otherwise, it
+// returns the context error once the context is done.
+func ReplicateWithOptions(ctx context.Context, src, dst Store, graph string, o *ReplicateOptions) error {
+	sg, err := src.Graph(ctx, graph)
+	if err != nil {
+		return err
+	}
+	created := false
+	dg, err := dst.Graph(ctx, graph)
+	if err != nil {
+		if dg, err = dst.NewGraph(ctx, graph); err != nil {
+			return err
+		}
+		created = true
+	}
+	gc, counted := src.(GenerationCounter)
+	var last uint64
+	for first := true; ; first = false {
+		changed := true
+		if counted {
+			gen, err := gc.Generation(ctx)
+			if err != nil {
+				return err
+			}
+			changed, last = first || gen != last, gen
+		}
+		if changed {
+			if err := syncGraph(ctx, sg, dg, first && created, o); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(o.PollInterval):
+		}
+	}
+}
+
+// syncGraph makes the destination graph hold the same triples as the source
+// graph. If bulk is true the missing triples are bulk loaded when possible.
+func syncGraph(ctx context.Context, src, dst Graph, bulk bool, o *ReplicateOptions) error {
+	toAdd, toRemove, err := Diff(ctx, dst, src, DefaultLookup)
+	if err != nil {
+		return err
+	}
+	if bl, ok := dst.(BulkLoader); ok && bulk && len(toRemove) == 0 {
+		return retryWrite(ctx, o, func() error {
+			if err := bl.AddTriplesDeferringIndexes(ctx, toAdd); err != nil {
+				return err
+			}
+			return bl.RebuildIndexes(ctx)
+		})
+	}
+	if len(toRemove) > 0 {
+		if err := retryWrite(ctx, o, func() error { return dst.RemoveTriples(ctx, toRemove) }); err != nil {
+			return err
+		}
+	}
+	if len(toAdd) > 0 {
+		return retryWrite(ctx, o, func() error { return dst.AddTriples(ctx, toAdd) })
+	}
+	return nil
+}
+
+// retryWrite calls the provided write function until it succeeds or the
+// retries of the options are exhausted, doubling the delay between attempts.
+func retryWrite(ctx context.Context, o *ReplicateOptions, f func() error) error {
+	d := o.RetryDelay
+	for i := 0; ; i++ {
+		err := f()
+		if err == nil {
+			return nil
+		}
+		if i >= o.Retries {
+			return fmt.Errorf("failed to write to the replica after %d retries; %v", o.Retries, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+		d *= 2
+	}
+}
@@ -101,16 +101,36 @@ func InitializeDriver(driverName string, drivers map[string]StoreGenerator) (sto
 	return f()
 }
 
+// NewStoreSelector returns a selector that initializes the registered drivers
+// the first time they are selected and reuses them afterwards. The provided
+// driver is returned when the name it was registered with is selected.
+func NewStoreSelector(driverName string, driver storage.Store, drivers map[string]StoreGenerator) repl.StoreSelector {
+	initialized := map[string]storage.Store{
+		driverName: driver,
+	}
+	return func(name string) (storage.Store, error) {
+		if s, ok := initialized[name]; ok {
+			return s, nil
+		}
+		s, err := InitializeDriver(name, drivers)
+		if err != nil {
+			return nil, err
+		}
+		initialized[name] = s
+		return s, nil
+	}
+}
+
 // InitializeCommands initializes the available commands with the given storage
-// instance.
-func InitializeCommands(driver storage.Store, chanSize, bulkTripleOpSize, builderSize int, rl repl.ReadLiner, done chan bool) []*command.Command {
+// instance. The selector allows the REPL to switch to other drivers.
+func InitializeCommands(driver storage.Store, sel repl.StoreSelector, chanSize, bulkTripleOpSize, builderSize int, rl repl.ReadLiner, done chan bool) []*command.Command {
 	return []*command.Command{
 		assert.New(driver, literal.DefaultBuilder(), chanSize),
 		benchmark.New(driver, chanSize),
 		export.New(driver, bulkTripleOpSize),
 		load.New(driver, bulkTripleOpSize, builderSize),
 		run.New(driver, chanSize),
-		repl.New(driver, chanSize, bulkTripleOpSize, builderSize, rl, done),
+		repl.New(driver, sel, chanSize, bulkTripleOpSize, builderSize, rl, done),
 		server.New(driver, chanSize),
 		version.New(),
 	}
@@ -157,5 +177,6 @@ func Run(driverName string, drivers map[string]StoreGenerator, chanSize, bulkTri
 		}
 		args = append(args, s)
 	}
-	return Eval(context.Background(), args, InitializeCommands(driver, chanSize, bulkTripleOpSize, builderSize, rl, make(chan bool)))
+	sel := NewStoreSelector(driverName, driver, drivers)
+	return Eval(context.Background(), args, InitializeCommands(driver, sel, chanSize, bulkTripleOpSize, builderSize, rl, make(chan bool)))
 }
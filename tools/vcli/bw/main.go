@@ -53,5 +53,5 @@ func registerDrivers() {
 func main() {
 	flag.Parse()
 	registerDrivers()
-	os.Exit(common.Run(*driver, registeredDrivers, *bqlChannelSize, *bulkTripleOpSize, *bulkTripleBuilderSize, repl.SimpleReadLine))
+	os.Exit(common.Run(*driver, registeredDrivers, *bqlChannelSize, *bulkTripleOpSize, *bulkTripleBuilderSize, repl.TerminalReadLine))
 }
@@ -0,0 +1,301 @@
+// Copyright 2016 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+)
+
+const (
+	// continuationPrompt is printed while reading the lines that follow the
+	// first one of a statement.
+	continuationPrompt = "   > "
+
+	// maxHistory is the maximum number of statements kept in the history.
+	maxHistory = 1000
+)
+
+// Control keys handled by the line editor.
+const (
+	ctrlA     = 0x01
+	ctrlB     = 0x02
+	ctrlC     = 0x03
+	ctrlD     = 0x04
+	ctrlE     = 0x05
+	ctrlF     = 0x06
+	ctrlH     = 0x08
+	ctrlK     = 0x0b
+	ctrlN     = 0x0e
+	ctrlP     = 0x10
+	ctrlU     = 0x15
+	esc       = 0x1b
+	backspace = 0x7f
+)
+
+// errInterrupted is returned when a line is discarded using Ctrl-C.
+var errInterrupted = errors.New("line interrupted")
+
+// lineEditor reads lines from a terminal in raw mode. It allows editing the
+// line being entered and browsing the history of the statements entered so
+// far. Lines longer than the terminal width are not redrawn properly.
+type lineEditor struct {
+	r       *bufio.Reader
+	w       io.Writer
+	history []string
+}
+
+// addHistory appends the statement to the history. Consecutive duplicates are
+// only kept once.
+func (e *lineEditor) addHistory(s string) {
+	if s == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == s) {
+		return
+	}
+	e.history = append(e.history, s)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+}
+
+// readLine prints the prompt and reads a line. It returns errInterrupted if
+// the line is discarded using Ctrl-C, and io.EOF if Ctrl-D is pressed on an
+// empty line or the input is exhausted.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	var (
+		buf   []rune
+		pos   int
+		hIdx  = len(e.history)
+		saved []rune
+	)
+	refresh := func() {
+		fmt.Fprintf(e.w, "\r%s%s\x1b[K", prompt, string(buf))
+		if n := len(buf) - pos; n > 0 {
+			fmt.Fprintf(e.w, "\x1b[%dD", n)
+		}
+	}
+	browse := func(idx int) {
+		if idx < 0 || idx > len(e.history) || idx == hIdx {
+			return
+		}
+		if hIdx == len(e.history) {
+			saved = buf
+		}
+		hIdx = idx
+		if hIdx == len(e.history) {
+			buf = saved
+		} else {
+			buf = []rune(e.history[hIdx])
+		}
+		pos = len(buf)
+	}
+
+	fmt.Fprint(e.w, prompt)
+	for {
+		r, _, err := e.r.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(buf) > 0 {
+				fmt.Fprint(e.w, "\r\n")
+				return string(buf), nil
+			}
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.w, "\r\n")
+			return string(buf), nil
+		case ctrlC:
+			fmt.Fprint(e.w, "^C\r\n")
+			return "", errInterrupted
+		case ctrlD:
+			if len(buf) == 0 {
+				fmt.Fprint(e.w, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos:pos], buf[pos+1:]...)
+			}
+		case backspace, ctrlH:
+			if pos > 0 {
+				buf = append(buf[:pos-1:pos-1], buf[pos:]...)
+				pos--
+			}
+		case ctrlA:
+			pos = 0
+		case ctrlE:
+			pos = len(buf)
+		case ctrlB:
+			if pos > 0 {
+				pos--
+			}
+		case ctrlF:
+			if pos < len(buf) {
+				pos++
+			}
+		case ctrlK:
+			buf = buf[:pos:pos]
+		case ctrlU:
+			buf, pos = buf[pos:], 0
+		case ctrlP:
+			browse(hIdx - 1)
+		case ctrlN:
+			browse(hIdx + 1)
+		case esc:
+			switch e.readEscape() {
+			case 'A':
+				browse(hIdx - 1)
+			case 'B':
+				browse(hIdx + 1)
+			case 'C':
+				if pos < len(buf) {
+					pos++
+				}
+			case 'D':
+				if pos > 0 {
+					pos--
+				}
+			case 'H':
+				pos = 0
+			case 'F':
+				pos = len(buf)
+			case '~':
+				// Delete key.
+				if pos < len(buf) {
+					buf = append(buf[:pos:pos], buf[pos+1:]...)
+				}
+			}
+		default:
+			if !unicode.IsPrint(r) {
+				continue
+			}
+			nb := make([]rune, 0, len(buf)+1)
+			nb = append(append(append(nb, buf[:pos]...), r), buf[pos:]...)
+			buf = nb
+			pos++
+		}
+		refresh()
+	}
+}
+
+// readEscape consumes the rest of an escape sequence after the escape key and
+// returns the key it represents: 'A', 'B', 'C', and 'D' for the arrows, 'H'
+// and 'F' for home and end, and '~' for delete. Any other sequence returns 0.
+func (e *lineEditor) readEscape() rune {
+	r, _, err := e.r.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return 0
+	}
+	var digits string
+	for {
+		r, _, err = e.r.ReadRune()
+		if err != nil {
+			return 0
+		}
+		if r < '0' || r > '9' {
+			break
+		}
+		digits += string(r)
+	}
+	switch {
+	case digits == "" && strings.ContainsRune("ABCDHF", r):
+		return r
+	case r == '~' && digits == "3":
+		return '~'
+	case r == '~' && (digits == "1" || digits == "7"):
+		return 'H'
+	case r == '~' && (digits == "4" || digits == "8"):
+		return 'F'
+	}
+	return 0
+}
+
+// readStatement reads lines until they form a statement terminated by ';'.
+// The lines of the statement are joined by new lines, and the statement is
+// added to the history as a single line. Ctrl-C discards the statement being
+// entered.
+func (e *lineEditor) readStatement() (string, error) {
+	var lines []string
+	for {
+		p := prompt
+		if len(lines) > 0 {
+			p = continuationPrompt
+		}
+		l, err := e.readLine(p)
+		if err == errInterrupted {
+			lines = nil
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if l = strings.TrimSpace(l); l == "" {
+			continue
+		}
+		lines = append(lines, l)
+		if strings.HasSuffix(l, ";") {
+			e.addHistory(strings.Join(lines, " "))
+			return strings.Join(lines, "\n"), nil
+		}
+	}
+}
+
+// stty runs stty with the provided arguments against the standard input.
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// TerminalReadLine reads statements from the standard input supporting line
+// editing, multi-line statements, and a history that can be browsed using
+// the up and down arrows. The terminal is put in raw mode using stty while a
+// statement is being entered. If the standard input is not a terminal, or
+// stty is not available, it falls back to SimpleReadLine.
+func TerminalReadLine(done chan bool) <-chan string {
+	state, err := stty("-g")
+	if err != nil {
+		return SimpleReadLine(done)
+	}
+	c := make(chan string)
+	go func() {
+		defer close(c)
+		e := &lineEditor{
+			r: bufio.NewReader(os.Stdin),
+			w: os.Stdout,
+		}
+		for {
+			if _, err := stty("raw", "-echo"); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to set the terminal in raw mode with error %v\n", err)
+				return
+			}
+			stm, err := e.readStatement()
+			stty(state)
+			if err != nil {
+				return
+			}
+			c <- stm
+			if <-done {
+				return
+			}
+		}
+	}()
+	return c
+}
@@ -0,0 +1,148 @@
+// Copyright 2016 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/badwolf/bql/table"
+	"github.com/google/badwolf/triple/node"
+)
+
+func newTestEditor(input string, history ...string) *lineEditor {
+	return &lineEditor{
+		r:       bufio.NewReader(strings.NewReader(input)),
+		w:       ioutil.Discard,
+		history: history,
+	}
+}
+
+func TestReadLineEdition(t *testing.T) {
+	testTable := []struct {
+		in      string
+		history []string
+		want    string
+	}{
+		{in: "select;\r", want: "select;"},
+		{in: "selct\x1b[D\x1b[De\r", want: "select"},
+		{in: "select\x7f\x7f\x7fect\r", want: "select"},
+		{in: "elect\x01s\x05;\r", want: "select;"},
+		{in: "sxelect\x01\x06\x06\x08\r", want: "select"},
+		{in: "sxelect\x1b[H\x1b[C\x1b[3~\x1b[F;\r", want: "select;"},
+		{in: "select ?s from\x0b\x02\x02\x02\x02\x02\x0b\r", want: "select ?s"},
+		{in: "foo select\x02\x02\x02\x02\x02\x02\x15\r", want: "select"},
+		{in: "sel\x04\x02\x04\r", want: "se"},
+		{in: "ñu\x7f\r", want: "ñ"},
+		{in: "\x1b[A\r", history: []string{"first;", "second;"}, want: "second;"},
+		{in: "\x1b[A\x1b[A\x1b[A\r", history: []string{"first;", "second;"}, want: "first;"},
+		{in: "\x10\x10\x0e\r", history: []string{"first;", "second;"}, want: "second;"},
+		{in: "new\x1b[A\x1b[B\r", history: []string{"first;"}, want: "new"},
+		{in: "new\x1b[A\x7f\x1b[B\x1b[A\r", history: []string{"first;"}, want: "first;"},
+	}
+	for _, entry := range testTable {
+		e := newTestEditor(entry.in, entry.history...)
+		got, err := e.readLine(prompt)
+		if err != nil {
+			t.Errorf("lineEditor.readLine(%q) failed with error %v", entry.in, err)
+			continue
+		}
+		if got != entry.want {
+			t.Errorf("lineEditor.readLine(%q) returned the wrong line; got %q, want %q", entry.in, got, entry.want)
+		}
+	}
+}
+
+func TestReadLineControlKeys(t *testing.T) {
+	if _, err := newTestEditor("select\x03").readLine(prompt); err != errInterrupted {
+		t.Errorf("lineEditor.readLine should have been interrupted by Ctrl-C; got error %v", err)
+	}
+	if _, err := newTestEditor("\x04").readLine(prompt); err != io.EOF {
+		t.Errorf("lineEditor.readLine should have returned io.EOF on Ctrl-D; got error %v", err)
+	}
+	if _, err := newTestEditor("").readLine(prompt); err != io.EOF {
+		t.Errorf("lineEditor.readLine should have returned io.EOF on an exhausted input; got error %v", err)
+	}
+}
+
+func TestReadStatement(t *testing.T) {
+	e := newTestEditor("select ?s\r\r  from ?g  \rwhere {?s ?p ?o};\rdiscarded\x03show graphs;\rshow graphs;\r")
+	var got []string
+	for {
+		stm, err := e.readStatement()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("lineEditor.readStatement failed with error %v", err)
+		}
+		got = append(got, stm)
+	}
+	if want := []string{"select ?s\nfrom ?g\nwhere {?s ?p ?o};", "show graphs;", "show graphs;"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lineEditor.readStatement returned the wrong statements; got %q, want %q", got, want)
+	}
+	if want := []string{"select ?s from ?g where {?s ?p ?o};", "show graphs;"}; !reflect.DeepEqual(e.history, want) {
+		t.Errorf("lineEditor.readStatement recorded the wrong history; got %q, want %q", e.history, want)
+	}
+}
+
+func TestPrintTable(t *testing.T) {
+	tbl, err := table.New([]string{"?s", "?name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"/u<joe>", "/person<mary>"} {
+		n, err := node.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tbl.AddRow(table.Row{"?s": &table.Cell{N: n}})
+	}
+	var b bytes.Buffer
+	printTable(&b, tbl)
+	want := "+---------------+-------+\n" +
+		"| ?s            | ?name |\n" +
+		"+---------------+-------+\n" +
+		"| /u<joe>       |       |\n" +
+		"| /person<mary> |       |\n" +
+		"+---------------+-------+\n" +
+		"\n2 rows\n"
+	if got := b.String(); got != want {
+		t.Errorf("printTable returned the wrong output; got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestIsCommand(t *testing.T) {
+	for _, entry := range []struct {
+		l    string
+		want bool
+	}{
+		{"use;", true},
+		{"use memory;", true},
+		{"use\tmemory;", true},
+		{"user;", false},
+		{"used memory;", false},
+		{"select ?s from ?use where {?s ?p ?o};", false},
+	} {
+		if got := isCommand(entry.l, "use"); got != entry.want {
+			t.Errorf("isCommand(%q, \"use\") = %v; want %v", entry.l, got, entry.want)
+		}
+	}
+}
@@ -12,8 +12,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package repl contains the implementation of the command that prints the
-// BQL version.
+// Package repl contains the implementation of the command that starts a REPL
+// to run BQL statements.
 package repl
 
 import (
@@ -40,11 +40,17 @@ import (
 
 const prompt = "bql> "
 
-// New create the version command.
-func New(driver storage.Store, chanSize, bulkSize, builderSize int, rl ReadLiner, done chan bool) *command.Command {
+// StoreSelector returns the store of the driver registered with the provided
+// name.
+type StoreSelector func(name string) (storage.Store, error)
+
+// New create the REPL command. The selector allows switching the store the
+// statements run against; it may be nil if only the provided driver is
+// available.
+func New(driver storage.Store, sel StoreSelector, chanSize, bulkSize, builderSize int, rl ReadLiner, done chan bool) *command.Command {
 	return &command.Command{
 		Run: func(ctx context.Context, args []string) int {
-			REPL(driver, os.Stdin, rl, chanSize, bulkSize, builderSize, done)
+			REPL(driver, sel, os.Stdin, rl, chanSize, bulkSize, builderSize, done)
 			return 0
 		},
 		UsageLine: "bql",
@@ -87,7 +93,7 @@ func SimpleReadLine(done chan bool) <-chan string {
 }
 
 // REPL starts a read-evaluation-print-loop to run BQL commands.
-func REPL(driver storage.Store, input *os.File, rl ReadLiner, chanSize, bulkSize, builderSize int, done chan bool) int {
+func REPL(driver storage.Store, sel StoreSelector, input *os.File, rl ReadLiner, chanSize, bulkSize, builderSize int, done chan bool) int {
 	var tracer io.Writer
 	ctx, isTracingToFile := context.Background(), false
 
@@ -109,7 +115,11 @@ func REPL(driver storage.Store, input *os.File, rl ReadLiner, chanSize, bulkSize
 		fmt.Printf("\n\nThanks for all those BQL queries!\n\n")
 	}()
 
-	for l := range rl(done) {
+	for stm := range rl(done) {
+		// Console commands split their arguments on spaces, hence the lines of
+		// multi-line input are joined by spaces. BQL statements keep their
+		// lines, so errors report the right positions.
+		l := strings.Join(strings.Fields(stm), " ")
 		if strings.HasPrefix(l, "quit") {
 			done <- true
 			break
@@ -119,6 +129,27 @@ func REPL(driver storage.Store, input *os.File, rl ReadLiner, chanSize, bulkSize
 			done <- false
 			continue
 		}
+		if isCommand(l, "use") {
+			args := strings.Fields(strings.TrimSuffix(l, ";"))
+			switch {
+			case len(args) == 1:
+				fmt.Printf("Using driver %q.\n\n", driver.Name(ctx))
+			case len(args) != 2:
+				fmt.Println("Invalid syntax\n\tuse <driver_name>")
+			case sel == nil:
+				fmt.Printf("[ERROR] no other drivers are available\n\n")
+			default:
+				s, err := sel(args[1])
+				if err != nil {
+					fmt.Printf("[ERROR] %s\n\n", err)
+				} else {
+					driver = s
+					fmt.Printf("[OK] Using driver %q.\n\n", driver.Name(ctx))
+				}
+			}
+			done <- false
+			continue
+		}
 		if strings.HasPrefix(l, "start tracing") {
 			args := strings.Split(strings.TrimSpace(l)[:len(l)-1], " ")
 			switch len(args) {
@@ -167,8 +198,8 @@ func REPL(driver storage.Store, input *os.File, rl ReadLiner, chanSize, bulkSize
 			done <- false
 			continue
 		}
-		if strings.HasPrefix(l, "desc") {
-			pln, err := planBQL(ctx, l[4:], driver, chanSize, nil)
+		if strings.HasPrefix(l, "desc") && !strings.HasPrefix(l, "describe") {
+			pln, err := planBQL(ctx, strings.TrimSpace(stm)[4:], driver, chanSize, nil)
 			if err != nil {
 				fmt.Printf("[ERROR] %s\n\n", err)
 			} else {
@@ -192,14 +223,14 @@ func REPL(driver storage.Store, input *os.File, rl ReadLiner, chanSize, bulkSize
 		}
 
 		now := time.Now()
-		table, err := runBQL(ctx, l, driver, chanSize, tracer)
+		table, err := runBQL(ctx, stm, driver, chanSize, tracer)
 		if err != nil {
 			fmt.Printf("[ERROR] %s\n", err)
 			fmt.Println("Time spent: ", time.Now().Sub(now))
 			fmt.Println()
 		} else {
 			if len(table.Bindings()) > 0 {
-				fmt.Println(table.String())
+				printTable(os.Stdout, table)
 			}
 			fmt.Println("[OK] Time spent: ", time.Now().Sub(now))
 		}
@@ -208,6 +239,13 @@ func REPL(driver storage.Store, input *os.File, rl ReadLiner, chanSize, bulkSize
 	return 0
 }
 
+// isCommand returns true if the first word of the line is the provided
+// console command.
+func isCommand(l, cmd string) bool {
+	f := strings.Fields(strings.TrimSuffix(l, ";"))
+	return len(f) > 0 && f[0] == cmd
+}
+
 // printHelp prints help for the console commands.
 func printHelp() {
 	fmt.Println("help                                                  - prints help for the bw console.")
@@ -215,10 +253,26 @@ func printHelp() {
 	fmt.Println("desc <BQL>                                            - prints the execution plan for a BQL statement.")
 	fmt.Println("load <file_path> <graph_names_separated_by_commas>    - load triples into the specified graphs.")
 	fmt.Println("run <file_with_bql_statements>                        - runs all the BQL statements in the file.")
+	fmt.Println("use [driver_name]                                     - switches the driver statements run against.")
 	fmt.Println("start tracing [trace_file]                            - starts tracing queries.")
 	fmt.Println("stop tracing                                          - stops tracing queries.")
 	fmt.Println("quit                                                  - quits the console.")
 	fmt.Println()
+	fmt.Println("Statements end with ; and may span several lines. When the input is a terminal,")
+	fmt.Println("the up and down arrows browse the history of statements and Ctrl-C discards the")
+	fmt.Println("statement being entered.")
+	fmt.Println()
+}
+
+// printTable writes the table as a grid truncating the cells to
+// table.DefaultCellWidth characters, followed by the number of rows.
+func printTable(w io.Writer, tbl *table.Table) {
+	fmt.Fprint(w, tbl.ToGrid(table.DefaultCellWidth))
+	if n := tbl.NumRows(); n == 1 {
+		fmt.Fprintf(w, "\n1 row\n")
+	} else {
+		fmt.Fprintf(w, "\n%d rows\n", n)
+	}
 }
 
 // runBQLFromFile loads all the statements in the file and runs them.
@@ -262,9 +316,10 @@ func planBQL(ctx context.Context, bql string, s storage.Store, chanSize int, w i
 	if err != nil {
 		return nil, fmt.Errorf("failed to initilize a valid BQL parser")
 	}
-	stm := &semantic.Statement{}
-	if err := p.Parse(grammar.NewLLk(bql, 1), stm); err != nil {
-		return nil, fmt.Errorf("failed to parse BQL statement with error %v", err)
+	stm, llk := &semantic.Statement{}, grammar.NewLLk(bql, 1)
+	if err := p.Parse(llk, stm); err != nil {
+		line, col := llk.Position()
+		return nil, fmt.Errorf("failed to parse BQL statement at line %d, column %d with error %v", line, col, err)
 	}
 	pln, err := planner.New(ctx, s, stm, chanSize, w)
 	if err != nil {
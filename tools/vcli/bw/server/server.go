@@ -17,6 +17,8 @@
 package server
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"log"
@@ -27,6 +29,7 @@ import (
 	"time"
 
 	"golang.org/x/net/context"
+	"golang.org/x/net/websocket"
 
 	"github.com/google/badwolf/bql/grammar"
 	"github.com/google/badwolf/bql/planner"
@@ -42,7 +45,13 @@ func New(store storage.Store, chanSize int) *command.Command {
 		UsageLine: "server port",
 		Short:     "runs a BQL endoint.",
 		Long: `Runs a BQL endpoint with the provided driver. It allows running
-all BQL queries and returns a JSON table with the results.`,
+all BQL queries and returns a JSON table with the results. Setting the
+metadata form value to true adds a header to each table describing the
+inferred kind of each column and the number of rows. The /bql/stream
+WebSocket endpoint runs the queries sent as its first message and streams
+their rows as JSON frames. The graph pattern of each query is resolved
+first; rows are then projected and sent one at a time, unless the query
+needs all its rows to compute the first one.`,
 	}
 	cmd.Run = func(ctx context.Context, args []string) int {
 		return runServer(ctx, cmd, args, store, chanSize)
@@ -80,6 +89,7 @@ func runServer(ctx context.Context, cmd *command.Command, args []string, store s
 		chanSize: chanSize,
 	}
 	http.HandleFunc("/bql", s.bqlHandler)
+	http.Handle("/bql/stream", websocket.Handler(s.streamHandler))
 	http.HandleFunc("/", defaultHandler)
 	if err := http.ListenAndServe(":"+p, nil); err != nil {
 		log.Printf("[%v] Failed to start server on port %s; %v", time.Now(), p, err)
@@ -116,6 +126,9 @@ func (s *serverConfig) bqlHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer cancel() // Cancel ctx as soon as handleSearch returns.
 
+	// The metadata header on the returned tables is optional.
+	metadata := r.FormValue("metadata") == "true"
+
 	var res []*result
 	for _, q := range getQueries(r.PostForm["bqlQuery"]) {
 		if nq, err := url.QueryUnescape(q); err == nil {
@@ -147,7 +160,16 @@ func (s *serverConfig) bqlHandler(w http.ResponseWriter, r *http.Request) {
 		if r.T == nil {
 			w.Write([]byte(`{}`))
 		} else {
-			r.T.ToJSON(w)
+			var err error
+			if metadata {
+				err = r.T.ToJSONWithMetadata(w)
+			} else {
+				err = r.T.ToJSON(w)
+			}
+			if err != nil {
+				log.Printf("[%s] failed to serialize the table for %q; %v", time.Now(), r.Q, err)
+				w.Write([]byte(`{}`))
+			}
 		}
 		w.Write([]byte(` }`))
 		if cnt > 1 {
@@ -159,6 +181,118 @@ func (s *serverConfig) bqlHandler(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// streamHandler runs the BQL queries received as the first message of the
+// WebSocket connection and streams their results. Each query produces a frame
+// with the query and the bindings of its rows, a frame per row using the typed
+// cell encoding of the tables, and a final frame with its outcome and the
+// number of rows sent, as in
+//
+//	{ "query": "...", "bindings": ["?s"] }
+//	{ "row": { "?s": {"type":"node","value":"/u<joe>"} } }
+//	{ "query": "...", "msg": "[OK]", "rows": 1 }
+//
+// Rows are written as soon as they are projected once the graph pattern is
+// resolved, as described by planner.Stream; projecting them only progresses as
+// fast as the client reads them. The connection is closed once all the queries
+// run, and queries are canceled if the client disconnects. The timeout URL
+// parameter bounds the execution of all the queries.
+func (s *serverConfig) streamHandler(ws *websocket.Conn) {
+	defer ws.Close()
+	var raw string
+	if err := websocket.Message.Receive(ws, &raw); err != nil {
+		log.Printf("[%s] Failed to receive the streamed queries; %v", time.Now(), err)
+		return
+	}
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout, err := time.ParseDuration(ws.Request().FormValue("timeout")); err == nil {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+	go func() {
+		// Receiving fails once the client disconnects, which cancels the
+		// running query.
+		var msg string
+		for websocket.Message.Receive(ws, &msg) == nil {
+		}
+		cancel()
+	}()
+	for _, q := range getQueries([]string{raw}) {
+		n, err := streamBQL(ctx, ws, q, s.store, s.chanSize)
+		res := &streamResult{Q: q, Msg: "[OK]", Rows: &n}
+		if err != nil {
+			log.Printf("[%s] %q failed; %v", time.Now(), q, err)
+			res.Msg = err.Error()
+		}
+		if err := sendFrame(ws, res); err != nil || ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// streamResult contains the header and outcome frames of a streamed query.
+type streamResult struct {
+	Q        string   `json:"query"`
+	Bindings []string `json:"bindings,omitempty"`
+	Msg      string   `json:"msg,omitempty"`
+	Rows     *int     `json:"rows,omitempty"`
+}
+
+// sendFrame sends the JSON encoding of the provided value as a text frame.
+func sendFrame(ws *websocket.Conn, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return websocket.Message.Send(ws, string(b))
+}
+
+// streamBQL executes the provided query sending its header and rows to the
+// WebSocket connection. It returns the number of rows sent.
+func streamBQL(ctx context.Context, ws *websocket.Conn, bql string, s storage.Store, chanSize int) (int, error) {
+	pln, err := plan(ctx, bql, s, chanSize)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var bs []string
+	start := func(b []string) error {
+		bs = b
+		return sendFrame(ws, &streamResult{Q: bql, Bindings: b})
+	}
+	rows, errc := make(chan table.Row, chanSize), make(chan error, 1)
+	go func() {
+		errc <- planner.Stream(ctx, pln, start, rows)
+	}()
+	n, wErr := 0, error(nil)
+	for r := range rows {
+		if wErr != nil {
+			continue
+		}
+		var b bytes.Buffer
+		b.WriteString(`{ "row": `)
+		if wErr = r.ToJSON(&b, bs); wErr == nil {
+			b.WriteString(` }`)
+			wErr = websocket.Message.Send(ws, b.String())
+		}
+		if wErr != nil {
+			// Stop the query; the remaining rows are drained.
+			cancel()
+			continue
+		}
+		n++
+	}
+	if err := <-errc; err != nil && wErr == nil {
+		return n, fmt.Errorf("[ERROR] Failed to execute BQL statement with error %v", err)
+	}
+	return n, wErr
+}
+
 // result contains a query and its outcome.
 type result struct {
 	Q   string       `json:"q,omitempty"`
@@ -183,6 +317,19 @@ func getQueries(raw []string) []string {
 
 // BQL attempts to execute the provided query against the given store.
 func BQL(ctx context.Context, bql string, s storage.Store, chanSize int) (*table.Table, error) {
+	pln, err := plan(ctx, bql, s, chanSize)
+	if err != nil {
+		return nil, err
+	}
+	res, err := pln.Execute(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[ERROR] Failed to execute BQL statement with error %v", err)
+	}
+	return res, nil
+}
+
+// plan parses the provided query and returns its execution plan.
+func plan(ctx context.Context, bql string, s storage.Store, chanSize int) (planner.Executor, error) {
 	p, err := grammar.NewParser(grammar.SemanticBQL())
 	if err != nil {
 		return nil, fmt.Errorf("[ERROR] Failed to initilize a valid BQL parser")
@@ -195,11 +342,7 @@ func BQL(ctx context.Context, bql string, s storage.Store, chanSize int) (*table
 	if err != nil {
 		return nil, fmt.Errorf("[ERROR] Should have not failed to create a plan using memory.DefaultStorage for statement %v with error %v", stm, err)
 	}
-	res, err := pln.Execute(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("[ERROR] Failed to execute BQL statement with error %v", err)
-	}
-	return res, nil
+	return pln, nil
 }
 
 // defaultHandler implements the handler to server BQL requests.
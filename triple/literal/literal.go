@@ -17,9 +17,13 @@ package literal
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"math"
+	"math/big"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -40,6 +44,9 @@ const (
 	Text
 	// Blob indicates that the type contained in the literal is a []byte.
 	Blob
+	// Decimal indicates that the type contained in the literal is an
+	// arbitrary-precision decimal number stored as a *big.Rat.
+	Decimal
 )
 
 // Strings returns the pretty printing version of the type
@@ -55,6 +62,8 @@ func (t Type) String() string {
 		return "text"
 	case Blob:
 		return "blob"
+	case Decimal:
+		return "decimal"
 	default:
 		return "UNKNOWN"
 	}
@@ -71,8 +80,16 @@ func (l *Literal) Type() Type {
 	return l.t
 }
 
-// String returns a string representation of the literal.
+// String returns a string representation of the literal. Blobs are base64
+// encoded. Decimals are printed with the minimum number of fractional digits
+// that represent them exactly, hence "1.0" and "1.00" are both printed as "1".
 func (l *Literal) String() string {
+	switch l.t {
+	case Blob:
+		return fmt.Sprintf("\"%s\"^^type:%v", base64.StdEncoding.EncodeToString(l.v.([]byte)), l.Type())
+	case Decimal:
+		return fmt.Sprintf("\"%s\"^^type:%v", decimalString(l.v.(*big.Rat)), l.Type())
+	}
 	return fmt.Sprintf("\"%v\"^^type:%v", l.Interface(), l.Type())
 }
 
@@ -84,6 +101,11 @@ func (l *Literal) ToComparableString() string {
 		s = fmt.Sprintf("\"%032d\"^^type:%v", l.Interface(), l.Type())
 	case Float64:
 		s = fmt.Sprintf("\"%032f\"^^type:%v", l.Interface(), l.Type())
+	case Blob:
+		// Hex encoding preserves the lexicographic byte order.
+		s = fmt.Sprintf("\"%s\"^^type:%v", hex.EncodeToString(l.v.([]byte)), l.Type())
+	case Decimal:
+		s = fmt.Sprintf("\"%s\"^^type:%v", comparableDecimalString(l.v.(*big.Rat)), l.Type())
 	default:
 		s = l.String()
 	}
@@ -130,11 +152,145 @@ func (l *Literal) Blob() ([]byte, error) {
 	return l.v.([]byte), nil
 }
 
+// Decimal returns the value of a literal as a *big.Rat. The returned value is
+// a copy and can be freely modified.
+func (l *Literal) Decimal() (*big.Rat, error) {
+	if l.t != Decimal {
+		return nil, fmt.Errorf("literal.Decimal: literal is of type %v; cannot be converted to a decimal", l.t)
+	}
+	return new(big.Rat).Set(l.v.(*big.Rat)), nil
+}
+
 // Interface returns the value as a simple interface{}.
 func (l *Literal) Interface() interface{} {
 	return l.v
 }
 
+// EqualFold returns true if both literals have the same type and value. Text
+// literals are compared under Unicode case folding, hence "Model S" and
+// "model s" are equal. Other literals need to be exactly equal.
+func (l *Literal) EqualFold(o *Literal) bool {
+	if o == nil || l.t != o.t {
+		return false
+	}
+	if l.t == Text {
+		return strings.EqualFold(l.v.(string), o.v.(string))
+	}
+	return l.String() == o.String()
+}
+
+// Compare returns a negative value if the literal sorts before the provided
+// one, zero if both are equal, and a positive value otherwise. Literals of any
+// two types can be compared, and the order is total. Booleans sort before
+// numbers, numbers before text, and text before blobs. Int64, float64, and
+// decimal literals are compared numerically among them, exactly if any of
+// them is a decimal, and numerically equal values sort int64 before float64
+// before decimal. Float64 NaN sorts before any other number. Within the other
+// types, false sorts before true, text is compared lexicographically, and
+// blobs byte by byte.
+func (l *Literal) Compare(o *Literal) (int, error) {
+	if o == nil {
+		return 0, fmt.Errorf("literal.Compare: cannot compare %v against a nil literal", l)
+	}
+	if ra, rb := typeRank(l.t), typeRank(o.t); ra != rb {
+		return compareInts(int64(ra), int64(rb)), nil
+	}
+	switch l.t {
+	case Bool:
+		va, vb := l.v.(bool), o.v.(bool)
+		switch {
+		case va == vb:
+			return 0, nil
+		case vb:
+			return -1, nil
+		}
+		return 1, nil
+	case Text:
+		return strings.Compare(l.v.(string), o.v.(string)), nil
+	case Blob:
+		return bytes.Compare(l.v.([]byte), o.v.([]byte)), nil
+	}
+	if c := compareNumbers(l, o); c != 0 {
+		return c, nil
+	}
+	return compareInts(int64(l.t), int64(o.t)), nil
+}
+
+// typeRank returns the position of the provided type in the order used by
+// Compare. Int64, float64, and decimal literals share the same rank.
+func typeRank(t Type) int {
+	switch t {
+	case Bool:
+		return 0
+	case Int64, Float64, Decimal:
+		return 1
+	case Text:
+		return 2
+	}
+	return 3
+}
+
+// compareInts returns -1, 0, or 1 if a is smaller, equal, or bigger than b.
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareNumbers numerically compares two int64, float64, or decimal literals.
+func compareNumbers(a, b *Literal) int {
+	if a.t == Int64 && b.t == Int64 {
+		return compareInts(a.v.(int64), b.v.(int64))
+	}
+	if a.t == Decimal || b.t == Decimal {
+		if ra, rb := numberRat(a), numberRat(b); ra != nil && rb != nil {
+			return ra.Cmp(rb)
+		}
+	}
+	fa, fb := numberFloat64(a), numberFloat64(b)
+	switch na, nb := math.IsNaN(fa), math.IsNaN(fb); {
+	case na && nb:
+		return 0
+	case na:
+		return -1
+	case nb:
+		return 1
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	}
+	return 0
+}
+
+// numberRat returns the exact value of a numeric literal, or nil if it has
+// none, as it is the case for infinite and NaN floats.
+func numberRat(l *Literal) *big.Rat {
+	switch l.t {
+	case Int64:
+		return new(big.Rat).SetInt64(l.v.(int64))
+	case Float64:
+		return new(big.Rat).SetFloat64(l.v.(float64))
+	}
+	return l.v.(*big.Rat)
+}
+
+// numberFloat64 returns the closest float64 value of a numeric literal.
+func numberFloat64(l *Literal) float64 {
+	switch l.t {
+	case Int64:
+		return float64(l.v.(int64))
+	case Float64:
+		return l.v.(float64)
+	}
+	f, _ := l.v.(*big.Rat).Float64()
+	return f
+}
+
 // Builder interface provides a standard way to build literals given a type and
 // a given value.
 type Builder interface {
@@ -176,6 +332,19 @@ func (b *unboundBuilder) Build(t Type, v interface{}) (*Literal, error) {
 		if t != Blob {
 			return nil, fmt.Errorf("literal.Build: type %v does not match type of value %v", t, v)
 		}
+	case *big.Rat:
+		if t != Decimal {
+			return nil, fmt.Errorf("literal.Build: type %v does not match type of value %v", t, v)
+		}
+		r := v.(*big.Rat)
+		if r == nil {
+			return nil, fmt.Errorf("literal.Build: cannot build a decimal literal from a nil value")
+		}
+		if decimalScale(r) < 0 {
+			return nil, fmt.Errorf("literal.Build: value %v cannot be represented with a finite number of decimal digits", r)
+		}
+		// Keep a private copy so the literal remains immutable.
+		v = new(big.Rat).Set(r)
 	default:
 		return nil, fmt.Errorf("literal.Build: type %T is not supported when building literals", v)
 	}
@@ -222,6 +391,14 @@ func (b *unboundBuilder) Parse(s string) (*Literal, error) {
 	case "text":
 		return b.Build(Text, v)
 	case "blob":
+		if len(v) < 2 || v[0] != '[' || v[len(v)-1] != ']' {
+			bs, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return nil, fmt.Errorf("literal.Parse: failed to decode base64 blob %q with error %v", v, err)
+			}
+			return b.Build(Blob, bs)
+		}
+		// Blobs used to be printed as arrays of byte values.
 		values := v[1 : len(v)-1]
 		if values == "" {
 			return b.Build(Blob, []byte{})
@@ -235,6 +412,15 @@ func (b *unboundBuilder) Parse(s string) (*Literal, error) {
 			bs = append(bs, byte(b))
 		}
 		return b.Build(Blob, bs)
+	case "decimal":
+		if !decimalRE.MatchString(v) {
+			return nil, fmt.Errorf("literal.Parse: could not convert value %q to decimal", v)
+		}
+		pv, ok := new(big.Rat).SetString(v)
+		if !ok {
+			return nil, fmt.Errorf("literal.Parse: could not convert value %q to decimal", v)
+		}
+		return b.Build(Decimal, pv)
 	default:
 		return nil, nil
 	}
@@ -256,11 +442,11 @@ func (b *boundedBuilder) Build(t Type, v interface{}) (*Literal, error) {
 	switch v.(type) {
 	case string:
 		if l := len(v.(string)); l > b.max {
-			return nil, fmt.Errorf("literal.Build: cannot create literal due to size of %v (%d>%d)", v, l, b.max)
+			return nil, fmt.Errorf("literal.Build: cannot create %v literal of %d bytes; the maximum is %d bytes", t, l, b.max)
 		}
 	case []byte:
 		if l := len(v.([]byte)); l > b.max {
-			return nil, fmt.Errorf("literal.Build: cannot create literal due to size of %v (%d>%d)", v, l, b.max)
+			return nil, fmt.Errorf("literal.Build: cannot create %v literal of %d bytes; the maximum is %d bytes", t, l, b.max)
 		}
 	}
 	return defaultBuilder.Build(t, v)
@@ -276,19 +462,20 @@ func (b *boundedBuilder) Parse(s string) (*Literal, error) {
 	switch t {
 	case Text:
 		if text, err := l.Text(); err != nil || len(text) > b.max {
-			return nil, fmt.Errorf("literal.Parse: cannot create literal due to size of %v (%d>%d)", t, len(text), b.max)
+			return nil, fmt.Errorf("literal.Parse: cannot create %v literal of %d bytes; the maximum is %d bytes", t, len(text), b.max)
 		}
 	case Blob:
 		if blob, err := l.Blob(); err != nil || len(blob) > b.max {
-			return nil, fmt.Errorf("literal.Parse: cannot create literal due to size of %v (%d>%d)", t, len(blob), b.max)
+			return nil, fmt.Errorf("literal.Parse: cannot create %v literal of %d bytes; the maximum is %d bytes", t, len(blob), b.max)
 		}
 	}
 	return l, nil
 }
 
 // NewBoundedBuilder creates a builder that guarantees that no literal will
-// be created if the size of the string or a blob is bigger than the provided
-// maximum.
+// be created if the size in bytes of the string or a blob is bigger than the
+// provided maximum. It can be used to reject over-long literals when reading
+// untrusted input, for instance with io.ReadIntoGraph.
 func NewBoundedBuilder(max int) Builder {
 	return &boundedBuilder{max: max}
 }
@@ -318,7 +505,63 @@ func (l *Literal) UUID() uuid.UUID {
 		buffer.Write([]byte(v))
 	case []byte:
 		buffer.Write(v)
+	case *big.Rat:
+		// The type prefix keeps decimals apart from the text literal with the
+		// same printed value.
+		buffer.WriteString("decimal:")
+		buffer.WriteString(decimalString(v))
 	}
 
 	return uuid.NewSHA1(uuid.NIL, buffer.Bytes())
 }
+
+// decimalRE matches the plain decimal notation accepted for decimal literals.
+var decimalRE = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)$`)
+
+// decimalScale returns the minimum number of fractional digits required to
+// print the provided value exactly, or -1 if the value has no finite decimal
+// representation.
+func decimalScale(r *big.Rat) int {
+	d, q, m := new(big.Int).Set(r.Denom()), new(big.Int), new(big.Int)
+	factor := func(f int64) int {
+		n, bf := 0, big.NewInt(f)
+		for {
+			if q.QuoRem(d, bf, m); m.Sign() != 0 {
+				return n
+			}
+			d.Set(q)
+			n++
+		}
+	}
+	twos, fives := factor(2), factor(5)
+	if !d.IsInt64() || d.Int64() != 1 {
+		return -1
+	}
+	if twos > fives {
+		return twos
+	}
+	return fives
+}
+
+// decimalString returns the canonical printed form of a decimal value.
+func decimalString(r *big.Rat) string {
+	return r.FloatString(decimalScale(r))
+}
+
+// comparableDecimalString returns the canonical printed form of a decimal
+// value with the integer part padded to 32 digits, hence the fractional
+// digits can be compared lexicographically.
+func comparableDecimalString(r *big.Rat) string {
+	ds, sign := decimalString(r), ""
+	if ds[0] == '-' {
+		ds, sign = ds[1:], "-"
+	}
+	ip := ds
+	if idx := strings.Index(ds, "."); idx >= 0 {
+		ip = ds[:idx]
+	}
+	if len(ip) < 32 {
+		ds = strings.Repeat("0", 32-len(ip)) + ds
+	}
+	return sign + ds
+}
@@ -15,8 +15,12 @@
 package literal
 
 import (
+	"math"
+	"math/big"
 	"reflect"
 	"testing"
+
+	"github.com/pborman/uuid"
 )
 
 func TestDefaultBuilder(t *testing.T) {
@@ -98,8 +102,11 @@ func TestPrettyPrinting(t *testing.T) {
 		{Float64, float64(1), `"1"^^type:float64`},
 		{Text, "", `""^^type:text`},
 		{Text, "some random string", `"some random string"^^type:text`},
-		{Blob, []byte{}, `"[]"^^type:blob`},
-		{Blob, []byte("some random bytes"), `"[115 111 109 101 32 114 97 110 100 111 109 32 98 121 116 101 115]"^^type:blob`},
+		{Blob, []byte{}, `""^^type:blob`},
+		{Blob, []byte("some random bytes"), `"c29tZSByYW5kb20gYnl0ZXM="^^type:blob`},
+		{Decimal, big.NewRat(1999, 100), `"19.99"^^type:decimal`},
+		{Decimal, big.NewRat(-1, 8), `"-0.125"^^type:decimal`},
+		{Decimal, big.NewRat(10, 1), `"10"^^type:decimal`},
 	}
 	for _, tc := range table {
 		lit, err := DefaultBuilder().Build(tc.t, tc.v)
@@ -129,8 +136,10 @@ func TestToComparableString(t *testing.T) {
 		{Float64, float64(1), `"0000000000000000000000001.000000"^^type:float64`},
 		{Text, "", `""^^type:text`},
 		{Text, "some random string", `"some random string"^^type:text`},
-		{Blob, []byte{}, `"[]"^^type:blob`},
-		{Blob, []byte("some random bytes"), `"[115 111 109 101 32 114 97 110 100 111 109 32 98 121 116 101 115]"^^type:blob`},
+		{Blob, []byte{}, `""^^type:blob`},
+		{Blob, []byte("some random bytes"), `"736f6d652072616e646f6d206279746573"^^type:blob`},
+		{Decimal, big.NewRat(-1, 2), `"-00000000000000000000000000000000.5"^^type:decimal`},
+		{Decimal, big.NewRat(1999, 100), `"00000000000000000000000000000019.99"^^type:decimal`},
 	}
 	for _, tc := range table {
 		lit, err := DefaultBuilder().Build(tc.t, tc.v)
@@ -160,6 +169,10 @@ func TestParse(t *testing.T) {
 		{Float64, float64(1), `"1"^^type:float64`},
 		{Text, "", `""^^type:text`},
 		{Text, "some random string", `"some random string"^^type:text`},
+		{Blob, []byte{}, `""^^type:blob`},
+		{Blob, []byte("hello"), `"aGVsbG8="^^type:blob`},
+		{Blob, []byte("some random bytes"), `"c29tZSByYW5kb20gYnl0ZXM="^^type:blob`},
+		// Blobs printed as arrays of byte values are still supported.
 		{Blob, []byte{}, `"[]"^^type:blob`},
 		{Blob, []byte("some random bytes"), `"[115 111 109 101 32 114 97 110 100 111 109 32 98 121 116 101 115]"^^type:blob`},
 	}
@@ -177,3 +190,196 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+func TestParseBlobErrors(t *testing.T) {
+	table := []string{
+		`"aGVsbG8"^^type:blob`,
+		`"not base64!"^^type:blob`,
+		`"[1 2 256]"^^type:blob`,
+		`"[1 -2]"^^type:blob`,
+		`"[1 2"^^type:blob`,
+	}
+	for _, s := range table {
+		if l, err := DefaultBuilder().Parse(s); err == nil {
+			t.Errorf("Parse should have failed to parse malformed blob %s; got %v", s, l)
+		}
+	}
+	if _, err := NewBoundedBuilder(4).Parse(`"aGVsbG8="^^type:blob`); err == nil {
+		t.Errorf("Parse should have failed to parse a blob over the bounded builder size")
+	}
+}
+
+func TestBlobOrder(t *testing.T) {
+	table := [][]byte{
+		{},
+		{0x00},
+		{0x00, 0xff},
+		{0x01},
+		{0x7f, 0x00},
+		{0x80},
+		{0xff},
+		{0xff, 0x00},
+	}
+	for i := 1; i < len(table); i++ {
+		prev, err := DefaultBuilder().Build(Blob, table[i-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		cur, err := DefaultBuilder().Build(Blob, table[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prev.ToComparableString() >= cur.ToComparableString() {
+			t.Errorf("ToComparableString should sort blob %v before %v; got %s and %s", table[i-1], table[i], prev.ToComparableString(), cur.ToComparableString())
+		}
+	}
+}
+
+func TestEqualFold(t *testing.T) {
+	table := []struct {
+		l, o string
+		want bool
+	}{
+		{`"Model S"^^type:text`, `"model s"^^type:text`, true},
+		{`"MODEL S"^^type:text`, `"Model S"^^type:text`, true},
+		{`"Model S"^^type:text`, `"Model X"^^type:text`, false},
+		{`"1"^^type:int64`, `"1"^^type:int64`, true},
+		{`"1"^^type:int64`, `"1"^^type:float64`, false},
+		{`"true"^^type:bool`, `"true"^^type:text`, false},
+		{`"aGVsbG8="^^type:blob`, `"aGVsbG8="^^type:blob`, true},
+		{`"1.0"^^type:decimal`, `"1.00"^^type:decimal`, true},
+		{`"1"^^type:decimal`, `"1"^^type:int64`, false},
+	}
+	for _, entry := range table {
+		l, err := DefaultBuilder().Parse(entry.l)
+		if err != nil {
+			t.Fatalf("literal.Parse failed to parse %q with error %v", entry.l, err)
+		}
+		o, err := DefaultBuilder().Parse(entry.o)
+		if err != nil {
+			t.Fatalf("literal.Parse failed to parse %q with error %v", entry.o, err)
+		}
+		if got := l.EqualFold(o); got != entry.want {
+			t.Errorf("%s.EqualFold(%s) returned %v; want %v", l, o, got, entry.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	table := []struct {
+		l, o string
+		want int
+	}{
+		{`"false"^^type:bool`, `"true"^^type:bool`, -1},
+		{`"true"^^type:bool`, `"-10"^^type:int64`, -1},
+		{`"-10"^^type:int64`, `"2"^^type:int64`, -1},
+		{`"10"^^type:int64`, `"2.5"^^type:float64`, 1},
+		{`"1"^^type:int64`, `"1"^^type:float64`, -1},
+		{`"1"^^type:float64`, `"1.00"^^type:decimal`, -1},
+		{`"0.1"^^type:decimal`, `"0.1"^^type:float64`, -1},
+		{`"1.0"^^type:decimal`, `"1.00"^^type:decimal`, 0},
+		{`"99999"^^type:decimal`, `"a"^^type:text`, -1},
+		{`"b"^^type:text`, `"a"^^type:text`, 1},
+		{`"z"^^type:text`, `"AA=="^^type:blob`, -1},
+		{`"AQ=="^^type:blob`, `"AA=="^^type:blob`, 1},
+	}
+	for _, entry := range table {
+		l, err := DefaultBuilder().Parse(entry.l)
+		if err != nil {
+			t.Fatalf("literal.Parse failed to parse %q with error %v", entry.l, err)
+		}
+		o, err := DefaultBuilder().Parse(entry.o)
+		if err != nil {
+			t.Fatalf("literal.Parse failed to parse %q with error %v", entry.o, err)
+		}
+		if got, err := l.Compare(o); err != nil || got != entry.want {
+			t.Errorf("%s.Compare(%s) returned %d, %v; want %d, nil", l, o, got, err, entry.want)
+		}
+		if got, err := o.Compare(l); err != nil || got != -entry.want {
+			t.Errorf("%s.Compare(%s) returned %d, %v; want %d, nil", o, l, got, err, -entry.want)
+		}
+	}
+	nan, err := DefaultBuilder().Build(Float64, math.NaN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	inf, err := DefaultBuilder().Build(Float64, math.Inf(-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := nan.Compare(inf); err != nil || got != -1 {
+		t.Errorf("%s.Compare(%s) returned %d, %v; want -1, nil", nan, inf, got, err)
+	}
+	if _, err := nan.Compare(nil); err == nil {
+		t.Errorf("%s.Compare(nil) should have failed", nan)
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	table := []struct {
+		s, want string
+	}{
+		{`"19.99"^^type:decimal`, `"19.99"^^type:decimal`},
+		{`"1.0"^^type:decimal`, `"1"^^type:decimal`},
+		{`"1.00"^^type:decimal`, `"1"^^type:decimal`},
+		{`"+0.50"^^type:decimal`, `"0.5"^^type:decimal`},
+		{`"-.25"^^type:decimal`, `"-0.25"^^type:decimal`},
+		{`"-0.0"^^type:decimal`, `"0"^^type:decimal`},
+		{`"12345678901234567890.123456789012345678901"^^type:decimal`, `"12345678901234567890.123456789012345678901"^^type:decimal`},
+	}
+	for _, entry := range table {
+		l, err := DefaultBuilder().Parse(entry.s)
+		if err != nil {
+			t.Fatalf("literal.Parse failed to parse %s with error %v", entry.s, err)
+		}
+		if got := l.String(); got != entry.want {
+			t.Errorf("literal.Parse(%s) returned %s; want %s", entry.s, got, entry.want)
+		}
+		want, err := DefaultBuilder().Parse(entry.want)
+		if err != nil {
+			t.Fatalf("literal.Parse failed to parse %s with error %v", entry.want, err)
+		}
+		if !uuid.Equal(l.UUID(), want.UUID()) {
+			t.Errorf("%s and %s should have the same UUID", entry.s, entry.want)
+		}
+	}
+	for _, s := range []string{
+		`""^^type:decimal`,
+		`"."^^type:decimal`,
+		`"1/3"^^type:decimal`,
+		`"1e3"^^type:decimal`,
+		`"1.2.3"^^type:decimal`,
+		`"abc"^^type:decimal`,
+	} {
+		if l, err := DefaultBuilder().Parse(s); err == nil {
+			t.Errorf("literal.Parse should have failed to parse malformed decimal %s; got %v", s, l)
+		}
+	}
+	if l, err := DefaultBuilder().Build(Decimal, big.NewRat(1, 3)); err == nil {
+		t.Errorf("literal.Build should have failed to build a decimal without a finite representation; got %v", l)
+	}
+	r := big.NewRat(1, 2)
+	l, err := DefaultBuilder().Build(Decimal, r)
+	if err != nil {
+		t.Fatalf("literal.Build failed to build decimal %v with error %v", r, err)
+	}
+	r.SetInt64(7)
+	v, err := l.Decimal()
+	if err != nil {
+		t.Fatalf("literal.Decimal failed with error %v", err)
+	}
+	v.SetInt64(9)
+	if got, want := l.String(), `"0.5"^^type:decimal`; got != want {
+		t.Errorf("decimal literals should be immutable; got %s, want %s", got, want)
+	}
+	if _, err := l.Float64(); err == nil {
+		t.Errorf("literal.Float64 should have failed for decimal literal %s", l)
+	}
+	text, err := DefaultBuilder().Build(Text, "0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uuid.Equal(l.UUID(), text.UUID()) {
+		t.Errorf("decimal literal %s and text literal %s should have different UUIDs", l, text)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2015 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const hexDigits = "0123456789ABCDEF"
+
+// needsEscaping returns true if the byte cannot be used as is on the ID of a
+// pretty printed node. '<' and '>' delimit the ID, ']' followed by spaces
+// splits the predicate and the object of a pretty printed triple, control
+// characters break line based formats, and '%' introduces escaped bytes.
+func needsEscaping(c byte) bool {
+	return c == '%' || c == '<' || c == '>' || c == ']' || c < 0x20 || c == 0x7f
+}
+
+// Escape returns the provided ID with the bytes that would break the pretty
+// printed form of nodes and triples replaced by '%' followed by their two
+// uppercase hexadecimal digits. Any other byte, including spaces and non ASCII
+// characters, is kept as is; hence "Fire Escape" is returned unchanged while
+// "a<b>" becomes "a%3Cb%3E". The escaped ID is always accepted by NewID and
+// Parse, unless empty.
+func Escape(id string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !needsEscaping(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&0x0f])
+	}
+	return b.String()
+}
+
+// unhex returns the value of the provided hexadecimal digit and true, or false
+// if it is not one.
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// Unescape reverts Escape, returning the original ID. It fails if a '%' is not
+// followed by two hexadecimal digits.
+func Unescape(id string) (string, error) {
+	var b bytes.Buffer
+	for i := 0; i < len(id); i++ {
+		if id[i] != '%' {
+			b.WriteByte(id[i])
+			continue
+		}
+		if i+2 >= len(id) {
+			return "", fmt.Errorf("node.Unescape(%q) found truncated escape sequence %q", id, id[i:])
+		}
+		h, hok := unhex(id[i+1])
+		l, lok := unhex(id[i+2])
+		if !hok || !lok {
+			return "", fmt.Errorf("node.Unescape(%q) found invalid escape sequence %q", id, id[i:i+3])
+		}
+		b.WriteByte(h<<4 | l)
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// Valid returns an error if the provided type and ID cannot be used to build a
+// node whose pretty printed form is parsed back into the same node. IDs must
+// be escaped with Escape when built out of arbitrary strings.
+func Valid(typeStr, id string) error {
+	if _, err := NewType(typeStr); err != nil {
+		return err
+	}
+	if _, err := NewID(id); err != nil {
+		return err
+	}
+	for i := 0; i < len(id); i++ {
+		if c := id[i]; c != '%' && needsEscaping(c) {
+			return fmt.Errorf("node.Valid(%q, %q) requires escaping %q", typeStr, id, c)
+		}
+	}
+	if _, err := Unescape(id); err != nil {
+		return fmt.Errorf("node.Valid(%q, %q) found an invalid ID; %v", typeStr, id, err)
+	}
+	return nil
+}
@@ -165,3 +165,87 @@ func TestBlankNode(t *testing.T) {
 		}
 	}
 }
+
+func TestEscape(t *testing.T) {
+	table := []struct {
+		id, want string
+	}{
+		{"some_id", "some_id"},
+		{"Fire Escape", "Fire Escape"},
+		{"model s", "model s"},
+		{"a<b>", "a%3Cb%3E"},
+		{"100%", "100%25"},
+		{"x] /y", "x%5D /y"},
+		{"two\nlines\t", "two%0Alines%09"},
+		{"café", "café"},
+	}
+	for _, tc := range table {
+		got := Escape(tc.id)
+		if got != tc.want {
+			t.Errorf("node.Escape(%q) returned %q; want %q", tc.id, got, tc.want)
+		}
+		if err := Valid("/some/type", got); err != nil {
+			t.Errorf("node.Valid rejected escaped ID %q; %v", got, err)
+		}
+		id, err := Unescape(got)
+		if err != nil {
+			t.Errorf("node.Unescape(%q) failed with error %v", got, err)
+		}
+		if id != tc.id {
+			t.Errorf("node.Unescape(%q) returned %q; want %q", got, id, tc.id)
+		}
+		n, err := Parse(NewNode(mustType(t, "/some/type"), mustID(t, got)).String())
+		if err != nil {
+			t.Errorf("node.Parse failed to parse node with escaped ID %q; %v", got, err)
+		} else if n.ID().String() != got {
+			t.Errorf("node.Parse returned ID %q; want %q", n.ID(), got)
+		}
+	}
+	for _, id := range []string{"50%", "%2", "%zz", "a%g1"} {
+		if got, err := Unescape(id); err == nil {
+			t.Errorf("node.Unescape(%q) should have failed; got %q", id, got)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	table := []struct {
+		t, id string
+		v     bool
+	}{
+		{"/some/type", "some id", true},
+		{"/some/type", "a%3Cb%3E", true},
+		{"/_", "some_id", true},
+		{"some/type", "some_id", false},
+		{"/some/type/", "some_id", false},
+		{"/some type", "some_id", false},
+		{"/some/type", "", false},
+		{"/some/type", "a<b", false},
+		{"/some/type", "a>b", false},
+		{"/some/type", "x] /y", false},
+		{"/some/type", "two\nlines", false},
+		{"/some/type", "100%", false},
+		{"/some/type", "%zz", false},
+	}
+	for _, tc := range table {
+		if err := Valid(tc.t, tc.id); (err == nil) != tc.v {
+			t.Errorf("node.Valid(%q, %q) returned error %v; want valid %v", tc.t, tc.id, err, tc.v)
+		}
+	}
+}
+
+func mustType(t *testing.T, s string) *Type {
+	nt, err := NewType(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nt
+}
+
+func mustID(t *testing.T, s string) *ID {
+	id, err := NewID(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
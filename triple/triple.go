@@ -92,14 +92,19 @@ func ParseObject(s string, b literal.Builder) (*Object, error) {
 	if err == nil {
 		return NewNodeObject(n), nil
 	}
-	l, err := b.Parse(s)
-	if err == nil {
+	l, lErr := b.Parse(s)
+	if lErr == nil {
 		return NewLiteralObject(l), nil
 	}
 	o, err := predicate.Parse(s)
 	if err == nil {
 		return NewPredicateObject(o), nil
 	}
+	// Surface why the literal was rejected, for instance by a bounded builder,
+	// if the object is written as a literal.
+	if strings.LastIndex(s, "\"^^type:") > strings.LastIndex(s, "\"@[") {
+		return nil, lErr
+	}
 	return nil, err
 }
 
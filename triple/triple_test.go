@@ -15,6 +15,7 @@
 package triple
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/google/badwolf/triple/literal"
@@ -80,6 +81,53 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParseEscapedNodes(t *testing.T) {
+	for _, id := range []string{"Fire Escape", "a<b>", "x]\t/y", "two\nlines", "100%"} {
+		n, err := node.NewNodeFromStrings("/some/type", node.Escape(id))
+		if err != nil {
+			t.Fatalf("node.NewNodeFromStrings failed for escaped ID %q with error %v", id, err)
+		}
+		p, err := predicate.NewImmutable("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr, err := New(n, p, NewNodeObject(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Parse(tr.String(), literal.DefaultBuilder())
+		if err != nil {
+			t.Fatalf("triple.Parse failed to parse %q with error %v", tr, err)
+		}
+		if got.String() != tr.String() {
+			t.Errorf("triple.Parse returned %q; want %q", got, tr)
+		}
+		uid, err := node.Unescape(got.Subject().ID().String())
+		if err != nil || uid != id {
+			t.Errorf("node.Unescape returned %q, %v for the parsed subject; want %q", uid, err, id)
+		}
+	}
+}
+
+func TestParseSurfacesBuilderErrors(t *testing.T) {
+	b := literal.NewBoundedBuilder(5)
+	if _, err := Parse("/some/type<some id>\t\"foo\"@[]\t\"short\"^^type:text", b); err != nil {
+		t.Errorf("triple.Parse failed to parse a literal within the bound with error %v", err)
+	}
+	_, err := Parse("/some/type<some id>\t\"foo\"@[]\t\"too long\"^^type:text", b)
+	if err == nil {
+		t.Fatal("triple.Parse should have rejected a literal longer than the bound")
+	}
+	if !strings.Contains(err.Error(), "the maximum is 5 bytes") {
+		t.Errorf("triple.Parse returned %q; want the error of the literal builder", err)
+	}
+	// Objects written as predicates keep reporting the predicate errors.
+	_, err = Parse("/some/type<some id>\t\"foo\"@[]\t\"bar\"@[not a time]", b)
+	if err == nil || !strings.Contains(err.Error(), "predicate.Parse") {
+		t.Errorf("triple.Parse returned %v; want the error of the predicate", err)
+	}
+}
+
 func TestReifyImmutable(t *testing.T) {
 	tr, err := Parse("/some/type<some id>\t\"foo\"@[]\t\"bar\"@[]", literal.DefaultBuilder())
 	if err != nil {
@@ -165,6 +213,10 @@ func TestUUID(t *testing.T) {
 			"/some/type<some id>\t\"foo\"@[]\t\"[0 0 0]\"^^type:blob",
 			"/some/type<some id>\t\"foo\"@[]\t\"[0 0 0]\"^^type:blob",
 		},
+		{
+			"/some/type<some id>\t\"foo\"@[]\t\"AAAA\"^^type:blob",
+			"/some/type<some id>\t\"foo\"@[]\t\"[0 0 0]\"^^type:blob",
+		},
 	}
 	for _, entry := range testTable {
 		// Parse the triples.